@@ -0,0 +1,110 @@
+package fileWatcher
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// ErrAccessEventsUnsupported is returned by AddWithAccessEvents on platforms other than Linux.
+var ErrAccessEventsUnsupported = errors.New("access events are only supported on Linux")
+
+type accessWatch struct {
+	dir   bool
+	reads bool
+}
+
+type accessState struct {
+	mu       sync.Mutex
+	notifier *accessNotifier
+	paths    map[string]accessWatch
+}
+
+// AddWithAccessEvents adds path like Add and also reports the files opened, and read when reads is set, with ACCESSED
+// events: path itself for a file, the files directly in it for a directory. The accesses of the process itself are not
+// reported.
+//
+// Access events are meant for auditing and are only available on Linux, where they are read from fanotify, which
+// needs the CAP_SYS_ADMIN capability. Elsewhere ErrAccessEventsUnsupported is returned. A file being read produces an
+// event for every read call, as much as the consumers can take, so reads should only be reported for the paths they
+// are needed for.
+func (w *FileWatcher) AddWithAccessEvents(path string, reads bool) error {
+	path = w.normalize(path)
+	info, err := w.fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := w.Add(path); err != nil {
+		return err
+	}
+
+	w.access.mu.Lock()
+	defer w.access.mu.Unlock()
+	if w.access.notifier == nil {
+		notifier, err := newAccessNotifier()
+		if err != nil {
+			return err
+		}
+		w.access.notifier = notifier
+		w.access.paths = make(map[string]accessWatch)
+		go func() {
+			w.labelGoroutine("access-events")
+			notifier.run(w.accessed)
+		}()
+	}
+
+	watch := accessWatch{dir: info.IsDir(), reads: reads}
+	if previous, ok := w.access.paths[path]; ok {
+		if previous == watch {
+			return nil
+		}
+		_ = w.access.notifier.unmark(path, previous.dir, previous.reads)
+		delete(w.access.paths, path)
+	}
+	if err := w.access.notifier.mark(path, watch.dir, watch.reads); err != nil {
+		return err
+	}
+	w.access.paths[path] = watch
+	return nil
+}
+
+// removeAccess stops reporting the accesses of path.
+func (w *FileWatcher) removeAccess(path string) {
+	w.access.mu.Lock()
+	defer w.access.mu.Unlock()
+	watch, ok := w.access.paths[path]
+	if !ok {
+		return
+	}
+	delete(w.access.paths, path)
+	if err := w.access.notifier.unmark(path, watch.dir, watch.reads); err != nil {
+		w.log.Debug("Unable to stop reporting the accesses of ", path, ": ", err)
+	}
+}
+
+// accessed emits the ACCESSED event of path, accessed by the process pid.
+func (w *FileWatcher) accessed(path string, pid int) {
+	if path == "" {
+		w.log.Warn("Access events queue overflowed, some accesses were not reported")
+		return
+	}
+	if pid == os.Getpid() {
+		return
+	}
+	path = w.normalize(path)
+	if reason, ok := w.ignoreReason(path); ok {
+		w.trace(path, "ignored by %s", reason)
+		return
+	}
+	w.emit(FileWatcherEvent{Path: path, Event: EventAccessed})
+}
+
+func (w *FileWatcher) stopAccessEvents() {
+	w.access.mu.Lock()
+	defer w.access.mu.Unlock()
+	if w.access.notifier != nil {
+		_ = w.access.notifier.close()
+		w.access.notifier = nil
+		w.access.paths = nil
+	}
+}
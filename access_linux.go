@@ -0,0 +1,88 @@
+//go:build linux
+
+package fileWatcher
+
+import (
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+	"strconv"
+	"unsafe"
+)
+
+const metadataSize = int(unsafe.Sizeof(unix.FanotifyEventMetadata{}))
+
+// accessNotifier reports the opens and reads of files through fanotify, which needs the CAP_SYS_ADMIN capability.
+type accessNotifier struct {
+	file *os.File
+	fd   int
+}
+
+func newAccessNotifier() (*accessNotifier, error) {
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK, unix.O_RDONLY|unix.O_LARGEFILE)
+	if err != nil {
+		if errors.Is(err, unix.EPERM) {
+			return nil, errors.New("access events need the CAP_SYS_ADMIN capability")
+		}
+		return nil, os.NewSyscallError("fanotify_init", err)
+	}
+	// the non blocking descriptor is handled by the runtime poller, so closing the file unblocks the reader
+	return &accessNotifier{file: os.NewFile(uintptr(fd), "fanotify"), fd: fd}, nil
+}
+
+func accessMask(dir bool, reads bool) uint64 {
+	mask := uint64(unix.FAN_OPEN)
+	if reads {
+		mask |= unix.FAN_ACCESS
+	}
+	if dir {
+		mask |= unix.FAN_EVENT_ON_CHILD
+	}
+	return mask
+}
+
+// mark reports the accesses of path, or of the files of the directory path.
+func (n *accessNotifier) mark(path string, dir bool, reads bool) error {
+	err := unix.FanotifyMark(n.fd, unix.FAN_MARK_ADD, accessMask(dir, reads), unix.AT_FDCWD, path)
+	return os.NewSyscallError("fanotify_mark", err)
+}
+
+func (n *accessNotifier) unmark(path string, dir bool, reads bool) error {
+	err := unix.FanotifyMark(n.fd, unix.FAN_MARK_REMOVE, accessMask(dir, reads), unix.AT_FDCWD, path)
+	return os.NewSyscallError("fanotify_mark", err)
+}
+
+// run calls fn with the path of every accessed file and the process accessing it until the notifier is closed. Queue
+// overflows are reported with an empty path.
+func (n *accessNotifier) run(fn func(path string, pid int)) {
+	buf := make([]byte, 4096*metadataSize)
+	for {
+		count, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+metadataSize <= count; {
+			meta := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buf[offset]))
+			if int(meta.Event_len) < metadataSize {
+				break
+			}
+			offset += int(meta.Event_len)
+			if meta.Mask&unix.FAN_Q_OVERFLOW != 0 {
+				fn("", 0)
+				continue
+			}
+			if meta.Fd == unix.FAN_NOFD {
+				continue
+			}
+			path, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(int(meta.Fd)))
+			unix.Close(int(meta.Fd))
+			if err == nil {
+				fn(path, int(meta.Pid))
+			}
+		}
+	}
+}
+
+func (n *accessNotifier) close() error {
+	return n.file.Close()
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package fileWatcher
+
+// accessNotifier is not available on this platform.
+type accessNotifier struct{}
+
+func newAccessNotifier() (*accessNotifier, error) {
+	return nil, ErrAccessEventsUnsupported
+}
+
+func (n *accessNotifier) mark(path string, dir bool, reads bool) error {
+	return ErrAccessEventsUnsupported
+}
+
+func (n *accessNotifier) unmark(path string, dir bool, reads bool) error {
+	return ErrAccessEventsUnsupported
+}
+
+func (n *accessNotifier) run(fn func(path string, pid int)) {}
+
+func (n *accessNotifier) close() error {
+	return nil
+}
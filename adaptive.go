@@ -0,0 +1,121 @@
+package fileWatcher
+
+import (
+	"sync"
+	"time"
+)
+
+// AdaptiveOptions configures backpressure auto-tuning. Under load the coalescing window is widened so bursts are
+// folded into fewer events, and it is tightened again once consumers keep up.
+type AdaptiveOptions struct {
+	Enabled bool
+	// TargetLatency is the time a delivery may block on a slow consumer before the window is widened. It defaults to
+	// 10 milliseconds.
+	TargetLatency time.Duration
+	// MinWindow and MaxWindow bound the coalescing window. MaxWindow defaults to one second.
+	MinWindow time.Duration
+	MaxWindow time.Duration
+}
+
+type adaptiveState struct {
+	mu      sync.Mutex
+	window  time.Duration
+	latency time.Duration
+	started bool
+}
+
+// coalesceWindow returns the coalescing window currently in effect.
+func (w *FileWatcher) coalesceWindow() time.Duration {
+	if !w.options.Adaptive.Enabled {
+		return w.options.CoalesceWindow
+	}
+
+	w.adaptive.mu.Lock()
+	defer w.adaptive.mu.Unlock()
+	if !w.adaptive.started {
+		w.adaptive.started = true
+		w.adaptive.window = w.options.Adaptive.MinWindow
+		if w.options.CoalesceWindow > w.adaptive.window {
+			w.adaptive.window = w.options.CoalesceWindow
+		}
+	}
+	return w.adaptive.window
+}
+
+// deliver hands e to the subscriptions and sends it on Events, measuring how long it blocked when adaptive tuning is
+// enabled.
+func (w *FileWatcher) deliver(e FileWatcherEvent) {
+	w.checkOrder(e)
+	w.fanOut(e)
+	if w.prioritized(e) {
+		if w.sendPriority(e) {
+			w.observeDeliveryLatency(e)
+		}
+		return
+	}
+	if w.batching() {
+		w.addToBatch(e)
+		return
+	}
+	if !w.options.Adaptive.Enabled {
+		if w.sendEvent(e) {
+			w.observeDeliveryLatency(e)
+		}
+		return
+	}
+
+	start := time.Now()
+	if w.sendEvent(e) {
+		w.observeDelivery(time.Since(start))
+		w.observeDeliveryLatency(e)
+	}
+}
+
+// observeDelivery folds the blocking time of a delivery into a moving average and adjusts the window.
+func (w *FileWatcher) observeDelivery(d time.Duration) {
+	a := w.options.Adaptive
+	target := a.TargetLatency
+	if target <= 0 {
+		target = 10 * time.Millisecond
+	}
+	maxWindow := a.MaxWindow
+	if maxWindow <= 0 {
+		maxWindow = time.Second
+	}
+
+	w.adaptive.mu.Lock()
+	defer w.adaptive.mu.Unlock()
+
+	// exponentially weighted moving average over roughly the last eight deliveries
+	w.adaptive.latency += (d - w.adaptive.latency) / 8
+
+	switch {
+	case w.adaptive.latency > target && w.adaptive.window < maxWindow:
+		if w.adaptive.window == 0 {
+			w.adaptive.window = 10 * time.Millisecond
+		} else {
+			w.adaptive.window *= 2
+		}
+		if w.adaptive.window > maxWindow {
+			w.adaptive.window = maxWindow
+		}
+		w.log.Debug("Consumers are slow, widening the coalescing window to ", w.adaptive.window)
+	case w.adaptive.latency < target/4 && w.adaptive.window > a.MinWindow:
+		w.adaptive.window /= 2
+		if w.adaptive.window < a.MinWindow || w.adaptive.window < time.Millisecond {
+			w.adaptive.window = a.MinWindow
+		}
+		w.log.Debug("Consumers caught up, tightening the coalescing window to ", w.adaptive.window)
+	}
+}
+
+// adaptiveBatchSize scales the batch size of a sink with how full its queue is.
+func adaptiveBatchSize(base int, queued int, capacity int) int {
+	switch {
+	case queued > capacity*3/4:
+		return base * 4
+	case queued > capacity/2:
+		return base * 2
+	}
+	return base
+}
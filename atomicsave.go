@@ -0,0 +1,108 @@
+package fileWatcher
+
+import (
+	"sync"
+	"time"
+)
+
+// atomicSaveWindow is how long the rename of a file to a backup name waits for the new version of the file.
+const atomicSaveWindow = 500 * time.Millisecond
+
+// DefaultAtomicSavePatterns are the temporary files of the usual editors and atomic writers: the swap files and the
+// backups of vim, including the file it creates to check the folder is writable, the lock files and the backups of
+// Emacs, the output streams of GNOME and the *.tmp files of the atomic write libraries.
+var DefaultAtomicSavePatterns = []string{"*.swp", "*.swx", "*~", "4913", ".#*", "#*#", ".goutputstream-*", "*.tmp"}
+
+type heldBackup struct {
+	event FileWatcherEvent
+	timer Timer
+}
+
+type atomicSaveState struct {
+	// temporary matches the temporary files, it is nil unless the AtomicSavePatterns option is set.
+	temporary *patternSet
+	mu        sync.Mutex
+	// backups are the renames of files to a backup name, by the path of the file, held back waiting for its new
+	// version.
+	backups map[string]*heldBackup
+}
+
+// classifyAtomicSave turns the steps of an atomic save into a single event about the saved file, and reports whether
+// e has to be emitted at all. The events of the temporary files are dropped, the rename of a temporary file over the
+// saved file is an EDIT_FILE, or a CREATE_FILE when the file did not exist yet, and the rename of the saved file to a
+// backup name followed by its new version, like vim saves, is an EDIT_FILE.
+func (w *FileWatcher) classifyAtomicSave(e FileWatcherEvent) (FileWatcherEvent, bool) {
+	if w.atomicSave.temporary == nil {
+		return e, true
+	}
+	temporary := func(path string) bool {
+		return path != "" && w.atomicSave.temporary.match(path)
+	}
+
+	switch {
+	case temporary(e.Path) && (e.PreviousPath == "" || temporary(e.PreviousPath)):
+		w.trace(e.Path, "temporary file of an atomic save, nothing emitted")
+		return e, false
+	case e.Event == EventRenameFile && temporary(e.PreviousPath):
+		_, existed := w.listings.lookup(e.Path)
+		if w.releaseBackup(e.Path) {
+			existed = true
+		}
+		w.trace(e.Path, "saved atomically from the temporary file %s", e.PreviousPath)
+		e.PreviousPath = ""
+		if existed {
+			e.Event = EventEditFile
+		} else {
+			e.Event = EventCreateFile
+		}
+	case e.Event == EventRenameFile && temporary(e.Path):
+		w.holdBackup(e)
+		return e, false
+	case e.Event == EventCreateFile && w.releaseBackup(e.Path):
+		w.trace(e.Path, "new version of a file moved to a backup, emitting EDIT_FILE")
+		e.Event = EventEditFile
+	}
+	return e, true
+}
+
+// holdBackup holds back e, the rename of a file to a backup name. When no new version of the file shows up, the file
+// was renamed to a name whose events are dropped: for the consumers, it is deleted.
+func (w *FileWatcher) holdBackup(e FileWatcherEvent) {
+	w.trace(e.PreviousPath, "moved to the backup %s, waiting for its new version", e.Path)
+	path := e.PreviousPath
+	w.atomicSave.mu.Lock()
+	defer w.atomicSave.mu.Unlock()
+	if w.atomicSave.backups == nil {
+		w.atomicSave.backups = make(map[string]*heldBackup)
+	}
+	if previous, ok := w.atomicSave.backups[path]; ok {
+		previous.timer.Stop()
+	}
+	b := &heldBackup{event: e}
+	b.timer = w.clock().AfterFunc(atomicSaveWindow, func() {
+		w.atomicSave.mu.Lock()
+		current, ok := w.atomicSave.backups[path]
+		if ok && current == b {
+			delete(w.atomicSave.backups, path)
+		}
+		w.atomicSave.mu.Unlock()
+
+		if ok && current == b {
+			w.emit(FileWatcherEvent{Event: EventDeleteFile, Path: path})
+		}
+	})
+	w.atomicSave.backups[path] = b
+}
+
+// releaseBackup forgets the rename of path to a backup name being held back, once its new version showed up, and
+// reports whether there was one.
+func (w *FileWatcher) releaseBackup(path string) bool {
+	w.atomicSave.mu.Lock()
+	defer w.atomicSave.mu.Unlock()
+	b, ok := w.atomicSave.backups[path]
+	if ok {
+		b.timer.Stop()
+		delete(w.atomicSave.backups, path)
+	}
+	return ok
+}
@@ -0,0 +1,60 @@
+package fileWatcher
+
+import "os"
+
+// FileAttributes are the mode and the owner of a file or folder. UID and GID are -1 on the platforms without owners.
+type FileAttributes struct {
+	Mode os.FileMode `json:"mode"`
+	UID  int         `json:"uid"`
+	GID  int         `json:"gid"`
+}
+
+// AttributeChange is the change of the attributes of the path of a CHMOD event.
+type AttributeChange struct {
+	// Before is nil when the previous attributes of the path are unknown. They are recorded by the listing cache,
+	// for the entries of the watched directories.
+	Before *FileAttributes `json:"before,omitempty"`
+	After  FileAttributes  `json:"after"`
+}
+
+// Widened reports whether the change grants permissions the path didn't have, or sets the setuid or setgid bit. It
+// is false when the previous attributes are unknown.
+func (c *AttributeChange) Widened() bool {
+	if c == nil || c.Before == nil {
+		return false
+	}
+	special := os.ModeSetuid | os.ModeSetgid
+	return c.After.Mode.Perm()&^c.Before.Mode.Perm() != 0 || c.After.Mode&special&^c.Before.Mode != 0
+}
+
+// OwnerChanged reports whether the user or the group owning the path changed. It is false when the previous
+// attributes are unknown.
+func (c *AttributeChange) OwnerChanged() bool {
+	if c == nil || c.Before == nil {
+		return false
+	}
+	return c.After.UID != c.Before.UID || c.After.GID != c.Before.GID
+}
+
+// attributesOf returns the attributes of the file described by info.
+func attributesOf(info os.FileInfo) FileAttributes {
+	uid, gid, _ := ownerOf(info)
+	return FileAttributes{Mode: info.Mode(), UID: uid, GID: gid}
+}
+
+// fillAttributes sets the attributes of the path of the CHMOD event e, along with the ones recorded by the listing.
+func (w *FileWatcher) fillAttributes(e *FileWatcherEvent) {
+	if e.Event != EventChMod || e.Attributes != nil || w.Shedding() {
+		return
+	}
+	info, err := w.Stat(e.Path)
+	if err != nil {
+		return
+	}
+	change := &AttributeChange{After: attributesOf(info)}
+	if entry, ok := w.listings.lookup(e.Path); ok && entry.attrs != nil {
+		before := *entry.attrs
+		change.Before = &before
+	}
+	e.Attributes = change
+}
@@ -0,0 +1,77 @@
+package fileWatcher
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"time"
+)
+
+// Backend is how a FileWatcher learns about changes, set with WithBackend. NotifyBackend, the default, relies on the
+// change notifications of the operating system through fsnotify. On platforms without notifications, like wasip1 and
+// js/wasm, PollingBackend is the default. PollingBackend scans the watched paths at an interval
+// instead, for NFS and SMB mounts or containers where notifications are not delivered. A SyntheticFs reports the
+// changes made through it, for tests. All produce the same stream of events.
+type Backend interface {
+	// polls reports whether path is watched by polling.
+	polls(w *FileWatcher, path string) bool
+}
+
+// NotifyBackend watches paths with the change notifications of the operating system. Paths on network shares are
+// polled when the PollNetworkPaths option is set.
+type NotifyBackend struct{}
+
+func (NotifyBackend) polls(w *FileWatcher, path string) bool {
+	return w.options.PollNetworkPaths && isNetworkPath(path)
+}
+
+// PollingBackend watches every path by comparing its state at an interval.
+type PollingBackend struct {
+	// Interval defaults to two seconds.
+	Interval time.Duration
+	// Hash compares the content of files whose size and modification time did not change, for filesystems with a
+	// coarse modification time. Every polled file is read at every poll, so only use it for small trees.
+	Hash bool
+}
+
+func (PollingBackend) polls(*FileWatcher, string) bool {
+	return true
+}
+
+func (w *FileWatcher) backend() Backend {
+	if w.options.Backend != nil {
+		return w.options.Backend
+	}
+	if s, ok := w.fs.(*SyntheticFs); ok {
+		return s
+	}
+	return defaultBackend
+}
+
+// notifier is the source of the change notifications of a watcher, the fsnotify watcher unless the backend is a
+// SyntheticFs or the WindowsBufferSize option is set.
+type notifier interface {
+	Add(path string) error
+	Remove(path string) error
+	Close() error
+	notifications() (<-chan fsnotify.Event, <-chan error)
+}
+
+type fsnotifyNotifier struct {
+	*fsnotify.Watcher
+}
+
+func (n fsnotifyNotifier) notifications() (<-chan fsnotify.Event, <-chan error) {
+	return notifications(n.Watcher)
+}
+
+// newNotifier returns the notifier of the backend.
+func (w *FileWatcher) newNotifier() notifier {
+	if s, ok := w.backend().(*SyntheticFs); ok {
+		return s.newNotifier()
+	}
+	if w.options.WindowsBufferSize > 0 {
+		if n := newBufferedNotifier(w.options.WindowsBufferSize); n != nil {
+			return n
+		}
+	}
+	return fsnotifyNotifier{w.Watcher}
+}
@@ -0,0 +1,147 @@
+package fileWatcher
+
+import (
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Backfill is what a new subscriber receives before the live events, see SubscriptionOptions.
+type Backfill int
+
+const (
+	// BackfillNone only sends the live events.
+	BackfillNone Backfill = iota
+	// BackfillListing sends a CREATE_FILE or CREATE_FOLDER event for every entry below the path of the subscription,
+	// the current state of the tree. The walk races with the live events, an entry created meanwhile may be received
+	// twice.
+	BackfillListing
+	// BackfillHistory sends the events of the history since SubscriptionOptions.BackfillSince, see the HistorySize
+	// option. Nothing is backfilled without a history.
+	BackfillHistory
+)
+
+type backfillState struct {
+	mu sync.Mutex
+	// running is set until the backfill and the live events held back meanwhile were sent.
+	running bool
+	held    []FileWatcherEvent
+	// last is the Seq of the last event of the history backfill, the live events up to it were backfilled already.
+	last uint64
+}
+
+// queue holds e back while the backfill runs and reports whether it did.
+func (s *subscription) queue(e FileWatcherEvent) bool {
+	s.backfill.mu.Lock()
+	defer s.backfill.mu.Unlock()
+	if !s.backfill.running {
+		return false
+	}
+	s.backfill.held = append(s.backfill.held, e)
+	return true
+}
+
+// runBackfill sends the backfill, the BACKFILL_COMPLETE event and then the live events held back meanwhile.
+func (s *subscription) runBackfill() {
+	s.w.labelGoroutine("backfill")
+	var events []FileWatcherEvent
+	switch s.options.Backfill {
+	case BackfillListing:
+		events = s.listingBackfill()
+	case BackfillHistory:
+		events = s.historyBackfill()
+	}
+	for _, e := range events {
+		s.push(e)
+	}
+	s.push(FileWatcherEvent{Path: s.path, Event: EventBackfillComplete, Time: time.Now()})
+
+	for {
+		s.backfill.mu.Lock()
+		held := s.backfill.held
+		s.backfill.held = nil
+		if len(held) == 0 {
+			s.backfill.running = false
+		}
+		last := s.backfill.last
+		s.backfill.mu.Unlock()
+		if len(held) == 0 {
+			return
+		}
+		for _, e := range held {
+			if e.Seq != 0 && e.Seq <= last {
+				continue
+			}
+			s.forward(e)
+		}
+	}
+}
+
+// push hands e to the subscriber, whatever its TTL.
+func (s *subscription) push(e FileWatcherEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.events <- e:
+	case <-s.done:
+	case <-s.w.life.abandon:
+	}
+}
+
+// listingBackfill returns the create events of the entries below the path of the subscription.
+func (s *subscription) listingBackfill() []FileWatcherEvent {
+	w := s.w
+	var res []FileWatcherEvent
+	err := afero.Walk(w.fs, s.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if isSentinel(path) || w.ignored(path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path == s.path && info.IsDir() {
+			return nil
+		}
+		e := FileWatcherEvent{Path: path, Event: EventCreateFile, Time: time.Now(), Info: info}
+		if info.IsDir() {
+			e.Event = EventCreateFolder
+		}
+		if s.wants(e) {
+			res = append(res, e)
+		}
+		return nil
+	})
+	if err != nil {
+		w.log.Warn("Unable to backfill ", s.path, ": ", err)
+	}
+	return res
+}
+
+// historyBackfill returns the events of the history about the path of the subscription.
+func (s *subscription) historyBackfill() []FileWatcherEvent {
+	if s.w.history == nil {
+		s.w.log.Debug("No history to backfill " + s.path + " from")
+		return nil
+	}
+	events := s.w.history.since(s.options.BackfillSince)
+	if len(events) > 0 {
+		s.backfill.mu.Lock()
+		s.backfill.last = events[len(events)-1].Seq
+		s.backfill.mu.Unlock()
+	}
+	var res []FileWatcherEvent
+	for _, e := range events {
+		if s.wants(e) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
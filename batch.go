@@ -0,0 +1,71 @@
+package fileWatcher
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const defaultBatchSize = 256
+
+// BatchSink is a Sink that can take several events at once. FanOut hands it batches instead of single events.
+//
+// A batch is leased: on success the sink owns it and must call Release once it no longer references Events, which
+// can happen after SendBatch returned, for example when the batch is written asynchronously. When SendBatch returns
+// an error the caller keeps ownership, may retry with the same batch and releases it itself, so the sink must neither
+// keep nor release it.
+type BatchSink interface {
+	Sink
+	SendBatch(batch *EventBatch) error
+}
+
+// EventBatch is a group of events backed by a reusable arena.
+type EventBatch struct {
+	Events []FileWatcherEvent
+
+	arena    *batchArena
+	released int32
+}
+
+// Release hands the memory of the batch back to its arena. Events must not be used afterwards.
+func (b *EventBatch) Release() {
+	if !atomic.CompareAndSwapInt32(&b.released, 0, 1) {
+		log.Warn("Event batch released twice")
+		return
+	}
+	if b.arena != nil {
+		b.arena.put(b)
+	}
+}
+
+// batchArena recycles batches so high volume sinks don't allocate a slice per batch.
+type batchArena struct {
+	size int
+	pool sync.Pool
+}
+
+func newBatchArena(size int) *batchArena {
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	a := &batchArena{size: size}
+	a.pool.New = func() interface{} {
+		return &EventBatch{Events: make([]FileWatcherEvent, 0, size), arena: a}
+	}
+	return a
+}
+
+// lease returns an empty batch with room for size events.
+func (a *batchArena) lease() *EventBatch {
+	b := a.pool.Get().(*EventBatch)
+	atomic.StoreInt32(&b.released, 0)
+	return b
+}
+
+func (a *batchArena) put(b *EventBatch) {
+	// clear the events so the arena doesn't keep paths alive
+	for i := range b.Events {
+		b.Events[i] = FileWatcherEvent{}
+	}
+	b.Events = b.Events[:0]
+	a.pool.Put(b)
+}
@@ -0,0 +1,97 @@
+package fileWatcher
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FileWatcherBatch is a group of events that happened in a row, without a pause as long as the BatchQuietPeriod
+// option, like the events of a git checkout or of the extraction of an archive.
+type FileWatcherBatch struct {
+	Started time.Time
+	Ended   time.Time
+	// Events is the net effect of the events of the batch, see ChangeSet.NetEffect: a file created and then deleted
+	// inside the batch is not in it, a file created and then edited is only created.
+	Events []FileWatcherEvent
+	// Count is the number of events emitted during the batch, before they were folded into Events.
+	Count int
+}
+
+type batchState struct {
+	mu     sync.Mutex
+	events []FileWatcherEvent
+	start  time.Time
+	timer  Timer
+}
+
+// batching reports whether the events are delivered in batches.
+func (w *FileWatcher) batching() bool {
+	return w.options.BatchQuietPeriod > 0
+}
+
+// addToBatch adds e to the current batch, starting one when needed.
+func (w *FileWatcher) addToBatch(e FileWatcherEvent) {
+	w.batches.mu.Lock()
+	defer w.batches.mu.Unlock()
+
+	quiet := w.options.BatchQuietPeriod
+	if w.batches.timer == nil {
+		w.batches.start = time.Now()
+		w.batches.timer = w.afterFunc(quiet, w.flushBatch)
+	} else {
+		w.batches.timer.Reset(quiet)
+	}
+	w.batches.events = append(w.batches.events, e)
+}
+
+// flushBatch delivers the current batch.
+func (w *FileWatcher) flushBatch() {
+	w.labelGoroutine("batches")
+	w.batches.mu.Lock()
+	events := w.batches.events
+	start := w.batches.start
+	if w.batches.timer != nil {
+		w.batches.timer.Stop()
+	}
+	w.batches.events = nil
+	w.batches.timer = nil
+	w.batches.mu.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	b := &FileWatcherBatch{Started: start, Ended: time.Now(), Events: netEffect(events), Count: len(events)}
+	w.instrumentCoalesced(len(events), len(b.Events), "batch closed")
+	w.sendBatch(b)
+}
+
+// sendBatch delivers b on Batches unless the watcher is closed.
+func (w *FileWatcher) sendBatch(b *FileWatcherBatch) {
+	w.life.mu.RLock()
+	defer w.life.mu.RUnlock()
+	if w.life.closed {
+		w.countDroppedBatch(b, "watcher closed")
+		return
+	}
+	select {
+	case w.Batches <- b:
+		atomic.AddUint64(&w.counters.delivered, uint64(len(b.Events)))
+		return
+	default:
+	}
+	start := time.Now()
+	select {
+	case w.Batches <- b:
+		atomic.AddUint64(&w.counters.delivered, uint64(len(b.Events)))
+		w.instrument(TraceEntry{
+			Kind:     TraceBlocked,
+			Level:    TraceLevelWarn,
+			Message:  "waited for the consumer of Batches",
+			Count:    len(b.Events),
+			Duration: time.Since(start),
+		})
+	case <-w.life.abandon:
+		w.countDroppedBatch(b, "not received within the close timeout")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build darwin || freebsd || netbsd
+
+package fileWatcher
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// birthTime returns when the file described by info was created.
+func birthTime(_ string, info os.FileInfo) (time.Time, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(stat.Birthtimespec.Unix()), true
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package fileWatcher
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+	"syscall"
+	"time"
+)
+
+// birthTime returns when the file at path, described by info, was created. It needs statx and a filesystem recording
+// it, like ext4, btrfs or xfs.
+func birthTime(path string, info os.FileInfo) (time.Time, bool) {
+	if _, ok := info.Sys().(*syscall.Stat_t); !ok {
+		// not a file of the operating system
+		return time.Time{}, false
+	}
+	var stat unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &stat); err != nil {
+		return time.Time{}, false
+	}
+	if stat.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(stat.Btime.Sec, int64(stat.Btime.Nsec)), true
+}
@@ -0,0 +1,13 @@
+//go:build !darwin && !freebsd && !linux && !netbsd && !windows
+
+package fileWatcher
+
+import (
+	"os"
+	"time"
+)
+
+// birthTime returns false, the creation time of files is not available on this platform.
+func birthTime(string, os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package fileWatcher
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// birthTime returns when the file described by info was created.
+func birthTime(_ string, info os.FileInfo) (time.Time, bool) {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, data.CreationTime.Nanoseconds()), true
+}
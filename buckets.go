@@ -0,0 +1,131 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// defaultBucketInterval is the interval of the buckets of SubscribeBuckets when none is given.
+const defaultBucketInterval = time.Minute
+
+// EventBucket summarizes the events of an interval, see SubscribeBuckets.
+type EventBucket struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Counts is the number of events of each kind for each root, the outermost watched path at or above the path of the
+	// events. The events of the paths no longer watched are counted under an empty root.
+	Counts map[string]map[EventKind]uint64 `json:"counts"`
+	Total  uint64                          `json:"total"`
+}
+
+// Count returns the number of events of the kinds in kinds, like EventCreateFile | EventEditFile, seen below root. An
+// empty root counts the events of every root, zero kinds every kind.
+func (b EventBucket) Count(root string, kinds EventKind) uint64 {
+	var res uint64
+	for r, counts := range b.Counts {
+		if root != "" && r != root {
+			continue
+		}
+		for kind, n := range counts {
+			if kinds == 0 || kinds&kind != 0 {
+				res += n
+			}
+		}
+	}
+	return res
+}
+
+// SubscribeBuckets returns a channel receiving a summary of the events of every interval, the number of events of
+// each kind for each root, and a function ending the subscription and closing the channel. It gives dashboards and
+// capacity planning their figures without reading every event. The intervals are aligned on the clock, a minute long
+// when interval is not positive, and a bucket is sent for every interval, empty or not. When the watcher is closed,
+// the bucket of the interval so far is sent before the channel is closed.
+//
+// The events are counted by a subscription, see Subscribe: a bucket not received holds back the counting once the
+// subscription is full.
+func (w *FileWatcher) SubscribeBuckets(interval time.Duration) (<-chan EventBucket, func()) {
+	if interval <= 0 {
+		interval = defaultBucketInterval
+	}
+	events, unsubscribe := w.subscribe("", true, SubscriptionOptions{})
+	res := make(chan EventBucket, 16)
+	stop := make(chan struct{})
+
+	go func() {
+		w.labelGoroutine("buckets")
+		defer close(res)
+		clock := w.clock()
+		bucket := newEventBucket(clock.Now(), interval)
+		timer := clock.NewTimer()
+		defer timer.Stop()
+		timer.Reset(bucket.End.Sub(clock.Now()))
+
+		send := func(b EventBucket) bool {
+			select {
+			case res <- b:
+				return true
+			case <-stop:
+				return false
+			}
+		}
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					bucket.End = clock.Now()
+					send(bucket)
+					return
+				}
+				bucket.add(w.bucketRoot(e.Path), e.Event)
+			case now := <-timer.C():
+				if !send(bucket) {
+					return
+				}
+				bucket = newEventBucket(now, interval)
+				timer.Reset(bucket.End.Sub(clock.Now()))
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	once := sync.Once{}
+	return res, func() {
+		once.Do(func() {
+			close(stop)
+			unsubscribe()
+		})
+	}
+}
+
+// newEventBucket returns the empty bucket of the interval holding now.
+func newEventBucket(now time.Time, interval time.Duration) EventBucket {
+	start := now.Truncate(interval)
+	return EventBucket{Start: start, End: start.Add(interval), Counts: make(map[string]map[EventKind]uint64)}
+}
+
+func (b *EventBucket) add(root string, kind EventKind) {
+	counts, ok := b.Counts[root]
+	if !ok {
+		counts = make(map[EventKind]uint64)
+		b.Counts[root] = counts
+	}
+	counts[kind]++
+	b.Total++
+}
+
+// bucketRoot returns the outermost watched path at or above path, an empty string when there is none.
+func (w *FileWatcher) bucketRoot(path string) string {
+	root := ""
+	for current := filepath.Clean(path); ; {
+		if w.Contains(current) {
+			root = current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return root
+		}
+		current = parent
+	}
+}
@@ -0,0 +1,259 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// BulkOptions configures the detection of bulk operations: thousands of items appearing at once below a folder, like
+// an archive extracted or a repository cloned. A bulk operation is reported with BULK_OPERATION_STARTED for the folder,
+// BULK_OPERATION_PROGRESS every Progress while it goes on and BULK_OPERATION_FINISHED once it settled, all carrying
+// the counts so far in Bulk, so consumers doing work per file can switch to a bulk path.
+type BulkOptions struct {
+	// Threshold is how many items have to be created below a watched folder within Window to start a bulk operation
+	// for it. Zero disables the detection.
+	Threshold int
+	// Window defaults to a second.
+	Window time.Duration
+	// Quiet is how long nothing has to happen below the folder for the operation to be finished. It defaults to two
+	// seconds.
+	Quiet time.Duration
+	// Progress is the interval of the BULK_OPERATION_PROGRESS events, sent when the counts changed. It defaults to a
+	// second, a negative interval sends none.
+	Progress time.Duration
+	// Suppress drops the events of the items below the folder while the operation goes on. The events of the items
+	// created before it started were delivered already.
+	Suppress bool
+}
+
+// BulkProgress is the progress of a bulk operation, see BulkOptions.
+type BulkProgress struct {
+	// Path is the folder below which the operation happens.
+	Path string `json:"path"`
+	// Started is when the operation was detected.
+	Started time.Time `json:"started"`
+	// Files and Folders count the items created below the folder, the ones that started the operation included.
+	// Changes counts the other events below it.
+	Files   int `json:"files"`
+	Folders int `json:"folders"`
+	Changes int `json:"changes"`
+}
+
+type bulkOperation struct {
+	progress BulkProgress
+	// reported is the progress of the last event sent for the operation.
+	reported BulkProgress
+	quiet    Timer
+	tick     Timer
+}
+
+type bulkCount struct {
+	files, folders int
+}
+
+type bulkState struct {
+	mu sync.Mutex
+	// window is when the counting window started, counts the items created below every watched folder since.
+	window time.Time
+	counts map[string]*bulkCount
+	ops    map[string]*bulkOperation
+}
+
+// trackBulk counts the items created below the watched folders, starts a bulk operation once a folder got Threshold
+// of them within the window and counts the events of the operations in progress. It reports whether
+// e has to be emitted.
+func (w *FileWatcher) trackBulk(e FileWatcherEvent) bool {
+	opts := w.options.Bulk
+	if opts.Threshold <= 0 || e.Event.Is(EventBulkStarted|EventBulkProgress|EventBulkFinished) {
+		return true
+	}
+
+	w.bulk.mu.Lock()
+	for path, op := range w.bulk.ops {
+		if isBelow(e.Path, path) {
+			op.count(e)
+			op.quiet.Reset(w.bulkQuiet())
+			w.bulk.mu.Unlock()
+			return !opts.Suppress
+		}
+	}
+	if !isCreate(e) {
+		w.bulk.mu.Unlock()
+		return true
+	}
+
+	now := time.Now()
+	window := opts.Window
+	if window <= 0 {
+		window = time.Second
+	}
+	if w.bulk.counts == nil || now.Sub(w.bulk.window) >= window {
+		w.bulk.window = now
+		w.bulk.counts = make(map[string]*bulkCount)
+	}
+	reached := ""
+	for dir := filepath.Dir(e.Path); w.Contains(dir); dir = filepath.Dir(dir) {
+		c, ok := w.bulk.counts[dir]
+		if !ok {
+			c = &bulkCount{}
+			w.bulk.counts[dir] = c
+		}
+		if e.Event.Is(FolderEvents) {
+			c.folders++
+		} else {
+			c.files++
+		}
+		if reached == "" && c.files+c.folders >= opts.Threshold {
+			reached = dir
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	if reached == "" {
+		w.bulk.mu.Unlock()
+		return true
+	}
+
+	// the folders above the items count them too and reach the threshold first, the operation is for the deepest
+	// folder holding at least half of it, whose items no longer count for the folders above
+	root := reached
+	for dir, c := range w.bulk.counts {
+		if isBelow(dir, root) && 2*(c.files+c.folders) >= opts.Threshold {
+			root = dir
+		}
+	}
+	started := *w.bulk.counts[root]
+	for dir, c := range w.bulk.counts {
+		switch {
+		case dir == root || isBelow(dir, root):
+			delete(w.bulk.counts, dir)
+		case isBelow(root, dir):
+			c.files -= started.files
+			c.folders -= started.folders
+		}
+	}
+
+	// the operations below the folder are taken over by the new one
+	var finished []BulkProgress
+	op := &bulkOperation{progress: BulkProgress{Path: root, Started: w.clock().Now(), Files: started.files,
+		Folders: started.folders}}
+	for path, inner := range w.bulk.ops {
+		if isBelow(path, root) {
+			w.stopBulkOperation(path, inner)
+			op.progress.Changes += inner.progress.Changes
+			finished = append(finished, inner.progress)
+		}
+	}
+	op.reported = op.progress
+	op.quiet = w.afterFunc(w.bulkQuiet(), func() { w.finishBulk(root, op) })
+	if progress := w.bulkProgressInterval(); progress > 0 {
+		op.tick = w.afterFunc(progress, func() { w.reportBulk(op) })
+	}
+	if w.bulk.ops == nil {
+		w.bulk.ops = make(map[string]*bulkOperation)
+	}
+	w.bulk.ops[root] = op
+	w.bulk.mu.Unlock()
+
+	for _, p := range finished {
+		w.emitBulk(EventBulkFinished, p)
+	}
+	w.log.Debug("Bulk operation started below ", root)
+	w.emitBulk(EventBulkStarted, op.reported)
+	return !opts.Suppress
+}
+
+func (op *bulkOperation) count(e FileWatcherEvent) {
+	switch {
+	case isCreate(e) && e.Event.Is(FolderEvents):
+		op.progress.Folders++
+	case isCreate(e):
+		op.progress.Files++
+	default:
+		op.progress.Changes++
+	}
+}
+
+// reportBulk sends the progress of op when it changed and schedules the next report.
+func (w *FileWatcher) reportBulk(op *bulkOperation) {
+	w.bulk.mu.Lock()
+	if w.bulk.ops[op.progress.Path] != op {
+		w.bulk.mu.Unlock()
+		return
+	}
+	changed := op.progress != op.reported
+	op.reported = op.progress
+	progress := op.progress
+	op.tick.Reset(w.bulkProgressInterval())
+	w.bulk.mu.Unlock()
+
+	if changed {
+		w.emitBulk(EventBulkProgress, progress)
+	}
+}
+
+// finishBulk ends the operation of root once nothing happened below it for Quiet.
+func (w *FileWatcher) finishBulk(root string, op *bulkOperation) {
+	w.bulk.mu.Lock()
+	if w.bulk.ops[root] != op {
+		w.bulk.mu.Unlock()
+		return
+	}
+	w.stopBulkOperation(root, op)
+	progress := op.progress
+	w.bulk.mu.Unlock()
+
+	w.log.Debug("Bulk operation below ", root, " finished")
+	w.emitBulk(EventBulkFinished, progress)
+}
+
+// stopBulkOperation forgets the operation of root. The caller holds the lock.
+func (w *FileWatcher) stopBulkOperation(root string, op *bulkOperation) {
+	op.quiet.Stop()
+	if op.tick != nil {
+		op.tick.Stop()
+	}
+	delete(w.bulk.ops, root)
+}
+
+func (w *FileWatcher) emitBulk(kind EventKind, progress BulkProgress) {
+	w.emit(FileWatcherEvent{Path: progress.Path, Event: kind, Bulk: &progress})
+}
+
+func (w *FileWatcher) bulkQuiet() time.Duration {
+	if quiet := w.options.Bulk.Quiet; quiet > 0 {
+		return quiet
+	}
+	return 2 * time.Second
+}
+
+func (w *FileWatcher) bulkProgressInterval() time.Duration {
+	if progress := w.options.Bulk.Progress; progress != 0 {
+		return progress
+	}
+	return time.Second
+}
+
+// BulkOperations returns the progress of the bulk operations in progress, see BulkOptions.
+func (w *FileWatcher) BulkOperations() []BulkProgress {
+	w.bulk.mu.Lock()
+	defer w.bulk.mu.Unlock()
+	res := make([]BulkProgress, 0, len(w.bulk.ops))
+	for _, op := range w.bulk.ops {
+		res = append(res, op.progress)
+	}
+	return res
+}
+
+// stopBulk drops the bulk operations in progress, their BULK_OPERATION_FINISHED would come after the watcher is
+// closed.
+func (w *FileWatcher) stopBulk() {
+	w.bulk.mu.Lock()
+	defer w.bulk.mu.Unlock()
+	for root, op := range w.bulk.ops {
+		w.stopBulkOperation(root, op)
+	}
+	w.bulk.counts = nil
+}
@@ -0,0 +1,73 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBulkOperation(t *testing.T) {
+	const threshold = 50
+	root := t.TempDir()
+	sub := filepath.Join(root, "archive")
+	w := newTestWatcher(t, func(o *Options) {
+		o.Bulk = BulkOptions{Threshold: threshold, Window: 5 * time.Second, Quiet: 200 * time.Millisecond,
+			Progress: -1, Suppress: true}
+	})
+	if err := w.AddRecursive(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event == EventCreateFolder && e.Path == sub })
+
+	for i := 0; i < 2*threshold; i++ {
+		writeFile(t, filepath.Join(sub, fmt.Sprint(i)), "")
+	}
+
+	var started, finished *BulkProgress
+	delivered := 0
+	for finished == nil {
+		e := waitEvent(t, w, func(FileWatcherEvent) bool { return true })
+		switch e.Event {
+		case EventBulkStarted:
+			started = e.Bulk
+		case EventBulkFinished:
+			finished = e.Bulk
+		case EventCreateFile:
+			if started != nil {
+				t.Fatalf("%s delivered during the operation despite Suppress", e.Path)
+			}
+			delivered++
+		}
+	}
+	if started == nil || started.Path != sub || finished.Path != sub {
+		t.Fatalf("started %+v, finished %+v, want both for %s", started, finished, sub)
+	}
+	if delivered >= threshold || finished.Files < threshold || finished.Files > 2*threshold {
+		t.Fatalf("finished with %d files, %d delivered before the start", finished.Files, delivered)
+	}
+	if ops := w.BulkOperations(); len(ops) != 0 {
+		t.Fatalf("%d operations left once finished", len(ops))
+	}
+}
+
+func TestBulkBelowThreshold(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t, WithBulkDetection(50, time.Second, true))
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		writeFile(t, filepath.Join(root, fmt.Sprint(i)), "")
+	}
+	for i := 0; i < 10; i++ {
+		e := waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event != EventChMod })
+		if e.Event != EventCreateFile {
+			t.Fatalf("got %s %s, want the creates", e.Event, e.Path)
+		}
+	}
+}
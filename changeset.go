@@ -0,0 +1,287 @@
+package fileWatcher
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrChangeSetOpen is returned by BeginChangeSet when a change set has already been started.
+var ErrChangeSetOpen = errors.New("a change set is already open")
+
+// ChangeSet is a group of events that happened together, either between BeginChangeSet and EndChangeSet or within
+// a burst detected by the ChangeSetQuietPeriod option.
+type ChangeSet struct {
+	Started time.Time
+	Ended   time.Time
+	// Events holds every event of the change set in the order it was emitted.
+	Events []FileWatcherEvent
+	// Truncated is set when events were left out of the change set because of the memory budget.
+	Truncated bool
+}
+
+// NetEffect returns the events needed to get from the state before the change set to the state after it. A file
+// created and then deleted inside the change set nets to nothing, a create followed by edits nets to a single create
+// and so on.
+func (c *ChangeSet) NetEffect() []FileWatcherEvent {
+	return netEffect(c.Events)
+}
+
+type changeSetState struct {
+	mu       sync.Mutex
+	explicit *ChangeSet
+	burst    *ChangeSet
+	timer    Timer
+}
+
+// BeginChangeSet starts collecting every emitted event into a change set until EndChangeSet is called.
+func (w *FileWatcher) BeginChangeSet() error {
+	w.changeSets.mu.Lock()
+	defer w.changeSets.mu.Unlock()
+
+	if w.changeSets.explicit != nil {
+		return ErrChangeSetOpen
+	}
+	w.changeSets.explicit = &ChangeSet{Started: time.Now()}
+	return nil
+}
+
+// EndChangeSet closes the change set opened by BeginChangeSet and returns it. It returns nil if no change set was
+// open.
+func (w *FileWatcher) EndChangeSet() *ChangeSet {
+	w.changeSets.mu.Lock()
+	defer w.changeSets.mu.Unlock()
+
+	cs := w.changeSets.explicit
+	if cs != nil {
+		cs.Ended = time.Now()
+		w.changeSets.explicit = nil
+		w.release(cs.Events)
+	}
+	return cs
+}
+
+// recordChangeSet adds e to the open change set, or to the current burst when burst detection is enabled.
+func (w *FileWatcher) recordChangeSet(e FileWatcherEvent) {
+	w.changeSets.mu.Lock()
+	defer w.changeSets.mu.Unlock()
+
+	quiet := w.options.ChangeSetQuietPeriod
+	if w.changeSets.explicit == nil && quiet <= 0 {
+		return
+	}
+
+	if !w.reserve(e) {
+		// the event is still delivered on Events, it is only missing from the change set
+		if !w.overBudget(e) {
+			atomic.AddUint64(&w.memory.dropped, 1)
+		}
+		if w.changeSets.explicit != nil {
+			w.changeSets.explicit.Truncated = true
+		} else if w.changeSets.burst != nil {
+			w.changeSets.burst.Truncated = true
+		}
+		return
+	}
+
+	if w.changeSets.explicit != nil {
+		w.changeSets.explicit.Events = append(w.changeSets.explicit.Events, e)
+		return
+	}
+
+	if w.changeSets.burst == nil {
+		w.changeSets.burst = &ChangeSet{Started: time.Now()}
+		w.changeSets.timer = w.afterFunc(quiet, w.flushBurst)
+	} else {
+		w.changeSets.timer.Reset(quiet)
+	}
+	w.changeSets.burst.Events = append(w.changeSets.burst.Events, e)
+}
+
+func (w *FileWatcher) flushBurst() {
+	w.labelGoroutine("change-sets")
+	w.changeSets.mu.Lock()
+	cs := w.changeSets.burst
+	w.changeSets.burst = nil
+	if w.changeSets.timer != nil {
+		w.changeSets.timer.Stop()
+		w.changeSets.timer = nil
+	}
+	w.changeSets.mu.Unlock()
+
+	if cs == nil {
+		return
+	}
+	w.release(cs.Events)
+	cs.Ended = time.Now()
+	w.sendChangeSet(cs)
+}
+
+func isFolderEvent(e FileWatcherEvent) bool {
+	return e.Event.Is(FolderEvents)
+}
+
+func isCreate(e FileWatcherEvent) bool {
+	return e.Event.Is(createKinds)
+}
+
+func isDelete(e FileWatcherEvent) bool {
+	return e.Event.Is(deleteKinds)
+}
+
+func isRename(e FileWatcherEvent) bool {
+	return e.Event.Is(EventRenameFile | EventRenameFolder)
+}
+
+// netEffect folds a sequence of events into the smallest sequence with the same end result. The result keeps one
+// event per path, ordered by when that path was last touched. The events of the items below a folder deleted or moved
+// out are dropped with it, and the ones below a renamed folder follow it to its new path, after its rename.
+func netEffect(events []FileWatcherEvent) []FileWatcherEvent {
+	type entry struct {
+		event FileWatcherEvent
+		order int
+	}
+
+	state := make(map[string]*entry)
+	order := 0
+	set := func(e FileWatcherEvent) {
+		order++
+		state[e.Path] = &entry{event: e, order: order}
+	}
+	// takeBelow removes the entries below dir and returns them in order
+	takeBelow := func(dir string) []*entry {
+		var res []*entry
+		for path, en := range state {
+			if isBelow(path, dir) {
+				res = append(res, en)
+				delete(state, path)
+			}
+		}
+		sort.Slice(res, func(i, j int) bool {
+			return res[i].order < res[j].order
+		})
+		return res
+	}
+	// moveBelow sets the entries taken below the folder renamed from from to to, at their new path
+	moveBelow := func(children []*entry, from string, to string) {
+		for _, child := range children {
+			next := child.event
+			rel, _ := filepath.Rel(from, next.Path)
+			next.Path = filepath.Join(to, rel)
+			if isBelow(next.PreviousPath, from) {
+				rel, _ = filepath.Rel(from, next.PreviousPath)
+				next.PreviousPath = filepath.Join(to, rel)
+			}
+			set(next)
+		}
+	}
+
+	for _, e := range events {
+		if isDelete(e) && isFolderEvent(e) {
+			for _, child := range takeBelow(e.Path) {
+				if isRename(child.event) && !isBelow(child.event.PreviousPath, e.Path) {
+					// moved into the folder from outside of it, its original path is gone too
+					gone := child.event
+					gone.Path, gone.PreviousPath = child.event.PreviousPath, ""
+					gone.Event = EventDeleteFile
+					if isFolderEvent(child.event) {
+						gone.Event = EventDeleteFolder
+					}
+					set(gone)
+				}
+			}
+		}
+		current, seen := state[e.Path]
+
+		switch {
+		case isRename(e):
+			var children []*entry
+			if isFolderEvent(e) {
+				children = takeBelow(e.PreviousPath)
+			}
+			old, oldSeen := state[e.PreviousPath]
+			delete(state, e.PreviousPath)
+			next := e
+			if oldSeen {
+				switch {
+				case isCreate(old.event):
+					// created and then renamed, it is a create of the new path
+					next.Event = old.event.Event
+					next.PreviousPath = ""
+				case isRename(old.event):
+					// renamed twice, keep the original location
+					next.PreviousPath = old.event.PreviousPath
+					if next.PreviousPath == next.Path {
+						// renamed back to where it started
+						moveBelow(children, e.PreviousPath, e.Path)
+						continue
+					}
+				default:
+					// the old path was modified before it was moved, report it as gone and the new one as new
+					gone := old.event
+					gone.Path = e.PreviousPath
+					gone.PreviousPath = ""
+					if isFolderEvent(e) {
+						gone.Event = EventDeleteFolder
+						next.Event = EventCreateFolder
+					} else {
+						gone.Event = EventDeleteFile
+						next.Event = EventCreateFile
+					}
+					next.PreviousPath = ""
+					set(gone)
+				}
+			}
+			set(next)
+			moveBelow(children, e.PreviousPath, e.Path)
+		case !seen:
+			set(e)
+		case isCreate(current.event):
+			if isDelete(e) {
+				// created and deleted inside the window, nothing happened
+				delete(state, e.Path)
+			}
+			// edits and chmods of a new item are part of its creation
+		case isRename(current.event) && isDelete(e):
+			// renamed and then deleted, what is gone is the item at its original path
+			gone := e
+			gone.Path = current.event.PreviousPath
+			delete(state, e.Path)
+			if created, ok := state[gone.Path]; ok && isCreate(created.event) {
+				// an item was created at the original path since, it replaced the item
+				if !isFolderEvent(created.event) {
+					created.event.Event = EventEditFile
+				}
+				continue
+			}
+			set(gone)
+		case isDelete(current.event):
+			if isCreate(e) {
+				next := e
+				if !isFolderEvent(e) {
+					next.Event = EventEditFile
+				}
+				set(next)
+			}
+		case current.event.Event == EventChMod:
+			set(e)
+		default:
+			if e.Event == EventChMod {
+				continue
+			}
+			set(e)
+		}
+	}
+
+	res := make([]FileWatcherEvent, 0, len(state))
+	for _, en := range state {
+		res = append(res, en.event)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return state[res[i].Path].order < state[res[j].Path].order
+	})
+	return res
+}
@@ -0,0 +1,146 @@
+package fileWatcher
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNetEffect(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []FileWatcherEvent
+		want   []FileWatcherEvent
+	}{
+		{"created and deleted", []FileWatcherEvent{
+			{Event: EventCreateFile, Path: "/a"}, {Event: EventEditFile, Path: "/a"},
+			{Event: EventDeleteFile, Path: "/a"},
+		}, []FileWatcherEvent{}},
+		{"created and edited", []FileWatcherEvent{
+			{Event: EventCreateFile, Path: "/a"}, {Event: EventEditFile, Path: "/a"}, {Event: EventChMod, Path: "/a"},
+		}, []FileWatcherEvent{{Event: EventCreateFile, Path: "/a"}}},
+		{"deleted and created again", []FileWatcherEvent{
+			{Event: EventDeleteFile, Path: "/a"}, {Event: EventCreateFile, Path: "/a"},
+		}, []FileWatcherEvent{{Event: EventEditFile, Path: "/a"}}},
+		{"created and renamed", []FileWatcherEvent{
+			{Event: EventCreateFile, Path: "/a"}, {Event: EventRenameFile, Path: "/b", PreviousPath: "/a"},
+		}, []FileWatcherEvent{{Event: EventCreateFile, Path: "/b"}}},
+		{"renamed twice", []FileWatcherEvent{
+			{Event: EventRenameFile, Path: "/b", PreviousPath: "/a"},
+			{Event: EventRenameFile, Path: "/c", PreviousPath: "/b"},
+		}, []FileWatcherEvent{{Event: EventRenameFile, Path: "/c", PreviousPath: "/a"}}},
+		{"renamed back", []FileWatcherEvent{
+			{Event: EventRenameFile, Path: "/b", PreviousPath: "/a"},
+			{Event: EventRenameFile, Path: "/a", PreviousPath: "/b"},
+		}, []FileWatcherEvent{}},
+		{"edited and renamed", []FileWatcherEvent{
+			{Event: EventEditFile, Path: "/a"}, {Event: EventRenameFile, Path: "/b", PreviousPath: "/a"},
+		}, []FileWatcherEvent{{Event: EventDeleteFile, Path: "/a"}, {Event: EventCreateFile, Path: "/b"}}},
+		{"ordered by last touch", []FileWatcherEvent{
+			{Event: EventEditFile, Path: "/a"}, {Event: EventEditFile, Path: "/b"}, {Event: EventEditFile, Path: "/a"},
+		}, []FileWatcherEvent{{Event: EventEditFile, Path: "/b"}, {Event: EventEditFile, Path: "/a"}}},
+		{"folder moved out with a new file", []FileWatcherEvent{
+			{Event: EventCreateFolder, Path: "/r/d"}, {Event: EventCreateFile, Path: "/r/d/f"},
+			{Event: EventMovedOutFolder, Path: "/r/d"},
+		}, []FileWatcherEvent{}},
+		{"folder deleted after edits below it", []FileWatcherEvent{
+			{Event: EventEditFile, Path: "/r/d/f"}, {Event: EventCreateFile, Path: "/r/d/sub/g"},
+			{Event: EventDeleteFolder, Path: "/r/d"},
+		}, []FileWatcherEvent{{Event: EventDeleteFolder, Path: "/r/d"}}},
+		{"file moved into a folder deleted then", []FileWatcherEvent{
+			{Event: EventRenameFile, Path: "/r/d/f", PreviousPath: "/r/f"}, {Event: EventDeleteFolder, Path: "/r/d"},
+		}, []FileWatcherEvent{{Event: EventDeleteFile, Path: "/r/f"}, {Event: EventDeleteFolder, Path: "/r/d"}}},
+		{"folder renamed after changes below it", []FileWatcherEvent{
+			{Event: EventCreateFile, Path: "/r/d/f"}, {Event: EventEditFile, Path: "/r/d/g"},
+			{Event: EventRenameFile, Path: "/r/d/b", PreviousPath: "/r/d/a"},
+			{Event: EventRenameFolder, Path: "/r/e", PreviousPath: "/r/d"},
+		}, []FileWatcherEvent{
+			{Event: EventRenameFolder, Path: "/r/e", PreviousPath: "/r/d"}, {Event: EventCreateFile, Path: "/r/e/f"},
+			{Event: EventEditFile, Path: "/r/e/g"}, {Event: EventRenameFile, Path: "/r/e/b", PreviousPath: "/r/e/a"},
+		}},
+		{"new folder renamed", []FileWatcherEvent{
+			{Event: EventCreateFolder, Path: "/r/d"}, {Event: EventCreateFile, Path: "/r/d/f"},
+			{Event: EventRenameFolder, Path: "/r/e", PreviousPath: "/r/d"},
+		}, []FileWatcherEvent{{Event: EventCreateFolder, Path: "/r/e"}, {Event: EventCreateFile, Path: "/r/e/f"}}},
+		{"renamed and deleted", []FileWatcherEvent{
+			{Event: EventRenameFile, Path: "/b", PreviousPath: "/a"}, {Event: EventDeleteFile, Path: "/b"},
+		}, []FileWatcherEvent{{Event: EventDeleteFile, Path: "/a"}}},
+	}
+	for _, test := range tests {
+		if got := netEffect(test.events); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExplicitChangeSet(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWatcher(t)
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.BeginChangeSet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.BeginChangeSet(); err != ErrChangeSetOpen {
+		t.Fatalf("second BeginChangeSet: %v", err)
+	}
+
+	kept, temporary := filepath.Join(dir, "kept"), filepath.Join(dir, "temporary")
+	writeFile(t, kept, "")
+	waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event == EventCreateFile && e.Path == kept })
+	writeFile(t, temporary, "")
+	waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event == EventCreateFile && e.Path == temporary })
+	if err := os.Remove(temporary); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event == EventDeleteFile && e.Path == temporary })
+
+	cs := w.EndChangeSet()
+	if cs == nil || len(cs.Events) < 3 {
+		t.Fatalf("change set %+v", cs)
+	}
+	net := cs.NetEffect()
+	if len(net) != 1 || net[0].Event != EventCreateFile || net[0].Path != kept {
+		t.Fatalf("net effect %v", net)
+	}
+	if w.EndChangeSet() != nil {
+		t.Fatal("a change set was ended twice")
+	}
+}
+
+func TestChangeSetBurst(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWatcher(t, WithChangeSetBurst(100*time.Millisecond))
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for range w.Events {
+		}
+	}()
+
+	paths := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")}
+	for _, path := range paths {
+		writeFile(t, path, "")
+	}
+	created := make(map[string]bool)
+	timeout := time.After(testTimeout)
+	for len(created) < len(paths) {
+		select {
+		case cs := <-w.ChangeSets:
+			if cs.Ended.Before(cs.Started) {
+				t.Fatalf("change set ended at %s before it started at %s", cs.Ended, cs.Started)
+			}
+			for _, e := range cs.NetEffect() {
+				if e.Event == EventCreateFile {
+					created[e.Path] = true
+				}
+			}
+		case <-timeout:
+			t.Fatalf("%d of the %d creates in the change sets", len(created), len(paths))
+		}
+	}
+}
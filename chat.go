@@ -0,0 +1,282 @@
+package fileWatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultChatTemplate is the message of an event posted by ChatSink when no template is set.
+const DefaultChatTemplate = `*{{ .Event }}* ` + "`{{ .Path }}`" + `{{ with .PreviousPath }} (from ` + "`{{ . }}`" +
+	`){{ end }}{{ with .Host }} on {{ . }}{{ end }}`
+
+// DefaultChatSummaryTemplate is the message summarizing a burst of events posted by ChatSink when no summary template
+// is set. It is executed on a ChatSummary.
+const DefaultChatSummaryTemplate = `*{{ .Count }} more change{{ if ne .Count 1 }}s{{ end }}* under ` + "`{{ .Dir }}`" +
+	` in {{ .Ended.Sub .Started | duration }}: {{ .KindList }}`
+
+// ChatChannel is a chat channel ChatSink posts to through an incoming webhook of Slack or Microsoft Teams, which both
+// accept a JSON payload with a text field.
+type ChatChannel struct {
+	Name string
+	URL  string
+	// Pattern is a glob matched against the event path, with the syntax of Router. An empty pattern matches all paths.
+	Pattern string
+	// Events limits the channel to these event types, like "DELETE_FILE". An empty list matches every event type.
+	Events []string
+}
+
+// ChatOptions configures a ChatSink.
+type ChatOptions struct {
+	// Template formats the message of an event, see ParseEventTemplate. It defaults to DefaultChatTemplate.
+	Template *template.Template
+	// SummaryTemplate formats the message summarizing a burst, it is executed on a ChatSummary. It defaults to
+	// DefaultChatSummaryTemplate.
+	SummaryTemplate *template.Template
+	// BurstWindow and BurstLimit summarize bursts: once BurstLimit events were posted to a channel within BurstWindow,
+	// the following ones are held back and summarized in a single message at the end of the window. Zero disables the
+	// summaries, a zero BurstLimit defaults to 5.
+	BurstWindow time.Duration
+	BurstLimit  int
+	Client      *http.Client
+}
+
+// ChatSummary describes the events of a channel held back during a burst.
+type ChatSummary struct {
+	Channel string
+	Count   int
+	// Dir is the deepest directory holding every path of the burst.
+	Dir string
+	// Kinds counts the events per type.
+	Kinds   map[string]int
+	Events  []FileWatcherEvent
+	Started time.Time
+	Ended   time.Time
+}
+
+// KindList returns the counts of Kinds from the most frequent, like "12 EDIT_FILE, 3 CREATE_FILE".
+func (s ChatSummary) KindList() string {
+	return kindList(s.Kinds)
+}
+
+// kindList formats counts per event type from the most frequent.
+func kindList(counts map[string]int) string {
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if counts[kinds[i]] != counts[kinds[j]] {
+			return counts[kinds[i]] > counts[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+	for i, kind := range kinds {
+		kinds[i] = fmt.Sprint(counts[kind], " ", kind)
+	}
+	return strings.Join(kinds, ", ")
+}
+
+// ChatSink posts human-readable messages about events to chat channels, routing every event to each channel matching
+// it, and summarizes bursts so a large change doesn't flood the channels.
+type ChatSink struct {
+	options  ChatOptions
+	channels []ChatChannel
+
+	mu     sync.Mutex
+	bursts map[int]*chatBurst
+	closed bool
+}
+
+// chatBurst is the current burst window of a channel.
+type chatBurst struct {
+	started time.Time
+	posted  int
+	held    []FileWatcherEvent
+	timer   *time.Timer
+}
+
+// NewChatSink creates a ChatSink posting to channels.
+func NewChatSink(options ChatOptions, channels ...ChatChannel) (*ChatSink, error) {
+	for _, channel := range channels {
+		if channel.URL == "" {
+			return nil, errors.New("chat channel " + channel.Name + " has no URL")
+		}
+		if channel.Pattern != "" {
+			if err := validPattern(channel.Pattern); err != nil {
+				return nil, fmt.Errorf("chat channel %s: invalid pattern %q: %w", channel.Name, channel.Pattern, err)
+			}
+		}
+	}
+	if options.Template == nil {
+		options.Template = template.Must(ParseEventTemplate(DefaultChatTemplate))
+	}
+	if options.SummaryTemplate == nil {
+		options.SummaryTemplate = template.Must(ParseEventTemplate(DefaultChatSummaryTemplate))
+	}
+	if options.BurstLimit <= 0 {
+		options.BurstLimit = 5
+	}
+	if options.Client == nil {
+		options.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &ChatSink{
+		options:  options,
+		channels: channels,
+		bursts:   make(map[int]*chatBurst),
+	}, nil
+}
+
+// Send posts e to every channel it matches, or holds it back for the summary of a burst. It returns the first error
+// of the posts.
+func (s *ChatSink) Send(e FileWatcherEvent) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	var firstErr error
+	for i, channel := range s.channels {
+		if !channel.matches(e) || s.hold(i, e) {
+			continue
+		}
+		msg, err := FormatEvent(s.options.Template, e)
+		if err == nil {
+			err = s.post(channel, string(msg))
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (c ChatChannel) matches(e FileWatcherEvent) bool {
+	return Route{Pattern: c.Pattern, Events: c.Events}.matches(e)
+}
+
+// hold counts e in the burst window of the channel at index i and reports whether it is held back for the summary.
+func (s *ChatSink) hold(i int, e FileWatcherEvent) bool {
+	if s.options.BurstWindow <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+
+	burst, ok := s.bursts[i]
+	if !ok {
+		burst = &chatBurst{started: e.Time}
+		burst.timer = time.AfterFunc(s.options.BurstWindow, func() {
+			s.endBurst(i)
+		})
+		s.bursts[i] = burst
+	}
+	if burst.posted < s.options.BurstLimit {
+		burst.posted++
+		return false
+	}
+	burst.held = append(burst.held, e)
+	return true
+}
+
+// endBurst closes the burst window of the channel at index i, posting the summary of the events held back.
+func (s *ChatSink) endBurst(i int) {
+	labelGoroutine("chat-burst")
+	s.mu.Lock()
+	burst := s.bursts[i]
+	delete(s.bursts, i)
+	s.mu.Unlock()
+	if burst == nil || len(burst.held) == 0 {
+		return
+	}
+	_ = s.postSummary(s.channels[i], burst)
+}
+
+func (s *ChatSink) postSummary(channel ChatChannel, burst *chatBurst) error {
+	summary := ChatSummary{
+		Channel: channel.Name,
+		Count:   len(burst.held),
+		Kinds:   make(map[string]int),
+		Events:  burst.held,
+		Started: burst.started,
+		Ended:   burst.held[len(burst.held)-1].Time,
+	}
+	for _, e := range burst.held {
+		summary.Kinds[e.Event.String()]++
+		summary.Dir = commonDir(summary.Dir, e.Path)
+	}
+
+	buf := bytes.Buffer{}
+	if err := s.options.SummaryTemplate.Execute(&buf, summary); err != nil {
+		return err
+	}
+	return s.post(channel, buf.String())
+}
+
+// commonDir returns the deepest directory holding dir and path, dir is empty for the first path.
+func commonDir(dir string, path string) string {
+	if dir == "" {
+		return filepath.Dir(path)
+	}
+	for dir != path && !isBelow(path, dir) && filepath.Dir(dir) != dir {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// post sends text to the incoming webhook of channel.
+func (s *ChatSink) post(channel ChatChannel, text string) error {
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{Text: text})
+	if err != nil {
+		return err
+	}
+	resp, err := s.options.Client.Post(channel.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("chat channel %s answered with status %d", channel.Name, resp.StatusCode)
+	}
+	return nil
+}
+
+// Close posts the summaries of the bursts in progress, the events sent afterwards are posted right away. It returns
+// the first error of the posts.
+func (s *ChatSink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	bursts := s.bursts
+	s.bursts = make(map[int]*chatBurst)
+	s.mu.Unlock()
+
+	var firstErr error
+	for i, channel := range s.channels {
+		burst, ok := bursts[i]
+		if !ok {
+			continue
+		}
+		burst.timer.Stop()
+		if len(burst.held) == 0 {
+			continue
+		}
+		if err := s.postSummary(channel, burst); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
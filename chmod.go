@@ -0,0 +1,103 @@
+package fileWatcher
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ChmodPolicy decides which CHMOD events are delivered. Spotlight, Time Machine and some editors touch the attributes
+// of the files they read in bursts, on macOS mostly, flooding the consumers with CHMOD events changing nothing.
+type ChmodPolicy int
+
+const (
+	// ChmodDeliver delivers every CHMOD event, the default.
+	ChmodDeliver ChmodPolicy = iota
+	// ChmodDrop drops every CHMOD event.
+	ChmodDrop
+	// ChmodChanged only delivers the CHMOD events changing the mode or the owner of their path. The first event of a
+	// path whose attributes are not known yet is delivered.
+	ChmodChanged
+	// ChmodCooldown delivers a single CHMOD event per path every Cooldown, and the events changing the mode or the
+	// owner of their path.
+	ChmodCooldown
+)
+
+// ChmodOptions configures the suppression of the CHMOD events.
+type ChmodOptions struct {
+	Policy ChmodPolicy
+	// Cooldown is how long the CHMOD events of a path are dropped after one was delivered, with ChmodCooldown. It
+	// defaults to a second.
+	Cooldown time.Duration
+}
+
+type chmodState struct {
+	mu sync.Mutex
+	// paths holds the attributes of the paths of the last CHMOD events delivered.
+	paths map[string]chmodEntry
+}
+
+type chmodEntry struct {
+	attrs     FileAttributes
+	delivered time.Time
+}
+
+// suppressChmod applies the Chmod option to a CHMOD event of path, and reports whether it is dropped. The event of a
+// path that can't be read is delivered, the classification tells what happened to it.
+func (w *FileWatcher) suppressChmod(path string) bool {
+	policy := w.options.Chmod.Policy
+	if policy == ChmodDeliver {
+		return false
+	}
+	if policy == ChmodDrop {
+		atomic.AddUint64(&w.counters.chmodSuppressed, 1)
+		return true
+	}
+
+	info, err := w.Stat(path)
+	if err != nil {
+		return false
+	}
+	attrs := attributesOf(info)
+	now := w.clock().Now()
+
+	w.chmod.mu.Lock()
+	defer w.chmod.mu.Unlock()
+	last, known := w.chmod.paths[path]
+	if !known {
+		if entry, ok := w.listings.lookup(path); ok && entry.attrs != nil {
+			last, known = chmodEntry{attrs: *entry.attrs}, true
+		}
+	}
+	changed := !known || last.attrs != attrs
+	cooled := policy == ChmodCooldown && now.Sub(last.delivered) >= w.chmodCooldown()
+	if !changed && !cooled {
+		atomic.AddUint64(&w.counters.chmodSuppressed, 1)
+		return true
+	}
+	if w.chmod.paths == nil {
+		w.chmod.paths = make(map[string]chmodEntry)
+	}
+	w.chmod.paths[path] = chmodEntry{attrs: attrs, delivered: now}
+	return false
+}
+
+func (w *FileWatcher) chmodCooldown() time.Duration {
+	if cooldown := w.options.Chmod.Cooldown; cooldown > 0 {
+		return cooldown
+	}
+	return time.Second
+}
+
+// sweepChmodLocked forgets the paths whose last CHMOD event was delivered before idleSince, and returns how many. The
+// caller holds the lock.
+func (w *FileWatcher) sweepChmodLocked(idleSince time.Time) int {
+	swept := 0
+	for path, entry := range w.chmod.paths {
+		if entry.delivered.Before(idleSince) {
+			delete(w.chmod.paths, path)
+			swept++
+		}
+	}
+	return swept
+}
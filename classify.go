@@ -0,0 +1,135 @@
+package fileWatcher
+
+import (
+	"hash/fnv"
+	"path/filepath"
+	"sync"
+)
+
+const classifyQueueSize = 256
+
+// classifier spreads the classification of the paired raw events over several workers: the stats, the content
+// hashes and the other lookups of emit. Tasks are routed by a hash of their path so the events of a path are always
+// classified by the same worker, in order.
+type classifier struct {
+	queues  []chan func()
+	stop    chan struct{}
+	workers sync.WaitGroup
+}
+
+// startClassifier starts the classification workers when more than one is configured.
+func (w *FileWatcher) startClassifier() {
+	n := w.options.ClassifyWorkers
+	if n <= 1 {
+		return
+	}
+
+	w.classifier.stop = make(chan struct{})
+	w.classifier.queues = make([]chan func(), n)
+	for i := range w.classifier.queues {
+		queue := make(chan func(), classifyQueueSize)
+		w.classifier.queues[i] = queue
+		w.classifier.workers.Add(1)
+		go func() {
+			defer w.classifier.workers.Done()
+			w.labelGoroutine("classify")
+			for {
+				select {
+				case task := <-queue:
+					task()
+				case <-w.classifier.stop:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// stopClassifier stops the classification workers and runs the tasks left in their queues.
+func (w *FileWatcher) stopClassifier() {
+	if w.classifier.stop == nil {
+		return
+	}
+	close(w.classifier.stop)
+	w.classifier.workers.Wait()
+	for _, queue := range w.classifier.queues {
+		for len(queue) > 0 {
+			(<-queue)()
+		}
+	}
+}
+
+// classify runs task, the classification of an event of path, on the worker owning path, or inline without workers.
+// The event of a rename from previous is classified after the events of previous queued before it.
+func (w *FileWatcher) classify(path string, previous string, task func()) {
+	if len(w.classifier.queues) == 0 {
+		task()
+		return
+	}
+
+	queue := w.classifier.queue(w.orderKey(path))
+	if previous != "" {
+		if from := w.classifier.queue(w.orderKey(previous)); from != queue {
+			// wait for the worker of previous to get through its queue
+			drained := make(chan struct{})
+			if !w.classifier.push(from, func() { close(drained) }) {
+				return
+			}
+			select {
+			case <-drained:
+			case <-w.classifier.stop:
+				return
+			}
+		}
+	}
+	w.classifier.push(queue, task)
+}
+
+func (c *classifier) queue(path string) chan func() {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(path))
+	return c.queues[h.Sum32()%uint32(len(c.queues))]
+}
+
+// push queues task and reports whether it did before the workers stopped.
+func (c *classifier) push(queue chan func(), task func()) bool {
+	select {
+	case queue <- task:
+		return true
+	case <-c.stop:
+		return false
+	}
+}
+
+// classifyRemove emits the delete of path reported by an explicit remove, taking its kind from the listings. A path
+// missing from the listing of its directory was reported already, like a watched folder whose remove is reported by
+// its own watch and by the watch of its parent.
+func (w *FileWatcher) classifyRemove(path string) {
+	e := FileWatcherEvent{Event: EventDeleteFile, Path: path}
+	switch _, listed := w.listings.lookup(path); {
+	case listed:
+		e = w.correctDeleteKind(e)
+	case w.listings.has(path):
+		e.Event = EventDeleteFolder
+	case w.listings.has(filepath.Dir(path)):
+		w.trace(path, "remove of an item no longer listed, nothing emitted")
+		return
+	}
+	w.trace(path, "explicit remove")
+	w.listings.remove(path)
+	w.classifyEmit(e)
+}
+
+// classifyWrite emits the edit of path reported by a write, unless path is a folder, whose writes only reflect the
+// changes of its entries, or the create still waiting to be classified, which the write is part of.
+func (w *FileWatcher) classifyWrite(path string, pendingCreate string) {
+	if path == pendingCreate {
+		w.trace(path, "write of the pending create")
+		return
+	}
+	if entry, ok := w.listings.lookup(path); (ok && entry.isDir) || w.listings.has(path) {
+		w.trace(path, "write of a folder, nothing emitted")
+		return
+	}
+	w.classifyEmit(FileWatcherEvent{Event: EventEditFile, Path: path})
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package fileWatcher
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"time"
+)
+
+// platformClassifier is the state of the classification specific to the platform. The generic classification is
+// built around the events of inotify, kqueue and FSEvents, there is none.
+type platformClassifier struct{}
+
+func (w *FileWatcher) newPlatformClassifier() *platformClassifier {
+	return &platformClassifier{}
+}
+
+// expired never fires, nothing is held back.
+func (p *platformClassifier) expired() <-chan time.Time {
+	return nil
+}
+
+func (w *FileWatcher) flushPlatform(*platformClassifier) {}
+
+// classifyPlatform leaves every event to the generic classification.
+func (w *FileWatcher) classifyPlatform(*platformClassifier, fsnotify.Event, string) bool {
+	return false
+}
@@ -0,0 +1,88 @@
+//go:build windows
+
+package fileWatcher
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"path/filepath"
+	"time"
+)
+
+// platformClassifier is the state of the classification specific to the platform. ReadDirectoryChangesW reports a
+// rename within a directory as two records in a row, the old name then the new name, which fsnotify and the Windows
+// notifier turn into a Rename followed by a Create. The old names wait for their new name here.
+type platformClassifier struct {
+	// renames holds the old name of the rename waiting for its new name, by directory.
+	renames map[string]string
+	timer   Timer
+}
+
+func (w *FileWatcher) newPlatformClassifier() *platformClassifier {
+	return &platformClassifier{renames: make(map[string]string), timer: w.clock().NewTimer()}
+}
+
+// expired fires when the renames waited too long for their new name.
+func (p *platformClassifier) expired() <-chan time.Time {
+	return p.timer.C()
+}
+
+// flushPlatform reports the renames whose new name never came. The item was renamed into a directory the watcher
+// doesn't see, it moved out.
+func (w *FileWatcher) flushPlatform(p *platformClassifier) {
+	for dir, old := range p.renames {
+		delete(p.renames, dir)
+		w.classifyMovedOut(old)
+	}
+}
+
+// classifyPlatform classifies the raw events of ReadDirectoryChangesW, and reports whether it consumed event: the two
+// halves of a rename are paired into a single RENAME_FILE or RENAME_FOLDER instead of a delete and a create, a Write
+// is the edit of a file and a Remove the delete of a file or folder. A move to another directory is reported as a
+// remove and a create by Windows.
+func (w *FileWatcher) classifyPlatform(p *platformClassifier, event fsnotify.Event, pendingCreate string) bool {
+	dir := filepath.Dir(event.Name)
+	switch {
+	case event.Has(fsnotify.Rename):
+		if old, ok := p.renames[dir]; ok {
+			w.classifyMovedOut(old)
+		}
+		w.trace(event.Name, "old name of a rename, waiting for the new name")
+		p.renames[dir] = event.Name
+		stopTimer(p.timer)
+		p.timer.Reset(w.createClassifyDelay())
+		return true
+	case event.Has(fsnotify.Create):
+		old, ok := p.renames[dir]
+		if !ok {
+			return false
+		}
+		delete(p.renames, dir)
+		if len(p.renames) == 0 {
+			stopTimer(p.timer)
+		}
+		w.trace(event.Name, "new name of the rename of %s", old)
+		e := FileWatcherEvent{Event: EventRenameFile, Path: event.Name, PreviousPath: old}
+		if entry, listed := w.listings.lookup(old); listed && entry.isDir {
+			e.Event = EventRenameFolder
+		} else if info, err := w.Stat(event.Name); !listed && err == nil && info.IsDir() {
+			e.Event = EventRenameFolder
+		}
+		w.classifyEmit(e)
+		return true
+	case event.Has(fsnotify.Write):
+		w.classifyWrite(event.Name, pendingCreate)
+		return true
+	case event.Has(fsnotify.Remove) && event.Name != pendingCreate:
+		w.classifyRemove(event.Name)
+		return true
+	}
+	return false
+}
+
+// classifyMovedOut emits the rename of old into a directory the watcher doesn't see.
+func (w *FileWatcher) classifyMovedOut(old string) {
+	w.trace(old, "rename without a new name, moved out")
+	e := w.correctDeleteKind(FileWatcherEvent{Event: EventMovedOutFile, Path: old})
+	w.listings.remove(old)
+	w.classifyEmit(e)
+}
@@ -0,0 +1,61 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/afero"
+)
+
+// newSyntheticWatcher returns a watcher of dir on a synthetic filesystem, whose raw notifications are scripted.
+func newSyntheticWatcher(t *testing.T, dir string) (*FileWatcher, *SyntheticFs) {
+	t.Helper()
+	fsys := NewSyntheticFs(afero.NewMemMapFs())
+	if err := fsys.Fs.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	w, err := Init(nil, fsys, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		go func() {
+			for range w.Events {
+			}
+		}()
+		_ = w.Close()
+	})
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+	return w, fsys
+}
+
+func TestWindowsRenamePaired(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "watched")
+	w, fsys := newSyntheticWatcher(t, dir)
+	old, renamed := filepath.Join(dir, "old.txt"), filepath.Join(dir, "new.txt")
+	if err := afero.WriteFile(fsys.Fs, renamed, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys.Notify(fsnotify.Event{Name: old, Op: fsnotify.Rename})
+	fsys.Notify(fsnotify.Event{Name: renamed, Op: fsnotify.Create})
+	e := waitEvent(t, w, func(FileWatcherEvent) bool { return true })
+	if e.Event != EventRenameFile || e.Path != renamed || e.PreviousPath != old {
+		t.Fatalf("got %s %s from %s, want RENAME_FILE %s from %s", e.Event, e.Path, e.PreviousPath, renamed, old)
+	}
+}
+
+func TestWindowsRenameWithoutNewNameMovedOut(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "watched")
+	w, fsys := newSyntheticWatcher(t, dir)
+	old := filepath.Join(dir, "old.txt")
+
+	fsys.Notify(fsnotify.Event{Name: old, Op: fsnotify.Rename})
+	e := waitEvent(t, w, func(FileWatcherEvent) bool { return true })
+	if e.Event != EventMovedOutFile || e.Path != old {
+		t.Fatalf("got %s %s, want MOVED_OUT_FILE %s", e.Event, e.Path, old)
+	}
+}
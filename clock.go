@@ -0,0 +1,67 @@
+package fileWatcher
+
+import (
+	"time"
+)
+
+// Clock is the source of time of a watcher, set with WithClock. It drives the create classification delay, the
+// rename inference and the time of the events, so tests can advance a fake clock instead of sleeping.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a stopped timer.
+	NewTimer() Timer
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer of a Clock, like time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent on when the timer fires. It is nil for the timers of AfterFunc.
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// SystemClock is the clock of the operating system, the default.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) NewTimer() Timer {
+	t := time.NewTimer(time.Hour)
+	t.Stop()
+	return systemTimer{t}
+}
+
+func (SystemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+func (w *FileWatcher) clock() Clock {
+	if w.timers.clock != nil {
+		return w.timers.clock
+	}
+	if w.options.Clock != nil {
+		return w.options.Clock
+	}
+	return SystemClock{}
+}
+
+// stopTimer stops t and drains its channel so it can be reset safely.
+func stopTimer(t Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C():
+		default:
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// Command filewatcher exposes the file watcher to other processes. With -stdio it speaks JSON-RPC 2.0 over its
+// standard input and output, so editor plugins and Electron apps can spawn it as a child process and subscribe to
+// paths. Otherwise it watches the paths given as arguments and prints their classified events, one per line,
+// formatted with the template of -format, see fileWatcher.TemplateFuncs for the helper functions:
+//
+//	filewatcher -format '{{ .Event | lower }} {{ .Path | base }} {{ .Info | humanSize }}' ~/Downloads
+//
+// With -json, the events are printed in the wire schema instead, see fileWatcher.EncodeEvent. With -exec, a command
+// is run by the shell for every event, like entr or watchexec, with the event in its environment: FILEWATCHER_EVENT,
+// FILEWATCHER_PATH, FILEWATCHER_PREVIOUS_PATH, FILEWATCHER_TIME, FILEWATCHER_SEQ, the sequence number of the event,
+// and FILEWATCHER_JSON, the whole event in the wire schema. The commands run one at a time, -debounce bounds how
+// often they run for a path saved over and over:
+//
+//	filewatcher -r -debounce 200ms -exec 'go test ./...' './**/*.go'
+//
+// With -shared, the paths are watched through the watcher shared by the processes of the host on the given Unix
+// socket, see fileWatcher.OpenShared: the first process serves it, the others share its kernel watches.
+//
+// The arguments holding glob characters are patterns: the folder above the first of them is watched recursively and
+// only the events of the paths matching the pattern are printed, see fileWatcher.Glob.
+//
+// The command is built on the public API of the package alone, which makes it an integration test of the
+// classification as much as a tool.
+package main
+
+import "github.com/flightx31/fileWatcher/internal/cli"
+
+func main() {
+	cli.Main("filewatcher")
+}
@@ -0,0 +1,9 @@
+// Command fwatch is the former name of filewatcher, kept for the editor plugins and Electron apps spawning it with
+// -stdio. It takes the same flags and arguments, see cmd/filewatcher.
+package main
+
+import "github.com/flightx31/fileWatcher/internal/cli"
+
+func main() {
+	cli.Main("fwatch")
+}
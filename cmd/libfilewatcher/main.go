@@ -0,0 +1,246 @@
+// Command libfilewatcher is a C API over the file watcher, built as a shared library so Python, Node.js and other
+// languages with a C foreign function interface can use the watcher without reimplementing its heuristics:
+//
+//	go build -buildmode=c-shared -o libfilewatcher.so ./cmd/libfilewatcher
+//
+// The build also writes libfilewatcher.h. Watchers are referred to by the handle returned by FileWatcherNew. The
+// functions returning a string return an error message, or NULL on success, except FileWatcherPoll which returns the
+// events as a JSON array in the wire format of EncodeEvent. Every returned string has to be released with
+// FileWatcherFree.
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/flightx31/fileWatcher"
+	"github.com/spf13/afero"
+	stdlog "log"
+	"os"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// queueSize is the number of events buffered for a watcher between two polls. Events arriving while the queue is full
+// are dropped.
+const queueSize = 4096
+
+var errUnknownHandle = errors.New("unknown watcher handle")
+
+type handle struct {
+	w      *fileWatcher.FileWatcher
+	events chan fileWatcher.FileWatcherEvent
+}
+
+var (
+	handlesMu  sync.Mutex
+	handles          = make(map[int64]*handle)
+	nextHandle int64 = 1
+)
+
+// stderrLogger implements fileWatcher.Logger on the standard error, at the warning level unless FILEWATCHER_DEBUG is
+// set.
+type stderrLogger struct {
+	verbose bool
+}
+
+func (l stderrLogger) Panic(args ...interface{}) {
+	stdlog.Panic(args...)
+}
+
+func (l stderrLogger) Error(args ...interface{}) {
+	stdlog.Print(append([]interface{}{"ERROR "}, args...)...)
+}
+
+func (l stderrLogger) Warn(args ...interface{}) {
+	stdlog.Print(append([]interface{}{"WARN "}, args...)...)
+}
+
+func (l stderrLogger) Info(args ...interface{}) {
+	l.verbosePrint("INFO ", args)
+}
+
+func (l stderrLogger) Debug(args ...interface{}) {
+	l.verbosePrint("DEBUG ", args)
+}
+
+func (l stderrLogger) Trace(args ...interface{}) {
+	l.verbosePrint("TRACE ", args)
+}
+
+func (l stderrLogger) Print(args ...interface{}) {
+	stdlog.Print(args...)
+}
+
+func (l stderrLogger) verbosePrint(level string, args []interface{}) {
+	if l.verbose {
+		stdlog.Print(append([]interface{}{level}, args...)...)
+	}
+}
+
+func lookup(h C.longlong) (*handle, error) {
+	handlesMu.Lock()
+	defer handlesMu.Unlock()
+	res, ok := handles[int64(h)]
+	if !ok {
+		return nil, errUnknownHandle
+	}
+	return res, nil
+}
+
+// result converts err to the string returned to C.
+func result(err error) *C.char {
+	if err == nil {
+		return nil
+	}
+	return C.CString(err.Error())
+}
+
+// FileWatcherNew creates a watcher and returns its handle, or -1 when it can not be created.
+//
+//export FileWatcherNew
+func FileWatcherNew() C.longlong {
+	w, err := fileWatcher.Init(make(chan bool), afero.NewOsFs(), stderrLogger{verbose: os.Getenv("FILEWATCHER_DEBUG") != ""})
+	if err != nil {
+		stdlog.Print("ERROR ", err)
+		return -1
+	}
+
+	h := &handle{w: w, events: make(chan fileWatcher.FileWatcherEvent, queueSize)}
+	go func() {
+		for e := range w.Events {
+			select {
+			case h.events <- e:
+			default:
+				stdlog.Print("WARN event queue full, ", e.Event, " of ", e.Path, " dropped")
+			}
+		}
+		close(h.events)
+	}()
+	go func() {
+		for err := range w.Errors {
+			stdlog.Print("ERROR ", err)
+		}
+	}()
+
+	handlesMu.Lock()
+	defer handlesMu.Unlock()
+	id := nextHandle
+	nextHandle++
+	handles[id] = h
+	return C.longlong(id)
+}
+
+// FileWatcherAdd watches path.
+//
+//export FileWatcherAdd
+func FileWatcherAdd(h C.longlong, path *C.char) *C.char {
+	hd, err := lookup(h)
+	if err != nil {
+		return result(err)
+	}
+	return result(hd.w.Add(C.GoString(path)))
+}
+
+// FileWatcherAddRecursive watches path and every directory below it.
+//
+//export FileWatcherAddRecursive
+func FileWatcherAddRecursive(h C.longlong, path *C.char) *C.char {
+	hd, err := lookup(h)
+	if err != nil {
+		return result(err)
+	}
+	return result(hd.w.AddRecursive(C.GoString(path)))
+}
+
+// FileWatcherRemove stops watching path.
+//
+//export FileWatcherRemove
+func FileWatcherRemove(h C.longlong, path *C.char) *C.char {
+	hd, err := lookup(h)
+	if err != nil {
+		return result(err)
+	}
+	return result(hd.w.Remove(C.GoString(path)))
+}
+
+// FileWatcherPoll waits up to timeoutMillis for events and returns the events buffered by then as a JSON array, empty
+// when the timeout passed without event. A negative timeout waits for the first event. It returns NULL for an unknown
+// or closed watcher.
+//
+//export FileWatcherPoll
+func FileWatcherPoll(h C.longlong, timeoutMillis C.int) *C.char {
+	hd, err := lookup(h)
+	if err != nil {
+		return nil
+	}
+
+	var timeout <-chan time.Time
+	if timeoutMillis >= 0 {
+		timer := time.NewTimer(time.Duration(timeoutMillis) * time.Millisecond)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	events := make([]json.RawMessage, 0)
+	select {
+	case e, ok := <-hd.events:
+		if !ok {
+			return nil
+		}
+		events = appendEvent(events, e)
+	case <-timeout:
+		return C.CString("[]")
+	}
+	for len(hd.events) > 0 {
+		e, ok := <-hd.events
+		if !ok {
+			break
+		}
+		events = appendEvent(events, e)
+	}
+
+	data, err := json.Marshal(events)
+	if err != nil {
+		stdlog.Print("ERROR ", err)
+		return C.CString("[]")
+	}
+	return C.CString(string(data))
+}
+
+func appendEvent(events []json.RawMessage, e fileWatcher.FileWatcherEvent) []json.RawMessage {
+	data, err := fileWatcher.EncodeEvent(e)
+	if err != nil {
+		stdlog.Print("ERROR ", err)
+		return events
+	}
+	return append(events, data)
+}
+
+// FileWatcherClose stops the watcher and releases its handle.
+//
+//export FileWatcherClose
+func FileWatcherClose(h C.longlong) *C.char {
+	handlesMu.Lock()
+	hd, ok := handles[int64(h)]
+	delete(handles, int64(h))
+	handlesMu.Unlock()
+	if !ok {
+		return result(errUnknownHandle)
+	}
+	return result(hd.w.Close())
+}
+
+// FileWatcherFree releases a string returned by the library.
+//
+//export FileWatcherFree
+func FileWatcherFree(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+func main() {}
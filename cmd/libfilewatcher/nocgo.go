@@ -0,0 +1,6 @@
+//go:build !cgo
+
+package main
+
+// The C API needs cgo, without it the command is empty.
+func main() {}
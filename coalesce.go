@@ -0,0 +1,59 @@
+package fileWatcher
+
+import (
+	"sync"
+	"time"
+)
+
+type coalesceState struct {
+	mu      sync.Mutex
+	pending []FileWatcherEvent
+	timer   Timer
+	// deliverMu keeps flushes from interleaving when a consumer is slow.
+	deliverMu sync.Mutex
+}
+
+// coalesce holds e until the current coalescing window closes.
+func (w *FileWatcher) coalesce(e FileWatcherEvent, window time.Duration) {
+	if !w.reserve(e) {
+		if w.overBudget(e) {
+			return
+		}
+		// deliver what is buffered to make room, and this event without waiting
+		w.flushCoalesced()
+		w.deliver(e)
+		return
+	}
+
+	w.coalescer.mu.Lock()
+	defer w.coalescer.mu.Unlock()
+
+	if w.coalescer.pending == nil {
+		w.coalescer.timer = w.afterFunc(window, func() {
+			w.labelGoroutine("coalesce")
+			w.flushCoalesced()
+		})
+	}
+	w.coalescer.pending = append(w.coalescer.pending, e)
+}
+
+func (w *FileWatcher) flushCoalesced() {
+	w.coalescer.deliverMu.Lock()
+	defer w.coalescer.deliverMu.Unlock()
+
+	w.coalescer.mu.Lock()
+	pending := w.coalescer.pending
+	w.coalescer.pending = nil
+	if w.coalescer.timer != nil {
+		w.coalescer.timer.Stop()
+		w.coalescer.timer = nil
+	}
+	w.coalescer.mu.Unlock()
+	w.release(pending)
+
+	folded := netEffect(pending)
+	w.instrumentCoalesced(len(pending), len(folded), "coalescing window closed")
+	for _, e := range folded {
+		w.deliver(e)
+	}
+}
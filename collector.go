@@ -0,0 +1,291 @@
+package fileWatcher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CollectorFrameHeader carries the number of events of a frame posted by a CollectorSink.
+const CollectorFrameHeader = "X-FileWatcher-Events"
+
+// Compression compresses the frames of a CollectorSink.
+type Compression struct {
+	// Encoding is the Content-Encoding of the frames, like "gzip" or "zstd".
+	Encoding string
+	// NewWriter returns a writer compressing to out. The frames are sent uncompressed when it is nil.
+	NewWriter func(out io.Writer) (io.WriteCloser, error)
+}
+
+// ZstdCompression compresses the frames with zstd at level, see github.com/klauspost/compress/zstd. It is the default
+// of a CollectorSink at zstd.SpeedDefault: it compresses the event lines better and faster than gzip.
+func ZstdCompression(level zstd.EncoderLevel) Compression {
+	return Compression{
+		Encoding: "zstd",
+		NewWriter: func(out io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(out, zstd.WithEncoderLevel(level))
+		},
+	}
+}
+
+// GzipCompression compresses the frames with gzip at level, see compress/gzip, for collectors not accepting zstd.
+// Other algorithms are plugged in with a Compression of their encoder.
+func GzipCompression(level int) Compression {
+	return Compression{
+		Encoding: "gzip",
+		NewWriter: func(out io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(out, level)
+		},
+	}
+}
+
+// NoCompression sends the frames as they are.
+var NoCompression = Compression{}
+
+// CollectorOptions configures a CollectorSink.
+type CollectorOptions struct {
+	Headers map[string]string
+	Client  *http.Client
+	// FlushInterval is the longest an event waits in a frame. It defaults to a second.
+	FlushInterval time.Duration
+	// MaxEvents and MaxBytes bound a frame, by its events and by its size before compression. A full frame is sent
+	// right away. They default to 10000 events and 4 MiB.
+	MaxEvents int
+	MaxBytes  int
+	// Compression compresses the frames. It defaults to zstd at its default level, see ZstdCompression.
+	Compression *Compression
+}
+
+// CollectorStats is the accounting of a CollectorSink.
+type CollectorStats struct {
+	Frames uint64
+	Events uint64
+	// Bytes is the size of the frames sent before compression, Compressed after.
+	Bytes      uint64
+	Compressed uint64
+	// Dropped counts the events of the frames that could not be sent.
+	Dropped uint64
+}
+
+// CollectorSink pushes the events to a remote collector in compressed frames, for the watchers of CI and deployment
+// hosts whose bulk changes would cost a request per event with a WebhookSink. The events are collected for
+// FlushInterval, or until the frame is full, then posted in a single request: the wire schema of every event on its
+// own line, see EncodeEvent, compressed with the Content-Encoding of the Compression option. The number of events is
+// sent in the CollectorFrameHeader.
+//
+// A frame that can't be sent is dropped: its error is returned by the Send filling it, or sent to the errors channel
+// for the frames sent by the timer.
+type CollectorSink struct {
+	URL     string
+	options CollectorOptions
+
+	// sending is held while a frame is sent, before mu.
+	sending sync.Mutex
+	mu      sync.Mutex
+	frame   bytes.Buffer
+	count   int
+	timer   *time.Timer
+	closed  bool
+	stats   CollectorStats
+	errs    chan<- error
+}
+
+// NewCollectorSink creates a CollectorSink posting to url. The errors of the frames sent by the timer are sent to
+// errs when it is not nil.
+func NewCollectorSink(url string, options CollectorOptions, errs chan<- error) *CollectorSink {
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = time.Second
+	}
+	if options.MaxEvents <= 0 {
+		options.MaxEvents = 10000
+	}
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = 4 << 20
+	}
+	if options.Compression == nil {
+		zc := ZstdCompression(zstd.SpeedDefault)
+		options.Compression = &zc
+	}
+	if options.Client == nil {
+		options.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &CollectorSink{URL: url, options: options, errs: errs}
+}
+
+// Send adds e to the current frame, and sends the frame when it is full.
+func (s *CollectorSink) Send(e FileWatcherEvent) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := EncodeEvent(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errors.New("the collector sink is closed")
+	}
+	s.frame.Write(line)
+	s.frame.WriteByte('\n')
+	s.count++
+	if s.count < s.options.MaxEvents && s.frame.Len() < s.options.MaxBytes {
+		if s.timer == nil {
+			s.timer = time.AfterFunc(s.options.FlushInterval, s.scheduled)
+		}
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+	return s.Flush()
+}
+
+// scheduled sends the frame once FlushInterval elapsed.
+func (s *CollectorSink) scheduled() {
+	labelGoroutine("collector")
+	if err := s.Flush(); err != nil && s.errs != nil {
+		s.errs <- err
+	}
+}
+
+// takeLocked returns the current frame and starts a new one. The caller holds the lock.
+func (s *CollectorSink) takeLocked() ([]byte, int) {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	body := append([]byte(nil), s.frame.Bytes()...)
+	count := s.count
+	s.frame.Reset()
+	s.count = 0
+	return body, count
+}
+
+// Flush sends the current frame right away.
+func (s *CollectorSink) Flush() error {
+	// the frame is taken once the previous one is sent, so they arrive in order
+	s.sending.Lock()
+	defer s.sending.Unlock()
+	s.mu.Lock()
+	body, count := s.takeLocked()
+	s.mu.Unlock()
+	return s.send(body, count)
+}
+
+// Close sends the current frame and refuses the next events.
+func (s *CollectorSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	return s.Flush()
+}
+
+// Stats returns the accounting of the sink.
+func (s *CollectorSink) Stats() CollectorStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// send compresses and posts a frame of count events. The caller holds the sending lock.
+func (s *CollectorSink) send(body []byte, count int) error {
+	if count == 0 {
+		return nil
+	}
+
+	compressed, err := s.compress(body)
+	if err == nil {
+		err = s.post(compressed, count)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.stats.Dropped += uint64(count)
+		return err
+	}
+	s.stats.Frames++
+	s.stats.Events += uint64(count)
+	s.stats.Bytes += uint64(len(body))
+	s.stats.Compressed += uint64(len(compressed))
+	return nil
+}
+
+func (s *CollectorSink) compress(body []byte) ([]byte, error) {
+	c := s.options.Compression
+	if c.NewWriter == nil {
+		return body, nil
+	}
+	out := bytes.Buffer{}
+	zw, err := c.NewWriter(&out)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(body); err != nil {
+		_ = zw.Close()
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (s *CollectorSink) post(body []byte, count int) error {
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.options.Compression.NewWriter != nil {
+		req.Header.Set("Content-Encoding", s.options.Compression.Encoding)
+	}
+	req.Header.Set(CollectorFrameHeader, strconv.Itoa(count))
+	for k, v := range s.options.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.options.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector %s answered with status %d", s.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// DecodeFrame returns the events of a frame posted by a CollectorSink, once the receiver decompressed it according to
+// its Content-Encoding.
+func DecodeFrame(frame io.Reader) ([]FileWatcherEvent, error) {
+	var res []FileWatcherEvent
+	scanner := bufio.NewScanner(frame)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e, err := DecodeEvent(scanner.Bytes())
+		if err != nil {
+			return res, err
+		}
+		res = append(res, e)
+	}
+	return res, scanner.Err()
+}
@@ -0,0 +1,129 @@
+package fileWatcher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// frameDecoder decodes the body of a frame of the given Content-Encoding.
+type frameDecoder func(body io.Reader) (io.Reader, error)
+
+// collect starts a collector decoding the frames it receives with decode, and returns its URL and the channel of
+// the events of every frame.
+func collect(t *testing.T, encoding string, decode frameDecoder) (string, <-chan []FileWatcherEvent) {
+	frames := make(chan []FileWatcherEvent, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if got := req.Header.Get("Content-Encoding"); got != encoding {
+			t.Errorf("Content-Encoding %q, want %q", got, encoding)
+		}
+		body, err := decode(req.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var events []FileWatcherEvent
+		lines := bufio.NewScanner(body)
+		for lines.Scan() {
+			e, err := DecodeEvent(lines.Bytes())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			events = append(events, e)
+		}
+		if err := lines.Err(); err != nil {
+			t.Error(err)
+		}
+		if got := req.Header.Get(CollectorFrameHeader); got != strconv.Itoa(len(events)) {
+			t.Errorf("%s %s for a frame of %d events", CollectorFrameHeader, got, len(events))
+		}
+		frames <- events
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, frames
+}
+
+func sendFrame(t *testing.T, s *CollectorSink, frames <-chan []FileWatcherEvent, count int) {
+	for i := 0; i < count; i++ {
+		e := FileWatcherEvent{Path: "/data/file" + strconv.Itoa(i), Event: EventEditFile, Time: time.Now()}
+		if err := s.Send(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case events := <-frames:
+		if len(events) != count {
+			t.Fatalf("%d events in the frame, want %d", len(events), count)
+		}
+		for i, e := range events {
+			if want := "/data/file" + strconv.Itoa(i); e.Path != want || e.Event != EventEditFile {
+				t.Fatalf("event %d is %s %s, want EDIT_FILE %s", i, e.Event, e.Path, want)
+			}
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("no frame received")
+	}
+}
+
+func TestCollectorSinkDefaultsToZstd(t *testing.T) {
+	url, frames := collect(t, "zstd", func(body io.Reader) (io.Reader, error) {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		data, err = dec.DecodeAll(data, nil)
+		return bytes.NewReader(data), err
+	})
+	s := NewCollectorSink(url, CollectorOptions{FlushInterval: time.Hour}, nil)
+	defer s.Close()
+
+	sendFrame(t, s, frames, 100)
+	stats := s.Stats()
+	if stats.Frames != 1 || stats.Events != 100 {
+		t.Fatalf("stats %+v, want a frame of 100 events", stats)
+	}
+	if stats.Compressed == 0 || stats.Compressed >= stats.Bytes {
+		t.Fatalf("%d bytes sent for frames of %d bytes", stats.Compressed, stats.Bytes)
+	}
+}
+
+func TestCollectorSinkCompression(t *testing.T) {
+	t.Run("gzip", func(t *testing.T) {
+		url, frames := collect(t, "gzip", func(body io.Reader) (io.Reader, error) {
+			return gzip.NewReader(body)
+		})
+		c := GzipCompression(gzip.BestSpeed)
+		s := NewCollectorSink(url, CollectorOptions{FlushInterval: time.Hour, Compression: &c}, nil)
+		defer s.Close()
+		sendFrame(t, s, frames, 10)
+	})
+	t.Run("none", func(t *testing.T) {
+		url, frames := collect(t, "", func(body io.Reader) (io.Reader, error) {
+			return body, nil
+		})
+		c := NoCompression
+		s := NewCollectorSink(url, CollectorOptions{FlushInterval: time.Hour, Compression: &c}, nil)
+		defer s.Close()
+		sendFrame(t, s, frames, 10)
+		if stats := s.Stats(); stats.Compressed != stats.Bytes {
+			t.Fatalf("%d bytes sent for frames of %d bytes", stats.Compressed, stats.Bytes)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package fileWatcher
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultContentCacheSize = 4096
+
+// contentState remembers the fingerprint of the content of the files seen created, edited or renamed, so edits leaving
+// the content as it was can be dropped.
+type contentState struct {
+	mu     sync.Mutex
+	hashes map[string]contentEntry
+}
+
+type contentEntry struct {
+	hash string
+	// size and modTime are the state of the file when it was hashed, see WarmCacheFile.
+	size    int64
+	modTime time.Time
+	// seen is when the hash was last recorded, see StateGCOptions.
+	seen time.Time
+}
+
+// contentChanged reports whether e has to be emitted when VerifyContentChange is set: every event except the edits of
+// files whose content hash did not change. The first edit of a file whose hash is not known yet, like a file present
+// before it was watched, is always emitted.
+func (w *FileWatcher) contentChanged(e FileWatcherEvent) bool {
+	if !w.options.VerifyContentChange {
+		return true
+	}
+
+	switch e.Event {
+	case EventCreateFile, EventEditFile, EventRenameFile, EventMovedInFile:
+		fingerprint, err := w.fingerprint(e.Path)
+		if err != nil {
+			// gone already or unreadable, let the consumers find out
+			w.forgetContent(e.Path, e.PreviousPath)
+			return true
+		}
+		w.content.mu.Lock()
+		defer w.content.mu.Unlock()
+		if e.PreviousPath != "" {
+			delete(w.content.hashes, e.PreviousPath)
+		}
+		previous, known := w.content.hashes[e.Path]
+		w.rememberContentLocked(Document{Path: e.Path, Hash: fingerprint.sum, ModTime: fingerprint.modTime,
+			Size: fingerprint.size})
+		return e.Event != EventEditFile || !known || previous.hash != fingerprint.sum
+	case EventDeleteFile, EventMovedOutFile:
+		w.forgetContent(e.Path)
+	case EventDeleteFolder, EventRenameFolder, EventMovedOutFolder:
+		w.forgetContentBelow(e.Path, e.PreviousPath)
+	}
+	return true
+}
+
+// rememberContentLocked records the hash of the file of doc, making room by dropping the oldest entries when the cache
+// is full. The caller holds the lock.
+func (w *FileWatcher) rememberContentLocked(doc Document) {
+	if w.content.hashes == nil {
+		w.content.hashes = make(map[string]contentEntry)
+	}
+	size := w.contentEntries()
+	if _, ok := w.content.hashes[doc.Path]; !ok && len(w.content.hashes) >= size {
+		ages := make([]stateAge, 0, len(w.content.hashes))
+		for path, entry := range w.content.hashes {
+			ages = append(ages, stateAge{path: path, seen: entry.seen})
+		}
+		for _, dropped := range oldestPaths(ages, evictionBatch(size)) {
+			delete(w.content.hashes, dropped)
+			atomic.AddUint64(&w.stateGC.contentEvicted, 1)
+		}
+	}
+	w.content.hashes[doc.Path] = contentEntry{hash: doc.Hash, size: doc.Size, modTime: doc.ModTime,
+		seen: w.clock().Now()}
+}
+
+func (w *FileWatcher) forgetContent(paths ...string) {
+	w.content.mu.Lock()
+	defer w.content.mu.Unlock()
+	for _, path := range paths {
+		delete(w.content.hashes, path)
+	}
+}
+
+// forgetContentBelow drops the hashes of the files below the folders dirs.
+func (w *FileWatcher) forgetContentBelow(dirs ...string) {
+	w.content.mu.Lock()
+	defer w.content.mu.Unlock()
+	for path := range w.content.hashes {
+		for _, dir := range dirs {
+			if dir != "" && isBelow(path, dir) {
+				delete(w.content.hashes, path)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package fileWatcher
+
+import (
+	"math/bits"
+	"sort"
+	"sync/atomic"
+)
+
+// EventStats counts the events and errors of a FileWatcher since it was created.
+type EventStats struct {
+	// Emitted counts the classified events, Delivered the ones received from Events and Dropped the ones that never
+	// were: over the memory budget or still pending when the watcher was closed.
+	Emitted   uint64
+	Delivered uint64
+	Dropped   uint64
+	Errors    uint64
+	// ErrorsDropped counts the errors dropped because Errors was full, see the ErrorBuffer option.
+	ErrorsDropped uint64
+	// Expired counts the events a subscriber did not receive within the TTL of its subscription, see
+	// SubscriptionOptions.
+	Expired uint64
+	// RateLimited counts the events dropped by the RateLimit option.
+	RateLimited uint64
+	// ChmodSuppressed counts the CHMOD events dropped by the Chmod option.
+	ChmodSuppressed uint64
+	// ByKind counts the emitted events of every kind.
+	ByKind map[EventKind]uint64
+}
+
+type counterState struct {
+	emitted   uint64
+	delivered uint64
+	dropped   uint64
+	errors    uint64
+	expired   uint64
+	// errorsDropped counts the errors dropped while Errors was full.
+	errorsDropped uint64
+	// rateLimited counts the events dropped by the rate limit.
+	rateLimited uint64
+	// chmodSuppressed counts the CHMOD events dropped by the chmod policy.
+	chmodSuppressed uint64
+	// kinds is indexed by the bit of the kind, the custom kinds included.
+	kinds [64]uint64
+}
+
+func (c *counterState) countEmitted(kind EventKind) {
+	atomic.AddUint64(&c.emitted, 1)
+	if kind != 0 {
+		atomic.AddUint64(&c.kinds[bits.TrailingZeros64(uint64(kind))], 1)
+	}
+}
+
+func (w *FileWatcher) eventStats() EventStats {
+	res := EventStats{
+		Emitted:         atomic.LoadUint64(&w.counters.emitted),
+		Delivered:       atomic.LoadUint64(&w.counters.delivered),
+		Dropped:         atomic.LoadUint64(&w.counters.dropped) + atomic.LoadUint64(&w.memory.dropped),
+		Errors:          atomic.LoadUint64(&w.counters.errors),
+		ErrorsDropped:   atomic.LoadUint64(&w.counters.errorsDropped),
+		Expired:         atomic.LoadUint64(&w.counters.expired),
+		RateLimited:     atomic.LoadUint64(&w.counters.rateLimited),
+		ChmodSuppressed: atomic.LoadUint64(&w.counters.chmodSuppressed),
+		ByKind:          make(map[EventKind]uint64),
+	}
+	for i := range w.counters.kinds {
+		if n := atomic.LoadUint64(&w.counters.kinds[i]); n > 0 {
+			res.ByKind[EventKind(1)<<i] = n
+		}
+	}
+	return res
+}
+
+// WatchedPaths returns the watched paths, sorted.
+func (w *FileWatcher) WatchedPaths() []string {
+	paths := w.WatchedMap.Keys()
+	sort.Strings(paths)
+	return paths
+}
+
+// Len returns the number of watched paths.
+func (w *FileWatcher) Len() int {
+	return w.WatchedMap.Count()
+}
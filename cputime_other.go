@@ -0,0 +1,10 @@
+//go:build plan9 || js || wasip1
+
+package fileWatcher
+
+import "time"
+
+// processCPUTime returns the CPU time consumed by the process. It is not available on this platform.
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
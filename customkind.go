@@ -0,0 +1,116 @@
+package fileWatcher
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+	"sync"
+)
+
+// firstCustomKind is the lowest bit of the custom kinds, the bits below it are kept for the kinds of the package so
+// adding one never takes room from the custom kinds.
+const firstCustomKind EventKind = 1 << 32
+
+// maxCustomKinds is how many custom kinds can be registered.
+const maxCustomKinds = 32
+
+var (
+	// ErrTooManyEventKinds is returned by RegisterEventKind once the 32 custom kinds are registered.
+	ErrTooManyEventKinds = errors.New("no event kind left to register")
+	// ErrNotCustomEventKind is returned by Emit for the events of the kinds of the package, which only the watcher
+	// emits.
+	ErrNotCustomEventKind = errors.New("not a custom event kind")
+)
+
+// customKinds are the kinds registered with RegisterEventKind.
+var customKinds struct {
+	mu    sync.RWMutex
+	names []struct {
+		kind EventKind
+		name string
+	}
+}
+
+// RegisterEventKind registers a custom event kind named name, like "MANIFEST_UPDATED", and returns it. Registering
+// a name again returns the same kind. Custom kinds are emitted with Emit or by ClassificationRule, and go through the
+// same filters, subscriptions, routes and sinks as the kinds of the package. Their name is used by String,
+// ParseEventKind and on the wire, so the processes decoding them have to register them too.
+//
+// There is room for 32 custom kinds, which are given in the order of registration: processes exchanging events have
+// to register them in the same order.
+func RegisterEventKind(name string) (EventKind, error) {
+	if name == "" || strings.Contains(name, "|") {
+		return 0, fmt.Errorf("invalid event kind name %q", name)
+	}
+	for _, n := range eventKindNames {
+		if n.name == name {
+			return 0, fmt.Errorf("event kind %s is a kind of the package", name)
+		}
+	}
+
+	customKinds.mu.Lock()
+	defer customKinds.mu.Unlock()
+	for _, n := range customKinds.names {
+		if n.name == name {
+			return n.kind, nil
+		}
+	}
+	if len(customKinds.names) == maxCustomKinds {
+		return 0, ErrTooManyEventKinds
+	}
+	kind := firstCustomKind << len(customKinds.names)
+	customKinds.names = append(customKinds.names, struct {
+		kind EventKind
+		name string
+	}{kind, name})
+	return kind, nil
+}
+
+// Custom reports whether k is a set of custom kinds, see RegisterEventKind.
+func (k EventKind) Custom() bool {
+	return k >= firstCustomKind && k&(firstCustomKind-1) == 0
+}
+
+// customKindNamed returns the custom kind named name.
+func customKindNamed(name string) (EventKind, bool) {
+	customKinds.mu.RLock()
+	defer customKinds.mu.RUnlock()
+	for _, n := range customKinds.names {
+		if n.name == name {
+			return n.kind, true
+		}
+	}
+	return 0, false
+}
+
+// customKindNames appends the names of the custom kinds of k to names and returns the kinds without a name.
+func customKindNames(k EventKind, names []string) ([]string, EventKind) {
+	customKinds.mu.RLock()
+	defer customKinds.mu.RUnlock()
+	for _, n := range customKinds.names {
+		if k&n.kind != 0 {
+			names = append(names, n.name)
+			k &^= n.kind
+		}
+	}
+	return names, k
+}
+
+// Emit emits e, an event of a custom kind, like an event of the watcher: it is given a Seq and an ID, recorded in the
+// history and delivered to the handlers, the subscriptions and Events. Path is what the event is about, usually a
+// watched path.
+//
+// Emit waits for the consumers when they are behind, a handler emitting events must not wait for them to be
+// delivered.
+func (w *FileWatcher) Emit(e FileWatcherEvent) error {
+	if !e.Event.Custom() || bits.OnesCount64(uint64(e.Event)) != 1 {
+		return fmt.Errorf("%w: %s", ErrNotCustomEventKind, e.Event)
+	}
+	e.Path = w.normalize(e.Path)
+	if e.PreviousPath != "" {
+		e.PreviousPath = w.normalize(e.PreviousPath)
+	}
+	w.emit(e)
+	return nil
+}
@@ -0,0 +1,86 @@
+package fileWatcher
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// withoutCustomKinds runs the test with no custom kind registered, and restores the registered ones after it.
+func withoutCustomKinds(t *testing.T) {
+	customKinds.mu.Lock()
+	saved := customKinds.names
+	customKinds.names = nil
+	customKinds.mu.Unlock()
+	t.Cleanup(func() {
+		customKinds.mu.Lock()
+		customKinds.names = saved
+		customKinds.mu.Unlock()
+	})
+}
+
+func TestBuiltinKindsLeaveTheCustomRange(t *testing.T) {
+	for _, n := range eventKindNames {
+		if n.kind >= firstCustomKind {
+			t.Errorf("%s uses a bit of the custom kinds", n.name)
+		}
+		if n.kind.Custom() {
+			t.Errorf("%s is reported as custom", n.name)
+		}
+	}
+}
+
+func TestRegisterEventKindCapacity(t *testing.T) {
+	withoutCustomKinds(t)
+	seen := EventKind(0)
+	for i := 0; i < maxCustomKinds; i++ {
+		name := fmt.Sprintf("CUSTOM_%d", i)
+		kind, err := RegisterEventKind(name)
+		if err != nil {
+			t.Fatalf("registering kind %d: %v", i+1, err)
+		}
+		if !kind.Custom() || seen&kind != 0 {
+			t.Fatalf("%s got %#x", name, uint64(kind))
+		}
+		seen |= kind
+		if kind.String() != name {
+			t.Fatalf("%#x is named %s, want %s", uint64(kind), kind, name)
+		}
+		if parsed, err := ParseEventKind(name); err != nil || parsed != kind {
+			t.Fatalf("ParseEventKind(%s) = %v, %v", name, parsed, err)
+		}
+	}
+	if again, err := RegisterEventKind("CUSTOM_0"); err != nil || again != firstCustomKind {
+		t.Fatalf("registering a name again returned %v, %v", again, err)
+	}
+	if _, err := RegisterEventKind("ONE_TOO_MANY"); !errors.Is(err, ErrTooManyEventKinds) {
+		t.Fatalf("got %v registering one kind too many", err)
+	}
+	if _, err := RegisterEventKind("CREATE_FILE"); err == nil {
+		t.Fatal("a kind of the package was registered")
+	}
+}
+
+func TestEmitCustomKind(t *testing.T) {
+	withoutCustomKinds(t)
+	kind, err := RegisterEventKind("MANIFEST_UPDATED")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newTestWatcher(t)
+	if err := w.Emit(FileWatcherEvent{Event: EventCreateFile, Path: "/a"}); !errors.Is(err, ErrNotCustomEventKind) {
+		t.Fatalf("emitting a kind of the package returned %v", err)
+	}
+	// Emit waits for the event to be delivered
+	emitted := make(chan error, 1)
+	go func() {
+		emitted <- w.Emit(FileWatcherEvent{Event: kind, Path: "/manifest"})
+	}()
+	e := waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event == kind })
+	if err := <-emitted; err != nil {
+		t.Fatal(err)
+	}
+	if e.Path != "/manifest" || w.Stats().Events.ByKind[kind] != 1 {
+		t.Fatalf("got %+v, counted %d", e, w.Stats().Events.ByKind[kind])
+	}
+}
@@ -0,0 +1,90 @@
+package fileWatcher
+
+import (
+	"sync"
+	"time"
+)
+
+type debouncedEvent struct {
+	e     FileWatcherEvent
+	timer Timer
+}
+
+type debounceState struct {
+	mu      sync.Mutex
+	pending map[string]*debouncedEvent
+}
+
+// debounce holds e until no other event for its path has been seen for the debounce interval, then dispatches the
+// latest event of the path. Editors saving a file produce several writes and renames in a row, they end up as a single
+// event.
+func (w *FileWatcher) debounce(e FileWatcherEvent, interval time.Duration) {
+	w.debouncer.mu.Lock()
+	defer w.debouncer.mu.Unlock()
+
+	if w.debouncer.pending == nil {
+		w.debouncer.pending = make(map[string]*debouncedEvent)
+	}
+	if pending, ok := w.debouncer.pending[e.Path]; ok {
+		w.traceEvent(e, "replaces the debounced %s", pending.e.Event)
+		w.instrument(TraceEntry{
+			Kind:    TraceCoalesced,
+			Level:   TraceLevelInfo,
+			Path:    e.Path,
+			Event:   e.Event,
+			Message: "replaces the debounced " + pending.e.Event.String(),
+			Count:   2,
+		})
+		pending.e = e
+		pending.timer.Reset(interval)
+		return
+	}
+
+	path := e.Path
+	pending := &debouncedEvent{e: e}
+	pending.timer = w.afterFunc(interval, func() {
+		w.labelGoroutine("debounce")
+		w.inOrder(path, func() {
+			w.debouncer.mu.Lock()
+			current, ok := w.debouncer.pending[path]
+			if !ok || current != pending {
+				w.debouncer.mu.Unlock()
+				return
+			}
+			delete(w.debouncer.pending, path)
+			e := current.e
+			w.debouncer.mu.Unlock()
+			w.dispatchSettled(e)
+		})
+	})
+	w.debouncer.pending[path] = pending
+	w.traceEvent(e, "debounced for %s", interval)
+}
+
+// takeDebounced returns the debounced events of the paths held reports true for, which are no longer held back.
+func (w *FileWatcher) takeDebounced(held func(string) bool) []FileWatcherEvent {
+	w.debouncer.mu.Lock()
+	defer w.debouncer.mu.Unlock()
+	var res []FileWatcherEvent
+	for path, p := range w.debouncer.pending {
+		if held(path) && p.timer.Stop() {
+			delete(w.debouncer.pending, path)
+			res = append(res, p.e)
+		}
+	}
+	return sortBySeq(res)
+}
+
+// flushDebounced dispatches the debounced events right away.
+func (w *FileWatcher) flushDebounced() {
+	w.debouncer.mu.Lock()
+	pending := w.debouncer.pending
+	w.debouncer.pending = nil
+	w.debouncer.mu.Unlock()
+
+	for _, p := range pending {
+		if p.timer.Stop() {
+			w.dispatchSettled(p.e)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package fileWatcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupStore records the logical changes already delivered by one of the agents sharing it.
+type DedupStore interface {
+	// Claim records key for ttl and reports whether this call recorded it, atomically across the agents sharing the
+	// store, like SET NX in Redis.
+	Claim(key string, ttl time.Duration) (bool, error)
+	// Release forgets key, so another agent can deliver the change when this one failed to.
+	Release(key string) error
+}
+
+// DedupOptions configures Deduplicated.
+type DedupOptions struct {
+	Store DedupStore
+	// Window is the coarse timestamp of the changes: the observations of a change by several agents are recognized as
+	// the same change when they fall in the same window. It has to be well above the clock skew between the agents and
+	// the delay of their watchers, a change observed on both sides of a window boundary is delivered twice. It
+	// defaults to 10 seconds.
+	Window time.Duration
+	// PathKey maps the path of an event to the path identifying the file across the agents, like the path relative to
+	// the mount point of the share when the agents mount it in different places. The path is used as is when nil.
+	PathKey func(path string) string
+	// FailOpen delivers the events when the store can't be reached, at the cost of duplicates. The error of the store
+	// is returned otherwise.
+	FailOpen bool
+}
+
+// Deduplicated wraps sink so that a logical change observed by several agents watching the same share, each
+// delivering through its own Deduplicated sink sharing the same store, reaches sink once. A change is identified by
+// its type, its path, the content hash of the file and the window of its time.
+func Deduplicated(sink Sink, options DedupOptions) Sink {
+	if options.Window <= 0 {
+		options.Window = 10 * time.Second
+	}
+	if options.PathKey == nil {
+		options.PathKey = func(path string) string { return path }
+	}
+	return SinkFunc(func(e FileWatcherEvent) error {
+		key := dedupKey(e, options)
+		claimed, err := options.Store.Claim(key, 2*options.Window)
+		if err != nil {
+			if !options.FailOpen {
+				return err
+			}
+			log.Warn("Unable to deduplicate ", e.Path, ", delivering it: ", err)
+			return sink.Send(e)
+		}
+		if !claimed {
+			log.Trace("Skipping ", e.Event, " of ", e.Path, ", another agent delivered it")
+			return nil
+		}
+		if err := sink.Send(e); err != nil {
+			if releaseErr := options.Store.Release(key); releaseErr != nil {
+				log.Warn("Unable to release the dedup key of ", e.Path, ": ", releaseErr)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// dedupKey hashes the type, the path, the content hash and the window of e.
+func dedupKey(e FileWatcherEvent, options DedupOptions) string {
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	h := sha256.New()
+	fmt.Fprint(h, e.Event, "\x00", options.PathKey(e.Path), "\x00")
+	if e.PreviousPath != "" {
+		fmt.Fprint(h, options.PathKey(e.PreviousPath))
+	}
+	fmt.Fprint(h, "\x00", dedupContentHash(e), "\x00", t.UnixNano()/int64(options.Window))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// dedupContentHash returns the content hash of the file of e, empty for deletes, folders and unreadable files.
+func dedupContentHash(e FileWatcherEvent) string {
+	switch e.Event {
+	case EventCreateFile, EventEditFile, EventRenameFile, EventMovedInFile, EventDownloadCompleted:
+	default:
+		return ""
+	}
+	doc, err := describeFile(fs, e.Path)
+	if err != nil {
+		return ""
+	}
+	return doc.Hash
+}
+
+// MemoryDedupStore is a DedupStore in memory, for the agents of a single process or behind a DedupHandler.
+type MemoryDedupStore struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+	// sweep is when the expired keys are removed next.
+	sweep time.Time
+}
+
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{expires: make(map[string]time.Time)}
+}
+
+func (s *MemoryDedupStore) Claim(key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.After(s.sweep) {
+		for k, expires := range s.expires {
+			if now.After(expires) {
+				delete(s.expires, k)
+			}
+		}
+		s.sweep = now.Add(ttl)
+	}
+	if expires, ok := s.expires[key]; ok && now.Before(expires) {
+		return false, nil
+	}
+	s.expires[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *MemoryDedupStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.expires, key)
+	return nil
+}
+
+// DedupHandler returns an http.Handler sharing store with the agents using an HTTPDedupStore, for an aggregator
+// deduplicating the changes of a fleet without Redis. A POST with the key and ttl query parameters claims a key and
+// answers {"claimed": true} or {"claimed": false}, a DELETE with the key parameter releases it.
+func DedupHandler(store DedupStore) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			http.Error(rw, "missing key", http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			ttl, err := time.ParseDuration(r.URL.Query().Get("ttl"))
+			if err != nil || ttl <= 0 {
+				http.Error(rw, "invalid ttl", http.StatusBadRequest)
+				return
+			}
+			claimed, err := store.Claim(key, ttl)
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			rw.Header().Set("Content-Type", "application/json")
+			_, _ = rw.Write([]byte(`{"claimed": ` + strconv.FormatBool(claimed) + "}\n"))
+		case http.MethodDelete:
+			if err := store.Release(key); err != nil {
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			rw.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// HTTPDedupStore is a DedupStore claiming the keys from an aggregator serving a DedupHandler.
+type HTTPDedupStore struct {
+	// URL is the address of the DedupHandler, like http://aggregator:8080/dedup.
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPDedupStore(url string) *HTTPDedupStore {
+	return &HTTPDedupStore{URL: url, Client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (s *HTTPDedupStore) Claim(key string, ttl time.Duration) (bool, error) {
+	resp, err := s.do(http.MethodPost, url.Values{"key": {key}, "ttl": {ttl.String()}})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	res := struct {
+		Claimed bool `json:"claimed"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return false, err
+	}
+	return res.Claimed, nil
+}
+
+func (s *HTTPDedupStore) Release(key string) error {
+	resp, err := s.do(http.MethodDelete, url.Values{"key": {key}})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *HTTPDedupStore) do(method string, query url.Values) (*http.Response, error) {
+	sep := "?"
+	if strings.Contains(s.URL, "?") {
+		sep = "&"
+	}
+	req, err := http.NewRequest(method, s.URL+sep+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, errors.New("the dedup aggregator answered with status " + strconv.Itoa(resp.StatusCode))
+	}
+	return resp, nil
+}
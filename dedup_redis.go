@@ -0,0 +1,153 @@
+package fileWatcher
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisDedupStore is a DedupStore keeping the keys in Redis, claimed with SET NX PX so they expire on their own. It
+// speaks the Redis protocol over a single connection, reconnecting after a failure.
+type RedisDedupStore struct {
+	// Addr is the host and port of the server, like "redis:6379".
+	Addr     string
+	Password string
+	DB       int
+	// Prefix is prepended to the keys, it defaults to "fileWatcher:dedup:".
+	Prefix  string
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func NewRedisDedupStore(addr string) *RedisDedupStore {
+	return &RedisDedupStore{Addr: addr, Prefix: "fileWatcher:dedup:", Timeout: 5 * time.Second}
+}
+
+func (s *RedisDedupStore) Claim(key string, ttl time.Duration) (bool, error) {
+	ms := ttl.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	reply, err := s.do("SET", s.Prefix+key, "1", "NX", "PX", strconv.FormatInt(ms, 10))
+	if err != nil {
+		return false, err
+	}
+	// OK when the key was set, a nil reply when it existed
+	return reply != nil, nil
+}
+
+func (s *RedisDedupStore) Release(key string) error {
+	_, err := s.do("DEL", s.Prefix+key)
+	return err
+}
+
+// Close closes the connection to the server.
+func (s *RedisDedupStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// do sends a command and returns its reply, nil for a nil reply.
+func (s *RedisDedupStore) do(args ...string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := s.command(args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// the connection is in an unknown state
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	return reply, err
+}
+
+// connect dials the server, authenticates and selects the database. The caller holds the lock.
+func (s *RedisDedupStore) connect() error {
+	conn, err := net.DialTimeout("tcp", s.Addr, s.Timeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+	if s.Password != "" {
+		_, err = s.command("AUTH", s.Password)
+	}
+	if err == nil && s.DB != 0 {
+		_, err = s.command("SELECT", strconv.Itoa(s.DB))
+	}
+	if err != nil {
+		_ = conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// command writes args as a RESP array and reads the reply. The caller holds the lock.
+func (s *RedisDedupStore) command(args ...string) (interface{}, error) {
+	if s.Timeout > 0 {
+		_ = s.conn.SetDeadline(time.Now().Add(s.Timeout))
+	}
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, "$"+strconv.Itoa(len(arg))+"\r\n"+arg+"\r\n"...)
+	}
+	if _, err := s.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return s.readReply()
+}
+
+// redisError is an error reply of the server.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func (s *RedisDedupStore) readReply() (interface{}, error) {
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, errors.New("redis: malformed reply")
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, redisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(s.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	}
+	return nil, errors.New("redis: unexpected reply " + strconv.Quote(line))
+}
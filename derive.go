@@ -0,0 +1,227 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DeriveFunc produces the derived artifact of source, for example a thumbnail, and writes it to target.
+type DeriveFunc func(source string, target string) error
+
+// DerivationRule maps source files to a derived artifact.
+type DerivationRule struct {
+	// Pattern is matched against the base name of the source with filepath.Match, for example "*.jpg".
+	Pattern string
+	// Target returns the path of the artifact derived from source.
+	Target func(source string) string
+	Derive DeriveFunc
+}
+
+func (r DerivationRule) matches(path string) bool {
+	ok, err := filepath.Match(r.Pattern, filepath.Base(path))
+	return err == nil && ok
+}
+
+// Deriver keeps derived artifacts in sync with their sources. Artifacts are (re)built when a source is created or its
+// content changes, and deleted when the source, or the folder holding it, is removed. Derivations run on a pool of
+// workers, events for the same source are always handled by the same worker so they are applied in order. The events
+// of the artifacts are ignored, so an artifact matching the Pattern of a rule is not derived in turn.
+type Deriver struct {
+	Rules []DerivationRule
+
+	workers int
+	queues  []chan FileWatcherEvent
+	mu      sync.Mutex
+	// hashes holds the hash of each source the last time its artifacts were built.
+	hashes map[string]string
+	// targets holds the artifacts built.
+	targets map[string]bool
+}
+
+// NewDeriver creates a Deriver running derivations on the given number of workers.
+func NewDeriver(workers int, rules ...DerivationRule) *Deriver {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Deriver{
+		Rules:   rules,
+		workers: workers,
+		hashes:  make(map[string]string),
+		targets: make(map[string]bool),
+	}
+}
+
+// Run handles the events received on events until done is signaled or events is closed. Errors returned by the
+// derivations are sent on errs when it is not nil.
+func (d *Deriver) Run(done chan bool, events <-chan FileWatcherEvent, errs chan<- error) {
+	wg := sync.WaitGroup{}
+	d.queues = make([]chan FileWatcherEvent, d.workers)
+	for i := range d.queues {
+		d.queues[i] = make(chan FileWatcherEvent, 64)
+		wg.Add(1)
+		go func(queue chan FileWatcherEvent) {
+			defer wg.Done()
+			labelGoroutine("derive")
+			for e := range queue {
+				if err := d.Handle(e); err != nil && errs != nil {
+					errs <- err
+				}
+			}
+		}(d.queues[i])
+	}
+
+	defer func() {
+		for _, queue := range d.queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			d.queues[d.shard(e)] <- e
+		case <-done:
+			return
+		}
+	}
+}
+
+func (d *Deriver) shard(e FileWatcherEvent) int {
+	// renames go to the worker of the new path, which handles the next events of the source
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(e.Path))
+	return int(h.Sum32() % uint32(d.workers))
+}
+
+// Handle applies a single event synchronously.
+func (d *Deriver) Handle(e FileWatcherEvent) error {
+	if d.derived(e.Path) {
+		return nil
+	}
+	switch e.Event {
+	case EventCreateFile, EventEditFile, EventMovedInFile:
+		return d.derive(e.Path)
+	case EventDeleteFile, EventMovedOutFile:
+		return d.removeDerived(e.Path)
+	case EventRenameFile:
+		if err := d.removeDerived(e.PreviousPath); err != nil {
+			return err
+		}
+		return d.derive(e.Path)
+	case EventDeleteFolder, EventMovedOutFolder:
+		return d.removeDerivedBelow(e.Path)
+	}
+	return nil
+}
+
+// derived reports whether path is an artifact built by d.
+func (d *Deriver) derived(path string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.targets[path]
+}
+
+// Stale reports whether the artifacts of source are out of date.
+func (d *Deriver) Stale(source string) (bool, error) {
+	doc, err := describeFile(fs, source)
+	if err != nil {
+		return false, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.hashes[source] != doc.Hash, nil
+}
+
+func (d *Deriver) derive(source string) error {
+	var rules []DerivationRule
+	for _, rule := range d.Rules {
+		if rule.matches(source) {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	doc, err := describeFile(fs, source)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	upToDate := d.hashes[source] == doc.Hash
+	d.mu.Unlock()
+	if upToDate {
+		log.Trace("Derived artifacts of " + source + " are up to date")
+		return nil
+	}
+
+	for _, rule := range rules {
+		target := rule.Target(source)
+		if target == source {
+			return fmt.Errorf("the derivation rule %s writes its artifact over the source %s", rule.Pattern, source)
+		}
+		// recorded first, the events of the artifact may be handled while it is written
+		d.mu.Lock()
+		d.targets[target] = true
+		d.mu.Unlock()
+		if err := fs.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := rule.Derive(source, target); err != nil {
+			return err
+		}
+	}
+
+	d.mu.Lock()
+	d.hashes[source] = doc.Hash
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *Deriver) removeDerived(source string) error {
+	d.mu.Lock()
+	delete(d.hashes, source)
+	d.mu.Unlock()
+
+	for _, rule := range d.Rules {
+		if !rule.matches(source) {
+			continue
+		}
+		target := rule.Target(source)
+		err := fs.Remove(target)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		d.mu.Lock()
+		delete(d.targets, target)
+		d.mu.Unlock()
+	}
+	return nil
+}
+
+// removeDerivedBelow removes the artifacts of the sources below the folder dir, which was removed along with them.
+func (d *Deriver) removeDerivedBelow(dir string) error {
+	var sources []string
+	d.mu.Lock()
+	for source := range d.hashes {
+		if isBelow(source, dir) {
+			sources = append(sources, source)
+		}
+	}
+	d.mu.Unlock()
+	for _, source := range sources {
+		if err := d.removeDerived(source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package fileWatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newThumbnailDeriver returns a Deriver writing the thumbnail of the JPEG files of dir to thumbnails, whose name
+// matches the pattern of the sources too, and the count of derivations.
+func newThumbnailDeriver(t *testing.T, dir string, thumbnails string) (*Deriver, *int) {
+	t.Helper()
+	// sets the filesystem and the logger of the package
+	newTestWatcher(t)
+	derived := 0
+	d := NewDeriver(4, DerivationRule{
+		Pattern: "*.jpg",
+		Target: func(source string) string {
+			rel, _ := filepath.Rel(dir, source)
+			return filepath.Join(thumbnails, rel+".thumb.jpg")
+		},
+		Derive: func(source string, target string) error {
+			derived++
+			return os.WriteFile(target, []byte("thumbnail"), 0644)
+		},
+	})
+	return d, &derived
+}
+
+func TestDeriverSkipsArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	d, derived := newThumbnailDeriver(t, dir, filepath.Join(dir, "thumbnails"))
+	source := filepath.Join(dir, "a.jpg")
+	writeFile(t, source, "image")
+
+	if err := d.Handle(FileWatcherEvent{Event: EventCreateFile, Path: source}); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "thumbnails", "a.jpg.thumb.jpg")
+	if err := d.Handle(FileWatcherEvent{Event: EventCreateFile, Path: target}); err != nil {
+		t.Fatal(err)
+	}
+	if *derived != 1 {
+		t.Fatalf("%d derivations, the artifact was derived in turn", *derived)
+	}
+	if _, err := os.Stat(target + ".thumb.jpg"); !os.IsNotExist(err) {
+		t.Fatal("the artifact of the artifact was written")
+	}
+}
+
+func TestDeriverRejectsTargetOverSource(t *testing.T) {
+	newTestWatcher(t)
+	source := filepath.Join(t.TempDir(), "a.jpg")
+	writeFile(t, source, "image")
+	d := NewDeriver(1, DerivationRule{
+		Pattern: "*.jpg",
+		Target:  func(source string) string { return source },
+		Derive: func(source string, target string) error {
+			t.Fatal("the source was overwritten")
+			return nil
+		},
+	})
+	if err := d.Handle(FileWatcherEvent{Event: EventCreateFile, Path: source}); err == nil {
+		t.Fatal("no error for a rule writing over its source")
+	}
+}
+
+func TestDeriverDeleteFolder(t *testing.T) {
+	dir := t.TempDir()
+	thumbnails := t.TempDir()
+	d, _ := newThumbnailDeriver(t, dir, thumbnails)
+	album := filepath.Join(dir, "album")
+	if err := os.Mkdir(album, 0755); err != nil {
+		t.Fatal(err)
+	}
+	kept := filepath.Join(dir, "kept.jpg")
+	for _, source := range []string{filepath.Join(album, "a.jpg"), filepath.Join(album, "b.jpg"), kept} {
+		writeFile(t, source, "image")
+		if err := d.Handle(FileWatcherEvent{Event: EventCreateFile, Path: source}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.RemoveAll(album); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Handle(FileWatcherEvent{Event: EventDeleteFolder, Path: album}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		if _, err := os.Stat(filepath.Join(thumbnails, "album", name+".thumb.jpg")); !os.IsNotExist(err) {
+			t.Fatal("the artifact of a source of the deleted folder was kept: ", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(thumbnails, "kept.jpg.thumb.jpg")); err != nil {
+		t.Fatal("the artifact of a source out of the deleted folder was removed: ", err)
+	}
+}
+
+func TestDeriverShardsRenamesByNewPath(t *testing.T) {
+	d := NewDeriver(8)
+	for i := 0; i < 32; i++ {
+		path := filepath.Join("dir", string(rune('a'+i))+".jpg")
+		rename := FileWatcherEvent{Event: EventRenameFile, Path: path, PreviousPath: path + ".tmp"}
+		edit := FileWatcherEvent{Event: EventEditFile, Path: path}
+		if d.shard(rename) != d.shard(edit) {
+			t.Fatalf("the rename to %s and its next edit go to different workers", path)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package fileWatcher
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultDigestSubject is the subject of the digests of EmailDigestSink when no subject template is set.
+const DefaultDigestSubject = `{{ .Count }} file change{{ if ne .Count 1 }}s{{ end }} from ` +
+	`{{ .Started | date "Jan 2 15:04" }} to {{ .Ended | date "Jan 2 15:04 MST" }}`
+
+// DefaultDigestTemplate is the body of the digests of EmailDigestSink when no template is set.
+const DefaultDigestTemplate = `{{ .Count }} change{{ if ne .Count 1 }}s{{ end }} between ` +
+	`{{ .Started | date "Mon Jan 2 15:04 MST" }} and {{ .Ended | date "Mon Jan 2 15:04 MST" }}: {{ .KindList }}.
+{{ range .Events }}
+{{ .Time | date "Jan 2 15:04:05" }}  {{ printf "%-15v" .Event }} {{ .Path }}
+{{- with .PreviousPath }} (from {{ . }}){{ end }}
+{{- end }}
+{{ if .Truncated }}
+... and {{ .Truncated }} more.
+{{ end }}`
+
+// SMTPConfig is the mail server EmailDigestSink sends through.
+type SMTPConfig struct {
+	// Addr is the host and port of the server, like "smtp.example.com:587". The connection is upgraded with STARTTLS
+	// when the server supports it.
+	Addr string
+	// Username and Password authenticate with PLAIN auth when Username is set, which requires TLS unless the server is
+	// on localhost.
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// EmailDigestOptions configures an EmailDigestSink.
+type EmailDigestOptions struct {
+	SMTP SMTPConfig
+	// Subject and Template format the subject and the plain text body of a digest, they are executed on an EmailDigest.
+	// They default to DefaultDigestSubject and DefaultDigestTemplate.
+	Subject  *template.Template
+	Template *template.Template
+	// Interval is the period covered by a digest, like 7 * 24 * time.Hour for weekly digests. It defaults to a day.
+	Interval time.Duration
+	// Align is a time the periods are aligned on, like a Monday at 9:00 for weekly digests sent on Monday mornings.
+	// The periods start when the sink is created when it is zero.
+	Align time.Time
+	// MaxEvents is the largest number of events listed in a digest, the others are only counted. It defaults to 1000.
+	MaxEvents int
+	// SendEmpty sends a digest even when nothing changed during the period.
+	SendEmpty bool
+}
+
+// EmailDigest is the content of a digest.
+type EmailDigest struct {
+	Started time.Time
+	Ended   time.Time
+	// Count is the number of changes, Events lists them as their net effect, see ChangeSet.NetEffect.
+	Count  int
+	Events []FileWatcherEvent
+	// Truncated is the number of changes left out of Events because of MaxEvents.
+	Truncated int
+	// Kinds counts the changes per event type.
+	Kinds map[string]int
+}
+
+// KindList returns the counts of Kinds from the most frequent, like "12 EDIT_FILE, 3 CREATE_FILE".
+func (d EmailDigest) KindList() string {
+	return kindList(d.Kinds)
+}
+
+// EmailDigestSink collects events and mails them in periodic digests instead of a mail per event, for low volume
+// notifications like the weekly changes under /etc.
+type EmailDigestSink struct {
+	options EmailDigestOptions
+	// sendMail is smtp.SendMail, replaced to send somewhere else.
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+	mu      sync.Mutex
+	started time.Time
+	// due is when the current period ends.
+	due    time.Time
+	events []FileWatcherEvent
+	timer  *time.Timer
+	closed bool
+	// errs receives the errors of the scheduled digests.
+	errs chan<- error
+}
+
+// NewEmailDigestSink creates an EmailDigestSink sending a digest at the end of every period. The errors of the
+// scheduled digests are sent to errs when it is not nil.
+func NewEmailDigestSink(options EmailDigestOptions, errs chan<- error) (*EmailDigestSink, error) {
+	if options.SMTP.Addr == "" || options.SMTP.From == "" || len(options.SMTP.To) == 0 {
+		return nil, errors.New("the digests need an SMTP server, a sender and recipients")
+	}
+	if options.Subject == nil {
+		options.Subject = template.Must(ParseEventTemplate(DefaultDigestSubject))
+	}
+	if options.Template == nil {
+		options.Template = template.Must(ParseEventTemplate(DefaultDigestTemplate))
+	}
+	if options.Interval <= 0 {
+		options.Interval = 24 * time.Hour
+	}
+	if options.MaxEvents <= 0 {
+		options.MaxEvents = 1000
+	}
+
+	s := &EmailDigestSink{options: options, sendMail: smtp.SendMail, errs: errs}
+	now := time.Now()
+	s.started = s.periodStart(now)
+	s.due = s.started.Add(options.Interval)
+	s.timer = time.AfterFunc(s.due.Sub(now), s.scheduled)
+	return s, nil
+}
+
+// periodStart returns the start of the period t is in.
+func (s *EmailDigestSink) periodStart(t time.Time) time.Time {
+	if s.options.Align.IsZero() {
+		return t
+	}
+	elapsed := t.Sub(s.options.Align) % s.options.Interval
+	if elapsed < 0 {
+		elapsed += s.options.Interval
+	}
+	return t.Add(-elapsed)
+}
+
+// Send adds e to the next digest.
+func (s *EmailDigestSink) Send(e FileWatcherEvent) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("the digest sink is closed")
+	}
+	s.events = append(s.events, e)
+	return nil
+}
+
+// scheduled sends the digest of the period that just ended and schedules the next one.
+func (s *EmailDigestSink) scheduled() {
+	labelGoroutine("email-digest")
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	end := s.due
+	s.due = end.Add(s.options.Interval)
+	s.timer = time.AfterFunc(time.Until(s.due), s.scheduled)
+	s.mu.Unlock()
+
+	if err := s.send(end); err != nil && s.errs != nil {
+		s.errs <- err
+	}
+}
+
+// Flush sends the digest of the events collected so far right away, the next digest covers the rest of the period.
+func (s *EmailDigestSink) Flush() error {
+	return s.send(time.Now())
+}
+
+// Close sends the digest of the events collected so far and stops the schedule.
+func (s *EmailDigestSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.timer.Stop()
+	s.mu.Unlock()
+	return s.send(time.Now())
+}
+
+// send mails the digest of the events collected until end and starts a period there.
+func (s *EmailDigestSink) send(end time.Time) error {
+	s.mu.Lock()
+	events := s.events
+	started := s.started
+	s.events = nil
+	s.started = end
+	s.mu.Unlock()
+
+	if len(events) == 0 && !s.options.SendEmpty {
+		return nil
+	}
+	msg, err := s.message(s.digest(started, end, events))
+	if err != nil {
+		return err
+	}
+
+	smtpConfig := s.options.SMTP
+	var auth smtp.Auth
+	if smtpConfig.Username != "" {
+		host, _, err := net.SplitHostPort(smtpConfig.Addr)
+		if err != nil {
+			host = smtpConfig.Addr
+		}
+		auth = smtp.PlainAuth("", smtpConfig.Username, smtpConfig.Password, host)
+	}
+	return s.sendMail(smtpConfig.Addr, auth, smtpConfig.From, smtpConfig.To, msg)
+}
+
+// digest folds events into their net effect.
+func (s *EmailDigestSink) digest(started time.Time, ended time.Time, events []FileWatcherEvent) EmailDigest {
+	d := EmailDigest{Started: started, Ended: ended, Events: netEffect(events), Kinds: make(map[string]int)}
+	d.Count = len(d.Events)
+	for _, e := range d.Events {
+		d.Kinds[e.Event.String()]++
+	}
+	if len(d.Events) > s.options.MaxEvents {
+		d.Truncated = len(d.Events) - s.options.MaxEvents
+		d.Events = d.Events[:s.options.MaxEvents]
+	}
+	return d
+}
+
+// message formats the mail of d.
+func (s *EmailDigestSink) message(d EmailDigest) ([]byte, error) {
+	subject := bytes.Buffer{}
+	if err := s.options.Subject.Execute(&subject, d); err != nil {
+		return nil, err
+	}
+	body := bytes.Buffer{}
+	if err := s.options.Template.Execute(&body, d); err != nil {
+		return nil, err
+	}
+
+	smtpConfig := s.options.SMTP
+	msg := bytes.Buffer{}
+	header := func(name string, value string) {
+		// the values come from the configuration, line breaks would inject headers
+		value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+		msg.WriteString(name + ": " + value + "\r\n")
+	}
+	header("From", smtpConfig.From)
+	header("To", strings.Join(smtpConfig.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "8bit")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body.String(), "\r\n", "\n"), "\n", "\r\n"))
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,112 @@
+package fileWatcher
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrDirtyTrackingDisabled is returned by the dirty path APIs when the TrackDirtyPaths option is not set.
+var ErrDirtyTrackingDisabled = errors.New("dirty path tracking is disabled")
+
+// ErrCheckpointExpired is returned by DirtySince for a checkpoint older than the last committed one.
+var ErrCheckpointExpired = errors.New("the checkpoint has been committed and its changes forgotten")
+
+// Checkpoint marks a point in the stream of events, see FileWatcher.Checkpoint.
+type Checkpoint uint64
+
+// DirtyPath is a path that changed since a checkpoint.
+type DirtyPath struct {
+	Path string
+	// Removed is set when the path no longer exists, because it was deleted or renamed away.
+	Removed bool
+	// Folder is set for folders, a backup has to walk a created or renamed folder.
+	Folder bool
+}
+
+type dirtyEntry struct {
+	generation Checkpoint
+	removed    bool
+	folder     bool
+}
+
+type dirtyState struct {
+	mu         sync.Mutex
+	generation Checkpoint
+	committed  Checkpoint
+	paths      map[string]dirtyEntry
+}
+
+// Checkpoint returns a checkpoint for the current state. A backup tool takes a checkpoint when it starts a run and
+// later asks for the paths that changed since then with DirtySince, instead of walking the whole tree.
+func (w *FileWatcher) Checkpoint() (Checkpoint, error) {
+	if !w.options.TrackDirtyPaths {
+		return 0, ErrDirtyTrackingDisabled
+	}
+	w.dirty.mu.Lock()
+	defer w.dirty.mu.Unlock()
+	w.dirty.generation++
+	return w.dirty.generation, nil
+}
+
+// DirtySince returns the paths changed after cp was taken, sorted by path. The zero checkpoint returns every path
+// changed since the watcher was started.
+func (w *FileWatcher) DirtySince(cp Checkpoint) ([]DirtyPath, error) {
+	if !w.options.TrackDirtyPaths {
+		return nil, ErrDirtyTrackingDisabled
+	}
+	w.dirty.mu.Lock()
+	defer w.dirty.mu.Unlock()
+	if w.dirty.committed > 0 && cp < w.dirty.committed {
+		return nil, ErrCheckpointExpired
+	}
+
+	var res []DirtyPath
+	for path, entry := range w.dirty.paths {
+		if entry.generation >= cp {
+			res = append(res, DirtyPath{Path: path, Removed: entry.removed, Folder: entry.folder})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Path < res[j].Path
+	})
+	return res, nil
+}
+
+// CommitCheckpoint forgets the changes made before cp, once a backup based on it has completed. Later calls to
+// DirtySince with an older checkpoint fail with ErrCheckpointExpired.
+func (w *FileWatcher) CommitCheckpoint(cp Checkpoint) error {
+	if !w.options.TrackDirtyPaths {
+		return ErrDirtyTrackingDisabled
+	}
+	w.dirty.mu.Lock()
+	defer w.dirty.mu.Unlock()
+	if cp <= w.dirty.committed {
+		return nil
+	}
+	w.dirty.committed = cp
+	for path, entry := range w.dirty.paths {
+		if entry.generation < cp {
+			delete(w.dirty.paths, path)
+		}
+	}
+	return nil
+}
+
+// markDirty records the paths touched by e in the current generation.
+func (w *FileWatcher) markDirty(e FileWatcherEvent) {
+	if !w.options.TrackDirtyPaths || e.Path == "" || e.Event == EventAccessed {
+		return
+	}
+	w.dirty.mu.Lock()
+	defer w.dirty.mu.Unlock()
+	if w.dirty.paths == nil {
+		w.dirty.paths = make(map[string]dirtyEntry)
+	}
+
+	folder := isFolderEvent(e)
+	if isRename(e) {
+		w.dirty.paths[e.PreviousPath] = dirtyEntry{generation: w.dirty.generation, removed: true, folder: folder}
+	}
+	w.dirty.paths[e.Path] = dirtyEntry{generation: w.dirty.generation, removed: isDelete(e), folder: folder}
+}
@@ -0,0 +1,101 @@
+package fileWatcher
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const dispatchQueueSize = 256
+
+// dispatcher spreads classified events over several workers. Events are routed by a hash of their path so the events
+// of a path are always handled by the same worker, in order.
+type dispatcher struct {
+	queues  []chan dispatchTask
+	stop    chan struct{}
+	workers sync.WaitGroup
+}
+
+// dispatchTask is an event for a dispatch worker, or a barrier closing done once the worker got through the events
+// queued before it. A barrier with a hold keeps the worker waiting until hold is closed, by the event closing released
+// once dispatched.
+type dispatchTask struct {
+	e        FileWatcherEvent
+	done     chan struct{}
+	hold     <-chan struct{}
+	released chan struct{}
+}
+
+// startDispatcher starts the dispatch workers when more than one is configured.
+func (w *FileWatcher) startDispatcher() {
+	n := w.options.DispatchWorkers
+	if n <= 1 {
+		return
+	}
+
+	w.dispatcher.stop = make(chan struct{})
+	w.dispatcher.queues = make([]chan dispatchTask, n)
+	for i := range w.dispatcher.queues {
+		queue := make(chan dispatchTask, dispatchQueueSize)
+		w.dispatcher.queues[i] = queue
+		w.dispatcher.workers.Add(1)
+		go func() {
+			defer w.dispatcher.workers.Done()
+			w.labelGoroutine("dispatch")
+			for {
+				select {
+				case t := <-queue:
+					w.runDispatchTask(t)
+				case <-w.dispatcher.stop:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// stopDispatcher stops the dispatch workers and dispatches the events left in their queues.
+func (w *FileWatcher) stopDispatcher() {
+	if w.dispatcher.stop == nil {
+		return
+	}
+	close(w.dispatcher.stop)
+	w.dispatcher.workers.Wait()
+	for _, queue := range w.dispatcher.queues {
+		for len(queue) > 0 {
+			w.runDispatchTask(<-queue)
+		}
+	}
+}
+
+func (w *FileWatcher) runDispatchTask(t dispatchTask) {
+	if t.done != nil {
+		close(t.done)
+		if t.hold != nil {
+			select {
+			case <-t.hold:
+			case <-w.dispatcher.stop:
+			}
+		}
+		return
+	}
+	w.dispatch(t.e)
+	if t.released != nil {
+		close(t.released)
+	}
+}
+
+func (d *dispatcher) queue(path string) chan dispatchTask {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(path))
+	return d.queues[h.Sum32()%uint32(len(d.queues))]
+}
+
+// push queues t and reports whether it did before the workers stopped.
+func (d *dispatcher) push(queue chan dispatchTask, t dispatchTask) bool {
+	select {
+	case queue <- t:
+		return true
+	case <-d.stop:
+		return false
+	}
+}
@@ -0,0 +1,67 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// downloadSuffixes are the suffixes browsers give to downloads in progress: Chrome, Firefox, the legacy Edge, Safari,
+// which downloads into a .download bundle, and Opera.
+var downloadSuffixes = []string{".crdownload", ".part", ".partial", ".download", ".opdownload"}
+
+// isDownloadName reports whether name is the name of a download in progress.
+func isDownloadName(name string) bool {
+	for _, suffix := range downloadSuffixes {
+		if strings.HasSuffix(strings.ToLower(name), suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// inDownload reports whether path is a download in progress or lies in one.
+func inDownload(path string) bool {
+	for path = filepath.Clean(path); ; path = filepath.Dir(path) {
+		if isDownloadName(filepath.Base(path)) {
+			return true
+		}
+		if filepath.Dir(path) == path {
+			return false
+		}
+	}
+}
+
+// classifyDownload turns the rename of a download in progress to its final name into DOWNLOAD_COMPLETED, and reports
+// whether e has to be emitted at all: the events of the temporary files and the placeholder Firefox creates before
+// the download completes are dropped.
+func (w *FileWatcher) classifyDownload(e FileWatcherEvent) (FileWatcherEvent, bool) {
+	if !w.options.DetectDownloads {
+		return e, true
+	}
+
+	if e.Event == EventRenameFile && inDownload(e.PreviousPath) && !inDownload(e.Path) {
+		e.Event = EventDownloadCompleted
+		return e, true
+	}
+	if inDownload(e.Path) && (e.PreviousPath == "" || inDownload(e.PreviousPath)) {
+		return e, false
+	}
+	if e.Event == EventCreateFile && w.downloadPlaceholder(e.Path) {
+		return e, false
+	}
+	return e, true
+}
+
+// downloadPlaceholder reports whether path is an empty file created next to the download that will replace it.
+func (w *FileWatcher) downloadPlaceholder(path string) bool {
+	info, err := w.Stat(path)
+	if err != nil || info.Size() > 0 {
+		return false
+	}
+	for _, suffix := range downloadSuffixes {
+		if _, err := w.Stat(path + suffix); err == nil {
+			return true
+		}
+	}
+	return false
+}
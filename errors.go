@@ -0,0 +1,67 @@
+package fileWatcher
+
+import "errors"
+
+// The classes of the errors delivered on Errors as a WatchError, to be tested with errors.Is.
+var (
+	// ErrStatFailed reports a path the watcher could not stat to classify its event, for another reason than the path
+	// being gone already.
+	ErrStatFailed = errors.New("stat failed")
+	// ErrWatchLost reports a notifier that stopped delivering events, see Healthy.
+	ErrWatchLost = errors.New("watch lost")
+	// ErrOverflow reports changes lost by the notifier, the affected directory is rescanned and an OVERFLOW event is
+	// emitted. Path is empty when which directories lost changes is unknown.
+	ErrOverflow = errors.New("notification overflow")
+	// ErrClassification reports raw notifications the watcher could not turn into an event.
+	ErrClassification = errors.New("classification failed")
+	// ErrNotifier reports the other errors of the notifier.
+	ErrNotifier = errors.New("notifier error")
+)
+
+// defaultErrorBuffer is the capacity of Errors.
+const defaultErrorBuffer = 64
+
+// WatchError is an error delivered on Errors: its class, the path it is about and its cause.
+type WatchError struct {
+	// Kind is the class of the error, like ErrStatFailed.
+	Kind error
+	// Path is empty when the error is not about a single path.
+	Path string
+	Err  error
+}
+
+func (e *WatchError) Error() string {
+	msg := e.Kind.Error()
+	if e.Path != "" {
+		msg += " for " + e.Path
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the cause, errors.Is matches the class too.
+func (e *WatchError) Unwrap() error {
+	return e.Err
+}
+
+func (e *WatchError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// watchError delivers a WatchError of kind on Errors.
+func (w *FileWatcher) watchError(kind error, path string, err error) {
+	w.sendError(&WatchError{Kind: kind, Path: path, Err: err})
+}
+
+// errorBuffer returns the capacity of Errors, see the ErrorBuffer option.
+func (w *FileWatcher) errorBuffer() int {
+	switch {
+	case w.options.ErrorBuffer < 0:
+		return 0
+	case w.options.ErrorBuffer == 0:
+		return defaultErrorBuffer
+	}
+	return w.options.ErrorBuffer
+}
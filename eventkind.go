@@ -0,0 +1,214 @@
+package fileWatcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EventKind is the kind of a FileWatcherEvent. Every kind is a single bit, so a set of kinds fits in one EventKind,
+// for example EventCreateFile | EventCreateFolder, and can be tested with Is and Has. The kinds of the package use the
+// low 32 bits, the custom kinds registered with RegisterEventKind the high 32 bits.
+type EventKind uint64
+
+const (
+	EventCreateFile EventKind = 1 << iota
+	EventEditFile
+	EventDeleteFile
+	EventRenameFile
+	EventCreateFolder
+	EventDeleteFolder
+	EventRenameFolder
+	EventChMod
+	EventDBChanged
+	EventQuarantined
+	EventReady
+	EventDownloadCompleted
+	EventExtractionCompleted
+	EventOverflow
+	// EventAccessed reports a file opened or read, see AddWithAccessEvents. It is only emitted on Linux.
+	EventAccessed
+	// EventMovedInFile and EventMovedInFolder report an item moved into the watched tree from outside of it, Path is
+	// where it arrived. Its previous path is unknown, PreviousPath is empty. The kernel does not tell a move in from a
+	// create, an item is told moved in when it was created 2 seconds or more before it appeared: an item created and
+	// moved in within 2 seconds, as by the editors and the downloads writing a temporary file outside the watched tree,
+	// is reported as created, and so are the moves across filesystems, which are copies. It needs the creation time of
+	// the file, which FreeBSD, NetBSD, macOS, Windows and Linux on ext4, btrfs or xfs record: elsewhere every item
+	// moved in is reported as created.
+	EventMovedInFile
+	EventMovedInFolder
+	// EventMovedOutFile and EventMovedOutFolder report an item moved out of the watched tree, Path is where it was.
+	// Its new path is unknown.
+	EventMovedOutFile
+	EventMovedOutFolder
+	// EventCatchUp tells a subscriber that events expired before it received them, see SubscriptionOptions.CatchUp.
+	EventCatchUp
+	// EventFileReady reports a new file that stopped changing, see StabilityOptions.Ready.
+	EventFileReady
+	// EventBackfillComplete tells a subscriber that the backfill is over and the live events follow, see
+	// SubscriptionOptions.Backfill.
+	EventBackfillComplete
+	// EventWatchReestablished reports a watched root that was deleted and is watched again since it was recreated, see
+	// the AutoRearmInterval option. What it holds is new, consumers keeping state about it should rescan it.
+	EventWatchReestablished
+	// EventWatchAdded and EventWatchRemoved tell a subscriber that a path at or above its own started or stopped being
+	// watched, see SubscriptionOptions.WatchChanges. They are not emitted on Events.
+	EventWatchAdded
+	EventWatchRemoved
+	// EventResyncRequired reports a watched root whose events may have been lost because the notifier died and was
+	// re-created, see Healthy. Consumers keeping state about the root should rescan it.
+	EventResyncRequired
+	// EventBulkStarted, EventBulkProgress and EventBulkFinished report thousands of items appearing at once below the
+	// folder of Path, with the counts so far in Bulk, see the Bulk option.
+	EventBulkStarted
+	EventBulkProgress
+	EventBulkFinished
+)
+
+// FileEvents and FolderEvents are the sets of the kinds reported for files and for folders.
+const (
+	FileEvents = EventCreateFile | EventEditFile | EventDeleteFile | EventRenameFile | EventMovedInFile |
+		EventMovedOutFile
+	FolderEvents = EventCreateFolder | EventDeleteFolder | EventRenameFolder | EventMovedInFolder | EventMovedOutFolder
+)
+
+// createKinds and deleteKinds are the kinds adding an item to the tree and removing one from it.
+const (
+	createKinds = EventCreateFile | EventCreateFolder | EventMovedInFile | EventMovedInFolder
+	deleteKinds = EventDeleteFile | EventDeleteFolder | EventMovedOutFile | EventMovedOutFolder
+)
+
+// eventKindNames are the names the kinds had when events carried them as strings. They are still used by String and
+// on the wire.
+var eventKindNames = []struct {
+	kind EventKind
+	name string
+}{
+	{EventCreateFile, "CREATE_FILE"},
+	{EventEditFile, "EDIT_FILE"},
+	{EventDeleteFile, "DELETE_FILE"},
+	{EventRenameFile, "RENAME_FILE"},
+	{EventCreateFolder, "CREATE_FOLDER"},
+	{EventDeleteFolder, "DELETE_FOLDER"},
+	{EventRenameFolder, "RENAME_FOLDER"},
+	{EventChMod, "CHMOD"},
+	{EventDBChanged, "DB_CHANGED"},
+	{EventQuarantined, "QUARANTINED"},
+	{EventReady, "READY"},
+	{EventDownloadCompleted, "DOWNLOAD_COMPLETED"},
+	{EventExtractionCompleted, "EXTRACTION_COMPLETED"},
+	{EventOverflow, "OVERFLOW"},
+	{EventAccessed, "ACCESSED"},
+	{EventMovedInFile, "MOVED_IN_FILE"},
+	{EventMovedInFolder, "MOVED_IN_FOLDER"},
+	{EventMovedOutFile, "MOVED_OUT_FILE"},
+	{EventMovedOutFolder, "MOVED_OUT_FOLDER"},
+	{EventCatchUp, "CATCH_UP"},
+	{EventFileReady, "FILE_READY"},
+	{EventBackfillComplete, "BACKFILL_COMPLETE"},
+	{EventWatchReestablished, "WATCH_REESTABLISHED"},
+	{EventWatchAdded, "WATCH_ADDED"},
+	{EventWatchRemoved, "WATCH_REMOVED"},
+	{EventResyncRequired, "RESYNC_REQUIRED"},
+	{EventBulkStarted, "BULK_OPERATION_STARTED"},
+	{EventBulkProgress, "BULK_OPERATION_PROGRESS"},
+	{EventBulkFinished, "BULK_OPERATION_FINISHED"},
+}
+
+// ErrUnknownEventKind is returned by ParseEventKind for a name that is not the name of a kind.
+var ErrUnknownEventKind = errors.New("unknown event kind")
+
+// ParseEventKind returns the kind named name, like "CREATE_FILE" or the name of a custom kind. Sets of kinds are
+// written as names joined by "|".
+func ParseEventKind(name string) (EventKind, error) {
+	var k EventKind
+	for _, part := range strings.Split(name, "|") {
+		found := false
+		for _, n := range eventKindNames {
+			if n.name == part {
+				k |= n.kind
+				found = true
+				break
+			}
+		}
+		if !found {
+			custom, ok := customKindNamed(part)
+			if !ok {
+				return 0, fmt.Errorf("%w: %q", ErrUnknownEventKind, part)
+			}
+			k |= custom
+		}
+	}
+	return k, nil
+}
+
+// String returns the name of the kind, like "CREATE_FILE", the names joined by "|" for a set of kinds.
+func (k EventKind) String() string {
+	if k == 0 {
+		return ""
+	}
+	var names []string
+	rest := k
+	for _, n := range eventKindNames {
+		if k&n.kind != 0 {
+			names = append(names, n.name)
+			rest &^= n.kind
+		}
+	}
+	if rest != 0 {
+		names, rest = customKindNames(rest, names)
+	}
+	if rest != 0 {
+		names = append(names, fmt.Sprintf("EventKind(%#x)", uint64(rest)))
+	}
+	return strings.Join(names, "|")
+}
+
+// Is reports whether k is one of the kinds in set, for example e.Event.Is(EventCreateFile | EventEditFile).
+func (k EventKind) Is(set EventKind) bool {
+	return k&set != 0
+}
+
+// Has reports whether the set k holds every kind of other.
+func (k EventKind) Has(other EventKind) bool {
+	return other != 0 && k&other == other
+}
+
+// MarshalJSON encodes the kind as its name.
+func (k EventKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.String())
+}
+
+// UnmarshalJSON decodes a kind from its name.
+func (k *EventKind) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	if name == "" {
+		*k = 0
+		return nil
+	}
+	parsed, err := ParseEventKind(name)
+	if err != nil {
+		return err
+	}
+	*k = parsed
+	return nil
+}
+
+// MarshalText encodes the kind as its name, so kinds can be keys of JSON objects.
+func (k EventKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes a kind from its name.
+func (k *EventKind) UnmarshalText(text []byte) error {
+	parsed, err := ParseEventKind(string(text))
+	if err != nil {
+		return err
+	}
+	*k = parsed
+	return nil
+}
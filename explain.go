@@ -0,0 +1,168 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"path/filepath"
+)
+
+// Explanation describes how a hypothetical raw event would be handled.
+type Explanation struct {
+	Path string
+	Op   fsnotify.Op
+	// Dropped is set when the event would not produce any event, Steps tells why.
+	Dropped bool
+	// Events are the events that would be emitted when the raw event is not paired with another one.
+	Events []FileWatcherEvent
+	Steps  []string
+}
+
+func (x *Explanation) step(format string, args ...interface{}) {
+	x.Steps = append(x.Steps, fmt.Sprintf(format, args...))
+}
+
+// Explain reports how a raw event with op for path would be filtered and classified with the current configuration,
+// without touching the filesystem. Whether path is a file or a folder is taken from the listing cache. Pass the
+// resulting events to Router.Explain to see where they would be routed.
+func (w *FileWatcher) Explain(path string, op fsnotify.Op) Explanation {
+	x := Explanation{Path: path, Op: op}
+
+	if !w.Contains(path) && !w.Contains(filepath.Dir(path)) && w.rootOf(path) == "" {
+		x.step("%s is not below a watched path, the operating system would not report it", path)
+	}
+
+	if reason, ok := w.ignoreReason(path); ok {
+		x.step("dropped: ignored by %s", reason)
+		x.Dropped = true
+		return x
+	}
+	x.step("not ignored")
+
+	if w.explainSQLite(path) {
+		x.step("consumed by the SQLite watcher, a DB_CHANGED event follows once the database is quiet")
+		e := FileWatcherEvent{}
+		e.Event = EventDBChanged
+		e.Path = w.sqliteDatabase(path)
+		x.Events = append(x.Events, e)
+		return x
+	}
+
+	entry, listed := w.listings.lookup(path)
+	e := FileWatcherEvent{Path: path}
+	rules := w.classificationRules()
+	if !op.Has(fsnotify.Chmod) {
+		for _, rule := range rules {
+			if rule.After == 0 {
+				continue
+			}
+			if op.Has(rule.After) {
+				x.step("followed by a %s, rule %s would pair them into %s", rule.Ops, rule.Name, ruleOutcome(rule))
+			}
+			if op.Has(rule.Ops) && op&rule.Without == 0 {
+				x.step("after a %s, rule %s would pair them into %s", rule.After, rule.Name, ruleOutcome(rule))
+			}
+		}
+	}
+
+	rule, matched := matchRule(rules, fsnotify.Event{Name: path, Op: op}, fsnotify.Event{}, 0)
+	switch {
+	case op.Has(fsnotify.Chmod):
+		x.step("chmod events are passed on right away")
+		e.Event = EventChMod
+	case matched && rule.Event == 0:
+		x.step("dropped: on its own it is dropped by rule %s", rule.Name)
+		x.Dropped = true
+		return x
+	case matched:
+		x.step("on its own, rule %s makes it %s", rule.Name, rule.Event)
+		e.Event = rule.Event
+		if isDelete(e) {
+			e = w.correctDeleteKind(e)
+			if listed {
+				x.step("the listing cache knows %s, it is a %s", path, kindName(entry.isDir))
+			}
+			if w.inferRenames() && listed && entry.inode != 0 {
+				x.step("the delete would be held back for %s waiting for a create of inode %d",
+					2*w.createClassifyDelay(), entry.inode)
+			}
+		}
+	case op.Has(fsnotify.Create):
+		x.step("the create waits %s for a second event", w.createClassifyDelay())
+		switch {
+		case listed && !entry.isDir:
+			x.step("the listing cache already has a file at %s, it would be reported as an edit", path)
+			e.Event = EventEditFile
+		case listed:
+			e.Event = EventCreateFolder
+		default:
+			x.step("whether it is a file or a folder is decided by a stat when the delay expires, assuming a file")
+			x.step("an item created more than %s before it appeared would be reported as moved in", movedInAge)
+			e.Event = EventCreateFile
+		}
+	case op.Has(fsnotify.Remove):
+		x.step("dropped: a remove only matters as part of a pair")
+		x.Dropped = true
+		return x
+	default:
+		x.step("dropped: %s is not a classified operation", op)
+		x.Dropped = true
+		return x
+	}
+	x.Events = append(x.Events, e)
+
+	if window := w.coalesceWindow(); window > 0 {
+		x.step("held for coalescing during %s, only the net effect is delivered", window)
+	}
+	if w.options.ChangeSetQuietPeriod > 0 {
+		x.step("recorded in the current burst change set")
+	}
+	x.step("delivered as %s", e.Event)
+	return x
+}
+
+// ruleOutcome describes the event of rule.
+func ruleOutcome(rule ClassificationRule) string {
+	if rule.Event == 0 {
+		return "nothing"
+	}
+	return rule.Event.String()
+}
+
+func kindName(isDir bool) string {
+	if isDir {
+		return "folder"
+	}
+	return "file"
+}
+
+// Explain reports the routes e would be delivered to, and why.
+func (r *Router) Explain(e FileWatcherEvent) []string {
+	var res []string
+	matched := false
+
+	for _, route := range r.Routes() {
+		if !route.matches(e) {
+			res = append(res, fmt.Sprintf("route %s does not match", route.Name))
+			continue
+		}
+		matched = true
+		if route.Continue {
+			res = append(res, fmt.Sprintf("route %s matches, evaluation continues", route.Name))
+			continue
+		}
+		res = append(res, fmt.Sprintf("route %s matches and stops evaluation", route.Name))
+		break
+	}
+
+	if !matched {
+		r.mu.RLock()
+		fallback := r.Fallback
+		r.mu.RUnlock()
+		if fallback != nil {
+			res = append(res, "no route matches, delivered to the fallback sink")
+		} else {
+			res = append(res, "no route matches, dropped")
+		}
+	}
+	return res
+}
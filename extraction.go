@@ -0,0 +1,96 @@
+package fileWatcher
+
+import (
+	"github.com/spf13/afero"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ExtractionOptions configures the detection of archives extracted into the watched tree: bursts of creates below a
+// newly created folder. The entries are only seen in folders watched recursively, see AddRecursive.
+type ExtractionOptions struct {
+	// Quiet is how long no entry has to be created below the new folder for the extraction to be complete. Zero
+	// disables the detection.
+	Quiet time.Duration
+	// Suppress drops the events of the extracted entries, leaving the CREATE_FOLDER of the top level folder and the
+	// EXTRACTION_COMPLETED. By default both the individual events and the summary are delivered.
+	Suppress bool
+}
+
+type extraction struct {
+	timer   Timer
+	entries int
+}
+
+type extractionState struct {
+	mu   sync.Mutex
+	dirs map[string]*extraction
+}
+
+// trackExtraction follows the bursts of creates below new folders and emits EXTRACTION_COMPLETED for the folder once
+// its burst settled. It reports whether e has to be emitted.
+func (w *FileWatcher) trackExtraction(e FileWatcherEvent) bool {
+	quiet := w.options.Extraction.Quiet
+	if quiet <= 0 || !(isCreate(e) || e.Event == EventEditFile) {
+		return true
+	}
+
+	w.extractions.mu.Lock()
+	defer w.extractions.mu.Unlock()
+
+	for dir := filepath.Dir(e.Path); ; dir = filepath.Dir(dir) {
+		if x, ok := w.extractions.dirs[dir]; ok {
+			x.entries++
+			x.timer.Reset(quiet)
+			return !w.options.Extraction.Suppress
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	if e.Event != EventCreateFolder {
+		return true
+	}
+	if w.extractions.dirs == nil {
+		w.extractions.dirs = make(map[string]*extraction)
+	}
+	path := e.Path
+	x := &extraction{}
+	x.timer = w.afterFunc(quiet, func() {
+		w.extractions.mu.Lock()
+		entries := x.entries
+		delete(w.extractions.dirs, path)
+		w.extractions.mu.Unlock()
+
+		// the entries extracted before the folder was watched produced no event
+		if entries > 0 || !emptyDir(w.fs, path) {
+			w.log.Debug("Extraction into ", path, " completed")
+			w.emit(FileWatcherEvent{Path: path, Event: EventExtractionCompleted})
+		}
+	})
+	w.extractions.dirs[path] = x
+	return true
+}
+
+func emptyDir(fsys afero.Fs, path string) bool {
+	dir, err := fsys.Open(path)
+	if err != nil {
+		return true
+	}
+	defer dir.Close()
+	names, _ := dir.Readdirnames(1)
+	return len(names) == 0
+}
+
+// stopExtractions drops the extractions still in progress, their EXTRACTION_COMPLETED would come after the watcher is
+// closed.
+func (w *FileWatcher) stopExtractions() {
+	w.extractions.mu.Lock()
+	defer w.extractions.mu.Unlock()
+	for _, x := range w.extractions.dirs {
+		x.timer.Stop()
+	}
+	w.extractions.dirs = nil
+}
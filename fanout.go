@@ -0,0 +1,405 @@
+package fileWatcher
+
+import (
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how often delivery to a sink is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of delivery attempts per event, including the first one. Zero means a single attempt.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled for every following retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d > p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return d
+}
+
+// SinkOptions configures a sink attached to a FanOut.
+type SinkOptions struct {
+	// QueueSize is the number of events buffered for the sink. When the queue is full new events are dropped for this
+	// sink only. It defaults to 1024.
+	QueueSize int
+	Retry     RetryPolicy
+	// BatchSize is the largest batch handed to a BatchSink. It defaults to 256.
+	BatchSize int
+	// AdaptiveBatch lets batches grow up to four times BatchSize while the queue of the sink is filling up.
+	AdaptiveBatch bool
+	// Spill is a journal on disk receiving the events once the queue is full instead of dropping them. The following
+	// events are spilled too until the worker of the sink caught up with the queue and drained the journal, so the
+	// events keep their order and the memory stays bounded by QueueSize. The events left in the journal by a previous
+	// run are delivered first.
+	Spill *Journal
+}
+
+// SinkStats is the delivery accounting of a sink attached to a FanOut.
+type SinkStats struct {
+	Delivered uint64
+	Failed    uint64
+	Dropped   uint64
+	Retries   uint64
+	// Spilled counts the events written to the Spill journal.
+	Spilled   uint64
+	Queued    int
+	LastError error
+}
+
+type fanOutSink struct {
+	name    string
+	sink    Sink
+	options SinkOptions
+	queue   chan FileWatcherEvent
+	arena   *batchArena
+	// wake is signaled when an event is spilled.
+	wake chan struct{}
+
+	mu    sync.Mutex
+	stats SinkStats
+	// spilling is set while the events go to the Spill journal, spilledSince counts them since the last drain started.
+	spilling     bool
+	spilledSince int
+}
+
+// FanOut delivers every event to several sinks. Each sink has its own queue, worker and retry policy, so a slow or
+// failing sink never delays or drops the deliveries of another one.
+type FanOut struct {
+	mu     sync.RWMutex
+	sinks  []*fanOutSink
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// NewFanOut creates a FanOut without sinks.
+func NewFanOut() *FanOut {
+	return &FanOut{}
+}
+
+// Attach adds sink under name and starts its worker.
+func (f *FanOut) Attach(name string, sink Sink, options SinkOptions) {
+	if options.QueueSize <= 0 {
+		options.QueueSize = 1024
+	}
+	s := &fanOutSink{
+		name:    name,
+		sink:    sink,
+		options: options,
+		queue:   make(chan FileWatcherEvent, options.QueueSize),
+	}
+	if _, ok := sink.(BatchSink); ok {
+		s.arena = newBatchArena(options.BatchSize)
+	}
+	if options.Spill != nil {
+		s.wake = make(chan struct{}, 1)
+		// deliver what a previous run left in the journal first
+		s.spilling = true
+		s.wake <- struct{}{}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sinks = append(f.sinks, s)
+	f.wg.Add(1)
+	go f.work(s)
+}
+
+// Send queues e for every sink. It never blocks, events are dropped for the sinks whose queue is full.
+func (f *FanOut) Send(e FileWatcherEvent) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.closed {
+		return nil
+	}
+	for _, s := range f.sinks {
+		if s.enqueue(e) {
+			continue
+		}
+		s.mu.Lock()
+		s.stats.Dropped++
+		s.mu.Unlock()
+		log.Warn("Queue of sink ", s.name, " is full, dropping event for ", e.Path)
+	}
+	return nil
+}
+
+// enqueue queues e, or spills it once the queue is full, and reports whether it did.
+func (s *fanOutSink) enqueue(e FileWatcherEvent) bool {
+	if s.options.Spill == nil {
+		select {
+		case s.queue <- e:
+			return true
+		default:
+			return false
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.spilling {
+		select {
+		case s.queue <- e:
+			return true
+		default:
+			s.spilling = true
+		}
+	}
+	if err := s.options.Spill.Append(e); err != nil {
+		log.Error("Sink ", s.name, " failed to spill event for ", e.Path, ": ", err)
+		return false
+	}
+	s.stats.Spilled++
+	s.spilledSince++
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// drainSpill delivers the spilled events with deliver, in order, until the journal is empty and the events go to the
+// queue again.
+func (s *fanOutSink) drainSpill(deliver func(events []FileWatcherEvent)) {
+	if s.options.Spill == nil {
+		return
+	}
+	size := s.options.BatchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	for {
+		s.mu.Lock()
+		if !s.spilling {
+			s.mu.Unlock()
+			return
+		}
+		s.spilledSince = 0
+		s.mu.Unlock()
+
+		var chunk []FileWatcherEvent
+		err := s.options.Spill.Drain(func(e FileWatcherEvent) error {
+			chunk = append(chunk, e)
+			if len(chunk) >= size {
+				deliver(chunk)
+				chunk = nil
+			}
+			return nil
+		})
+		if len(chunk) > 0 {
+			deliver(chunk)
+		}
+		if err != nil {
+			log.Error("Sink ", s.name, " failed to drain its spilled events: ", err)
+			return
+		}
+
+		s.mu.Lock()
+		if s.spilledSince == 0 {
+			s.spilling = false
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Stats returns the delivery accounting of every sink, keyed by name.
+func (f *FanOut) Stats() map[string]SinkStats {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	res := make(map[string]SinkStats, len(f.sinks))
+	for _, s := range f.sinks {
+		s.mu.Lock()
+		stats := s.stats
+		s.mu.Unlock()
+		stats.Queued = len(s.queue)
+		res[s.name] = stats
+	}
+	return res
+}
+
+// Close stops accepting events and waits for the queued ones to be delivered.
+func (f *FanOut) Close() {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.closed = true
+	for _, s := range f.sinks {
+		close(s.queue)
+	}
+	f.mu.Unlock()
+	f.wg.Wait()
+}
+
+// Run sends the events received on events to the sinks until done is signaled or events is closed, then closes the
+// FanOut.
+func (f *FanOut) Run(done chan bool, events <-chan FileWatcherEvent) {
+	defer f.Close()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			_ = f.Send(e)
+		case <-done:
+			return
+		}
+	}
+}
+
+func (f *FanOut) work(s *fanOutSink) {
+	defer f.wg.Done()
+	labelGoroutine("sink:" + s.name)
+
+	if batchSink, ok := s.sink.(BatchSink); ok {
+		f.workBatches(s, batchSink)
+		return
+	}
+
+	send := func(e FileWatcherEvent) {
+		err := s.deliver(func() error {
+			return s.sink.Send(e)
+		})
+		s.account(1, err)
+
+		if err != nil {
+			log.Error("Sink ", s.name, " failed to deliver event for ", e.Path, ": ", err)
+		}
+	}
+	sendAll := func(events []FileWatcherEvent) {
+		for _, e := range events {
+			send(e)
+		}
+	}
+
+	for {
+		select {
+		case e, ok := <-s.queue:
+			if !ok {
+				s.drainSpill(sendAll)
+				return
+			}
+			send(e)
+		case <-s.wake:
+		}
+		if len(s.queue) == 0 {
+			// the spilled events come after the queued ones
+			s.drainSpill(sendAll)
+		}
+	}
+}
+
+// workBatches hands the queued events of s to sink in leased batches.
+func (f *FanOut) workBatches(s *fanOutSink, sink BatchSink) {
+	for {
+		select {
+		case e, ok := <-s.queue:
+			if !ok {
+				s.drainSpill(func(events []FileWatcherEvent) {
+					f.sendBatch(s, sink, events)
+				})
+				return
+			}
+			f.fillBatch(s, sink, e)
+		case <-s.wake:
+		}
+		if len(s.queue) == 0 {
+			// the spilled events come after the queued ones
+			s.drainSpill(func(events []FileWatcherEvent) {
+				f.sendBatch(s, sink, events)
+			})
+		}
+	}
+}
+
+// fillBatch hands e to sink with whatever else is already queued.
+func (f *FanOut) fillBatch(s *fanOutSink, sink BatchSink, e FileWatcherEvent) {
+	size := s.arena.size
+	if s.options.AdaptiveBatch {
+		size = adaptiveBatchSize(size, len(s.queue), cap(s.queue))
+	}
+
+	batch := s.arena.lease()
+	batch.Events = append(batch.Events, e)
+	// take whatever else is already queued, without waiting for more
+fill:
+	for len(batch.Events) < size {
+		select {
+		case next, ok := <-s.queue:
+			if !ok {
+				break fill
+			}
+			batch.Events = append(batch.Events, next)
+		default:
+			break fill
+		}
+	}
+	f.deliverBatch(s, sink, batch)
+}
+
+// sendBatch hands events to sink in a leased batch.
+func (f *FanOut) sendBatch(s *fanOutSink, sink BatchSink, events []FileWatcherEvent) {
+	batch := s.arena.lease()
+	batch.Events = append(batch.Events, events...)
+	f.deliverBatch(s, sink, batch)
+}
+
+func (f *FanOut) deliverBatch(s *fanOutSink, sink BatchSink, batch *EventBatch) {
+	count := len(batch.Events)
+	err := s.deliver(func() error {
+		return sink.SendBatch(batch)
+	})
+	s.account(uint64(count), err)
+
+	if err != nil {
+		// ownership stays with us when the sink failed
+		batch.Release()
+		log.Error("Sink ", s.name, " failed to deliver a batch of ", count, " events: ", err)
+	}
+}
+
+// deliver calls send according to the retry policy of s.
+func (s *fanOutSink) deliver(send func() error) error {
+	attempts := s.options.Retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			s.mu.Lock()
+			s.stats.Retries++
+			s.mu.Unlock()
+			time.Sleep(s.options.Retry.delay(attempt - 1))
+		}
+		if err = send(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (s *fanOutSink) account(events uint64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.stats.Failed += events
+		s.stats.LastError = err
+	} else {
+		s.stats.Delivered += events
+	}
+}
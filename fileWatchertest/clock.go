@@ -0,0 +1,153 @@
+package fileWatchertest
+
+import (
+	"github.com/flightx31/fileWatcher"
+	"sort"
+	"sync"
+	"time"
+)
+
+// timerReceiveTimeout bounds how long Advance waits for a fired timer to be received, for the timers nothing reads
+// anymore.
+const timerReceiveTimeout = time.Second
+
+// Clock is a fileWatcher.Clock whose time only moves with Advance.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+	// settle waits for the watcher to handle a fired timer before the next one fires, for a Harness
+	settle func()
+}
+
+// NewClock returns a clock set to start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) NewTimer() fileWatcher.Timer {
+	t := &timer{c: c, ch: make(chan time.Time, 1)}
+	c.mu.Lock()
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+	return t
+}
+
+func (c *Clock) AfterFunc(d time.Duration, f func()) fileWatcher.Timer {
+	t := &timer{c: c, f: f}
+	c.mu.Lock()
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d and fires the timers due in the order of their deadlines. The functions of
+// AfterFunc are called before Advance returns, and the time sent by the other timers is received by the watcher.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		due := c.due(target)
+		if due == nil {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		c.now = due.when
+		due.active = false
+		now := c.now
+		settle := c.settle
+		c.mu.Unlock()
+		if due.fire(now) && settle != nil {
+			settle()
+		}
+	}
+}
+
+// due returns the first active timer due at target, with the lock held.
+func (c *Clock) due(target time.Time) *timer {
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+	kept := c.timers[:0]
+	var due *timer
+	for _, t := range c.timers {
+		// the stopped timers of AfterFunc are never reset by the watcher
+		if !t.active && t.f != nil {
+			continue
+		}
+		kept = append(kept, t)
+		if due == nil && t.active && !t.when.After(target) {
+			due = t
+		}
+	}
+	c.timers = kept
+	return due
+}
+
+type timer struct {
+	c      *Clock
+	when   time.Time
+	active bool
+	ch     chan time.Time
+	f      func()
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *timer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	was := t.active
+	t.active = false
+	return was
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	was := t.active
+	t.when = t.c.now.Add(d)
+	t.active = true
+	if t.f != nil && !was {
+		// AfterFunc timers are dropped from the clock once stopped
+		found := false
+		for _, other := range t.c.timers {
+			found = found || other == t
+		}
+		if !found {
+			t.c.timers = append(t.c.timers, t)
+		}
+	}
+	return was
+}
+
+// fire calls the function of t, or sends now on its channel and waits for it to be received, and reports whether it
+// was sent.
+func (t *timer) fire(now time.Time) bool {
+	if t.f != nil {
+		t.f()
+		return false
+	}
+	select {
+	case t.ch <- now:
+	default:
+		return false
+	}
+	deadline := time.Now().Add(timerReceiveTimeout)
+	for len(t.ch) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+// Package fileWatchertest provides utilities for testing code built on fileWatcher, and the classification of the
+// watcher itself, without a real filesystem or real time.
+//
+// A Harness runs a watcher on a fileWatcher.SyntheticFs with a Clock only moving when told to. The raw notifications
+// are scripted with EmitRaw, or replayed from a recording made with a Recorder, and every step waits for the event
+// loop to handle them, so the timing-dependent classification of the creates is deterministic:
+//
+//	h, err := fileWatchertest.New(nil)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer h.Close()
+//	_ = h.Fs.MkdirAll("/watched", 0755)
+//	_ = h.Watcher.Add("/watched")
+//	_ = afero.WriteFile(h.Fs.Fs, "/watched/file", nil, 0644)
+//	_ = h.EmitRaw(fsnotify.Event{Name: "/watched/file", Op: fsnotify.Create})
+//	_ = h.Advance(time.Second)
+//	events := h.Events() // a CREATE_FILE of /watched/file
+package fileWatchertest
+
+import (
+	"context"
+	"github.com/flightx31/fileWatcher"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/afero"
+	"sync"
+	"time"
+)
+
+// syncDir is the directory a Harness watches to wait for the event loop, see Harness.Sync.
+const syncDir = "/.fileWatchertest"
+
+// eventBuffer is the capacity of the Events of the watcher of a Harness, the events stay there until Events is called.
+const eventBuffer = 4096
+
+// syncTimeout is how long Sync waits for the event loop.
+const syncTimeout = 5 * time.Second
+
+// Harness is a watcher on a synthetic filesystem and a fake clock, see the package documentation.
+type Harness struct {
+	Watcher *fileWatcher.FileWatcher
+	// Fs reports the changes made through it to the watcher. Its Fs field is the filesystem underneath, to set up
+	// files without any notification.
+	Fs *fileWatcher.SyntheticFs
+	// Clock is the clock of the watcher, set to the start of 2000 UTC.
+	Clock *Clock
+
+	mu   sync.Mutex
+	errs []error
+	// priority are the events delivered on the Priority channel of the watcher, which is not buffered.
+	priority []fileWatcher.FileWatcherEvent
+	closed   chan struct{}
+}
+
+// New returns a harness whose watcher is created with l, a logger dropping everything when nil, and options. The
+// watcher watches a hidden directory of its own, /.fileWatchertest, and buffers its events until Events is called.
+func New(l fileWatcher.Logger, options ...fileWatcher.Option) (*Harness, error) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	h := &Harness{
+		Fs:     fileWatcher.NewSyntheticFs(afero.NewMemMapFs()),
+		Clock:  NewClock(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		closed: make(chan struct{}),
+	}
+	opts := append([]fileWatcher.Option{
+		fileWatcher.WithClock(h.Clock),
+		fileWatcher.WithEventBuffer(eventBuffer, fileWatcher.OverflowBlock),
+	}, options...)
+	w, err := fileWatcher.Init(nil, h.Fs, l, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.Watcher = w
+	h.Clock.settle = func() {
+		_ = h.Sync()
+	}
+	go h.collect()
+
+	if err := h.Fs.Fs.MkdirAll(syncDir, 0755); err != nil {
+		_ = h.Close()
+		return nil, err
+	}
+	if err := w.Add(syncDir); err != nil {
+		_ = h.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// EmitRaw hands events to the watcher as notifications of the operating system and waits for the event loop to
+// handle them. The filesystem is left as it is: the classification stats the paths, so they are usually set up
+// through Fs.Fs first.
+func (h *Harness) EmitRaw(events ...fsnotify.Event) error {
+	for _, e := range events {
+		h.Fs.Notify(e)
+	}
+	return h.Sync()
+}
+
+// Advance waits for the event loop to handle the notifications sent before, moves the clock forward by d, firing the
+// timers of the watcher, like the wait of a create for a second notification, and waits for the event loop to handle
+// them.
+func (h *Harness) Advance(d time.Duration) error {
+	if err := h.Sync(); err != nil {
+		return err
+	}
+	h.Clock.Advance(d)
+	return h.Sync()
+}
+
+// Sync waits until the event loop handled the notifications sent before, by a round trip of a sentinel file through
+// the directory of the harness. The events the notifications were classified into are then in Events, unless
+// options like DispatchWorkers or the debouncing deliver them later.
+func (h *Harness) Sync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+	return h.Watcher.ProbeContext(ctx, syncDir)
+}
+
+// Events returns the events delivered since the previous call, the ones of the paths with PriorityHigh first.
+func (h *Harness) Events() []fileWatcher.FileWatcherEvent {
+	h.mu.Lock()
+	res := h.priority
+	h.priority = nil
+	h.mu.Unlock()
+	for {
+		select {
+		case e, ok := <-h.Watcher.Events:
+			if !ok {
+				return res
+			}
+			res = append(res, e)
+		default:
+			return res
+		}
+	}
+}
+
+// Errors returns the errors of the watcher since the previous call.
+func (h *Harness) Errors() []error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	res := h.errs
+	h.errs = nil
+	return res
+}
+
+// Close closes the watcher. The events not taken with Events are dropped.
+func (h *Harness) Close() error {
+	go func() {
+		// the events left in the buffer don't hold back the close
+		for range h.Watcher.Events {
+		}
+	}()
+	err := h.Watcher.Close()
+	<-h.closed
+	return err
+}
+
+// collect gathers the errors and the events with PriorityHigh until the watcher is closed.
+func (h *Harness) collect() {
+	defer close(h.closed)
+	errs, priority := h.Watcher.Errors, h.Watcher.Priority
+	for errs != nil || priority != nil {
+		select {
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			h.mu.Lock()
+			h.errs = append(h.errs, err)
+			h.mu.Unlock()
+		case e, ok := <-priority:
+			if !ok {
+				priority = nil
+				continue
+			}
+			h.mu.Lock()
+			h.priority = append(h.priority, e)
+			h.mu.Unlock()
+		}
+	}
+}
+
+// nopLogger drops everything.
+type nopLogger struct{}
+
+func (nopLogger) Panic(args ...interface{}) {}
+func (nopLogger) Error(args ...interface{}) {}
+func (nopLogger) Warn(args ...interface{})  {}
+func (nopLogger) Info(args ...interface{})  {}
+func (nopLogger) Debug(args ...interface{}) {}
+func (nopLogger) Trace(args ...interface{}) {}
+func (nopLogger) Print(args ...interface{}) {}
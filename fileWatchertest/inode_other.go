@@ -0,0 +1,10 @@
+//go:build windows || plan9 || js || wasip1
+
+package fileWatchertest
+
+import "os"
+
+// inodeOf returns the inode number of the file described by info, which is not available on this platform.
+func inodeOf(info os.FileInfo) uint64 {
+	return 0
+}
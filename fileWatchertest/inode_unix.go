@@ -0,0 +1,17 @@
+//go:build !windows && !plan9 && !js && !wasip1
+
+package fileWatchertest
+
+import (
+	"os"
+	"syscall"
+)
+
+// inodeOf returns the inode number of the file described by info, zero when it is unknown.
+func inodeOf(info os.FileInfo) uint64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	return uint64(stat.Ino)
+}
@@ -0,0 +1,32 @@
+package fileWatchertest
+
+import (
+	"github.com/flightx31/fileWatcher"
+	"testing"
+	"time"
+)
+
+// leakTimeout is how long VerifyClosed waits for a closed watcher to wind down.
+const leakTimeout = 5 * time.Second
+
+// VerifyClosed fails t unless w, once closed, left nothing behind, see FileWatcher.LeakCheck. The goroutines and the
+// timers of the watcher wind down right after Close returns, they are given a few seconds:
+//
+//	w, _ := fileWatcher.Init(nil, nil, logger)
+//	defer fileWatchertest.VerifyClosed(t, w)
+//	defer w.Close()
+func VerifyClosed(t testing.TB, w *fileWatcher.FileWatcher) {
+	t.Helper()
+	deadline := time.Now().Add(leakTimeout)
+	for {
+		err := w.LeakCheck()
+		if err == nil {
+			return
+		}
+		if err == fileWatcher.ErrNotClosed || time.Now().After(deadline) {
+			t.Errorf("fileWatchertest: %v", err)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
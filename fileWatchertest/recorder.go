@@ -0,0 +1,193 @@
+package fileWatchertest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/flightx31/fileWatcher"
+	"github.com/fsnotify/fsnotify"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RawEvent is a notification of the operating system as recorded by a Recorder, one JSON object per line.
+type RawEvent struct {
+	// Offset is the time of the notification since the first one of the recording.
+	Offset time.Duration `json:"offset"`
+	Path   string        `json:"path"`
+	// Op is the operation, like "CREATE" or "REMOVE|RENAME".
+	Op string `json:"op"`
+	// Exists and IsDir are the state of Path when the notification was handled.
+	Exists bool `json:"exists"`
+	IsDir  bool `json:"isDir,omitempty"`
+	// Inode is the inode number of Path, so the replayed renames are paired like the recorded ones. Zero when it is
+	// unknown.
+	Inode uint64 `json:"inode,omitempty"`
+}
+
+// opNames are the names fsnotify gives the operations.
+var opNames = map[string]fsnotify.Op{
+	"CREATE": fsnotify.Create,
+	"WRITE":  fsnotify.Write,
+	"REMOVE": fsnotify.Remove,
+	"RENAME": fsnotify.Rename,
+	"CHMOD":  fsnotify.Chmod,
+}
+
+// Event returns the notification.
+func (e RawEvent) Event() (fsnotify.Event, error) {
+	var op fsnotify.Op
+	for _, name := range strings.Split(e.Op, "|") {
+		o, ok := opNames[name]
+		if !ok {
+			return fsnotify.Event{}, fmt.Errorf("unknown operation %q of %s", name, e.Path)
+		}
+		op |= o
+	}
+	return fsnotify.Event{Name: e.Path, Op: op}, nil
+}
+
+// Recorder writes the notifications a real watcher receives as RawEvents, for a Harness to replay them later. It
+// is a fileWatcher.Tracer, set with fileWatcher.WithTracer. It stats every notified path in the event loop, so it is
+// meant for recording sessions rather than production.
+type Recorder struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+	err   error
+}
+
+// NewRecorder returns a recorder writing to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+func (r *Recorder) Trace(entry fileWatcher.TraceEntry) {
+	if entry.Kind != fileWatcher.TraceRaw {
+		return
+	}
+	e := RawEvent{Path: entry.Path, Op: entry.Op}
+	if info, err := os.Lstat(entry.Path); err == nil {
+		e.Exists = true
+		e.IsDir = info.IsDir()
+		e.Inode = inodeOf(info)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.start.IsZero() {
+		r.start = entry.Time
+	}
+	e.Offset = entry.Time.Sub(r.start)
+	if err := r.enc.Encode(e); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// Err returns the first error writing the recording.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// ReadRecording reads the RawEvents of a recording.
+func ReadRecording(r io.Reader) ([]RawEvent, error) {
+	var res []RawEvent
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		e := RawEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, scanner.Err()
+}
+
+// Replay feeds events, a recording, to the watcher with its timing: the clock is advanced by the time between the
+// notifications, so the creates are paired or classified on their own as they were. Before each notification its path
+// is created or removed on Fs.Fs to match its recorded state and given its recorded inode, the content of the files
+// is not recorded. The directories holding the recorded paths are created and watched first.
+func (h *Harness) Replay(events []RawEvent) error {
+	dirs := make(map[string]bool)
+	for _, e := range events {
+		dirs[filepath.Dir(e.Path)] = true
+	}
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Strings(sorted)
+	for _, dir := range sorted {
+		if err := h.Fs.Fs.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := h.Watcher.Add(dir); err != nil {
+			return err
+		}
+	}
+
+	var last time.Duration
+	for _, e := range events {
+		if e.Offset > last {
+			if err := h.Advance(e.Offset - last); err != nil {
+				return err
+			}
+			last = e.Offset
+		}
+		if err := h.applyState(e); err != nil {
+			return err
+		}
+		if e.Exists && e.Inode != 0 {
+			h.Fs.SetInode(e.Path, e.Inode)
+		}
+		notification, err := e.Event()
+		if err != nil {
+			return err
+		}
+		if err := h.EmitRaw(notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyState creates or removes the path of e on Fs.Fs to match its recorded state.
+func (h *Harness) applyState(e RawEvent) error {
+	base := h.Fs.Fs
+	info, err := base.Stat(e.Path)
+	exists := err == nil
+	switch {
+	case !e.Exists:
+		if exists {
+			return base.RemoveAll(e.Path)
+		}
+		return nil
+	case exists && info.IsDir() == e.IsDir:
+		return nil
+	case exists:
+		if err := base.RemoveAll(e.Path); err != nil {
+			return err
+		}
+	}
+	if e.IsDir {
+		return base.MkdirAll(e.Path, 0755)
+	}
+	if err := base.MkdirAll(filepath.Dir(e.Path), 0755); err != nil {
+		return err
+	}
+	f, err := base.Create(e.Path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
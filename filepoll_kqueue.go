@@ -0,0 +1,17 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package fileWatcher
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// kqueueFileBudget returns the number of watches kqueue may hold before files added on their own are polled: half of
+// the open file limit, as kqueue holds a file descriptor per watched path.
+func kqueueFileBudget() (int, bool) {
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &limit); err != nil || limit.Cur == unix.RLIM_INFINITY {
+		return defaultKqueueFileBudget, true
+	}
+	return int(limit.Cur / 2), true
+}
@@ -0,0 +1,8 @@
+//go:build !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package fileWatcher
+
+// kqueueFileBudget returns false, only kqueue holds a file descriptor per watched file.
+func kqueueFileBudget() (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,299 @@
+package fileWatcher
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+	"math/bits"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultFingerprintCacheSize = 4096
+
+// Fingerprinter hashes the content of files for the features comparing them, like VerifyContentChange, and for
+// Fingerprint. The fingerprints of different algorithms don't compare.
+type Fingerprinter interface {
+	// Name identifies the algorithm, like "xxh64" or "sha256".
+	Name() string
+	// New returns a hash to write the content of a file to.
+	New() hash.Hash
+}
+
+// XXHashFingerprinter fingerprints the files with the 64 bits xxHash, XXH64, much faster than a cryptographic hash
+// and enough to tell the versions of a file apart. It is the default.
+type XXHashFingerprinter struct{}
+
+func (XXHashFingerprinter) Name() string {
+	return "xxh64"
+}
+
+func (XXHashFingerprinter) New() hash.Hash {
+	return newXXH64()
+}
+
+// SHA256Fingerprinter fingerprints the files with SHA-256, for the consumers matching the fingerprints against the
+// checksums of sha256sum or of a Manifest.
+type SHA256Fingerprinter struct{}
+
+func (SHA256Fingerprinter) Name() string {
+	return "sha256"
+}
+
+func (SHA256Fingerprinter) New() hash.Hash {
+	return sha256.New()
+}
+
+// FingerprintStats counts the lookups of the fingerprint cache, see Fingerprint and Stats.
+type FingerprintStats struct {
+	// Hits counts the fingerprints served from the cache, Misses the files hashed.
+	Hits   uint64
+	Misses uint64
+	// Entries is the number of fingerprints cached.
+	Entries int
+}
+
+// fingerprintEntry is the fingerprint of a file and the state of the file when it was hashed.
+type fingerprintEntry struct {
+	path    string
+	sum     string
+	size    int64
+	modTime time.Time
+}
+
+// fingerprintCache is the least recently used fingerprints. An entry is only used while the size and the modification
+// time of its file are unchanged, and dropped by every event of its path.
+type fingerprintCache struct {
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	hits    uint64
+	misses  uint64
+}
+
+// Fingerprint returns the fingerprint of the content of the file at path, in hexadecimal, hashed with the
+// Fingerprinter option. The fingerprints of the files the watcher already hashed, for VerifyContentChange or an
+// earlier call, are reused while the files are unchanged, so consumers needing them for checksums, snapshots or
+// mirroring don't hash the files again.
+func (w *FileWatcher) Fingerprint(path string) (string, error) {
+	entry, err := w.fingerprint(w.normalize(path))
+	return entry.sum, err
+}
+
+func (w *FileWatcher) fingerprintStats() FingerprintStats {
+	w.fingerprints.mu.Lock()
+	defer w.fingerprints.mu.Unlock()
+	return FingerprintStats{
+		Hits:    atomic.LoadUint64(&w.fingerprints.hits),
+		Misses:  atomic.LoadUint64(&w.fingerprints.misses),
+		Entries: len(w.fingerprints.entries),
+	}
+}
+
+// fingerprinter returns the Fingerprinter option, XXHashFingerprinter by default.
+func (w *FileWatcher) fingerprinter() Fingerprinter {
+	if w.options.Fingerprinter != nil {
+		return w.options.Fingerprinter
+	}
+	return XXHashFingerprinter{}
+}
+
+// fingerprint returns the fingerprint of path from the cache when its file is unchanged, and hashes it otherwise. The
+// stat checking the file refreshes the stat cache.
+func (w *FileWatcher) fingerprint(path string) (fingerprintEntry, error) {
+	now := time.Now()
+	info, err := w.fs.Stat(path)
+	w.cacheStat(path, info, err, now)
+	if err != nil {
+		w.fingerprints.invalidate(path)
+		return fingerprintEntry{}, err
+	}
+
+	c := &w.fingerprints
+	c.mu.Lock()
+	if element, ok := c.entries[path]; ok {
+		entry := element.Value.(fingerprintEntry)
+		if entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+			c.order.MoveToFront(element)
+			c.mu.Unlock()
+			atomic.AddUint64(&c.hits, 1)
+			return entry, nil
+		}
+	}
+	c.mu.Unlock()
+
+	atomic.AddUint64(&c.misses, 1)
+	file, err := w.fs.Open(path)
+	if err != nil {
+		return fingerprintEntry{}, err
+	}
+	defer file.Close()
+	h := w.fingerprinter().New()
+	if _, err := io.Copy(h, throttled(file)); err != nil {
+		return fingerprintEntry{}, err
+	}
+	entry := fingerprintEntry{path: path, sum: hex.EncodeToString(h.Sum(nil)), size: info.Size(),
+		modTime: info.ModTime()}
+	w.rememberFingerprint(entry)
+	return entry, nil
+}
+
+// rememberFingerprint caches entry, dropping the least recently used fingerprints when the cache is full.
+func (w *FileWatcher) rememberFingerprint(entry fingerprintEntry) {
+	size := w.options.FingerprintCacheSize
+	if size == 0 {
+		size = defaultFingerprintCacheSize
+	}
+	if size < 0 {
+		return
+	}
+
+	c := &w.fingerprints
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+		c.order = list.New()
+	}
+	if element, ok := c.entries[entry.path]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	for c.order.Len() >= size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(fingerprintEntry).path)
+	}
+	c.entries[entry.path] = c.order.PushFront(entry)
+}
+
+// invalidate drops the fingerprints of paths.
+func (c *fingerprintCache) invalidate(paths ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		path = filepath.Clean(path)
+		if element, ok := c.entries[path]; ok {
+			c.order.Remove(element)
+			delete(c.entries, path)
+		}
+	}
+}
+
+// XXH64, see https://github.com/Cyan4973/xxHash/blob/dev/doc/xxhash_spec.md.
+const (
+	xxhPrime1 uint64 = 11400714785074694791
+	xxhPrime2 uint64 = 14029467366897019727
+	xxhPrime3 uint64 = 1609587929392839161
+	xxhPrime4 uint64 = 9650029242287828579
+	xxhPrime5 uint64 = 2870177450012600261
+)
+
+// xxh64 is a streaming XXH64 with a zero seed.
+type xxh64 struct {
+	v     [4]uint64
+	total uint64
+	mem   [32]byte
+	n     int
+}
+
+func newXXH64() *xxh64 {
+	h := &xxh64{}
+	h.Reset()
+	return h
+}
+
+func (h *xxh64) Reset() {
+	// the accumulators wrap around, which the constants can't
+	p1, p2 := xxhPrime1, xxhPrime2
+	h.v = [4]uint64{p1 + p2, p2, 0, -p1}
+	h.total = 0
+	h.n = 0
+}
+
+func (h *xxh64) Size() int {
+	return 8
+}
+
+func (h *xxh64) BlockSize() int {
+	return 32
+}
+
+func (h *xxh64) Write(b []byte) (int, error) {
+	written := len(b)
+	h.total += uint64(written)
+	if h.n+len(b) < 32 {
+		h.n += copy(h.mem[h.n:], b)
+		return written, nil
+	}
+	if h.n > 0 {
+		b = b[copy(h.mem[h.n:], b):]
+		h.stripe(h.mem[:])
+		h.n = 0
+	}
+	for ; len(b) >= 32; b = b[32:] {
+		h.stripe(b)
+	}
+	h.n = copy(h.mem[:], b)
+	return written, nil
+}
+
+func (h *xxh64) stripe(b []byte) {
+	for i := range h.v {
+		h.v[i] = xxhRound(h.v[i], binary.LittleEndian.Uint64(b[8*i:]))
+	}
+}
+
+func (h *xxh64) Sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v[0], 1) + bits.RotateLeft64(h.v[1], 7) + bits.RotateLeft64(h.v[2], 12) +
+			bits.RotateLeft64(h.v[3], 18)
+		for _, v := range h.v {
+			acc = (acc^xxhRound(0, v))*xxhPrime1 + xxhPrime4
+		}
+	} else {
+		acc = xxhPrime5
+	}
+	acc += h.total
+
+	b := h.mem[:h.n]
+	for ; len(b) >= 8; b = b[8:] {
+		acc ^= xxhRound(0, binary.LittleEndian.Uint64(b))
+		acc = bits.RotateLeft64(acc, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(b) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(b)) * xxhPrime1
+		acc = bits.RotateLeft64(acc, 23)*xxhPrime2 + xxhPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		acc ^= uint64(c) * xxhPrime5
+		acc = bits.RotateLeft64(acc, 11) * xxhPrime1
+	}
+
+	acc ^= acc >> 33
+	acc *= xxhPrime2
+	acc ^= acc >> 29
+	acc *= xxhPrime3
+	acc ^= acc >> 32
+	return acc
+}
+
+func (h *xxh64) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, h.Sum64())
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * xxhPrime2
+	return bits.RotateLeft64(acc, 31) * xxhPrime1
+}
@@ -0,0 +1,218 @@
+package fileWatcher
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultStateGCInterval = time.Minute
+	defaultStateMaxIdle    = 10 * time.Minute
+	defaultOrderingEntries = 1 << 16
+	defaultRateEntries     = 1 << 16
+)
+
+// StateGCOptions bounds the state the watcher keeps about the paths it saw events for to correlate their later
+// events: the content hashes of VerifyContentChange, the windows of RateLimit, the last events of CheckOrdering, the
+// attributes followed by the Chmod option and the stat cache. The state of a path untouched for MaxIdle is collected
+// every Interval, and a full cache makes room by dropping its oldest entries. High-churn environments, like build
+// trees, lower MaxIdle and the sizes to trade the accuracy of the correlation for memory.
+type StateGCOptions struct {
+	// Interval is how often the idle state is collected. It defaults to a minute, a negative value only bounds the
+	// sizes.
+	Interval time.Duration
+	// MaxIdle is how long the state of a path is kept once no event touched it. It defaults to ten minutes.
+	MaxIdle time.Duration
+	// ContentEntries bounds the content hashes of VerifyContentChange. It defaults to 4096.
+	ContentEntries int
+	// OrderingEntries bounds the paths followed by CheckOrdering. It defaults to 65536.
+	OrderingEntries int
+	// RateEntries bounds the paths followed by RateLimit. Past it, the events of the other paths are not limited. It
+	// defaults to 65536.
+	RateEntries int
+}
+
+// StateGCStats is the size of the correlation state of a FileWatcher and what its collection dropped, see
+// StateGCOptions.
+type StateGCStats struct {
+	// Collections counts the periodic collections.
+	Collections uint64
+	Content     int
+	Ordering    int
+	Rates       int
+	StatCache   int
+	// The evictions count the entries dropped because they were idle or their cache was full.
+	ContentEvicted   uint64
+	OrderingEvicted  uint64
+	RatesEvicted     uint64
+	StatCacheEvicted uint64
+}
+
+type stateGCState struct {
+	mu      sync.Mutex
+	timer   Timer
+	stopped bool
+
+	collections      uint64
+	contentEvicted   uint64
+	orderingEvicted  uint64
+	ratesEvicted     uint64
+	statCacheEvicted uint64
+}
+
+// stateAge is when the state of a path was last touched.
+type stateAge struct {
+	path string
+	seen time.Time
+}
+
+// oldestPaths returns the paths of the n entries of ages touched the longest ago.
+func oldestPaths(ages []stateAge, n int) []string {
+	sort.Slice(ages, func(i, j int) bool {
+		return ages[i].seen.Before(ages[j].seen)
+	})
+	if n > len(ages) {
+		n = len(ages)
+	}
+	res := make([]string, n)
+	for i := range res {
+		res[i] = ages[i].path
+	}
+	return res
+}
+
+// evictionBatch is how many entries a full cache of size drops at once, so it doesn't sort its entries on every
+// insertion.
+func evictionBatch(size int) int {
+	return size/8 + 1
+}
+
+func (w *FileWatcher) stateMaxIdle() time.Duration {
+	if idle := w.options.StateGC.MaxIdle; idle > 0 {
+		return idle
+	}
+	return defaultStateMaxIdle
+}
+
+func (w *FileWatcher) contentEntries() int {
+	if size := w.options.StateGC.ContentEntries; size > 0 {
+		return size
+	}
+	return defaultContentCacheSize
+}
+
+func (w *FileWatcher) orderingEntries() int {
+	if size := w.options.StateGC.OrderingEntries; size > 0 {
+		return size
+	}
+	return defaultOrderingEntries
+}
+
+func (w *FileWatcher) rateEntries() int {
+	if size := w.options.StateGC.RateEntries; size > 0 {
+		return size
+	}
+	return defaultRateEntries
+}
+
+// startStateGC collects the idle correlation state every StateGC.Interval until the watcher is closed.
+func (w *FileWatcher) startStateGC() {
+	interval := w.options.StateGC.Interval
+	if interval < 0 {
+		return
+	}
+	if interval == 0 {
+		interval = defaultStateGCInterval
+	}
+	var collect func()
+	collect = func() {
+		w.collectState()
+		w.stateGC.mu.Lock()
+		defer w.stateGC.mu.Unlock()
+		if !w.stateGC.stopped {
+			w.stateGC.timer = w.clock().AfterFunc(interval, collect)
+		}
+	}
+	w.stateGC.mu.Lock()
+	w.stateGC.timer = w.clock().AfterFunc(interval, collect)
+	w.stateGC.mu.Unlock()
+}
+
+func (w *FileWatcher) stopStateGC() {
+	w.stateGC.mu.Lock()
+	defer w.stateGC.mu.Unlock()
+	w.stateGC.stopped = true
+	if w.stateGC.timer != nil {
+		w.stateGC.timer.Stop()
+	}
+}
+
+// collectState drops the correlation state of the paths untouched for MaxIdle, and the expired stat cache entries.
+func (w *FileWatcher) collectState() {
+	idleSince := w.clock().Now().Add(-w.stateMaxIdle())
+	evicted := 0
+
+	w.content.mu.Lock()
+	for path, entry := range w.content.hashes {
+		if entry.seen.Before(idleSince) {
+			delete(w.content.hashes, path)
+			atomic.AddUint64(&w.stateGC.contentEvicted, 1)
+			evicted++
+		}
+	}
+	w.content.mu.Unlock()
+
+	w.ordering.mu.Lock()
+	for path, entry := range w.ordering.last {
+		if entry.seen.Before(idleSince) {
+			delete(w.ordering.last, path)
+			atomic.AddUint64(&w.stateGC.orderingEvicted, 1)
+			evicted++
+		}
+	}
+	w.ordering.mu.Unlock()
+
+	w.rates.mu.Lock()
+	evicted += w.sweepRatesLocked(w.clock().Now())
+	w.rates.mu.Unlock()
+
+	w.chmod.mu.Lock()
+	evicted += w.sweepChmodLocked(idleSince)
+	w.chmod.mu.Unlock()
+
+	w.statCache.mu.Lock()
+	expired := w.statCache.evict(time.Now(), -1)
+	w.statCache.mu.Unlock()
+	atomic.AddUint64(&w.stateGC.statCacheEvicted, uint64(expired))
+	evicted += expired
+
+	atomic.AddUint64(&w.stateGC.collections, 1)
+	if evicted > 0 {
+		w.log.Debug("Collected ", evicted, " entries of the correlation state")
+	}
+}
+
+func (w *FileWatcher) stateGCStats() StateGCStats {
+	res := StateGCStats{
+		Collections:      atomic.LoadUint64(&w.stateGC.collections),
+		ContentEvicted:   atomic.LoadUint64(&w.stateGC.contentEvicted),
+		OrderingEvicted:  atomic.LoadUint64(&w.stateGC.orderingEvicted),
+		RatesEvicted:     atomic.LoadUint64(&w.stateGC.ratesEvicted),
+		StatCacheEvicted: atomic.LoadUint64(&w.stateGC.statCacheEvicted),
+	}
+	w.content.mu.Lock()
+	res.Content = len(w.content.hashes)
+	w.content.mu.Unlock()
+	w.ordering.mu.Lock()
+	res.Ordering = len(w.ordering.last)
+	w.ordering.mu.Unlock()
+	w.rates.mu.Lock()
+	res.Rates = len(w.rates.paths)
+	w.rates.mu.Unlock()
+	w.statCache.mu.Lock()
+	res.StatCache = len(w.statCache.entries)
+	w.statCache.mu.Unlock()
+	return res
+}
@@ -4,11 +4,10 @@ go 1.19
 
 require (
 	github.com/fsnotify/fsnotify v1.6.0
+	github.com/klauspost/compress v1.17.4
 	github.com/orcaman/concurrent-map/v2 v2.0.1
 	github.com/spf13/afero v1.9.5
+	golang.org/x/sys v0.0.0-20220908164124-27713097b956
 )
 
-require (
-	golang.org/x/sys v0.0.0-20220908164124-27713097b956 // indirect
-	golang.org/x/text v0.3.7 // indirect
-)
+require golang.org/x/text v0.3.7 // indirect
@@ -0,0 +1,842 @@
+package fileWatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// GraphQLSchema is the schema served by GraphQLHandler.
+const GraphQLSchema = `type Query {
+  # history returns the events of the history since the given RFC 3339 time, below path and of the given kinds, the
+  # last limit ones when limit is set. It needs the HistorySize option.
+  history(since: String, path: String, kinds: [String!], limit: Int): [Event!]!
+  watched: [String!]!
+}
+
+type Subscription {
+  events(path: String!, kinds: [String!]): Event!
+}
+
+type Event {
+  id: String!
+  seq: Int!
+  key: String!
+  event: String!
+  path: String!
+  previousPath: String
+  time: String!
+  locked: Boolean!
+  trashed: Boolean!
+  host: String
+  watcherId: String
+  pid: Int
+  traceparent: String
+  tracestate: String
+}
+`
+
+// GraphQLHandler returns an http.Handler serving GraphQLSchema. Queries are sent as GET requests with a query
+// parameter or as POST requests with a JSON body holding the query, its variables and its operationName.
+// Subscriptions are streamed as server-sent events, like the distinct connections mode of GraphQL over SSE: the
+// request must accept text/event-stream, every event is a "next" message and a "complete" message ends the stream
+// when the watcher is closed. Subscriptions are backed by Subscribe, see its notes on slow subscribers: they take no event
+// away from Events, which the application keeps reading.
+//
+// Only the operations, variables, aliases and field selections of GraphQL are supported, not fragments or
+// directives.
+func (w *FileWatcher) GraphQLHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query         string                 `json:"query"`
+			Variables     map[string]interface{} `json:"variables"`
+			OperationName string                 `json:"operationName"`
+		}
+		switch r.Method {
+		case http.MethodGet:
+			req.Query = r.URL.Query().Get("query")
+			req.OperationName = r.URL.Query().Get("operationName")
+			if vars := r.URL.Query().Get("variables"); vars != "" {
+				if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+					writeGraphQLError(rw, http.StatusBadRequest, "invalid variables: "+err.Error())
+					return
+				}
+			}
+		case http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeGraphQLError(rw, http.StatusBadRequest, "invalid request: "+err.Error())
+				return
+			}
+		default:
+			rw.Header().Set("Allow", "GET, POST")
+			writeGraphQLError(rw, http.StatusMethodNotAllowed, "only GET and POST are supported")
+			return
+		}
+
+		op, err := parseGraphQL(req.Query, req.OperationName)
+		if err != nil {
+			writeGraphQLError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+		vars, err := op.resolveVariables(req.Variables)
+		if err != nil {
+			writeGraphQLError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch op.kind {
+		case "query":
+			data, err := w.graphQLQuery(op.selections, vars)
+			if err != nil {
+				writeGraphQLError(rw, http.StatusOK, err.Error())
+				return
+			}
+			rw.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(rw).Encode(map[string]interface{}{"data": data})
+		case "subscription":
+			w.serveGraphQLSubscription(rw, r, op.selections, vars)
+		default:
+			writeGraphQLError(rw, http.StatusBadRequest, op.kind+" operations are not supported")
+		}
+	})
+}
+
+func writeGraphQLError(rw http.ResponseWriter, status int, message string) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"message": message}},
+	})
+}
+
+// graphQLQuery resolves the root fields of a query.
+func (w *FileWatcher) graphQLQuery(fields []*gqlField, vars map[string]interface{}) (gqlObject, error) {
+	var res gqlObject
+	for _, f := range fields {
+		args, err := f.arguments(vars)
+		if err != nil {
+			return nil, err
+		}
+		switch f.name {
+		case "__typename":
+			res = append(res, gqlEntry{f.key(), "Query"})
+		case "watched":
+			if len(f.selections) > 0 {
+				return nil, errors.New("field watched of type [String!]! has no subfields")
+			}
+			res = append(res, gqlEntry{f.key(), w.WatchedPaths()})
+		case "history":
+			events, err := w.graphQLHistory(args)
+			if err != nil {
+				return nil, err
+			}
+			list := make([]gqlObject, 0, len(events))
+			for _, e := range events {
+				o, err := graphQLEvent(w.Export(e), f.selections)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, o)
+			}
+			res = append(res, gqlEntry{f.key(), list})
+		default:
+			return nil, fmt.Errorf("cannot query field %q on type Query", f.name)
+		}
+	}
+	return res, nil
+}
+
+func (w *FileWatcher) graphQLHistory(args map[string]interface{}) ([]FileWatcherEvent, error) {
+	var since time.Time
+	if s, ok := args["since"].(string); ok {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since: %w", err)
+		}
+		since = t
+	}
+	filter, err := graphQLFilter(args)
+	if err != nil {
+		return nil, err
+	}
+	events, err := w.History(since)
+	if err != nil {
+		return nil, err
+	}
+
+	res := events[:0]
+	for _, e := range events {
+		if filter(e) {
+			res = append(res, e)
+		}
+	}
+	if limit, ok := args["limit"].(int); ok && limit >= 0 && limit < len(res) {
+		res = res[len(res)-limit:]
+	}
+	return res, nil
+}
+
+// graphQLFilter returns the filter of the path and kinds arguments.
+func graphQLFilter(args map[string]interface{}) (func(FileWatcherEvent) bool, error) {
+	var kinds EventKind
+	if list, ok := args["kinds"].([]interface{}); ok {
+		for _, item := range list {
+			name, ok := item.(string)
+			if !ok {
+				return nil, errors.New("kinds must be a list of event kinds")
+			}
+			k, err := ParseEventKind(name)
+			if err != nil {
+				return nil, err
+			}
+			kinds |= k
+		}
+	}
+	path, _ := args["path"].(string)
+	s := &subscription{path: normalizePath(path), all: path == "", options: SubscriptionOptions{Kinds: kinds}}
+	return s.wants, nil
+}
+
+func (w *FileWatcher) serveGraphQLSubscription(rw http.ResponseWriter, r *http.Request, fields []*gqlField,
+	vars map[string]interface{}) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		writeGraphQLError(rw, http.StatusNotAcceptable, "subscriptions are streamed as text/event-stream")
+		return
+	}
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		writeGraphQLError(rw, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	if len(fields) != 1 || fields[0].name != "events" {
+		writeGraphQLError(rw, http.StatusBadRequest, "a subscription must select the events field only")
+		return
+	}
+	f := fields[0]
+	args, err := f.arguments(vars)
+	if err != nil {
+		writeGraphQLError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	path, _ := args["path"].(string)
+	if path == "" {
+		writeGraphQLError(rw, http.StatusBadRequest, "events needs a path")
+		return
+	}
+	filter, err := graphQLFilter(args)
+	if err != nil {
+		writeGraphQLError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := graphQLEvent(FileWatcherEvent{}, f.selections); err != nil {
+		writeGraphQLError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, unsubscribe := w.Subscribe(path)
+	defer unsubscribe()
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				_, _ = fmt.Fprint(rw, "event: complete\ndata:\n\n")
+				flusher.Flush()
+				return
+			}
+			if !filter(e) {
+				continue
+			}
+			o, _ := graphQLEvent(w.Export(e), f.selections)
+			data, err := json.Marshal(map[string]interface{}{"data": gqlObject{{f.key(), o}}})
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(rw, "event: next\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// graphQLEvent returns the selected fields of e.
+func graphQLEvent(e FileWatcherEvent, fields []*gqlField) (gqlObject, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("field of type Event must have a selection of subfields")
+	}
+	optional := func(s string) interface{} {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+	res := make(gqlObject, 0, len(fields))
+	for _, f := range fields {
+		if len(f.selections) > 0 {
+			return nil, fmt.Errorf("field %q of type Event has no subfields", f.name)
+		}
+		var value interface{}
+		switch f.name {
+		case "__typename":
+			value = "Event"
+		case "id":
+			value = e.ID
+		case "seq":
+			value = e.Seq
+		case "key":
+			value = e.Key
+		case "event":
+			value = e.Event.String()
+		case "path":
+			value = e.Path
+		case "previousPath":
+			value = optional(e.PreviousPath)
+		case "time":
+			value = e.Time.Format(time.RFC3339Nano)
+		case "locked":
+			value = e.Locked
+		case "trashed":
+			value = e.Trashed
+		case "host":
+			value = optional(e.Host)
+		case "watcherId":
+			value = optional(e.WatcherID)
+		case "pid":
+			if e.PID != 0 {
+				value = e.PID
+			}
+		case "traceparent":
+			value = optional(e.Trace.TraceParent)
+		case "tracestate":
+			value = optional(e.Trace.TraceState)
+		default:
+			return nil, fmt.Errorf("cannot query field %q on type Event", f.name)
+		}
+		res = append(res, gqlEntry{f.key(), value})
+	}
+	return res, nil
+}
+
+// gqlObject is a JSON object keeping the order of the selected fields.
+type gqlObject []gqlEntry
+
+type gqlEntry struct {
+	key   string
+	value interface{}
+}
+
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, entry := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(entry.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(entry.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type gqlOperation struct {
+	kind       string
+	name       string
+	variables  []gqlVariableDef
+	selections []*gqlField
+}
+
+type gqlVariableDef struct {
+	name       string
+	required   bool
+	value      interface{}
+	hasDefault bool
+}
+
+type gqlField struct {
+	alias      string
+	name       string
+	args       map[string]interface{}
+	selections []*gqlField
+}
+
+// gqlVariable is a reference to a variable in an argument.
+type gqlVariable string
+
+// key is the name of the field in the response.
+func (f *gqlField) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// arguments returns the arguments of f with the variables replaced by their values.
+func (f *gqlField) arguments(vars map[string]interface{}) (map[string]interface{}, error) {
+	res := make(map[string]interface{}, len(f.args))
+	for name, value := range f.args {
+		v, err := substituteGraphQL(value, vars)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			res[name] = v
+		}
+	}
+	return res, nil
+}
+
+func substituteGraphQL(value interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case gqlVariable:
+		value, ok := vars[string(v)]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined", v)
+		}
+		if f, ok := value.(float64); ok && f == float64(int(f)) {
+			// JSON numbers of the variables
+			return int(f), nil
+		}
+		return value, nil
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			item, err := substituteGraphQL(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = item
+		}
+		return res, nil
+	}
+	return value, nil
+}
+
+// resolveVariables checks the given variables against the definitions of op and applies the defaults.
+func (op *gqlOperation) resolveVariables(given map[string]interface{}) (map[string]interface{}, error) {
+	res := make(map[string]interface{}, len(op.variables))
+	for _, def := range op.variables {
+		value, ok := given[def.name]
+		switch {
+		case ok && value != nil:
+			res[def.name] = value
+		case def.hasDefault:
+			res[def.name] = def.value
+		case def.required:
+			return nil, fmt.Errorf("variable $%s is required", def.name)
+		default:
+			res[def.name] = nil
+		}
+	}
+	return res, nil
+}
+
+// parseGraphQL returns the operation named name of the document query, or its only operation when name is empty.
+func parseGraphQL(query string, name string) (*gqlOperation, error) {
+	p := &gqlParser{src: strings.TrimPrefix(query, "\uFEFF")}
+	p.next()
+	var ops []*gqlOperation
+	for p.tok != gqlEOF {
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+
+	switch {
+	case len(ops) == 0:
+		return nil, errors.New("the document has no operation")
+	case name == "" && len(ops) > 1:
+		return nil, errors.New("operationName is required with several operations")
+	case name == "":
+		return ops[0], nil
+	}
+	for _, op := range ops {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+type gqlToken int
+
+const (
+	gqlEOF gqlToken = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+type gqlParser struct {
+	src string
+	pos int
+	tok gqlToken
+	val string
+	err error
+}
+
+// next reads the next token, skipping white space, commas and comments.
+func (p *gqlParser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+			continue
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+	if p.pos >= len(p.src) {
+		p.tok, p.val = gqlEOF, ""
+		return
+	}
+
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok, p.val = gqlPunct, "..."
+	case strings.IndexByte("!$()=:@[]{}|&", c) >= 0:
+		p.pos++
+		p.tok, p.val = gqlPunct, string(c)
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		for p.pos < len(p.src) && isGraphQLNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok, p.val = gqlName, p.src[start:p.pos]
+	case c == '-' || c >= '0' && c <= '9':
+		p.pos++
+		p.tok = gqlInt
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			if c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-' {
+				p.tok = gqlFloat
+			} else if c < '0' || c > '9' {
+				break
+			}
+			p.pos++
+		}
+		p.val = p.src[start:p.pos]
+	case c == '"':
+		p.tok = gqlString
+		p.val, p.err = p.string()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.err = fmt.Errorf("unexpected character %q at %d", r, p.pos)
+		p.tok, p.val = gqlEOF, ""
+	}
+}
+
+func isGraphQLNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// string reads a string value, the JSON escapes are those of GraphQL.
+func (p *gqlParser) string() (string, error) {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			return "", errors.New("unterminated block string")
+		}
+		value := p.src[p.pos+3 : p.pos+3+end]
+		p.pos += end + 6
+		return strings.TrimSpace(value), nil
+	}
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.src) && p.src[p.pos] != '"' {
+		if p.src[p.pos] == '\\' {
+			p.pos++
+		}
+		if p.pos < len(p.src) && p.src[p.pos] == '\n' {
+			break
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+		return "", fmt.Errorf("unterminated string at %d", start)
+	}
+	p.pos++
+	var s string
+	if err := json.Unmarshal([]byte(p.src[start:p.pos]), &s); err != nil {
+		return "", fmt.Errorf("invalid string at %d: %w", start, err)
+	}
+	return s, nil
+}
+
+func (p *gqlParser) expect(punct string) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.tok != gqlPunct || p.val != punct {
+		return p.unexpected("expected " + strconv.Quote(punct))
+	}
+	p.next()
+	return nil
+}
+
+func (p *gqlParser) unexpected(context string) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.tok == gqlEOF {
+		return errors.New("unexpected end of the document, " + context)
+	}
+	return fmt.Errorf("unexpected %q at %d, %s", p.val, p.pos-len(p.val), context)
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+	if p.tok != gqlName {
+		return "", p.unexpected("expected a name")
+	}
+	name := p.val
+	p.next()
+	return name, nil
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	op := &gqlOperation{kind: "query"}
+	if p.tok == gqlPunct && p.val == "{" {
+		// the query shorthand
+		selections, err := p.selectionSet()
+		op.selections = selections
+		return op, err
+	}
+	kind, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "query", "mutation", "subscription":
+		op.kind = kind
+	case "fragment":
+		return nil, errors.New("fragments are not supported")
+	default:
+		return nil, fmt.Errorf("unexpected %q, expected an operation", kind)
+	}
+	if p.tok == gqlName {
+		op.name = p.val
+		p.next()
+	}
+	if p.tok == gqlPunct && p.val == "(" {
+		if op.variables, err = p.variableDefinitions(); err != nil {
+			return nil, err
+		}
+	}
+	if p.tok == gqlPunct && p.val == "@" {
+		return nil, errors.New("directives are not supported")
+	}
+	op.selections, err = p.selectionSet()
+	return op, err
+}
+
+func (p *gqlParser) variableDefinitions() ([]gqlVariableDef, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var res []gqlVariableDef
+	for !(p.tok == gqlPunct && p.val == ")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		required, err := p.variableType()
+		if err != nil {
+			return nil, err
+		}
+		def := gqlVariableDef{name: name, required: required}
+		if p.tok == gqlPunct && p.val == "=" {
+			p.next()
+			if def.value, err = p.value(true); err != nil {
+				return nil, err
+			}
+			def.hasDefault = true
+		}
+		res = append(res, def)
+	}
+	p.next()
+	return res, nil
+}
+
+// variableType reads a type like [String!]! and reports whether it is non-null. Values are checked by the fields.
+func (p *gqlParser) variableType() (bool, error) {
+	if p.tok == gqlPunct && p.val == "[" {
+		p.next()
+		if _, err := p.variableType(); err != nil {
+			return false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.name(); err != nil {
+		return false, err
+	}
+	if p.tok == gqlPunct && p.val == "!" {
+		p.next()
+		return true, nil
+	}
+	return false, nil
+}
+
+func (p *gqlParser) selectionSet() ([]*gqlField, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var res []*gqlField
+	for !(p.tok == gqlPunct && p.val == "}") {
+		if p.tok == gqlPunct && p.val == "..." {
+			return nil, errors.New("fragments are not supported")
+		}
+		f, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, f)
+	}
+	p.next()
+	if len(res) == 0 {
+		return nil, errors.New("empty selection set")
+	}
+	return res, nil
+}
+
+func (p *gqlParser) field() (*gqlField, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	f := &gqlField{name: name}
+	if p.tok == gqlPunct && p.val == ":" {
+		p.next()
+		f.alias = name
+		if f.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if p.tok == gqlPunct && p.val == "(" {
+		p.next()
+		f.args = make(map[string]interface{})
+		for !(p.tok == gqlPunct && p.val == ")") {
+			arg, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if f.args[arg], err = p.value(false); err != nil {
+				return nil, err
+			}
+		}
+		p.next()
+	}
+	if p.tok == gqlPunct && p.val == "@" {
+		return nil, errors.New("directives are not supported")
+	}
+	if p.tok == gqlPunct && p.val == "{" {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, p.err
+}
+
+// value reads a value. Enum values are returned as strings, so the kinds can be written as names or as strings.
+func (p *gqlParser) value(constant bool) (interface{}, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok, val := p.tok, p.val
+	switch tok {
+	case gqlInt:
+		p.next()
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int %q", val)
+		}
+		return n, nil
+	case gqlFloat:
+		p.next()
+		return strconv.ParseFloat(val, 64)
+	case gqlString:
+		p.next()
+		return val, nil
+	case gqlName:
+		p.next()
+		switch val {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return val, nil
+	case gqlPunct:
+		switch val {
+		case "$":
+			if constant {
+				return nil, errors.New("variables are not allowed in default values")
+			}
+			p.next()
+			name, err := p.name()
+			return gqlVariable(name), err
+		case "[":
+			p.next()
+			list := []interface{}{}
+			for !(p.tok == gqlPunct && p.val == "]") {
+				item, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+			p.next()
+			return list, nil
+		case "{":
+			return nil, errors.New("input objects are not supported")
+		}
+	}
+	return nil, p.unexpected("expected a value")
+}
@@ -0,0 +1,65 @@
+package fileWatcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphQLSubscriptionLeavesEvents(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t)
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(w.GraphQLHandler())
+	defer server.Close()
+
+	query := url.Values{"query": {`subscription { events(path: "` + filepath.ToSlash(root) + `") { event path } }`}}
+	req, err := http.NewRequest(http.MethodGet, server.URL+"?"+query.Encode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(resp.Status)
+	}
+	streamed := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+				streamed <- line
+			}
+		}
+	}()
+
+	path := filepath.Join(root, "file")
+	// the subscription is registered once the headers are sent, the file is written until both readers got it
+	received, remote := false, false
+	timeout := time.After(testTimeout)
+	for !received || !remote {
+		writeFile(t, path, "")
+		select {
+		case e := <-w.Events:
+			received = received || e.Path == path
+		case line := <-streamed:
+			quoted, _ := json.Marshal(path)
+			remote = remote || strings.Contains(line, string(quoted))
+		case <-time.After(50 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("received on Events %v, streamed %v", received, remote)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package fileWatcher
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// WatchGroup is a named set of watches that can be operated on collectively, for example the watches of one project
+// of an IDE. Get one with FileWatcher.Group.
+type WatchGroup struct {
+	Name string
+
+	w      *FileWatcher
+	mu     sync.Mutex
+	paths  map[string]bool
+	paused bool
+}
+
+type groupState struct {
+	mu     sync.Mutex
+	groups map[string]*WatchGroup
+	// paused counts the paused groups, so events are only checked against the groups while one is paused.
+	paused int32
+}
+
+// Group returns the watch group with the given name, creating it when needed.
+func (w *FileWatcher) Group(name string) *WatchGroup {
+	w.groups.mu.Lock()
+	defer w.groups.mu.Unlock()
+	if w.groups.groups == nil {
+		w.groups.groups = make(map[string]*WatchGroup)
+	}
+	g, ok := w.groups.groups[name]
+	if !ok {
+		g = &WatchGroup{Name: name, w: w, paths: make(map[string]bool)}
+		w.groups.groups[name] = g
+	}
+	return g
+}
+
+// Groups returns the names of the watch groups.
+func (w *FileWatcher) Groups() []string {
+	w.groups.mu.Lock()
+	defer w.groups.mu.Unlock()
+	names := make([]string, 0, len(w.groups.groups))
+	for name := range w.groups.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RemoveGroup removes every watch of the group, except those another group holds too, and forgets the group.
+func (w *FileWatcher) RemoveGroup(name string) error {
+	w.groups.mu.Lock()
+	g, ok := w.groups.groups[name]
+	delete(w.groups.groups, name)
+	w.groups.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	g.ResumeAll()
+	return g.Remove(g.Paths()...)
+}
+
+// Add watches paths as members of the group.
+func (g *WatchGroup) Add(paths ...string) error {
+	for _, path := range paths {
+		path = g.w.normalize(path)
+		if err := g.w.Add(path); err != nil {
+			return err
+		}
+		g.mu.Lock()
+		g.paths[path] = true
+		g.mu.Unlock()
+	}
+	return nil
+}
+
+// AddRecursive watches the directory trees at paths as members of the group, see FileWatcher.AddRecursive.
+func (g *WatchGroup) AddRecursive(paths ...string) error {
+	for _, path := range paths {
+		path = g.w.normalize(path)
+		if err := g.w.AddRecursive(path); err != nil {
+			return err
+		}
+		g.mu.Lock()
+		g.paths[path] = true
+		g.mu.Unlock()
+	}
+	return nil
+}
+
+// Remove removes paths from the group, and their watches unless another group holds them too.
+func (g *WatchGroup) Remove(paths ...string) error {
+	for _, path := range paths {
+		path = g.w.normalize(path)
+		g.mu.Lock()
+		member := g.paths[path]
+		delete(g.paths, path)
+		g.mu.Unlock()
+		if !member || g.w.groupHolds(path) {
+			continue
+		}
+
+		var err error
+		if g.w.recursiveRoot(path) {
+			g.w.removeTree(path)
+			g.w.recursive.mu.Lock()
+			delete(g.w.recursive.roots, path)
+			g.w.recursive.mu.Unlock()
+		} else {
+			err = g.w.Remove(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Paths returns the members of the group.
+func (g *WatchGroup) Paths() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	paths := make([]string, 0, len(g.paths))
+	for path := range g.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// PauseAll drops the events of every member of the group, and of the paths below them, until ResumeAll is called.
+// The watches are kept, so resuming is cheap.
+func (g *WatchGroup) PauseAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		g.paused = true
+		atomic.AddInt32(&g.w.groups.paused, 1)
+	}
+}
+
+// ResumeAll passes on the events of the members of the group again.
+func (g *WatchGroup) ResumeAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		g.paused = false
+		atomic.AddInt32(&g.w.groups.paused, -1)
+	}
+}
+
+// Paused reports whether the group is paused.
+func (g *WatchGroup) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// covers reports whether path is a member of the group or below one.
+func (g *WatchGroup) covers(path string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for member := range g.paths {
+		if path == member || isBelow(path, member) {
+			return true
+		}
+	}
+	return false
+}
+
+// groupHolds reports whether a group has path as a member.
+func (w *FileWatcher) groupHolds(path string) bool {
+	w.groups.mu.Lock()
+	defer w.groups.mu.Unlock()
+	for _, g := range w.groups.groups {
+		g.mu.Lock()
+		member := g.paths[path]
+		g.mu.Unlock()
+		if member {
+			return true
+		}
+	}
+	return false
+}
+
+// pausedGroup returns the name of a paused group covering path.
+func (w *FileWatcher) pausedGroup(path string) (string, bool) {
+	if atomic.LoadInt32(&w.groups.paused) == 0 {
+		return "", false
+	}
+	w.groups.mu.Lock()
+	defer w.groups.mu.Unlock()
+	for name, g := range w.groups.groups {
+		if g.Paused() && g.covers(path) {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,220 @@
+package fileWatcher
+
+import (
+	"hash/fnv"
+	"runtime"
+	"sync"
+)
+
+// EventHandler is called for every event delivered on Events, see FileWatcher.OnEvent.
+type EventHandler func(e FileWatcherEvent)
+
+// ErrorHandler is called for every error delivered on Errors, see FileWatcher.OnError.
+type ErrorHandler func(err error)
+
+type handlerState struct {
+	mu      sync.RWMutex
+	started bool
+	nextID  int
+	events  map[int]EventHandler
+	errors  map[int]ErrorHandler
+	// queues are the queues of the workers, once started.
+	queues []chan handlerTask
+	// stopped is closed once the workers handled the last events, see waitHandlers.
+	stopped chan struct{}
+}
+
+// handlerTask is an event for a handler worker, or a barrier closing done once the worker got through the events
+// queued before it, then keeping it waiting until hold is closed. The event waits for after before being handled, and
+// closes released once handled.
+type handlerTask struct {
+	e        FileWatcherEvent
+	done     chan struct{}
+	hold     <-chan struct{}
+	after    <-chan struct{}
+	released chan struct{}
+}
+
+// OnEvent registers h to be called for every event and returns a function unregistering it. Handlers are called from
+// a pool of HandlerWorkers goroutines. The events of a path are always handled by the same worker, so every handler
+// sees them in order, but events of different paths are handled concurrently.
+//
+// Once a handler is registered, the watcher reads Events, Errors and Priority itself: do not read them too.
+func (w *FileWatcher) OnEvent(h EventHandler) func() {
+	w.handlers.mu.Lock()
+	defer w.handlers.mu.Unlock()
+	if w.handlers.events == nil {
+		w.handlers.events = make(map[int]EventHandler)
+	}
+	id := w.handlers.nextID
+	w.handlers.nextID++
+	w.handlers.events[id] = h
+	w.startHandlers()
+
+	return func() {
+		w.handlers.mu.Lock()
+		defer w.handlers.mu.Unlock()
+		delete(w.handlers.events, id)
+	}
+}
+
+// OnError registers h to be called for every error and returns a function unregistering it. Error handlers are
+// called one error at a time.
+func (w *FileWatcher) OnError(h ErrorHandler) func() {
+	w.handlers.mu.Lock()
+	defer w.handlers.mu.Unlock()
+	if w.handlers.errors == nil {
+		w.handlers.errors = make(map[int]ErrorHandler)
+	}
+	id := w.handlers.nextID
+	w.handlers.nextID++
+	w.handlers.errors[id] = h
+	w.startHandlers()
+
+	return func() {
+		w.handlers.mu.Lock()
+		defer w.handlers.mu.Unlock()
+		delete(w.handlers.errors, id)
+	}
+}
+
+// startHandlers starts reading Events and Errors for the handlers until the watcher is closed. The caller holds the
+// lock.
+func (w *FileWatcher) startHandlers() {
+	if w.handlers.started || w.closed() {
+		return
+	}
+	w.handlers.started = true
+	stopped := make(chan struct{})
+	w.handlers.stopped = stopped
+
+	workers := w.options.HandlerWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	wg := sync.WaitGroup{}
+	queues := make([]chan handlerTask, workers)
+	for i := range queues {
+		queues[i] = make(chan handlerTask, 64)
+		wg.Add(1)
+		go func(queue chan handlerTask) {
+			defer wg.Done()
+			w.labelGoroutine("handlers")
+			for t := range queue {
+				if t.done != nil {
+					close(t.done)
+					<-t.hold
+					continue
+				}
+				if t.after != nil {
+					<-t.after
+				}
+				e := t.e
+				w.handlers.mu.RLock()
+				handlers := make([]EventHandler, 0, len(w.handlers.events))
+				for _, h := range w.handlers.events {
+					handlers = append(handlers, h)
+				}
+				w.handlers.mu.RUnlock()
+				for _, h := range handlers {
+					h(e)
+				}
+				if t.released != nil {
+					close(t.released)
+				}
+			}
+		}(queues[i])
+	}
+	w.handlers.queues = queues
+
+	go func() {
+		w.labelGoroutine("handlers")
+		defer close(stopped)
+		defer func() {
+			for _, queue := range queues {
+				close(queue)
+			}
+			wg.Wait()
+		}()
+		worker := func(path string) chan handlerTask {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(w.orderKey(path)))
+			return queues[h.Sum32()%uint32(workers)]
+		}
+		queue := func(e FileWatcherEvent) {
+			task := handlerTask{e: e}
+			to := worker(e.Path)
+			// with OrderedDelivery, the worker of the old directory of a rename waits for it to be handled
+			if w.options.OrderedDelivery && e.PreviousPath != "" {
+				if from := worker(e.PreviousPath); from != to {
+					done := make(chan struct{})
+					task.after, task.released = done, make(chan struct{})
+					from <- handlerTask{done: done, hold: task.released}
+				}
+			}
+			to <- task
+		}
+		// the events left once the channels are closed are handled too, the loop ends once all three are drained
+		priority, events, errs := w.Priority, w.Events, w.Errors
+		for priority != nil || events != nil || errs != nil {
+			// the events with PriorityHigh go ahead of the ones waiting on Events
+			select {
+			case e, ok := <-priority:
+				if !ok {
+					priority = nil
+					continue
+				}
+				queue(e)
+				continue
+			default:
+			}
+			select {
+			case e, ok := <-priority:
+				if !ok {
+					priority = nil
+					continue
+				}
+				queue(e)
+			case e, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				queue(e)
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				w.handlers.mu.RLock()
+				handlers := make([]ErrorHandler, 0, len(w.handlers.errors))
+				for _, h := range w.handlers.errors {
+					handlers = append(handlers, h)
+				}
+				w.handlers.mu.RUnlock()
+				if len(handlers) == 0 {
+					w.log.Error("Unhandled watcher error: ", err)
+				}
+				for _, h := range handlers {
+					h(err)
+				}
+			}
+		}
+	}()
+}
+
+// waitHandlers waits for the handlers to handle the events left once the channels are closed, and for their
+// goroutines to return, until the close timeout. A handler calling Close holds it back until then.
+func (w *FileWatcher) waitHandlers() {
+	w.handlers.mu.RLock()
+	stopped := w.handlers.stopped
+	w.handlers.mu.RUnlock()
+	if stopped == nil {
+		return
+	}
+	select {
+	case <-stopped:
+	case <-w.life.abandon:
+		w.log.Warn("Event handlers still running after the close timeout, Close returns without them")
+	}
+}
@@ -0,0 +1,93 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCloseWaitsForHandlers(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t, WithHandlerWorkers(2))
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	handled := 0
+	received := make(chan struct{}, 1)
+	w.OnEvent(func(e FileWatcherEvent) {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		// a slow handler, still busy when Close is called
+		time.Sleep(100 * time.Millisecond)
+		mu.Lock()
+		handled++
+		mu.Unlock()
+	})
+	writeFile(t, filepath.Join(root, "file"), "")
+	select {
+	case <-received:
+	case <-time.After(testTimeout):
+		t.Fatal("no event handled")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if handled == 0 {
+		t.Fatal("Close returned before the handler")
+	}
+	if goroutines := w.goroutines()["handlers"]; goroutines > 0 {
+		t.Fatalf("Close left %d handlers goroutines", goroutines)
+	}
+}
+
+func TestOnEventAfterClose(t *testing.T) {
+	w := newTestWatcher(t)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w.OnEvent(func(FileWatcherEvent) {})
+	if goroutines := w.goroutines()["handlers"]; goroutines > 0 {
+		t.Fatalf("%d handlers goroutines started on a closed watcher", goroutines)
+	}
+}
+
+func TestHandlersDrainBufferedEvents(t *testing.T) {
+	withoutCustomKinds(t)
+	kind, err := RegisterEventKind("TICK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newTestWatcher(t, WithEventBuffer(256, OverflowBlock), WithHandlerWorkers(1))
+	var mu sync.Mutex
+	handled := 0
+	w.OnEvent(func(e FileWatcherEvent) {
+		// a slow handler, the events pile up in the buffer of Events
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		handled++
+		mu.Unlock()
+	})
+
+	const events = 200
+	for i := 0; i < events; i++ {
+		if err := w.Emit(FileWatcherEvent{Event: kind, Path: t.TempDir()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if handled != events {
+		t.Fatalf("%d of the %d events emitted before Close handled", handled, events)
+	}
+}
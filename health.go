@@ -0,0 +1,258 @@
+package fileWatcher
+
+import (
+	"errors"
+	"github.com/fsnotify/fsnotify"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotifierClosed is the LastError of a watcher whose notifier closed its channels on its own, which it does when
+// reading the kernel queue fails.
+var ErrNotifierClosed = errors.New("the notifier stopped delivering events")
+
+const (
+	// defaultHealthRetry is how long a watcher waits before trying again to re-create a notifier it could not
+	// re-create.
+	defaultHealthRetry = time.Second
+	// healthProbes is how many directories a health check probes before deciding the notifier is dead.
+	healthProbes = 3
+)
+
+type healthState struct {
+	// mu guards the swaps of the notifier, and of Watcher with it.
+	mu      sync.RWMutex
+	lastErr error
+	// dead is set from when the notifier is found dead until it is re-created.
+	dead        int32
+	recreations uint64
+	// recreate asks the event loop to re-create the notifier, with the reason.
+	recreate chan error
+	stop     chan struct{}
+	// retry asks again for a notifier that could not be re-created.
+	retry Timer
+}
+
+// Healthy reports whether the watcher is open and its notifier delivers events. A notifier found dead, because its
+// channels were closed or because the health checks saw none of the watched directories deliver events, is
+// re-created and every watched path added to it again: the watcher is unhealthy until then.
+func (w *FileWatcher) Healthy() bool {
+	w.life.mu.RLock()
+	closed := w.life.closed
+	w.life.mu.RUnlock()
+	return !closed && atomic.LoadInt32(&w.health.dead) == 0
+}
+
+// LastError returns the last error reported by the notifier or met re-creating it, nil when there was none. The
+// overflows the watcher recovered from are reported too.
+func (w *FileWatcher) LastError() error {
+	w.health.mu.RLock()
+	defer w.health.mu.RUnlock()
+	return w.health.lastErr
+}
+
+func (w *FileWatcher) recordError(err error) {
+	w.health.mu.Lock()
+	w.health.lastErr = err
+	w.health.mu.Unlock()
+}
+
+// currentNotifier returns the notifier, which is replaced when it is re-created.
+func (w *FileWatcher) currentNotifier() notifier {
+	w.health.mu.RLock()
+	defer w.health.mu.RUnlock()
+	return w.notifier
+}
+
+// startHealthCheck probes the watched directories every HealthCheckInterval until the watcher is closed.
+func (w *FileWatcher) startHealthCheck() {
+	interval := w.options.HealthCheckInterval
+	if interval <= 0 {
+		return
+	}
+	w.health.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		w.labelGoroutine("health-check")
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := w.checkHealth(); err != nil {
+					w.requestRecreate(err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(w.health.stop)
+}
+
+func (w *FileWatcher) stopHealthCheck() {
+	if w.health.stop != nil {
+		close(w.health.stop)
+	}
+	w.health.mu.Lock()
+	if w.health.retry != nil {
+		w.health.retry.Stop()
+	}
+	w.health.mu.Unlock()
+}
+
+// checkHealth probes up to three of the directories the notifier watches and returns an error when none of them
+// delivered its sentinel. A single directory failing is a broken watch, not a dead notifier.
+func (w *FileWatcher) checkHealth() error {
+	if atomic.LoadInt32(&w.health.dead) != 0 {
+		return nil
+	}
+	probed := 0
+	var last error
+	for _, path := range w.kernelPaths() {
+		if probed == healthProbes {
+			break
+		}
+		if info, err := w.fs.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+		err := w.Probe(path)
+		if err == nil {
+			return nil
+		}
+		// a directory gone or read-only tells nothing about the notifier
+		if errors.Is(err, ErrWatchDead) {
+			probed++
+			last = err
+		}
+	}
+	return last
+}
+
+// requestRecreate asks the event loop to re-create the notifier, unless a request is already waiting.
+func (w *FileWatcher) requestRecreate(reason error) {
+	select {
+	case w.health.recreate <- reason:
+	default:
+	}
+}
+
+// notifierDead marks the notifier dead for reason and asks for it to be re-created.
+func (w *FileWatcher) notifierDead(reason error) {
+	if atomic.CompareAndSwapInt32(&w.health.dead, 0, 1) {
+		w.log.Warn("The notifier is dead: ", reason)
+		w.watchError(ErrWatchLost, "", reason)
+	}
+	w.recordError(reason)
+	w.requestRecreate(reason)
+}
+
+// recreateNotifier replaces the dead notifier with a new one watching every path again, and returns the channels of
+// the new one for the event loop. The events that happened meanwhile are lost: the listings are scanned again and a
+// RESYNC_REQUIRED event is emitted for every root, consumers keeping state about it should rescan it. When the
+// notifier can't be re-created, the channels are nil and it is tried again later.
+func (w *FileWatcher) recreateNotifier(reason error) (<-chan fsnotify.Event, <-chan error) {
+	if atomic.CompareAndSwapInt32(&w.health.dead, 0, 1) {
+		// found dead by a health check
+		w.watchError(ErrWatchLost, "", reason)
+	}
+	w.recordError(reason)
+	fsWatcher, err := newNotifier()
+	if err != nil {
+		w.log.Warn("Unable to re-create the notifier: ", err)
+		w.recordError(err)
+		w.watchError(ErrWatchLost, "", err)
+		w.retryRecreate(reason)
+		return nil, nil
+	}
+
+	w.health.mu.Lock()
+	old, oldWatcher := w.notifier, w.Watcher
+	w.Watcher = fsWatcher
+	w.notifier = w.newNotifier()
+	current := w.notifier
+	w.health.mu.Unlock()
+	_ = old.Close()
+	if _, ok := old.(fsnotifyNotifier); !ok {
+		_ = oldWatcher.Close()
+	}
+
+	// the paths added meanwhile are recorded before they are handed to the notifier, so they are not missed
+	paths := w.kernelPaths()
+	for _, path := range paths {
+		if err := current.Add(path); err != nil {
+			w.log.Warn("Unable to watch ", path, " again: ", err)
+			w.recordError(err)
+			w.watchError(ErrWatchLost, path, err)
+		}
+	}
+	atomic.StoreInt32(&w.health.dead, 0)
+	atomic.AddUint64(&w.health.recreations, 1)
+	w.log.Info("Re-created the notifier, watching ", len(paths), " paths again")
+
+	for _, dir := range w.listings.listed() {
+		if err := w.listings.scan(w.fs, dir); err != nil {
+			w.log.Debug("Unable to rescan ", dir, ": ", err)
+		}
+	}
+	for _, root := range w.Roots() {
+		w.emit(FileWatcherEvent{Event: EventResyncRequired, Path: root.Path})
+	}
+	return current.notifications()
+}
+
+// retryRecreate asks for the notifier to be re-created again after a while.
+func (w *FileWatcher) retryRecreate(reason error) {
+	delay := w.options.HealthCheckInterval
+	if delay <= 0 {
+		delay = defaultHealthRetry
+	}
+	retry := w.clock().AfterFunc(delay, func() {
+		w.requestRecreate(reason)
+	})
+	w.health.mu.Lock()
+	w.health.retry = retry
+	w.health.mu.Unlock()
+}
+
+// kernelPaths returns the paths handed to the notifier: the watched directories, the directories watched in place of
+// their files, the files watched on their own and the directories of the SQLite databases.
+func (w *FileWatcher) kernelPaths() []string {
+	paths := make(map[string]bool)
+	w.scopes.mu.Lock()
+	for dir, scope := range w.scopes.dirs {
+		if scope.explicit || scope.promoted {
+			paths[dir] = true
+			continue
+		}
+		for file := range scope.files {
+			paths[file] = true
+		}
+	}
+	w.scopes.mu.Unlock()
+
+	w.poller.mu.Lock()
+	polled := make(map[string]bool, len(w.poller.paths))
+	for path := range w.poller.paths {
+		polled[path] = true
+	}
+	w.poller.mu.Unlock()
+	for _, path := range w.WatchedMap.Keys() {
+		if !polled[path] && !w.isFileWatch(path) {
+			paths[path] = true
+		}
+	}
+
+	w.sqlite.mu.Lock()
+	for dir := range w.sqlite.dirs {
+		paths[dir] = true
+	}
+	w.sqlite.mu.Unlock()
+
+	res := make([]string, 0, len(paths))
+	for path := range paths {
+		res = append(res, path)
+	}
+	sort.Strings(res)
+	return res
+}
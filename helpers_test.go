@@ -0,0 +1,81 @@
+package fileWatcher
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// testTimeout bounds every wait of the tests for an event.
+const testTimeout = 5 * time.Second
+
+// nopLogger drops everything.
+type nopLogger struct{}
+
+func (nopLogger) Panic(args ...interface{}) {}
+func (nopLogger) Error(args ...interface{}) {}
+func (nopLogger) Warn(args ...interface{})  {}
+func (nopLogger) Info(args ...interface{})  {}
+func (nopLogger) Debug(args ...interface{}) {}
+func (nopLogger) Trace(args ...interface{}) {}
+func (nopLogger) Print(args ...interface{}) {}
+
+// newTestWatcher returns a watcher on the filesystem of the host, closed with the test.
+func newTestWatcher(t *testing.T, opts ...Option) *FileWatcher {
+	t.Helper()
+	w, err := Init(nil, afero.NewOsFs(), nopLogger{}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		go func() {
+			for range w.Events {
+			}
+		}()
+		_ = w.Close()
+	})
+	return w
+}
+
+// waitEvent returns the first event of w matching match, failing the test when none comes within testTimeout.
+func waitEvent(t *testing.T, w *FileWatcher, match func(FileWatcherEvent) bool) FileWatcherEvent {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case e, ok := <-w.Events:
+			if !ok {
+				t.Fatal("the watcher closed its events")
+			}
+			if match(e) {
+				return e
+			}
+		case <-timeout:
+			t.Fatal("no matching event within ", testTimeout)
+		}
+	}
+}
+
+// chdir changes the working directory to dir for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(previous)
+	})
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
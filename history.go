@@ -0,0 +1,129 @@
+package fileWatcher
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// ErrNoHistory is returned by the history based APIs when the HistorySize option is not set.
+var ErrNoHistory = errors.New("event history is disabled")
+
+// history keeps the last emitted events in a ring, oldest first.
+type history struct {
+	mu     sync.RWMutex
+	events []FileWatcherEvent
+	start  int
+	count  int
+	// retention is how long the events are kept, zero keeps them until the ring is full.
+	retention time.Duration
+	clock     Clock
+}
+
+func newHistory(size int, retention time.Duration, clock Clock) *history {
+	if size <= 0 {
+		return nil
+	}
+	return &history{events: make([]FileWatcherEvent, size), retention: retention, clock: clock}
+}
+
+func (h *history) append(e FileWatcherEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.expire(e.Time)
+	if h.count < len(h.events) {
+		h.events[(h.start+h.count)%len(h.events)] = e
+		h.count++
+		return
+	}
+	h.events[h.start] = e
+	h.start = (h.start + 1) % len(h.events)
+}
+
+// expire drops the events older than the retention at now. The caller holds the write lock.
+func (h *history) expire(now time.Time) {
+	if h.retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-h.retention)
+	for h.count > 0 && h.events[h.start].Time.Before(cutoff) {
+		h.events[h.start] = FileWatcherEvent{}
+		h.start = (h.start + 1) % len(h.events)
+		h.count--
+	}
+}
+
+// since returns the events emitted at or after t, oldest first.
+func (h *history) since(t time.Time) []FileWatcherEvent {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.retention > 0 {
+		if cutoff := h.clock.Now().Add(-h.retention); t.Before(cutoff) {
+			t = cutoff
+		}
+	}
+
+	// events are appended in time order, find the first one not before t
+	first := sort.Search(h.count, func(i int) bool {
+		return !h.events[(h.start+i)%len(h.events)].Time.Before(t)
+	})
+	res := make([]FileWatcherEvent, 0, h.count-first)
+	for i := first; i < h.count; i++ {
+		res = append(res, h.events[(h.start+i)%len(h.events)])
+	}
+	return res
+}
+
+// sinceSeq returns the events with a Seq above seq, oldest first.
+func (h *history) sinceSeq(seq uint64) []FileWatcherEvent {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var cutoff time.Time
+	if h.retention > 0 {
+		cutoff = h.clock.Now().Add(-h.retention)
+	}
+	// the events emitted concurrently may be appended slightly out of Seq order, they are all checked
+	var res []FileWatcherEvent
+	for i := 0; i < h.count; i++ {
+		e := h.events[(h.start+i)%len(h.events)]
+		if e.Seq > seq && !e.Time.Before(cutoff) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// size estimates the memory held by the history, in bytes.
+func (h *history) size() int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	res := int64(len(h.events)) * int64(unsafe.Sizeof(FileWatcherEvent{}))
+	for i := 0; i < h.count; i++ {
+		e := h.events[(h.start+i)%len(h.events)]
+		res += int64(len(e.Path) + len(e.PreviousPath) + len(e.Key) + len(e.ID))
+	}
+	return res
+}
+
+// History returns the events emitted since t that are still in the history, oldest first.
+func (w *FileWatcher) History(since time.Time) ([]FileWatcherEvent, error) {
+	if w.history == nil {
+		return nil, ErrNoHistory
+	}
+	return w.history.since(since), nil
+}
+
+// EventsSince returns the events of the history with a Seq above seq, oldest first, so a consumer that missed events,
+// like a restarted goroutine or a reconnected client, catches up from the Seq of the last event it handled without a
+// full rescan. It returns nil without a history, see the HistorySize and HistoryRetention options.
+//
+// The history only holds the last events: when the first event returned is not seq+1, or nothing is returned while
+// the watcher went on emitting, events were lost and the consumer has to rescan.
+func (w *FileWatcher) EventsSince(seq uint64) []FileWatcherEvent {
+	if w.history == nil {
+		return nil
+	}
+	return w.history.sinceSeq(seq)
+}
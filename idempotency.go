@@ -0,0 +1,92 @@
+package fileWatcher
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// idempotencyKey builds the key of the event with sequence number seq. The start time of the watcher tells apart the
+// sequences of successive runs, the identity the sequences of different watchers.
+func (w *FileWatcher) idempotencyKey(seq uint64) string {
+	b := strings.Builder{}
+	if id := w.options.Identity; id != nil {
+		b.WriteString(id.Host)
+		b.WriteByte(':')
+		b.WriteString(id.WatcherID)
+		b.WriteByte(':')
+	}
+	b.WriteString(w.epoch)
+	b.WriteByte('-')
+	b.WriteString(strconv.FormatUint(seq, 10))
+	return b.String()
+}
+
+// IdempotencyStore remembers the idempotency keys of the events a sink has processed.
+type IdempotencyStore interface {
+	Seen(key string) (bool, error)
+	Mark(key string) error
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore keeping the last keys in memory.
+type MemoryIdempotencyStore struct {
+	mu    sync.Mutex
+	keys  map[string]struct{}
+	order []string
+	next  int
+}
+
+// NewMemoryIdempotencyStore creates a MemoryIdempotencyStore remembering the last size keys.
+func NewMemoryIdempotencyStore(size int) *MemoryIdempotencyStore {
+	if size < 1 {
+		size = 1
+	}
+	return &MemoryIdempotencyStore{keys: make(map[string]struct{}, size), order: make([]string, 0, size)}
+}
+
+func (s *MemoryIdempotencyStore) Seen(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.keys[key]
+	return ok, nil
+}
+
+func (s *MemoryIdempotencyStore) Mark(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[key]; ok {
+		return nil
+	}
+	if len(s.order) < cap(s.order) {
+		s.order = append(s.order, key)
+	} else {
+		delete(s.keys, s.order[s.next])
+		s.order[s.next] = key
+		s.next = (s.next + 1) % len(s.order)
+	}
+	s.keys[key] = struct{}{}
+	return nil
+}
+
+// Idempotent wraps sink so every event is processed at most once, whatever the number of times it is retried or
+// replayed: events whose key is in store are skipped, and the key of every event sink accepted is added to it.
+// Events without a key are always passed on.
+func Idempotent(sink Sink, store IdempotencyStore) Sink {
+	return SinkFunc(func(e FileWatcherEvent) error {
+		if e.Key == "" {
+			return sink.Send(e)
+		}
+		seen, err := store.Seen(e.Key)
+		if err != nil {
+			return err
+		}
+		if seen {
+			log.Trace("Skipping event ", e.Key, ", it was already delivered")
+			return nil
+		}
+		if err := sink.Send(e); err != nil {
+			return err
+		}
+		return store.Mark(e.Key)
+	})
+}
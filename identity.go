@@ -0,0 +1,43 @@
+package fileWatcher
+
+import (
+	"os"
+)
+
+// Identity is stamped onto every event so events merged from many agents, or many watchers of a process, can be
+// attributed downstream.
+type Identity struct {
+	// Host defaults to the hostname of the machine.
+	Host string
+	// WatcherID names the watcher within the process.
+	WatcherID string
+	// PID defaults to the id of the current process.
+	PID int
+}
+
+// resolveIdentity fills the defaults of the identity option.
+func resolveIdentity(id *Identity) {
+	if id == nil {
+		return
+	}
+	if id.Host == "" {
+		host, err := os.Hostname()
+		if err == nil {
+			id.Host = host
+		}
+	}
+	if id.PID == 0 {
+		id.PID = os.Getpid()
+	}
+}
+
+// stamp adds the identity of the watcher to e.
+func (w *FileWatcher) stamp(e *FileWatcherEvent) {
+	id := w.options.Identity
+	if id == nil {
+		return
+	}
+	e.Host = id.Host
+	e.WatcherID = id.WatcherID
+	e.PID = id.PID
+}
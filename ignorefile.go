@@ -0,0 +1,370 @@
+package fileWatcher
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WatcherIgnoreFile is the name of the ignore file dedicated to the watcher, with .gitignore semantics.
+const WatcherIgnoreFile = ".watcherignore"
+
+// DefaultIgnoreFiles are the ignore files imported by WithIgnoreFiles when no names are given.
+var DefaultIgnoreFiles = []string{".gitignore", ".dockerignore", WatcherIgnoreFile}
+
+// vcsDirs are the directories of the version control systems. Like the version control systems never track them, their
+// content is ignored below the directories of the ignore files.
+var vcsDirs = map[string]bool{".git": true, ".hg": true, ".svn": true, ".bzr": true, "_darcs": true, ".jj": true}
+
+// ignoreRule is a single line of an ignore file.
+type ignoreRule struct {
+	source  string
+	line    int
+	pattern string
+	re      *regexp.Regexp
+	negate  bool
+	// dirOnly is set by a trailing slash, the rule only matches directories.
+	dirOnly bool
+}
+
+// ignoreFile holds the rules of an ignore file, relative to the directory it is in.
+type ignoreFile struct {
+	path  string
+	base  string
+	rules []ignoreRule
+	// docker is set for a .dockerignore file, whose rules also match the paths below the ones they match.
+	docker bool
+}
+
+// ignoreFiles evaluates the rules of the imported ignore files with gitignore precedence: files in deeper directories
+// override the ones above them, later lines override earlier ones and "!" lines re-include paths, except the paths
+// below an excluded directory.
+type ignoreFiles struct {
+	mu    sync.RWMutex
+	files map[string]*ignoreFile
+	// ordered holds the files from the shallowest to the deepest directory.
+	ordered []*ignoreFile
+	// isDir tells whether a path is a directory, for the rules only matching directories. Without it, they only
+	// match the paths below the directories.
+	isDir func(path string) bool
+}
+
+// load reads the ignore file at path on fsys, replacing the rules it was previously loaded with. Invalid patterns are
+// logged to l and skipped.
+func (f *ignoreFiles) load(fsys afero.Fs, l Logger, path string) error {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	docker := filepath.Base(path) == ".dockerignore"
+	loaded := &ignoreFile{path: path, base: filepath.Dir(path), docker: docker}
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		rule, ok, err := parseIgnoreLine(scanner.Text(), docker)
+		if err != nil {
+			l.Warn("Invalid pattern in ", path, " line ", line, ": ", err)
+			continue
+		}
+		if ok {
+			rule.source = path
+			rule.line = line
+			loaded.rules = append(loaded.rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.files == nil {
+		f.files = make(map[string]*ignoreFile)
+	}
+	f.files[path] = loaded
+	f.reorder()
+	return nil
+}
+
+// unload forgets the rules of the ignore file at path.
+func (f *ignoreFiles) unload(path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.files[path]; ok {
+		delete(f.files, path)
+		f.reorder()
+	}
+}
+
+// loaded reports whether the ignore file at path is loaded.
+func (f *ignoreFiles) loaded(path string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	_, ok := f.files[path]
+	return ok
+}
+
+func (f *ignoreFiles) reorder() {
+	f.ordered = f.ordered[:0]
+	for _, file := range f.files {
+		f.ordered = append(f.ordered, file)
+	}
+	sort.Slice(f.ordered, func(i, j int) bool {
+		di := strings.Count(f.ordered[i].base, string(filepath.Separator))
+		dj := strings.Count(f.ordered[j].base, string(filepath.Separator))
+		if di != dj {
+			return di < dj
+		}
+		return f.ordered[i].path < f.ordered[j].path
+	})
+}
+
+// match returns the rule deciding that path is ignored. Like git, a path below an excluded directory is ignored with
+// it, "!" lines can't re-include it, unless the directory is only excluded by a .dockerignore file.
+func (f *ignoreFiles) match(path string) (string, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.ordered) == 0 {
+		return "", false
+	}
+
+	// the directories above path, from the shallowest
+	var dirs []string
+	for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if rule, ok := f.decide(dirs[i], func() bool { return true }, false); ok {
+			return rule, true
+		}
+	}
+
+	isDir := func() bool {
+		return f.isDir != nil && f.isDir(path)
+	}
+	return f.decide(path, isDir, true)
+}
+
+// decide returns the rule of the ignore files deciding that path is ignored, isDir telling whether it is a directory.
+// The .dockerignore files are left out unless docker is set.
+func (f *ignoreFiles) decide(path string, isDir func() bool, docker bool) (string, bool) {
+	var decided *ignoreRule
+	for _, file := range f.ordered {
+		if file.docker && !docker {
+			continue
+		}
+		rel, err := filepath.Rel(file.base, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if dir, ok := inVCSDir(rel); ok {
+			return filepath.Join(file.base, dir) + " (version control directory)", true
+		}
+		for i := range file.rules {
+			rule := &file.rules[i]
+			if rule.re.MatchString(rel) && (!rule.dirOnly || isDir()) {
+				decided = rule
+			}
+		}
+	}
+
+	if decided == nil || decided.negate {
+		return "", false
+	}
+	return fmt.Sprintf("%s:%d %s", decided.source, decided.line, decided.pattern), true
+}
+
+// parseIgnoreLine parses a line of an ignore file with .gitignore semantics, or .dockerignore semantics when docker is
+// set: the patterns are relative to the build context, as if they all started with a slash, a trailing slash is
+// dropped and they also match the paths below the ones they match. ok is false for blank lines and comments.
+func parseIgnoreLine(line string, docker bool) (ignoreRule, bool, error) {
+	rule := ignoreRule{}
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false, nil
+	}
+	rule.pattern = line
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		line = strings.TrimSuffix(line, "/")
+		rule.dirOnly = !docker
+	}
+	anchored := docker || strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return rule, false, nil
+	}
+
+	body := globToRegexp("/" + line)
+	// globToRegexp anchors on "^" and ends with "$", rework both ends for relative paths
+	body = strings.TrimSuffix(strings.TrimPrefix(body, "^"+regexp.QuoteMeta("/")), "$")
+	prefix := "^"
+	if !anchored {
+		prefix = "^(.*/)?"
+	}
+	suffix := "$"
+	if docker {
+		suffix = "(/.*)?$"
+	}
+
+	re, err := regexp.Compile(prefix + body + suffix)
+	if err != nil {
+		return rule, false, err
+	}
+	rule.re = re
+	return rule, true, nil
+}
+
+// LoadIgnoreFile imports the rules of an ignore file with .gitignore semantics, or .dockerignore semantics when the
+// file is named so. The rules apply to the paths below the directory of the file.
+//
+// Unlike WithIgnoreFiles, it loads a .dockerignore file of any directory.
+func (w *FileWatcher) LoadIgnoreFile(path string) error {
+	return w.ignoreFiles.load(w.fs, w.log, w.normalize(path))
+}
+
+// importIgnoreFiles loads the ignore files found below dir when the IgnoreFiles option is set. A .dockerignore file is
+// only loaded at the root of a build context, see contextRoot.
+func (w *FileWatcher) importIgnoreFiles(dir string) {
+	if len(w.options.IgnoreFiles) == 0 {
+		return
+	}
+
+	names := make(map[string]bool, len(w.options.IgnoreFiles))
+	for _, name := range w.options.IgnoreFiles {
+		names[name] = true
+	}
+
+	err := afero.Walk(w.fs, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && vcsDirs[info.Name()] {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && names[info.Name()] {
+			if info.Name() == ".dockerignore" && (filepath.Dir(path) != dir || !w.contextRoot(dir)) {
+				return nil
+			}
+			if err := w.ignoreFiles.load(w.fs, w.log, path); err != nil {
+				w.log.Warn("Unable to load ignore file ", path, ": ", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		w.log.Warn("Unable to look for ignore files in ", dir, ": ", err)
+	}
+}
+
+// contextRoot reports whether the directory dir is the root of a build context, whose .dockerignore file applies: a
+// directory added to the watcher, not one watched because it is below a recursively watched directory. Docker only
+// reads the .dockerignore file at the root of the context.
+func (w *FileWatcher) contextRoot(dir string) bool {
+	w.recursive.mu.RLock()
+	defer w.recursive.mu.RUnlock()
+	for root := range w.recursive.roots {
+		if isBelow(dir, root) {
+			return false
+		}
+	}
+	return true
+}
+
+// knownDir reports whether path is a directory, from the listings when they know it so the paths of removed
+// directories are told apart too, or from the filesystem.
+func (w *FileWatcher) knownDir(path string) bool {
+	if w.listings.has(path) {
+		return true
+	}
+	if entry, ok := w.listings.lookup(path); ok {
+		return entry.isDir
+	}
+	info, err := w.fs.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// isIgnoreFile reports whether path is named like one of the imported ignore files.
+func (w *FileWatcher) isIgnoreFile(path string) bool {
+	name := filepath.Base(path)
+	for _, ignoreFile := range w.options.IgnoreFiles {
+		if name == ignoreFile {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadIgnoreFile reloads the rules of the ignore file at path after it changed, or forgets them once it is gone, so
+// the new rules apply to the next events.
+func (w *FileWatcher) reloadIgnoreFile(path string) {
+	if _, vcs := inVCSDir(filepath.ToSlash(path)); vcs || !w.isIgnoreFile(path) {
+		return
+	}
+	if filepath.Base(path) == ".dockerignore" && !w.contextRoot(filepath.Dir(path)) {
+		return
+	}
+	info, err := w.fs.Stat(path)
+	if err != nil || info.IsDir() {
+		if !w.ignoreFiles.loaded(path) {
+			return
+		}
+		w.trace(path, "ignore file removed, forgetting its rules")
+		w.ignoreFiles.unload(path)
+	} else {
+		w.trace(path, "ignore file changed, reloading its rules")
+		if err := w.ignoreFiles.load(w.fs, w.log, path); err != nil {
+			w.log.Warn("Unable to reload ignore file ", path, ": ", err)
+			return
+		}
+	}
+	w.rewatchIgnored(filepath.Dir(path))
+}
+
+// rewatchIgnored brings the watches of the recursively watched tree at dir in line with the ignore files after they
+// changed: the directories they now exclude stop being watched and the ones they stopped excluding are watched.
+func (w *FileWatcher) rewatchIgnored(dir string) {
+	if !w.recursiveRoot(dir) {
+		return
+	}
+	for _, path := range w.WatchedMap.Keys() {
+		if isBelow(path, dir) && w.excludedDir(path) {
+			w.trace(path, "excluded by the ignore files, no longer watched")
+			if err := w.Remove(path); err != nil {
+				w.log.Debug("Unable to remove the watch of ", path, ": ", err)
+			}
+		}
+	}
+	if err := w.addTree(dir); err != nil {
+		w.log.Warn("Unable to watch ", dir, ": ", err)
+	}
+}
+
+// inVCSDir returns the version control directory rel, a slash separated path, is in.
+func inVCSDir(rel string) (string, bool) {
+	parts := strings.Split(rel, "/")
+	for i, part := range parts {
+		if vcsDirs[part] {
+			return strings.Join(parts[:i+1], "/"), true
+		}
+	}
+	return "", false
+}
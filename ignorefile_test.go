@@ -0,0 +1,145 @@
+package fileWatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		docker  bool
+		ok      bool
+		negate  bool
+		dirOnly bool
+		matches []string
+		misses  []string
+	}{
+		{"blank", "  ", false, false, false, false, nil, nil},
+		{"comment", "# build", false, false, false, false, nil, nil},
+		{"name", "*.log", false, true, false, false, []string{"a.log", "dir/a.log"}, []string{"a.log/x", "a.txt"}},
+		{"trailing spaces", "a.txt  ", false, true, false, false, []string{"a.txt", "dir/a.txt"}, []string{"a.txt  "}},
+		{"directory", "build/", false, true, false, true, []string{"build", "dir/build"}, []string{"build/out.o"}},
+		{"anchored", "/root.txt", false, true, false, false, []string{"root.txt"}, []string{"dir/root.txt"}},
+		{"path", "dir/a", false, true, false, false, []string{"dir/a"}, []string{"x/dir/a", "dir/a/b"}},
+		{"negation", "!keep.log", false, true, true, false, []string{"keep.log", "dir/keep.log"}, nil},
+		{"escaped", `\#file`, false, true, false, false, []string{"#file"}, []string{`\#file`}},
+		{"content", "dir/**", false, true, false, false, []string{"dir/a", "dir/a/b"}, []string{"dir"}},
+		{"any directory", "**/dir", false, true, false, false, []string{"dir", "a/b/dir"}, []string{"dir/a"}},
+		{"docker name", "*.md", true, true, false, false, []string{"a.md", "a.md/b"}, []string{"docs/a.md"}},
+		{"docker directory", "build/", true, true, false, false, []string{"build", "build/out.o"}, []string{"a/build"}},
+	}
+	for _, test := range tests {
+		rule, ok, err := parseIgnoreLine(test.line, test.docker)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if ok != test.ok || rule.negate != test.negate || rule.dirOnly != test.dirOnly {
+			t.Errorf("%s: ok %v, negate %v, dirOnly %v, want %v, %v, %v", test.name, ok, rule.negate, rule.dirOnly,
+				test.ok, test.negate, test.dirOnly)
+			continue
+		}
+		for _, path := range test.matches {
+			if !rule.re.MatchString(path) {
+				t.Errorf("%s: %q does not match %s", test.name, test.line, path)
+			}
+		}
+		for _, path := range test.misses {
+			if rule.re.MatchString(path) {
+				t.Errorf("%s: %q matches %s", test.name, test.line, path)
+			}
+		}
+	}
+}
+
+func TestIgnoreFilesMatch(t *testing.T) {
+	base := filepath.Join(string(filepath.Separator), "ctx")
+	dirs := map[string]bool{"build": true, "logs": true, "dir": true, "dir/build": true}
+	tests := []struct {
+		name    string
+		file    string
+		lines   []string
+		path    string
+		ignored bool
+	}{
+		{"directory", ".gitignore", []string{"build/"}, "build", true},
+		{"file named like a directory", ".gitignore", []string{"build/"}, "src/build", false},
+		{"below a directory", ".gitignore", []string{"build/"}, "build/out.o", true},
+		{"nested directory", ".gitignore", []string{"build/"}, "dir/build/out.o", true},
+		{"negation", ".gitignore", []string{"*.log", "!keep.log"}, "keep.log", false},
+		{"later lines win", ".gitignore", []string{"!keep.log", "*.log"}, "keep.log", true},
+		{"negation below an excluded directory", ".gitignore", []string{"logs", "!logs/keep.log"}, "logs/keep.log",
+			true},
+		{"negation below an excluded directory only", ".gitignore", []string{"logs/", "!keep.log"}, "logs/keep.log",
+			true},
+		{"negation of the content", ".gitignore", []string{"logs/*", "!logs/keep.log"}, "logs/keep.log", false},
+		{"content", ".gitignore", []string{"logs/*", "!logs/keep.log"}, "logs/other.log", true},
+		{"version control directory", ".gitignore", []string{"!.git"}, ".git/config", true},
+		{"docker", ".dockerignore", []string{"*.md"}, "README.md", true},
+		{"docker anchored", ".dockerignore", []string{"*.md"}, "docs/README.md", false},
+		{"docker negation below an excluded directory", ".dockerignore", []string{"logs", "!logs/keep.log"},
+			"logs/keep.log", false},
+	}
+	for _, test := range tests {
+		file := &ignoreFile{path: filepath.Join(base, test.file), base: base, docker: test.file == ".dockerignore"}
+		for i, line := range test.lines {
+			rule, ok, err := parseIgnoreLine(line, file.docker)
+			if err != nil || !ok {
+				t.Fatalf("%s: invalid line %q: %v", test.name, line, err)
+			}
+			rule.source, rule.line = file.path, i+1
+			file.rules = append(file.rules, rule)
+		}
+		f := ignoreFiles{
+			files:   map[string]*ignoreFile{file.path: file},
+			ordered: []*ignoreFile{file},
+			isDir: func(path string) bool {
+				rel, err := filepath.Rel(base, path)
+				return err == nil && dirs[filepath.ToSlash(rel)]
+			},
+		}
+		if _, ignored := f.match(filepath.Join(base, filepath.FromSlash(test.path))); ignored != test.ignored {
+			t.Errorf("%s: %s ignored %v, want %v", test.name, test.path, ignored, test.ignored)
+		}
+	}
+}
+
+func TestDockerIgnoreAtTheContextRoot(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, ".dockerignore"), "*.tmp\n")
+	writeFile(t, filepath.Join(sub, ".dockerignore"), "*.txt\n")
+	writeFile(t, filepath.Join(sub, ".gitignore"), "*.bak\n")
+
+	w := newTestWatcher(t, WithIgnoreFiles())
+	if err := w.AddRecursive(root); err != nil {
+		t.Fatal(err)
+	}
+	for path, ignored := range map[string]bool{
+		filepath.Join(root, "a.tmp"):  true,
+		filepath.Join(sub, "a.tmp"):   false,
+		filepath.Join(sub, "b.txt"):   false,
+		filepath.Join(sub, "c.bak"):   true,
+		filepath.Join(root, "c.bak"):  false,
+		filepath.Join(root, "d.txt"):  false,
+		filepath.Join(sub, "e.other"): false,
+	} {
+		if _, ok := w.ignoreFiles.match(path); ok != ignored {
+			t.Errorf("%s ignored %v, want %v", path, ok, ignored)
+		}
+	}
+
+	// a .dockerignore file changing below the root is not loaded either
+	nested := filepath.Join(sub, ".dockerignore")
+	writeFile(t, nested, "*.other\n")
+	w.reloadIgnoreFile(nested)
+	if w.ignoreFiles.loaded(nested) {
+		t.Fatal("the .dockerignore file below the context root was loaded")
+	}
+}
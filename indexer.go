@@ -0,0 +1,215 @@
+package fileWatcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/spf13/afero"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Document describes a file handed to an Indexer.
+type Document struct {
+	Path    string
+	Hash    string
+	ModTime time.Time
+	Size    int64
+}
+
+// Indexer is the contract an incremental search index has to fulfil to be kept up to date by an IndexFeeder.
+type Indexer interface {
+	Add(doc Document) error
+	Update(doc Document) error
+	Delete(path string) error
+	// Move is called when a file was renamed, so the index can move the document instead of re-indexing it.
+	Move(oldPath string, doc Document) error
+}
+
+// FolderIndexer can optionally be implemented by an Indexer that is able to delete every document below a folder.
+// Without it, the documents of a deleted folder are left in the index because their paths are no longer known.
+type FolderIndexer interface {
+	DeleteFolder(path string) error
+}
+
+// Flusher can optionally be implemented by an Indexer that buffers operations. Flush is called after every group of
+// events has been applied.
+type Flusher interface {
+	Flush() error
+}
+
+// IndexFeeder turns watcher events into add, update, delete and move operations on an Indexer. Events are coalesced
+// to their net effect before being applied, so a file created and removed within the window never reaches the index.
+type IndexFeeder struct {
+	Indexer Indexer
+	// Window is how long events are collected before their net effect is applied. It defaults to one second.
+	Window time.Duration
+
+	fs  afero.Fs
+	log Logger
+}
+
+// NewIndexFeeder creates an IndexFeeder for indexer, applying changes every window. It reads the files on newFs, the
+// filesystem of the watcher whose events it is fed, and logs to l. They default to the ones set with SetFs and
+// SetLogger when they are nil.
+func NewIndexFeeder(newFs afero.Fs, l Logger, indexer Indexer, window time.Duration) *IndexFeeder {
+	return &IndexFeeder{Indexer: indexer, Window: window, fs: newFs, log: l}
+}
+
+// filesystem returns the filesystem of the feeder, the one set with SetFs for a feeder created without one.
+func (f *IndexFeeder) filesystem() afero.Fs {
+	if f.fs == nil {
+		return fs
+	}
+	return f.fs
+}
+
+// logger returns the logger of the feeder, the one set with SetLogger for a feeder created without one.
+func (f *IndexFeeder) logger() Logger {
+	if f.log == nil {
+		return log
+	}
+	return f.log
+}
+
+// Run feeds the events received on events to the indexer until done is signaled or events is closed. Errors returned
+// by the indexer are sent on errs when it is not nil.
+func (f *IndexFeeder) Run(done chan bool, events <-chan FileWatcherEvent, errs chan<- error) {
+	var pending []FileWatcherEvent
+	window := f.Window
+	if window <= 0 {
+		window = time.Second
+	}
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if err := f.Apply(pending); err != nil && errs != nil {
+			errs <- err
+		}
+		pending = nil
+	}
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				flush()
+				return
+			}
+			pending = append(pending, e)
+		case <-ticker.C:
+			flush()
+		case <-done:
+			flush()
+			return
+		}
+	}
+}
+
+// Apply applies the net effect of events to the indexer. It keeps going when an operation fails and returns the
+// first error encountered.
+func (f *IndexFeeder) Apply(events []FileWatcherEvent) error {
+	var firstErr error
+	keep := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, e := range netEffect(events) {
+		switch e.Event {
+		case EventCreateFile, EventMovedInFile:
+			keep(f.add(e.Path))
+		case EventEditFile:
+			doc, err := describeFile(f.filesystem(), e.Path)
+			if err == nil {
+				err = f.Indexer.Update(doc)
+			}
+			keep(err)
+		case EventDeleteFile, EventMovedOutFile:
+			keep(f.Indexer.Delete(e.Path))
+		case EventRenameFile:
+			doc, err := describeFile(f.filesystem(), e.Path)
+			if err == nil {
+				err = f.Indexer.Move(e.PreviousPath, doc)
+			}
+			keep(err)
+		case EventCreateFolder, EventMovedInFolder:
+			keep(f.walk(e.Path, func(path string) error {
+				return f.add(path)
+			}))
+		case EventRenameFolder:
+			keep(f.walk(e.Path, func(path string) error {
+				doc, err := describeFile(f.filesystem(), path)
+				if err != nil {
+					return err
+				}
+				old := filepath.Join(e.PreviousPath, strings.TrimPrefix(path, e.Path))
+				return f.Indexer.Move(old, doc)
+			}))
+		case EventDeleteFolder, EventMovedOutFolder:
+			if fi, ok := f.Indexer.(FolderIndexer); ok {
+				keep(fi.DeleteFolder(e.Path))
+			} else {
+				f.logger().Debug("Indexer can not delete folders, documents below " + e.Path + " are left in the index")
+			}
+		}
+	}
+
+	if fl, ok := f.Indexer.(Flusher); ok {
+		keep(fl.Flush())
+	}
+	return firstErr
+}
+
+func (f *IndexFeeder) add(path string) error {
+	doc, err := describeFile(f.filesystem(), path)
+	if err != nil {
+		return err
+	}
+	return f.Indexer.Add(doc)
+}
+
+func (f *IndexFeeder) walk(root string, fn func(path string) error) error {
+	return afero.Walk(f.filesystem(), root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		return fn(path)
+	})
+}
+
+// describeFile stats and hashes path on fsys.
+func describeFile(fsys afero.Fs, path string) (Document, error) {
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return Document{}, err
+	}
+
+	file, err := fsys.Open(path)
+	if err != nil {
+		return Document{}, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, throttled(file)); err != nil {
+		return Document{}, err
+	}
+
+	return Document{
+		Path:    path,
+		Hash:    hex.EncodeToString(h.Sum(nil)),
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+	}, nil
+}
@@ -0,0 +1,85 @@
+package fileWatcher
+
+import (
+	"github.com/spf13/afero"
+	"time"
+)
+
+// BleveIndex is the subset of bleve.Index used by BleveIndexer. A bleve index satisfies it as is, which keeps this
+// package free of a dependency on bleve.
+type BleveIndex interface {
+	Index(id string, data interface{}) error
+	Delete(id string) error
+}
+
+// BleveDocument is the value stored in the bleve index for every file. The document id is the file path.
+type BleveDocument struct {
+	Path    string    `json:"path"`
+	Hash    string    `json:"hash"`
+	ModTime time.Time `json:"mtime"`
+	Size    int64     `json:"size"`
+	Content string    `json:"content,omitempty"`
+}
+
+// BleveIndexer is an Indexer storing documents in an embedded bleve index.
+type BleveIndexer struct {
+	Index BleveIndex
+	// MaxContentSize is the largest file, in bytes, whose content is stored in the document so it can be searched.
+	// Zero disables content indexing.
+	MaxContentSize int64
+
+	fs  afero.Fs
+	log Logger
+}
+
+// NewBleveIndexer creates a BleveIndexer storing the content of files up to maxContentSize bytes, read on newFs, the
+// filesystem of the watcher and of the IndexFeeder, and logging to l. They default to the ones set with SetFs and
+// SetLogger when they are nil.
+func NewBleveIndexer(newFs afero.Fs, l Logger, index BleveIndex, maxContentSize int64) *BleveIndexer {
+	return &BleveIndexer{Index: index, MaxContentSize: maxContentSize, fs: newFs, log: l}
+}
+
+func (b *BleveIndexer) Add(doc Document) error {
+	return b.Index.Index(doc.Path, b.document(doc))
+}
+
+func (b *BleveIndexer) Update(doc Document) error {
+	return b.Index.Index(doc.Path, b.document(doc))
+}
+
+func (b *BleveIndexer) Delete(path string) error {
+	return b.Index.Delete(path)
+}
+
+func (b *BleveIndexer) Move(oldPath string, doc Document) error {
+	if err := b.Index.Delete(oldPath); err != nil {
+		return err
+	}
+	return b.Index.Index(doc.Path, b.document(doc))
+}
+
+func (b *BleveIndexer) document(doc Document) BleveDocument {
+	res := BleveDocument{
+		Path:    doc.Path,
+		Hash:    doc.Hash,
+		ModTime: doc.ModTime,
+		Size:    doc.Size,
+	}
+
+	if b.MaxContentSize > 0 && doc.Size <= b.MaxContentSize {
+		fsys, l := b.fs, b.log
+		if fsys == nil {
+			fsys = fs
+		}
+		if l == nil {
+			l = log
+		}
+		content, err := afero.ReadFile(fsys, doc.Path)
+		if err != nil {
+			l.Warn("Unable to read content of ", doc.Path, ": ", err)
+		} else {
+			res.Content = string(content)
+		}
+	}
+	return res
+}
@@ -0,0 +1,192 @@
+package fileWatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ElasticsearchIndexer is an Indexer sending documents to Elasticsearch through the bulk API. Operations are buffered
+// and sent when BatchSize is reached or Flush is called. The document id is the file path, and the "path" field is
+// expected to be mapped as a keyword so DeleteFolder can match on its prefix.
+type ElasticsearchIndexer struct {
+	// URL is the base url of the cluster, for example http://localhost:9200.
+	URL   string
+	Index string
+	// BatchSize is the number of operations buffered before they are sent. Zero sends every 500 operations.
+	BatchSize int
+	Username  string
+	Password  string
+	Client    *http.Client
+
+	mu      sync.Mutex
+	pending bytes.Buffer
+	count   int
+}
+
+// NewElasticsearchIndexer creates an ElasticsearchIndexer writing to index on the cluster at url.
+func NewElasticsearchIndexer(url string, index string) *ElasticsearchIndexer {
+	return &ElasticsearchIndexer{
+		URL:    strings.TrimSuffix(url, "/"),
+		Index:  index,
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type elasticsearchDocument struct {
+	Path    string    `json:"path"`
+	Hash    string    `json:"hash"`
+	ModTime time.Time `json:"mtime"`
+	Size    int64     `json:"size"`
+}
+
+func (es *ElasticsearchIndexer) Add(doc Document) error {
+	return es.queue("index", doc.Path, &doc)
+}
+
+func (es *ElasticsearchIndexer) Update(doc Document) error {
+	return es.queue("index", doc.Path, &doc)
+}
+
+func (es *ElasticsearchIndexer) Delete(path string) error {
+	return es.queue("delete", path, nil)
+}
+
+func (es *ElasticsearchIndexer) Move(oldPath string, doc Document) error {
+	if err := es.queue("delete", oldPath, nil); err != nil {
+		return err
+	}
+	return es.queue("index", doc.Path, &doc)
+}
+
+// DeleteFolder removes every document below path with a delete by query request. Buffered operations are flushed
+// first so they can't recreate documents afterwards.
+func (es *ElasticsearchIndexer) DeleteFolder(path string) error {
+	if err := es.Flush(); err != nil {
+		return err
+	}
+
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"prefix": map[string]interface{}{
+				"path": strings.TrimSuffix(path, "/") + "/",
+			},
+		},
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+
+	_, err = es.post("/"+es.Index+"/_delete_by_query", "application/json", body)
+	return err
+}
+
+// Flush sends the buffered operations.
+func (es *ElasticsearchIndexer) Flush() error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	return es.flushLocked()
+}
+
+func (es *ElasticsearchIndexer) queue(action string, id string, doc *Document) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	meta := map[string]map[string]string{
+		action: {"_index": es.Index, "_id": id},
+	}
+	line, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	es.pending.Write(line)
+	es.pending.WriteByte('\n')
+
+	if doc != nil {
+		line, err = json.Marshal(elasticsearchDocument{
+			Path:    doc.Path,
+			Hash:    doc.Hash,
+			ModTime: doc.ModTime,
+			Size:    doc.Size,
+		})
+		if err != nil {
+			return err
+		}
+		es.pending.Write(line)
+		es.pending.WriteByte('\n')
+	}
+	es.count++
+
+	batchSize := es.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	if es.count >= batchSize {
+		return es.flushLocked()
+	}
+	return nil
+}
+
+func (es *ElasticsearchIndexer) flushLocked() error {
+	if es.count == 0 {
+		return nil
+	}
+
+	body := make([]byte, es.pending.Len())
+	copy(body, es.pending.Bytes())
+	es.pending.Reset()
+	es.count = 0
+
+	res, err := es.post("/_bulk", "application/x-ndjson", body)
+	if err != nil {
+		return err
+	}
+
+	bulk := struct {
+		Errors bool `json:"errors"`
+	}{}
+	if err := json.Unmarshal(res, &bulk); err != nil {
+		return err
+	}
+	if bulk.Errors {
+		return errors.New("elasticsearch bulk request reported errors: " + string(res))
+	}
+	return nil
+}
+
+func (es *ElasticsearchIndexer) post(path string, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, es.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if es.Username != "" {
+		req.SetBasicAuth(es.Username, es.Password)
+	}
+
+	client := es.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	res, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("elasticsearch request %s failed with status %d: %s", path, resp.StatusCode, res)
+	}
+	return res, nil
+}
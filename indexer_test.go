@@ -0,0 +1,73 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// memoryBleveIndex is a BleveIndex keeping the documents in memory.
+type memoryBleveIndex struct {
+	docs map[string]BleveDocument
+}
+
+func (m *memoryBleveIndex) Index(id string, data interface{}) error {
+	m.docs[id] = data.(BleveDocument)
+	return nil
+}
+
+func (m *memoryBleveIndex) Delete(id string) error {
+	delete(m.docs, id)
+	return nil
+}
+
+// warnLogger records the warnings.
+type warnLogger struct {
+	nopLogger
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (l *warnLogger) Warn(args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, fmt.Sprint(args...))
+}
+
+func TestIndexerOnTheFilesystemOfTheWatcher(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	l := &warnLogger{}
+	// paths no test could write on the filesystem of the host
+	dir := filepath.Join(string(filepath.Separator), "indexer-"+t.Name())
+	file := filepath.Join(dir, "file")
+	if err := afero.WriteFile(fs, file, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	index := &memoryBleveIndex{docs: make(map[string]BleveDocument)}
+	feeder := NewIndexFeeder(fs, l, NewBleveIndexer(fs, l, index, 1024), time.Hour)
+	if err := feeder.Apply([]FileWatcherEvent{{Path: file, Event: EventCreateFile}}); err != nil {
+		t.Fatal(err)
+	}
+	if doc, ok := index.docs[file]; !ok || doc.Content != "content" || doc.Size != int64(len("content")) {
+		t.Fatalf("indexed %+v, want the file of the filesystem of the watcher", index.docs)
+	}
+
+	// a file gone once described is indexed without its content
+	missing := filepath.Join(dir, "missing")
+	if err := feeder.Indexer.Add(Document{Path: missing, Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if doc := index.docs[missing]; doc.Content != "" {
+		t.Fatalf("indexed the content %q of a missing file", doc.Content)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.warnings) != 1 {
+		t.Fatalf("warnings %q, want the one of the missing file on the logger of the indexer", l.warnings)
+	}
+}
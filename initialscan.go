@@ -0,0 +1,67 @@
+package fileWatcher
+
+import (
+	"github.com/spf13/afero"
+	"path/filepath"
+)
+
+// AddWithInitialScan adds path like Add and, when it was not watched yet, emits a CREATE_FILE or CREATE_FOLDER event
+// for every entry already present: the entries of a directory, or the file itself. Consumers can build their state
+// from the event stream instead of walking the directory themselves, which races with the live events. The watch is
+// set up before the scan, so nothing is missed, but an entry created meanwhile may be reported twice.
+func (w *FileWatcher) AddWithInitialScan(path string) error {
+	path = w.normalize(path)
+	if w.Contains(path) {
+		return nil
+	}
+	if err := w.Add(path); err != nil {
+		return err
+	}
+	if !w.options.EmitExisting {
+		w.scanExisting(path)
+	}
+	return nil
+}
+
+// scanExisting emits the events of the entries present in the newly watched path from a goroutine, so Add does not
+// block on consumers.
+func (w *FileWatcher) scanExisting(path string) {
+	info, err := w.fs.Stat(path)
+	if err != nil {
+		return
+	}
+	if !info.IsDir() {
+		e := FileWatcherEvent{Path: path}
+		e.Event = EventCreateFile
+		go w.emitExisting([]FileWatcherEvent{e})
+		return
+	}
+
+	entries, err := afero.ReadDir(w.fs, path)
+	if err != nil {
+		w.log.Warn("Unable to scan ", path, ": ", err)
+		return
+	}
+	events := make([]FileWatcherEvent, 0, len(entries))
+	for _, entry := range entries {
+		e := FileWatcherEvent{Path: filepath.Join(path, entry.Name())}
+		if entry.IsDir() {
+			e.Event = EventCreateFolder
+		} else {
+			e.Event = EventCreateFile
+		}
+		if isSentinel(e.Path) || w.ignored(e.Path) {
+			continue
+		}
+		events = append(events, e)
+	}
+	go w.emitExisting(events)
+}
+
+func (w *FileWatcher) emitExisting(events []FileWatcherEvent) {
+	w.labelGoroutine("initial-scan")
+	for _, e := range events {
+		w.traceEvent(e, "emitted by the initial scan")
+		w.emit(e)
+	}
+}
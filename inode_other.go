@@ -0,0 +1,19 @@
+//go:build windows || plan9 || js || wasip1
+
+package fileWatcher
+
+import "os"
+
+// inodeOf returns the inode number of the file described by info. It is only available for the files of a
+// SyntheticFs on this platform.
+func inodeOf(info os.FileInfo) (uint64, bool) {
+	if inode, ok := info.Sys().(syntheticInode); ok {
+		return uint64(inode), true
+	}
+	return 0, false
+}
+
+// ownerOf returns the user and group owning the file described by info. They are not available on this platform.
+func ownerOf(info os.FileInfo) (int, int, bool) {
+	return -1, -1, false
+}
@@ -0,0 +1,29 @@
+//go:build !windows && !plan9 && !js && !wasip1
+
+package fileWatcher
+
+import (
+	"os"
+	"syscall"
+)
+
+// inodeOf returns the inode number of the file described by info.
+func inodeOf(info os.FileInfo) (uint64, bool) {
+	if inode, ok := info.Sys().(syntheticInode); ok {
+		return uint64(inode), true
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Ino), true
+}
+
+// ownerOf returns the user and group owning the file described by info.
+func ownerOf(info os.FileInfo) (int, int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1, -1, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
@@ -0,0 +1,23 @@
+//go:build linux
+
+package fileWatcher
+
+import "github.com/fsnotify/fsnotify"
+
+// classifyExplicit classifies the changes inotify reports explicitly rather than as the pairs of notifications the
+// classification rules are built for, and reports whether it consumed event. A Write is a file rewritten in place, like
+// by touch, rsync or a build tool, which no rule pairs: it is the edit of the file, VerifyContentChange then drops the
+// ones leaving the content as it was. A Remove without Rename is an item unlinked, or a watched folder deleted whose
+// own watch reports it, which no rule pairs either: it is the delete of the file or folder, which AutoRearmInterval
+// needs to notice a deleted root.
+func (w *FileWatcher) classifyExplicit(event fsnotify.Event, pendingCreate string) bool {
+	switch {
+	case event.Op == fsnotify.Write:
+		w.classifyWrite(event.Name, pendingCreate)
+		return true
+	case event.Op == fsnotify.Remove && event.Name != pendingCreate:
+		w.classifyRemove(event.Name)
+		return true
+	}
+	return false
+}
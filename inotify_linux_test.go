@@ -0,0 +1,138 @@
+package fileWatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInPlaceWriteIsEdit(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "file")
+	writeFile(t, path, "first")
+	w := newTestWatcher(t)
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(" second"); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	e := waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event != EventChMod })
+	if e.Event != EventEditFile || e.Path != path {
+		t.Fatalf("got %s %s, want EDIT_FILE %s", e.Event, e.Path, path)
+	}
+	if errs := len(w.Errors); errs > 0 {
+		t.Fatalf("%d classification errors", errs)
+	}
+}
+
+func TestNewFileWriteIsOneCreate(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "file")
+	w := newTestWatcher(t)
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, "content")
+	e := waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event != EventChMod })
+	if e.Event != EventCreateFile || e.Path != path {
+		t.Fatalf("got %s %s, want CREATE_FILE %s", e.Event, e.Path, path)
+	}
+	writeFile(t, filepath.Join(root, "next"), "")
+	if e := waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event != EventChMod }); e.Path == path {
+		t.Fatalf("the write of the new file was reported as %s", e.Event)
+	}
+}
+
+func TestVerifyContentChangeDropsIdenticalRewrite(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "file")
+	writeFile(t, path, "xxxx")
+	w := newTestWatcher(t, WithContentVerification())
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+	// rewritten without truncating it first, which would be a change to an empty file
+	rewrite := func(content string) {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		_ = f.Close()
+	}
+
+	rewrite("same")
+	e := waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event != EventChMod })
+	if e.Event != EventEditFile || e.Path != path {
+		t.Fatalf("got %s %s, want EDIT_FILE %s", e.Event, e.Path, path)
+	}
+	rewrite("same")
+	writeFile(t, filepath.Join(root, "marker"), "")
+	e = waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event != EventChMod })
+	if filepath.Base(e.Path) != "marker" {
+		t.Fatalf("got %s %s for the identical rewrite", e.Event, e.Path)
+	}
+	rewrite("diff")
+	e = waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event != EventChMod })
+	if e.Event != EventEditFile || e.Path != path {
+		t.Fatalf("got %s %s, want EDIT_FILE %s", e.Event, e.Path, path)
+	}
+}
+
+func TestUnlinkIsDelete(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "file")
+	dir := filepath.Join(root, "dir")
+	writeFile(t, file, "")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	w := newTestWatcher(t)
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]EventKind{file: EventDeleteFile, dir: EventDeleteFolder} {
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+		e := waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event != EventChMod })
+		if e.Event != want || e.Path != path {
+			t.Fatalf("got %s %s, want %s %s", e.Event, e.Path, want, path)
+		}
+	}
+}
+
+func TestDeletedRootRearmed(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	w := newTestWatcher(t, WithAutoRearm(20*time.Millisecond))
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(root); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event == EventDeleteFolder && e.Path == root })
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event == EventWatchReestablished && e.Path == root })
+	if !w.Contains(root) {
+		t.Fatal("the recreated root is not watched")
+	}
+}
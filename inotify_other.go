@@ -0,0 +1,11 @@
+//go:build !linux
+
+package fileWatcher
+
+import "github.com/fsnotify/fsnotify"
+
+// classifyExplicit leaves every event to the platform and the generic classification, only inotify reports the
+// changes explicitly.
+func (w *FileWatcher) classifyExplicit(fsnotify.Event, string) bool {
+	return false
+}
@@ -0,0 +1,221 @@
+package fileWatcher
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceLevel is the severity of a TraceEntry.
+type TraceLevel int
+
+const (
+	// TraceLevelDebug is the level of the raw events and of the classification decisions.
+	TraceLevelDebug TraceLevel = iota
+	// TraceLevelInfo is the level of the emitted and coalesced events.
+	TraceLevelInfo
+	// TraceLevelWarn is the level of the dropped events and of the deliveries blocked by a slow consumer.
+	TraceLevelWarn
+)
+
+func (l TraceLevel) String() string {
+	switch l {
+	case TraceLevelDebug:
+		return "debug"
+	case TraceLevelInfo:
+		return "info"
+	case TraceLevelWarn:
+		return "warn"
+	}
+	return "unknown"
+}
+
+// TraceKind is what a TraceEntry records.
+type TraceKind int
+
+const (
+	// TraceRaw is a raw event received from the operating system, Op holds its operation.
+	TraceRaw TraceKind = iota
+	// TraceDecision is a step of the classification of a raw event, like a filter dropping it or the pairing of a
+	// rename, described by Message.
+	TraceDecision
+	// TraceEmitted is an event leaving the classification.
+	TraceEmitted
+	// TraceCoalesced is the folding of Count events of a debounce, coalescing or batching window into their net
+	// effect.
+	TraceCoalesced
+	// TraceDropped is Count events that never reached the consumers, Message says why.
+	TraceDropped
+	// TraceBlocked is a delivery that waited Duration for a consumer to receive it.
+	TraceBlocked
+)
+
+func (k TraceKind) String() string {
+	switch k {
+	case TraceRaw:
+		return "raw"
+	case TraceDecision:
+		return "decision"
+	case TraceEmitted:
+		return "emitted"
+	case TraceCoalesced:
+		return "coalesced"
+	case TraceDropped:
+		return "dropped"
+	case TraceBlocked:
+		return "blocked"
+	}
+	return "unknown"
+}
+
+// TraceEntry is a structured record of what the watcher did with an event, see the Tracer option.
+type TraceEntry struct {
+	Time  time.Time
+	Kind  TraceKind
+	Level TraceLevel
+	Path  string
+	// PreviousPath is the source of a rename.
+	PreviousPath string
+	// Op is the raw operation of a TraceRaw entry, like "CREATE" or "RENAME".
+	Op string
+	// Event is the type of the event, for the entries about a classified event, zero otherwise.
+	Event   EventKind
+	Message string
+	// Count is the number of events a TraceCoalesced or TraceDropped entry is about.
+	Count int
+	// Duration is how long a TraceBlocked delivery waited.
+	Duration time.Duration
+}
+
+// Tracer receives the structured records of the watcher, to find out why events go missing in production or to feed
+// them to a tracing system like OpenTelemetry, as span events or metrics. Trace is called synchronously on the event
+// path, so it must be quick and must not block.
+type Tracer interface {
+	Trace(entry TraceEntry)
+}
+
+// TracerFunc adapts a function to the Tracer interface.
+type TracerFunc func(entry TraceEntry)
+
+func (f TracerFunc) Trace(entry TraceEntry) {
+	f(entry)
+}
+
+// JSONTracer writes the entries of at least a level as JSON lines.
+type JSONTracer struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	level TraceLevel
+}
+
+// NewJSONTracer creates a JSONTracer writing the entries of level and above to w.
+func NewJSONTracer(w io.Writer, level TraceLevel) *JSONTracer {
+	return &JSONTracer{enc: json.NewEncoder(w), level: level}
+}
+
+func (t *JSONTracer) Trace(entry TraceEntry) {
+	if entry.Level < t.level {
+		return
+	}
+	record := struct {
+		Time         time.Time `json:"time"`
+		Level        string    `json:"level"`
+		Kind         string    `json:"kind"`
+		Path         string    `json:"path,omitempty"`
+		PreviousPath string    `json:"previousPath,omitempty"`
+		Op           string    `json:"op,omitempty"`
+		Event        string    `json:"event,omitempty"`
+		Message      string    `json:"message,omitempty"`
+		Count        int       `json:"count,omitempty"`
+		DurationMs   float64   `json:"durationMs,omitempty"`
+	}{
+		Time:         entry.Time,
+		Level:        entry.Level.String(),
+		Kind:         entry.Kind.String(),
+		Path:         entry.Path,
+		PreviousPath: entry.PreviousPath,
+		Op:           entry.Op,
+		Message:      entry.Message,
+		Count:        entry.Count,
+		DurationMs:   float64(entry.Duration) / float64(time.Millisecond),
+	}
+	if entry.Event != 0 {
+		record.Event = entry.Event.String()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_ = t.enc.Encode(record)
+}
+
+// instrument hands entry to the Tracer option, when set.
+func (w *FileWatcher) instrument(entry TraceEntry) {
+	tracer := w.options.Tracer
+	if tracer == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	tracer.Trace(entry)
+}
+
+// instrumentEvent records kind for the event e at level.
+func (w *FileWatcher) instrumentEvent(kind TraceKind, level TraceLevel, e FileWatcherEvent, message string) {
+	if w.options.Tracer == nil {
+		return
+	}
+	w.instrument(TraceEntry{
+		Kind:         kind,
+		Level:        level,
+		Path:         e.Path,
+		PreviousPath: e.PreviousPath,
+		Event:        e.Event,
+		Message:      message,
+		Count:        1,
+	})
+}
+
+// instrumentCoalesced records the folding of events into folded.
+func (w *FileWatcher) instrumentCoalesced(events int, folded int, why string) {
+	if w.options.Tracer == nil || events == folded {
+		return
+	}
+	w.instrument(TraceEntry{
+		Kind:    TraceCoalesced,
+		Level:   TraceLevelInfo,
+		Message: why,
+		Count:   events,
+	})
+}
+
+// countDropped counts the dropped event e and records why it was dropped.
+func (w *FileWatcher) countDropped(e FileWatcherEvent, why string) {
+	atomic.AddUint64(&w.counters.dropped, 1)
+	w.instrumentEvent(TraceDropped, TraceLevelWarn, e, why)
+}
+
+// countDroppedBatch counts the events of the dropped batch b and records why it was dropped.
+func (w *FileWatcher) countDroppedBatch(b *FileWatcherBatch, why string) {
+	atomic.AddUint64(&w.counters.dropped, uint64(len(b.Events)))
+	w.instrument(TraceEntry{Kind: TraceDropped, Level: TraceLevelWarn, Message: why, Count: len(b.Events)})
+}
+
+// instrumentBlocked records a delivery of e that waited since start for its consumer, when it did wait.
+func (w *FileWatcher) instrumentBlocked(e FileWatcherEvent, start time.Time, to string) {
+	if w.options.Tracer == nil {
+		return
+	}
+	w.instrument(TraceEntry{
+		Kind:         TraceBlocked,
+		Level:        TraceLevelWarn,
+		Path:         e.Path,
+		PreviousPath: e.PreviousPath,
+		Event:        e.Event,
+		Message:      "waited for the consumer of " + to,
+		Count:        1,
+		Duration:     time.Since(start),
+	})
+}
@@ -0,0 +1,255 @@
+// Package cli is the command line of filewatcher, shared by cmd/filewatcher and its former name cmd/fwatch.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/flightx31/fileWatcher"
+	"github.com/spf13/afero"
+	stdlog "log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stderrLogger implements fileWatcher.Logger on the standard error, which keeps the standard output free for the
+// protocol.
+type stderrLogger struct {
+	verbose bool
+}
+
+func (l stderrLogger) Panic(args ...interface{}) {
+	stdlog.Panic(args...)
+}
+
+func (l stderrLogger) Error(args ...interface{}) {
+	stdlog.Print(append([]interface{}{"ERROR "}, args...)...)
+}
+
+func (l stderrLogger) Warn(args ...interface{}) {
+	stdlog.Print(append([]interface{}{"WARN "}, args...)...)
+}
+
+func (l stderrLogger) Info(args ...interface{}) {
+	l.verbosePrint("INFO ", args)
+}
+
+func (l stderrLogger) Debug(args ...interface{}) {
+	l.verbosePrint("DEBUG ", args)
+}
+
+func (l stderrLogger) Trace(args ...interface{}) {
+	l.verbosePrint("TRACE ", args)
+}
+
+func (l stderrLogger) Print(args ...interface{}) {
+	stdlog.Print(args...)
+}
+
+func (l stderrLogger) verbosePrint(level string, args []interface{}) {
+	if l.verbose {
+		stdlog.Print(append([]interface{}{level}, args...)...)
+	}
+}
+
+// watchArg is a path or a glob pattern given as argument.
+type watchArg struct {
+	// dir is the path to watch, the folder above the first glob element of a pattern.
+	dir string
+	// matcher matches the paths whose events are printed.
+	matcher fileWatcher.Matcher
+	glob    bool
+}
+
+// parseArg splits arg into the path to watch and the paths it matches.
+func parseArg(arg string) (watchArg, error) {
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		return watchArg{}, err
+	}
+	if !strings.ContainsAny(arg, "*?[") {
+		return watchArg{dir: abs, matcher: below(abs)}, nil
+	}
+
+	m, err := fileWatcher.Glob(filepath.ToSlash(abs))
+	if err != nil {
+		return watchArg{}, fmt.Errorf("invalid pattern %s: %w", arg, err)
+	}
+	dir := abs
+	for strings.ContainsAny(dir, "*?[") {
+		dir = filepath.Dir(dir)
+	}
+	return watchArg{dir: dir, matcher: m, glob: true}, nil
+}
+
+// below matches path and everything below it.
+func below(path string) fileWatcher.Matcher {
+	prefix := strings.TrimSuffix(path, string(filepath.Separator)) + string(filepath.Separator)
+	return fileWatcher.MatcherFunc(func(p string) bool {
+		return p == path || strings.HasPrefix(p, prefix)
+	})
+}
+
+// matches reports whether m matches one of the paths of e.
+func matches(m fileWatcher.Matcher, e fileWatcher.FileWatcherEvent) bool {
+	return m.Match(e.Path) || e.PreviousPath != "" && m.Match(e.PreviousPath)
+}
+
+// runCommand runs command with the shell, with e in its environment.
+func runCommand(command string, e fileWatcher.FileWatcherEvent) error {
+	encoded, err := fileWatcher.EncodeEvent(e)
+	if err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"FILEWATCHER_EVENT="+e.Event.String(),
+		"FILEWATCHER_PATH="+e.Path,
+		"FILEWATCHER_PREVIOUS_PATH="+e.PreviousPath,
+		"FILEWATCHER_TIME="+e.Time.Format(time.RFC3339Nano),
+		"FILEWATCHER_SEQ="+strconv.FormatUint(e.Seq, 10),
+		"FILEWATCHER_JSON="+string(encoded),
+	)
+	return cmd.Run()
+}
+
+// errUsage is returned by run for invalid arguments, the usage was printed.
+var errUsage = errors.New("invalid arguments")
+
+// Main runs the command named name and exits.
+func Main(name string) {
+	stdlog.SetOutput(os.Stderr)
+	if err := run(name); errors.Is(err, errUsage) {
+		os.Exit(2)
+	} else if err != nil {
+		stdlog.Fatal(err)
+	}
+}
+
+// run runs the command until the watcher stops. It returns rather than exiting on errors, so the watchers are closed.
+func run(name string) error {
+	stdio := flag.Bool("stdio", false, "serve JSON-RPC 2.0 over the standard input and output")
+	verbose := flag.Bool("v", false, "log debug messages to the standard error")
+	format := flag.String("format", fileWatcher.DefaultEventTemplate, "Go template printing an event")
+	jsonLines := flag.Bool("json", false, "print the events in the wire schema, one per line")
+	command := flag.String("exec", "", "shell command run for every event, with the event in its environment")
+	debounce := flag.Duration("debounce", 0, "only handle the latest event of a path once it was quiet for this long")
+	recursive := flag.Bool("r", false, "watch the folders recursively")
+	shared := flag.String("shared", "", "watch through the watcher shared by the processes of the host on this socket")
+	flag.Parse()
+
+	if *stdio == (flag.NArg() > 0) || *jsonLines && *command != "" || *stdio && *shared != "" {
+		indent := strings.Repeat(" ", len(name))
+		fmt.Fprintln(os.Stderr, "usage: "+name+" -stdio [-v]")
+		fmt.Fprintln(os.Stderr, "       "+name+" [-format template | -json | -exec command] [-debounce d] [-r] [-v]")
+		fmt.Fprintln(os.Stderr, "       "+indent+" [-shared socket] path|glob...")
+		return errUsage
+	}
+	tmpl, err := fileWatcher.ParseEventTemplate(*format)
+	if err != nil {
+		return fmt.Errorf("invalid -format: %w", err)
+	}
+	args := make([]watchArg, 0, flag.NArg())
+	globs := false
+	for _, arg := range flag.Args() {
+		parsed, err := parseArg(arg)
+		if err != nil {
+			return err
+		}
+		args = append(args, parsed)
+		globs = globs || parsed.glob
+	}
+
+	var options []fileWatcher.Option
+	if *debounce > 0 {
+		options = append(options, fileWatcher.WithDebounce(*debounce))
+	}
+	var events <-chan fileWatcher.FileWatcherEvent
+	var errs <-chan error
+	var addPath, addTree func(path string) error
+	if *shared != "" {
+		// the options only apply when this process serves the shared watcher
+		s, err := fileWatcher.OpenShared(*shared, stderrLogger{verbose: *verbose}, options...)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		events, errs, addPath, addTree = s.Events, s.Errors, s.Add, s.AddRecursive
+	} else {
+		done := make(chan bool)
+		w, err := fileWatcher.Init(done, afero.NewOsFs(), stderrLogger{verbose: *verbose}, options...)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		if *stdio {
+			go func() {
+				for err := range w.Errors {
+					stdlog.Print("ERROR ", err)
+				}
+			}()
+			err := fileWatcher.ServeJSONRPC(w, os.Stdin, os.Stdout)
+			close(done)
+			return err
+		}
+		events, errs, addPath, addTree = w.Events, w.Errors, w.Add, w.AddRecursive
+	}
+
+	go func() {
+		for err := range errs {
+			stdlog.Print("ERROR ", err)
+		}
+	}()
+
+	matchers := make([]fileWatcher.Matcher, 0, len(args))
+	for _, arg := range args {
+		add := addPath
+		if *recursive || arg.glob {
+			add = addTree
+		}
+		if err := add(arg.dir); err != nil {
+			return err
+		}
+		matchers = append(matchers, arg.matcher)
+	}
+	// the events of the paths only watched as the folder of a pattern are left out
+	var match fileWatcher.Matcher
+	if globs {
+		match = fileWatcher.AnyOf(matchers...)
+	}
+
+	var out fileWatcher.Sink = fileWatcher.NewWriterSink(os.Stdout, tmpl)
+	for e := range events {
+		if match != nil && !matches(match, e) {
+			continue
+		}
+		switch {
+		case *command != "":
+			err = runCommand(*command, e)
+		case *jsonLines:
+			var line []byte
+			if line, err = fileWatcher.EncodeEvent(e); err == nil {
+				_, err = fmt.Fprintf(os.Stdout, "%s\n", line)
+			}
+		default:
+			err = out.Send(e)
+		}
+		if err != nil {
+			stdlog.Print("ERROR ", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/flightx31/fileWatcher"
+)
+
+func TestParseArg(t *testing.T) {
+	root := t.TempDir()
+
+	plain, err := parseArg(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.glob || plain.dir != root {
+		t.Fatalf("got %+v for a path", plain)
+	}
+	if !plain.matcher.Match(filepath.Join(root, "a", "b")) || plain.matcher.Match(root+"x") {
+		t.Fatal("a path argument does not match exactly what is below it")
+	}
+
+	pattern, err := parseArg(filepath.Join(root, "src", "**", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pattern.glob || pattern.dir != filepath.Join(root, "src") {
+		t.Fatalf("got %+v for a pattern", pattern)
+	}
+	moved := fileWatcher.FileWatcherEvent{Path: filepath.Join(root, "src", "a.txt"),
+		PreviousPath: filepath.Join(root, "src", "pkg", "a.go")}
+	if !matches(pattern.matcher, moved) {
+		t.Fatal("a rename from a matching path is not matched")
+	}
+	if matches(pattern.matcher, fileWatcher.FileWatcherEvent{Path: filepath.Join(root, "src", "a.txt")}) {
+		t.Fatal("a path not matching the pattern is matched")
+	}
+}
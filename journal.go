@@ -0,0 +1,306 @@
+package fileWatcher
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrJournalClosed is returned when appending to a closed Journal.
+var ErrJournalClosed = errors.New("journal is closed")
+
+const (
+	journalSegmentPrefix = "segment-"
+	journalSegmentSuffix = ".log"
+	journalSnapshot      = "snapshot.json"
+)
+
+// Journal persists events to a directory so they can be replayed after a restart. Events are appended to segment
+// files in the wire schema, one per line, and a new segment is started once the current one exceeds SegmentSize.
+type Journal struct {
+	Dir string
+	// SegmentSize is the size at which a new segment is started. It defaults to 4 MiB.
+	SegmentSize int64
+	// CompactInterval is how often Run compacts the closed segments into the snapshot. Zero disables compaction.
+	CompactInterval time.Duration
+	// Signer signs the events written to the journal, see SignEvent.
+	Signer *EventSigner
+	// Verifier checks the signatures of the events read back, a journal altered or written without the signing key
+	// fails to replay. Compacting such a journal needs the Signer as well, the snapshot is signed.
+	Verifier *EventVerifier
+
+	mu       sync.Mutex
+	segments []int
+	current  afero.File
+	size     int64
+	closed   bool
+}
+
+// OpenJournal opens the journal in dir, creating the directory when needed. Appends go to a new segment.
+func OpenJournal(dir string) (*Journal, error) {
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	j := &Journal{Dir: dir}
+	segments, err := j.listSegments()
+	if err != nil {
+		return nil, err
+	}
+	j.segments = segments
+	return j, nil
+}
+
+func (j *Journal) segmentPath(n int) string {
+	return filepath.Join(j.Dir, fmt.Sprintf("%s%016d%s", journalSegmentPrefix, n, journalSegmentSuffix))
+}
+
+// listSegments returns the numbers of the segments in the directory, oldest first.
+func (j *Journal) listSegments() ([]int, error) {
+	entries, err := afero.ReadDir(fs, j.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, journalSegmentPrefix) || !strings.HasSuffix(name, journalSegmentSuffix) {
+			continue
+		}
+		n := 0
+		if _, err := fmt.Sscanf(strings.TrimPrefix(name, journalSegmentPrefix), "%d", &n); err == nil {
+			segments = append(segments, n)
+		}
+	}
+	sort.Ints(segments)
+	return segments, nil
+}
+
+// Append writes e to the current segment.
+func (j *Journal) Append(e FileWatcherEvent) error {
+	line, err := j.encode(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return ErrJournalClosed
+	}
+
+	limit := j.SegmentSize
+	if limit <= 0 {
+		limit = 4 << 20
+	}
+	if j.current == nil || j.size+int64(len(line)) > limit && j.size > 0 {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := j.current.Write(line)
+	j.size += int64(n)
+	return err
+}
+
+// encode serializes e in the wire schema, signed when the journal has a Signer.
+func (j *Journal) encode(e FileWatcherEvent) ([]byte, error) {
+	if j.Signer != nil {
+		return SignEvent(e, j.Signer)
+	}
+	return EncodeEvent(e)
+}
+
+// decode deserializes an event, checking its signature when the journal has a Verifier.
+func (j *Journal) decode(data []byte) (FileWatcherEvent, error) {
+	if j.Verifier != nil {
+		return VerifyEvent(data, j.Verifier)
+	}
+	return DecodeEvent(data)
+}
+
+// rotate starts a new segment. The caller holds the lock.
+func (j *Journal) rotate() error {
+	if j.current != nil {
+		if err := j.current.Close(); err != nil {
+			return err
+		}
+		j.current = nil
+	}
+
+	next := 1
+	if len(j.segments) > 0 {
+		next = j.segments[len(j.segments)-1] + 1
+	}
+	file, err := fs.OpenFile(j.segmentPath(next), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	j.current = file
+	j.size = 0
+	j.segments = append(j.segments, next)
+	return nil
+}
+
+// Send appends e, so the journal can be used as a Sink, like the spillover of a ResilientSink.
+func (j *Journal) Send(e FileWatcherEvent) error {
+	return j.Append(e)
+}
+
+// Replay calls fn with the events of the snapshot followed by the events of every segment, oldest first. Events
+// written by older versions of the package are migrated to the current schema. Replay stops at the first error
+// returned by fn.
+func (j *Journal) Replay(fn func(e FileWatcherEvent) error) error {
+	j.mu.Lock()
+	segments := append([]int(nil), j.segments...)
+	j.mu.Unlock()
+	return j.replay(segments, fn)
+}
+
+// Drain replays the journal like Replay and removes the replayed events, for journals buffering events until they
+// can be delivered. The events appended meanwhile are kept for the next drain. When fn fails, nothing is removed and
+// the events are replayed again by the next drain.
+func (j *Journal) Drain(fn func(e FileWatcherEvent) error) error {
+	j.mu.Lock()
+	if j.current != nil {
+		// the next appends go to a new segment, which is not drained
+		if err := j.current.Close(); err != nil {
+			j.mu.Unlock()
+			return err
+		}
+		j.current = nil
+	}
+	segments := append([]int(nil), j.segments...)
+	j.mu.Unlock()
+
+	if err := j.replay(segments, fn); err != nil {
+		return err
+	}
+
+	if err := fs.Remove(filepath.Join(j.Dir, journalSnapshot)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	drained := make(map[int]bool, len(segments))
+	for _, n := range segments {
+		drained[n] = true
+		if err := fs.Remove(j.segmentPath(n)); err != nil && !os.IsNotExist(err) {
+			log.Warn("Unable to remove journal segment ", n, ": ", err)
+		}
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	remaining := j.segments[:0]
+	for _, n := range j.segments {
+		if !drained[n] {
+			remaining = append(remaining, n)
+		}
+	}
+	j.segments = remaining
+	return nil
+}
+
+// replay calls fn with the events of the snapshot and of segments.
+func (j *Journal) replay(segments []int, fn func(e FileWatcherEvent) error) error {
+	snapshot, err := j.readSnapshot()
+	if err != nil {
+		return err
+	}
+	for _, we := range snapshot.Events {
+		if err := fn(we.FileWatcherEvent()); err != nil {
+			return err
+		}
+	}
+
+	for _, n := range segments {
+		if n <= snapshot.Through {
+			// compacted but not removed yet
+			continue
+		}
+		err := j.readSegment(n, func(e FileWatcherEvent) error {
+			return fn(e)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *Journal) readSegment(n int, fn func(e FileWatcherEvent) error) error {
+	content, err := afero.ReadFile(fs, j.segmentPath(n))
+	if err != nil {
+		return err
+	}
+	if i := bytes.LastIndexByte(content, '\n'); i < len(content)-1 {
+		// the last line was cut short by a crash
+		content = content[:i+1]
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		e, err := j.decode(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("journal segment %d line %d: %w", n, line, err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Run appends the events received on events until done is signaled or events is closed, and compacts the journal
+// every CompactInterval. Errors are sent on errs when it is not nil.
+func (j *Journal) Run(done chan bool, events <-chan FileWatcherEvent, errs chan<- error) {
+	var compact <-chan time.Time
+	if j.CompactInterval > 0 {
+		ticker := time.NewTicker(j.CompactInterval)
+		defer ticker.Stop()
+		compact = ticker.C
+	}
+
+	report := func(err error) {
+		if err != nil && errs != nil {
+			errs <- err
+		}
+	}
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			report(j.Append(e))
+		case <-compact:
+			report(j.Compact())
+		case <-done:
+			return
+		}
+	}
+}
+
+// Close closes the current segment.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.closed = true
+	if j.current == nil {
+		return nil
+	}
+	err := j.current.Close()
+	j.current = nil
+	return err
+}
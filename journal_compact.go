@@ -0,0 +1,207 @@
+package fileWatcher
+
+import (
+	"encoding/json"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// journalSnapshotFile is the content of the snapshot of a Journal.
+type journalSnapshotFile struct {
+	Version int `json:"v"`
+	// Through is the number of the last segment folded into the snapshot.
+	Through int         `json:"through"`
+	Events  []WireEvent `json:"events"`
+}
+
+func (j *Journal) readSnapshot() (journalSnapshotFile, error) {
+	snapshot := journalSnapshotFile{}
+	content, err := afero.ReadFile(fs, filepath.Join(j.Dir, journalSnapshot))
+	if os.IsNotExist(err) {
+		return snapshot, nil
+	}
+	if err != nil {
+		return snapshot, err
+	}
+
+	raw := struct {
+		Version int               `json:"v"`
+		Through int               `json:"through"`
+		Events  []json.RawMessage `json:"events"`
+	}{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return snapshot, err
+	}
+	snapshot.Version = raw.Version
+	snapshot.Through = raw.Through
+	for _, data := range raw.Events {
+		e, err := j.decode(data)
+		if err != nil {
+			return snapshot, err
+		}
+		snapshot.Events = append(snapshot.Events, NewWireEvent(e))
+	}
+	return snapshot, nil
+}
+
+// Compact folds every segment but the one being written into the snapshot and removes them. The snapshot holds the
+// final state of every path: a CREATE_FILE or CREATE_FOLDER event carrying the time of the last change for paths that
+// exist, a DELETE_FILE or DELETE_FOLDER event for paths that were removed. Replaying a compacted journal is bounded by
+// the number of paths instead of the number of events.
+func (j *Journal) Compact() error {
+	j.mu.Lock()
+	var closed []int
+	for _, n := range j.segments {
+		if j.current == nil || n != j.segments[len(j.segments)-1] {
+			closed = append(closed, n)
+		}
+	}
+	j.mu.Unlock()
+	if len(closed) == 0 {
+		return nil
+	}
+
+	snapshot, err := j.readSnapshot()
+	if err != nil {
+		return err
+	}
+	state := newJournalState(snapshot.Events)
+	through := snapshot.Through
+	for _, n := range closed {
+		if n <= snapshot.Through {
+			continue
+		}
+		err := j.readSegment(n, func(e FileWatcherEvent) error {
+			state.apply(e)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		through = n
+	}
+
+	snapshot = journalSnapshotFile{Version: EventSchemaVersion, Through: through, Events: state.events()}
+	raw := struct {
+		Version int               `json:"v"`
+		Through int               `json:"through"`
+		Events  []json.RawMessage `json:"events"`
+	}{Version: snapshot.Version, Through: snapshot.Through, Events: make([]json.RawMessage, 0, len(snapshot.Events))}
+	for _, we := range snapshot.Events {
+		data, err := j.encode(we.FileWatcherEvent())
+		if err != nil {
+			return err
+		}
+		raw.Events = append(raw.Events, data)
+	}
+	content, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	// the snapshot is replaced atomically, segments are only removed once it is in place
+	tmp := filepath.Join(j.Dir, journalSnapshot+".tmp")
+	if err := afero.WriteFile(fs, tmp, content, 0644); err != nil {
+		return err
+	}
+	if err := fs.Rename(tmp, filepath.Join(j.Dir, journalSnapshot)); err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	remaining := j.segments[:0]
+	for _, n := range j.segments {
+		if n > through {
+			remaining = append(remaining, n)
+			continue
+		}
+		if err := fs.Remove(j.segmentPath(n)); err != nil && !os.IsNotExist(err) {
+			log.Warn("Unable to remove journal segment ", n, ": ", err)
+		}
+	}
+	j.segments = remaining
+	return nil
+}
+
+// journalState is the final state of every path seen in a journal.
+type journalState struct {
+	paths map[string]FileWatcherEvent
+}
+
+func newJournalState(events []WireEvent) *journalState {
+	s := &journalState{paths: make(map[string]FileWatcherEvent, len(events))}
+	for _, we := range events {
+		s.apply(we.FileWatcherEvent())
+	}
+	return s
+}
+
+func (s *journalState) apply(e FileWatcherEvent) {
+	switch e.Event {
+	case EventCreateFile, EventCreateFolder, EventMovedInFile, EventMovedInFolder:
+		s.paths[e.Path] = e
+	case EventEditFile:
+		e.Event = EventCreateFile
+		s.paths[e.Path] = e
+	case EventDeleteFile, EventMovedOutFile:
+		s.paths[e.Path] = e
+	case EventDeleteFolder, EventMovedOutFolder:
+		s.dropBelow(e.Path)
+		s.paths[e.Path] = e
+	case EventRenameFile:
+		s.paths[e.PreviousPath] = s.tombstone(e, e.PreviousPath, EventDeleteFile)
+		s.paths[e.Path] = s.created(e, EventCreateFile)
+	case EventRenameFolder:
+		for path, child := range s.paths {
+			if !isBelow(path, e.PreviousPath) {
+				continue
+			}
+			delete(s.paths, path)
+			if isDelete(child) {
+				continue
+			}
+			child.Path = filepath.Join(e.Path, strings.TrimPrefix(path, e.PreviousPath))
+			s.paths[child.Path] = child
+		}
+		s.paths[e.PreviousPath] = s.tombstone(e, e.PreviousPath, EventDeleteFolder)
+		s.paths[e.Path] = s.created(e, EventCreateFolder)
+	}
+}
+
+func (s *journalState) tombstone(e FileWatcherEvent, path string, kind EventKind) FileWatcherEvent {
+	e.Path = path
+	e.PreviousPath = ""
+	e.Event = kind
+	return e
+}
+
+func (s *journalState) created(e FileWatcherEvent, kind EventKind) FileWatcherEvent {
+	e.PreviousPath = ""
+	e.Event = kind
+	return e
+}
+
+func (s *journalState) dropBelow(dir string) {
+	for path := range s.paths {
+		if isBelow(path, dir) {
+			delete(s.paths, path)
+		}
+	}
+}
+
+// events returns the state as events sorted by path, so parents come before their children.
+func (s *journalState) events() []WireEvent {
+	paths := make([]string, 0, len(s.paths))
+	for path := range s.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	res := make([]WireEvent, 0, len(paths))
+	for _, path := range paths {
+		res = append(res, NewWireEvent(s.paths[path]))
+	}
+	return res
+}
@@ -0,0 +1,292 @@
+package fileWatcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	jsonRPCParseError     = -32700
+	jsonRPCInvalidRequest = -32600
+	jsonRPCMethodNotFound = -32601
+	jsonRPCInvalidParams  = -32602
+	jsonRPCInternalError  = -32603
+)
+
+type jsonRPCRequest struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonRPCResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *jsonRPCError    `json:"error,omitempty"`
+}
+
+type jsonRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// JSONRPCSubscription is a path a JSON-RPC client subscribed to.
+type JSONRPCSubscription struct {
+	ID   int    `json:"id"`
+	Path string `json:"path"`
+}
+
+// JSONRPCEvent is the parameter of the "event" notifications sent to JSON-RPC clients.
+type JSONRPCEvent struct {
+	Subscription int       `json:"subscription"`
+	Event        EventKind `json:"event"`
+	Path         string    `json:"path"`
+	PreviousPath string    `json:"previousPath,omitempty"`
+}
+
+// jsonRPCServer serves a single client over a pair of streams.
+type jsonRPCServer struct {
+	w      *FileWatcher
+	out    io.Writer
+	framed bool
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int
+	subs    map[int]string
+}
+
+// ServeJSONRPC serves the watcher to a single client speaking JSON-RPC 2.0 over in and out, typically the standard
+// input and output of a child process spawned by an editor. Messages are either one JSON object per line or framed
+// with Content-Length headers like the Language Server Protocol, whichever the client uses first.
+//
+// Methods:
+//   - subscribe {"path": string} returns a subscription id and watches path
+//   - unsubscribe {"subscription": number} stops the subscription, and the watch once no subscription needs it
+//   - list returns the subscriptions
+//
+// Events under a subscribed path are sent as "event" notifications with JSONRPCEvent parameters. ServeJSONRPC takes
+// over the Events channel of the watcher and returns when in is closed.
+func ServeJSONRPC(w *FileWatcher, in io.Reader, out io.Writer) error {
+	s := &jsonRPCServer{w: w, out: out, subs: make(map[int]string)}
+	reader := bufio.NewReader(in)
+
+	peek, err := reader.Peek(len("Content-Length"))
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return err
+	}
+	s.framed = strings.EqualFold(string(peek), "Content-Length")
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.forwardEvents(stop)
+
+	for {
+		message, err := s.read(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(strings.TrimSpace(string(message))) == 0 {
+			continue
+		}
+		s.handle(message)
+	}
+}
+
+func (s *jsonRPCServer) read(reader *bufio.Reader) ([]byte, error) {
+	if !s.framed {
+		return reader.ReadBytes('\n')
+	}
+
+	length := -1
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length header: %w", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("message without Content-Length header")
+	}
+
+	message := make([]byte, length)
+	_, err := io.ReadFull(reader, message)
+	return message, err
+}
+
+func (s *jsonRPCServer) write(v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		s.w.log.Error("Unable to encode JSON-RPC message: ", err)
+		return
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if s.framed {
+		_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	} else {
+		_, err = fmt.Fprintf(s.out, "%s\n", body)
+	}
+	if err != nil {
+		s.w.log.Error("Unable to write JSON-RPC message: ", err)
+	}
+}
+
+func (s *jsonRPCServer) handle(message []byte) {
+	req := jsonRPCRequest{}
+	if err := json.Unmarshal(message, &req); err != nil {
+		s.write(jsonRPCResponse{JSONRPC: "2.0", Error: &jsonRPCError{Code: jsonRPCParseError, Message: err.Error()}})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		s.reply(req, nil, &jsonRPCError{Code: jsonRPCInvalidRequest, Message: "not a JSON-RPC 2.0 request"})
+		return
+	}
+
+	switch req.Method {
+	case "subscribe":
+		params := struct {
+			Path string `json:"path"`
+		}{}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.Path == "" {
+			s.reply(req, nil, &jsonRPCError{Code: jsonRPCInvalidParams, Message: "expected {\"path\": string}"})
+			return
+		}
+		id, err := s.subscribe(params.Path)
+		if err != nil {
+			s.reply(req, nil, &jsonRPCError{Code: jsonRPCInternalError, Message: err.Error()})
+			return
+		}
+		s.reply(req, JSONRPCSubscription{ID: id, Path: params.Path}, nil)
+	case "unsubscribe":
+		params := struct {
+			Subscription int `json:"subscription"`
+		}{}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			s.reply(req, nil, &jsonRPCError{Code: jsonRPCInvalidParams, Message: "expected {\"subscription\": number}"})
+			return
+		}
+		if err := s.unsubscribe(params.Subscription); err != nil {
+			s.reply(req, nil, &jsonRPCError{Code: jsonRPCInvalidParams, Message: err.Error()})
+			return
+		}
+		s.reply(req, true, nil)
+	case "list":
+		s.reply(req, s.list(), nil)
+	default:
+		s.reply(req, nil, &jsonRPCError{Code: jsonRPCMethodNotFound, Message: "unknown method " + req.Method})
+	}
+}
+
+func (s *jsonRPCServer) reply(req jsonRPCRequest, result interface{}, rpcErr *jsonRPCError) {
+	if req.ID == nil {
+		// notifications get no response
+		return
+	}
+	s.write(jsonRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr})
+}
+
+func (s *jsonRPCServer) subscribe(path string) (int, error) {
+	path = filepath.Clean(path)
+	if err := s.w.Add(path); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	s.subs[s.nextID] = path
+	return s.nextID, nil
+}
+
+func (s *jsonRPCServer) unsubscribe(id int) error {
+	s.mu.Lock()
+	path, ok := s.subs[id]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("unknown subscription %d", id)
+	}
+	delete(s.subs, id)
+	stillUsed := false
+	for _, other := range s.subs {
+		if other == path {
+			stillUsed = true
+		}
+	}
+	s.mu.Unlock()
+
+	if stillUsed {
+		return nil
+	}
+	return s.w.Remove(path)
+}
+
+func (s *jsonRPCServer) list() []JSONRPCSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]JSONRPCSubscription, 0, len(s.subs))
+	for id, path := range s.subs {
+		res = append(res, JSONRPCSubscription{ID: id, Path: path})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
+
+func (s *jsonRPCServer) forwardEvents(stop chan struct{}) {
+	for {
+		select {
+		case e, ok := <-s.w.Events:
+			if !ok {
+				return
+			}
+			for _, sub := range s.list() {
+				if e.Path == sub.Path || isBelow(e.Path, sub.Path) ||
+					(e.PreviousPath != "" && (e.PreviousPath == sub.Path || isBelow(e.PreviousPath, sub.Path))) {
+					exported := s.w.Export(e)
+					s.write(jsonRPCNotification{JSONRPC: "2.0", Method: "event", Params: JSONRPCEvent{
+						Subscription: sub.ID,
+						Event:        exported.Event,
+						Path:         exported.Path,
+						PreviousPath: exported.PreviousPath,
+					}})
+				}
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package fileWatcher
+
+import (
+	"context"
+	"path/filepath"
+	"runtime/pprof"
+)
+
+// profilingLabelKey is the pprof label naming the subsystem of the package a goroutine belongs to.
+const profilingLabelKey = "fileWatcher"
+
+// watcherLabelKey is the pprof label identifying the watcher a goroutine belongs to, see RuntimeStats.
+const watcherLabelKey = "watcher"
+
+// watcherInstances numbers the watchers of the process.
+var watcherInstances uint64
+
+// labelGoroutine tags the calling goroutine, and the goroutines it starts, with the subsystem so CPU profiles of the
+// host application attribute the time spent watching.
+func labelGoroutine(subsystem string) {
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels(profilingLabelKey, subsystem)))
+}
+
+// labelGoroutine tags the calling goroutine with the subsystem and the watcher it runs for.
+func (w *FileWatcher) labelGoroutine(subsystem string) {
+	labels := pprof.Labels(profilingLabelKey, subsystem, watcherLabelKey, w.instance)
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), labels))
+}
+
+// labeled runs fn with the labels of the subsystem and, when the ProfilingLabels option is set, the watched root
+// path. Labelling per call allocates, so the root is only added on request.
+func (w *FileWatcher) labeled(subsystem string, path string, fn func()) {
+	if !w.options.ProfilingLabels {
+		fn()
+		return
+	}
+
+	labels := pprof.Labels(profilingLabelKey, subsystem, watcherLabelKey, w.instance, "root", w.rootOf(path))
+	pprof.Do(context.Background(), labels, func(context.Context) {
+		fn()
+	})
+}
+
+// rootOf returns the closest watched path at or above path, or an empty string when path is not below a watched
+// path.
+func (w *FileWatcher) rootOf(path string) string {
+	for current := filepath.Clean(path); ; {
+		if w.Contains(current) {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return ""
+		}
+		current = parent
+	}
+}
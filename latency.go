@@ -0,0 +1,141 @@
+package fileWatcher
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencySamples is the number of samples the percentiles are computed over.
+const latencySamples = 256
+
+// LatencyPercentiles summarizes the last latency samples.
+type LatencyPercentiles struct {
+	Samples int
+	P50     time.Duration
+	P90     time.Duration
+	P99     time.Duration
+	Max     time.Duration
+}
+
+// LatencyStats is the latency of the event pipeline, measured when the LatencyProbeInterval option is set.
+type LatencyStats struct {
+	// Probe is the time between writing a sentinel file in a watched directory and the watcher seeing its event. It
+	// covers the kernel and the event loop.
+	Probe LatencyPercentiles
+	// Delivery is the time between an event being emitted and a consumer receiving it from Events. It grows when the
+	// consumers, not the watcher, are slow.
+	Delivery LatencyPercentiles
+}
+
+type latencyRing struct {
+	samples []time.Duration
+	next    int
+}
+
+func (r *latencyRing) add(d time.Duration) {
+	if len(r.samples) < latencySamples {
+		r.samples = append(r.samples, d)
+		return
+	}
+	r.samples[r.next] = d
+	r.next = (r.next + 1) % latencySamples
+}
+
+func (r *latencyRing) percentiles() LatencyPercentiles {
+	if len(r.samples) == 0 {
+		return LatencyPercentiles{}
+	}
+	sorted := append([]time.Duration(nil), r.samples...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	at := func(p int) time.Duration {
+		return sorted[(len(sorted)-1)*p/100]
+	}
+	return LatencyPercentiles{Samples: len(sorted), P50: at(50), P90: at(90), P99: at(99), Max: sorted[len(sorted)-1]}
+}
+
+type latencyState struct {
+	mu       sync.Mutex
+	probe    latencyRing
+	delivery latencyRing
+	stop     chan struct{}
+}
+
+// observeDeliveryLatency records how long e took from being emitted to being received.
+func (w *FileWatcher) observeDeliveryLatency(e FileWatcherEvent) {
+	if w.options.LatencyProbeInterval <= 0 || e.Time.IsZero() {
+		return
+	}
+	d := time.Since(e.Time)
+	w.latency.mu.Lock()
+	w.latency.delivery.add(d)
+	w.latency.mu.Unlock()
+}
+
+func (w *FileWatcher) latencyStats() LatencyStats {
+	w.latency.mu.Lock()
+	defer w.latency.mu.Unlock()
+	return LatencyStats{Probe: w.latency.probe.percentiles(), Delivery: w.latency.delivery.percentiles()}
+}
+
+// startLatencyProbe probes the watched directories in turn every LatencyProbeInterval.
+func (w *FileWatcher) startLatencyProbe() {
+	interval := w.options.LatencyProbeInterval
+	if interval <= 0 {
+		return
+	}
+	w.latency.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		w.labelGoroutine("latency-probe")
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		next := 0
+		for {
+			select {
+			case <-ticker.C:
+				if !w.Shedding() {
+					next = w.probeLatency(next, interval)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(w.latency.stop)
+}
+
+func (w *FileWatcher) stopLatencyProbe() {
+	if w.latency.stop != nil {
+		close(w.latency.stop)
+	}
+}
+
+// probeLatency measures the round trip of a sentinel in the next watched directory, and returns the index of the one
+// to probe next time.
+func (w *FileWatcher) probeLatency(next int, timeout time.Duration) int {
+	var dirs []string
+	for _, path := range w.WatchedMap.Keys() {
+		if info, err := w.fs.Stat(path); err == nil && info.IsDir() && !w.shouldPoll(path) {
+			dirs = append(dirs, path)
+		}
+	}
+	if len(dirs) == 0 {
+		return 0
+	}
+	sort.Strings(dirs)
+	dir := dirs[next%len(dirs)]
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	if err := w.roundTrip(ctx, dir); err != nil {
+		w.log.Debug("Latency probe of ", dir, " failed: ", err)
+		return next + 1
+	}
+	w.latency.mu.Lock()
+	w.latency.probe.add(time.Since(start))
+	w.latency.mu.Unlock()
+	return next + 1
+}
@@ -0,0 +1,227 @@
+package fileWatcher
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/spf13/afero"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// leaseSettle is how long FileLeaderLock waits after writing a lease before reading it back, so a contender that
+// wrote at the same time is noticed.
+const leaseSettle = 200 * time.Millisecond
+
+// LeaderLock elects the instance emitting the events among the watchers of a shared filesystem, see
+// LeaderElectionOptions. FileLeaderLock is a lease on the shared storage itself, implement it on a coordination
+// service like etcd, Consul or a database lock for stricter guarantees.
+type LeaderLock interface {
+	// Acquire takes the leadership, or renews it when this instance holds it already, for ttl. It reports whether this
+	// instance is the leader.
+	Acquire(ttl time.Duration) (bool, error)
+	// Release gives up the leadership, when this instance holds it.
+	Release() error
+}
+
+// LeaderElectionOptions configures the coordination of several watchers of the same shared storage, like an NFS
+// export watched by every host of a cluster: a single instance, the leader, emits the events and the others stand by
+// with their watches in place, ready to take over when the leader stops renewing its lease.
+type LeaderElectionOptions struct {
+	// Lock elects the leader. Nil disables leader election.
+	Lock LeaderLock
+	// TTL is how long the leadership lasts without being renewed, it is renewed every third of it. The failover takes
+	// up to TTL and a third. It defaults to 15 seconds.
+	TTL time.Duration
+	// OnChange is called when this instance becomes the leader or stops being it.
+	OnChange func(leading bool)
+}
+
+type leaderState struct {
+	leading int32
+	// snapshot is the state of the watched paths when the instance last checked the leadership without holding it,
+	// the changes since are reported when it takes over.
+	snapshot map[string]map[string]polledEntry
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// Leading reports whether the watcher emits events, which is always the case without leader election.
+func (w *FileWatcher) Leading() bool {
+	return w.options.LeaderElection.Lock == nil || atomic.LoadInt32(&w.leader.leading) == 1
+}
+
+// startLeaderElection checks the leadership every third of the TTL, renewing it or trying to take it over.
+func (w *FileWatcher) startLeaderElection() {
+	lock := w.options.LeaderElection.Lock
+	if lock == nil {
+		return
+	}
+	ttl := w.options.LeaderElection.TTL
+	if ttl <= 0 {
+		ttl = 15 * time.Second
+	}
+
+	w.leader.stop = make(chan struct{})
+	w.leader.stopped = make(chan struct{})
+	go func(stop chan struct{}) {
+		w.labelGoroutine("leader-election")
+		defer close(w.leader.stopped)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			w.checkLeadership(lock, ttl)
+			select {
+			case <-ticker.C:
+			case <-stop:
+				if w.Leading() {
+					if err := lock.Release(); err != nil {
+						w.log.Warn("Unable to release the leadership: ", err)
+					}
+				}
+				return
+			}
+		}
+	}(w.leader.stop)
+}
+
+func (w *FileWatcher) stopLeaderElection() {
+	if w.leader.stop != nil {
+		close(w.leader.stop)
+		<-w.leader.stopped
+	}
+}
+
+// checkLeadership acquires or renews the leadership. An instance unable to reach the lock steps down, since another
+// one may take over meanwhile.
+func (w *FileWatcher) checkLeadership(lock LeaderLock, ttl time.Duration) {
+	leading, err := lock.Acquire(ttl)
+	if err != nil {
+		w.log.Warn("Unable to acquire the leadership: ", err)
+		leading = false
+	}
+
+	if !leading {
+		w.leader.snapshot = w.snapshotWatched(false)
+		if atomic.CompareAndSwapInt32(&w.leader.leading, 1, 0) {
+			w.log.Info("Lost the leadership, standing by")
+			w.leadershipChanged(false)
+		}
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&w.leader.leading, 0, 1) {
+		return
+	}
+	w.log.Info("Took over the leadership")
+	w.leadershipChanged(true)
+	// the previous leader may have stopped before reporting the latest changes
+	if before := w.leader.snapshot; before != nil {
+		w.leader.snapshot = nil
+		w.emitChangesSince(before, false, "changed while following another leader")
+	}
+}
+
+func (w *FileWatcher) leadershipChanged(leading bool) {
+	if onChange := w.options.LeaderElection.OnChange; onChange != nil {
+		onChange(leading)
+	}
+}
+
+// FileLeaderLock is a LeaderLock keeping a lease in a file of the shared storage, holding the identity of the leader
+// and when its lease expires. The lease is taken over by writing a new file and renaming it over the old one, then
+// reading it back to check no other instance took it at the same time.
+//
+// It relies on the renames of the storage being atomic, which is the case of NFS and SMB, and on the clocks of the
+// hosts agreeing to well within the TTL. Two instances may both lead for a short while when a takeover races with a
+// slow storage, the consumers should tolerate the occasional duplicate event.
+type FileLeaderLock struct {
+	// Path is the lease file.
+	Path string
+	// ID identifies this instance, it defaults to the hostname and the process id.
+	ID string
+
+	mu sync.Mutex
+}
+
+// fileLease is the content of the lease file.
+type fileLease struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewFileLeaderLock creates a FileLeaderLock keeping its lease at path, on the shared storage.
+func NewFileLeaderLock(path string, id string) *FileLeaderLock {
+	if id == "" {
+		host, _ := os.Hostname()
+		id = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	return &FileLeaderLock{Path: path, ID: id}
+}
+
+func (l *FileLeaderLock) Acquire(ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lease, err := l.read()
+	if err != nil {
+		return false, err
+	}
+	renewing := lease.Owner == l.ID
+	if !renewing && time.Now().Before(lease.Expires) {
+		return false, nil
+	}
+
+	if err := l.write(fileLease{Owner: l.ID, Expires: time.Now().Add(ttl)}); err != nil {
+		return false, err
+	}
+	if !renewing {
+		time.Sleep(leaseSettle)
+	}
+	lease, err = l.read()
+	if err != nil {
+		return false, err
+	}
+	return lease.Owner == l.ID, nil
+}
+
+func (l *FileLeaderLock) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lease, err := l.read()
+	if err != nil || lease.Owner != l.ID {
+		return err
+	}
+	// an expired lease lets the others take over right away
+	return l.write(fileLease{Owner: l.ID})
+}
+
+// read returns the current lease, an empty one when there is none or it is unreadable.
+func (l *FileLeaderLock) read() (fileLease, error) {
+	lease := fileLease{}
+	data, err := afero.ReadFile(fs, l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lease, nil
+	}
+	if err != nil {
+		return lease, err
+	}
+	if err := json.Unmarshal(data, &lease); err != nil {
+		log.Warn("Ignoring the unreadable lease ", l.Path, ": ", err)
+		return fileLease{}, nil
+	}
+	return lease, nil
+}
+
+// write replaces the lease file atomically.
+func (l *FileLeaderLock) write(lease fileLease) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	tmp := l.Path + ".tmp-" + l.ID
+	if err := afero.WriteFile(fs, tmp, data, 0644); err != nil {
+		return err
+	}
+	return fs.Rename(tmp, l.Path)
+}
@@ -0,0 +1,138 @@
+package fileWatcher
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotClosed is returned by LeakCheck for a watcher still open.
+var ErrNotClosed = errors.New("the watcher is not closed")
+
+// LeakError lists what a closed watcher left behind, see LeakCheck.
+type LeakError struct {
+	// Goroutines counts the goroutines of the watcher still running by subsystem, see RuntimeStats.
+	Goroutines map[string]int
+	// Timers is the number of timers of the watcher still pending.
+	Timers int
+	// Notifier is the error of the notifier, when closing it failed or its kernel queue is still open.
+	Notifier error
+}
+
+func (e *LeakError) Error() string {
+	var leaks []string
+	subsystems := make([]string, 0, len(e.Goroutines))
+	for subsystem := range e.Goroutines {
+		subsystems = append(subsystems, subsystem)
+	}
+	sort.Strings(subsystems)
+	for _, subsystem := range subsystems {
+		leaks = append(leaks, fmt.Sprintf("%d %s goroutines", e.Goroutines[subsystem], subsystem))
+	}
+	if e.Timers > 0 {
+		leaks = append(leaks, fmt.Sprintf("%d pending timers", e.Timers))
+	}
+	if e.Notifier != nil {
+		leaks = append(leaks, "the notifier: "+e.Notifier.Error())
+	}
+	return "the closed watcher left " + strings.Join(leaks, ", ")
+}
+
+type timerTracker struct {
+	// live counts the timers armed and not yet fired or stopped.
+	live int64
+	// clock is the clock of the watcher, system the clock of the timers on the real time, both counting their timers.
+	clock  Clock
+	system Clock
+}
+
+// trackingClock counts the pending timers of its AfterFunc. The timers of NewTimer are received by goroutines, which
+// LeakCheck follows instead.
+type trackingClock struct {
+	Clock
+	live *int64
+}
+
+func (c trackingClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &trackedTimer{armed: 1, live: c.live}
+	atomic.AddInt64(c.live, 1)
+	t.Timer = c.Clock.AfterFunc(d, func() {
+		t.disarm()
+		f()
+	})
+	return t
+}
+
+type trackedTimer struct {
+	Timer
+	armed int32
+	live  *int64
+}
+
+func (t *trackedTimer) disarm() {
+	if atomic.CompareAndSwapInt32(&t.armed, 1, 0) {
+		atomic.AddInt64(t.live, -1)
+	}
+}
+
+func (t *trackedTimer) Stop() bool {
+	stopped := t.Timer.Stop()
+	t.disarm()
+	return stopped
+}
+
+func (t *trackedTimer) Reset(d time.Duration) bool {
+	if atomic.CompareAndSwapInt32(&t.armed, 0, 1) {
+		atomic.AddInt64(t.live, 1)
+	}
+	return t.Timer.Reset(d)
+}
+
+// trackTimers makes the clocks of the watcher count their timers.
+func (w *FileWatcher) trackTimers() {
+	base := w.options.Clock
+	if base == nil {
+		base = SystemClock{}
+	}
+	w.timers.clock = trackingClock{Clock: base, live: &w.timers.live}
+	w.timers.system = trackingClock{Clock: SystemClock{}, live: &w.timers.live}
+}
+
+// afterFunc calls f in its own goroutine after d of real time, whatever the Clock option.
+func (w *FileWatcher) afterFunc(d time.Duration, f func()) Timer {
+	if w.timers.system == nil {
+		return SystemClock{}.AfterFunc(d, f)
+	}
+	return w.timers.system.AfterFunc(d, f)
+}
+
+// LeakCheck verifies a closed watcher left nothing behind: no goroutine labeled with the watcher, no pending timer and
+// no open notifier, whose descriptors hold the kernel watches. It returns ErrNotClosed before Close, and a LeakError
+// listing the leftovers. Close waits for the goroutines of the event handlers, the goroutines started by the handlers
+// and the sinks are not tied to the watcher and not checked. The other goroutines and the timers wind down right
+// after Close returns, see fileWatchertest.VerifyClosed to wait for them.
+func (w *FileWatcher) LeakCheck() error {
+	if !w.closed() {
+		return ErrNotClosed
+	}
+	leak := &LeakError{Goroutines: w.goroutines(), Timers: int(atomic.LoadInt64(&w.timers.live))}
+	leak.Notifier = w.life.err
+	// the notifier of a platform without fsnotify backend, like wasip1 or js, has no kernel queue
+	if events, _ := notifications(w.Watcher); leak.Notifier == nil && events != nil {
+		select {
+		case _, open := <-events:
+			if open {
+				leak.Notifier = errors.New("the kernel queue is still open")
+			}
+		default:
+			leak.Notifier = errors.New("the kernel queue is still open")
+		}
+	}
+	if len(leak.Goroutines) == 0 && leak.Timers == 0 && leak.Notifier == nil {
+		return nil
+	}
+	return leak
+}
@@ -0,0 +1,189 @@
+package fileWatcher
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultCloseTimeout = 5 * time.Second
+
+// lifecycle coordinates the shutdown of a watcher. Sends on Events, Errors, ChangeSets, Batches and Priority hold
+// the read lock, so the channels are only closed once no send is in flight.
+type lifecycle struct {
+	once sync.Once
+	// stopping is closed by Close to stop the event loop.
+	stopping chan struct{}
+	// abandon is closed when the pending events could not be delivered within the close timeout.
+	abandon chan struct{}
+	// stopped is closed once the channels are closed.
+	stopped chan struct{}
+	// deadline closes abandon once the close timeout passed.
+	deadline *time.Timer
+	mu       sync.RWMutex
+	closed   bool
+	err      error
+}
+
+func (l *lifecycle) init() {
+	l.stopping = make(chan struct{})
+	l.abandon = make(chan struct{})
+	l.stopped = make(chan struct{})
+}
+
+// Close stops the watcher. The events held back by debouncing, coalescing, batching or a dispatch queue are delivered
+// first, then Events, Errors, ChangeSets, Batches and Priority are closed, so ranging over them terminates, and the
+// handlers registered with OnEvent and OnError handle the last events. Events the consumers do not receive within the
+// CloseTimeout are dropped, and handlers still running then are left behind. Close can be called several times and from several
+// goroutines, every call returns once the watcher is stopped. Signaling the done channel given to Init stops the
+// watcher the same way.
+func (w *FileWatcher) Close() error {
+	w.beginClose()
+	<-w.life.stopped
+	return w.life.err
+}
+
+// beginClose asks the event loop to shut down and starts the close timeout, which also covers a loop blocked on a
+// consumer that stopped receiving.
+func (w *FileWatcher) beginClose() {
+	w.life.once.Do(func() {
+		timeout := w.options.CloseTimeout
+		if timeout <= 0 {
+			timeout = defaultCloseTimeout
+		}
+		w.life.deadline = time.AfterFunc(timeout, func() {
+			w.log.Warn("Events not received within " + timeout.String() + " of closing the watcher are dropped")
+			close(w.life.abandon)
+		})
+		close(w.life.stopping)
+	})
+}
+
+// shutdown runs the shutdown sequence on the event loop.
+func (w *FileWatcher) shutdown() {
+	w.beginClose()
+	defer w.life.deadline.Stop()
+
+	w.stopLeaderElection()
+	w.stopPoller()
+	w.stopLatencyProbe()
+	w.stopLoadMonitor()
+	w.stopStateGC()
+	w.stopHealthCheck()
+	w.stopAccessEvents()
+	w.stopPermissions()
+	current := w.currentNotifier()
+	err := current.Close()
+	if _, ok := current.(fsnotifyNotifier); !ok {
+		_ = w.Watcher.Close()
+	}
+
+	w.flushSQLite()
+	w.stopClassifier()
+	w.stopDispatcher()
+	w.flushRateLimited()
+	w.flushDebounced()
+	w.flushCoalesced()
+	w.flushBurst()
+	w.flushBatch()
+	w.stopExtractions()
+	w.stopBulk()
+	if err := w.saveWarmCache(); err != nil {
+		w.log.Warn("Unable to save the warm cache: ", err)
+	}
+
+	w.life.mu.Lock()
+	w.life.closed = true
+	close(w.Events)
+	close(w.Errors)
+	close(w.ChangeSets)
+	close(w.Batches)
+	close(w.Priority)
+	w.life.mu.Unlock()
+	w.waitHandlers()
+	// after the handlers, which may forward to subscriptions, like the ones of Merge
+	w.closeSubscriptions()
+
+	w.life.err = err
+	close(w.life.stopped)
+}
+
+// closed reports whether the watcher is closed.
+func (w *FileWatcher) closed() bool {
+	w.life.mu.RLock()
+	defer w.life.mu.RUnlock()
+	return w.life.closed
+}
+
+// sendEvent delivers e on Events unless the watcher is closed, and reports whether it did.
+func (w *FileWatcher) sendEvent(e FileWatcherEvent) bool {
+	w.life.mu.RLock()
+	defer w.life.mu.RUnlock()
+	if w.life.closed {
+		w.trace(e.Path, "watcher closed, %s dropped", e.Event)
+		w.countDropped(e, "watcher closed")
+		return false
+	}
+	switch w.options.Overflow {
+	case OverflowDropOldest:
+		w.sendDroppingOldest(e)
+		return true
+	case OverflowDropNewest:
+		return w.sendDroppingNewest(e)
+	}
+	select {
+	case w.Events <- e:
+		atomic.AddUint64(&w.counters.delivered, 1)
+		return true
+	default:
+	}
+	// the consumer is behind, the wait is measured
+	start := time.Now()
+	select {
+	case w.Events <- e:
+		atomic.AddUint64(&w.counters.delivered, 1)
+		w.instrumentBlocked(e, start, "Events")
+		return true
+	case <-w.life.abandon:
+		w.countDropped(e, "not received within the close timeout")
+		return false
+	}
+}
+
+// sendError delivers err on Errors unless the watcher is closed, or drops it when Errors is full, see the ErrorBuffer
+// option.
+func (w *FileWatcher) sendError(err error) {
+	atomic.AddUint64(&w.counters.errors, 1)
+	w.life.mu.RLock()
+	defer w.life.mu.RUnlock()
+	if w.life.closed {
+		return
+	}
+	if w.options.ErrorBuffer < 0 {
+		select {
+		case w.Errors <- err:
+		case <-w.life.abandon:
+		}
+		return
+	}
+	select {
+	case w.Errors <- err:
+	default:
+		// nobody reads Errors fast enough, the event loop doesn't wait for them
+		atomic.AddUint64(&w.counters.errorsDropped, 1)
+		w.log.Debug("Errors is full, dropped: ", err)
+	}
+}
+
+// sendChangeSet delivers cs on ChangeSets unless the watcher is closed.
+func (w *FileWatcher) sendChangeSet(cs *ChangeSet) {
+	w.life.mu.RLock()
+	defer w.life.mu.RUnlock()
+	if w.life.closed {
+		return
+	}
+	select {
+	case w.ChangeSets <- cs:
+	case <-w.life.abandon:
+	}
+}
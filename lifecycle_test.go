@@ -0,0 +1,71 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCloseHandsTheLastEventsToTheHandlers(t *testing.T) {
+	withoutCustomKinds(t)
+	kind, err := RegisterEventKind("TICK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	w := newTestWatcher(t, WithEventBuffer(64, OverflowBlock), WithDebounce(time.Hour))
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	handled := make(map[string]bool)
+	w.OnEvent(func(e FileWatcherEvent) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		handled[e.Path] = true
+		mu.Unlock()
+	})
+
+	// the creates are held back by the debouncing until Close, the custom events wait in the buffer of Events
+	const files = 20
+	for i := 0; i < files; i++ {
+		writeFile(t, filepath.Join(root, fmt.Sprint("file", i)), "")
+	}
+	deadline := time.Now().Add(testTimeout)
+	for {
+		w.debouncer.mu.Lock()
+		pending := len(w.debouncer.pending)
+		w.debouncer.mu.Unlock()
+		if pending == files {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of the %d creates debounced", pending, files)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	const emitted = 100
+	for i := 0; i < emitted; i++ {
+		if err := w.Emit(FileWatcherEvent{Event: kind, Path: filepath.Join(root, fmt.Sprint("emitted", i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i := 0; i < files; i++ {
+		if path := filepath.Join(root, fmt.Sprint("file", i)); !handled[path] {
+			t.Error("the debounced create of ", path, " was not handled")
+		}
+	}
+	for i := 0; i < emitted; i++ {
+		if path := filepath.Join(root, fmt.Sprint("emitted", i)); !handled[path] {
+			t.Error("the event of ", path, " emitted before Close was not handled")
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package fileWatcher
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrWatchLimitExceeded is returned by Add when the kernel has no watch left, the fs.inotify.max_user_watches sysctl
+// on Linux. Raise the limit, watch fewer directories or set the WatchLimitFallback option.
+var ErrWatchLimitExceeded = errors.New("watch limit exceeded")
+
+// RemainingWatchBudget returns the number of watches the kernel still allows, and false when the platform has no
+// fixed limit. Only the watches of this process are accounted for: the limit is per user, the watches of the other
+// processes of the user count against it too.
+func (w *FileWatcher) RemainingWatchBudget() (int, bool) {
+	limit, ok := watchLimit()
+	if !ok {
+		return 0, false
+	}
+	used, ok := watchesUsed()
+	if !ok {
+		return 0, false
+	}
+	if used > limit {
+		return 0, true
+	}
+	return limit - used, true
+}
+
+// overWatchLimit handles err, the failure to watch path. When the kernel ran out of watches, path is polled instead
+// with the WatchLimitFallback option, otherwise an ErrWatchLimitExceeded is returned. Other errors are returned as is.
+func (w *FileWatcher) overWatchLimit(path string, err error) error {
+	if !isWatchLimitError(err) {
+		return err
+	}
+	if !w.options.WatchLimitFallback {
+		limit, _ := watchLimit()
+		return fmt.Errorf("%w: unable to watch %s, every one of the %d inotify watches is in use, "+
+			"raise fs.inotify.max_user_watches or set the WatchLimitFallback option", ErrWatchLimitExceeded, path, limit)
+	}
+	w.trace(path, "out of watches, polling")
+	w.log.Warn("Out of inotify watches, polling ", path)
+	w.WatchedMap.Set(path, path)
+	return w.addPolled(path)
+}
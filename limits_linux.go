@@ -0,0 +1,53 @@
+//go:build linux
+
+package fileWatcher
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// watchLimit returns the maximum number of inotify watches of a user.
+func watchLimit() (int, bool) {
+	content, err := os.ReadFile("/proc/sys/fs/inotify/max_user_watches")
+	if err != nil {
+		return 0, false
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, false
+	}
+	return limit, true
+}
+
+// watchesUsed returns the number of inotify watches held by the process, counted from the fdinfo of its inotify
+// instances.
+func watchesUsed() (int, bool) {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, false
+	}
+	used := 0
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+		if err != nil || target != "anon_inode:inotify" {
+			continue
+		}
+		info, err := os.ReadFile(filepath.Join("/proc/self/fdinfo", fd.Name()))
+		if err != nil {
+			continue
+		}
+		used += bytes.Count(info, []byte("inotify wd:"))
+	}
+	return used, true
+}
+
+// isWatchLimitError reports whether err is inotify running out of watches.
+func isWatchLimitError(err error) bool {
+	return errors.Is(err, unix.ENOSPC)
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package fileWatcher
+
+// watchLimit returns the maximum number of watches. Only inotify has a fixed limit.
+func watchLimit() (int, bool) {
+	return 0, false
+}
+
+// watchesUsed returns the number of inotify watches held by the process, there are none off Linux.
+func watchesUsed() (int, bool) {
+	return 0, false
+}
+
+// isWatchLimitError reports whether err is inotify running out of watches, which never happens off Linux.
+func isWatchLimitError(error) bool {
+	return false
+}
@@ -0,0 +1,203 @@
+package fileWatcher
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Codes of the warnings returned by Lint.
+const (
+	LintMissingRoot        = "missing-root"
+	LintDuplicateRoot      = "duplicate-root"
+	LintOverlappingRoots   = "overlapping-roots"
+	LintRedundantChild     = "redundant-child"
+	LintInvalidPattern     = "invalid-pattern"
+	LintContradictoryRules = "contradictory-rules"
+	LintWatchLimit         = "watch-limit"
+)
+
+// WatchConfig is a watch configuration to be checked by Lint.
+type WatchConfig struct {
+	Roots []string
+	// Recursive is set when every directory below the roots will be watched.
+	Recursive bool
+	Include   []string
+	Exclude   []string
+}
+
+// LintWarning is a problem found in a watch configuration.
+type LintWarning struct {
+	Code    string
+	Path    string
+	Message string
+}
+
+func (l LintWarning) String() string {
+	if l.Path == "" {
+		return l.Code + ": " + l.Message
+	}
+	return l.Code + ": " + l.Path + ": " + l.Message
+}
+
+// Lint checks a watch configuration before it is applied. It reports missing and duplicate roots, roots that overlap
+// or are already covered by a watched directory, invalid or contradictory include and exclude patterns and
+// configurations that would exceed the watch limit of the kernel.
+func Lint(config WatchConfig) []LintWarning {
+	var res []LintWarning
+	warn := func(code string, path string, format string, args ...interface{}) {
+		res = append(res, LintWarning{Code: code, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	roots := make([]string, 0, len(config.Roots))
+	dirs := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, root := range config.Roots {
+		clean := filepath.Clean(root)
+		if seen[clean] {
+			warn(LintDuplicateRoot, root, "the path is listed more than once")
+			continue
+		}
+		seen[clean] = true
+
+		info, err := fs.Stat(clean)
+		if err != nil {
+			warn(LintMissingRoot, root, "%v", err)
+			continue
+		}
+		dirs[clean] = info.IsDir()
+		roots = append(roots, clean)
+	}
+
+	sort.Strings(roots)
+	for _, root := range roots {
+		for _, other := range roots {
+			if other == root || !dirs[other] {
+				continue
+			}
+			switch {
+			case !dirs[root] && filepath.Dir(root) == other:
+				warn(LintRedundantChild, root, "the file is already covered by the watch of %s", other)
+			case !dirs[root] && config.Recursive && isBelow(root, other):
+				warn(LintRedundantChild, root, "the file is already covered by the recursive watch of %s", other)
+			case dirs[root] && config.Recursive && isBelow(root, other):
+				warn(LintOverlappingRoots, root, "the directory is already watched recursively through %s", other)
+			}
+		}
+	}
+
+	res = append(res, lintPatterns(config.Include, config.Exclude)...)
+
+	if limit, ok := watchLimit(); ok {
+		needed := 0
+		for _, root := range roots {
+			if config.Recursive && dirs[root] {
+				needed += countDirs(root, limit)
+			} else {
+				needed++
+			}
+		}
+		if needed > limit {
+			warn(LintWatchLimit, "", "%d watches are needed but the kernel allows %d", needed, limit)
+		}
+	}
+
+	return res
+}
+
+// Lint checks the current configuration of the watcher, see the Lint function.
+func (w *FileWatcher) Lint() []LintWarning {
+	return Lint(WatchConfig{
+		Roots:   w.WatchedMap.Keys(),
+		Include: w.IncludePatterns(),
+		Exclude: w.IgnorePatterns(),
+	})
+}
+
+func lintPatterns(include []string, exclude []string) []LintWarning {
+	var res []LintWarning
+	for _, list := range [][]string{include, exclude} {
+		for _, pattern := range list {
+			if err := validPattern(pattern); err != nil {
+				res = append(res, LintWarning{Code: LintInvalidPattern, Path: pattern, Message: err.Error()})
+			}
+		}
+	}
+
+	excluded := make(map[string]bool, len(exclude))
+	for _, pattern := range exclude {
+		excluded[pattern] = true
+		if (pattern == "*" || pattern == "**" || pattern == "/**") && len(include) > 0 {
+			res = append(res, LintWarning{
+				Code:    LintContradictoryRules,
+				Path:    pattern,
+				Message: "every path is excluded, the include patterns can never match",
+			})
+		}
+	}
+
+	for _, pattern := range include {
+		if excluded[pattern] {
+			res = append(res, LintWarning{
+				Code:    LintContradictoryRules,
+				Path:    pattern,
+				Message: "the pattern is both included and excluded",
+			})
+			continue
+		}
+
+		// try a path the include pattern matches against the exclude patterns
+		sample := samplePath(pattern)
+		for _, ex := range exclude {
+			if matchPattern(ex, sample) {
+				res = append(res, LintWarning{
+					Code:    LintContradictoryRules,
+					Path:    pattern,
+					Message: fmt.Sprintf("paths like %s are included but excluded by %s", sample, ex),
+				})
+				break
+			}
+		}
+	}
+	return res
+}
+
+// samplePath builds a path matched by the glob pattern.
+func samplePath(pattern string) string {
+	r := strings.NewReplacer("**", "x", "*", "x", "?", "x")
+	sample := r.Replace(filepath.ToSlash(pattern))
+	if !strings.HasPrefix(sample, "/") {
+		sample = "/" + sample
+	}
+	return filepath.FromSlash(sample)
+}
+
+func isBelow(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	// names starting with two dots, like the ..data link of a Kubernetes volume, are below dir
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+var errWalkStopped = errors.New("walk stopped")
+
+// countDirs counts the directories below root, stopping once limit is exceeded.
+func countDirs(root string, limit int) int {
+	count := 0
+	_ = afero.Walk(fs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			count++
+			if count > limit {
+				return errWalkStopped
+			}
+		}
+		return nil
+	})
+	return count
+}
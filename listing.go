@@ -0,0 +1,235 @@
+package fileWatcher
+
+import (
+	"github.com/spf13/afero"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ListedEntry is an entry of a watched directory as recorded by the listing cache.
+type ListedEntry struct {
+	Name  string
+	IsDir bool
+}
+
+// listingEntry is what is known about an entry of a watched directory.
+type listingEntry struct {
+	isDir bool
+	inode uint64
+	// attrs are the attributes of the entry when it was last seen, for the CHMOD events to tell what changed
+	attrs *FileAttributes
+}
+
+// dirListings is an in-memory listing of the entries of each watched directory. It is filled when a directory is
+// added, kept up to date from the emitted events and refreshed by rescans, so the event loop can tell whether a path
+// was a file or a directory, or whether it existed before, without racing the filesystem with a stat.
+type dirListings struct {
+	// mu is only held for reading by the lookups of the event loop, so they don't wait for each other
+	mu   sync.RWMutex
+	dirs map[string]map[string]listingEntry
+}
+
+// scan replaces the listing of dir with its current content on fsys.
+func (l *dirListings) scan(fsys afero.Fs, dir string) error {
+	entries, err := afero.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	listing := make(map[string]listingEntry, len(entries))
+	for _, info := range entries {
+		inode, _ := inodeOf(info)
+		attrs := attributesOf(info)
+		listing[info.Name()] = listingEntry{isDir: info.IsDir(), inode: inode, attrs: &attrs}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.dirs == nil {
+		l.dirs = make(map[string]map[string]listingEntry)
+	}
+	l.dirs[dir] = listing
+	return nil
+}
+
+// forget drops the listing of dir.
+func (l *dirListings) forget(dir string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.dirs, dir)
+}
+
+// has reports whether dir is listed.
+func (l *dirListings) has(dir string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.dirs[dir]
+	return ok
+}
+
+// snapshot returns a copy of the listing of dir.
+func (l *dirListings) snapshot(dir string) map[string]listingEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	res := make(map[string]listingEntry, len(l.dirs[dir]))
+	for name, entry := range l.dirs[dir] {
+		res[name] = entry
+	}
+	return res
+}
+
+// lookup returns the entry recorded for path.
+func (l *dirListings) lookup(path string) (listingEntry, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	listing, ok := l.dirs[filepath.Dir(path)]
+	if !ok {
+		return listingEntry{}, false
+	}
+	entry, ok := listing[filepath.Base(path)]
+	return entry, ok
+}
+
+// set records entry for path when its directory is listed.
+func (l *dirListings) set(path string, entry listingEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if listing, ok := l.dirs[filepath.Dir(path)]; ok {
+		listing[filepath.Base(path)] = entry
+	}
+}
+
+// remove removes the entry of path.
+func (l *dirListings) remove(path string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if listing, ok := l.dirs[filepath.Dir(path)]; ok {
+		delete(listing, filepath.Base(path))
+	}
+}
+
+// apply updates the listing from an emitted event.
+func (l *dirListings) apply(e FileWatcherEvent) {
+	// the inode is recorded so a later move of the entry, possibly to another watched directory, can be inferred
+	var inode uint64
+	var attrs *FileAttributes
+	if e.Info != nil {
+		inode, _ = inodeOf(e.Info)
+		current := attributesOf(e.Info)
+		attrs = &current
+	}
+
+	switch {
+	case isCreate(e):
+		if entry, ok := l.lookup(e.Path); !ok || entry.inode == 0 {
+			l.set(e.Path, listingEntry{isDir: isFolderEvent(e), inode: inode, attrs: attrs})
+		}
+	case e.Event == EventEditFile && inode != 0:
+		// atomic saves replace the file with a new inode
+		l.set(e.Path, listingEntry{inode: inode, attrs: attrs})
+	case e.Event == EventChMod && e.Attributes != nil:
+		if entry, ok := l.lookup(e.Path); ok {
+			after := e.Attributes.After
+			entry.attrs = &after
+			l.set(e.Path, entry)
+		}
+	case isDelete(e):
+		l.remove(e.Path)
+	case isRename(e), e.Event == EventDownloadCompleted:
+		old, ok := l.lookup(e.PreviousPath)
+		l.remove(e.PreviousPath)
+		if !ok {
+			old = listingEntry{isDir: e.Event == EventRenameFolder}
+		}
+		if inode != 0 {
+			old.inode = inode
+		}
+		if attrs != nil {
+			old.attrs = attrs
+		}
+		l.set(e.Path, old)
+	}
+}
+
+// listed returns the directories that have a listing.
+func (l *dirListings) listed() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	res := make([]string, 0, len(l.dirs))
+	for dir := range l.dirs {
+		res = append(res, dir)
+	}
+	return res
+}
+
+// Listing returns the entries of the watched directory dir as recorded by the listing cache, sorted by name. The
+// second result is false when dir has no listing.
+func (w *FileWatcher) Listing(dir string) ([]ListedEntry, bool) {
+	w.listings.mu.Lock()
+	defer w.listings.mu.Unlock()
+
+	listing, ok := w.listings.dirs[w.normalize(dir)]
+	if !ok {
+		return nil, false
+	}
+	res := make([]ListedEntry, 0, len(listing))
+	for name, entry := range listing {
+		res = append(res, ListedEntry{Name: name, IsDir: entry.isDir})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res, true
+}
+
+// Rescan refreshes the listing of the watched directory dir from the filesystem.
+func (w *FileWatcher) Rescan(dir string) error {
+	return w.listings.scan(w.fs, w.normalize(dir))
+}
+
+// rescanListings refreshes every listing.
+func (w *FileWatcher) rescanListings() {
+	for _, dir := range w.listings.listed() {
+		if err := w.listings.scan(w.fs, dir); err != nil {
+			w.log.Debug("Unable to rescan ", dir, ": ", err)
+		}
+	}
+}
+
+// rescanTicker returns the channel driving periodic rescans, nil when they are disabled.
+func (w *FileWatcher) rescanTicker() (<-chan time.Time, func()) {
+	if w.options.ListingRescanInterval <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(w.options.ListingRescanInterval)
+	return ticker.C, ticker.Stop
+}
+
+// correctDeleteKind uses the listing to tell whether the deleted or moved out path of e was a file or a folder, the
+// raw event flags are not always enough.
+func (w *FileWatcher) correctDeleteKind(e FileWatcherEvent) FileWatcherEvent {
+	entry, ok := w.listings.lookup(e.Path)
+	if !ok {
+		return e
+	}
+	moved := e.Event.Is(EventMovedOutFile | EventMovedOutFolder)
+	switch {
+	case entry.isDir && moved:
+		e.Event = EventMovedOutFolder
+	case entry.isDir:
+		e.Event = EventDeleteFolder
+	case moved:
+		e.Event = EventMovedOutFile
+	default:
+		e.Event = EventDeleteFile
+	}
+	return e
+}
+
+// existedBefore reports whether the listing already had a file at path, which turns a create into an edit.
+func (w *FileWatcher) existedBefore(path string) bool {
+	entry, ok := w.listings.lookup(path)
+	return ok && !entry.isDir
+}
@@ -0,0 +1,102 @@
+package fileWatcher
+
+import (
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// LoadSheddingOptions configures the cooperative yield of a watcher embedded in a latency sensitive process. While the
+// CPU usage of the process is above Threshold, events are debounced for longer, their Info is not filled in and the
+// latency probe is paused. Everything is back to normal once the usage dropped below three quarters of the threshold.
+type LoadSheddingOptions struct {
+	// Threshold is the CPU usage of the process, as a fraction of all the CPUs of the host, above which load is shed.
+	// Zero disables load shedding.
+	Threshold float64
+	// Interval is the time between two samples of the CPU usage. It defaults to one second.
+	Interval time.Duration
+	// Debounce is the debounce interval while shedding, see DebounceInterval. It defaults to 500 milliseconds and is
+	// only used when it is longer than DebounceInterval.
+	Debounce time.Duration
+}
+
+type loadState struct {
+	shedding int32
+	stop     chan struct{}
+}
+
+// Shedding reports whether the watcher is shedding load, see LoadSheddingOptions.
+func (w *FileWatcher) Shedding() bool {
+	return atomic.LoadInt32(&w.load.shedding) == 1
+}
+
+// debounceInterval returns the debounce interval in effect.
+func (w *FileWatcher) debounceInterval() time.Duration {
+	interval := w.options.DebounceInterval
+	if !w.Shedding() {
+		return interval
+	}
+	widened := w.options.LoadShedding.Debounce
+	if widened <= 0 {
+		widened = 500 * time.Millisecond
+	}
+	if widened > interval {
+		return widened
+	}
+	return interval
+}
+
+// startLoadMonitor samples the CPU usage of the process every interval and starts or stops shedding load.
+func (w *FileWatcher) startLoadMonitor() {
+	threshold := w.options.LoadShedding.Threshold
+	if threshold <= 0 {
+		return
+	}
+	if _, ok := processCPUTime(); !ok {
+		w.log.Warn("Load shedding is not available on " + runtime.GOOS)
+		return
+	}
+	interval := w.options.LoadShedding.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	w.load.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		w.labelGoroutine("load-monitor")
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		lastCPU, _ := processCPUTime()
+		lastWall := time.Now()
+		for {
+			select {
+			case <-ticker.C:
+				cpu, _ := processCPUTime()
+				wall := time.Now()
+				usage := float64(cpu-lastCPU) / float64(wall.Sub(lastWall)) / float64(runtime.NumCPU())
+				lastCPU, lastWall = cpu, wall
+				w.adjustLoad(usage, threshold)
+			case <-stop:
+				return
+			}
+		}
+	}(w.load.stop)
+}
+
+func (w *FileWatcher) stopLoadMonitor() {
+	if w.load.stop != nil {
+		close(w.load.stop)
+	}
+}
+
+// adjustLoad starts shedding load when usage went above threshold and stops once it is well below, so the watcher
+// does not flap around the threshold.
+func (w *FileWatcher) adjustLoad(usage float64, threshold float64) {
+	switch {
+	case usage > threshold && atomic.CompareAndSwapInt32(&w.load.shedding, 0, 1):
+		w.log.Info("Process CPU usage at " + strconv.Itoa(int(usage*100)) + "%, shedding load")
+	case usage < threshold*3/4 && atomic.CompareAndSwapInt32(&w.load.shedding, 1, 0):
+		w.log.Info("Process CPU usage at " + strconv.Itoa(int(usage*100)) + "%, no longer shedding load")
+	}
+}
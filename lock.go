@@ -0,0 +1,69 @@
+package fileWatcher
+
+import (
+	"time"
+)
+
+// LockPolicy decides what happens to CREATE_FILE and EDIT_FILE events of files still locked by their writer.
+type LockPolicy int
+
+const (
+	// LockPolicyIgnore delivers events right away without checking for locks.
+	LockPolicyIgnore LockPolicy = iota
+	// LockPolicyDefer holds events back until the file can be opened for reading, or the timeout expires.
+	LockPolicyDefer
+	// LockPolicyFlag delivers events right away with Locked set when the file can't be opened for reading.
+	LockPolicyFlag
+)
+
+// LockOptions configures the handling of files locked by their writer. Only Windows locks files against readers,
+// on other platforms files are never reported as locked.
+type LockOptions struct {
+	Policy LockPolicy
+	// Timeout is how long an event is deferred at most, after which it is delivered with Locked set. It defaults to
+	// 30 seconds.
+	Timeout time.Duration
+	// PollInterval is how often a deferred file is tried again. It defaults to 100 milliseconds.
+	PollInterval time.Duration
+}
+
+// checkLock applies the lock policy to e. It reports false when e has been deferred and will be handed to deliver
+// later.
+func (w *FileWatcher) checkLock(e *FileWatcherEvent, deliver func(FileWatcherEvent)) bool {
+	policy := w.options.Lock.Policy
+	if policy == LockPolicyIgnore || !e.Event.Is(EventCreateFile|EventEditFile) || !fileLocked(e.Path) {
+		return true
+	}
+
+	// deferring the file would deliver it after the later events of its directory
+	if policy == LockPolicyFlag || w.options.OrderedDelivery {
+		e.Locked = true
+		return true
+	}
+
+	timeout := w.options.Lock.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	interval := w.options.Lock.PollInterval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+
+	w.traceEvent(*e, "deferred, the file is locked by its writer")
+	deferred := *e
+	go func() {
+		w.labelGoroutine("lock-wait")
+		deadline := time.Now().Add(timeout)
+		for fileLocked(deferred.Path) {
+			if time.Now().After(deadline) {
+				w.log.Warn("File ", deferred.Path, " is still locked after ", timeout)
+				deferred.Locked = true
+				break
+			}
+			time.Sleep(interval)
+		}
+		deliver(deferred)
+	}()
+	return false
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package fileWatcher
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// fileLocked reports whether another process holds path open without sharing it for reading.
+func fileLocked(path string) bool {
+	f, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err == nil {
+		_ = f.Close()
+		return false
+	}
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation)
+}
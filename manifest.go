@@ -0,0 +1,319 @@
+package fileWatcher
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/spf13/afero"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Manifest maintains the SHA-256 checksums of the files of a tree, in the format of sha256sum, for artifact
+// directories and mirrors. It is updated incrementally from watcher events.
+type Manifest struct {
+	Root string
+	// File, when set, is rewritten with the manifest after every group of applied events, for example
+	// Root/SHA256SUMS. Events for the file itself are ignored.
+	File string
+	// Window is how long events are collected by Run before they are applied. It defaults to one second.
+	Window time.Duration
+
+	mu     sync.RWMutex
+	hashes map[string]string
+}
+
+// ManifestMismatch is a difference between a manifest and the files on disk found by Verify.
+type ManifestMismatch struct {
+	Path string
+	// Expected is the checksum in the manifest, empty for files missing from it.
+	Expected string
+	// Actual is the checksum on disk, empty for missing files.
+	Actual string
+}
+
+// NewManifest creates an empty Manifest for root.
+func NewManifest(root string) *Manifest {
+	return &Manifest{Root: filepath.Clean(root), hashes: make(map[string]string)}
+}
+
+// Build hashes every file below the root, replacing the content of the manifest.
+func (m *Manifest) Build() error {
+	hashes := make(map[string]string)
+	err := m.walk(m.Root, func(path string) error {
+		doc, err := describeFile(fs, path)
+		if err != nil {
+			return err
+		}
+		hashes[m.rel(path)] = doc.Hash
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.hashes = hashes
+	m.mu.Unlock()
+	return m.save()
+}
+
+// Run applies the events received on events until done is signaled or events is closed. Errors are sent on errs
+// when it is not nil.
+func (m *Manifest) Run(done chan bool, events <-chan FileWatcherEvent, errs chan<- error) {
+	var pending []FileWatcherEvent
+	window := m.Window
+	if window <= 0 {
+		window = time.Second
+	}
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if err := m.Apply(pending); err != nil && errs != nil {
+			errs <- err
+		}
+		pending = nil
+	}
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				flush()
+				return
+			}
+			pending = append(pending, e)
+		case <-ticker.C:
+			flush()
+		case <-done:
+			flush()
+			return
+		}
+	}
+}
+
+// Apply updates the manifest with the net effect of events. It keeps going when a file can not be hashed and returns
+// the first error encountered.
+func (m *Manifest) Apply(events []FileWatcherEvent) error {
+	var firstErr error
+	keep := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	changed := false
+	for _, e := range netEffect(events) {
+		if !m.covers(e.Path) && !m.covers(e.PreviousPath) {
+			continue
+		}
+		changed = true
+		switch e.Event {
+		case EventCreateFile, EventEditFile, EventMovedInFile:
+			keep(m.hash(e.Path))
+		case EventDeleteFile, EventMovedOutFile:
+			m.forget(e.Path, false)
+		case EventRenameFile:
+			m.forget(e.PreviousPath, false)
+			keep(m.hash(e.Path))
+		case EventCreateFolder, EventMovedInFolder:
+			keep(m.walk(e.Path, m.hash))
+		case EventDeleteFolder, EventMovedOutFolder:
+			m.forget(e.Path, true)
+		case EventRenameFolder:
+			m.forget(e.PreviousPath, true)
+			keep(m.walk(e.Path, m.hash))
+		default:
+			changed = false
+		}
+	}
+
+	if changed {
+		keep(m.save())
+	}
+	return firstErr
+}
+
+// Checksum returns the checksum of path in the manifest.
+func (m *Manifest) Checksum(path string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	hash, ok := m.hashes[m.rel(path)]
+	return hash, ok
+}
+
+// Verify hashes every file below the root again and returns the files whose checksum differs from the manifest,
+// including files missing on disk or from the manifest.
+func (m *Manifest) Verify() ([]ManifestMismatch, error) {
+	m.mu.RLock()
+	expected := make(map[string]string, len(m.hashes))
+	for rel, hash := range m.hashes {
+		expected[rel] = hash
+	}
+	m.mu.RUnlock()
+
+	var mismatches []ManifestMismatch
+	err := m.walk(m.Root, func(path string) error {
+		doc, err := describeFile(fs, path)
+		if err != nil {
+			return err
+		}
+		rel := m.rel(path)
+		if expected[rel] != doc.Hash {
+			mismatches = append(mismatches, ManifestMismatch{Path: rel, Expected: expected[rel], Actual: doc.Hash})
+		}
+		delete(expected, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for rel, hash := range expected {
+		mismatches = append(mismatches, ManifestMismatch{Path: rel, Expected: hash})
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Path < mismatches[j].Path
+	})
+	return mismatches, nil
+}
+
+// Export writes the manifest to out in the format of sha256sum, sorted by path. Paths are relative to the root and
+// use forward slashes.
+func (m *Manifest) Export(out io.Writer) error {
+	m.mu.RLock()
+	paths := make([]string, 0, len(m.hashes))
+	for rel := range m.hashes {
+		paths = append(paths, rel)
+	}
+	lines := make([]string, 0, len(paths))
+	sort.Strings(paths)
+	for _, rel := range paths {
+		lines = append(lines, m.hashes[rel]+"  "+rel+"\n")
+	}
+	m.mu.RUnlock()
+
+	w := bufio.NewWriter(out)
+	for _, line := range lines {
+		if _, err := w.WriteString(line); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// Load replaces the content of the manifest with the sha256sum formatted manifest read from in.
+func (m *Manifest) Load(in io.Reader) error {
+	hashes := make(map[string]string)
+	scanner := bufio.NewScanner(in)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		hash, rel, ok := strings.Cut(text, " ")
+		if !ok || len(hash) != 64 {
+			return fmt.Errorf("malformed manifest line %d", line)
+		}
+		// sha256sum separates the checksum with a space and a mode character, " " for text or "*" for binary
+		rel = strings.TrimPrefix(strings.TrimPrefix(rel, " "), "*")
+		hashes[rel] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.hashes = hashes
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Manifest) hash(path string) error {
+	if m.File != "" && filepath.Clean(path) == filepath.Clean(m.File) {
+		return nil
+	}
+	doc, err := describeFile(fs, path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// removed again before it could be hashed, the delete event follows
+			return nil
+		}
+		return err
+	}
+
+	m.mu.Lock()
+	m.hashes[m.rel(path)] = doc.Hash
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Manifest) forget(path string, folder bool) {
+	rel := m.rel(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !folder {
+		delete(m.hashes, rel)
+		return
+	}
+	for p := range m.hashes {
+		if strings.HasPrefix(p, rel+"/") {
+			delete(m.hashes, p)
+		}
+	}
+}
+
+func (m *Manifest) save() error {
+	if m.File == "" {
+		return nil
+	}
+	b := strings.Builder{}
+	if err := m.Export(&b); err != nil {
+		return err
+	}
+	// write next to the manifest and rename so readers never see a partial manifest
+	tmp := m.File + ".tmp"
+	if err := afero.WriteFile(fs, tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return fs.Rename(tmp, m.File)
+}
+
+func (m *Manifest) covers(path string) bool {
+	if path == "" {
+		return false
+	}
+	path = filepath.Clean(path)
+	if m.File != "" && (path == filepath.Clean(m.File) || path == filepath.Clean(m.File)+".tmp") {
+		return false
+	}
+	return isBelow(path, m.Root)
+}
+
+func (m *Manifest) rel(path string) string {
+	rel, err := filepath.Rel(m.Root, filepath.Clean(path))
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (m *Manifest) walk(root string, fn func(path string) error) error {
+	return afero.Walk(fs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !m.covers(path) {
+			return nil
+		}
+		return fn(path)
+	})
+}
@@ -0,0 +1,240 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// Matcher decides which paths something applies to, in place of the glob patterns: the ignore and include lists, see
+// AddIgnoreMatcher and AddIncludeMatcher, the watches, see AddWithMatcher, the subscriptions, see
+// SubscriptionOptions.Matcher, and the routes, see Route.Matcher. Glob, Regexp and GitIgnore return the matchers of
+// the built-in syntaxes, conventions of their own are implemented with a MatcherFunc. A Matcher implementing
+// fmt.Stringer is described by its String in the traces and in Explain.
+//
+// Match receives the native path, with the separators of the platform, and is called from the event loop.
+type Matcher interface {
+	Match(path string) bool
+}
+
+// MatcherFunc adapts a function to the Matcher interface.
+type MatcherFunc func(path string) bool
+
+func (f MatcherFunc) Match(path string) bool {
+	return f(path)
+}
+
+type globMatcher string
+
+func (m globMatcher) Match(path string) bool {
+	return matchPattern(string(m), path)
+}
+
+func (m globMatcher) String() string {
+	return string(m)
+}
+
+// Glob returns the Matcher of the glob pattern, with the syntax of AddIgnorePatterns.
+func Glob(pattern string) (Matcher, error) {
+	if err := validPattern(pattern); err != nil {
+		return nil, err
+	}
+	return globMatcher(pattern), nil
+}
+
+type regexpMatcher struct {
+	re *regexp.Regexp
+}
+
+func (m regexpMatcher) Match(path string) bool {
+	return m.re.MatchString(filepath.ToSlash(path))
+}
+
+func (m regexpMatcher) String() string {
+	return m.re.String()
+}
+
+// Regexp returns a Matcher of the paths re matches, with forward slashes on every platform.
+func Regexp(re *regexp.Regexp) Matcher {
+	return regexpMatcher{re: re}
+}
+
+type gitIgnoreMatcher struct {
+	rules ignoreFiles
+	base  string
+}
+
+func (m *gitIgnoreMatcher) Match(path string) bool {
+	_, ok := m.rules.match(path)
+	return ok
+}
+
+func (m *gitIgnoreMatcher) String() string {
+	return "the .gitignore rules of " + m.base
+}
+
+// GitIgnore returns the Matcher of the lines of a .gitignore file in the directory base, with its precedence: later
+// lines override earlier ones and "!" lines match the paths again, except the paths below a matched directory. The
+// content of the version control directories is matched too. A line ending with a slash only matches directories:
+// knowing the paths alone, the matcher only matches the paths below them.
+func GitIgnore(base string, lines ...string) (Matcher, error) {
+	base = absPath(base)
+	file := &ignoreFile{path: filepath.Join(base, ".gitignore"), base: base}
+	for i, line := range lines {
+		rule, ok, err := parseIgnoreLine(line, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern line %d: %w", i+1, err)
+		}
+		if ok {
+			rule.source = file.path
+			rule.line = i + 1
+			file.rules = append(file.rules, rule)
+		}
+	}
+	m := &gitIgnoreMatcher{base: base}
+	m.rules.files = map[string]*ignoreFile{file.path: file}
+	m.rules.ordered = []*ignoreFile{file}
+	return m, nil
+}
+
+// AnyOf returns a Matcher of the paths one of matchers matches.
+func AnyOf(matchers ...Matcher) Matcher {
+	return MatcherFunc(func(path string) bool {
+		for _, m := range matchers {
+			if m.Match(path) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Not returns a Matcher of the paths m doesn't match.
+func Not(m Matcher) Matcher {
+	return MatcherFunc(func(path string) bool {
+		return !m.Match(path)
+	})
+}
+
+// describeMatcher returns how m is described in the traces.
+func describeMatcher(m Matcher) string {
+	if s, ok := m.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", m)
+}
+
+type matcherState struct {
+	mu       sync.RWMutex
+	nextID   int
+	ignores  []registeredMatcher
+	includes []registeredMatcher
+	// watches holds the matchers of the paths added with AddWithMatcher.
+	watches map[string]Matcher
+}
+
+type registeredMatcher struct {
+	id int
+	m  Matcher
+}
+
+// AddIgnoreMatcher drops the events of every path m matches, like the exclude patterns, and returns a function
+// removing it. The directories it matches are left out of the recursive watches.
+func (w *FileWatcher) AddIgnoreMatcher(m Matcher) func() {
+	return w.registerMatcher(&w.matchers.ignores, m)
+}
+
+// AddIncludeMatcher only passes on the events of the files m, or another include matcher or pattern, matches, like
+// SetIncludePatterns, and returns a function removing it.
+func (w *FileWatcher) AddIncludeMatcher(m Matcher) func() {
+	return w.registerMatcher(&w.matchers.includes, m)
+}
+
+func (w *FileWatcher) registerMatcher(list *[]registeredMatcher, m Matcher) func() {
+	w.matchers.mu.Lock()
+	defer w.matchers.mu.Unlock()
+	id := w.matchers.nextID
+	w.matchers.nextID++
+	*list = append(*list, registeredMatcher{id: id, m: m})
+
+	return func() {
+		w.matchers.mu.Lock()
+		defer w.matchers.mu.Unlock()
+		kept := make([]registeredMatcher, 0, len(*list))
+		for _, r := range *list {
+			if r.id != id {
+				kept = append(kept, r)
+			}
+		}
+		*list = kept
+	}
+}
+
+// AddWithMatcher adds path like Add, and only passes on the events of the paths below it m matches, folders
+// included. The matcher of the closest path added with a matcher applies.
+func (w *FileWatcher) AddWithMatcher(path string, m Matcher) error {
+	path = w.normalize(path)
+	if err := w.Add(path); err != nil {
+		return err
+	}
+	w.matchers.mu.Lock()
+	defer w.matchers.mu.Unlock()
+	if w.matchers.watches == nil {
+		w.matchers.watches = make(map[string]Matcher)
+	}
+	w.matchers.watches[path] = m
+	return nil
+}
+
+// forgetMatcher drops the matcher of path, once it is removed.
+func (w *FileWatcher) forgetMatcher(path string) {
+	w.matchers.mu.Lock()
+	defer w.matchers.mu.Unlock()
+	delete(w.matchers.watches, path)
+}
+
+// ignoringMatcher returns the ignore matcher matching path.
+func (w *FileWatcher) ignoringMatcher(path string) (Matcher, bool) {
+	w.matchers.mu.RLock()
+	defer w.matchers.mu.RUnlock()
+	for _, r := range w.matchers.ignores {
+		if r.m.Match(path) {
+			return r.m, true
+		}
+	}
+	return nil, false
+}
+
+// includeMatched reports whether there are include matchers, and whether one of them matches path.
+func (w *FileWatcher) includeMatched(path string) (bool, bool) {
+	w.matchers.mu.RLock()
+	defer w.matchers.mu.RUnlock()
+	for _, r := range w.matchers.includes {
+		if r.m.Match(path) {
+			return true, true
+		}
+	}
+	return len(w.matchers.includes) > 0, false
+}
+
+// watchMatcher returns the matcher of the closest watch above path added with AddWithMatcher, and the path of the
+// watch.
+func (w *FileWatcher) watchMatcher(path string) (Matcher, string, bool) {
+	w.matchers.mu.RLock()
+	defer w.matchers.mu.RUnlock()
+	if len(w.matchers.watches) == 0 {
+		return nil, "", false
+	}
+	// the watched path itself is not subject to its matcher
+	for current := filepath.Dir(path); ; {
+		if m, ok := w.matchers.watches[current]; ok {
+			return m, current, true
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return nil, "", false
+		}
+		current = parent
+	}
+}
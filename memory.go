@@ -0,0 +1,158 @@
+package fileWatcher
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// MemoryPolicy decides what happens to an event that would have to be buffered beyond the memory budget.
+type MemoryPolicy int
+
+const (
+	// MemoryPolicyFlush delivers the buffered events right away to free memory, and the event itself unbuffered.
+	MemoryPolicyFlush MemoryPolicy = iota
+	// MemoryPolicyDrop drops the event.
+	MemoryPolicyDrop
+	// MemoryPolicySpill hands the event to the Spill sink of the budget, for example a journal on disk.
+	MemoryPolicySpill
+)
+
+// MemoryBudget limits the memory used by the internal event buffers: coalescing windows and change sets.
+type MemoryBudget struct {
+	// Limit is the budget in bytes, zero means no limit.
+	Limit  int64
+	Policy MemoryPolicy
+	// Spill receives the events over budget with MemoryPolicySpill.
+	Spill Sink
+}
+
+// MemoryStats is the memory accounting of a FileWatcher. Sizes are estimates in bytes.
+type MemoryStats struct {
+	Budget int64
+	// Buffered is held by coalescing windows and change sets, it is what the budget is enforced on.
+	Buffered  int64
+	StatCache int64
+	Listings  int64
+	// Dropped and Spilled count the events that went over budget.
+	Dropped uint64
+	Spilled uint64
+}
+
+// Stats is a snapshot of the internal state of a FileWatcher.
+type Stats struct {
+	// Watched is the number of watched paths.
+	Watched int
+	Events  EventStats
+	Memory  MemoryStats
+	Latency LatencyStats
+	// Shedding is set while the watcher sheds load, see LoadSheddingOptions.
+	Shedding bool
+	Runtime  RuntimeStats
+	// State is the correlation state kept about the paths, see StateGCOptions.
+	State StateGCStats
+	// Recreations counts the notifiers re-created after they died, see Healthy.
+	Recreations uint64
+	Polling     PollStats
+	// Fingerprints is the use of the fingerprint cache, see Fingerprint.
+	Fingerprints FingerprintStats
+}
+
+type memoryState struct {
+	buffered int64
+	dropped  uint64
+	spilled  uint64
+}
+
+// Stats returns a snapshot of the internal state of the watcher. Counting the goroutines of the watcher for Runtime
+// briefly stops the world, like taking a goroutine profile, so it should not be called more than every few seconds.
+func (w *FileWatcher) Stats() Stats {
+	return Stats{
+		Watched:  w.Len(),
+		Events:   w.eventStats(),
+		Memory:   w.memoryStats(),
+		Latency:  w.latencyStats(),
+		Shedding: w.Shedding(),
+		Runtime:  w.runtimeStats(),
+		State:    w.stateGCStats(),
+
+		Recreations:  atomic.LoadUint64(&w.health.recreations),
+		Polling:      w.pollStats(),
+		Fingerprints: w.fingerprintStats(),
+	}
+}
+
+func (w *FileWatcher) memoryStats() MemoryStats {
+	res := MemoryStats{
+		Budget:   w.options.MemoryBudget.Limit,
+		Buffered: atomic.LoadInt64(&w.memory.buffered),
+		Dropped:  atomic.LoadUint64(&w.memory.dropped),
+		Spilled:  atomic.LoadUint64(&w.memory.spilled),
+	}
+
+	w.statCache.mu.Lock()
+	for path := range w.statCache.entries {
+		// the file info behind the interface is about the size of an event
+		res.StatCache += int64(len(path)) + int64(unsafe.Sizeof(statEntry{})) + int64(unsafe.Sizeof(FileWatcherEvent{}))
+	}
+	w.statCache.mu.Unlock()
+
+	w.listings.mu.Lock()
+	for dir, listing := range w.listings.dirs {
+		res.Listings += int64(len(dir))
+		for name := range listing {
+			res.Listings += int64(len(name)) + int64(unsafe.Sizeof(listingEntry{})) + 16
+		}
+	}
+	w.listings.mu.Unlock()
+	return res
+}
+
+// eventSize estimates the memory held by a buffered event.
+func eventSize(e FileWatcherEvent) int64 {
+	return int64(unsafe.Sizeof(e)) + int64(len(e.Path)+len(e.PreviousPath))
+}
+
+// reserve accounts for e being buffered and reports whether it fits in the budget.
+func (w *FileWatcher) reserve(e FileWatcherEvent) bool {
+	size := eventSize(e)
+	limit := w.options.MemoryBudget.Limit
+	if limit > 0 && atomic.LoadInt64(&w.memory.buffered)+size > limit {
+		return false
+	}
+	atomic.AddInt64(&w.memory.buffered, size)
+	return true
+}
+
+// release accounts for buffered events being let go.
+func (w *FileWatcher) release(events []FileWatcherEvent) {
+	var size int64
+	for _, e := range events {
+		size += eventSize(e)
+	}
+	atomic.AddInt64(&w.memory.buffered, -size)
+}
+
+// overBudget applies the drop and spill policies to an event that did not fit in the budget. It reports whether the
+// event was dealt with, which is not the case with MemoryPolicyFlush.
+func (w *FileWatcher) overBudget(e FileWatcherEvent) bool {
+	budget := w.options.MemoryBudget
+	switch budget.Policy {
+	case MemoryPolicyDrop:
+		w.traceEvent(e, "dropped, memory budget exceeded")
+		atomic.AddUint64(&w.memory.dropped, 1)
+		w.instrumentEvent(TraceDropped, TraceLevelWarn, e, "memory budget exceeded")
+		w.log.Warn("Memory budget exceeded, dropping event for ", e.Path)
+		return true
+	case MemoryPolicySpill:
+		if budget.Spill != nil {
+			if err := budget.Spill.Send(e); err != nil {
+				w.log.Error("Unable to spill event for ", e.Path, ": ", err)
+				atomic.AddUint64(&w.memory.dropped, 1)
+			} else {
+				atomic.AddUint64(&w.memory.spilled, 1)
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package fileWatcher
+
+import "sync"
+
+// MergedEvent is an event of a MergedWatcher with the watcher it comes from.
+type MergedEvent struct {
+	FileWatcherEvent
+	// Source is the position of the watcher in the arguments of Merge.
+	Source  int
+	Watcher *FileWatcher
+}
+
+// SourceError is an error of a watcher of a MergedWatcher.
+type SourceError struct {
+	Source  int
+	Watcher *FileWatcher
+	Err     error
+}
+
+func (e *SourceError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// MergedWatcher is a single Events, Errors and Subscribe surface over several watchers, like watchers with different
+// backends or watching different hosts, see Merge.
+type MergedWatcher struct {
+	// Events receives the events of every watcher, in order for each watcher.
+	Events chan MergedEvent
+	// Errors receives the errors of every watcher, as SourceError.
+	Errors chan error
+
+	sources []*FileWatcher
+	// stops end the subscriptions and the error handlers reading the watchers.
+	stops   []func()
+	once    sync.Once
+	closing chan struct{}
+	// done is closed once Events and Errors are closed.
+	done chan struct{}
+}
+
+// Merge returns a MergedWatcher delivering the events and the errors of watchers. It receives the events through a
+// subscription, see Subscribe, and reads the errors with handlers, see OnError: Errors of the watchers must not be read
+// anymore, nor Events once another handler is registered. Events and Errors of the merged watcher are closed once every
+// watcher is closed, or by Close.
+func Merge(watchers ...*FileWatcher) *MergedWatcher {
+	m := &MergedWatcher{
+		Events:  make(chan MergedEvent),
+		Errors:  make(chan error),
+		sources: watchers,
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	forwarders := sync.WaitGroup{}
+	for i, w := range watchers {
+		i, w := i, w
+		unregister := w.OnError(func(err error) {
+			select {
+			case m.Errors <- &SourceError{Source: i, Watcher: w, Err: err}:
+			case <-m.closing:
+			}
+		})
+		// the subscription is closed once the handlers of the watcher are done, see FileWatcher.closeSubscriptions
+		events, unsubscribe := w.subscribe("", true, SubscriptionOptions{})
+		m.stops = append(m.stops, unregister, unsubscribe)
+		forwarders.Add(1)
+		go func() {
+			w.labelGoroutine("merge")
+			defer forwarders.Done()
+			for e := range events {
+				select {
+				case m.Events <- MergedEvent{FileWatcherEvent: e, Source: i, Watcher: w}:
+				case <-m.closing:
+				}
+			}
+		}()
+	}
+	go func() {
+		forwarders.Wait()
+		close(m.Events)
+		close(m.Errors)
+		close(m.done)
+	}()
+	return m
+}
+
+// Watchers returns the watchers of m, in the order given to Merge.
+func (m *MergedWatcher) Watchers() []*FileWatcher {
+	return append([]*FileWatcher{}, m.sources...)
+}
+
+// Subscribe returns a channel receiving the events of path and of everything below it from every watcher, and a
+// function ending the subscription and closing the channel, see FileWatcher.Subscribe.
+func (m *MergedWatcher) Subscribe(path string) (<-chan MergedEvent, func()) {
+	return m.SubscribeWithOptions(path, SubscriptionOptions{})
+}
+
+// SubscribeWithOptions subscribes to path like Subscribe, with the given options for the subscription to each watcher.
+// The channel is closed once the subscriptions to every watcher are closed.
+func (m *MergedWatcher) SubscribeWithOptions(path string, options SubscriptionOptions) (<-chan MergedEvent, func()) {
+	res := make(chan MergedEvent)
+	stop := make(chan struct{})
+	unsubscribes := make([]func(), 0, len(m.sources))
+	forwarders := sync.WaitGroup{}
+	for i, w := range m.sources {
+		i, w := i, w
+		events, unsubscribe := w.SubscribeWithOptions(path, options)
+		unsubscribes = append(unsubscribes, unsubscribe)
+		forwarders.Add(1)
+		go func() {
+			w.labelGoroutine("merge")
+			defer forwarders.Done()
+			for e := range events {
+				select {
+				case res <- MergedEvent{FileWatcherEvent: e, Source: i, Watcher: w}:
+				case <-stop:
+				}
+			}
+		}()
+	}
+	go func() {
+		forwarders.Wait()
+		close(res)
+	}()
+
+	once := sync.Once{}
+	return res, func() {
+		once.Do(func() {
+			close(stop)
+			for _, unsubscribe := range unsubscribes {
+				unsubscribe()
+			}
+		})
+	}
+}
+
+// Close stops reading the watchers and closes Events and Errors. The events not received yet are dropped. The
+// watchers are not closed.
+func (m *MergedWatcher) Close() error {
+	m.once.Do(func() {
+		close(m.closing)
+		for _, stop := range m.stops {
+			stop()
+		}
+	})
+	<-m.done
+	return nil
+}
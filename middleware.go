@@ -0,0 +1,85 @@
+package fileWatcher
+
+import "sync"
+
+// Middleware transforms, enriches or filters the events before they are delivered, see Use. It passes the event to
+// next, changed or not, to hand it on to the following middleware, and eventually to the delivery. Not calling next
+// drops the event, calling it several times emits several events.
+type Middleware func(e FileWatcherEvent, next func(FileWatcherEvent))
+
+type middlewareState struct {
+	mu     sync.RWMutex
+	nextID int
+	chain  []registeredMiddleware
+}
+
+type registeredMiddleware struct {
+	id int
+	m  Middleware
+}
+
+// Use adds m to the middlewares and returns a function removing it. The middlewares run in the order they were added,
+// in the event loop, on every event once it is classified and before it is numbered, stamped and delivered: they see
+// the events of every path and delay the classification of the next ones. The tracking of the watched folders and of
+// their listings works on the events before the middlewares.
+//
+// A middleware must call next before returning, the events handed to next later are dropped. The Tags of an event may
+// be shared with other events: set a new map rather than changing it.
+func (w *FileWatcher) Use(m Middleware) func() {
+	w.middlewares.mu.Lock()
+	defer w.middlewares.mu.Unlock()
+	id := w.middlewares.nextID
+	w.middlewares.nextID++
+	w.middlewares.chain = append(w.middlewares.chain, registeredMiddleware{id: id, m: m})
+
+	return func() {
+		w.middlewares.mu.Lock()
+		defer w.middlewares.mu.Unlock()
+		chain := make([]registeredMiddleware, 0, len(w.middlewares.chain))
+		for _, r := range w.middlewares.chain {
+			if r.id != id {
+				chain = append(chain, r)
+			}
+		}
+		w.middlewares.chain = chain
+	}
+}
+
+// runMiddlewares passes e through the middlewares, then hands what comes out of them to last.
+func (w *FileWatcher) runMiddlewares(e FileWatcherEvent, last func(FileWatcherEvent)) {
+	w.middlewares.mu.RLock()
+	chain := w.middlewares.chain
+	w.middlewares.mu.RUnlock()
+	if len(chain) == 0 {
+		last(e)
+		return
+	}
+
+	// mu keeps the events handed on from another goroutine after the chain returned out of the delivery
+	mu := sync.Mutex{}
+	returned := false
+	passed := 0
+	call := func(e FileWatcherEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if returned {
+			w.trace(e.Path, "%s passed on by a middleware after it returned, dropped", e.Event)
+			return
+		}
+		passed++
+		last(e)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		m, next := chain[i].m, call
+		call = func(e FileWatcherEvent) {
+			m(e, next)
+		}
+	}
+	call(e)
+	mu.Lock()
+	returned = true
+	mu.Unlock()
+	if passed == 0 {
+		w.trace(e.Path, "%s dropped by a middleware", e.Event)
+	}
+}
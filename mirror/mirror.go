@@ -0,0 +1,537 @@
+// Package mirror keeps a destination directory in sync with a source tree watched by a fileWatcher.FileWatcher: it
+// copies the tree once, then applies the events of the watcher to the destination as they come. The destination is
+// an afero.Fs, so it can be a local directory, memory or a remote filesystem.
+//
+//	m, err := mirror.New(w, "/srv/data", mirror.Options{Destination: afero.NewBasePathFs(afero.NewOsFs(), "/backup")})
+//	if err != nil {
+//		return err
+//	}
+//	err = m.Start()
+//	defer m.Stop()
+package mirror
+
+import (
+	"errors"
+	"fmt"
+	"github.com/flightx31/fileWatcher"
+	"github.com/spf13/afero"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConflictPolicy is what a Mirror does with a destination entry changed by someone else since it last wrote it.
+type ConflictPolicy int
+
+const (
+	// ConflictOverwrite replaces the destination entry with the source, the default: the source wins.
+	ConflictOverwrite ConflictPolicy = iota
+	// ConflictKeep leaves the destination entry as it is, until Sync is called.
+	ConflictKeep
+	// ConflictBackup renames the destination entry aside, with a ".conflict-" suffix and the time, before replacing it
+	// with the source.
+	ConflictBackup
+)
+
+// createEvents are the events of the source putting an item at a path, which conflicts with an entry of the
+// destination the Mirror did not write.
+const createEvents = fileWatcher.EventCreateFile | fileWatcher.EventMovedInFile | fileWatcher.EventRenameFile |
+	fileWatcher.EventRenameFolder
+
+// Conflict is a destination entry changed by someone else since the Mirror last wrote it.
+type Conflict struct {
+	// Path is relative to the roots.
+	Path string
+	// Event is the source event the conflict was found applying, EDIT_FILE or DELETE_FILE for Sync.
+	Event fileWatcher.EventKind
+	// Backup is where the destination entry was moved with ConflictBackup.
+	Backup string
+}
+
+// Error is a source event a Mirror was unable to apply. The path is applied again by the next Sync.
+type Error struct {
+	// Path is relative to the roots.
+	Path string
+	// Event is zero for the errors of the initial copy.
+	Event fileWatcher.EventKind
+	Err   error
+}
+
+func (e *Error) Error() string {
+	if e.Event == 0 {
+		return "unable to mirror " + e.Path + ": " + e.Err.Error()
+	}
+	return "unable to mirror " + e.Event.String() + " of " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Options configures a Mirror.
+type Options struct {
+	// Source is the filesystem of the source tree. It defaults to the operating system filesystem.
+	Source afero.Fs
+	// Destination is the filesystem the tree is mirrored to, at its root, see afero.NewBasePathFs to mirror to a
+	// folder of it.
+	Destination afero.Fs
+	// KeepDeleted leaves the entries deleted from the source in the destination.
+	KeepDeleted bool
+	// Skip excludes the paths it returns true for, relative to the roots, from the mirror.
+	Skip      func(path string) bool
+	Conflicts ConflictPolicy
+	// OnConflict is called for every conflict found.
+	OnConflict func(c Conflict)
+	// OnError is called with an *Error for every event that could not be applied.
+	OnError func(err error)
+}
+
+// Stats counts the operations of a Mirror.
+type Stats struct {
+	Copied    uint64
+	Removed   uint64
+	Renamed   uint64
+	Conflicts uint64
+	Errors    uint64
+}
+
+// entryState is the state of a destination file as the Mirror left it, to tell whether someone else changed it.
+type entryState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Mirror keeps Options.Destination in sync with a source tree.
+type Mirror struct {
+	w       *fileWatcher.FileWatcher
+	root    string
+	options Options
+
+	// mu serializes the operations on the destination.
+	mu      sync.Mutex
+	written map[string]entryState
+	stats   Stats
+
+	queueMu sync.Mutex
+	queue   []fileWatcher.FileWatcherEvent
+	wake    chan struct{}
+
+	unsubscribe func()
+	added       bool
+	done        chan struct{}
+}
+
+// New creates a Mirror of the tree at root, watched by w, to options.Destination.
+func New(w *fileWatcher.FileWatcher, root string, options Options) (*Mirror, error) {
+	if options.Destination == nil {
+		return nil, errors.New("mirror: no destination")
+	}
+	if options.Source == nil {
+		options.Source = afero.NewOsFs()
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Mirror{
+		w:       w,
+		root:    root,
+		options: options,
+		written: make(map[string]entryState),
+		wake:    make(chan struct{}, 1),
+	}, nil
+}
+
+// Start watches the source tree recursively, unless it is watched already, copies it to the destination and applies
+// its events from then on. The events received during the initial copy are applied once it is over. The entries the
+// initial copy failed for are reported to OnError. The events are received through a subscription, see
+// FileWatcher.Subscribe: the application keeps reading Events of the watcher, which still carries them.
+func (m *Mirror) Start() error {
+	if !m.w.Contains(m.root) {
+		if err := m.w.AddRecursive(m.root); err != nil {
+			return err
+		}
+		m.added = true
+	}
+	events, unsubscribe := m.w.Subscribe(m.root)
+	m.unsubscribe = unsubscribe
+	m.done = make(chan struct{})
+	go m.receive(events)
+
+	if err := m.Sync(); err != nil {
+		m.mu.Lock()
+		m.fail(".", 0, err)
+		m.mu.Unlock()
+	}
+	go m.apply()
+	return nil
+}
+
+// Stop stops applying the events and waits for the event being applied.
+func (m *Mirror) Stop() {
+	if m.unsubscribe == nil {
+		return
+	}
+	m.unsubscribe()
+	<-m.done
+	if m.added {
+		_ = m.w.Remove(m.root)
+	}
+	m.unsubscribe = nil
+}
+
+// Stats returns the operations counted since the Mirror was created.
+func (m *Mirror) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// receive queues the events, so a slow destination doesn't hold back the watcher.
+func (m *Mirror) receive(events <-chan fileWatcher.FileWatcherEvent) {
+	for e := range events {
+		m.queueMu.Lock()
+		m.queue = append(m.queue, e)
+		m.queueMu.Unlock()
+		select {
+		case m.wake <- struct{}{}:
+		default:
+		}
+	}
+	close(m.wake)
+}
+
+// apply applies the queued events in order until the subscription ends.
+func (m *Mirror) apply() {
+	defer close(m.done)
+	for {
+		m.queueMu.Lock()
+		queue := m.queue
+		m.queue = nil
+		m.queueMu.Unlock()
+		for _, e := range queue {
+			m.Apply(e)
+		}
+		if _, ok := <-m.wake; !ok {
+			return
+		}
+	}
+}
+
+// Sync reconciles the whole destination with the source: copies what is missing or differs, removes what is not in
+// the source anymore unless KeepDeleted is set. It also retries the paths that failed to be applied.
+func (m *Mirror) Sync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.syncPath("")
+}
+
+// Apply applies a single event of the source tree to the destination. Start applies the events of the watcher by
+// itself, Apply is for the applications feeding the events some other way, like from a journal.
+func (m *Mirror) Apply(e fileWatcher.FileWatcherEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rel, inside := m.rel(e.Path)
+	previous, previousInside := m.rel(e.PreviousPath)
+	var err error
+	switch {
+	case e.Event.Is(fileWatcher.EventRenameFile | fileWatcher.EventRenameFolder):
+		switch {
+		case inside && previousInside:
+			err = m.rename(previous, rel, e.Event)
+		case inside:
+			err = m.syncPath(rel)
+		case previousInside:
+			err = m.remove(previous, e.Event)
+		}
+	case !inside:
+		return
+	case e.Event.Is(fileWatcher.EventCreateFile | fileWatcher.EventEditFile | fileWatcher.EventMovedInFile |
+		fileWatcher.EventChMod | fileWatcher.EventDownloadCompleted | fileWatcher.EventFileReady):
+		err = m.copyFile(rel, e.Event)
+	case e.Event.Is(fileWatcher.EventCreateFolder | fileWatcher.EventMovedInFolder |
+		fileWatcher.EventExtractionCompleted | fileWatcher.EventOverflow | fileWatcher.EventCatchUp |
+		fileWatcher.EventWatchReestablished | fileWatcher.EventResyncRequired | fileWatcher.EventBulkFinished):
+		// the content of the folder may predate its watch, or events of it were lost
+		err = m.syncPath(rel)
+	case e.Event.Is(fileWatcher.EventDeleteFile | fileWatcher.EventDeleteFolder | fileWatcher.EventMovedOutFile |
+		fileWatcher.EventMovedOutFolder):
+		err = m.remove(rel, e.Event)
+	}
+
+	if err != nil {
+		m.fail(rel, e.Event, err)
+	}
+}
+
+// fail reports the error of applying event to rel. The caller holds the lock.
+func (m *Mirror) fail(rel string, event fileWatcher.EventKind, err error) {
+	m.stats.Errors++
+	if m.options.OnError != nil {
+		m.options.OnError(&Error{Path: rel, Event: event, Err: err})
+	}
+}
+
+// rel returns path relative to the source root, and whether it is inside the tree and not skipped.
+func (m *Mirror) rel(path string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(m.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if rel == "." {
+		rel = ""
+	}
+	if rel != "" && m.options.Skip != nil && m.options.Skip(rel) {
+		return "", false
+	}
+	return rel, true
+}
+
+// source returns the path of rel in the source tree.
+func (m *Mirror) source(rel string) string {
+	return filepath.Join(m.root, rel)
+}
+
+// destination returns the path of rel in the destination.
+func (m *Mirror) destination(rel string) string {
+	return filepath.Join(string(filepath.Separator), rel)
+}
+
+// conflicts reports whether the destination entry rel was changed by someone else since the Mirror last wrote it,
+// and makes room for the source with ConflictBackup. It reports whether the operation should go on.
+func (m *Mirror) conflicts(rel string, event fileWatcher.EventKind) (bool, error) {
+	info, err := m.options.Destination.Stat(m.destination(rel))
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.IsDir() {
+		return true, nil
+	}
+	written, ok := m.written[rel]
+	if ok && written.size == info.Size() && written.modTime.Equal(info.ModTime()) {
+		return true, nil
+	}
+	if !ok && !event.Is(createEvents) {
+		// an entry predating the mirror is only in the way of the items created in the source
+		return true, nil
+	}
+
+	m.stats.Conflicts++
+	conflict := Conflict{Path: rel, Event: event}
+	proceed := true
+	switch m.options.Conflicts {
+	case ConflictKeep:
+		proceed = false
+	case ConflictBackup:
+		conflict.Backup = rel + ".conflict-" + strconv.FormatInt(time.Now().Unix(), 10)
+		if err := m.options.Destination.Rename(m.destination(rel), m.destination(conflict.Backup)); err != nil {
+			return false, err
+		}
+	}
+	if m.options.OnConflict != nil {
+		m.options.OnConflict(conflict)
+	}
+	return proceed, nil
+}
+
+// copyFile copies the source file rel to the destination, through a temporary file renamed in place so the readers
+// of the destination never see a partial file.
+func (m *Mirror) copyFile(rel string, event fileWatcher.EventKind) error {
+	info, err := m.options.Source.Stat(m.source(rel))
+	if errors.Is(err, os.ErrNotExist) {
+		// deleted since, its delete event follows
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return m.syncPath(rel)
+	}
+	if proceed, err := m.conflicts(rel, event); err != nil || !proceed {
+		return err
+	}
+
+	in, err := m.options.Source.Open(m.source(rel))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	target := m.destination(rel)
+	if err := m.options.Destination.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(target), ".mirror-"+filepath.Base(target))
+	out, err := m.options.Destination.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = m.options.Destination.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = m.options.Destination.Remove(tmp)
+		return err
+	}
+	_ = m.options.Destination.Chmod(tmp, info.Mode().Perm())
+	_ = m.options.Destination.Chtimes(tmp, info.ModTime(), info.ModTime())
+	if err := m.options.Destination.Rename(tmp, target); err != nil {
+		_ = m.options.Destination.Remove(tmp)
+		return err
+	}
+
+	m.stats.Copied++
+	m.record(rel)
+	return nil
+}
+
+// record remembers the state of the destination file rel the Mirror just wrote.
+func (m *Mirror) record(rel string) {
+	if info, err := m.options.Destination.Stat(m.destination(rel)); err == nil && !info.IsDir() {
+		m.written[rel] = entryState{size: info.Size(), modTime: info.ModTime()}
+	}
+}
+
+// remove removes rel from the destination, unless KeepDeleted is set.
+func (m *Mirror) remove(rel string, event fileWatcher.EventKind) error {
+	if m.options.KeepDeleted || rel == "" {
+		return nil
+	}
+	if proceed, err := m.conflicts(rel, event); err != nil || !proceed {
+		return err
+	}
+	if _, err := m.options.Destination.Stat(m.destination(rel)); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := m.options.Destination.RemoveAll(m.destination(rel)); err != nil {
+		return err
+	}
+	m.stats.Removed++
+	m.forget(rel)
+	return nil
+}
+
+// forget drops the states of rel and of everything below it.
+func (m *Mirror) forget(rel string) {
+	for path := range m.written {
+		if path == rel || strings.HasPrefix(path, rel+string(filepath.Separator)) {
+			delete(m.written, path)
+		}
+	}
+}
+
+// rename renames previous to rel in the destination, or copies rel when previous is not there.
+func (m *Mirror) rename(previous string, rel string, event fileWatcher.EventKind) error {
+	if _, err := m.options.Destination.Stat(m.destination(previous)); err != nil {
+		return m.syncPath(rel)
+	}
+	if proceed, err := m.conflicts(rel, event); err != nil || !proceed {
+		return err
+	}
+	target := m.destination(rel)
+	if err := m.options.Destination.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := m.options.Destination.Rename(m.destination(previous), target); err != nil {
+		return err
+	}
+	m.stats.Renamed++
+
+	for path, state := range m.written {
+		if path == previous || strings.HasPrefix(path, previous+string(filepath.Separator)) {
+			delete(m.written, path)
+			m.written[rel+path[len(previous):]] = state
+		}
+	}
+	// the source may have changed since the rename
+	return m.syncPath(rel)
+}
+
+// syncPath reconciles the destination entry rel, and everything below it for a folder, with the source.
+func (m *Mirror) syncPath(rel string) error {
+	info, err := m.options.Source.Stat(m.source(rel))
+	if errors.Is(err, os.ErrNotExist) {
+		return m.remove(rel, fileWatcher.EventDeleteFile)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if m.same(rel, info) {
+			m.record(rel)
+			return nil
+		}
+		return m.copyFile(rel, fileWatcher.EventEditFile)
+	}
+
+	if existing, err := m.options.Destination.Stat(m.destination(rel)); err == nil && !existing.IsDir() {
+		if err := m.remove(rel, fileWatcher.EventDeleteFile); err != nil {
+			return err
+		}
+	}
+	if err := m.options.Destination.MkdirAll(m.destination(rel), info.Mode().Perm()|0700); err != nil {
+		return err
+	}
+	entries, err := afero.ReadDir(m.options.Source, m.source(rel))
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(entries))
+	var errs []string
+	for _, entry := range entries {
+		child, ok := m.rel(filepath.Join(m.source(rel), entry.Name()))
+		if !ok {
+			continue
+		}
+		names[entry.Name()] = true
+		if err := m.syncPath(child); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if !m.options.KeepDeleted {
+		existing, err := afero.ReadDir(m.options.Destination, m.destination(rel))
+		if err != nil {
+			return err
+		}
+		for _, entry := range existing {
+			if names[entry.Name()] || strings.HasPrefix(entry.Name(), ".mirror-") {
+				continue
+			}
+			child := filepath.Join(rel, entry.Name())
+			if m.options.Skip != nil && m.options.Skip(child) {
+				continue
+			}
+			if err := m.remove(child, fileWatcher.EventDeleteFile); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d entries failed: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// same reports whether the destination file rel has the size and modification time of the source.
+func (m *Mirror) same(rel string, source os.FileInfo) bool {
+	info, err := m.options.Destination.Stat(m.destination(rel))
+	return err == nil && !info.IsDir() && info.Size() == source.Size() && info.ModTime().Equal(source.ModTime())
+}
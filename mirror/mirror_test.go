@@ -0,0 +1,66 @@
+package mirror
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flightx31/fileWatcher"
+	"github.com/spf13/afero"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Panic(args ...interface{}) {}
+func (nopLogger) Error(args ...interface{}) {}
+func (nopLogger) Warn(args ...interface{})  {}
+func (nopLogger) Info(args ...interface{})  {}
+func (nopLogger) Debug(args ...interface{}) {}
+func (nopLogger) Trace(args ...interface{}) {}
+func (nopLogger) Print(args ...interface{}) {}
+
+func TestMirrorLeavesEvents(t *testing.T) {
+	source := t.TempDir()
+	w, err := fileWatcher.Init(nil, afero.NewOsFs(), nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		go func() {
+			for range w.Events {
+			}
+		}()
+		_ = w.Close()
+	}()
+	destination := afero.NewMemMapFs()
+	m, err := New(w, source, Options{Destination: destination})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	path := filepath.Join(source, "file")
+	if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the application reads Events meanwhile, and gets the event the mirror applies
+	timeout := time.After(5 * time.Second)
+	seen := false
+	for {
+		mirrored, _ := afero.Exists(destination, filepath.Join(string(filepath.Separator), "file"))
+		if seen && mirrored {
+			return
+		}
+		select {
+		case e := <-w.Events:
+			seen = seen || e.Path == path
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("event received on Events %v, mirrored %v", seen, mirrored)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package fileWatcher
+
+import (
+	"os"
+	"time"
+)
+
+// movedInAge is how long before it appeared an item reported as created must have been created to be moved in
+// instead. A move keeps the creation time of the item, a create or a copy sets it to the current time. Moves across
+// filesystems are copies, they are reported as creates, and so are the items moved in within movedInAge of being
+// created, see EventMovedInFile.
+const movedInAge = 2 * time.Second
+
+// classifyMoveIn turns the create e of an item created well before it appeared into a move into the watched tree.
+func classifyMoveIn(e FileWatcherEvent, info os.FileInfo) FileWatcherEvent {
+	if !e.Event.Is(EventCreateFile | EventCreateFolder) {
+		return e
+	}
+	born, ok := birthTime(e.Path, info)
+	if !ok || time.Since(born) < movedInAge {
+		return e
+	}
+	if e.Event == EventCreateFolder {
+		e.Event = EventMovedInFolder
+	} else {
+		e.Event = EventMovedInFile
+	}
+	return e
+}
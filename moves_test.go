@@ -0,0 +1,59 @@
+package fileWatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMoveInClassification(t *testing.T) {
+	outside, watched := t.TempDir(), t.TempDir()
+	old, fresh := filepath.Join(outside, "old"), filepath.Join(outside, "fresh")
+	writeFile(t, old, "")
+	info, err := os.Stat(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := birthTime(old, info); !ok {
+		t.Skip("the filesystem does not record the creation time of the files")
+	}
+	time.Sleep(movedInAge + 100*time.Millisecond)
+	writeFile(t, fresh, "")
+
+	w := newTestWatcher(t)
+	if err := w.Add(watched); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(old, filepath.Join(watched, "old")); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, func(e FileWatcherEvent) bool {
+		return e.Event == EventMovedInFile && e.Path == filepath.Join(watched, "old")
+	})
+	// created within movedInAge, it cannot be told from a create
+	if err := os.Rename(fresh, filepath.Join(watched, "fresh")); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, func(e FileWatcherEvent) bool {
+		return e.Event == EventCreateFile && e.Path == filepath.Join(watched, "fresh")
+	})
+}
+
+func TestMoveOutClassification(t *testing.T) {
+	watched, outside := t.TempDir(), t.TempDir()
+	file := filepath.Join(watched, "file")
+	writeFile(t, file, "")
+	w := newTestWatcher(t)
+	if err := w.Add(watched); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Rename(file, filepath.Join(outside, "file")); err != nil {
+		t.Fatal(err)
+	}
+	e := waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Path == file })
+	if e.Event != EventMovedOutFile || e.PreviousPath != "" {
+		t.Fatalf("got %s %s from %q, want MOVED_OUT_FILE", e.Event, e.Path, e.PreviousPath)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || windows
+
+package fileWatcher
+
+import "github.com/fsnotify/fsnotify"
+
+// defaultBackend is the backend used when the Backend option is not set.
+var defaultBackend Backend = NotifyBackend{}
+
+func newNotifier() (*fsnotify.Watcher, error) {
+	return fsnotify.NewWatcher()
+}
+
+// notifications returns the raw events and errors of the notifier.
+func notifications(n *fsnotify.Watcher) (<-chan fsnotify.Event, <-chan error) {
+	return n.Events, n.Errors
+}
@@ -0,0 +1,18 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+
+package fileWatcher
+
+import "github.com/fsnotify/fsnotify"
+
+// fsnotify has no backend on this platform, like wasip1 or js/wasm, every path is polled.
+var defaultBackend Backend = PollingBackend{}
+
+// newNotifier returns the inert watcher of fsnotify, so Watcher is usable but never reports anything.
+func newNotifier() (*fsnotify.Watcher, error) {
+	return &fsnotify.Watcher{}, nil
+}
+
+// notifications returns nil channels, the event loop only gets the events of the poller.
+func notifications(*fsnotify.Watcher) (<-chan fsnotify.Event, <-chan error) {
+	return nil, nil
+}
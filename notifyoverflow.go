@@ -0,0 +1,83 @@
+package fileWatcher
+
+import (
+	"errors"
+	"github.com/fsnotify/fsnotify"
+	"path/filepath"
+	"runtime"
+)
+
+// notifyOverflowError reports that the changes of dir overflowed the notification buffer and were lost.
+type notifyOverflowError struct {
+	dir string
+}
+
+func (e *notifyOverflowError) Error() string {
+	return "notification buffer overflow in " + e.dir
+}
+
+// notifyError handles an error of the notifier: overflows are recovered from by rescanning the affected directories.
+// Every error is delivered on Errors as a WatchError.
+func (w *FileWatcher) notifyError(err error) {
+	w.recordError(err)
+	overflow := &notifyOverflowError{}
+	switch {
+	case errors.As(err, &overflow):
+		w.watchError(ErrOverflow, overflow.dir, err)
+		w.recoverOverflow(overflow.dir)
+	case errors.Is(err, fsnotify.ErrEventOverflow),
+		runtime.GOOS == "windows" && err.Error() == "short read in readEvents()":
+		// the queue of inotify, or the buffer of a directory with fsnotify on Windows, overflowed and which
+		// directories missed changes is unknown
+		w.log.Warn("Notification queue overflow, rescanning every watched directory")
+		w.watchError(ErrOverflow, "", err)
+		for _, dir := range w.listings.listed() {
+			w.recoverOverflow(dir)
+		}
+	default:
+		w.watchError(ErrNotifier, "", err)
+	}
+}
+
+// recoverOverflow rescans dir after its changes were lost. The entries created or deleted meanwhile are reported with
+// CREATE and DELETE events, then an OVERFLOW event with the path of dir tells the consumers that the edits made in it
+// may have been missed.
+func (w *FileWatcher) recoverOverflow(dir string) {
+	w.trace(dir, "notifications lost, rescanning")
+	before := w.listings.snapshot(dir)
+	if err := w.listings.scan(w.fs, dir); err != nil {
+		w.log.Debug("Unable to rescan ", dir, ": ", err)
+		return
+	}
+	after := w.listings.snapshot(dir)
+
+	var events []FileWatcherEvent
+	for name, entry := range before {
+		if _, ok := after[name]; ok {
+			continue
+		}
+		e := FileWatcherEvent{Path: filepath.Join(dir, name), Event: EventDeleteFile}
+		if entry.isDir {
+			e.Event = EventDeleteFolder
+		}
+		events = append(events, e)
+	}
+	for name, entry := range after {
+		if _, ok := before[name]; ok {
+			continue
+		}
+		e := FileWatcherEvent{Path: filepath.Join(dir, name), Event: EventCreateFile}
+		if entry.isDir {
+			e.Event = EventCreateFolder
+		}
+		events = append(events, e)
+	}
+	for _, e := range events {
+		if reason, ignored := w.ignoreReason(e.Path); ignored {
+			w.trace(e.Path, "ignored by %s", reason)
+			continue
+		}
+		w.emit(e)
+	}
+	w.emit(FileWatcherEvent{Path: dir, Event: EventOverflow})
+}
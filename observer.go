@@ -0,0 +1,87 @@
+package fileWatcher
+
+import "time"
+
+// Observer is a read-only handle on a FileWatcher, for plugins and third-party code: it receives the events and reads
+// the state of the watcher, but can't add or remove watches, pause or close the watcher. Its subscriptions leave Events
+// to the application, see Subscribe, but its handlers are handlers of the watcher, see OnEvent: once it registered
+// one, the application has to consume the events with handlers too rather than by reading Events.
+type Observer struct {
+	w *FileWatcher
+}
+
+// Observer returns a read-only handle on the watcher.
+func (w *FileWatcher) Observer() *Observer {
+	return &Observer{w: w}
+}
+
+// OnEvent registers h to be called for every event, see FileWatcher.OnEvent.
+func (o *Observer) OnEvent(h EventHandler) func() {
+	return o.w.OnEvent(h)
+}
+
+// OnError registers h to be called for every error, see FileWatcher.OnError.
+func (o *Observer) OnError(h ErrorHandler) func() {
+	return o.w.OnError(h)
+}
+
+// Subscribe returns a channel receiving the events of path and of everything below it, see FileWatcher.Subscribe.
+func (o *Observer) Subscribe(path string) (<-chan FileWatcherEvent, func()) {
+	return o.w.Subscribe(path)
+}
+
+// SubscribeWithOptions subscribes to path like Subscribe, with the given options.
+func (o *Observer) SubscribeWithOptions(path string, options SubscriptionOptions) (<-chan FileWatcherEvent, func()) {
+	return o.w.SubscribeWithOptions(path, options)
+}
+
+// EventsSince returns the events of the history with a Seq above seq, see FileWatcher.EventsSince.
+func (o *Observer) EventsSince(seq uint64) []FileWatcherEvent {
+	return o.w.EventsSince(seq)
+}
+
+// History returns the events emitted since t that are still in the history, see FileWatcher.History.
+func (o *Observer) History(since time.Time) ([]FileWatcherEvent, error) {
+	return o.w.History(since)
+}
+
+// Stats returns a snapshot of the internal state of the watcher, see FileWatcher.Stats.
+func (o *Observer) Stats() Stats {
+	return o.w.Stats()
+}
+
+// Contains reports whether path is watched.
+func (o *Observer) Contains(path string) bool {
+	return o.w.Contains(path)
+}
+
+// Len returns the number of watched paths.
+func (o *Observer) Len() int {
+	return o.w.Len()
+}
+
+// WatchedPaths returns the watched paths, sorted.
+func (o *Observer) WatchedPaths() []string {
+	return o.w.WatchedPaths()
+}
+
+// ListWatched returns the watched paths with their owners, see FileWatcher.ListWatched.
+func (o *Observer) ListWatched() []WatchedPath {
+	return o.w.ListWatched()
+}
+
+// Roots returns the current watch set, see FileWatcher.Roots.
+func (o *Observer) Roots() []RootSpec {
+	return o.w.Roots()
+}
+
+// Listing returns the entries of the watched directory dir as recorded by the listing cache, see
+// FileWatcher.Listing.
+func (o *Observer) Listing(dir string) ([]ListedEntry, bool) {
+	return o.w.Listing(dir)
+}
+
+// Paused reports whether the watcher is paused.
+func (o *Observer) Paused() bool {
+	return o.w.Paused()
+}
@@ -0,0 +1,768 @@
+package fileWatcher
+
+import (
+	"runtime"
+	"time"
+)
+
+// Options holds the optional settings of a FileWatcher. The zero value keeps the original behaviour.
+type Options struct {
+	// ChangeSetQuietPeriod enables burst detection. When greater than zero, events that arrive while no explicit
+	// change set is open are grouped into a ChangeSet which is delivered on ChangeSets once no event has been seen
+	// for the quiet period.
+	ChangeSetQuietPeriod time.Duration
+
+	// BatchQuietPeriod enables batching. When greater than zero, events are no longer delivered on Events but grouped
+	// into a FileWatcherBatch, delivered on Batches once no event has been seen for the quiet period.
+	BatchQuietPeriod time.Duration
+
+	// CoalesceWindow enables net-effect coalescing. When greater than zero, events are held for the window starting
+	// with the first held event and only their net effect is delivered on Events: create, edit, edit becomes a single
+	// create and create, delete becomes nothing.
+	CoalesceWindow time.Duration
+
+	// SQLiteDebounce is how long a database watched with AddSQLite has to be quiet before DB_CHANGED is emitted.
+	// It defaults to 100 milliseconds.
+	SQLiteDebounce time.Duration
+
+	// InferRenames pairs deletes and creates of the same inode into rename events, using a listing of the watched
+	// directories, so a file moved from one watched directory to another is reported as a single rename. It is enabled
+	// by default on Linux and the kqueue platforms (macOS and the BSDs), where renames often arrive unpaired, and
+	// disabled elsewhere.
+	InferRenames *bool
+
+	// ListingRescanInterval refreshes the in-memory listing of every watched directory periodically, correcting drift
+	// caused by missed events. Zero disables periodic rescans.
+	ListingRescanInterval time.Duration
+
+	// StatCacheTTL is how long a stat result is cached when no event invalidates it. It defaults to one second, a
+	// negative value disables the cache.
+	StatCacheTTL time.Duration
+	// StatCacheSize is the maximum number of cached stat results. It defaults to 4096.
+	StatCacheSize int
+
+	// DispatchWorkers is the number of goroutines delivering classified events. Events are spread over them by a
+	// hash of their path, so the events of a path keep their order but events of different paths may be delivered
+	// out of order, see the ordering guarantees in sequencer.go. Zero or one delivers every event from the event loop.
+	DispatchWorkers int
+	// ClassifyWorkers is the number of goroutines classifying the events paired by the event loop, which stats the
+	// files, hashes their content and looks them up. Events are spread over them by a hash of their path, so the
+	// events of a path keep their order. Zero or one classifies every event in the event loop.
+	ClassifyWorkers int
+	// CheckOrdering verifies every delivered event against the ordering guarantees and sends an OrderingError on Errors
+	// for each violation. It is meant for tests and costs a lock and a map entry per path.
+	CheckOrdering bool
+	// OrderedDelivery extends the ordering guarantees of a path to its directory, for consumers applying the events
+	// to a state of their own, like a database, see the ordering guarantees in sequencer.go.
+	OrderedDelivery bool
+
+	// Adaptive tunes the coalescing window to the speed of the consumers.
+	Adaptive AdaptiveOptions
+
+	// MemoryBudget limits the memory held by buffered events.
+	MemoryBudget MemoryBudget
+
+	// ProfilingLabels adds the watched root path to the pprof labels of every event delivery. Internal goroutines
+	// are always labelled with their subsystem.
+	ProfilingLabels bool
+
+	// DebugTrace keeps the decision trail of that many of the last raw events, see FileWatcher.DebugTrace. Zero
+	// disables tracing.
+	DebugTrace int
+
+	// IgnoreFiles are the names of the ignore files, like ".gitignore", imported from the directories added to the
+	// watcher and the directories below them. They are reloaded when they change, and the version control directories
+	// next to them are ignored too.
+	IgnoreFiles []string
+
+	// CreateClassifyDelay is how long a create waits for the event following it, which makes it an edit or a rename,
+	// before it is classified on its own. It defaults to 125 milliseconds, slow network volumes may need more.
+	CreateClassifyDelay time.Duration
+	// ClassificationRules pair the raw notifications into events, in place of DefaultClassificationRules, for the
+	// platforms and filesystems reporting their changes in unusual ways. See ClassificationRule.
+	ClassificationRules []ClassificationRule
+	// Clock is the source of time of the watcher, the system clock when nil. See Clock.
+	Clock Clock
+
+	// Lock configures how files still locked by their writer are reported on Windows.
+	Lock LockOptions
+	// Stability configures the wait for new files to stop changing before they are reported as complete.
+	Stability StabilityOptions
+	// Chmod configures which CHMOD events are delivered, see ChmodPolicy.
+	Chmod ChmodOptions
+
+	// PollNetworkPaths watches paths on UNC shares by polling, as change notifications are unreliable there. It is
+	// enabled by Init on Windows unless DisableNetworkPolling is set.
+	PollNetworkPaths      bool
+	DisableNetworkPolling bool
+	// PollInterval is the interval between two polls of a polled path. It defaults to two seconds.
+	PollInterval time.Duration
+	// AdaptivePolling adapts the interval of every polled path to how often it changes, see AdaptivePollingOptions.
+	AdaptivePolling AdaptivePollingOptions
+	// KqueueFileBudget is the number of watched paths past which the files added on their own are polled instead of
+	// watched on kqueue platforms (macOS and the BSDs), where every watch holds a file descriptor. Directories are
+	// still watched. It defaults to half of the open file limit, a negative budget never polls.
+	KqueueFileBudget int
+	// WatchLimitFallback polls the paths that can't be watched because the kernel ran out of watches, like the
+	// directories of a large tree past fs.inotify.max_user_watches on Linux, instead of failing with
+	// ErrWatchLimitExceeded.
+	WatchLimitFallback bool
+
+	// Identity is stamped onto every event when set.
+	Identity *Identity
+	// IDGenerator returns the ID of an event, like a UUID or the span id of a tracer. The event has its Seq, Key and
+	// identity set already. Events are identified by their Key when it is nil.
+	IDGenerator func(e FileWatcherEvent) string
+
+	// HistorySize is the number of emitted events kept for History, EventsSince and Report. Zero disables the
+	// history.
+	HistorySize int
+	// HistoryRetention is how long the events are kept in the history, zero keeps them until newer events replace
+	// them.
+	HistoryRetention time.Duration
+
+	// Quarantine moves the created files rejected by its policy to a quarantine directory.
+	Quarantine QuarantineOptions
+
+	// TrackDirtyPaths records the paths changed since each checkpoint, see FileWatcher.Checkpoint.
+	TrackDirtyPaths bool
+
+	// PromoteThreshold is the number of files of a directory added individually at which the directory is watched in
+	// their place, with its events filtered down to the added files. It defaults to 64, a negative value disables
+	// promotion.
+	PromoteThreshold int
+	// SingleFiles watches the files added individually through their directory from the first one, whatever
+	// PromoteThreshold, for the few configuration files scattered across busy directories. The files keep being
+	// watched across atomic saves, which replace them, and the events of their siblings are dropped by a lookup of
+	// their name as they arrive, before any other work.
+	SingleFiles bool
+	// PruneOnRemove makes Remove of a directory also remove the files of the directory added individually, which are
+	// otherwise kept watched on their own.
+	PruneOnRemove bool
+	// CanonicalCase resolves the paths given to the watcher to the case of the names on disk, so on a case-insensitive
+	// filesystem, like the default ones of macOS and Windows, the events carry the same paths whatever case the paths
+	// were added with. Every path given is resolved by reading its parent folders.
+	CanonicalCase bool
+
+	// DebounceInterval enables per path debouncing. When greater than zero, the events of a path are held until no
+	// other event for the path has been seen for the interval, and only the latest of them is delivered.
+	DebounceInterval time.Duration
+
+	// RateLimit is the largest number of events per second delivered for a path, like a log file written a thousand
+	// times a second. The edits, chmods and accesses over the limit are dropped, except the last one, delivered at the
+	// end of the second. The delivered events count the dropped ones in Suppressed. Zero means no limit.
+	RateLimit int
+
+	// HandlerWorkers is the number of goroutines calling the handlers registered with OnEvent. It defaults to the
+	// number of CPUs.
+	HandlerWorkers int
+
+	// Backend is how changes are detected. It defaults to NotifyBackend.
+	Backend Backend
+
+	// EmitExisting emits a CREATE_FILE or CREATE_FOLDER event for every entry already present in a path when it is
+	// first added, see AddWithInitialScan.
+	EmitExisting bool
+
+	// DisableEventInfo leaves the Info field of the events empty, saving a stat per event the stat cache does not
+	// hold.
+	DisableEventInfo bool
+	// StatPolicy is when the Info field of the events is filled in, see AddWithStatPolicy to set it per watched path.
+	// DisableEventInfo overrides it with StatNever.
+	StatPolicy StatPolicy
+
+	// LatencyProbeInterval enables latency measurement, see LatencyStats. Every interval a sentinel file is written in
+	// one of the watched directories to measure the latency of the watcher itself.
+	LatencyProbeInterval time.Duration
+
+	// LoadShedding makes the watcher yield when the process is under CPU pressure.
+	LoadShedding LoadSheddingOptions
+	// StateGC bounds the state kept about the paths to correlate their events, see StateGCOptions.
+	StateGC StateGCOptions
+	// WarmCacheFile is a file the stat cache and the content hashes of VerifyContentChange are saved to when the
+	// watcher is closed, and loaded from when it is created, so a restart doesn't stat and hash every file again
+	// before the events are enriched accurately. The entries of the paths changed meanwhile are left out. An invalid
+	// file is ignored with a warning.
+	WarmCacheFile string
+	// HealthCheckInterval probes up to three of the watched directories every interval, and re-creates the notifier
+	// when none of them delivers events, see Healthy. Zero only re-creates the notifiers that close on their own.
+	HealthCheckInterval time.Duration
+
+	// Tracer receives structured records of the raw events, the classification decisions, the coalesced and dropped
+	// events and the deliveries blocked by slow consumers.
+	Tracer Tracer
+
+	// ExportRedaction transforms the paths of the events sent by the remote APIs, ServeJSONRPC and GraphQLHandler,
+	// see Export. Wrap the sinks with Redacted for them to receive the same paths.
+	ExportRedaction []Redaction
+
+	// AutoRearmInterval keeps watching the roots that get deleted, like directories replaced atomically by deleting
+	// them and renaming a new one in place: their path is checked every interval and watched again once it is back,
+	// with a WATCH_REESTABLISHED event. Zero lets the watches of deleted roots go.
+	AutoRearmInterval time.Duration
+
+	// LeaderElection lets a single one of the watchers of a shared filesystem emit the events, with failover.
+	LeaderElection LeaderElectionOptions
+
+	// VerifyContentChange drops the EDIT_FILE events of files rewritten with the content they had, as touch, rsync and
+	// build tools often do. The hash of every created, edited or renamed file is kept to compare with, which costs a
+	// read of the whole file per event.
+	VerifyContentChange bool
+	// Fingerprinter hashes the files for VerifyContentChange and Fingerprint. It defaults to XXHashFingerprinter.
+	Fingerprinter Fingerprinter
+	// FingerprintCacheSize is the maximum number of cached fingerprints. It defaults to 4096, a negative size disables
+	// the cache.
+	FingerprintCacheSize int
+
+	// DetectDownloads reports browser downloads with a single DOWNLOAD_COMPLETED event once the temporary file, like
+	// "report.pdf.crdownload" or "report.pdf.part", is renamed to its final name. The events of the temporary files
+	// are dropped.
+	DetectDownloads bool
+
+	// AtomicSavePatterns are the names of the temporary files of the atomic saves, like "*.swp" or ".#*", in the
+	// syntax of AddIgnorePatterns. When set, an atomic save is reported with a single EDIT_FILE event for the saved
+	// file and the events of the temporary files are dropped, see DefaultAtomicSavePatterns.
+	AtomicSavePatterns []string
+
+	// Extraction reports archives extracted into the watched tree with an EXTRACTION_COMPLETED event.
+	Extraction ExtractionOptions
+	// Bulk reports thousands of items appearing at once below a folder with BULK_OPERATION events.
+	Bulk BulkOptions
+
+	// CloseTimeout is how long Close waits for the consumers to receive the pending events before dropping them. It
+	// defaults to five seconds.
+	CloseTimeout time.Duration
+
+	// EventBuffer is the capacity of the Events channel, which is unbuffered by default. It defaults to 1024 with the
+	// dropping overflow policies.
+	EventBuffer int
+	// Overflow decides what happens to the events delivered while the Events buffer is full.
+	Overflow OverflowPolicy
+	// ErrorBuffer is the capacity of the Errors channel. The errors delivered while it is full are dropped and counted
+	// in EventStats.ErrorsDropped, so a watcher whose errors nobody reads doesn't stall. It defaults to 64, a negative
+	// value keeps Errors unbuffered and the watcher waiting for a reader.
+	ErrorBuffer int
+
+	// WindowsBufferSize is the size in bytes of the buffer the changes of each watched directory are read into on
+	// Windows, 64 KiB at most. When more changes than it holds happen between two reads, the directory is rescanned
+	// and an OVERFLOW event with its path is emitted. Setting it replaces fsnotify, whose buffer is fixed, by a reader
+	// of its own. It is ignored on the other platforms.
+	WindowsBufferSize int
+
+	// Symlinks decides whether the symlinks found in recursively watched trees are followed, and which path the
+	// changes behind them are reported against. They are not followed by default.
+	Symlinks SymlinkMode
+}
+
+// Option is used to configure a FileWatcher in Init.
+type Option func(*Options)
+
+// WithChangeSetBurst groups bursts of events separated by less than quiet into change sets delivered on the
+// ChangeSets channel.
+func WithChangeSetBurst(quiet time.Duration) Option {
+	return func(o *Options) {
+		o.ChangeSetQuietPeriod = quiet
+	}
+}
+
+// WithCoalescing delivers only the net effect of the events seen within each window.
+func WithCoalescing(window time.Duration) Option {
+	return func(o *Options) {
+		o.CoalesceWindow = window
+	}
+}
+
+// WithSQLiteDebounce sets how long a database watched with AddSQLite has to be quiet before DB_CHANGED is emitted.
+func WithSQLiteDebounce(d time.Duration) Option {
+	return func(o *Options) {
+		o.SQLiteDebounce = d
+	}
+}
+
+// WithRenameInference enables or disables the inference of renames from inode numbers.
+func WithRenameInference(enabled bool) Option {
+	return func(o *Options) {
+		o.InferRenames = &enabled
+	}
+}
+
+// WithListingRescan refreshes the listing of the watched directories every interval.
+func WithListingRescan(interval time.Duration) Option {
+	return func(o *Options) {
+		o.ListingRescanInterval = interval
+	}
+}
+
+// WithStatCache sets the time to live and the size of the stat cache.
+func WithStatCache(ttl time.Duration, size int) Option {
+	return func(o *Options) {
+		o.StatCacheTTL = ttl
+		o.StatCacheSize = size
+	}
+}
+
+// WithDispatchWorkers delivers classified events from n goroutines sharded by path.
+func WithDispatchWorkers(n int) Option {
+	return func(o *Options) {
+		o.DispatchWorkers = n
+	}
+}
+
+// WithOrderingChecks verifies the order of the delivered events, see Options.CheckOrdering.
+func WithOrderingChecks() Option {
+	return func(o *Options) {
+		o.CheckOrdering = true
+	}
+}
+
+// WithOrderedDelivery delivers the events of a directory in the order they happened, see Options.OrderedDelivery.
+func WithOrderedDelivery() Option {
+	return func(o *Options) {
+		o.OrderedDelivery = true
+	}
+}
+
+// WithClassifyWorkers classifies events from n goroutines sharded by path.
+func WithClassifyWorkers(n int) Option {
+	return func(o *Options) {
+		o.ClassifyWorkers = n
+	}
+}
+
+// WithAdaptiveTuning widens the coalescing window between min and max while deliveries block for longer than
+// target, and tightens it again when consumers keep up.
+func WithAdaptiveTuning(target time.Duration, min time.Duration, max time.Duration) Option {
+	return func(o *Options) {
+		o.Adaptive = AdaptiveOptions{Enabled: true, TargetLatency: target, MinWindow: min, MaxWindow: max}
+	}
+}
+
+// WithMemoryBudget limits the memory held by buffered events to limit bytes, applying policy to the events over it.
+func WithMemoryBudget(limit int64, policy MemoryPolicy, spill Sink) Option {
+	return func(o *Options) {
+		o.MemoryBudget = MemoryBudget{Limit: limit, Policy: policy, Spill: spill}
+	}
+}
+
+// WithProfilingLabels labels event deliveries with the watched root path in CPU profiles.
+func WithProfilingLabels() Option {
+	return func(o *Options) {
+		o.ProfilingLabels = true
+	}
+}
+
+// WithDebugTrace keeps the decision trail of the last size raw events.
+func WithDebugTrace(size int) Option {
+	return func(o *Options) {
+		o.DebugTrace = size
+	}
+}
+
+// WithIgnoreFiles imports the ignore files with the given names found under the added directories, DefaultIgnoreFiles
+// when no name is given.
+func WithIgnoreFiles(names ...string) Option {
+	return func(o *Options) {
+		if len(names) == 0 {
+			names = DefaultIgnoreFiles
+		}
+		o.IgnoreFiles = names
+	}
+}
+
+// WithLockPolicy applies policy to the CREATE_FILE and EDIT_FILE events of files locked by their writer, deferring
+// them for up to timeout with LockPolicyDefer.
+func WithLockPolicy(policy LockPolicy, timeout time.Duration) Option {
+	return func(o *Options) {
+		o.Lock.Policy = policy
+		o.Lock.Timeout = timeout
+	}
+}
+
+// WithChmodPolicy applies policy to the CHMOD events, dropping the events of a path for cooldown after one was
+// delivered with ChmodCooldown.
+func WithChmodPolicy(policy ChmodPolicy, cooldown time.Duration) Option {
+	return func(o *Options) {
+		o.Chmod.Policy = policy
+		o.Chmod.Cooldown = cooldown
+	}
+}
+
+// WithStabilityWait holds CREATE_FILE events back until the size and modification time of the file stayed the same
+// for quiet, or timeout expired.
+func WithStabilityWait(quiet time.Duration, timeout time.Duration) Option {
+	return func(o *Options) {
+		o.Stability.Quiet = quiet
+		o.Stability.Timeout = timeout
+		o.Stability.Ready = false
+	}
+}
+
+// WithFileReady emits a FILE_READY event once the size and modification time of a new file stayed the same for
+// quiet, or timeout expired. CREATE_FILE is delivered right away.
+func WithFileReady(quiet time.Duration, timeout time.Duration) Option {
+	return func(o *Options) {
+		o.Stability.Quiet = quiet
+		o.Stability.Timeout = timeout
+		o.Stability.Ready = true
+	}
+}
+
+// WithClassificationRules classifies the raw notifications with rules instead of DefaultClassificationRules.
+func WithClassificationRules(rules ...ClassificationRule) Option {
+	return func(o *Options) {
+		o.ClassificationRules = rules
+	}
+}
+
+// WithCreateClassifyDelay sets how long a create waits for the event following it before it is classified on its own.
+func WithCreateClassifyDelay(delay time.Duration) Option {
+	return func(o *Options) {
+		o.CreateClassifyDelay = delay
+	}
+}
+
+// WithClock sets the source of time of the watcher, for tests driving the delays with a fake clock.
+func WithClock(clock Clock) Option {
+	return func(o *Options) {
+		o.Clock = clock
+	}
+}
+
+// WithPollInterval sets the interval between two polls of a polled path.
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.PollInterval = interval
+	}
+}
+
+// WithAdaptivePolling polls the polled paths between every min and every max depending on how often they change.
+func WithAdaptivePolling(min time.Duration, max time.Duration) Option {
+	return func(o *Options) {
+		o.AdaptivePolling.MinInterval = min
+		o.AdaptivePolling.MaxInterval = max
+	}
+}
+
+// WithoutNetworkPolling uses change notifications for UNC shares too.
+func WithoutNetworkPolling() Option {
+	return func(o *Options) {
+		o.DisableNetworkPolling = true
+	}
+}
+
+// WithIdentity stamps the hostname, watcherID and the process id onto every event.
+func WithIdentity(watcherID string) Option {
+	return func(o *Options) {
+		o.Identity = &Identity{WatcherID: watcherID}
+	}
+}
+
+// WithHistory keeps the last size emitted events for History, EventsSince and Report.
+func WithHistory(size int) Option {
+	return func(o *Options) {
+		o.HistorySize = size
+	}
+}
+
+// WithHistoryRetention drops the events of the history once they are older than retention.
+func WithHistoryRetention(retention time.Duration) Option {
+	return func(o *Options) {
+		o.HistoryRetention = retention
+	}
+}
+
+// WithQuarantine moves the created files rejected by policy to dir and reports them with a QUARANTINED event instead
+// of CREATE_FILE.
+func WithQuarantine(dir string, policy QuarantinePolicy) Option {
+	return func(o *Options) {
+		o.Quarantine = QuarantineOptions{Dir: dir, Policy: policy}
+	}
+}
+
+// WithDirtyPaths records the paths changed since each checkpoint for differential backups.
+func WithDirtyPaths() Option {
+	return func(o *Options) {
+		o.TrackDirtyPaths = true
+	}
+}
+
+// WithWatchPromotion watches a directory in place of its files once threshold of them are added individually.
+func WithWatchPromotion(threshold int) Option {
+	return func(o *Options) {
+		o.PromoteThreshold = threshold
+	}
+}
+
+// WithSingleFiles watches the files added individually through their directory, see the SingleFiles option.
+func WithSingleFiles() Option {
+	return func(o *Options) {
+		o.SingleFiles = true
+	}
+}
+
+// WithPruneOnRemove removes the files of a directory added individually along with the directory.
+func WithPruneOnRemove() Option {
+	return func(o *Options) {
+		o.PruneOnRemove = true
+	}
+}
+
+// WithCanonicalCase resolves the paths given to the watcher to the case of the names on disk.
+func WithCanonicalCase() Option {
+	return func(o *Options) {
+		o.CanonicalCase = true
+	}
+}
+
+// WithDebounce delivers only the latest event of a path once the path has been quiet for interval.
+func WithDebounce(interval time.Duration) Option {
+	return func(o *Options) {
+		o.DebounceInterval = interval
+	}
+}
+
+// WithRateLimit delivers at most perSecond events per second for a path.
+func WithRateLimit(perSecond int) Option {
+	return func(o *Options) {
+		o.RateLimit = perSecond
+	}
+}
+
+// WithHandlerWorkers calls the handlers registered with OnEvent from n goroutines.
+func WithHandlerWorkers(n int) Option {
+	return func(o *Options) {
+		o.HandlerWorkers = n
+	}
+}
+
+// WithBackend detects changes with b, for example PollingBackend on network filesystems.
+func WithBackend(b Backend) Option {
+	return func(o *Options) {
+		o.Backend = b
+	}
+}
+
+// WithInitialScan emits events for the entries already present in every added path.
+func WithInitialScan() Option {
+	return func(o *Options) {
+		o.EmitExisting = true
+	}
+}
+
+// WithoutEventInfo leaves the Info field of the events empty.
+func WithoutEventInfo() Option {
+	return func(o *Options) {
+		o.DisableEventInfo = true
+	}
+}
+
+// WithStatPolicy sets when the Info field of the events is filled in.
+func WithStatPolicy(policy StatPolicy) Option {
+	return func(o *Options) {
+		o.StatPolicy = policy
+	}
+}
+
+// WithLatencyProbe measures the latency of the event pipeline, probing a watched directory every interval.
+func WithLatencyProbe(interval time.Duration) Option {
+	return func(o *Options) {
+		o.LatencyProbeInterval = interval
+	}
+}
+
+// WithLoadShedding debounces events for longer and skips their enrichment while the process uses more than threshold
+// of the CPUs of the host.
+func WithLoadShedding(threshold float64) Option {
+	return func(o *Options) {
+		o.LoadShedding.Threshold = threshold
+	}
+}
+
+// WithWarmCache saves the stat cache and the content hashes to file when the watcher is closed, and loads them from
+// it when it is created.
+func WithWarmCache(file string) Option {
+	return func(o *Options) {
+		o.WarmCacheFile = file
+	}
+}
+
+// WithHealthCheck checks every interval that the notifier still delivers events, re-creating it otherwise.
+func WithHealthCheck(interval time.Duration) Option {
+	return func(o *Options) {
+		o.HealthCheckInterval = interval
+	}
+}
+
+// WithStateGC sets how the state kept about the paths to correlate their events is collected and bounded.
+func WithStateGC(gc StateGCOptions) Option {
+	return func(o *Options) {
+		o.StateGC = gc
+	}
+}
+
+// WithContentVerification only emits EDIT_FILE when the content of the file actually changed.
+func WithContentVerification() Option {
+	return func(o *Options) {
+		o.VerifyContentChange = true
+	}
+}
+
+// WithFingerprinter hashes the files with f, keeping up to cacheSize fingerprints, 4096 when it is zero.
+func WithFingerprinter(f Fingerprinter, cacheSize int) Option {
+	return func(o *Options) {
+		o.Fingerprinter = f
+		o.FingerprintCacheSize = cacheSize
+	}
+}
+
+// WithDownloadDetection reports completed browser downloads with DOWNLOAD_COMPLETED and drops the events of downloads
+// in progress.
+func WithDownloadDetection() Option {
+	return func(o *Options) {
+		o.DetectDownloads = true
+	}
+}
+
+// WithAtomicSaveDetection reports the atomic saves of the editors with a single EDIT_FILE event, the temporary files
+// being named like one of patterns, or one of DefaultAtomicSavePatterns when none is given.
+func WithAtomicSaveDetection(patterns ...string) Option {
+	if len(patterns) == 0 {
+		patterns = DefaultAtomicSavePatterns
+	}
+	return func(o *Options) {
+		o.AtomicSavePatterns = append([]string{}, patterns...)
+	}
+}
+
+// WithExtractionDetection emits EXTRACTION_COMPLETED for a new folder once no entry has been created below it for
+// quiet, dropping the events of the entries when suppress is set.
+func WithExtractionDetection(quiet time.Duration, suppress bool) Option {
+	return func(o *Options) {
+		o.Extraction = ExtractionOptions{Quiet: quiet, Suppress: suppress}
+	}
+}
+
+// WithBulkDetection reports a bulk operation below a folder once threshold items were created below it within window,
+// dropping the events of the items while it goes on when suppress is set.
+func WithBulkDetection(threshold int, window time.Duration, suppress bool) Option {
+	return func(o *Options) {
+		o.Bulk = BulkOptions{Threshold: threshold, Window: window, Suppress: suppress}
+	}
+}
+
+// WithCloseTimeout sets how long Close waits for the consumers to receive the pending events.
+func WithCloseTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.CloseTimeout = timeout
+	}
+}
+
+// WithErrorBuffer buffers up to size errors on Errors, dropping the next ones while it is full.
+func WithErrorBuffer(size int) Option {
+	return func(o *Options) {
+		o.ErrorBuffer = size
+	}
+}
+
+// WithEventBuffer buffers up to size events on Events, applying policy to the events delivered while it is full.
+func WithEventBuffer(size int, policy OverflowPolicy) Option {
+	return func(o *Options) {
+		o.EventBuffer = size
+		o.Overflow = policy
+	}
+}
+
+// WithIDGenerator sets the function returning the ID of every event.
+func WithIDGenerator(generate func(e FileWatcherEvent) string) Option {
+	return func(o *Options) {
+		o.IDGenerator = generate
+	}
+}
+
+// WithWindowsBufferSize sets the size of the buffer the changes of each watched directory are read into on Windows.
+func WithWindowsBufferSize(size int) Option {
+	return func(o *Options) {
+		o.WindowsBufferSize = size
+	}
+}
+
+// WithSymlinks sets how the symlinks found in recursively watched trees are handled.
+func WithSymlinks(mode SymlinkMode) Option {
+	return func(o *Options) {
+		o.Symlinks = mode
+	}
+}
+
+// WithBatches delivers the events in batches separated by pauses of at least quiet on the Batches channel, instead of
+// one by one on Events.
+func WithBatches(quiet time.Duration) Option {
+	return func(o *Options) {
+		o.BatchQuietPeriod = quiet
+	}
+}
+
+// WithKqueueFileBudget sets the number of watched paths past which the files added on their own are polled on kqueue
+// platforms, a negative budget never polls.
+func WithKqueueFileBudget(budget int) Option {
+	return func(o *Options) {
+		o.KqueueFileBudget = budget
+	}
+}
+
+// WithWatchLimitFallback polls the paths over the watch limit of the kernel instead of failing to add them.
+func WithWatchLimitFallback() Option {
+	return func(o *Options) {
+		o.WatchLimitFallback = true
+	}
+}
+
+// WithLeaderElection only emits events while lock elects this instance as the leader among the watchers of the same
+// shared storage, see LeaderElectionOptions. The leadership is renewed every third of ttl.
+func WithLeaderElection(lock LeaderLock, ttl time.Duration) Option {
+	return func(o *Options) {
+		o.LeaderElection.Lock = lock
+		o.LeaderElection.TTL = ttl
+	}
+}
+
+// WithTracer hands structured records of what the watcher does with the events to t, see Tracer.
+func WithTracer(t Tracer) Option {
+	return func(o *Options) {
+		o.Tracer = t
+	}
+}
+
+// WithAutoRearm watches the deleted roots again once they are recreated, checking for them every interval.
+func WithAutoRearm(interval time.Duration) Option {
+	return func(o *Options) {
+		o.AutoRearmInterval = interval
+	}
+}
+
+// WithExportRedaction transforms the paths of the events leaving the process through the remote APIs with rules,
+// like StripHomeDirs or HashFileNames.
+func WithExportRedaction(rules ...Redaction) Option {
+	return func(o *Options) {
+		o.ExportRedaction = append(o.ExportRedaction, rules...)
+	}
+}
+
+func buildOptions(opts []Option) Options {
+	o := Options{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	if runtime.GOOS == "windows" && !o.DisableNetworkPolling {
+		o.PollNetworkPaths = true
+	}
+	if b, ok := o.Backend.(PollingBackend); ok && b.Interval > 0 {
+		o.PollInterval = b.Interval
+	}
+	if o.Overflow != OverflowBlock && o.EventBuffer <= 0 {
+		o.EventBuffer = defaultEventBuffer
+	}
+	resolveIdentity(o.Identity)
+	return o
+}
@@ -0,0 +1,85 @@
+package fileWatcher
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// defaultEventBuffer is the size of the Events buffer with the dropping overflow policies when EventBuffer is not
+// set.
+const defaultEventBuffer = 1024
+
+// OverflowPolicy decides what happens to an event delivered while the Events buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for the consumers to make room. The event loop stalls meanwhile, and the events of the
+	// platform may be lost once its own buffer is full.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest buffered event to make room.
+	OverflowDropOldest
+	// OverflowDropNewest drops the event and delivers an OVERFLOW event as soon as there is room, telling the
+	// consumers they missed events and should rescan.
+	OverflowDropNewest
+)
+
+type overflowState struct {
+	// pending is set while an OVERFLOW event waits for room.
+	pending int32
+}
+
+// sendDroppingOldest delivers e, dropping the oldest buffered events while the buffer is full. The caller holds the
+// read lock of the lifecycle.
+func (w *FileWatcher) sendDroppingOldest(e FileWatcherEvent) {
+	for {
+		select {
+		case w.Events <- e:
+			atomic.AddUint64(&w.counters.delivered, 1)
+			return
+		default:
+		}
+		select {
+		case old := <-w.Events:
+			w.trace(old.Path, "%s dropped from the full buffer", old.Event)
+			w.countDropped(old, "dropped from the full buffer")
+			// it was counted as delivered when it was buffered
+			atomic.AddUint64(&w.counters.delivered, ^uint64(0))
+		default:
+		}
+	}
+}
+
+// sendDroppingNewest delivers e when the buffer has room and drops it otherwise. The caller holds the read lock of the
+// lifecycle.
+func (w *FileWatcher) sendDroppingNewest(e FileWatcherEvent) bool {
+	select {
+	case w.Events <- e:
+		atomic.AddUint64(&w.counters.delivered, 1)
+		return true
+	default:
+	}
+
+	w.trace(e.Path, "buffer full, %s dropped", e.Event)
+	w.countDropped(e, "buffer full")
+	if atomic.CompareAndSwapInt32(&w.overflow.pending, 0, 1) {
+		go w.sendOverflow()
+	}
+	return false
+}
+
+// sendOverflow delivers an OVERFLOW event once the buffer has room.
+func (w *FileWatcher) sendOverflow() {
+	w.labelGoroutine("overflow")
+	defer atomic.StoreInt32(&w.overflow.pending, 0)
+	w.log.Warn("Events buffer full, events dropped")
+
+	w.life.mu.RLock()
+	defer w.life.mu.RUnlock()
+	if w.life.closed {
+		return
+	}
+	select {
+	case w.Events <- FileWatcherEvent{Event: EventOverflow, Time: time.Now()}:
+	case <-w.life.abandon:
+	}
+}
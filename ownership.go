@@ -0,0 +1,119 @@
+package fileWatcher
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WatchedPath is a watched path and the components holding it, see ListWatched.
+type WatchedPath struct {
+	Path string
+	// Owners are the owners that added the path with AddAs, sorted. It is empty for the paths added with Add only.
+	Owners []string
+	// Refs counts the AddAs calls not matched by a RemoveAs yet, over every owner.
+	Refs int
+}
+
+type ownership struct {
+	refs map[string]int
+	// shared is set when the path was watched already when its first owner added it, the watch is then kept once
+	// every owner removed it.
+	shared bool
+}
+
+type ownerState struct {
+	mu    sync.Mutex
+	paths map[string]*ownership
+}
+
+// AddAs watches path like Add on behalf of owner, a name identifying the component, like "indexer". The watch is
+// reference counted: it is only removed once every AddAs of every owner was matched by a RemoveAs, so a component
+// removing its watch doesn't break the watch another component relies on.
+func (w *FileWatcher) AddAs(owner string, path string) error {
+	path = w.normalize(path)
+	watched := w.Contains(path)
+	if err := w.Add(path); err != nil {
+		return err
+	}
+
+	w.owners.mu.Lock()
+	defer w.owners.mu.Unlock()
+	if w.owners.paths == nil {
+		w.owners.paths = make(map[string]*ownership)
+	}
+	o, ok := w.owners.paths[path]
+	if !ok {
+		o = &ownership{refs: make(map[string]int), shared: watched}
+		w.owners.paths[path] = o
+	}
+	o.refs[owner]++
+	return nil
+}
+
+// RemoveAs drops a reference of owner to path, and removes the watch once no owner holds it anymore, unless it was
+// added with Add before its first owner added it. Removing a path owner does not hold does nothing.
+func (w *FileWatcher) RemoveAs(owner string, path string) error {
+	path = w.normalize(path)
+	w.owners.mu.Lock()
+	o, ok := w.owners.paths[path]
+	if !ok || o.refs[owner] == 0 {
+		w.owners.mu.Unlock()
+		return nil
+	}
+	o.refs[owner]--
+	if o.refs[owner] > 0 {
+		w.owners.mu.Unlock()
+		return nil
+	}
+	delete(o.refs, owner)
+	if len(o.refs) > 0 {
+		w.owners.mu.Unlock()
+		w.trace(path, "released by %s, still held by %s", owner, strings.Join(o.owners(), ", "))
+		return nil
+	}
+	delete(w.owners.paths, path)
+	w.owners.mu.Unlock()
+	if o.shared {
+		return nil
+	}
+	return w.Remove(path)
+}
+
+// ListWatched returns the watched paths with their owners, sorted by path.
+func (w *FileWatcher) ListWatched() []WatchedPath {
+	paths := w.WatchedPaths()
+	res := make([]WatchedPath, 0, len(paths))
+	w.owners.mu.Lock()
+	defer w.owners.mu.Unlock()
+	for _, path := range paths {
+		entry := WatchedPath{Path: path}
+		if o, ok := w.owners.paths[path]; ok {
+			entry.Owners = o.owners()
+			for _, refs := range o.refs {
+				entry.Refs += refs
+			}
+		}
+		res = append(res, entry)
+	}
+	return res
+}
+
+func (o *ownership) owners() []string {
+	res := make([]string, 0, len(o.refs))
+	for owner := range o.refs {
+		res = append(res, owner)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// forgetOwners drops the owners of path, once its watch is removed.
+func (w *FileWatcher) forgetOwners(path string) {
+	w.owners.mu.Lock()
+	defer w.owners.mu.Unlock()
+	if o, ok := w.owners.paths[path]; ok {
+		w.trace(path, "watch removed while held by %s", strings.Join(o.owners(), ", "))
+		delete(w.owners.paths, path)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package fileWatcher
+
+// normalizePath returns the form of path used for WatchedMap keys and event paths, absolute and cleaned.
+func normalizePath(path string) string {
+	return absPath(path)
+}
+
+// isNetworkPath reports whether path is on a UNC share. UNC paths only exist on Windows.
+func isNetworkPath(path string) bool {
+	return false
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package fileWatcher
+
+import "strings"
+
+// normalizePath returns the form of path used for WatchedMap keys and event paths. Long path prefixes are removed,
+// `\\?\C:\dir` becomes `C:\dir` and `\\?\UNC\server\share` becomes `\\server\share`, the os package adds them back
+// when a path is too long. The path is made absolute and cleaned, which also turns the forward slashes into
+// backslashes.
+func normalizePath(path string) string {
+	switch {
+	case strings.HasPrefix(path, `\\?\UNC\`):
+		path = `\\` + path[len(`\\?\UNC\`):]
+	case strings.HasPrefix(path, `\\?\`):
+		path = path[len(`\\?\`):]
+	}
+	return absPath(path)
+}
+
+// isNetworkPath reports whether path is on a UNC share, where change notifications are unreliable.
+func isNetworkPath(path string) bool {
+	return strings.HasPrefix(normalizePath(path), `\\`)
+}
@@ -0,0 +1,42 @@
+package fileWatcher
+
+import "sync"
+
+// pathLocks serializes the Add and Remove calls of a path, so a path is never added and removed at once, while the
+// calls about different paths, and the event loop, run concurrently.
+type pathLocks struct {
+	mu    sync.Mutex
+	paths map[string]*pathLock
+}
+
+type pathLock struct {
+	mu sync.Mutex
+	// refs counts the callers holding or waiting for the lock, it is dropped once nobody needs it.
+	refs int
+}
+
+// lock locks path and returns the function unlocking it. It is not reentrant.
+func (l *pathLocks) lock(path string) func() {
+	l.mu.Lock()
+	if l.paths == nil {
+		l.paths = make(map[string]*pathLock)
+	}
+	p, ok := l.paths[path]
+	if !ok {
+		p = &pathLock{}
+		l.paths[path] = p
+	}
+	p.refs++
+	l.mu.Unlock()
+
+	p.mu.Lock()
+	return func() {
+		p.mu.Unlock()
+		l.mu.Lock()
+		p.refs--
+		if p.refs == 0 {
+			delete(l.paths, path)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,170 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestPathLocksSerializeOnePath(t *testing.T) {
+	l := pathLocks{}
+	var inside, overlaps int32
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				unlock := l.lock("/watched")
+				if atomic.AddInt32(&inside, 1) > 1 {
+					atomic.AddInt32(&overlaps, 1)
+				}
+				atomic.AddInt32(&inside, -1)
+				unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if overlaps > 0 {
+		t.Fatalf("%d callers held the lock of the same path at once", overlaps)
+	}
+	if len(l.paths) != 0 {
+		t.Fatalf("%d locks left once released", len(l.paths))
+	}
+}
+
+func TestPathLocksDistinctPathsConcurrent(t *testing.T) {
+	l := pathLocks{}
+	unlock := l.lock("/a")
+	defer unlock()
+	locked := make(chan struct{})
+	go func() {
+		l.lock("/b")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(testTimeout):
+		t.Fatal("the lock of /a held back the lock of /b")
+	}
+}
+
+// TestConcurrentAddRemoveUnderLoad adds, checks and removes distinct paths from many goroutines while files are
+// written in the watched folders, run it with -race.
+func TestConcurrentAddRemoveUnderLoad(t *testing.T) {
+	const goroutines, rounds = 8, 20
+	root := t.TempDir()
+	w := newTestWatcher(t)
+	go func() {
+		for range w.Events {
+		}
+	}()
+
+	stop := make(chan struct{})
+	var writers sync.WaitGroup
+	writers.Add(1)
+	go func() {
+		defer writers.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			_ = os.WriteFile(filepath.Join(root, fmt.Sprintf("g%d-0", i%goroutines), "load"), nil, 0644)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				dir := filepath.Join(root, fmt.Sprintf("g%d-%d", g, r))
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					errs <- err
+					return
+				}
+				if err := w.Add(dir); err != nil {
+					errs <- err
+					return
+				}
+				if !w.Contains(dir) {
+					errs <- fmt.Errorf("%s is not watched after Add", dir)
+					return
+				}
+				if r%2 == 1 {
+					if err := w.Remove(dir); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(stop)
+	writers.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	for g := 0; g < goroutines; g++ {
+		for r := 0; r < rounds; r++ {
+			dir := filepath.Join(root, fmt.Sprintf("g%d-%d", g, r))
+			if w.Contains(dir) != (r%2 == 0) {
+				t.Fatalf("%s watched: %v", dir, w.Contains(dir))
+			}
+		}
+	}
+}
+
+// BenchmarkAddParallel adds distinct paths from 1 to 8 goroutines on a synthetic filesystem, so the kernel does not
+// serialize the calls. The adds/s metric grows linearly with the goroutines, up to the number of CPUs, while the calls
+// don't contend.
+func BenchmarkAddParallel(b *testing.B) {
+	for _, goroutines := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+			fsys := NewSyntheticFs(afero.NewMemMapFs())
+			dirs := make([]string, b.N)
+			for i := range dirs {
+				dirs[i] = fmt.Sprintf("/watched/%d", i)
+				if err := fsys.Fs.MkdirAll(dirs[i], 0755); err != nil {
+					b.Fatal(err)
+				}
+			}
+			w, err := Init(nil, fsys, nopLogger{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer w.Close()
+
+			var next int64 = -1
+			var wg sync.WaitGroup
+			b.ResetTimer()
+			start := time.Now()
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := atomic.AddInt64(&next, 1); i < int64(b.N); i = atomic.AddInt64(&next, 1) {
+						if err := w.Add(dirs[i]); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "adds/s")
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package fileWatcher
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotBelowRoot is returned by FileWatcherEvent.Rel for the events of paths outside of the root.
+var ErrNotBelowRoot = errors.New("the path of the event is not below the root")
+
+// absPath returns path absolute and cleaned, or only cleaned when the working directory is unknown.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// normalize returns the form of path used by the watcher, see normalizePath, in the case of the names on disk with the
+// CanonicalCase option.
+func (w *FileWatcher) normalize(path string) string {
+	path = normalizePath(path)
+	if !w.options.CanonicalCase {
+		return path
+	}
+	return w.canonicalCase(path)
+}
+
+// canonicalCase returns the absolute path in the case of the names on disk. The names not found on disk, like the
+// ones of paths gone already, are kept as given.
+func (w *FileWatcher) canonicalCase(path string) string {
+	volume := filepath.VolumeName(path)
+	res := volume + string(filepath.Separator)
+	rest := strings.TrimPrefix(path[len(volume):], string(filepath.Separator))
+	if rest == "" {
+		return path
+	}
+	names := strings.Split(rest, string(filepath.Separator))
+	for i, name := range names {
+		dir, err := w.fs.Open(res)
+		if err != nil {
+			return filepath.Join(append([]string{res}, names[i:]...)...)
+		}
+		entries, err := dir.Readdirnames(-1)
+		_ = dir.Close()
+		if err != nil {
+			return filepath.Join(append([]string{res}, names[i:]...)...)
+		}
+		found := ""
+		for _, entry := range entries {
+			if entry == name {
+				found = entry
+				break
+			}
+			if found == "" && strings.EqualFold(entry, name) {
+				found = entry
+			}
+		}
+		// on a case-sensitive filesystem, a name differing in case from an entry is another file
+		if found == "" || (found != name && !w.exists(filepath.Join(res, name))) {
+			found = name
+		}
+		res = filepath.Join(res, found)
+	}
+	return res
+}
+
+// exists reports whether there is a file or a folder at path.
+func (w *FileWatcher) exists(path string) bool {
+	_, err := w.fs.Stat(path)
+	return err == nil
+}
+
+// Rel returns the path of the event relative to root, like a watched root, or ErrNotBelowRoot when the path is not
+// at or below root. The path of the event itself is "." when it is root.
+func (e FileWatcherEvent) Rel(root string) (string, error) {
+	root = normalizePath(root)
+	if e.Path != root && !isBelow(e.Path, root) {
+		return "", ErrNotBelowRoot
+	}
+	return filepath.Rel(root, e.Path)
+}
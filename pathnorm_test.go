@@ -0,0 +1,68 @@
+package fileWatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAddSQLiteRelativePath(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	if err := os.Mkdir("data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join("data", "app.db"), "")
+
+	w := newTestWatcher(t, WithSQLiteDebounce(50*time.Millisecond))
+	if err := w.AddSQLite(filepath.Join("data", "app.db")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join("data", "app.db-wal"), "commit")
+
+	want, _ := filepath.Abs(filepath.Join("data", "app.db"))
+	e := waitEvent(t, w, func(FileWatcherEvent) bool { return true })
+	if e.Event != EventDBChanged || e.Path != want {
+		t.Fatalf("got %s %s, want DB_CHANGED %s", e.Event, e.Path, want)
+	}
+}
+
+func TestFingerprintRelativePathInvalidated(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFile(t, "file", "first")
+
+	w := newTestWatcher(t)
+	if _, err := w.Fingerprint("file"); err != nil {
+		t.Fatal(err)
+	}
+	abs, _ := filepath.Abs("file")
+	w.fingerprints.invalidate(abs)
+	if stats := w.fingerprintStats(); stats.Entries != 0 {
+		t.Fatalf("the fingerprint of the relative path survived the invalidation of %s", abs)
+	}
+}
+
+func TestRescanRelativePath(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	if err := os.Mkdir("sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newTestWatcher(t)
+	if err := w.Add("sub"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join("sub", "file"), "")
+	if err := w.Rescan("sub"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.listings.dirs["sub"]; ok {
+		t.Fatal("Rescan kept a listing keyed by the relative path")
+	}
+	if _, ok := w.Listing("sub"); !ok {
+		t.Fatal("no listing for the relative path")
+	}
+}
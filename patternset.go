@@ -0,0 +1,254 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// patternSet is a set of glob patterns, with the syntax of matchPattern, indexed so that deciding a path matches none
+// of thousands of patterns costs a few map lookups instead of evaluating every pattern.
+//
+//   - patterns that are a plain name, like ".DS_Store", are looked up by the base name of the path
+//   - patterns like "*.tmp" are looked up by the extension of the path
+//   - patterns with a literal path element, like "node_modules/**", are only evaluated when the path has that element
+//   - the remaining patterns are evaluated for every path
+type patternSet struct {
+	mu          sync.RWMutex
+	patterns    []string
+	names       map[string]struct{}
+	exts        map[string]struct{}
+	byComponent map[string][]string
+	rest        []string
+}
+
+func newPatternSet() *patternSet {
+	return &patternSet{
+		names:       make(map[string]struct{}),
+		exts:        make(map[string]struct{}),
+		byComponent: make(map[string][]string),
+	}
+}
+
+// set replaces the patterns of the set.
+func (s *patternSet) set(patterns ...string) error {
+	for _, pattern := range patterns {
+		if err := validPattern(pattern); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.patterns = nil
+	s.names = make(map[string]struct{})
+	s.exts = make(map[string]struct{})
+	s.byComponent = make(map[string][]string)
+	s.rest = nil
+	for _, pattern := range patterns {
+		s.patterns = append(s.patterns, pattern)
+		s.index(filepath.ToSlash(pattern))
+	}
+	return nil
+}
+
+// add adds patterns to the set.
+func (s *patternSet) add(patterns ...string) error {
+	for _, pattern := range patterns {
+		if err := validPattern(pattern); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, pattern := range patterns {
+		s.patterns = append(s.patterns, pattern)
+		s.index(filepath.ToSlash(pattern))
+	}
+	return nil
+}
+
+// list returns the patterns of the set in the order they were added.
+func (s *patternSet) list() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.patterns...)
+}
+
+// empty reports whether the set has no pattern.
+func (s *patternSet) empty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.patterns) == 0
+}
+
+func (s *patternSet) index(pattern string) {
+	if !strings.Contains(pattern, "/") {
+		if !hasMeta(pattern) {
+			s.names[pattern] = struct{}{}
+			return
+		}
+		if strings.HasPrefix(pattern, "*.") && !hasMeta(pattern[1:]) {
+			s.exts[pattern[1:]] = struct{}{}
+			return
+		}
+		s.rest = append(s.rest, pattern)
+		return
+	}
+
+	for _, component := range strings.Split(pattern, "/") {
+		if component != "" && !hasMeta(component) {
+			s.byComponent[component] = append(s.byComponent[component], pattern)
+			return
+		}
+	}
+	s.rest = append(s.rest, pattern)
+}
+
+// match reports whether path matches a pattern of the set.
+func (s *patternSet) match(path string) bool {
+	_, ok := s.matching(path)
+	return ok
+}
+
+// matching returns the first pattern of the set matching path.
+func (s *patternSet) matching(path string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.patterns) == 0 {
+		return "", false
+	}
+
+	base := path[strings.LastIndexAny(path, `/\`)+1:]
+	if _, ok := s.names[base]; ok {
+		return base, true
+	}
+	if dot := strings.LastIndexByte(base, '.'); dot >= 0 {
+		if _, ok := s.exts[base[dot:]]; ok {
+			return "*" + base[dot:], true
+		}
+	}
+
+	if len(s.byComponent) > 0 {
+		rest := path
+		for rest != "" {
+			var component string
+			if i := strings.IndexAny(rest, `/\`); i >= 0 {
+				component, rest = rest[:i], rest[i+1:]
+			} else {
+				component, rest = rest, ""
+			}
+			for _, pattern := range s.byComponent[component] {
+				if matchPattern(pattern, path) {
+					return pattern, true
+				}
+			}
+		}
+	}
+
+	for _, pattern := range s.rest {
+		if matchPattern(pattern, path) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// DefaultExcludePatterns are the exclude patterns of a new watcher.
+var DefaultExcludePatterns = []string{".DS_Store"}
+
+// AddIgnorePatterns drops the events of every path matching one of patterns, in addition to the exclude patterns
+// already set. Patterns without a slash, like "*.tmp", are matched against the file name. Patterns with a slash are
+// matched against the end of the path, or the whole path when they start with a slash, and "**" matches any number of
+// directories, like "node_modules/**". Large pattern lists are indexed, so the common case of a path not being ignored
+// stays cheap.
+func (w *FileWatcher) AddIgnorePatterns(patterns ...string) error {
+	return w.ignores.add(patterns...)
+}
+
+// IgnorePatterns returns the exclude patterns, starting with DefaultExcludePatterns unless they were replaced.
+func (w *FileWatcher) IgnorePatterns() []string {
+	return w.ignores.list()
+}
+
+// SetExcludePatterns replaces the exclude patterns, DefaultExcludePatterns included, with patterns. The syntax is the
+// one of AddIgnorePatterns.
+func (w *FileWatcher) SetExcludePatterns(patterns ...string) error {
+	return w.ignores.set(patterns...)
+}
+
+// SetIncludePatterns only passes on the events of files matching one of patterns, for example "*.go" and "*.md".
+// Folders are not subject to the include patterns, so folder events and the files inside them still come through.
+// Exclude patterns win over include patterns. No pattern, the default, includes every file.
+func (w *FileWatcher) SetIncludePatterns(patterns ...string) error {
+	return w.includes.set(patterns...)
+}
+
+// IncludePatterns returns the patterns set with SetIncludePatterns.
+func (w *FileWatcher) IncludePatterns() []string {
+	return w.includes.list()
+}
+
+// included reports whether path passes the include patterns. It is only called when there are include patterns or
+// matchers, the matchers are checked by the caller.
+func (w *FileWatcher) included(path string) bool {
+	if w.includes.match(path) {
+		return true
+	}
+	if entry, ok := w.listings.lookup(path); ok {
+		return entry.isDir
+	}
+	info, err := w.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// ignored reports whether the events of path are dropped.
+func (w *FileWatcher) ignored(path string) bool {
+	_, ok := w.ignoreReason(path)
+	return ok
+}
+
+// excludedDir reports whether the directory path is left out of recursive watches by the exclude patterns or the
+// ignore files. Unlike ignored, it does not depend on state that can change later, like paused groups.
+func (w *FileWatcher) excludedDir(path string) bool {
+	if w.ignores.match(path) {
+		return true
+	}
+	if _, ok := w.ignoringMatcher(path); ok {
+		return true
+	}
+	_, ok := w.ignoreFiles.match(path)
+	return ok
+}
+
+// ignoreReason returns what causes the events of path to be dropped.
+func (w *FileWatcher) ignoreReason(path string) (string, bool) {
+	if pattern, ok := w.ignores.matching(path); ok {
+		return "exclude pattern " + pattern, true
+	}
+	if m, ok := w.ignoringMatcher(path); ok {
+		return "ignore matcher " + describeMatcher(m), true
+	}
+	if matchers, matched := w.includeMatched(path); (matchers || !w.includes.empty()) && !matched && !w.included(path) {
+		return "the include patterns and matchers, the file matches none of them", true
+	}
+	if m, watch, ok := w.watchMatcher(path); ok && !m.Match(path) {
+		return "the matcher " + describeMatcher(m) + " of " + watch, true
+	}
+	if rule, ok := w.ignoreFiles.match(path); ok {
+		return "ignore file rule " + rule, true
+	}
+	if reason, ok := w.scopes.excluded(path); ok {
+		return reason, true
+	}
+	if group, ok := w.pausedGroup(path); ok {
+		return "the paused group " + group, true
+	}
+	return "", false
+}
@@ -0,0 +1,121 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+type pauseState struct {
+	mu     sync.Mutex
+	paused bool
+	// snapshot is the state of the watched paths when the watcher was paused, by watched path.
+	snapshot map[string]map[string]polledEntry
+	// hash is set when the snapshot has the content hashes of the files, see InitWithSnapshot.
+	hash bool
+}
+
+// Pause stops emitting events, for bulk operations the application performs itself, like the atomic rewrite of a
+// directory. The watches are kept in line with the changes meanwhile, but nothing is reported until Resume.
+func (w *FileWatcher) Pause() {
+	w.pause.mu.Lock()
+	defer w.pause.mu.Unlock()
+	if w.pause.paused {
+		return
+	}
+	w.pause.snapshot = w.snapshotWatched(false)
+	w.pause.hash = false
+	w.pause.paused = true
+	w.log.Debug("Watcher paused")
+}
+
+// Resume emits events again. The watched paths are rescanned and what changed while the watcher was paused is
+// reported with CREATE, EDIT and DELETE events, the net effect of the changes: a file created and deleted meanwhile is
+// not reported, a renamed one is reported as deleted and created.
+func (w *FileWatcher) Resume() {
+	w.pause.mu.Lock()
+	if !w.pause.paused {
+		w.pause.mu.Unlock()
+		return
+	}
+	// the live events are emitted again before the rescan, so a change racing with it is reported twice rather than
+	// lost
+	before := w.pause.snapshot
+	hash := w.pause.hash
+	w.pause.snapshot = nil
+	w.pause.paused = false
+	w.pause.mu.Unlock()
+	w.log.Debug("Watcher resumed")
+	w.emitChangesSince(before, hash, "changed while the watcher was paused")
+}
+
+// emitChangesSince rescans the watched paths and emits what changed since the snapshot before, as the net effect of
+// the changes. The paths watched after the snapshot was taken are not reported.
+func (w *FileWatcher) emitChangesSince(before map[string]map[string]polledEntry, hash bool, why string) {
+	after := w.snapshotWatched(hash)
+	// the events of diffPolled only have a path and a kind
+	type polledEvent struct {
+		path string
+		kind EventKind
+	}
+	seen := make(map[polledEvent]bool)
+	paths := make([]string, 0, len(after))
+	for path := range after {
+		paths = append(paths, path)
+	}
+	// folders are reported before their entries
+	sort.Strings(paths)
+	for _, path := range paths {
+		current := after[path]
+		previous, ok := before[path]
+		if !ok && !w.createdWhilePaused(path, before) {
+			// added since the snapshot
+			continue
+		}
+		for _, e := range diffPolled(path, previous, current) {
+			if seen[polledEvent{e.Path, e.Event}] || isSentinel(e.Path) {
+				continue
+			}
+			seen[polledEvent{e.Path, e.Event}] = true
+			if reason, ignored := w.ignoreReason(e.Path); ignored {
+				w.trace(e.Path, "ignored by %s", reason)
+				continue
+			}
+			w.traceEvent(e, why)
+			w.emit(e)
+		}
+	}
+}
+
+// createdWhilePaused reports whether the watched path is a folder created in a recursive tree while the watcher was
+// paused, whose entries are new as well.
+func (w *FileWatcher) createdWhilePaused(path string, before map[string]map[string]polledEntry) bool {
+	for dir := filepath.Dir(path); dir != path; path, dir = dir, filepath.Dir(dir) {
+		if _, ok := before[dir]; ok {
+			return w.recursiveRoot(path)
+		}
+	}
+	return false
+}
+
+// Paused reports whether the watcher is paused.
+func (w *FileWatcher) Paused() bool {
+	w.pause.mu.Lock()
+	defer w.pause.mu.Unlock()
+	return w.pause.paused
+}
+
+// snapshotWatched returns the state of every watched path: its entries for a directory, itself for a file. Files are
+// hashed when hash is set.
+func (w *FileWatcher) snapshotWatched(hash bool) map[string]map[string]polledEntry {
+	res := make(map[string]map[string]polledEntry)
+	for _, path := range w.WatchedMap.Keys() {
+		state, err := poll(w.fs, path, hash)
+		if err != nil {
+			w.log.Debug("Unable to snapshot ", path, ": ", err)
+			state = map[string]polledEntry{}
+		}
+		res[path] = state
+	}
+	return res
+}
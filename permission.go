@@ -0,0 +1,161 @@
+package fileWatcher
+
+import (
+	"errors"
+	"github.com/spf13/afero"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// ErrProtectUnsupported is returned by Protect on platforms other than Linux.
+var ErrProtectUnsupported = errors.New("protection is only supported on Linux")
+
+// OpenRequest is an attempt to open a file of a protected tree.
+type OpenRequest struct {
+	Path string
+	// PID is the id of the process opening the file.
+	PID int
+}
+
+// OpenPolicy decides whether an open is allowed.
+type OpenPolicy func(r OpenRequest) bool
+
+type permissionState struct {
+	mu       sync.RWMutex
+	notifier *permissionNotifier
+	// roots holds the policies of the protected trees.
+	roots map[string]OpenPolicy
+	// dirs holds the marked directories.
+	dirs map[string]bool
+}
+
+// Protect watches path recursively, like AddRecursive, and lets policy allow or deny every open of the files below it,
+// for reading or for writing, before it happens. Denied opens fail with EPERM. The opens of the process itself are
+// always allowed, and when trees are nested the policy of the deepest one decides.
+//
+// Protection is only available on Linux, where it relies on fanotify permission events, which need the
+// CAP_SYS_ADMIN capability. Elsewhere ErrProtectUnsupported is returned. The opening process waits for the policy,
+// which is called for one open at a time, so it must return quickly. Directories created in the tree are protected
+// once their CREATE_FOLDER event is emitted; the files created in them before are not.
+func (w *FileWatcher) Protect(path string, policy OpenPolicy) error {
+	path = w.normalize(path)
+	if err := w.AddRecursive(path); err != nil {
+		return err
+	}
+
+	w.permissions.mu.Lock()
+	if w.permissions.notifier == nil {
+		notifier, err := newPermissionNotifier()
+		if err != nil {
+			w.permissions.mu.Unlock()
+			return err
+		}
+		w.permissions.notifier = notifier
+		w.permissions.roots = make(map[string]OpenPolicy)
+		w.permissions.dirs = make(map[string]bool)
+		go func() {
+			w.labelGoroutine("open-permissions")
+			notifier.run(w.allowOpen)
+		}()
+	}
+	w.permissions.roots[path] = policy
+	w.permissions.mu.Unlock()
+
+	return w.protectTree(path)
+}
+
+// Unprotect stops protecting the tree path was protected with. It is still watched.
+func (w *FileWatcher) Unprotect(path string) {
+	path = w.normalize(path)
+	w.permissions.mu.Lock()
+	defer w.permissions.mu.Unlock()
+	if _, ok := w.permissions.roots[path]; !ok {
+		return
+	}
+	delete(w.permissions.roots, path)
+	for dir := range w.permissions.dirs {
+		if w.protectingRoot(dir) != "" {
+			continue
+		}
+		delete(w.permissions.dirs, dir)
+		if err := w.permissions.notifier.unmark(dir); err != nil {
+			w.log.Debug("Unable to stop protecting ", dir, ": ", err)
+		}
+	}
+}
+
+// protectTree marks root and the directories below it when they are part of a protected tree.
+func (w *FileWatcher) protectTree(root string) error {
+	w.permissions.mu.Lock()
+	defer w.permissions.mu.Unlock()
+	if w.permissions.notifier == nil || w.protectingRoot(root) == "" {
+		return nil
+	}
+	return afero.Walk(w.fs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path != root {
+				// removed while walking
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() || w.permissions.dirs[path] {
+			return nil
+		}
+		if err := w.permissions.notifier.mark(path); err != nil {
+			return err
+		}
+		w.permissions.dirs[path] = true
+		return nil
+	})
+}
+
+// protectingRoot returns the deepest protected tree path is part of, with the lock held.
+func (w *FileWatcher) protectingRoot(path string) string {
+	found := ""
+	for root := range w.permissions.roots {
+		if (path == root || isBelow(path, root)) && len(root) > len(found) {
+			found = root
+		}
+	}
+	return found
+}
+
+// allowOpen asks the policy of the protected tree path is part of whether the process pid may open it.
+func (w *FileWatcher) allowOpen(path string, pid int) (allowed bool) {
+	if pid == os.Getpid() {
+		return true
+	}
+	path = w.normalize(path)
+	w.permissions.mu.RLock()
+	policy := w.permissions.roots[w.protectingRoot(path)]
+	w.permissions.mu.RUnlock()
+	if policy == nil {
+		return true
+	}
+
+	defer func() {
+		// a failing policy must not leave the opening process blocked
+		if r := recover(); r != nil {
+			w.log.Error("Open policy failed for ", path, ": ", r)
+			allowed = true
+		}
+	}()
+	allowed = policy(OpenRequest{Path: path, PID: pid})
+	if !allowed {
+		w.log.Debug("Denied the open of " + path + " by process " + strconv.Itoa(pid))
+	}
+	return allowed
+}
+
+func (w *FileWatcher) stopPermissions() {
+	w.permissions.mu.Lock()
+	defer w.permissions.mu.Unlock()
+	if w.permissions.notifier != nil {
+		_ = w.permissions.notifier.close()
+		w.permissions.notifier = nil
+		w.permissions.roots = nil
+		w.permissions.dirs = nil
+	}
+}
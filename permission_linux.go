@@ -0,0 +1,75 @@
+//go:build linux
+
+package fileWatcher
+
+import (
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+	"strconv"
+	"unsafe"
+)
+
+// permissionNotifier asks for a decision on every open of the files of the marked directories through fanotify
+// permission events, which need the CAP_SYS_ADMIN capability.
+type permissionNotifier struct {
+	file *os.File
+	fd   int
+}
+
+func newPermissionNotifier() (*permissionNotifier, error) {
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_CONTENT|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK, unix.O_RDONLY|unix.O_LARGEFILE)
+	if err != nil {
+		if errors.Is(err, unix.EPERM) {
+			return nil, errors.New("protection needs the CAP_SYS_ADMIN capability")
+		}
+		return nil, os.NewSyscallError("fanotify_init", err)
+	}
+	return &permissionNotifier{file: os.NewFile(uintptr(fd), "fanotify"), fd: fd}, nil
+}
+
+// mark asks for the opens of the files directly in dir.
+func (n *permissionNotifier) mark(dir string) error {
+	err := unix.FanotifyMark(n.fd, unix.FAN_MARK_ADD, unix.FAN_OPEN_PERM|unix.FAN_EVENT_ON_CHILD, unix.AT_FDCWD, dir)
+	return os.NewSyscallError("fanotify_mark", err)
+}
+
+func (n *permissionNotifier) unmark(dir string) error {
+	err := unix.FanotifyMark(n.fd, unix.FAN_MARK_REMOVE, unix.FAN_OPEN_PERM|unix.FAN_EVENT_ON_CHILD, unix.AT_FDCWD, dir)
+	return os.NewSyscallError("fanotify_mark", err)
+}
+
+// run answers every open with the decision of allow until the notifier is closed. The opens still waiting for an
+// answer then are allowed by the kernel.
+func (n *permissionNotifier) run(allow func(path string, pid int) bool) {
+	buf := make([]byte, 256*metadataSize)
+	for {
+		count, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+metadataSize <= count; {
+			meta := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buf[offset]))
+			if int(meta.Event_len) < metadataSize {
+				break
+			}
+			offset += int(meta.Event_len)
+			if meta.Fd == unix.FAN_NOFD {
+				continue
+			}
+
+			response := unix.FanotifyResponse{Fd: meta.Fd, Response: unix.FAN_ALLOW}
+			path, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(int(meta.Fd)))
+			if err == nil && !allow(path, int(meta.Pid)) {
+				response.Response = unix.FAN_DENY
+			}
+			// the opening process is blocked until the response is written
+			_, _ = n.file.Write((*[unsafe.Sizeof(response)]byte)(unsafe.Pointer(&response))[:])
+			unix.Close(int(meta.Fd))
+		}
+	}
+}
+
+func (n *permissionNotifier) close() error {
+	return n.file.Close()
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package fileWatcher
+
+// permissionNotifier is not available on this platform.
+type permissionNotifier struct{}
+
+func newPermissionNotifier() (*permissionNotifier, error) {
+	return nil, ErrProtectUnsupported
+}
+
+func (n *permissionNotifier) mark(dir string) error {
+	return ErrProtectUnsupported
+}
+
+func (n *permissionNotifier) unmark(dir string) error {
+	return ErrProtectUnsupported
+}
+
+func (n *permissionNotifier) run(allow func(path string, pid int) bool) {}
+
+func (n *permissionNotifier) close() error {
+	return nil
+}
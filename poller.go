@@ -0,0 +1,365 @@
+package fileWatcher
+
+import (
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const defaultPollInterval = 2 * time.Second
+
+// defaultKqueueFileBudget is the kqueue file budget when the open file limit is unknown or unlimited.
+const defaultKqueueFileBudget = 4096
+
+// polledEntry is the state of a polled path or of an entry of a polled directory.
+type polledEntry struct {
+	isDir   bool
+	size    int64
+	modTime time.Time
+	// hash is the content hash of files, only set with PollingBackend.Hash.
+	hash string
+}
+
+// AdaptivePollingOptions adapts the interval of every polled path to its changes, which makes polling large trees
+// viable, like network mounts: a path changing at a poll is polled twice as often, down to MinInterval, and a path
+// that didn't change half as often, up to MaxInterval. The paths start at the PollInterval option. Polling adapts
+// when MaxInterval is above MinInterval, MinInterval defaulting to a tenth of the PollInterval option.
+type AdaptivePollingOptions struct {
+	MinInterval time.Duration
+	MaxInterval time.Duration
+}
+
+// PollStats is the accounting of the polled paths.
+type PollStats struct {
+	// Paths is the number of polled paths.
+	Paths int
+	// Polls counts the polls of a path, Changes the polls that found it changed.
+	Polls   uint64
+	Changes uint64
+	// Hot counts the paths polled at the MinInterval of the AdaptivePolling option, Cold the paths polled at its
+	// MaxInterval.
+	Hot  int
+	Cold int
+}
+
+// poller watches paths by comparing their state at an interval, for filesystems that don't deliver change
+// notifications. It emits classified events directly.
+type poller struct {
+	mu    sync.Mutex
+	paths map[string]map[string]polledEntry
+	// schedules holds when the paths are polled next.
+	schedules map[string]*pollSchedule
+	stop      chan struct{}
+	polls     uint64
+	changes   uint64
+}
+
+type pollSchedule struct {
+	interval time.Duration
+	due      time.Time
+}
+
+func newPolledEntry(fsys afero.Fs, path string, info os.FileInfo, hash bool) polledEntry {
+	entry := polledEntry{isDir: info.IsDir(), size: info.Size(), modTime: info.ModTime()}
+	if hash && !entry.isDir {
+		if doc, err := describeFile(fsys, path); err == nil {
+			entry.hash = doc.Hash
+		}
+	}
+	return entry
+}
+
+// poll returns the current state of path on fsys: its entries for a directory, or itself under the empty name for a
+// file. Files are hashed when hash is set.
+func poll(fsys afero.Fs, path string, hash bool) (map[string]polledEntry, error) {
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return map[string]polledEntry{"": newPolledEntry(fsys, path, info, hash)}, nil
+	}
+
+	entries, err := afero.ReadDir(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]polledEntry, len(entries))
+	for _, info := range entries {
+		res[info.Name()] = newPolledEntry(fsys, filepath.Join(path, info.Name()), info, hash)
+	}
+	return res, nil
+}
+
+// shouldPoll reports whether path is watched by polling instead of change notifications.
+func (w *FileWatcher) shouldPoll(path string) bool {
+	return w.backend().polls(w, path)
+}
+
+// pollsFile reports whether a file added on its own is polled by modification time instead of watched by kqueue, which
+// holds a file descriptor per watched path: directories keep their watch, but past the KqueueFileBudget option the
+// files are polled.
+func (w *FileWatcher) pollsFile() bool {
+	auto, ok := kqueueFileBudget()
+	if !ok || w.options.KqueueFileBudget < 0 {
+		return false
+	}
+	if _, ok := w.currentNotifier().(fsnotifyNotifier); !ok {
+		return false
+	}
+	budget := w.options.KqueueFileBudget
+	if budget == 0 {
+		budget = auto
+	}
+	return w.Len() >= budget
+}
+
+// pollHash reports whether polled files are compared by content.
+func (w *FileWatcher) pollHash() bool {
+	b, ok := w.options.Backend.(PollingBackend)
+	return ok && b.Hash
+}
+
+// addPolled starts polling path.
+func (w *FileWatcher) addPolled(path string) error {
+	state, err := poll(w.fs, path, w.pollHash())
+	if err != nil {
+		return err
+	}
+
+	w.poller.mu.Lock()
+	defer w.poller.mu.Unlock()
+	if w.poller.paths == nil {
+		w.poller.paths = make(map[string]map[string]polledEntry)
+	}
+	w.poller.paths[path] = state
+	if w.poller.schedules == nil {
+		w.poller.schedules = make(map[string]*pollSchedule)
+	}
+	interval := w.pollInterval()
+	if min, max, ok := w.adaptivePolling(); ok {
+		interval = clampDuration(interval, min, max)
+	}
+	w.poller.schedules[path] = &pollSchedule{interval: interval, due: time.Now().Add(interval)}
+
+	if w.poller.stop == nil {
+		w.poller.stop = make(chan struct{})
+		go w.runPoller(w.poller.stop)
+	}
+	return nil
+}
+
+// removePolled stops polling path and reports whether it was polled.
+func (w *FileWatcher) removePolled(path string) bool {
+	w.poller.mu.Lock()
+	defer w.poller.mu.Unlock()
+	if _, ok := w.poller.paths[path]; !ok {
+		return false
+	}
+	delete(w.poller.paths, path)
+	delete(w.poller.schedules, path)
+	return true
+}
+
+// stopPoller stops the polling goroutine.
+func (w *FileWatcher) stopPoller() {
+	w.poller.mu.Lock()
+	defer w.poller.mu.Unlock()
+	if w.poller.stop != nil {
+		close(w.poller.stop)
+		w.poller.stop = nil
+	}
+}
+
+func (w *FileWatcher) runPoller(stop chan struct{}) {
+	w.labelGoroutine("poller")
+	tick := w.pollInterval()
+	if min, _, ok := w.adaptivePolling(); ok {
+		tick = min
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.pollOnce()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (w *FileWatcher) pollInterval() time.Duration {
+	if w.options.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return w.options.PollInterval
+}
+
+// adaptivePolling returns the bounds of the intervals of the polled paths, and whether they adapt to the changes.
+func (w *FileWatcher) adaptivePolling() (time.Duration, time.Duration, bool) {
+	min, max := w.options.AdaptivePolling.MinInterval, w.options.AdaptivePolling.MaxInterval
+	if min <= 0 {
+		min = w.pollInterval() / 10
+	}
+	return min, max, max > min
+}
+
+func clampDuration(d time.Duration, min time.Duration, max time.Duration) time.Duration {
+	if d < min {
+		return min
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// PollInterval returns the current interval between two polls of the polled path, see AdaptivePollingOptions. It
+// reports false when path is not polled.
+func (w *FileWatcher) PollInterval(path string) (time.Duration, bool) {
+	path = w.normalize(path)
+	w.poller.mu.Lock()
+	defer w.poller.mu.Unlock()
+	s, ok := w.poller.schedules[path]
+	if !ok {
+		return 0, false
+	}
+	return s.interval, true
+}
+
+// reschedule sets when path is polled next, after a poll that found it changed or not.
+func (w *FileWatcher) reschedule(path string, changed bool, now time.Time) {
+	w.poller.polls++
+	if changed {
+		w.poller.changes++
+	}
+	s, ok := w.poller.schedules[path]
+	if !ok {
+		return
+	}
+	if min, max, ok := w.adaptivePolling(); ok {
+		interval := s.interval * 2
+		if changed {
+			interval = s.interval / 2
+		}
+		interval = clampDuration(interval, min, max)
+		if interval != s.interval {
+			w.trace(path, "polled every %s", interval)
+		}
+		s.interval = interval
+	}
+	s.due = now.Add(s.interval)
+}
+
+func (w *FileWatcher) pollStats() PollStats {
+	w.poller.mu.Lock()
+	defer w.poller.mu.Unlock()
+	res := PollStats{Paths: len(w.poller.paths), Polls: w.poller.polls, Changes: w.poller.changes}
+	if min, max, ok := w.adaptivePolling(); ok {
+		for _, s := range w.poller.schedules {
+			switch s.interval {
+			case min:
+				res.Hot++
+			case max:
+				res.Cold++
+			}
+		}
+	}
+	return res
+}
+
+// pollOnce compares every polled path due with its previous state and emits the differences.
+func (w *FileWatcher) pollOnce() {
+	now := time.Now()
+	min, _, adaptive := w.adaptivePolling()
+	w.poller.mu.Lock()
+	paths := make([]string, 0, len(w.poller.paths))
+	for path := range w.poller.paths {
+		// the paths are due at the tick closest to their due time
+		if s, ok := w.poller.schedules[path]; !adaptive || !ok || !s.due.After(now.Add(min/2)) {
+			paths = append(paths, path)
+		}
+	}
+	w.poller.mu.Unlock()
+
+	for _, path := range paths {
+		current, err := poll(w.fs, path, w.pollHash())
+		if err != nil && !os.IsNotExist(err) {
+			w.log.Debug("Unable to poll ", path, ": ", err)
+			continue
+		}
+
+		events := []FileWatcherEvent(nil)
+		w.poller.mu.Lock()
+		previous, ok := w.poller.paths[path]
+		if ok {
+			w.poller.paths[path] = current
+			events = diffPolled(path, previous, current)
+			w.reschedule(path, len(events) > 0, now)
+		}
+		w.poller.mu.Unlock()
+		if !ok {
+			// removed meanwhile
+			continue
+		}
+
+		for _, e := range events {
+			if isSentinel(e.Path) {
+				w.sentinelSeen(e.Path)
+				continue
+			}
+			w.reloadIgnoreFile(e.Path)
+			if reason, ignored := w.ignoreReason(e.Path); ignored {
+				w.trace(e.Path, "ignored by %s", reason)
+				continue
+			}
+			w.emit(e)
+		}
+	}
+}
+
+// diffPolled returns the events turning previous into current.
+func diffPolled(path string, previous map[string]polledEntry, current map[string]polledEntry) []FileWatcherEvent {
+	var res []FileWatcherEvent
+	at := func(name string) string {
+		if name == "" {
+			return path
+		}
+		return path + string(os.PathSeparator) + name
+	}
+
+	for name, before := range previous {
+		after, ok := current[name]
+		e := FileWatcherEvent{Path: at(name)}
+		switch {
+		case !ok && before.isDir:
+			e.Event = EventDeleteFolder
+		case !ok:
+			e.Event = EventDeleteFile
+		case !before.isDir && !after.isDir && (before.size != after.size || !before.modTime.Equal(after.modTime) ||
+			before.hash != after.hash):
+			e.Event = EventEditFile
+		default:
+			continue
+		}
+		res = append(res, e)
+	}
+
+	for name, after := range current {
+		if _, ok := previous[name]; ok {
+			continue
+		}
+		e := FileWatcherEvent{Path: at(name)}
+		if after.isDir {
+			e.Event = EventCreateFolder
+		} else {
+			e.Event = EventCreateFile
+		}
+		res = append(res, e)
+	}
+	return res
+}
@@ -0,0 +1,113 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Priority is how urgently the events of a watched path are delivered, see AddWithPriority.
+type Priority int
+
+const (
+	// PriorityNormal delivers the events on Events, through the debouncing, coalescing and batching options. It is
+	// the default.
+	PriorityNormal Priority = iota
+	// PriorityHigh delivers the events on Priority as soon as they are classified, bypassing the dispatch queues, the
+	// debouncing, the coalescing and the batching, so they are not held back by the bulk traffic of other paths.
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+type priorityState struct {
+	mu    sync.RWMutex
+	paths map[string]Priority
+}
+
+// AddWithPriority adds path like Add, with priority for the events of path and of everything below it. The priority
+// of the closest path added with a priority applies.
+//
+// The events of the paths with PriorityHigh are delivered on Priority instead of Events, which must then be read too.
+// Handlers receive them ahead of the events waiting on Events. The order of the events of a path is kept, but a rename
+// between a path with PriorityHigh and a path without may be delivered before the earlier events of the other path.
+func (w *FileWatcher) AddWithPriority(path string, priority Priority) error {
+	path = w.normalize(path)
+	if err := w.Add(path); err != nil {
+		return err
+	}
+	w.priorities.mu.Lock()
+	defer w.priorities.mu.Unlock()
+	if w.priorities.paths == nil {
+		w.priorities.paths = make(map[string]Priority)
+	}
+	w.priorities.paths[path] = priority
+	return nil
+}
+
+// forgetPriority drops the priority of path, once it is removed.
+func (w *FileWatcher) forgetPriority(path string) {
+	w.priorities.mu.Lock()
+	defer w.priorities.mu.Unlock()
+	delete(w.priorities.paths, path)
+}
+
+// priorityOf returns the priority of the events of path.
+func (w *FileWatcher) priorityOf(path string) Priority {
+	w.priorities.mu.RLock()
+	defer w.priorities.mu.RUnlock()
+	if len(w.priorities.paths) == 0 {
+		return PriorityNormal
+	}
+	for current := path; ; {
+		if priority, ok := w.priorities.paths[current]; ok {
+			return priority
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return PriorityNormal
+		}
+		current = parent
+	}
+}
+
+// prioritized reports whether e takes the lane of the events with PriorityHigh.
+func (w *FileWatcher) prioritized(e FileWatcherEvent) bool {
+	return w.priorityOf(e.Path) == PriorityHigh
+}
+
+// sendPriority delivers e on Priority unless the watcher is closed.
+func (w *FileWatcher) sendPriority(e FileWatcherEvent) bool {
+	w.life.mu.RLock()
+	defer w.life.mu.RUnlock()
+	if w.life.closed {
+		w.trace(e.Path, "watcher closed, %s dropped", e.Event)
+		w.countDropped(e, "watcher closed")
+		return false
+	}
+	select {
+	case w.Priority <- e:
+		atomic.AddUint64(&w.counters.delivered, 1)
+		return true
+	default:
+	}
+	start := time.Now()
+	select {
+	case w.Priority <- e:
+		atomic.AddUint64(&w.counters.delivered, 1)
+		w.instrumentBlocked(e, start, "Priority")
+		return true
+	case <-w.life.abandon:
+		w.countDropped(e, "not received within the close timeout")
+		return false
+	}
+}
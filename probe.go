@@ -0,0 +1,112 @@
+package fileWatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrWatchDead is returned by Probe when the sentinel event did not arrive within the deadline.
+var ErrWatchDead = errors.New("the watch did not deliver the sentinel event")
+
+// ErrNotProbeable is returned by Probe for paths it can not check, like single files.
+var ErrNotProbeable = errors.New("only watched directories can be probed")
+
+// defaultProbeTimeout is the deadline of Probe.
+const defaultProbeTimeout = 5 * time.Second
+
+// Probe checks that the watch of the directory path still delivers events, by writing and removing a hidden sentinel
+// file and waiting for its event. Watches can break silently, for example after an NFS failover. It waits for five
+// seconds, or twice the poll interval for polled paths, see ProbeContext for another deadline.
+func (w *FileWatcher) Probe(path string) error {
+	timeout := defaultProbeTimeout
+	if w.shouldPoll(w.normalize(path)) {
+		timeout = 2 * w.pollInterval()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return w.ProbeContext(ctx, path)
+}
+
+// ProbeContext is Probe with the deadline of ctx.
+func (w *FileWatcher) ProbeContext(ctx context.Context, path string) error {
+	path = w.normalize(path)
+	if !w.Contains(path) {
+		return ErrNotProbeable
+	}
+	info, err := w.fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return ErrNotProbeable
+	}
+
+	err = w.roundTrip(ctx, path)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %s", ErrWatchDead, path)
+	}
+	return err
+}
+
+// ProbeAll probes every watched directory concurrently and returns the failures by path.
+func (w *FileWatcher) ProbeAll(ctx context.Context) map[string]error {
+	type result struct {
+		path string
+		err  error
+	}
+	results := make(chan result)
+	count := 0
+	for _, path := range w.WatchedMap.Keys() {
+		info, err := w.fs.Stat(path)
+		if err == nil && !info.IsDir() {
+			continue
+		}
+		count++
+		go func(path string) {
+			err := err
+			if err == nil {
+				err = w.ProbeContext(ctx, path)
+			}
+			results <- result{path: path, err: err}
+		}(path)
+	}
+
+	failures := make(map[string]error)
+	for i := 0; i < count; i++ {
+		r := <-results
+		if r.err != nil {
+			failures[r.path] = r.err
+		}
+	}
+	return failures
+}
+
+// HealthHandler returns an http.Handler probing every watched directory, for liveness checks. It answers 200 when
+// every probe succeeds and 503 with the failures otherwise.
+func (w *FileWatcher) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), defaultProbeTimeout)
+		defer cancel()
+
+		failures := w.ProbeAll(ctx)
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(failures) == 0 {
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte("ok\n"))
+			return
+		}
+
+		lines := make([]string, 0, len(failures))
+		for path, err := range failures {
+			lines = append(lines, path+": "+err.Error())
+		}
+		sort.Strings(lines)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = rw.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	})
+}
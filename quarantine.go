@@ -0,0 +1,145 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"github.com/spf13/afero"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sync"
+)
+
+// Deprecated: use EventQuarantined.
+func (e FileWatcherEvent) QuarantinedEvent() EventKind {
+	return EventQuarantined
+}
+
+// Deprecated: compare e.Event with EventQuarantined.
+func (e FileWatcherEvent) IsQuarantinedEvent() bool {
+	return e.Event == EventQuarantined
+}
+
+// SuspectFile describes a created file handed to a QuarantinePolicy.
+type SuspectFile struct {
+	Path string
+	// Hash is the hex encoded SHA-256 of the content.
+	Hash string
+	// MIME is the content type sniffed from the first bytes of the content.
+	MIME string
+	Size int64
+}
+
+// QuarantinePolicy examines a created file and reports whether it has to be quarantined.
+type QuarantinePolicy func(f SuspectFile) bool
+
+// QuarantineOptions configures the quarantine hook.
+type QuarantineOptions struct {
+	// Dir receives the quarantined files.
+	Dir    string
+	Policy QuarantinePolicy
+}
+
+type quarantineState struct {
+	mu sync.Mutex
+	// moved holds the original paths of the quarantined files whose removal has not been seen yet.
+	moved map[string]bool
+}
+
+// checkQuarantine hands the created files to the quarantine policy. A file the policy rejects is moved to the
+// quarantine directory and e becomes a QUARANTINED event, with Path set to the quarantined file and PreviousPath to the
+// original path. It reports false when e has to be dropped because it is the removal caused by a quarantine move.
+func (w *FileWatcher) checkQuarantine(e *FileWatcherEvent) bool {
+	q := w.options.Quarantine
+	if q.Policy == nil || q.Dir == "" {
+		return true
+	}
+
+	if isDelete(*e) || isRename(*e) {
+		w.quarantine.mu.Lock()
+		defer w.quarantine.mu.Unlock()
+		path := e.Path
+		if isRename(*e) {
+			path = e.PreviousPath
+		}
+		if w.quarantine.moved[path] {
+			delete(w.quarantine.moved, path)
+			w.traceEvent(*e, "dropped, the file was moved to quarantine")
+			return false
+		}
+		return true
+	}
+
+	if !e.Event.Is(EventCreateFile | EventMovedInFile) {
+		return true
+	}
+
+	suspect, err := describeSuspect(w.fs, e.Path)
+	if err != nil {
+		w.log.Debug("Unable to examine ", e.Path, " for quarantine: ", err)
+		return true
+	}
+	if !q.Policy(suspect) {
+		return true
+	}
+
+	target := filepath.Join(q.Dir, fmt.Sprintf("%s.%s", filepath.Base(e.Path), suspect.Hash[:12]))
+	w.quarantine.mu.Lock()
+	if w.quarantine.moved == nil {
+		w.quarantine.moved = make(map[string]bool)
+	}
+	w.quarantine.moved[e.Path] = true
+	w.quarantine.mu.Unlock()
+
+	if err := moveFile(w.fs, e.Path, target); err != nil {
+		w.quarantine.mu.Lock()
+		delete(w.quarantine.moved, e.Path)
+		w.quarantine.mu.Unlock()
+		w.log.Error("Unable to quarantine ", e.Path, ": ", err)
+		return true
+	}
+
+	w.traceEvent(*e, "quarantined to %s", target)
+	e.PreviousPath = e.Path
+	e.Path = target
+	e.Event = EventQuarantined
+	return true
+}
+
+func describeSuspect(fsys afero.Fs, path string) (SuspectFile, error) {
+	doc, err := describeFile(fsys, path)
+	if err != nil {
+		return SuspectFile{}, err
+	}
+
+	file, err := fsys.Open(path)
+	if err != nil {
+		return SuspectFile{}, err
+	}
+	defer file.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return SuspectFile{}, err
+	}
+
+	return SuspectFile{Path: path, Hash: doc.Hash, MIME: http.DetectContentType(head[:n]), Size: doc.Size}, nil
+}
+
+// moveFile renames source to target on fsys, copying it when both are on different devices.
+func moveFile(fsys afero.Fs, source string, target string) error {
+	if err := fsys.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+	if err := fsys.Rename(source, target); err == nil {
+		return nil
+	}
+
+	content, err := afero.ReadFile(fsys, source)
+	if err != nil {
+		return err
+	}
+	if err := afero.WriteFile(fsys, target, content, 0600); err != nil {
+		return err
+	}
+	return fsys.Remove(source)
+}
@@ -0,0 +1,181 @@
+package fileWatcher
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimitedKinds are the kinds subject to the RateLimit option, the changes of the content of a path. The other
+// kinds change the tree and are always delivered.
+const rateLimitedKinds = EventEditFile | EventChMod | EventAccessed
+
+type pathRate struct {
+	// start is the start of the current one second window, count the events delivered in it.
+	start time.Time
+	count int
+	// suppressed counts the events dropped since the last delivered one, latest is the last of them, delivered at
+	// the end of the window.
+	suppressed int
+	latest     *FileWatcherEvent
+	timer      Timer
+}
+
+type rateLimitState struct {
+	mu    sync.Mutex
+	paths map[string]*pathRate
+	// sweep is when the windows that ended are forgotten next.
+	sweep time.Time
+}
+
+// rateLimit applies the RateLimit option to e, and reports whether it is delivered now. Once a path got RateLimit
+// events in a second, its following events are dropped until the end of the second, when the last one is delivered.
+// The delivered events carry the number of events dropped before them in Suppressed. An event changing the tree
+// delivers the pending one of its path first, so the order of the events of a path is kept.
+func (w *FileWatcher) rateLimit(e *FileWatcherEvent) bool {
+	limit := w.options.RateLimit
+	if limit <= 0 {
+		return true
+	}
+	now := w.clock().Now()
+	w.rates.mu.Lock()
+	if w.rates.paths == nil {
+		w.rates.paths = make(map[string]*pathRate)
+	}
+	if now.After(w.rates.sweep) {
+		w.sweepRatesLocked(now)
+	}
+
+	p, ok := w.rates.paths[e.Path]
+	if !ok && len(w.rates.paths) >= w.rateEntries() {
+		w.rates.mu.Unlock()
+		w.trace(e.Path, "too many paths rate limited already, %s not limited", e.Event)
+		return true
+	}
+	if !ok {
+		p = &pathRate{start: now}
+		w.rates.paths[e.Path] = p
+	}
+	if !e.Event.Is(rateLimitedKinds) {
+		pending := []*FileWatcherEvent{w.takeRateLimited(p)}
+		if previous, ok := w.rates.paths[e.PreviousPath]; ok && e.PreviousPath != "" {
+			pending = append(pending, w.takeRateLimited(previous))
+		}
+		w.rates.mu.Unlock()
+		for _, held := range pending {
+			if held != nil {
+				w.dispatchLimited(*held)
+			}
+		}
+		return true
+	}
+
+	if now.Sub(p.start) >= time.Second {
+		p.start = now
+		p.count = 0
+	}
+	if p.count < limit {
+		p.count++
+		e.Suppressed = p.suppressed
+		p.suppressed = 0
+		w.rates.mu.Unlock()
+		return true
+	}
+
+	if p.latest != nil {
+		p.suppressed++
+		atomic.AddUint64(&w.counters.rateLimited, 1)
+	}
+	latest := *e
+	p.latest = &latest
+	if p.timer == nil {
+		path := e.Path
+		p.timer = w.clock().AfterFunc(p.start.Add(time.Second).Sub(now), func() {
+			w.inOrder(path, func() {
+				w.rates.mu.Lock()
+				p.timer = nil
+				pending := w.takeRateLimited(p)
+				if pending != nil {
+					p.start = w.clock().Now()
+					p.count = 1
+				}
+				w.rates.mu.Unlock()
+				if pending != nil {
+					w.traceEvent(*pending, "delivered at the end of the rate limit window of %s", path)
+					w.dispatchLimited(*pending)
+				}
+			})
+		})
+	}
+	w.rates.mu.Unlock()
+	w.traceEvent(*e, "over the rate limit of %d events per second", limit)
+	return false
+}
+
+// takeRateLimited returns the event of p held back by the rate limit, with the number of events dropped before it,
+// or nil.
+func (w *FileWatcher) takeRateLimited(p *pathRate) *FileWatcherEvent {
+	pending := p.latest
+	if pending == nil {
+		return nil
+	}
+	pending.Suppressed = p.suppressed
+	p.latest = nil
+	p.suppressed = 0
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	return pending
+}
+
+// takeRateLimitedIn returns the events held back by the rate limit for the paths held reports true for.
+func (w *FileWatcher) takeRateLimitedIn(held func(string) bool) []FileWatcherEvent {
+	if w.options.RateLimit <= 0 {
+		return nil
+	}
+	w.rates.mu.Lock()
+	defer w.rates.mu.Unlock()
+	var res []FileWatcherEvent
+	for path, p := range w.rates.paths {
+		if !held(path) {
+			continue
+		}
+		if e := w.takeRateLimited(p); e != nil {
+			res = append(res, *e)
+		}
+	}
+	return sortBySeq(res)
+}
+
+// flushRateLimited delivers the events held back by the rate limit right away.
+func (w *FileWatcher) flushRateLimited() {
+	w.rates.mu.Lock()
+	var pending []FileWatcherEvent
+	for _, p := range w.rates.paths {
+		if e := w.takeRateLimited(p); e != nil {
+			pending = append(pending, *e)
+		}
+	}
+	w.rates.paths = nil
+	w.rates.mu.Unlock()
+
+	for _, e := range pending {
+		w.dispatchLimited(e)
+	}
+}
+
+// sweepRatesLocked forgets the paths whose window ended without an event held back, and returns how many. The caller
+// holds the lock.
+func (w *FileWatcher) sweepRatesLocked(now time.Time) int {
+	swept := 0
+	for path, p := range w.rates.paths {
+		if p.latest == nil && now.Sub(p.start) >= time.Second {
+			delete(w.rates.paths, path)
+			swept++
+		}
+	}
+	atomic.AddUint64(&w.stateGC.ratesEvicted, uint64(swept))
+	w.rates.sweep = now.Add(time.Second)
+	return swept
+}
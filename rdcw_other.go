@@ -0,0 +1,8 @@
+//go:build !windows
+
+package fileWatcher
+
+// newBufferedNotifier returns nil, the notification buffer size is only configurable on Windows.
+func newBufferedNotifier(int) notifier {
+	return nil
+}
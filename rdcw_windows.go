@@ -0,0 +1,265 @@
+//go:build windows
+
+package fileWatcher
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/sys/windows"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+)
+
+// maxWindowsBufferSize is the largest buffer ReadDirectoryChangesW accepts for directories on network shares.
+const maxWindowsBufferSize = 64 * 1024
+
+const windowsNotifyFilter = windows.FILE_NOTIFY_CHANGE_FILE_NAME | windows.FILE_NOTIFY_CHANGE_DIR_NAME |
+	windows.FILE_NOTIFY_CHANGE_ATTRIBUTES | windows.FILE_NOTIFY_CHANGE_LAST_WRITE
+
+// windowsNotifier reads the changes of the watched directories with ReadDirectoryChangesW and a buffer of the given
+// size, one directory per goroutine. The events are the ones fsnotify reports, so they are classified the same way,
+// but an overflowing buffer is reported with a notifyOverflowError naming the directory, so it can be rescanned.
+type windowsNotifier struct {
+	bufferSize int
+	mu         sync.Mutex
+	dirs       map[string]*windowsWatch
+	closed     bool
+	events     chan fsnotify.Event
+	errors     chan error
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// windowsWatch is a watched directory. Files are watched through their directory.
+type windowsWatch struct {
+	dir    string
+	handle windows.Handle
+	// stop is signaled to end the read of the directory.
+	stop windows.Handle
+	// all is set when the directory itself is watched, names holds the files of the directory watched on their own.
+	all   bool
+	names map[string]bool
+}
+
+// newBufferedNotifier returns a notifier reading the changes of every directory into a buffer of size bytes.
+func newBufferedNotifier(size int) notifier {
+	if size > maxWindowsBufferSize {
+		size = maxWindowsBufferSize
+	}
+	return &windowsNotifier{
+		bufferSize: size,
+		dirs:       make(map[string]*windowsWatch),
+		events:     make(chan fsnotify.Event),
+		errors:     make(chan error),
+		done:       make(chan struct{}),
+	}
+}
+
+func (n *windowsNotifier) Add(path string) error {
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	dir, name := path, ""
+	if !info.IsDir() {
+		dir, name = filepath.Dir(path), filepath.Base(path)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return errors.New("the watcher is closed")
+	}
+	watch, ok := n.dirs[dir]
+	if !ok {
+		watch, err = openWindowsWatch(dir)
+		if err != nil {
+			return err
+		}
+		n.dirs[dir] = watch
+		n.wg.Add(1)
+		go n.read(watch)
+	}
+	if name == "" {
+		watch.all = true
+	} else {
+		watch.names[name] = true
+	}
+	return nil
+}
+
+func openWindowsWatch(dir string) (*windowsWatch, error) {
+	name, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := windows.CreateFile(name, windows.FILE_LIST_DIRECTORY,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil, windows.OPEN_EXISTING,
+		windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("CreateFile", err)
+	}
+	stop, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		windows.CloseHandle(handle)
+		return nil, os.NewSyscallError("CreateEvent", err)
+	}
+	return &windowsWatch{dir: dir, handle: handle, stop: stop, names: make(map[string]bool)}, nil
+}
+
+func (n *windowsNotifier) Remove(path string) error {
+	path = filepath.Clean(path)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if watch, ok := n.dirs[path]; ok && watch.all {
+		watch.all = false
+		n.release(watch)
+		return nil
+	}
+	if watch, ok := n.dirs[filepath.Dir(path)]; ok && watch.names[filepath.Base(path)] {
+		delete(watch.names, filepath.Base(path))
+		n.release(watch)
+		return nil
+	}
+	return fmt.Errorf("%w: %s", fsnotify.ErrNonExistentWatch, path)
+}
+
+// release stops reading the directory of watch once nothing in it is watched anymore, with the lock held.
+func (n *windowsNotifier) release(watch *windowsWatch) {
+	if watch.all || len(watch.names) > 0 {
+		return
+	}
+	delete(n.dirs, watch.dir)
+	windows.SetEvent(watch.stop)
+}
+
+func (n *windowsNotifier) Close() error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	for dir, watch := range n.dirs {
+		delete(n.dirs, dir)
+		windows.SetEvent(watch.stop)
+	}
+	n.mu.Unlock()
+
+	close(n.done)
+	n.wg.Wait()
+	return nil
+}
+
+func (n *windowsNotifier) notifications() (<-chan fsnotify.Event, <-chan error) {
+	return n.events, n.errors
+}
+
+// read reports the changes of the directory of watch until it is stopped or removed.
+func (n *windowsNotifier) read(watch *windowsWatch) {
+	labelGoroutine("windows-notifier")
+	defer n.wg.Done()
+	defer windows.CloseHandle(watch.stop)
+	defer windows.CloseHandle(watch.handle)
+
+	event, err := windows.CreateEvent(nil, 0, 0, nil)
+	if err != nil {
+		n.sendError(os.NewSyscallError("CreateEvent", err))
+		return
+	}
+	defer windows.CloseHandle(event)
+
+	// the buffer is filled by the kernel, it must stay in place until the read completed
+	buf := make([]byte, n.bufferSize)
+	for {
+		overlapped := windows.Overlapped{HEvent: event}
+		var count uint32
+		err := windows.ReadDirectoryChanges(watch.handle, &buf[0], uint32(len(buf)), false, windowsNotifyFilter,
+			nil, &overlapped, 0)
+		if err == nil || err == windows.ERROR_IO_PENDING {
+			signaled, _ := windows.WaitForMultipleObjects([]windows.Handle{event, watch.stop}, false, windows.INFINITE)
+			if signaled == windows.WAIT_OBJECT_0+1 {
+				windows.CancelIoEx(watch.handle, &overlapped)
+				windows.GetOverlappedResult(watch.handle, &overlapped, &count, true)
+				return
+			}
+			err = windows.GetOverlappedResult(watch.handle, &overlapped, &count, false)
+		}
+
+		switch {
+		case err == windows.ERROR_NOTIFY_ENUM_DIR || (err == nil && count == 0):
+			// more changes than the buffer holds happened, they are lost
+			n.sendError(&notifyOverflowError{dir: watch.dir})
+			continue
+		case err == windows.ERROR_ACCESS_DENIED:
+			// the directory was removed
+			n.send(fsnotify.Event{Name: watch.dir, Op: fsnotify.Remove})
+			n.mu.Lock()
+			if n.dirs[watch.dir] == watch {
+				delete(n.dirs, watch.dir)
+			}
+			n.mu.Unlock()
+			return
+		case err == windows.ERROR_OPERATION_ABORTED:
+			return
+		case err != nil:
+			n.sendError(os.NewSyscallError("ReadDirectoryChangesW", err))
+			return
+		}
+
+		for offset := uint32(0); offset < count; {
+			raw := (*windows.FileNotifyInformation)(unsafe.Pointer(&buf[offset]))
+			name := windows.UTF16ToString(unsafe.Slice(&raw.FileName, raw.FileNameLength/2))
+			if n.covers(watch, name) {
+				if op, ok := windowsOp(raw.Action); ok {
+					n.send(fsnotify.Event{Name: filepath.Join(watch.dir, name), Op: op})
+				}
+			}
+			if raw.NextEntryOffset == 0 {
+				break
+			}
+			offset += raw.NextEntryOffset
+		}
+	}
+}
+
+// covers reports whether the changes of the entry name of the directory of watch are reported.
+func (n *windowsNotifier) covers(watch *windowsWatch, name string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return watch.all || watch.names[name]
+}
+
+// windowsOp returns the operation fsnotify reports for a file action.
+func windowsOp(action uint32) (fsnotify.Op, bool) {
+	switch action {
+	case windows.FILE_ACTION_ADDED, windows.FILE_ACTION_RENAMED_NEW_NAME:
+		return fsnotify.Create, true
+	case windows.FILE_ACTION_REMOVED:
+		return fsnotify.Remove, true
+	case windows.FILE_ACTION_MODIFIED:
+		return fsnotify.Write, true
+	case windows.FILE_ACTION_RENAMED_OLD_NAME:
+		return fsnotify.Rename, true
+	}
+	return 0, false
+}
+
+func (n *windowsNotifier) send(e fsnotify.Event) {
+	select {
+	case n.events <- e:
+	case <-n.done:
+	}
+}
+
+func (n *windowsNotifier) sendError(err error) {
+	select {
+	case n.errors <- err:
+	case <-n.done:
+	}
+}
@@ -0,0 +1,128 @@
+package fileWatcher
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type rearmState struct {
+	mu sync.Mutex
+	// pending holds the deleted roots waiting to reappear, by path.
+	pending map[string]RootSpec
+	// polling is set while the goroutine checking the pending roots runs.
+	polling bool
+}
+
+// PendingRoots returns the deleted roots waiting to reappear to be watched again, see the AutoRearmInterval option.
+func (w *FileWatcher) PendingRoots() []RootSpec {
+	w.rearm.mu.Lock()
+	defer w.rearm.mu.Unlock()
+	res := make([]RootSpec, 0, len(w.rearm.pending))
+	for _, root := range w.rearm.pending {
+		res = append(res, root)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Path < res[j].Path
+	})
+	return res
+}
+
+// forgetPendingRoot stops waiting for path to reappear, once the application removed it.
+func (w *FileWatcher) forgetPendingRoot(path string) {
+	w.rearm.mu.Lock()
+	defer w.rearm.mu.Unlock()
+	delete(w.rearm.pending, path)
+}
+
+// rearmDeletedRoot keeps the root removed by e, if any, pending until it reappears.
+func (w *FileWatcher) rearmDeletedRoot(e FileWatcherEvent) {
+	if w.options.AutoRearmInterval <= 0 || !e.Event.Is(EventDeleteFolder|EventRenameFolder|EventMovedOutFolder) {
+		return
+	}
+	path := e.Path
+	if e.Event == EventRenameFolder {
+		path = e.PreviousPath
+	}
+
+	w.recursive.mu.RLock()
+	_, recursive := w.recursive.roots[path]
+	w.recursive.mu.RUnlock()
+	if recursive {
+		// removed here rather than by followRecursive, since removing the watches forgets the pending roots
+		w.removeTree(path)
+		w.recursive.mu.Lock()
+		delete(w.recursive.roots, path)
+		w.recursive.mu.Unlock()
+	} else {
+		if _, ok := w.WatchedMap.Get(path); !ok || w.recursiveRoot(path) {
+			return
+		}
+		// the kernel dropped the watch, the path is added again from scratch
+		if err := w.Remove(path); err != nil {
+			w.WatchedMap.Remove(path)
+			w.listings.forget(path)
+		}
+	}
+
+	w.log.Info("Watched root ", path, " was removed, waiting for it to reappear")
+	w.rearm.mu.Lock()
+	defer w.rearm.mu.Unlock()
+	if w.rearm.pending == nil {
+		w.rearm.pending = make(map[string]RootSpec)
+	}
+	w.rearm.pending[path] = RootSpec{Path: path, Recursive: recursive}
+	if !w.rearm.polling {
+		w.rearm.polling = true
+		go w.pollPendingRoots()
+	}
+}
+
+// pollPendingRoots checks the pending roots every AutoRearmInterval until none is left or the watcher is closed.
+func (w *FileWatcher) pollPendingRoots() {
+	w.labelGoroutine("rearm")
+	ticker := time.NewTicker(w.options.AutoRearmInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.life.stopping:
+			return
+		}
+
+		for _, root := range w.PendingRoots() {
+			w.rearmRoot(root)
+		}
+
+		w.rearm.mu.Lock()
+		if len(w.rearm.pending) == 0 {
+			w.rearm.polling = false
+			w.rearm.mu.Unlock()
+			return
+		}
+		w.rearm.mu.Unlock()
+	}
+}
+
+// rearmRoot watches root again when it is back.
+func (w *FileWatcher) rearmRoot(root RootSpec) {
+	info, err := w.fs.Stat(root.Path)
+	if err != nil || !info.IsDir() {
+		return
+	}
+	if root.Recursive {
+		err = w.AddRecursive(root.Path)
+	} else {
+		err = w.Add(root.Path)
+	}
+	if err != nil {
+		w.log.Warn("Unable to watch ", root.Path, " again: ", err)
+		return
+	}
+
+	w.rearm.mu.Lock()
+	delete(w.rearm.pending, root.Path)
+	w.rearm.mu.Unlock()
+	w.log.Info("Watched root ", root.Path, " is back, watching it again")
+	w.emit(FileWatcherEvent{Event: EventWatchReestablished, Path: root.Path})
+}
@@ -0,0 +1,143 @@
+package fileWatcher
+
+import (
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type recursiveState struct {
+	mu    sync.RWMutex
+	roots map[string]bool
+}
+
+// AddRecursive watches the directory path and every directory below it. Directories created below it later are
+// watched as their CREATE_FOLDER events arrive, and deleted or renamed directories stop being watched. Directories
+// matching the ignore patterns are skipped. Symlinks are handled according to the Symlinks option.
+func (w *FileWatcher) AddRecursive(path string) error {
+	path = w.normalize(path)
+	info, err := w.fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return w.Add(path)
+	}
+
+	w.recursive.mu.Lock()
+	if w.recursive.roots == nil {
+		w.recursive.roots = make(map[string]bool)
+	}
+	w.recursive.roots[path] = true
+	w.recursive.mu.Unlock()
+
+	return w.addTree(path)
+}
+
+// RemoveRecursive stops watching path and every path recorded below it, files and directories, and the directories
+// added with AddRecursive at or below path stop following their trees.
+func (w *FileWatcher) RemoveRecursive(path string) error {
+	path = w.normalize(path)
+	w.recursive.mu.Lock()
+	for root := range w.recursive.roots {
+		if root == path || isBelow(root, path) {
+			delete(w.recursive.roots, root)
+		}
+	}
+	w.recursive.mu.Unlock()
+	w.rearm.mu.Lock()
+	for root := range w.rearm.pending {
+		if root == path || isBelow(root, path) {
+			delete(w.rearm.pending, root)
+		}
+	}
+	w.rearm.mu.Unlock()
+
+	w.removeTree(path)
+	return nil
+}
+
+// addTree adds root and the directories below it.
+func (w *FileWatcher) addTree(root string) error {
+	return afero.Walk(w.fs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path != root {
+				// removed while walking
+				return nil
+			}
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 && w.options.Symlinks != SymlinkIgnore {
+			if err := w.followLink(path); err != nil {
+				w.log.Warn("Unable to follow the symlink ", path, ": ", err)
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && w.excludedDir(path) {
+			return filepath.SkipDir
+		}
+		return w.Add(path)
+	})
+}
+
+// removeTree removes the watches of root and every path below it.
+func (w *FileWatcher) removeTree(root string) {
+	for _, path := range w.WatchedMap.Keys() {
+		if path != root && !isBelow(path, root) {
+			continue
+		}
+		if err := w.Remove(path); err != nil {
+			// the kernel drops the watches of deleted directories by itself
+			w.log.Debug("Unable to remove the watch of ", path, ": ", err)
+			w.forgetWatch(path)
+		}
+	}
+}
+
+// recursiveRoot reports whether path is a directory added with AddRecursive or below one.
+func (w *FileWatcher) recursiveRoot(path string) bool {
+	w.recursive.mu.RLock()
+	defer w.recursive.mu.RUnlock()
+	for root := range w.recursive.roots {
+		if path == root || isBelow(path, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// followRecursive keeps the watches of the recursively watched trees in line with the folder events.
+func (w *FileWatcher) followRecursive(e FileWatcherEvent) {
+	w.followLinks(e)
+	w.rearmDeletedRoot(e)
+	if !isFolderEvent(e) {
+		return
+	}
+
+	if e.Event.Is(EventDeleteFolder | EventRenameFolder | EventMovedOutFolder) {
+		old := e.Path
+		if e.Event == EventRenameFolder {
+			old = e.PreviousPath
+		}
+		if w.recursiveRoot(old) {
+			w.removeTree(old)
+			w.recursive.mu.Lock()
+			delete(w.recursive.roots, old)
+			w.recursive.mu.Unlock()
+		}
+	}
+
+	if e.Event.Is(EventCreateFolder|EventRenameFolder|EventMovedInFolder) && w.recursiveRoot(e.Path) {
+		// the directories created inside before the watch was set up are picked up by the walk
+		if err := w.addTree(e.Path); err != nil {
+			w.log.Warn("Unable to watch ", e.Path, ": ", err)
+		}
+		if err := w.protectTree(e.Path); err != nil {
+			w.log.Warn("Unable to protect ", e.Path, ": ", err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package fileWatcher
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Redaction transforms the paths of the events leaving the process, for the sinks and the remote APIs of
+// applications with privacy requirements. The events handled in the process keep their full paths.
+type Redaction func(path string) string
+
+// homeDirPattern matches the home directories of the usual systems, like /home/alice, /Users/alice or
+// C:\Users\alice.
+var homeDirPattern = regexp.MustCompile(`^(/home/[^/]+|/Users/[^/]+|/root|[A-Za-z]:\\Users\\[^\\]+)($|[/\\])`)
+
+// StripHomeDirs replaces the home directory prefix of the paths with "~", hiding the names of the users.
+func StripHomeDirs() Redaction {
+	return func(path string) string {
+		return homeDirPattern.ReplaceAllString(path, "~$2")
+	}
+}
+
+// StripPrefix replaces the prefix of the paths below it with replacement, like the mount point of a share.
+func StripPrefix(prefix string, replacement string) Redaction {
+	prefix = normalizePath(prefix)
+	return func(path string) string {
+		if path == prefix || isBelow(path, prefix) {
+			return replacement + path[len(prefix):]
+		}
+		return path
+	}
+}
+
+// HashFileNames replaces the name of the files with its HMAC-SHA256 under key, keeping their extension and their
+// folder. The same name always hashes the same way, so the consumers can still correlate the events of a file without
+// learning its name. The key keeps the hashes of short or common names from being reversed with a dictionary.
+func HashFileNames(key []byte) Redaction {
+	return func(path string) string {
+		dir, name := filepath.Split(path)
+		if name == "" || name == "~" {
+			return path
+		}
+		ext := filepath.Ext(name)
+		mac := hmac.New(sha256.New, key)
+		_, _ = mac.Write([]byte(strings.TrimSuffix(name, ext)))
+		return dir + hex.EncodeToString(mac.Sum(nil))[:16] + ext
+	}
+}
+
+// ReplacePattern replaces the matches of pattern in the paths with replacement, which may refer to the submatches
+// like regexp.Regexp.ReplaceAllString.
+func ReplacePattern(pattern *regexp.Regexp, replacement string) Redaction {
+	return func(path string) string {
+		return pattern.ReplaceAllString(path, replacement)
+	}
+}
+
+// RedactEvent returns e with its paths transformed by the rules, in order. The name of its Info is redacted along,
+// and the system specific data of Info, like the owner of the file, is left out.
+func RedactEvent(e FileWatcherEvent, rules ...Redaction) FileWatcherEvent {
+	if len(rules) == 0 {
+		return e
+	}
+	redact := func(path string) string {
+		if path == "" {
+			return path
+		}
+		for _, rule := range rules {
+			path = rule(path)
+		}
+		return path
+	}
+	e.Path = redact(e.Path)
+	e.PreviousPath = redact(e.PreviousPath)
+	if e.Info != nil {
+		e.Info = redactedInfo{FileInfo: e.Info, name: filepath.Base(e.Path)}
+	}
+	return e
+}
+
+// redactedInfo is the Info of a redacted event.
+type redactedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i redactedInfo) Name() string {
+	return i.name
+}
+
+func (i redactedInfo) Sys() interface{} {
+	return nil
+}
+
+// Redacted wraps sink so the events it receives have their paths transformed by the rules.
+func Redacted(sink Sink, rules ...Redaction) Sink {
+	if batchSink, ok := sink.(BatchSink); ok {
+		return &redactedBatchSink{BatchSink: batchSink, rules: rules}
+	}
+	return SinkFunc(func(e FileWatcherEvent) error {
+		return sink.Send(RedactEvent(e, rules...))
+	})
+}
+
+// redactedBatchSink keeps a redacted BatchSink receiving batches from a FanOut.
+type redactedBatchSink struct {
+	BatchSink
+	rules []Redaction
+}
+
+func (s *redactedBatchSink) Send(e FileWatcherEvent) error {
+	return s.BatchSink.Send(RedactEvent(e, s.rules...))
+}
+
+func (s *redactedBatchSink) SendBatch(batch *EventBatch) error {
+	// the events are redacted into a copy, the caller retries with the batch as it was when the sink fails
+	redacted := &EventBatch{Events: make([]FileWatcherEvent, len(batch.Events))}
+	for i, e := range batch.Events {
+		redacted.Events[i] = RedactEvent(e, s.rules...)
+	}
+	if err := s.BatchSink.SendBatch(redacted); err != nil {
+		return err
+	}
+	batch.Release()
+	return nil
+}
+
+// Export returns e as the remote APIs of the watcher send it, with the ExportRedaction option applied.
+func (w *FileWatcher) Export(e FileWatcherEvent) FileWatcherEvent {
+	return RedactEvent(e, w.options.ExportRedaction...)
+}
@@ -0,0 +1,119 @@
+package fileWatcher
+
+import (
+	"os"
+	"sync"
+)
+
+type pendingDelete struct {
+	event FileWatcherEvent
+	timer Timer
+}
+
+// renameInference pairs deletes with creates of the same inode to recover renames the platform did not report as
+// such. Deletes of known inodes are held back for a short while waiting for their create.
+type renameInference struct {
+	mu      sync.Mutex
+	pending map[uint64]*pendingDelete
+}
+
+func (w *FileWatcher) inferRenames() bool {
+	if w.options.InferRenames != nil {
+		return *w.options.InferRenames
+	}
+	return inferRenamesByDefault
+}
+
+// holdDelete holds back the delete event e when the inode of its path is known, and reports whether it did.
+func (w *FileWatcher) holdDelete(e FileWatcherEvent) bool {
+	if !w.inferRenames() {
+		return false
+	}
+
+	entry, ok := w.listings.lookup(e.Path)
+	w.listings.remove(e.Path)
+	if !ok || entry.inode == 0 {
+		return false
+	}
+
+	w.renames.mu.Lock()
+	defer w.renames.mu.Unlock()
+	if w.renames.pending == nil {
+		w.renames.pending = make(map[uint64]*pendingDelete)
+	}
+
+	inode := entry.inode
+	w.traceEvent(e, "delete held back waiting for a create of inode %d", inode)
+	p := &pendingDelete{event: e}
+	// the create of a renamed item is classified after the create delay, give it some slack on top of that
+	p.timer = w.clock().AfterFunc(2*w.createClassifyDelay(), func() {
+		w.renames.mu.Lock()
+		current, ok := w.renames.pending[inode]
+		if ok && current == p {
+			delete(w.renames.pending, inode)
+		}
+		w.renames.mu.Unlock()
+
+		if ok && current == p {
+			w.emit(p.event)
+		}
+	})
+	w.renames.pending[inode] = p
+	return true
+}
+
+// dropHeldDelete forgets the delete of path being held back, once its rename was recognized otherwise, and reports
+// whether there was one.
+func (w *FileWatcher) dropHeldDelete(path string) bool {
+	w.renames.mu.Lock()
+	defer w.renames.mu.Unlock()
+	dropped := false
+	for inode, p := range w.renames.pending {
+		if p.event.Path == path {
+			p.timer.Stop()
+			delete(w.renames.pending, inode)
+			dropped = true
+		}
+	}
+	return dropped
+}
+
+// pairCreate turns the create event e into a rename when a delete of the same inode is being held back.
+func (w *FileWatcher) pairCreate(e FileWatcherEvent, info os.FileInfo) FileWatcherEvent {
+	if !w.inferRenames() || info == nil {
+		return e
+	}
+
+	inode, ok := inodeOf(info)
+	if !ok {
+		return e
+	}
+	w.renames.mu.Lock()
+	p, ok := w.renames.pending[inode]
+	if ok {
+		delete(w.renames.pending, inode)
+		p.timer.Stop()
+	}
+	w.renames.mu.Unlock()
+
+	if !ok {
+		// the entry of a rename is moved along when the rename is emitted, the path it replaces is still listed
+		w.listings.set(e.Path, listingEntry{isDir: info.IsDir(), inode: inode})
+		if !info.IsDir() && w.dropHeldDelete(e.Path) {
+			// the file was moved away and another one took its place, like the backups of the editors do
+			w.trace(e.Path, "replaces the file whose delete was held back, emitting EDIT_FILE")
+			e.Event = EventEditFile
+		}
+		return e
+	}
+
+	res := e
+	res.PreviousPath = p.event.Path
+	w.trace(e.Path, "paired with the delete of %s by inode %d", p.event.Path, inode)
+	if info.IsDir() {
+		res.Event = EventRenameFolder
+	} else {
+		res.Event = EventRenameFile
+	}
+	return res
+}
@@ -0,0 +1,7 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package fileWatcher
+
+// kqueue reports a rename as a Rename of the old path and, when the directory is rescanned, a Create of the new one,
+// often in an order the event loop can't pair. Renames are inferred from inode numbers instead.
+const inferRenamesByDefault = true
@@ -0,0 +1,8 @@
+//go:build linux
+
+package fileWatcher
+
+// inotify reports a move as a Rename of the old path followed by a Create of the new one. fsnotify does not expose the
+// cookie tying the two together, so moves, within a directory or between two watched directories, are inferred from
+// inode numbers instead.
+const inferRenamesByDefault = true
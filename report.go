@@ -0,0 +1,144 @@
+package fileWatcher
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReportOptions configures Report.
+type ReportOptions struct {
+	// Root limits the report to the paths below it.
+	Root string
+	// TopDirectories is the number of directories listed in ChurnDirectories. It defaults to ten.
+	TopDirectories int
+}
+
+// DirectoryChurn is the number of events seen in a directory.
+type DirectoryChurn struct {
+	Path   string
+	Events int
+}
+
+// ChangeReport summarizes the changes seen over a period.
+type ChangeReport struct {
+	Since time.Time
+	Until time.Time
+	// Events is the number of events seen.
+	Events   int
+	Added    []string
+	Modified []string
+	Deleted  []string
+	// Renamed maps the new path of renamed items to their old path.
+	Renamed map[string]string
+	// ChurnDirectories are the directories with the most events, busiest first.
+	ChurnDirectories []DirectoryChurn
+	// BytesChanged is the current size of the added, modified and renamed files that still exist. The size of deleted
+	// files is not known.
+	BytesChanged int64
+}
+
+// Report summarizes the changes recorded in the history since the given time, for scheduled change reports and
+// deployment audits. The history must be enabled with the HistorySize option, and only covers the events it still
+// holds.
+func (w *FileWatcher) Report(ctx context.Context, since time.Time, opts ReportOptions) (*ChangeReport, error) {
+	events, err := w.History(since)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Root != "" {
+		root := normalizePath(opts.Root)
+		filtered := events[:0]
+		for _, e := range events {
+			if e.Path == root || isBelow(e.Path, root) {
+				filtered = append(filtered, e)
+			}
+		}
+		events = filtered
+	}
+
+	report := &ChangeReport{Since: since, Until: time.Now(), Events: len(events), Renamed: make(map[string]string)}
+
+	churn := make(map[string]int)
+	for _, e := range events {
+		churn[filepath.Dir(e.Path)]++
+	}
+	for dir, count := range churn {
+		report.ChurnDirectories = append(report.ChurnDirectories, DirectoryChurn{Path: dir, Events: count})
+	}
+	sort.Slice(report.ChurnDirectories, func(i, j int) bool {
+		a, b := report.ChurnDirectories[i], report.ChurnDirectories[j]
+		if a.Events != b.Events {
+			return a.Events > b.Events
+		}
+		return a.Path < b.Path
+	})
+	top := opts.TopDirectories
+	if top <= 0 {
+		top = 10
+	}
+	if len(report.ChurnDirectories) > top {
+		report.ChurnDirectories = report.ChurnDirectories[:top]
+	}
+
+	for _, e := range netEffect(events) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		counted := false
+		switch {
+		case isCreate(e):
+			report.Added = append(report.Added, e.Path)
+			counted = !isFolderEvent(e)
+		case e.Event == EventEditFile:
+			report.Modified = append(report.Modified, e.Path)
+			counted = true
+		case isDelete(e):
+			report.Deleted = append(report.Deleted, e.Path)
+		case isRename(e):
+			report.Renamed[e.Path] = e.PreviousPath
+			counted = e.Event == EventRenameFile
+		}
+
+		if counted {
+			if info, err := w.Stat(e.Path); err == nil && !info.IsDir() {
+				report.BytesChanged += info.Size()
+			}
+		}
+	}
+
+	for _, list := range [][]string{report.Added, report.Modified, report.Deleted} {
+		sort.Strings(list)
+	}
+	return report, nil
+}
+
+func (r *ChangeReport) String() string {
+	b := strings.Builder{}
+	b.WriteString("Changes from " + r.Since.Format(time.RFC3339) + " to " + r.Until.Format(time.RFC3339) + "\n")
+	section := func(title string, paths []string) {
+		if len(paths) == 0 {
+			return
+		}
+		b.WriteString(title + ":\n")
+		for _, p := range paths {
+			b.WriteString("  " + p + "\n")
+		}
+	}
+	section("Added", r.Added)
+	section("Modified", r.Modified)
+	section("Deleted", r.Deleted)
+	if len(r.Renamed) > 0 {
+		renamed := make([]string, 0, len(r.Renamed))
+		for to, from := range r.Renamed {
+			renamed = append(renamed, from+" -> "+to)
+		}
+		sort.Strings(renamed)
+		section("Renamed", renamed)
+	}
+	return b.String()
+}
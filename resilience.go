@@ -0,0 +1,248 @@
+package fileWatcher
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a ResilientSink without spillover while its circuit is open.
+var ErrCircuitOpen = errors.New("the circuit of the sink is open")
+
+// CircuitState is the state of the circuit breaker of a ResilientSink.
+type CircuitState int
+
+const (
+	// CircuitClosed delivers the events to the sink.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen spills the events without trying the sink, after too many failures in a row.
+	CircuitOpen
+	// CircuitHalfOpen probes the sink with a single event once the open timeout elapsed, the other events are spilled.
+	// With a spillover, the probe is the oldest spilled event.
+	CircuitHalfOpen
+	// CircuitRecovering replays the spilled events to the sink after a successful probe. The new events are spilled
+	// behind them until the spillover is empty, so they are delivered in order.
+	CircuitRecovering
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	case CircuitRecovering:
+		return "recovering"
+	}
+	return "unknown"
+}
+
+// ResilienceOptions configures a ResilientSink.
+type ResilienceOptions struct {
+	// Retry retries a failed delivery with exponential backoff while the circuit is closed.
+	Retry RetryPolicy
+	// Jitter randomizes every backoff delay by up to this fraction of it, like 0.2, so the sinks of many watchers
+	// don't retry in lockstep.
+	Jitter float64
+	// FailureThreshold is the number of deliveries failing in a row that opens the circuit. It defaults to 5.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before an event is sent as a probe. It defaults to 30 seconds.
+	OpenTimeout time.Duration
+	// Spill receives the events that could not be delivered, and the events sent while the circuit is open. They are
+	// replayed to the sink once it recovers, at least once: a replay interrupted by a failure starts over. Without
+	// Spill, these events are dropped and Send returns the error.
+	Spill *Journal
+}
+
+// ResilienceStats is the accounting of a ResilientSink.
+type ResilienceStats struct {
+	State CircuitState
+	// Failures is the number of deliveries failing in a row.
+	Failures int
+	// Opened counts the times the circuit opened.
+	Opened   uint64
+	Spilled  uint64
+	Replayed uint64
+	Dropped  uint64
+	// LastError is the error of the last failed delivery.
+	LastError error
+}
+
+// ResilientSink protects the watcher from a failing sink, typically a network one like a WebhookSink or a ChatSink:
+// deliveries are retried with jittered exponential backoff, and after repeated failures a circuit breaker stops
+// calling the sink for a while, spilling the events to a local journal instead, until a probe succeeds and the
+// spilled events are replayed. A dead endpoint costs one failed call per open timeout instead of a blocked worker.
+type ResilientSink struct {
+	sink    Sink
+	options ResilienceOptions
+
+	mu       sync.Mutex
+	stats    ResilienceStats
+	openedAt time.Time
+	// spilledSince counts the events spilled since the replay started.
+	spilledSince int
+}
+
+// NewResilientSink wraps sink with the given resilience options.
+func NewResilientSink(sink Sink, options ResilienceOptions) *ResilientSink {
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = 5
+	}
+	if options.OpenTimeout <= 0 {
+		options.OpenTimeout = 30 * time.Second
+	}
+	return &ResilientSink{sink: sink, options: options}
+}
+
+// Send delivers e to the sink, or spills it while the circuit is not closed.
+func (s *ResilientSink) Send(e FileWatcherEvent) error {
+	s.mu.Lock()
+	probe := false
+	switch s.stats.State {
+	case CircuitOpen:
+		if time.Since(s.openedAt) < s.options.OpenTimeout {
+			defer s.mu.Unlock()
+			return s.spill(e, ErrCircuitOpen)
+		}
+		s.stats.State = CircuitHalfOpen
+		if s.options.Spill != nil {
+			// the replay of the spilled events probes the sink, e is delivered after them
+			defer s.mu.Unlock()
+			err := s.spill(e, ErrCircuitOpen)
+			go s.replay()
+			return err
+		}
+		probe = true
+	case CircuitHalfOpen, CircuitRecovering:
+		defer s.mu.Unlock()
+		return s.spill(e, ErrCircuitOpen)
+	}
+	s.mu.Unlock()
+
+	var err error
+	if probe {
+		err = s.sink.Send(e)
+	} else {
+		err = s.deliver(e)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failed(err)
+		return s.spill(e, err)
+	}
+	s.stats.Failures = 0
+	if probe {
+		s.stats.State = CircuitClosed
+	}
+	return nil
+}
+
+// deliver sends e according to the retry policy, with jitter.
+func (s *ResilientSink) deliver(e FileWatcherEvent) error {
+	attempts := s.options.Retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(s.jitter(s.options.Retry.delay(attempt - 1)))
+		}
+		if err = s.sink.Send(e); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (s *ResilientSink) jitter(d time.Duration) time.Duration {
+	if s.options.Jitter <= 0 || d <= 0 {
+		return d
+	}
+	return d + time.Duration((rand.Float64()*2-1)*s.options.Jitter*float64(d))
+}
+
+// failed accounts for a failed delivery, opening the circuit when needed. The caller holds the lock.
+func (s *ResilientSink) failed(err error) {
+	s.stats.Failures++
+	s.stats.LastError = err
+	if s.stats.State == CircuitClosed && s.stats.Failures < s.options.FailureThreshold {
+		return
+	}
+	if s.stats.State != CircuitOpen {
+		s.stats.Opened++
+		log.Warn("Opening the circuit of a sink after ", s.stats.Failures, " failures: ", err)
+	}
+	s.stats.State = CircuitOpen
+	s.openedAt = time.Now()
+}
+
+// spill hands e to the spillover, or drops it and returns err without one. The caller holds the lock.
+func (s *ResilientSink) spill(e FileWatcherEvent, err error) error {
+	if s.options.Spill == nil {
+		s.stats.Dropped++
+		return err
+	}
+	if spillErr := s.options.Spill.Append(e); spillErr != nil {
+		s.stats.Dropped++
+		return spillErr
+	}
+	s.stats.Spilled++
+	s.spilledSince++
+	return nil
+}
+
+// replay drains the spillover into the sink until it is empty, then closes the circuit. The first event replayed
+// probes the sink.
+func (s *ResilientSink) replay() {
+	labelGoroutine("sink-recovery")
+	for {
+		s.mu.Lock()
+		s.spilledSince = 0
+		s.mu.Unlock()
+
+		err := s.options.Spill.Drain(func(e FileWatcherEvent) error {
+			if err := s.sink.Send(e); err != nil {
+				return err
+			}
+			s.mu.Lock()
+			s.stats.Replayed++
+			s.stats.State = CircuitRecovering
+			s.mu.Unlock()
+			return nil
+		})
+
+		s.mu.Lock()
+		if err != nil {
+			s.failed(err)
+			s.mu.Unlock()
+			return
+		}
+		if s.spilledSince == 0 {
+			s.stats.State = CircuitClosed
+			s.stats.Failures = 0
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+	}
+}
+
+// State returns the state of the circuit.
+func (s *ResilientSink) State() CircuitState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats.State
+}
+
+// Stats returns the accounting of the sink.
+func (s *ResilientSink) Stats() ResilienceStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
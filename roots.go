@@ -0,0 +1,143 @@
+package fileWatcher
+
+import (
+	"sort"
+)
+
+// RootSpec describes a watched root for SwitchRoots.
+type RootSpec struct {
+	Path string
+	// Recursive watches the directories below Path too, see AddRecursive.
+	Recursive bool
+}
+
+// Roots returns the current watch set: the roots added with AddRecursive, and the paths added with Add that are not
+// below them.
+func (w *FileWatcher) Roots() []RootSpec {
+	w.recursive.mu.RLock()
+	recursive := make([]string, 0, len(w.recursive.roots))
+	for root := range w.recursive.roots {
+		recursive = append(recursive, root)
+	}
+	w.recursive.mu.RUnlock()
+
+	res := make([]RootSpec, 0, len(recursive))
+	for _, root := range recursive {
+		res = append(res, RootSpec{Path: root, Recursive: true})
+	}
+	for _, path := range w.WatchedMap.Keys() {
+		if !w.recursiveRoot(path) {
+			res = append(res, RootSpec{Path: path})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Path < res[j].Path
+	})
+	return res
+}
+
+// SwitchRoots replaces the current watch set, see Roots, with roots, for IDE like applications switching between
+// projects. Only the difference is applied: roots in both sets keep their watches and never miss an event. The new
+// roots are added before the old ones are removed, and when adding fails the roots added so far are removed again, so
+// the watch set is either switched or left as it was.
+func (w *FileWatcher) SwitchRoots(roots []RootSpec) error {
+	w.switching.Lock()
+	defer w.switching.Unlock()
+
+	desired := make(map[string]RootSpec, len(roots))
+	for _, root := range roots {
+		root.Path = w.normalize(root.Path)
+		desired[root.Path] = root
+	}
+	current := make(map[string]RootSpec)
+	for _, root := range w.Roots() {
+		current[root.Path] = root
+	}
+
+	// keep reports whether path stays watched because of the desired roots
+	keep := func(path string) bool {
+		if _, ok := desired[path]; ok {
+			return true
+		}
+		for _, root := range desired {
+			if root.Recursive && isBelow(path, root.Path) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var added []RootSpec
+	for _, root := range roots {
+		root.Path = w.normalize(root.Path)
+		if cur, ok := current[root.Path]; ok && cur.Recursive == root.Recursive {
+			continue
+		}
+		var err error
+		if root.Recursive {
+			err = w.AddRecursive(root.Path)
+		} else if cur, ok := current[root.Path]; ok && cur.Recursive {
+			// the root stays watched, it only stops being recursive below
+			err = nil
+		} else {
+			err = w.Add(root.Path)
+		}
+		if err != nil {
+			for _, undo := range added {
+				w.unwatchRoot(undo, func(path string) bool {
+					_, ok := current[path]
+					return ok || w.recursiveRootIn(current, path)
+				})
+			}
+			return err
+		}
+		added = append(added, root)
+	}
+
+	for path, root := range current {
+		if want, ok := desired[path]; ok && want.Recursive == root.Recursive {
+			continue
+		}
+		if !root.Recursive && keep(path) {
+			continue
+		}
+		w.unwatchRoot(root, keep)
+	}
+	return nil
+}
+
+// unwatchRoot removes the watches of root, except the paths keep reports.
+func (w *FileWatcher) unwatchRoot(root RootSpec, keep func(path string) bool) {
+	if !root.Recursive {
+		if keep(root.Path) {
+			return
+		}
+		if err := w.Remove(root.Path); err != nil {
+			w.log.Debug("Unable to remove the watch of ", root.Path, ": ", err)
+		}
+		return
+	}
+
+	w.recursive.mu.Lock()
+	delete(w.recursive.roots, root.Path)
+	w.recursive.mu.Unlock()
+	for _, path := range w.WatchedMap.Keys() {
+		if (path != root.Path && !isBelow(path, root.Path)) || keep(path) || w.recursiveRoot(path) {
+			continue
+		}
+		if err := w.Remove(path); err != nil {
+			w.log.Debug("Unable to remove the watch of ", path, ": ", err)
+			w.forgetWatch(path)
+		}
+	}
+}
+
+// recursiveRootIn reports whether path is below a recursive root of roots.
+func (w *FileWatcher) recursiveRootIn(roots map[string]RootSpec, path string) bool {
+	for _, root := range roots {
+		if root.Recursive && isBelow(path, root.Path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package fileWatcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Sink is a destination events can be delivered to.
+type Sink interface {
+	Send(e FileWatcherEvent) error
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(e FileWatcherEvent) error
+
+func (f SinkFunc) Send(e FileWatcherEvent) error {
+	return f(e)
+}
+
+// Route sends the events matching Pattern to Sink.
+type Route struct {
+	Name string
+	// Pattern is a glob matched against the event path, see Router for the syntax. An empty pattern matches all paths.
+	Pattern string
+	// Matcher selects the paths of the route in place of Pattern when it is set, see Matcher.
+	Matcher Matcher
+	// Events limits the route to these event types, like "CREATE_FILE". An empty list matches every event type.
+	Events []string
+	// Routes with a higher priority are evaluated first. Routes with the same priority keep the order they were added
+	// in.
+	Priority int
+	// Continue lets evaluation go on with the next routes after this one matched. By default the first matching route
+	// stops evaluation.
+	Continue bool
+	Sink     Sink
+}
+
+func (r Route) matches(e FileWatcherEvent) bool {
+	if r.Matcher != nil {
+		if !r.Matcher.Match(e.Path) {
+			return false
+		}
+	} else if r.Pattern != "" && !matchPattern(r.Pattern, e.Path) {
+		return false
+	}
+	if len(r.Events) == 0 {
+		return true
+	}
+	for _, kind := range r.Events {
+		if kind == e.Event.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// Router directs events to sinks according to a table of routes. Patterns without a slash, like "*.log", are matched
+// against the file name. Patterns with a slash are matched against the end of the path, or the whole path when they
+// start with a slash, and "**" matches any number of directories, like "build/**".
+type Router struct {
+	// Fallback receives the events no route matched, they are dropped when it is nil.
+	Fallback Sink
+
+	mu     sync.RWMutex
+	routes []Route
+	sinks  map[string]Sink
+}
+
+// NewRouter creates an empty Router.
+func NewRouter() *Router {
+	return &Router{sinks: make(map[string]Sink)}
+}
+
+// RegisterSink makes sink available under name to routes loaded with LoadConfig.
+func (r *Router) RegisterSink(name string, sink Sink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sinks[name] = sink
+}
+
+// AddRoute adds route to the routing table.
+func (r *Router) AddRoute(route Route) error {
+	if route.Sink == nil {
+		return errors.New("route " + route.Name + " has no sink")
+	}
+	if route.Pattern != "" && route.Matcher == nil {
+		if err := validPattern(route.Pattern); err != nil {
+			return fmt.Errorf("route %s: invalid pattern %q: %w", route.Name, route.Pattern, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, route)
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return r.routes[i].Priority > r.routes[j].Priority
+	})
+	return nil
+}
+
+// Routes returns a copy of the routing table in evaluation order.
+func (r *Router) Routes() []Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Route(nil), r.routes...)
+}
+
+// RouteConfig is the declarative form of a Route, where the sink is referenced by the name it was registered under.
+type RouteConfig struct {
+	Name     string   `json:"name"`
+	Pattern  string   `json:"pattern"`
+	Events   []string `json:"events,omitempty"`
+	Priority int      `json:"priority"`
+	Continue bool     `json:"continue"`
+	Sink     string   `json:"sink"`
+}
+
+// RouterConfig is the declarative form of a routing table.
+type RouterConfig struct {
+	Routes   []RouteConfig `json:"routes"`
+	Fallback string        `json:"fallback,omitempty"`
+}
+
+// LoadConfig adds the routes of the JSON encoded RouterConfig read from reader. Sinks are looked up by the names
+// given to RegisterSink.
+func (r *Router) LoadConfig(reader io.Reader) error {
+	config := RouterConfig{}
+	if err := json.NewDecoder(reader).Decode(&config); err != nil {
+		return err
+	}
+	return r.ApplyConfig(config)
+}
+
+// ApplyConfig adds the routes of config.
+func (r *Router) ApplyConfig(config RouterConfig) error {
+	r.mu.RLock()
+	sinks := make(map[string]Sink, len(r.sinks))
+	for name, sink := range r.sinks {
+		sinks[name] = sink
+	}
+	r.mu.RUnlock()
+
+	if config.Fallback != "" {
+		fallback, ok := sinks[config.Fallback]
+		if !ok {
+			return errors.New("unknown fallback sink " + config.Fallback)
+		}
+		r.mu.Lock()
+		r.Fallback = fallback
+		r.mu.Unlock()
+	}
+
+	for _, rc := range config.Routes {
+		sink, ok := sinks[rc.Sink]
+		if !ok {
+			return errors.New("route " + rc.Name + " references unknown sink " + rc.Sink)
+		}
+		err := r.AddRoute(Route{
+			Name:     rc.Name,
+			Pattern:  rc.Pattern,
+			Events:   rc.Events,
+			Priority: rc.Priority,
+			Continue: rc.Continue,
+			Sink:     sink,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match returns the routes e would be delivered to, in order.
+func (r *Router) Match(e FileWatcherEvent) []Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var res []Route
+	for _, route := range r.routes {
+		if !route.matches(e) {
+			continue
+		}
+		res = append(res, route)
+		if !route.Continue {
+			break
+		}
+	}
+	return res
+}
+
+// Route delivers e to every matching route, or the fallback sink. It returns the first error returned by a sink.
+func (r *Router) Route(e FileWatcherEvent) error {
+	routes := r.Match(e)
+	if len(routes) == 0 {
+		r.mu.RLock()
+		fallback := r.Fallback
+		r.mu.RUnlock()
+		if fallback != nil {
+			return fallback.Send(e)
+		}
+		return nil
+	}
+
+	var firstErr error
+	for _, route := range routes {
+		if err := route.Sink.Send(e); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+	return firstErr
+}
+
+// Send makes a Router usable as a Sink, so routing tables can be nested.
+func (r *Router) Send(e FileWatcherEvent) error {
+	return r.Route(e)
+}
+
+// Run routes the events received on events until done is signaled or events is closed. Errors returned by sinks are
+// sent on errs when it is not nil.
+func (r *Router) Run(done chan bool, events <-chan FileWatcherEvent, errs chan<- error) {
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := r.Route(e); err != nil && errs != nil {
+				errs <- err
+			}
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RsyncExporter turns accumulated change sets into file lists for the --files-from option of rsync, so replication
+// jobs only transfer what changed:
+//
+//	rsync -a --files-from=LIST --delete-missing-args ROOT/ DESTINATION/
+//
+// Paths are relative to Root. Deleted paths are listed too, --delete-missing-args removes them on the receiver. Note
+// that --files-from disables the recursion implied by -a, so the files of created and renamed folders are listed one
+// by one.
+type RsyncExporter struct {
+	Root string
+	// Dir receives the file lists.
+	Dir string
+	// Interval is how often Run exports the accumulated changes. It defaults to one minute.
+	Interval time.Duration
+	// NullSeparated separates the paths with NUL characters instead of newlines, for the --from0 option.
+	NullSeparated bool
+	// OnExport is called by Run with the path of every written list, for example to start the rsync job.
+	OnExport func(list string) error
+
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+// NewRsyncExporter creates an RsyncExporter for root writing its lists to dir every interval.
+func NewRsyncExporter(root string, dir string, interval time.Duration) *RsyncExporter {
+	return &RsyncExporter{Root: filepath.Clean(root), Dir: dir, Interval: interval}
+}
+
+// Add accumulates the net effect of cs.
+func (x *RsyncExporter) Add(cs *ChangeSet) {
+	x.AddEvents(cs.NetEffect())
+}
+
+// AddEvents accumulates the paths touched by events.
+func (x *RsyncExporter) AddEvents(events []FileWatcherEvent) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.pending == nil {
+		x.pending = make(map[string]bool)
+	}
+
+	for _, e := range events {
+		for _, path := range []string{e.PreviousPath, e.Path} {
+			if path != "" && isBelow(path, x.Root) {
+				x.pending[filepath.Clean(path)] = true
+			}
+		}
+	}
+}
+
+// Export writes the accumulated changes to a new list in Dir and returns its path. It returns an empty path when
+// nothing changed since the last export.
+func (x *RsyncExporter) Export() (string, error) {
+	x.mu.Lock()
+	pending := x.pending
+	x.pending = nil
+	x.mu.Unlock()
+	if len(pending) == 0 {
+		return "", nil
+	}
+
+	// the files below folders that exist now are listed one by one, rsync does not recurse into them
+	for path := range pending {
+		info, err := fs.Stat(path)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		_ = afero.Walk(fs, path, func(p string, info os.FileInfo, err error) error {
+			if err == nil {
+				pending[p] = true
+			}
+			return nil
+		})
+	}
+
+	list := make([]string, 0, len(pending))
+	for path := range pending {
+		rel, err := filepath.Rel(x.Root, path)
+		if err != nil {
+			continue
+		}
+		list = append(list, filepath.ToSlash(rel))
+	}
+	sort.Strings(list)
+
+	sep := "\n"
+	if x.NullSeparated {
+		sep = "\x00"
+	}
+	if err := fs.MkdirAll(x.Dir, 0755); err != nil {
+		x.restore(pending)
+		return "", err
+	}
+	target := filepath.Join(x.Dir, fmt.Sprintf("files-from-%d.txt", time.Now().UnixNano()))
+	if err := afero.WriteFile(fs, target, []byte(strings.Join(list, sep)+sep), 0644); err != nil {
+		x.restore(pending)
+		return "", err
+	}
+	return target, nil
+}
+
+// restore puts paths back after a failed export so they are part of the next one.
+func (x *RsyncExporter) restore(paths map[string]bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.pending == nil {
+		x.pending = make(map[string]bool)
+	}
+	for path := range paths {
+		x.pending[path] = true
+	}
+}
+
+// Run accumulates the change sets received on changeSets and exports them every interval until done is signaled or
+// changeSets is closed. Errors are sent on errs when it is not nil.
+func (x *RsyncExporter) Run(done chan bool, changeSets <-chan *ChangeSet, errs chan<- error) {
+	interval := x.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	export := func() {
+		list, err := x.Export()
+		if err == nil && list != "" && x.OnExport != nil {
+			err = x.OnExport(list)
+		}
+		if err != nil && errs != nil {
+			errs <- err
+		}
+	}
+
+	for {
+		select {
+		case cs, ok := <-changeSets:
+			if !ok {
+				export()
+				return
+			}
+			x.Add(cs)
+		case <-ticker.C:
+			export()
+		case <-done:
+			export()
+			return
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"time"
+)
+
+// ClassificationRule turns a raw notification, on its own or paired with the notification before it, into an event.
+// The event loop tries the rules in order on every notification the platform classifiers leave, the first rule
+// matching applies. A create no rule matches waits CreateClassifyDelay for a second notification before it is
+// classified on its own, the other notifications no rule matches are kept to pair with the next one.
+type ClassificationRule struct {
+	// Name identifies the rule in the traces and in Explain.
+	Name string
+	// Ops are the operations the notification must have, Without the ones it must not have.
+	Ops     fsnotify.Op
+	Without fsnotify.Op
+	// After makes the rule a pair: the notification before must have these operations. Zero matches the notification
+	// on its own.
+	After fsnotify.Op
+	// SameName requires both notifications of a pair to be about the same path.
+	SameName bool
+	// Window is how long before the notification the one it pairs with may have arrived. Zero allows any age.
+	Window time.Duration
+	// Event is the kind of the resulting event, zero drops the notifications. The path of a rename is the path of the
+	// notification with the create and its previous path the path of the other one, renames of folders are told
+	// apart by a stat. Deletes and moves out are told apart from the ones of folders by the listing cache, and may
+	// be held back to infer renames, see WithRenameInference.
+	Event EventKind
+}
+
+// DefaultClassificationRules returns the rules the event loop uses unless the ClassificationRules option is set, for
+// custom rules to start from.
+func DefaultClassificationRules() []ClassificationRule {
+	return []ClassificationRule{
+		// the item was moved away right after its create, like the temporary file of an atomic save. Its new name,
+		// if it is in the watched tree, gets a create of its own
+		{Name: "renamed-away", Ops: fsnotify.Rename, After: fsnotify.Create, SameName: true},
+		{Name: "rename-folder", Ops: fsnotify.Rename | fsnotify.Remove, After: fsnotify.Create, Event: EventRenameFolder},
+		{Name: "rename", Ops: fsnotify.Rename, After: fsnotify.Create, Event: EventRenameFile},
+		// the file was replaced by a new one, like by an atomic save
+		{Name: "edit", Ops: fsnotify.Create, After: fsnotify.Remove, Event: EventEditFile},
+		{Name: "rapid-delete", Ops: fsnotify.Remove, After: fsnotify.Create},
+		{Name: "delete-folder", Ops: fsnotify.Rename | fsnotify.Remove, Event: EventDeleteFolder},
+		// a rename not followed by its create moved the item out of the watched directories
+		{Name: "move-out", Ops: fsnotify.Rename, Event: EventMovedOutFile},
+	}
+}
+
+// classificationRules returns the rules of the event loop.
+func (w *FileWatcher) classificationRules() []ClassificationRule {
+	if w.options.ClassificationRules != nil {
+		return w.options.ClassificationRules
+	}
+	return DefaultClassificationRules()
+}
+
+// validateRules rejects the rules matching every notification and the renames without a pair.
+func validateRules(rules []ClassificationRule) error {
+	for i, rule := range rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprint("#", i)
+		}
+		if rule.Ops == 0 {
+			return fmt.Errorf("classification rule %s has no operations", name)
+		}
+		if rule.After == 0 && rule.Event.Is(EventRenameFile|EventRenameFolder) {
+			return fmt.Errorf("classification rule %s emits a rename from a single notification", name)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to cur, which arrived age after prev.
+func (r ClassificationRule) matches(cur fsnotify.Event, prev fsnotify.Event, age time.Duration) bool {
+	if !cur.Has(r.Ops) || cur.Op&r.Without != 0 {
+		return false
+	}
+	if r.After == 0 {
+		return true
+	}
+	if prev.Op == 0 || !prev.Has(r.After) || (r.SameName && cur.Name != prev.Name) {
+		return false
+	}
+	return r.Window <= 0 || age <= r.Window
+}
+
+// matchRule returns the first rule applying to cur, which arrived age after prev.
+func matchRule(rules []ClassificationRule, cur fsnotify.Event, prev fsnotify.Event, age time.Duration) (
+	ClassificationRule, bool) {
+	for _, rule := range rules {
+		if rule.matches(cur, prev, age) {
+			return rule, true
+		}
+	}
+	return ClassificationRule{}, false
+}
+
+// applyRule emits the event of rule for cur, paired with prev when the rule is a pair.
+func (w *FileWatcher) applyRule(rule ClassificationRule, cur fsnotify.Event, prev fsnotify.Event) {
+	if rule.Event == 0 {
+		if rule.After != 0 {
+			w.trace(cur.Name, "paired with the %s of %s by rule %s, nothing emitted", prev.Op, prev.Name, rule.Name)
+		} else {
+			w.trace(cur.Name, "dropped by rule %s", rule.Name)
+		}
+		return
+	}
+
+	e := FileWatcherEvent{Event: rule.Event, Path: cur.Name}
+	if rule.After != 0 {
+		w.trace(cur.Name, "paired with the %s of %s by rule %s", prev.Op, prev.Name, rule.Name)
+	}
+	switch {
+	case isRename(e):
+		e.Path, e.PreviousPath = prev.Name, cur.Name
+		if cur.Has(fsnotify.Create) && !prev.Has(fsnotify.Create) {
+			e.Path, e.PreviousPath = cur.Name, prev.Name
+		}
+		w.listings.remove(e.PreviousPath)
+		// a renamed folder watched itself reports its rename twice, the first one was held back as a delete
+		w.dropHeldDelete(e.PreviousPath)
+		if info, err := w.Stat(e.Path); err == nil && info.IsDir() {
+			e.Event = EventRenameFolder
+		}
+		w.classifyEmit(e)
+	case isDelete(e):
+		e = w.correctDeleteKind(e)
+		if !w.holdDelete(e) {
+			w.classifyEmit(e)
+		}
+	default:
+		w.classifyEmit(e)
+	}
+}
@@ -0,0 +1,98 @@
+package fileWatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/afero"
+)
+
+func TestDefaultClassificationRules(t *testing.T) {
+	none := fsnotify.Event{}
+	tests := []struct {
+		cur   fsnotify.Event
+		prev  fsnotify.Event
+		rule  string
+		event EventKind
+	}{
+		{fsnotify.Event{Name: "/d/new", Op: fsnotify.Rename}, fsnotify.Event{Name: "/d/new", Op: fsnotify.Create},
+			"renamed-away", 0},
+		{fsnotify.Event{Name: "/d/old", Op: fsnotify.Rename}, fsnotify.Event{Name: "/d/new", Op: fsnotify.Create},
+			"rename", EventRenameFile},
+		{fsnotify.Event{Name: "/d/old", Op: fsnotify.Rename | fsnotify.Remove},
+			fsnotify.Event{Name: "/d/new", Op: fsnotify.Create}, "rename-folder", EventRenameFolder},
+		{fsnotify.Event{Name: "/d/f", Op: fsnotify.Create}, fsnotify.Event{Name: "/d/f", Op: fsnotify.Remove},
+			"edit", EventEditFile},
+		{fsnotify.Event{Name: "/d/f", Op: fsnotify.Remove}, fsnotify.Event{Name: "/d/f", Op: fsnotify.Create},
+			"rapid-delete", 0},
+		{fsnotify.Event{Name: "/d/sub", Op: fsnotify.Rename | fsnotify.Remove}, none, "delete-folder",
+			EventDeleteFolder},
+		{fsnotify.Event{Name: "/d/f", Op: fsnotify.Rename}, none, "move-out", EventMovedOutFile},
+	}
+	for _, test := range tests {
+		rule, ok := matchRule(DefaultClassificationRules(), test.cur, test.prev, time.Millisecond)
+		if !ok || rule.Name != test.rule || rule.Event != test.event {
+			t.Errorf("%s after %s: rule %q %s, want %q %s", test.cur, test.prev, rule.Name, rule.Event, test.rule,
+				test.event)
+		}
+	}
+	if _, ok := matchRule(DefaultClassificationRules(), fsnotify.Event{Name: "/d/f", Op: fsnotify.Create}, none,
+		0); ok {
+		t.Error("a create on its own matched a rule, it waits for a second notification")
+	}
+}
+
+func TestClassificationRuleWindow(t *testing.T) {
+	rule := ClassificationRule{Ops: fsnotify.Create, After: fsnotify.Remove, SameName: true, Window: time.Second}
+	cur := fsnotify.Event{Name: "/d/f", Op: fsnotify.Create}
+	prev := fsnotify.Event{Name: "/d/f", Op: fsnotify.Remove}
+	if !rule.matches(cur, prev, time.Second) {
+		t.Error("a pair within the window did not match")
+	}
+	if rule.matches(cur, prev, 2*time.Second) {
+		t.Error("a pair out of the window matched")
+	}
+	if rule.matches(cur, fsnotify.Event{Name: "/d/g", Op: fsnotify.Remove}, 0) {
+		t.Error("a pair of two paths matched a SameName rule")
+	}
+}
+
+func TestInvalidClassificationRules(t *testing.T) {
+	for _, rule := range []ClassificationRule{
+		{Name: "everything"},
+		{Name: "lone-rename", Ops: fsnotify.Rename, Event: EventRenameFile},
+	} {
+		if _, err := Init(nil, afero.NewOsFs(), nopLogger{}, WithClassificationRules(rule)); err == nil {
+			t.Errorf("the rule %s was accepted", rule.Name)
+		}
+	}
+}
+
+func TestCustomClassificationRule(t *testing.T) {
+	withoutCustomKinds(t)
+	archived, err := RegisterEventKind("ARCHIVED")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := DefaultClassificationRules()
+	for i := range rules {
+		if rules[i].Name == "move-out" {
+			rules[i].Event = archived
+		}
+	}
+	dir, archive := t.TempDir(), t.TempDir()
+	path := filepath.Join(dir, "file")
+	writeFile(t, path, "")
+	w := newTestWatcher(t, WithClassificationRules(rules...))
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Rename(path, filepath.Join(archive, "file")); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event == archived && e.Path == path })
+}
@@ -0,0 +1,133 @@
+package fileWatcher
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"runtime/metrics"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// QueueStats is the occupancy of a channel of the watcher.
+type QueueStats struct {
+	Len int
+	Cap int
+}
+
+// RuntimeStats attributes the resources of the process to a watcher, so embedders can tell its share of their
+// goroutines and memory apart.
+type RuntimeStats struct {
+	// Goroutines counts the goroutines of the watcher by subsystem, like "event-loop" or "handlers". The goroutines of
+	// the sinks, of the pipeline stages and of the notifier backends are not tied to a watcher and are not counted.
+	Goroutines map[string]int
+	// Queues holds the occupancy of the channels of the watcher by name, the queues of the workers summed up.
+	Queues map[string]QueueStats
+	// QueueMemory is the memory held by the buffers of the queues, in bytes.
+	QueueMemory int64
+	// History is the estimated memory held by the history, in bytes.
+	History int64
+
+	// ProcessGoroutines, HeapObjects and GCCycles are the figures of the whole process the watcher figures relate to,
+	// from runtime/metrics.
+	ProcessGoroutines uint64
+	HeapObjects       uint64
+	GCCycles          uint64
+}
+
+// runtimeMetrics are the runtime/metrics samples of RuntimeStats, in the order of its fields.
+var runtimeMetrics = []string{
+	"/sched/goroutines:goroutines",
+	"/memory/classes/heap/objects:bytes",
+	"/gc/cycles/total:gc-cycles",
+}
+
+// labelsPattern matches the labels of a record of the goroutine profile.
+var labelsPattern = regexp.MustCompile(`"([^"]*)":"([^"]*)"`)
+
+// runtimeStats collects the RuntimeStats. Counting the goroutines takes a goroutine profile, which briefly stops the
+// world.
+func (w *FileWatcher) runtimeStats() RuntimeStats {
+	res := RuntimeStats{
+		Goroutines: w.goroutines(),
+		Queues:     make(map[string]QueueStats),
+	}
+	if w.history != nil {
+		res.History = w.history.size()
+	}
+
+	queue := func(name string, length int, capacity int, elem uintptr) {
+		q := res.Queues[name]
+		q.Len += length
+		q.Cap += capacity
+		res.Queues[name] = q
+		res.QueueMemory += int64(capacity) * int64(elem)
+	}
+	eventSize := unsafe.Sizeof(FileWatcherEvent{})
+	queue("events", len(w.Events), cap(w.Events), eventSize)
+	queue("errors", len(w.Errors), cap(w.Errors), unsafe.Sizeof(error(nil)))
+	if w.ChangeSets != nil {
+		queue("change-sets", len(w.ChangeSets), cap(w.ChangeSets), unsafe.Sizeof(&ChangeSet{}))
+	}
+	if w.Batches != nil {
+		queue("batches", len(w.Batches), cap(w.Batches), unsafe.Sizeof(&FileWatcherBatch{}))
+	}
+	for _, q := range w.dispatcher.queues {
+		queue("dispatch", len(q), cap(q), unsafe.Sizeof(dispatchTask{}))
+	}
+	for _, q := range w.classifier.queues {
+		queue("classify", len(q), cap(q), unsafe.Sizeof(func() {}))
+	}
+	w.handlers.mu.RLock()
+	for _, q := range w.handlers.queues {
+		queue("handlers", len(q), cap(q), eventSize)
+	}
+	w.handlers.mu.RUnlock()
+
+	samples := make([]metrics.Sample, len(runtimeMetrics))
+	for i, name := range runtimeMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	values := []*uint64{&res.ProcessGoroutines, &res.HeapObjects, &res.GCCycles}
+	for i, sample := range samples {
+		if sample.Value.Kind() == metrics.KindUint64 {
+			*values[i] = sample.Value.Uint64()
+		}
+	}
+	return res
+}
+
+// goroutines counts the goroutines labeled with the watcher by subsystem, from the goroutine profile.
+func (w *FileWatcher) goroutines() map[string]int {
+	res := make(map[string]int)
+	buf := bytes.Buffer{}
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		w.log.Warn("Unable to count the goroutines: ", err)
+		return res
+	}
+
+	// a record is a count of goroutines sharing a stack, followed by their labels
+	count := 0
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if n, _, ok := strings.Cut(line, " @ "); ok {
+			count, _ = strconv.Atoi(n)
+			continue
+		}
+		if !strings.HasPrefix(line, "# labels: ") {
+			continue
+		}
+		labels := make(map[string]string)
+		for _, m := range labelsPattern.FindAllStringSubmatch(line, -1) {
+			labels[m[1]] = m[2]
+		}
+		if labels[watcherLabelKey] == w.instance {
+			res[labels[profilingLabelKey]] += count
+		}
+	}
+	return res
+}
@@ -0,0 +1,141 @@
+package fileWatcher
+
+import (
+	"context"
+	"hash/fnv"
+	"io"
+	"sync"
+)
+
+// Deprecated: use EventReady.
+func (e FileWatcherEvent) ReadyEvent() EventKind {
+	return EventReady
+}
+
+// Deprecated: compare e.Event with EventReady.
+func (e FileWatcherEvent) IsReadyEvent() bool {
+	return e.Event == EventReady
+}
+
+// ScanVerdict is the outcome of scanning a file.
+type ScanVerdict struct {
+	Path  string
+	Clean bool
+	// Threat names what was found when the file is not clean.
+	Threat string
+}
+
+// Scanner scans the content of a file, for example with an antivirus engine.
+type Scanner interface {
+	Scan(ctx context.Context, path string, content io.Reader) (ScanVerdict, error)
+}
+
+// InfectedFileError is sent on the error channel of a ScanStage when a scanner rejected a file.
+type InfectedFileError struct {
+	Verdict ScanVerdict
+}
+
+func (e *InfectedFileError) Error() string {
+	return "file " + e.Verdict.Path + " did not pass the scan: " + e.Verdict.Threat
+}
+
+// ScanStage submits created, edited and renamed files to a Scanner. Every event is passed on to Events right away,
+// followed by a READY event for the file once the scanner found it clean, so consumers only pick up files marked
+// READY. Files that fail the scan never get a READY event and are reported as InfectedFileError instead.
+type ScanStage struct {
+	Scanner Scanner
+	Events  chan FileWatcherEvent
+
+	workers int
+	queues  []chan FileWatcherEvent
+}
+
+// NewScanStage creates a ScanStage running scans on the given number of workers.
+func NewScanStage(scanner Scanner, workers int) *ScanStage {
+	if workers < 1 {
+		workers = 1
+	}
+	return &ScanStage{
+		Scanner: scanner,
+		Events:  make(chan FileWatcherEvent),
+		workers: workers,
+	}
+}
+
+// Run scans the files touched by the events received on events until done is signaled or events is closed. Scan
+// failures are sent on errs when it is not nil.
+func (s *ScanStage) Run(done chan bool, events <-chan FileWatcherEvent, errs chan<- error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	s.queues = make([]chan FileWatcherEvent, s.workers)
+	for i := range s.queues {
+		s.queues[i] = make(chan FileWatcherEvent, 64)
+		wg.Add(1)
+		go func(queue chan FileWatcherEvent) {
+			defer wg.Done()
+			labelGoroutine("scan")
+			for e := range queue {
+				ready, err := s.Scan(ctx, e.Path)
+				if err != nil {
+					if errs != nil {
+						errs <- err
+					}
+					continue
+				}
+				s.Events <- ready
+			}
+		}(s.queues[i])
+	}
+
+	defer func() {
+		for _, queue := range s.queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			s.Events <- e
+			if e.Event.Is(EventCreateFile | EventEditFile | EventRenameFile | EventMovedInFile) {
+				s.queues[s.shard(e.Path)] <- e
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (s *ScanStage) shard(path string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(path))
+	return int(h.Sum32() % uint32(s.workers))
+}
+
+// Scan scans path synchronously and returns its READY event when it is clean.
+func (s *ScanStage) Scan(ctx context.Context, path string) (FileWatcherEvent, error) {
+	file, err := fs.Open(path)
+	if err != nil {
+		return FileWatcherEvent{}, err
+	}
+	defer file.Close()
+
+	verdict, err := s.Scanner.Scan(ctx, path, throttled(file))
+	if err != nil {
+		return FileWatcherEvent{}, err
+	}
+	verdict.Path = path
+	if !verdict.Clean {
+		return FileWatcherEvent{}, &InfectedFileError{Verdict: verdict}
+	}
+
+	e := FileWatcherEvent{Path: path}
+	e.Event = EventReady
+	return e, nil
+}
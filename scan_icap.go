@@ -0,0 +1,119 @@
+package fileWatcher
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ICAPScanner is a Scanner submitting files to an ICAP server (RFC 3507), like c-icap with ClamAV or a commercial
+// antivirus gateway, in RESPMOD requests. A 204 answer means the file is clean, any other answer that the server
+// blocked or rewrote it.
+type ICAPScanner struct {
+	// Addr is the host:port of the ICAP server, port 1344 by default.
+	Addr string
+	// Service is the ICAP service name, for example "avscan" or "srv_clamav".
+	Service string
+	Timeout time.Duration
+}
+
+// NewICAPScanner creates an ICAPScanner for the service at addr.
+func NewICAPScanner(addr string, service string) *ICAPScanner {
+	return &ICAPScanner{Addr: addr, Service: service, Timeout: time.Minute}
+}
+
+// icapThreatHeaders are the headers ICAP servers use to name what they found.
+var icapThreatHeaders = []string{"X-Infection-Found", "X-Violations-Found", "X-Virus-Id"}
+
+func (s *ICAPScanner) Scan(ctx context.Context, path string, content io.Reader) (ScanVerdict, error) {
+	addr := s.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "1344")
+	}
+	host, _, _ := net.SplitHostPort(addr)
+
+	dialer := net.Dialer{Timeout: s.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return ScanVerdict{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else if s.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(s.Timeout))
+	}
+
+	reqHdr := "GET /" + (&url.URL{Path: strings.TrimPrefix(path, "/")}).EscapedPath() + " HTTP/1.1\r\nHost: " + host +
+		"\r\n\r\n"
+	resHdr := "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\nTransfer-Encoding: chunked\r\n\r\n"
+
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "RESPMOD icap://%s/%s ICAP/1.0\r\n", addr, s.Service)
+	fmt.Fprintf(w, "Host: %s\r\n", host)
+	fmt.Fprintf(w, "Allow: 204\r\n")
+	fmt.Fprintf(w, "Encapsulated: req-hdr=0, res-hdr=%d, res-body=%d\r\n\r\n", len(reqHdr), len(reqHdr)+len(resHdr))
+	w.WriteString(reqHdr)
+	w.WriteString(resHdr)
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := content.Read(buf)
+		if n > 0 {
+			fmt.Fprintf(w, "%x\r\n", n)
+			w.Write(buf[:n])
+			w.WriteString("\r\n")
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ScanVerdict{}, err
+		}
+	}
+	w.WriteString("0\r\n\r\n")
+	if err := w.Flush(); err != nil {
+		return ScanVerdict{}, err
+	}
+
+	r := textproto.NewReader(bufio.NewReader(conn))
+	status, err := r.ReadLine()
+	if err != nil {
+		return ScanVerdict{}, err
+	}
+	fields := strings.SplitN(status, " ", 3)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "ICAP/") {
+		return ScanVerdict{}, fmt.Errorf("malformed ICAP status line %q", status)
+	}
+	code, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return ScanVerdict{}, fmt.Errorf("malformed ICAP status line %q", status)
+	}
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		return ScanVerdict{}, err
+	}
+
+	switch {
+	case code == 204:
+		return ScanVerdict{Path: path, Clean: true}, nil
+	case code == 200:
+		threat := "blocked by the ICAP server"
+		for _, name := range icapThreatHeaders {
+			if v := header.Get(name); v != "" {
+				threat = strings.TrimSpace(v)
+				break
+			}
+		}
+		return ScanVerdict{Path: path, Threat: threat}, nil
+	default:
+		return ScanVerdict{}, fmt.Errorf("ICAP server %s answered with status %d", addr, code)
+	}
+}
@@ -0,0 +1,191 @@
+package fileWatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EventSchemaVersion is the version of the wire schema written by EncodeEvent.
+//
+// Version 1 is the payload of the webhook sink of earlier releases, without a version field. Version 2 adds the
+// version, the lock flag and the identity of the watcher. Version 3 adds the sequence number and the idempotency key.
+// Version 4 adds the trashed flag. Version 5 adds the event ID and the trace context. Version 6 adds the count of the
+// events suppressed by the rate limit. Version 7 adds the attribute change of the chmod events. Version 8 adds the
+// tags. Version 9 adds the progress of the bulk operations.
+const EventSchemaVersion = 9
+
+// WireEvent is the serialized form of a FileWatcherEvent used by journals and network sinks. The events signed by an
+// EventSigner carry their signature in an additional sig field, see SignEvent.
+type WireEvent struct {
+	Version      int               `json:"v"`
+	Event        EventKind         `json:"event"`
+	Path         string            `json:"path"`
+	PreviousPath string            `json:"previousPath,omitempty"`
+	Time         time.Time         `json:"time"`
+	Locked       bool              `json:"locked,omitempty"`
+	Trashed      bool              `json:"trashed,omitempty"`
+	Suppressed   int               `json:"suppressed,omitempty"`
+	Attributes   *AttributeChange  `json:"attributes,omitempty"`
+	Bulk         *BulkProgress     `json:"bulk,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Host         string            `json:"host,omitempty"`
+	WatcherID    string            `json:"watcherId,omitempty"`
+	PID          int               `json:"pid,omitempty"`
+	Seq          uint64            `json:"seq,omitempty"`
+	Key          string            `json:"key,omitempty"`
+	ID           string            `json:"id,omitempty"`
+	TraceParent  string            `json:"traceparent,omitempty"`
+	TraceState   string            `json:"tracestate,omitempty"`
+}
+
+// NewWireEvent converts e to the current wire schema.
+func NewWireEvent(e FileWatcherEvent) WireEvent {
+	return WireEvent{
+		Version:      EventSchemaVersion,
+		Event:        e.Event,
+		Path:         e.Path,
+		PreviousPath: e.PreviousPath,
+		Time:         e.Time,
+		Locked:       e.Locked,
+		Trashed:      e.Trashed,
+		Suppressed:   e.Suppressed,
+		Attributes:   e.Attributes,
+		Bulk:         e.Bulk,
+		Tags:         e.Tags,
+		Host:         e.Host,
+		WatcherID:    e.WatcherID,
+		PID:          e.PID,
+		Seq:          e.Seq,
+		Key:          e.Key,
+		ID:           e.ID,
+		TraceParent:  e.Trace.TraceParent,
+		TraceState:   e.Trace.TraceState,
+	}
+}
+
+// FileWatcherEvent converts the wire event back.
+func (we WireEvent) FileWatcherEvent() FileWatcherEvent {
+	return FileWatcherEvent{
+		Path:         we.Path,
+		PreviousPath: we.PreviousPath,
+		Event:        we.Event,
+		Locked:       we.Locked,
+		Trashed:      we.Trashed,
+		Suppressed:   we.Suppressed,
+		Attributes:   we.Attributes,
+		Bulk:         we.Bulk,
+		Tags:         we.Tags,
+		Time:         we.Time,
+		Host:         we.Host,
+		WatcherID:    we.WatcherID,
+		PID:          we.PID,
+		Seq:          we.Seq,
+		Key:          we.Key,
+		ID:           we.ID,
+		Trace:        TraceContext{TraceParent: we.TraceParent, TraceState: we.TraceState},
+	}
+}
+
+// EncodeEvent serializes e with the current wire schema.
+func EncodeEvent(e FileWatcherEvent) ([]byte, error) {
+	return json.Marshal(NewWireEvent(e))
+}
+
+// MarshalJSON encodes e with the wire schema, see EncodeEvent.
+func (e FileWatcherEvent) MarshalJSON() ([]byte, error) {
+	return EncodeEvent(e)
+}
+
+// UnmarshalJSON decodes an event written with any version of the wire schema, see DecodeEvent.
+func (e *FileWatcherEvent) UnmarshalJSON(data []byte) error {
+	decoded, err := DecodeEvent(data)
+	if err != nil {
+		return err
+	}
+	*e = decoded
+	return nil
+}
+
+// DecodeEvent deserializes an event written with any version of the wire schema.
+func DecodeEvent(data []byte) (FileWatcherEvent, error) {
+	migrated, err := MigrateEvent(data)
+	if err != nil {
+		return FileWatcherEvent{}, err
+	}
+	we := WireEvent{}
+	if err := json.Unmarshal(migrated, &we); err != nil {
+		return FileWatcherEvent{}, err
+	}
+	return we.FileWatcherEvent(), nil
+}
+
+// eventMigration upgrades the fields of a serialized event by one version.
+type eventMigration func(fields map[string]json.RawMessage) error
+
+// eventMigrations are indexed by the version they upgrade from.
+var eventMigrations = map[int]eventMigration{
+	1: func(fields map[string]json.RawMessage) error {
+		// version 2 only added fields, their zero values are right for older events
+		return nil
+	},
+	2: func(fields map[string]json.RawMessage) error {
+		// version 3 only added fields, events without a key are never deduplicated
+		return nil
+	},
+	3: func(fields map[string]json.RawMessage) error {
+		// version 4 only added the trashed flag
+		return nil
+	},
+	4: func(fields map[string]json.RawMessage) error {
+		// version 5 only added the ID and the trace context, older events are identified by their key
+		if _, ok := fields["id"]; !ok {
+			if key, ok := fields["key"]; ok {
+				fields["id"] = key
+			}
+		}
+		return nil
+	},
+	5: func(fields map[string]json.RawMessage) error {
+		// version 6 only added the suppressed count
+		return nil
+	},
+	6: func(fields map[string]json.RawMessage) error {
+		// version 7 only added the attribute change
+		return nil
+	},
+	7: func(fields map[string]json.RawMessage) error {
+		// version 8 only added the tags
+		return nil
+	},
+}
+
+// MigrateEvent upgrades an event serialized with an older version of the wire schema to the current version. Events
+// without a version field are version 1. Events written by a newer version of the package are rejected.
+func MigrateEvent(data []byte) ([]byte, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	version := 1
+	if raw, ok := fields["v"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, fmt.Errorf("malformed event schema version: %w", err)
+		}
+	}
+	if version == EventSchemaVersion {
+		return data, nil
+	}
+	if version > EventSchemaVersion || version < 1 {
+		return nil, fmt.Errorf("unsupported event schema version %d", version)
+	}
+
+	for ; version < EventSchemaVersion; version++ {
+		if err := eventMigrations[version](fields); err != nil {
+			return nil, fmt.Errorf("migrating event from schema version %d: %w", version, err)
+		}
+	}
+	fields["v"] = json.RawMessage(fmt.Sprint(EventSchemaVersion))
+	return json.Marshal(fields)
+}
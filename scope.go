@@ -0,0 +1,308 @@
+package fileWatcher
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// defaultPromoteThreshold is the number of individually added files of a directory at which the directory is watched
+// instead.
+const defaultPromoteThreshold = 64
+
+// WatchScope is what the consumer hears about from a watched directory.
+type WatchScope int
+
+const (
+	// ScopeAll reports every child of the directory, it applies to the directories added with Add.
+	ScopeAll WatchScope = iota
+	// ScopeChildren only reports the children added with AddChildren or added individually with Add.
+	ScopeChildren
+)
+
+// dirScope describes why the kernel watches a directory and which of its children the consumer wants to hear about.
+type dirScope struct {
+	// explicit is set when the directory itself was added, the consumer then hears about all of its children.
+	explicit bool
+	// files holds the paths of the files of the directory added individually. They are only watched on their own
+	// while the directory is neither explicit nor promoted.
+	files map[string]bool
+	// promoted is set when the directory is watched in place of its files.
+	promoted bool
+	// children is set when the files were added with AddChildren, the directory then stays watched in their place
+	// whatever their number.
+	children bool
+}
+
+type scopeState struct {
+	mu   sync.Mutex
+	dirs map[string]*dirScope
+}
+
+func (s *scopeState) get(dir string) *dirScope {
+	if s.dirs == nil {
+		s.dirs = make(map[string]*dirScope)
+	}
+	scope, ok := s.dirs[dir]
+	if !ok {
+		scope = &dirScope{files: make(map[string]bool)}
+		s.dirs[dir] = scope
+	}
+	return scope
+}
+
+func (s *scopeState) drop(dir string) {
+	if scope, ok := s.dirs[dir]; ok && !scope.explicit && !scope.promoted && len(scope.files) == 0 {
+		delete(s.dirs, dir)
+	}
+}
+
+// excluded reports whether path is dropped because its directory is only watched on behalf of some of its files.
+func (s *scopeState) excluded(path string) (string, bool) {
+	if !s.outOfScope(path) {
+		return "", false
+	}
+	return "the scope of " + filepath.Dir(path) + ", only some of its files are watched", true
+}
+
+// outOfScope reports whether path is a sibling of the files a directory is only watched for. It is checked as the
+// events arrive, so the siblings of the watched files cost a lookup and nothing else.
+func (s *scopeState) outOfScope(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scope, ok := s.dirs[filepath.Dir(path)]
+	return ok && scope.promoted && !scope.explicit && !scope.files[path]
+}
+
+func (w *FileWatcher) promoteThreshold() int {
+	if w.options.SingleFiles {
+		return 1
+	}
+	if w.options.PromoteThreshold == 0 {
+		return defaultPromoteThreshold
+	}
+	return w.options.PromoteThreshold
+}
+
+// addFile watches a single file. The file is covered by the watch of its directory when the directory was added
+// itself. Otherwise it gets its own watch until enough files of the same directory are watched, then the directory is
+// watched in their place and its events are filtered down to the added files, which saves kernel watches.
+func (w *FileWatcher) addFile(path string) error {
+	dir := filepath.Dir(path)
+	w.scopes.mu.Lock()
+	defer w.scopes.mu.Unlock()
+
+	scope := w.scopes.get(dir)
+	if scope.explicit || scope.promoted {
+		scope.files[path] = true
+		w.WatchedMap.Set(path, path)
+		return nil
+	}
+
+	threshold := w.promoteThreshold()
+	if threshold < 0 || len(scope.files)+1 < threshold {
+		if err := w.currentNotifier().Add(path); err != nil {
+			w.scopes.drop(dir)
+			return err
+		}
+		scope.files[path] = true
+		w.WatchedMap.Set(path, path)
+		return nil
+	}
+
+	scope.files[path] = true
+	if err := w.promote(dir, scope); err != nil {
+		delete(scope.files, path)
+		return err
+	}
+	w.WatchedMap.Set(path, path)
+	return nil
+}
+
+// promote watches dir in place of its individually watched files. The caller holds the lock.
+func (w *FileWatcher) promote(dir string, scope *dirScope) error {
+	w.log.Debug("Watching ", dir, " in place of ", len(scope.files), " of its files")
+	if err := w.currentNotifier().Add(dir); err != nil {
+		return err
+	}
+	w.unwatchFiles(scope)
+	scope.promoted = true
+	return nil
+}
+
+// removeFile stops watching a file added with addFile. A promoted directory goes back to watching its remaining files
+// individually once less than half of the threshold is left.
+func (w *FileWatcher) removeFile(path string) error {
+	dir := filepath.Dir(path)
+	w.scopes.mu.Lock()
+	defer w.scopes.mu.Unlock()
+
+	scope := w.scopes.get(dir)
+	delete(scope.files, path)
+	defer w.scopes.drop(dir)
+	if scope.explicit {
+		// the file is covered by the watch of the directory
+		return nil
+	}
+	if !scope.promoted {
+		return w.currentNotifier().Remove(path)
+	}
+	if len(scope.files) > 0 && (len(scope.files) >= w.promoteThreshold()/2 || scope.children) {
+		return nil
+	}
+
+	if len(scope.files) > 0 {
+		w.log.Debug("Watching the ", len(scope.files), " remaining files of ", dir, " individually")
+	}
+	w.watchFiles(scope)
+	scope.promoted = false
+	scope.children = false
+	return w.currentNotifier().Remove(dir)
+}
+
+// addDir records that dir was added itself. The files added individually before are covered by its watch from now on
+// and lose their own watches.
+func (w *FileWatcher) addDir(dir string) {
+	w.scopes.mu.Lock()
+	defer w.scopes.mu.Unlock()
+
+	scope := w.scopes.get(dir)
+	scope.explicit = true
+	if !scope.promoted {
+		w.unwatchFiles(scope)
+	}
+	scope.promoted = false
+}
+
+// removeDir stops watching a directory added itself. The files of the directory added individually stay watched, the
+// directory keeps its watch on their behalf when there are enough of them.
+func (w *FileWatcher) removeDir(dir string) error {
+	w.scopes.mu.Lock()
+	defer w.scopes.mu.Unlock()
+
+	scope := w.scopes.get(dir)
+	scope.explicit = false
+	defer w.scopes.drop(dir)
+
+	threshold := w.promoteThreshold()
+	if len(scope.files) > 0 && (scope.children || (threshold >= 0 && len(scope.files) >= threshold)) {
+		w.log.Debug("Keeping the watch of ", dir, " for ", len(scope.files), " of its files")
+		scope.promoted = true
+		return nil
+	}
+	w.watchFiles(scope)
+	return w.currentNotifier().Remove(dir)
+}
+
+// pruneFiles removes the files of dir added individually, when dir itself is watched.
+func (w *FileWatcher) pruneFiles(dir string) {
+	w.scopes.mu.Lock()
+	scope, ok := w.scopes.dirs[dir]
+	var files []string
+	if ok && scope.explicit {
+		for file := range scope.files {
+			files = append(files, file)
+		}
+	}
+	w.scopes.mu.Unlock()
+
+	sort.Strings(files)
+	for _, file := range files {
+		if err := w.Remove(file); err != nil {
+			w.log.Debug("Unable to remove the watch of ", file, ": ", err)
+		}
+	}
+}
+
+func (w *FileWatcher) watchFiles(scope *dirScope) {
+	for file := range scope.files {
+		if err := w.currentNotifier().Add(file); err != nil {
+			w.log.Warn("Unable to watch ", file, ": ", err)
+		}
+	}
+}
+
+func (w *FileWatcher) unwatchFiles(scope *dirScope) {
+	for file := range scope.files {
+		if err := w.currentNotifier().Remove(file); err != nil {
+			w.log.Debug("Unable to remove the watch of ", file, ": ", err)
+		}
+	}
+}
+
+// isFileWatch reports whether path was added with addFile.
+func (w *FileWatcher) isFileWatch(path string) bool {
+	w.scopes.mu.Lock()
+	defer w.scopes.mu.Unlock()
+	scope, ok := w.scopes.dirs[filepath.Dir(path)]
+	return ok && scope.files[path]
+}
+
+// AddChildren watches the children of dir with the given names, which do not have to exist yet, and nothing else in
+// dir: the directory is watched with ScopeChildren and its events are filtered down to the named children. Adding dir
+// itself with Add widens its scope to ScopeAll, removing it again goes back to the named children.
+func (w *FileWatcher) AddChildren(dir string, names ...string) error {
+	dir = w.normalize(dir)
+	info, err := w.fs.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New(dir + " is not a directory")
+	}
+	for _, name := range names {
+		if name == "" || filepath.Base(name) != name {
+			return errors.New("invalid child name " + name)
+		}
+	}
+	if w.shouldPoll(dir) {
+		return errors.New("the children of the polled directory " + dir + " can not be watched on their own")
+	}
+
+	w.scopes.mu.Lock()
+	defer w.scopes.mu.Unlock()
+
+	scope := w.scopes.get(dir)
+	if !scope.explicit && !scope.promoted {
+		if err := w.currentNotifier().Add(dir); err != nil {
+			w.scopes.drop(dir)
+			return err
+		}
+		w.unwatchFiles(scope)
+		scope.promoted = true
+	}
+	scope.children = true
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		scope.files[path] = true
+		w.WatchedMap.Set(path, path)
+	}
+	return nil
+}
+
+// Scope returns the scope of the watched directory dir, and the names of the children reported with ScopeChildren.
+// It reports false when neither dir nor any of its children are watched.
+func (w *FileWatcher) Scope(dir string) (WatchScope, []string, bool) {
+	dir = w.normalize(dir)
+	w.scopes.mu.Lock()
+	defer w.scopes.mu.Unlock()
+
+	scope, ok := w.scopes.dirs[dir]
+	if !ok {
+		if w.Contains(dir) {
+			// polled directories have no scope of their own
+			return ScopeAll, nil, true
+		}
+		return ScopeAll, nil, false
+	}
+	if scope.explicit {
+		return ScopeAll, nil, true
+	}
+	names := make([]string, 0, len(scope.files))
+	for path := range scope.files {
+		names = append(names, filepath.Base(path))
+	}
+	sort.Strings(names)
+	return ScopeChildren, names, true
+}
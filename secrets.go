@@ -0,0 +1,279 @@
+package fileWatcher
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/spf13/afero"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretsBuffer is how many updates and errors a SecretsWatcher holds for a consumer that is behind.
+const secretsBuffer = 16
+
+// SecretValidator checks that the content of a secret file parses correctly.
+type SecretValidator func(content []byte) error
+
+// SecretUpdate is delivered when a secret file was created or changed and its new content is valid, or when it was
+// removed.
+type SecretUpdate struct {
+	Path    string
+	Content []byte
+	Removed bool
+}
+
+// InvalidSecretError is sent on SecretsWatcher.Errors when a secret file changed to content that does not validate.
+// The previous valid content is kept.
+type InvalidSecretError struct {
+	Path string
+	// QuarantinePath is where a copy of the invalid content was written, empty when quarantining is disabled.
+	QuarantinePath string
+	Err            error
+}
+
+func (e *InvalidSecretError) Error() string {
+	return "invalid secret " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *InvalidSecretError) Unwrap() error {
+	return e.Err
+}
+
+// SecretsWatcher validates changes to a directory of PEM and JSON secrets and only passes on valid content.
+//
+// A consumer behind on Updates does not hold back the validation: the updates it did not receive yet are coalesced,
+// only the last one of each path is delivered, in the order the paths were first updated. Errors not received are
+// dropped once Errors is full. Current always returns the last valid content.
+type SecretsWatcher struct {
+	Dir string
+	// Validators maps a file extension to its validator. Files with other extensions are ignored.
+	Validators map[string]SecretValidator
+	// QuarantineDir, when set, receives a timestamped copy of every invalid update for inspection.
+	QuarantineDir string
+
+	Updates chan SecretUpdate
+	Errors  chan error
+
+	mu      sync.Mutex
+	current map[string][]byte
+	// pending holds the updates not delivered yet, by path, and order their paths.
+	pending map[string]*SecretUpdate
+	order   []string
+}
+
+// NewSecretsWatcher creates a SecretsWatcher for dir validating .pem, .crt, .key and .json files.
+func NewSecretsWatcher(dir string) *SecretsWatcher {
+	return &SecretsWatcher{
+		Dir: filepath.Clean(dir),
+		Validators: map[string]SecretValidator{
+			".pem":  ValidatePEM,
+			".crt":  ValidatePEM,
+			".key":  ValidatePEM,
+			".json": ValidateJSON,
+		},
+		Updates: make(chan SecretUpdate, secretsBuffer),
+		Errors:  make(chan error, secretsBuffer),
+		current: make(map[string][]byte),
+	}
+}
+
+// ValidatePEM checks that content holds at least one PEM block and that certificates parse.
+func ValidatePEM(content []byte) error {
+	rest := content
+	blocks := 0
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		blocks++
+		if block.Type == "CERTIFICATE" {
+			if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+				return err
+			}
+		}
+	}
+
+	if blocks == 0 {
+		return errors.New("no PEM block found")
+	}
+	if len(strings.TrimSpace(string(rest))) > 0 {
+		return errors.New("trailing data after the last PEM block")
+	}
+	return nil
+}
+
+// ValidateJSON checks that content is valid JSON.
+func ValidateJSON(content []byte) error {
+	if !json.Valid(content) {
+		return errors.New("content is not valid JSON")
+	}
+	return nil
+}
+
+// Current returns the last valid content of the secret at path.
+func (s *SecretsWatcher) Current(path string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.current[filepath.Clean(path)]
+	return content, ok
+}
+
+// Run validates the secrets touched by the events received on events until done is signaled or events is closed.
+// The updates pending when it returns are delivered by the next call of Handle or Run.
+func (s *SecretsWatcher) Run(done chan bool, events <-chan FileWatcherEvent) {
+	for {
+		// the next pending update is sent once the consumer is ready, without holding back the events
+		var updates chan SecretUpdate
+		var update SecretUpdate
+		next := s.nextUpdate()
+		if next != nil {
+			updates, update = s.Updates, *next
+		}
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			s.Handle(e)
+		case updates <- update:
+			s.delivered(next)
+		case <-done:
+			return
+		}
+	}
+}
+
+// Handle processes a single event. It does not wait for the consumers of Updates and Errors.
+func (s *SecretsWatcher) Handle(e FileWatcherEvent) {
+	if e.Event == EventRenameFile && s.watched(e.PreviousPath) {
+		s.removed(e.PreviousPath)
+	}
+	if !s.watched(e.Path) {
+		return
+	}
+
+	switch e.Event {
+	case EventCreateFile, EventEditFile, EventRenameFile, EventMovedInFile:
+		s.changed(e.Path)
+	case EventDeleteFile, EventMovedOutFile:
+		s.removed(e.Path)
+	}
+}
+
+func (s *SecretsWatcher) watched(path string) bool {
+	if filepath.Dir(filepath.Clean(path)) != s.Dir {
+		return false
+	}
+	_, ok := s.Validators[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+func (s *SecretsWatcher) changed(path string) {
+	path = filepath.Clean(path)
+	content, err := afero.ReadFile(fs, path)
+	if err != nil {
+		s.sendError(err)
+		return
+	}
+
+	validate := s.Validators[strings.ToLower(filepath.Ext(path))]
+	if err := validate(content); err != nil {
+		s.sendError(&InvalidSecretError{Path: path, QuarantinePath: s.quarantine(path, content), Err: err})
+		return
+	}
+
+	s.mu.Lock()
+	s.current[path] = content
+	s.mu.Unlock()
+	s.queueUpdate(SecretUpdate{Path: path, Content: content})
+}
+
+func (s *SecretsWatcher) removed(path string) {
+	path = filepath.Clean(path)
+	s.mu.Lock()
+	delete(s.current, path)
+	s.mu.Unlock()
+	s.queueUpdate(SecretUpdate{Path: path, Removed: true})
+}
+
+// queueUpdate queues u, replacing the update of its path not delivered yet, and delivers the pending updates Updates
+// has room for.
+func (s *SecretsWatcher) queueUpdate(u SecretUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[string]*SecretUpdate)
+	}
+	if _, ok := s.pending[u.Path]; !ok {
+		s.order = append(s.order, u.Path)
+	}
+	s.pending[u.Path] = &u
+	for len(s.order) > 0 {
+		select {
+		case s.Updates <- *s.pending[s.order[0]]:
+			delete(s.pending, s.order[0])
+			s.order = s.order[1:]
+		default:
+			return
+		}
+	}
+}
+
+// nextUpdate returns the next pending update, nil when there is none.
+func (s *SecretsWatcher) nextUpdate() *SecretUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.order) == 0 {
+		return nil
+	}
+	return s.pending[s.order[0]]
+}
+
+// delivered drops u, returned by nextUpdate, from the pending updates unless a newer update of its path replaced it.
+func (s *SecretsWatcher) delivered(u *SecretUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[u.Path] != u {
+		return
+	}
+	delete(s.pending, u.Path)
+	for i, path := range s.order {
+		if path == u.Path {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// sendError delivers err on Errors, or drops it when Errors is full.
+func (s *SecretsWatcher) sendError(err error) {
+	select {
+	case s.Errors <- err:
+	default:
+		log.Debug("Errors of the secrets watcher is full, dropped: ", err)
+	}
+}
+
+func (s *SecretsWatcher) quarantine(path string, content []byte) string {
+	if s.QuarantineDir == "" {
+		return ""
+	}
+
+	target := filepath.Join(s.QuarantineDir, fmt.Sprintf("%s.%d", filepath.Base(path), time.Now().UnixNano()))
+	if err := fs.MkdirAll(s.QuarantineDir, 0700); err != nil {
+		log.Error("Unable to create quarantine directory ", s.QuarantineDir, ": ", err)
+		return ""
+	}
+	if err := afero.WriteFile(fs, target, content, 0600); err != nil {
+		log.Error("Unable to quarantine ", path, ": ", err)
+		return ""
+	}
+	return target
+}
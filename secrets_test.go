@@ -0,0 +1,68 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSecretsWatcherSlowConsumer(t *testing.T) {
+	// sets the filesystem and the logger of the package
+	newTestWatcher(t)
+	dir := t.TempDir()
+	s := NewSecretsWatcher(dir)
+	events := make(chan FileWatcherEvent)
+	done := make(chan bool)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.Run(done, events)
+	}()
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+
+	// nobody receives the updates nor the errors meanwhile
+	secrets := 2 * secretsBuffer
+	for version := 0; version < 3; version++ {
+		for i := 0; i < secrets; i++ {
+			path := filepath.Join(dir, fmt.Sprint("secret", i, ".json"))
+			writeFile(t, path, fmt.Sprint(version))
+			select {
+			case events <- FileWatcherEvent{Event: EventEditFile, Path: path}:
+			case <-time.After(testTimeout):
+				t.Fatal("the secrets watcher is held back by its consumer")
+			}
+		}
+		invalid := filepath.Join(dir, "invalid.json")
+		writeFile(t, invalid, "{")
+		events <- FileWatcherEvent{Event: EventEditFile, Path: invalid}
+	}
+	if content, _ := s.Current(filepath.Join(dir, "secret0.json")); string(content) != "2" {
+		t.Fatalf("current content %q", content)
+	}
+
+	// the last update of every secret is delivered
+	last := make(map[string]string)
+	timeout := time.After(testTimeout)
+	for len(last) < secrets || countValue(last, "2") < secrets {
+		select {
+		case u := <-s.Updates:
+			last[u.Path] = string(u.Content)
+		case <-timeout:
+			t.Fatalf("%d of the %d last updates delivered", countValue(last, "2"), secrets)
+		}
+	}
+}
+
+func countValue(m map[string]string, value string) int {
+	count := 0
+	for _, v := range m {
+		if v == value {
+			count++
+		}
+	}
+	return count
+}
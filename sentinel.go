@@ -0,0 +1,96 @@
+package fileWatcher
+
+import (
+	"context"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sentinelPrefix starts the names of the hidden files the watcher writes to check its own watches. Their events never
+// reach the consumers.
+const sentinelPrefix = ".fileWatcher-sentinel-"
+
+var sentinelCount uint64
+
+type sentinelState struct {
+	mu      sync.Mutex
+	waiting map[string]chan struct{}
+}
+
+// isSentinel reports whether path is a sentinel file.
+func isSentinel(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), sentinelPrefix)
+}
+
+// sentinelSeen wakes up the round trip waiting for the event of the sentinel at path.
+func (w *FileWatcher) sentinelSeen(path string) {
+	w.sentinels.mu.Lock()
+	defer w.sentinels.mu.Unlock()
+	if seen, ok := w.sentinels.waiting[path]; ok {
+		close(seen)
+		delete(w.sentinels.waiting, path)
+	}
+}
+
+// roundTrip writes a sentinel file in the watched directory dir and waits for its event. The sentinel is rewritten
+// until its event arrives, in case the first write happened before the watch was active.
+func (w *FileWatcher) roundTrip(ctx context.Context, dir string) error {
+	name := sentinelPrefix + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatUint(atomic.AddUint64(&sentinelCount, 1), 10)
+	path := filepath.Join(dir, name)
+	seen := make(chan struct{})
+
+	w.sentinels.mu.Lock()
+	if w.sentinels.waiting == nil {
+		w.sentinels.waiting = make(map[string]chan struct{})
+	}
+	w.sentinels.waiting[path] = seen
+	w.sentinels.mu.Unlock()
+
+	defer func() {
+		w.sentinels.mu.Lock()
+		delete(w.sentinels.waiting, path)
+		w.sentinels.mu.Unlock()
+		if err := w.fs.Remove(path); err != nil && !os.IsNotExist(err) {
+			w.log.Warn("Unable to remove the sentinel ", path, ": ", err)
+		}
+	}()
+
+	retry := time.NewTicker(25 * time.Millisecond)
+	defer retry.Stop()
+	for i := 0; ; i++ {
+		if err := afero.WriteFile(w.fs, path, []byte(strconv.Itoa(i)), 0600); err != nil {
+			return err
+		}
+		select {
+		case <-seen:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-retry.C:
+		}
+	}
+}
+
+// AddAndWait adds path like Add and only returns once the watch is active, so changes made right after it are not
+// missed. For directories watched by change notifications a hidden sentinel file is written until its event arrives.
+// Watches of files and polled paths are active once Add returns.
+func (w *FileWatcher) AddAndWait(ctx context.Context, path string) error {
+	path = w.normalize(path)
+	if err := w.Add(path); err != nil {
+		return err
+	}
+	info, err := w.fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() || w.shouldPoll(path) {
+		return nil
+	}
+	return w.roundTrip(ctx, path)
+}
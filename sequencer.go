@@ -0,0 +1,211 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The sequencer hands the emitted events to the dispatch workers in an order keeping these guarantees, whatever the
+// number of workers:
+//
+//  1. The events of a path are delivered in the order of their Seq.
+//  2. A rename is delivered after the events of its PreviousPath emitted before it, and before the events of its Path
+//     emitted after it, so the two halves of a move are never delivered around unrelated events of either path.
+//
+// Events of unrelated paths carry no guarantee. Stability and the debouncing release the event they hold back before
+// the later events of its path, but LockPolicyWait delivers each locked file once it is unlocked, possibly after later
+// events of the file. The CheckOrdering option verifies the guarantees at delivery, so a change breaking them shows up
+// in tests as an OrderingError.
+//
+// The OrderedDelivery option extends the guarantees of a path to the paths of its directory:
+//
+//  3. The events of the paths of a directory are delivered in the order of their Seq, a rename counting for the
+//     directories of both its paths.
+//
+// The classification, the dispatch and the handlers are then spread over their workers by directory instead of path.
+// The ordering takes precedence over the holding back: an event releases the events the stability wait, the rate
+// limit and the debouncing hold back for the other paths of its directories before it goes through, and the files
+// locked by their writer are delivered with Locked set instead of deferred. The events with PriorityHigh still go
+// ahead, and the coalescing window still replaces its events with their net effect.
+
+// OrderingError is sent on Errors with the CheckOrdering option when an event is delivered after an event of the same
+// path emitted later.
+type OrderingError struct {
+	// Path is the path whose events were delivered out of order.
+	Path  string
+	Event EventKind
+	Seq   uint64
+	// After is the Seq of the event of Path delivered before.
+	After uint64
+}
+
+func (e *OrderingError) Error() string {
+	return fmt.Sprintf("%s %d of %s delivered after event %d", e.Event, e.Seq, e.Path, e.After)
+}
+
+type orderingState struct {
+	mu   sync.Mutex
+	last map[string]orderedPath
+	// publishing is held while an event is numbered and handed to the dispatch with the OrderedDelivery option.
+	publishing sync.Mutex
+}
+
+// orderedPath is the last event of a path checked by CheckOrdering.
+type orderedPath struct {
+	seq  uint64
+	seen time.Time
+}
+
+// sequence hands e to the worker owning its path, or dispatches it inline without workers. A rename goes to the
+// worker of its new path once the worker of its previous path got through the events queued before it.
+func (w *FileWatcher) sequence(e FileWatcherEvent) {
+	if len(w.dispatcher.queues) == 0 || w.prioritized(e) {
+		w.dispatch(e)
+		return
+	}
+
+	queue := w.dispatcher.queue(w.orderKey(e.Path))
+	task := dispatchTask{e: e}
+	if e.PreviousPath != "" {
+		if from := w.dispatcher.queue(w.orderKey(e.PreviousPath)); from != queue {
+			// with OrderedDelivery, the worker of the old directory waits for the rename to be dispatched, the events
+			// of the old directory following it can't overtake it
+			barrier := dispatchTask{done: make(chan struct{})}
+			if w.options.OrderedDelivery {
+				task.released = make(chan struct{})
+				barrier.hold = task.released
+			}
+			if !w.dispatcher.push(from, barrier) {
+				return
+			}
+			select {
+			case <-barrier.done:
+			case <-w.dispatcher.stop:
+				return
+			}
+		}
+	}
+	w.dispatcher.push(queue, task)
+}
+
+// checkOrder verifies e against the ordering guarantees with the CheckOrdering option. The events emitted outside of
+// the sequence, without a Seq, aren't checked.
+func (w *FileWatcher) checkOrder(e FileWatcherEvent) {
+	if !w.options.CheckOrdering || e.Seq == 0 {
+		return
+	}
+	var violations []*OrderingError
+	now := w.clock().Now()
+	w.ordering.mu.Lock()
+	if w.ordering.last == nil {
+		w.ordering.last = make(map[string]orderedPath)
+	}
+	if size := w.orderingEntries(); len(w.ordering.last) >= size {
+		ages := make([]stateAge, 0, len(w.ordering.last))
+		for path, last := range w.ordering.last {
+			ages = append(ages, stateAge{path: path, seen: last.seen})
+		}
+		for _, dropped := range oldestPaths(ages, evictionBatch(size)) {
+			delete(w.ordering.last, dropped)
+			atomic.AddUint64(&w.stateGC.orderingEvicted, 1)
+		}
+	}
+	for _, path := range []string{e.Path, e.PreviousPath} {
+		if path == "" {
+			continue
+		}
+		if last := w.ordering.last[path]; last.seq > e.Seq {
+			violations = append(violations, &OrderingError{Path: path, Event: e.Event, Seq: e.Seq, After: last.seq})
+			continue
+		}
+		w.ordering.last[path] = orderedPath{seq: e.Seq, seen: now}
+	}
+	w.ordering.mu.Unlock()
+
+	for _, err := range violations {
+		w.log.Error("Ordering violated: ", err)
+		w.sendError(err)
+	}
+}
+
+// orderKey returns what the workers are chosen by for the events of path: the path, or its directory with the
+// OrderedDelivery option.
+func (w *FileWatcher) orderKey(path string) string {
+	if w.options.OrderedDelivery {
+		return filepath.Dir(path)
+	}
+	return path
+}
+
+// inOrder runs deliver, which hands on events of path held back, in the order of the directory of path with the
+// OrderedDelivery option: not while an event of the directory goes through the dispatch.
+func (w *FileWatcher) inOrder(path string, deliver func()) {
+	if !w.options.OrderedDelivery {
+		deliver()
+		return
+	}
+	unlock := w.orderLocks.lock(filepath.Dir(path))
+	defer unlock()
+	deliver()
+}
+
+// lockOrder locks the directories of e for its dispatch with the OrderedDelivery option, and releases the events held
+// back for the other paths of the directories, which come first. It returns the function unlocking the directories.
+func (w *FileWatcher) lockOrder(e FileWatcherEvent) func() {
+	if !w.options.OrderedDelivery {
+		return func() {}
+	}
+	dirs := []string{filepath.Dir(e.Path)}
+	if e.PreviousPath != "" && filepath.Dir(e.PreviousPath) != dirs[0] {
+		dirs = append(dirs, filepath.Dir(e.PreviousPath))
+		// always in the same order, so two renames between the same directories don't wait for each other
+		sort.Strings(dirs)
+	}
+	unlocks := make([]func(), 0, len(dirs))
+	for _, dir := range dirs {
+		unlocks = append(unlocks, w.orderLocks.lock(dir))
+	}
+
+	held := func(path string) bool {
+		if path == e.Path {
+			return false
+		}
+		for _, dir := range dirs {
+			if filepath.Dir(path) == dir {
+				return true
+			}
+		}
+		return false
+	}
+	// the events released by a stage go through the next ones, which may hold them back in turn
+	for _, released := range w.takeStable(held) {
+		w.traceEvent(released, "released before %s %d, which comes next in its directory", e.Event, e.Seq)
+		w.dispatchStable(released)
+	}
+	for _, released := range w.takeRateLimitedIn(held) {
+		w.traceEvent(released, "released before %s %d, which comes next in its directory", e.Event, e.Seq)
+		w.dispatchLimited(released)
+	}
+	for _, released := range w.takeDebounced(held) {
+		w.traceEvent(released, "released before %s %d, which comes next in its directory", e.Event, e.Seq)
+		w.dispatchSettled(released)
+	}
+
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+// sortBySeq sorts events in the order they were emitted.
+func sortBySeq(events []FileWatcherEvent) []FileWatcherEvent {
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Seq < events[j].Seq
+	})
+	return events
+}
@@ -0,0 +1,62 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOrderedDelivery(t *testing.T) {
+	dir := t.TempDir()
+	other := filepath.Join(dir, "other")
+	if err := os.Mkdir(other, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the debouncing holds events back, without OrderedDelivery later events of their directory would overtake them
+	w := newTestWatcher(t, WithDispatchWorkers(4), WithDebounce(50*time.Millisecond), WithOrderedDelivery(),
+		WithOrderingChecks())
+	for _, path := range []string{dir, other} {
+		if err := w.Add(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const files = 40
+	go func() {
+		for i := 0; i < files; i++ {
+			path := filepath.Join(dir, fmt.Sprint("file", i))
+			_ = os.WriteFile(path, []byte("content"), 0644)
+			if i%4 == 0 {
+				_ = os.Rename(path, filepath.Join(other, fmt.Sprint("file", i)))
+			}
+		}
+	}()
+
+	// the last Seq delivered in each directory, a rename counting for both of its directories
+	last := make(map[string]uint64)
+	created := 0
+	timeout := time.After(testTimeout)
+	for created < files {
+		select {
+		case e := <-w.Events:
+			for _, path := range []string{e.Path, e.PreviousPath} {
+				if path == "" {
+					continue
+				}
+				if dir := filepath.Dir(path); last[dir] > e.Seq {
+					t.Fatalf("%s %d of %s delivered after event %d of its directory", e.Event, e.Seq, path, last[dir])
+				}
+				last[filepath.Dir(path)] = e.Seq
+			}
+			if e.Event.Is(EventCreateFile) {
+				created++
+			}
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("%d of the %d creates delivered", created, files)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package fileWatcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// serveEventsWriteTimeout is how long ServeEvents waits for a client to take an event before disconnecting it, so a
+// client not reading does not hold back the other consumers of the watcher.
+const serveEventsWriteTimeout = 10 * time.Second
+
+// EventFilter selects the events a client of ServeEvents receives. Clients send it as a line of JSON like
+// {"paths":["/srv/data"],"kinds":"CREATE_FILE|EDIT_FILE","since":42}.
+type EventFilter struct {
+	// Paths limits the events to these paths and everything below them. Empty means every watched path.
+	Paths []string `json:"paths,omitempty"`
+	// Kinds limits the events to these kinds, written as names joined by "|". Empty means every kind.
+	Kinds EventKind `json:"kinds,omitempty"`
+	// Since sends the events of the history after this sequence number first, for a client catching up after a
+	// reconnection, see EventsSince. Zero sends the whole history, nil none of it.
+	Since *uint64 `json:"since,omitempty"`
+}
+
+// wants reports whether e passes the filter.
+func (f EventFilter) wants(e FileWatcherEvent) bool {
+	if f.Kinds != 0 && !e.Event.Is(f.Kinds) {
+		return false
+	}
+	if len(f.Paths) == 0 {
+		return true
+	}
+	for _, path := range f.Paths {
+		if e.Path == path || isBelow(e.Path, path) ||
+			(e.PreviousPath != "" && (e.PreviousPath == path || isBelow(e.PreviousPath, path))) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeEvents streams the events to the clients connecting to listener, a Unix socket or a TCP listener, so other
+// processes consume them without embedding the watcher. Each event is a line of JSON in the wire schema, with the
+// ExportRedaction option applied.
+//
+// A client receives every event until it sends an EventFilter, and may send another one at any time to replace it. A
+// malformed filter is answered with a line like {"error":"..."} and the previous filter is kept. A client not taking
+// its events is disconnected.
+//
+// ServeEvents returns nil when listener is closed or once the watcher is closed, which also closes listener, and the
+// error of listener otherwise.
+func (w *FileWatcher) ServeEvents(listener net.Listener) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-w.life.stopping:
+			_ = listener.Close()
+		case <-done:
+		}
+	}()
+
+	clients := sync.WaitGroup{}
+	defer clients.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			w.serveEventClient(conn)
+		}()
+	}
+}
+
+// serveEventClient streams the events to a client of ServeEvents until it disconnects or the watcher is closed.
+func (w *FileWatcher) serveEventClient(conn net.Conn) {
+	w.labelGoroutine("serve-events")
+	defer conn.Close()
+	events, unsubscribe := w.subscribe("", true, SubscriptionOptions{})
+	defer unsubscribe()
+
+	// requests carries the filters sent by the client, or the error of a malformed one
+	type request struct {
+		filter EventFilter
+		err    error
+	}
+	requests := make(chan request)
+	disconnected := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		w.labelGoroutine("serve-events")
+		defer close(disconnected)
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			r := request{}
+			r.err = json.Unmarshal(scanner.Bytes(), &r.filter)
+			for i := range r.filter.Paths {
+				r.filter.Paths[i] = normalizePath(r.filter.Paths[i])
+			}
+			select {
+			case requests <- r:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	enc := json.NewEncoder(conn)
+	write := func(v interface{}) bool {
+		_ = conn.SetWriteDeadline(time.Now().Add(serveEventsWriteTimeout))
+		if err := enc.Encode(v); err != nil {
+			w.log.Debug("Disconnecting the event client ", conn.RemoteAddr(), ": ", err)
+			return false
+		}
+		return true
+	}
+
+	filter := EventFilter{}
+	// replayed is the last sequence number sent from the history, the live events up to it were sent already
+	replayed := uint64(0)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if (e.Seq != 0 && e.Seq <= replayed) || !filter.wants(e) {
+				continue
+			}
+			if !write(w.Export(e)) {
+				return
+			}
+		case r := <-requests:
+			if r.err != nil {
+				if !write(map[string]string{"error": r.err.Error()}) {
+					return
+				}
+				continue
+			}
+			filter = r.filter
+			if filter.Since == nil {
+				continue
+			}
+			for _, e := range w.EventsSince(*filter.Since) {
+				if e.Seq > replayed {
+					replayed = e.Seq
+				}
+				if filter.wants(e) && !write(w.Export(e)) {
+					return
+				}
+			}
+		case <-disconnected:
+			return
+		}
+	}
+}
@@ -0,0 +1,606 @@
+package fileWatcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/spf13/afero"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// sharedClientBuffer is how many events the daemon of a shared watcher holds for a client before disconnecting it,
+	// so a client not reading does not hold back the others.
+	sharedClientBuffer = 1024
+	// sharedRetryMax is the longest a client waits between its attempts to reach a daemon again.
+	sharedRetryMax = 2 * time.Second
+)
+
+// ErrSharedDisconnected is returned for the requests of a shared watcher that lost its daemon before they were
+// answered. The watch is added again once the daemon is reached again, see OpenShared.
+var ErrSharedDisconnected = errors.New("disconnected from the shared watcher")
+
+// ErrSharedClosed is returned by the methods of a closed SharedWatcher.
+var ErrSharedClosed = errors.New("the shared watcher is closed")
+
+// errSharedServed is returned by startSharedDaemon when another process serves the socket.
+var errSharedServed = errors.New("the shared watcher is served by another process")
+
+// sharedRequest is a line of JSON sent by a client to the daemon.
+type sharedRequest struct {
+	ID        uint64 `json:"id"`
+	Op        string `json:"op"`
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive,omitempty"`
+}
+
+// sharedMessage is a line of JSON sent by the daemon to a client: an event, the answer to a request, or an error of
+// the watcher when Reply is zero.
+type sharedMessage struct {
+	Event *WireEvent `json:"event,omitempty"`
+	Reply uint64     `json:"reply,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+type sharedWatch struct {
+	path      string
+	recursive bool
+}
+
+// SharedWatcher watches paths through a watcher shared by the processes of a host, see OpenShared. It is used like a
+// FileWatcher: the events of the paths it added are delivered on Events and the errors of the shared watcher on
+// Errors, both closed by Close.
+type SharedWatcher struct {
+	Events chan FileWatcherEvent
+	Errors chan error
+
+	socket string
+	log    Logger
+	opts   []Option
+
+	mu      sync.Mutex
+	conn    net.Conn
+	daemon  *sharedDaemon
+	watches map[sharedWatch]int
+	nextID  uint64
+	pending map[uint64]chan error
+	closed  chan struct{}
+	stopped chan struct{}
+}
+
+// OpenShared returns a watcher shared by the processes of the host through the Unix socket at socket. The first process
+// opening it becomes the daemon: it creates a FileWatcher with opts and serves it on the socket. The next processes
+// connect to it as clients and share its kernel watches, which matters on hosts where dozens of small tools would
+// otherwise each use up inotify watches for the same trees. The watches are counted across the processes: a path
+// stays watched until every process adding it removed it or went away.
+//
+// Only the user running the daemon can connect to the socket. The processes taking the socket over are serialized by
+// a lock file next to it, named after socket with .lock appended.
+//
+// When the process of the daemon closes its SharedWatcher or exits, one of the clients becomes the daemon and the
+// others connect to it again. The watches of every client are added again, and a RESYNC_REQUIRED event is delivered
+// for each of them since events may have been lost meanwhile.
+func OpenShared(socket string, l Logger, opts ...Option) (*SharedWatcher, error) {
+	if l == nil {
+		l = log
+	}
+	s := &SharedWatcher{
+		Events:  make(chan FileWatcherEvent),
+		Errors:  make(chan error, 64),
+		socket:  socket,
+		log:     l,
+		opts:    opts,
+		watches: make(map[sharedWatch]int),
+		pending: make(map[uint64]chan error),
+		closed:  make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	conn, daemon, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	s.conn, s.daemon = conn, daemon
+	go s.read(conn)
+	return s, nil
+}
+
+// connect reaches the daemon serving the socket, or becomes it when there is none.
+func (s *SharedWatcher) connect() (net.Conn, *sharedDaemon, error) {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		var conn net.Conn
+		if conn, err = net.Dial("unix", s.socket); err == nil {
+			return conn, nil, nil
+		}
+		var daemon *sharedDaemon
+		if daemon, err = startSharedDaemon(s.socket, s.log, s.opts); errors.Is(err, errSharedServed) {
+			// another process became the daemon first
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if conn, err = net.Dial("unix", s.socket); err != nil {
+			_ = daemon.close()
+			return nil, nil, err
+		}
+		s.log.Debug("Serving the shared watcher on ", s.socket)
+		return conn, daemon, nil
+	}
+	return nil, nil, err
+}
+
+// Daemon reports whether the process serves the shared watcher.
+func (s *SharedWatcher) Daemon() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.daemon != nil
+}
+
+// Add watches path for this process, see FileWatcher.Add.
+func (s *SharedWatcher) Add(path string) error {
+	return s.request("add", sharedWatch{path: normalizePath(path)})
+}
+
+// AddRecursive watches path and everything below it for this process, see FileWatcher.AddRecursive.
+func (s *SharedWatcher) AddRecursive(path string) error {
+	return s.request("add", sharedWatch{path: normalizePath(path), recursive: true})
+}
+
+// Remove stops watching path for this process, added with Add or AddRecursive. The shared watcher keeps watching it
+// while another process needs it.
+func (s *SharedWatcher) Remove(path string) error {
+	path = normalizePath(path)
+	s.mu.Lock()
+	watch := sharedWatch{path: path, recursive: s.watches[sharedWatch{path: path, recursive: true}] > 0}
+	s.mu.Unlock()
+	return s.request("remove", watch)
+}
+
+// request sends a request for watch to the daemon and waits for its answer.
+func (s *SharedWatcher) request(op string, watch sharedWatch) error {
+	answer := make(chan error, 1)
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		return ErrSharedClosed
+	default:
+	}
+	s.nextID++
+	id := s.nextID
+	s.pending[id] = answer
+	err := s.send(s.conn, sharedRequest{ID: id, Op: op, Path: watch.path, Recursive: watch.recursive})
+	if err != nil {
+		delete(s.pending, id)
+		s.mu.Unlock()
+		return ErrSharedDisconnected
+	}
+	s.mu.Unlock()
+
+	select {
+	case err = <-answer:
+	case <-s.stopped:
+		err = ErrSharedClosed
+	}
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if op == "add" {
+		s.watches[watch]++
+	} else if s.watches[watch]--; s.watches[watch] <= 0 {
+		delete(s.watches, watch)
+	}
+	return nil
+}
+
+// send writes r to conn. The caller holds the lock.
+func (s *SharedWatcher) send(conn net.Conn, r sharedRequest) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(append(line, '\n'))
+	return err
+}
+
+// read delivers the messages of the daemon until the watcher is closed, reaching a daemon again when the connection
+// is lost.
+func (s *SharedWatcher) read(conn net.Conn) {
+	defer close(s.stopped)
+	defer close(s.Events)
+	defer close(s.Errors)
+	for {
+		scanner := bufio.NewScanner(conn)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			m := sharedMessage{}
+			if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+				s.log.Warn("Malformed message of the shared watcher: ", err)
+				continue
+			}
+			if !s.deliver(m) {
+				return
+			}
+		}
+		if conn = s.reconnect(); conn == nil {
+			return
+		}
+	}
+}
+
+func (s *SharedWatcher) deliver(m sharedMessage) bool {
+	switch {
+	case m.Reply != 0:
+		s.mu.Lock()
+		answer, ok := s.pending[m.Reply]
+		delete(s.pending, m.Reply)
+		s.mu.Unlock()
+		if ok && m.Error != "" {
+			answer <- errors.New(m.Error)
+		} else if ok {
+			answer <- nil
+		}
+	case m.Event != nil:
+		select {
+		case s.Events <- m.Event.FileWatcherEvent():
+		case <-s.closed:
+			return false
+		}
+	case m.Error != "":
+		select {
+		case s.Errors <- errors.New(m.Error):
+		default:
+		}
+	}
+	return true
+}
+
+// reconnect reaches a daemon again after the connection was lost, adds the watches of the process again and delivers
+// RESYNC_REQUIRED for them. It returns nil once the watcher is closed.
+func (s *SharedWatcher) reconnect() net.Conn {
+	s.mu.Lock()
+	for id, answer := range s.pending {
+		answer <- ErrSharedDisconnected
+		delete(s.pending, id)
+	}
+	s.mu.Unlock()
+
+	delay := 10 * time.Millisecond
+	for {
+		select {
+		case <-s.closed:
+			return nil
+		default:
+		}
+		conn, daemon, err := s.connect()
+		if err == nil {
+			s.mu.Lock()
+			select {
+			case <-s.closed:
+				s.mu.Unlock()
+				_ = conn.Close()
+				if daemon != nil {
+					_ = daemon.close()
+				}
+				return nil
+			default:
+			}
+			s.conn, s.daemon = conn, daemon
+			watches := make([]sharedWatch, 0, len(s.watches))
+			for watch := range s.watches {
+				// the answers are not waited for, the ones of unknown requests are ignored
+				_ = s.send(conn, sharedRequest{Op: "add", Path: watch.path, Recursive: watch.recursive})
+				watches = append(watches, watch)
+			}
+			s.mu.Unlock()
+			s.log.Info("Reconnected to the shared watcher on ", s.socket)
+			for _, watch := range watches {
+				if !s.deliver(sharedMessage{Event: &WireEvent{Event: EventResyncRequired, Path: watch.path,
+					Time: time.Now()}}) {
+					return nil
+				}
+			}
+			return conn
+		}
+		s.log.Debug("Unable to reach the shared watcher: ", err)
+		select {
+		case <-time.After(delay):
+		case <-s.closed:
+			return nil
+		}
+		if delay *= 2; delay > sharedRetryMax {
+			delay = sharedRetryMax
+		}
+	}
+}
+
+// Close disconnects the process from the shared watcher, releasing its watches. When the process serves the shared
+// watcher, the watcher is closed and another process takes over.
+func (s *SharedWatcher) Close() error {
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		return nil
+	default:
+	}
+	close(s.closed)
+	conn, daemon := s.conn, s.daemon
+	s.daemon = nil
+	s.mu.Unlock()
+
+	_ = conn.Close()
+	<-s.stopped
+	if daemon != nil {
+		return daemon.close()
+	}
+	return nil
+}
+
+// sharedDaemon serves a FileWatcher to the clients of a shared watcher.
+type sharedDaemon struct {
+	w        *FileWatcher
+	socket   string
+	listener net.Listener
+	done     sync.WaitGroup
+
+	// watching guards refs, the watches counted across the clients, and the changes of the watches of the watcher.
+	// It is not held while delivering, the watcher emits events while adding a tree.
+	watching sync.Mutex
+	refs     map[sharedWatch]int
+
+	mu      sync.Mutex
+	clients map[*sharedConn]bool
+}
+
+// sharedConn is a client of the daemon. Its watches and filter are guarded by the lock of the daemon.
+type sharedConn struct {
+	conn    net.Conn
+	out     chan sharedMessage
+	watches map[sharedWatch]int
+	filter  EventFilter
+}
+
+// startSharedDaemon listens on socket and serves a new watcher on it, unless another process serves it already. A
+// socket file left behind by a daemon that exited is replaced. Only the user running the process can connect.
+func startSharedDaemon(socket string, l Logger, opts []Option) (*sharedDaemon, error) {
+	unlock, err := lockSharedSocket(socket)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	// the socket is only taken over once no daemon answers on it, by one process at a time
+	if conn, err := net.Dial("unix", socket); err == nil {
+		_ = conn.Close()
+		return nil, errSharedServed
+	}
+	listener, err := listenShared(socket)
+	if err != nil {
+		return nil, err
+	}
+	w, err := Init(nil, afero.NewOsFs(), l, opts...)
+	if err != nil {
+		_ = listener.Close()
+		_ = os.Remove(socket)
+		return nil, err
+	}
+
+	d := &sharedDaemon{w: w, socket: socket, listener: listener, refs: make(map[sharedWatch]int),
+		clients: make(map[*sharedConn]bool)}
+	d.done.Add(3)
+	go d.accept()
+	go d.forwardEvents()
+	go d.forwardErrors()
+	return d, nil
+}
+
+func (d *sharedDaemon) accept() {
+	defer d.done.Done()
+	d.w.labelGoroutine("shared")
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &sharedConn{conn: conn, out: make(chan sharedMessage, sharedClientBuffer),
+			watches: make(map[sharedWatch]int)}
+		d.mu.Lock()
+		d.clients[c] = true
+		d.mu.Unlock()
+		d.done.Add(2)
+		go d.serve(c)
+		go d.write(c)
+	}
+}
+
+// serve handles the requests of c until it disconnects, then releases its watches.
+func (d *sharedDaemon) serve(c *sharedConn) {
+	defer d.done.Done()
+	d.w.labelGoroutine("shared")
+	scanner := bufio.NewScanner(c.conn)
+	for scanner.Scan() {
+		r := sharedRequest{}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			d.w.log.Debug("Malformed request of a shared watcher client: ", err)
+			continue
+		}
+		watch := sharedWatch{path: r.Path, recursive: r.Recursive}
+		var err error
+		switch r.Op {
+		case "add":
+			err = d.add(c, watch)
+		case "remove":
+			err = d.remove(c, watch)
+		default:
+			err = errors.New("unknown request " + r.Op)
+		}
+		answer := sharedMessage{Reply: r.ID}
+		if err != nil {
+			answer.Error = err.Error()
+		}
+		if r.ID != 0 {
+			d.send(c, answer)
+		}
+	}
+
+	d.mu.Lock()
+	delete(d.clients, c)
+	close(c.out)
+	watches := make(map[sharedWatch]int, len(c.watches))
+	for watch, n := range c.watches {
+		watches[watch] = n
+	}
+	d.mu.Unlock()
+	for watch, n := range watches {
+		for ; n > 0; n-- {
+			_ = d.remove(c, watch)
+		}
+	}
+}
+
+// write sends its messages to c, and disconnects it when it fails to.
+func (d *sharedDaemon) write(c *sharedConn) {
+	defer d.done.Done()
+	d.w.labelGoroutine("shared")
+	enc := json.NewEncoder(c.conn)
+	for m := range c.out {
+		if err := enc.Encode(m); err != nil {
+			_ = c.conn.Close()
+			for range c.out {
+			}
+			return
+		}
+	}
+	_ = c.conn.Close()
+}
+
+// send queues m for c, disconnecting c when it does not keep up. The caller may hold the lock.
+func (d *sharedDaemon) send(c *sharedConn, m sharedMessage) {
+	select {
+	case c.out <- m:
+	default:
+		d.w.log.Warn("Disconnecting a shared watcher client not reading its events")
+		_ = c.conn.Close()
+	}
+}
+
+// add counts a watch of c, and watches its path when c is the first to need it.
+func (d *sharedDaemon) add(c *sharedConn, watch sharedWatch) error {
+	d.watching.Lock()
+	defer d.watching.Unlock()
+	if d.refs[watch] == 0 {
+		var err error
+		if watch.recursive {
+			err = d.w.AddRecursive(watch.path)
+		} else if d.refs[sharedWatch{path: watch.path, recursive: true}] == 0 {
+			err = d.w.Add(watch.path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	d.refs[watch]++
+	d.mu.Lock()
+	c.watches[watch]++
+	c.filter = EventFilter{Paths: sharedPaths(c.watches)}
+	d.mu.Unlock()
+	return nil
+}
+
+// remove releases a watch of c, and stops watching its path once no client needs it.
+func (d *sharedDaemon) remove(c *sharedConn, watch sharedWatch) error {
+	d.watching.Lock()
+	defer d.watching.Unlock()
+	d.mu.Lock()
+	if c.watches[watch] == 0 {
+		d.mu.Unlock()
+		return errors.New("not watched: " + watch.path)
+	}
+	if c.watches[watch]--; c.watches[watch] == 0 {
+		delete(c.watches, watch)
+	}
+	c.filter = EventFilter{Paths: sharedPaths(c.watches)}
+	d.mu.Unlock()
+	if d.refs[watch]--; d.refs[watch] > 0 {
+		return nil
+	}
+	delete(d.refs, watch)
+
+	plain := d.refs[sharedWatch{path: watch.path}] > 0
+	recursive := d.refs[sharedWatch{path: watch.path, recursive: true}] > 0
+	switch {
+	case watch.recursive && !recursive:
+		if err := d.w.RemoveRecursive(watch.path); err != nil {
+			return err
+		}
+		if plain {
+			return d.w.Add(watch.path)
+		}
+	case !watch.recursive && !plain && !recursive:
+		return d.w.Remove(watch.path)
+	}
+	return nil
+}
+
+func sharedPaths(watches map[sharedWatch]int) []string {
+	paths := make([]string, 0, len(watches))
+	for watch := range watches {
+		paths = append(paths, watch.path)
+	}
+	return paths
+}
+
+// forwardEvents hands the events of the watcher to the clients watching their paths.
+func (d *sharedDaemon) forwardEvents() {
+	defer d.done.Done()
+	d.w.labelGoroutine("shared")
+	for e := range d.w.Events {
+		wire := NewWireEvent(d.w.Export(e))
+		d.mu.Lock()
+		for c := range d.clients {
+			// a filter without paths wants every event, a client without watches none
+			if len(c.filter.Paths) > 0 && c.filter.wants(e) {
+				d.send(c, sharedMessage{Event: &wire})
+			}
+		}
+		d.mu.Unlock()
+	}
+}
+
+// forwardErrors hands the errors of the watcher to every client.
+func (d *sharedDaemon) forwardErrors() {
+	defer d.done.Done()
+	d.w.labelGoroutine("shared")
+	for err := range d.w.Errors {
+		d.mu.Lock()
+		for c := range d.clients {
+			d.send(c, sharedMessage{Error: err.Error()})
+		}
+		d.mu.Unlock()
+	}
+}
+
+// close stops serving the socket, disconnects the clients and closes the watcher.
+func (d *sharedDaemon) close() error {
+	// the socket file is removed before another process can take the socket over
+	if unlock, err := lockSharedSocket(d.socket); err == nil {
+		_ = d.listener.Close()
+		_ = os.Remove(d.socket)
+		unlock()
+	} else {
+		_ = d.listener.Close()
+	}
+	d.mu.Lock()
+	for c := range d.clients {
+		_ = c.conn.Close()
+	}
+	d.mu.Unlock()
+	err := d.w.Close()
+	d.done.Wait()
+	return err
+}
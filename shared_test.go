@@ -0,0 +1,140 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openShared(t *testing.T, socket string) *SharedWatcher {
+	t.Helper()
+	s, err := OpenShared(socket, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	return s
+}
+
+// waitShared returns the first event of s matching match, failing the test when none comes within testTimeout.
+func waitShared(t *testing.T, s *SharedWatcher, match func(FileWatcherEvent) bool) FileWatcherEvent {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case e, ok := <-s.Events:
+			if !ok {
+				t.Fatal("the shared watcher closed its events")
+			}
+			if match(e) {
+				return e
+			}
+		case <-timeout:
+			t.Fatal("no matching event within ", testTimeout)
+		}
+	}
+}
+
+func TestSharedWatcherClientsAndTakeOver(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "s.sock")
+	watched := filepath.Join(dir, "watched")
+	if err := os.Mkdir(watched, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	daemon := openShared(t, socket)
+	client := openShared(t, socket)
+	if !daemon.Daemon() || client.Daemon() {
+		t.Fatalf("daemon %v, client %v", daemon.Daemon(), client.Daemon())
+	}
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		t.Fatalf("the socket is open to other users: %v", perm)
+	}
+
+	if err := client.Add(watched); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(watched, "file")
+	writeFile(t, path, "")
+	waitShared(t, client, func(e FileWatcherEvent) bool { return e.Event == EventCreateFile && e.Path == path })
+	select {
+	case e := <-daemon.Events:
+		t.Fatalf("the daemon, which added nothing, got %s %s", e.Event, e.Path)
+	default:
+	}
+
+	if err := daemon.Close(); err != nil {
+		t.Fatal(err)
+	}
+	waitShared(t, client, func(e FileWatcherEvent) bool { return e.Event == EventResyncRequired && e.Path == watched })
+	if !client.Daemon() {
+		t.Fatal("the client did not take over")
+	}
+	// the watches are added again without waiting for the answers of the new daemon, the files are written until one is
+	// reported
+	timeout := time.After(testTimeout)
+	for i := 0; ; i++ {
+		writeFile(t, filepath.Join(watched, fmt.Sprint("second", i)), "")
+		select {
+		case e := <-client.Events:
+			if e.Event == EventCreateFile && filepath.Dir(e.Path) == watched {
+				return
+			}
+		case <-time.After(50 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("no event after the take over within ", testTimeout)
+		}
+	}
+}
+
+func TestSharedWatcherStaleSocketOneDaemon(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "s.sock")
+	// a socket file left behind by a daemon that exited
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.SetUnlinkOnClose(false)
+	_ = listener.Close()
+
+	const processes = 8
+	watchers := make([]*SharedWatcher, processes)
+	var wg sync.WaitGroup
+	for i := range watchers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s, err := OpenShared(socket, nopLogger{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			watchers[i] = s
+		}(i)
+	}
+	wg.Wait()
+	daemons := 0
+	for _, s := range watchers {
+		if s == nil {
+			continue
+		}
+		defer s.Close()
+		if s.Daemon() {
+			daemons++
+		}
+	}
+	if daemons != 1 {
+		t.Fatalf("%d processes serve the socket", daemons)
+	}
+}
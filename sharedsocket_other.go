@@ -0,0 +1,25 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+package fileWatcher
+
+import (
+	"net"
+	"os"
+)
+
+// lockSharedSocket does nothing, the processes taking a socket over at once are not told apart on this platform.
+func lockSharedSocket(string) (func(), error) {
+	return func() {}, nil
+}
+
+// listenShared listens on socket, replacing a socket file left behind by a daemon that exited. The access to the
+// socket is the one of its directory.
+func listenShared(socket string) (*net.UnixListener, error) {
+	_ = os.Remove(socket)
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	listener.SetUnlinkOnClose(false)
+	return listener, nil
+}
@@ -0,0 +1,53 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package fileWatcher
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockSharedSocket takes the lock of socket, held by a lock file next to it, so one process at a time checks whether
+// a daemon serves the socket and takes it over. It returns the function releasing the lock.
+func lockSharedSocket(socket string) (func(), error) {
+	f, err := os.OpenFile(socket+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		_ = f.Close()
+	}, nil
+}
+
+// listenShared listens on socket, which only the user running the process can connect to. The socket is bound in a
+// directory of its own only the user can enter, restricted to the user, then moved to socket, so no other user can
+// connect in between. A socket file left behind by a daemon that exited is replaced.
+func listenShared(socket string) (*net.UnixListener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(socket), ".filewatcher-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	bound := filepath.Join(dir, "socket")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: bound, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// the socket file is removed by the daemon, under the lock, see sharedDaemon.close
+	listener.SetUnlinkOnClose(false)
+	if err = os.Chmod(bound, 0600); err == nil {
+		err = os.Rename(bound, socket)
+	}
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
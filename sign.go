@@ -0,0 +1,290 @@
+package fileWatcher
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUnsignedEvent is returned by VerifyEvent for events without a signature.
+	ErrUnsignedEvent = errors.New("the event is not signed")
+	// ErrUnknownSigningKey is returned when an event is signed with a key the verifier doesn't have.
+	ErrUnknownSigningKey = errors.New("the event is signed with an unknown key")
+	// ErrInvalidSignature is returned when the signature of an event doesn't match its content.
+	ErrInvalidSignature = errors.New("invalid event signature")
+)
+
+// SigningAlgorithm is the algorithm of a SigningKey.
+type SigningAlgorithm string
+
+const (
+	// SigningHMACSHA256 authenticates the events with a secret shared between the watcher and the verifiers.
+	SigningHMACSHA256 SigningAlgorithm = "hmac-sha256"
+	// SigningEd25519 signs the events with a private key only the watcher host has, the verifiers only need the
+	// public key.
+	SigningEd25519 SigningAlgorithm = "ed25519"
+)
+
+// SignatureHeader is the HTTP header holding the signature of the body posted by a WebhookSink with a Signer, like
+// `kid="2024-06",alg="ed25519",sig="..."`.
+const SignatureHeader = "X-FileWatcher-Signature"
+
+// SigningKey is a key of an EventSigner, identified by ID so the verifiers know which key to check a signature with.
+type SigningKey struct {
+	ID        string
+	Algorithm SigningAlgorithm
+	// Secret is the key of SigningHMACSHA256.
+	Secret []byte
+	// PrivateKey is the key of SigningEd25519.
+	PrivateKey ed25519.PrivateKey
+}
+
+// HMACKey returns a SigningHMACSHA256 key.
+func HMACKey(id string, secret []byte) SigningKey {
+	return SigningKey{ID: id, Algorithm: SigningHMACSHA256, Secret: secret}
+}
+
+// Ed25519Key returns a SigningEd25519 key.
+func Ed25519Key(id string, key ed25519.PrivateKey) SigningKey {
+	return SigningKey{ID: id, Algorithm: SigningEd25519, PrivateKey: key}
+}
+
+// EventSignature is the signature of a serialized event, the sig field of the wire schema.
+type EventSignature struct {
+	KeyID     string           `json:"kid"`
+	Algorithm SigningAlgorithm `json:"alg"`
+	// Value is the signature encoded in standard base64.
+	Value string `json:"value"`
+}
+
+// EventSigner signs the events written by the journals and the network sinks it is set on, so the systems receiving
+// them can check they come from the watcher host and were not altered.
+type EventSigner struct {
+	mu  sync.RWMutex
+	key SigningKey
+}
+
+// NewEventSigner creates an EventSigner signing with key.
+func NewEventSigner(key SigningKey) (*EventSigner, error) {
+	if err := key.validate(); err != nil {
+		return nil, err
+	}
+	return &EventSigner{key: key}, nil
+}
+
+func (k SigningKey) validate() error {
+	if k.ID == "" {
+		return errors.New("the signing key has no ID")
+	}
+	switch k.Algorithm {
+	case SigningHMACSHA256:
+		if len(k.Secret) == 0 {
+			return errors.New("the HMAC signing key has no secret")
+		}
+	case SigningEd25519:
+		if len(k.PrivateKey) != ed25519.PrivateKeySize {
+			return fmt.Errorf("invalid Ed25519 private key of %d bytes, want %d", len(k.PrivateKey),
+				ed25519.PrivateKeySize)
+		}
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", k.Algorithm)
+	}
+	return nil
+}
+
+// Rotate signs with key from now on. The verifiers should have the new key before the rotation, and keep the old one
+// as long as events signed with it may still be verified, like the events of the journal not replayed yet.
+func (s *EventSigner) Rotate(key SigningKey) error {
+	if err := key.validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.key = key
+	return nil
+}
+
+// Sign signs payload with the current key.
+func (s *EventSigner) Sign(payload []byte) EventSignature {
+	s.mu.RLock()
+	key := s.key
+	s.mu.RUnlock()
+
+	var sig []byte
+	switch key.Algorithm {
+	case SigningHMACSHA256:
+		mac := hmac.New(sha256.New, key.Secret)
+		_, _ = mac.Write(payload)
+		sig = mac.Sum(nil)
+	case SigningEd25519:
+		sig = ed25519.Sign(key.PrivateKey, payload)
+	}
+	return EventSignature{KeyID: key.ID, Algorithm: key.Algorithm, Value: base64.StdEncoding.EncodeToString(sig)}
+}
+
+// header formats the signature of payload as the value of SignatureHeader.
+func (s *EventSigner) header(payload []byte) string {
+	sig := s.Sign(payload)
+	return `kid="` + sig.KeyID + `",alg="` + string(sig.Algorithm) + `",sig="` + sig.Value + `"`
+}
+
+// verificationKey is a key of an EventVerifier.
+type verificationKey struct {
+	algorithm SigningAlgorithm
+	secret    []byte
+	public    ed25519.PublicKey
+}
+
+// EventVerifier checks the signatures of events, with any of the keys it was given, so the keys can be rotated.
+type EventVerifier struct {
+	mu   sync.RWMutex
+	keys map[string]verificationKey
+}
+
+func NewEventVerifier() *EventVerifier {
+	return &EventVerifier{keys: make(map[string]verificationKey)}
+}
+
+// AddHMACKey accepts the signatures of the SigningHMACSHA256 key id. It returns an error for an empty secret.
+func (v *EventVerifier) AddHMACKey(id string, secret []byte) error {
+	if len(secret) == 0 {
+		return errors.New("the HMAC verification key has no secret")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys[id] = verificationKey{algorithm: SigningHMACSHA256, secret: secret}
+	return nil
+}
+
+// AddEd25519Key accepts the signatures of the SigningEd25519 key id, given its public key. It returns an error for a
+// key that is not ed25519.PublicKeySize long, which ed25519.Verify would panic on.
+func (v *EventVerifier) AddEd25519Key(id string, key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid Ed25519 public key of %d bytes, want %d", len(key), ed25519.PublicKeySize)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys[id] = verificationKey{algorithm: SigningEd25519, public: key}
+	return nil
+}
+
+// RemoveKey stops accepting the signatures of the key id, once it was rotated out.
+func (v *EventVerifier) RemoveKey(id string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.keys, id)
+}
+
+// Verify checks that sig is a signature of payload.
+func (v *EventVerifier) Verify(payload []byte, sig EventSignature) error {
+	v.mu.RLock()
+	key, ok := v.keys[sig.KeyID]
+	v.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownSigningKey, sig.KeyID)
+	}
+	if key.algorithm != sig.Algorithm {
+		return ErrInvalidSignature
+	}
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	switch key.algorithm {
+	case SigningHMACSHA256:
+		mac := hmac.New(sha256.New, key.secret)
+		_, _ = mac.Write(payload)
+		if !hmac.Equal(mac.Sum(nil), value) {
+			return ErrInvalidSignature
+		}
+	case SigningEd25519:
+		if !ed25519.Verify(key.public, payload, value) {
+			return ErrInvalidSignature
+		}
+	}
+	return nil
+}
+
+// VerifyHeader checks the value of SignatureHeader against the body it came with.
+func (v *EventVerifier) VerifyHeader(body []byte, header string) error {
+	sig := EventSignature{}
+	for _, field := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		value = strings.Trim(value, `"`)
+		switch name {
+		case "kid":
+			sig.KeyID = value
+		case "alg":
+			sig.Algorithm = SigningAlgorithm(value)
+		case "sig":
+			sig.Value = value
+		}
+	}
+	if sig.Value == "" {
+		return ErrUnsignedEvent
+	}
+	return v.Verify(body, sig)
+}
+
+// SignEvent serializes e in the wire schema with its signature in the sig field. The signed payload is the canonical
+// form of the serialized event without the sig field: its fields sorted by name, without whitespace, which other
+// languages can rebuild to verify the signature.
+func SignEvent(e FileWatcherEvent, signer *EventSigner) ([]byte, error) {
+	fields, err := eventFields(NewWireEvent(e))
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := json.Marshal(signer.Sign(payload))
+	if err != nil {
+		return nil, err
+	}
+	fields["sig"] = sig
+	return json.Marshal(fields)
+}
+
+// VerifyEvent checks the signature of an event serialized by SignEvent and decodes it.
+func VerifyEvent(data []byte, verifier *EventVerifier) (FileWatcherEvent, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return FileWatcherEvent{}, err
+	}
+	raw, ok := fields["sig"]
+	if !ok {
+		return FileWatcherEvent{}, ErrUnsignedEvent
+	}
+	sig := EventSignature{}
+	if err := json.Unmarshal(raw, &sig); err != nil {
+		return FileWatcherEvent{}, fmt.Errorf("malformed event signature: %w", err)
+	}
+	delete(fields, "sig")
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return FileWatcherEvent{}, err
+	}
+	if err := verifier.Verify(payload, sig); err != nil {
+		return FileWatcherEvent{}, err
+	}
+	return DecodeEvent(data)
+}
+
+// eventFields returns the fields of we by name.
+func eventFields(we WireEvent) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(we)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	return fields, json.Unmarshal(data, &fields)
+}
@@ -0,0 +1,195 @@
+package fileWatcher
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func signedEvent() FileWatcherEvent {
+	return FileWatcherEvent{Path: "/data/file", Event: EventEditFile, Time: time.Unix(1700000000, 0).UTC()}
+}
+
+func newEd25519Key(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return public, private
+}
+
+func TestSignAndVerifyEvent(t *testing.T) {
+	public, private := newEd25519Key(t)
+	verifier := NewEventVerifier()
+	if err := verifier.AddHMACKey("hmac", []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.AddEd25519Key("ed25519", public); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []SigningKey{HMACKey("hmac", []byte("secret")), Ed25519Key("ed25519", private)} {
+		t.Run(string(key.Algorithm), func(t *testing.T) {
+			signer, err := NewEventSigner(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := SignEvent(signedEvent(), signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, err := VerifyEvent(data, verifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := signedEvent(); e.Path != want.Path || e.Event != want.Event || !e.Time.Equal(want.Time) {
+				t.Fatalf("verified %+v, want %+v", e, want)
+			}
+
+			body := []byte(`{"path":"/data/file"}`)
+			if err := verifier.VerifyHeader(body, signer.header(body)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestVerifyTamperedEvent(t *testing.T) {
+	public, private := newEd25519Key(t)
+	verifier := NewEventVerifier()
+	if err := verifier.AddHMACKey("hmac", []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.AddEd25519Key("ed25519", public); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []SigningKey{HMACKey("hmac", []byte("secret")), Ed25519Key("ed25519", private)} {
+		t.Run(string(key.Algorithm), func(t *testing.T) {
+			signer, err := NewEventSigner(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := SignEvent(signedEvent(), signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tampered := bytes.Replace(data, []byte("/data/file"), []byte("/data/other"), 1)
+			if _, err := VerifyEvent(tampered, verifier); !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("verified a tampered event: %v", err)
+			}
+
+			fields := make(map[string]json.RawMessage)
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatal(err)
+			}
+			delete(fields, "sig")
+			unsigned, err := json.Marshal(fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := VerifyEvent(unsigned, verifier); !errors.Is(err, ErrUnsignedEvent) {
+				t.Fatalf("verified an unsigned event: %v", err)
+			}
+
+			body := []byte(`{"path":"/data/file"}`)
+			header := signer.header(body)
+			err = verifier.VerifyHeader([]byte(`{"path":"/data/other"}`), header)
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("verified a tampered body: %v", err)
+			}
+		})
+	}
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	oldPublic, oldPrivate := newEd25519Key(t)
+	newPublic, newPrivate := newEd25519Key(t)
+	verifier := NewEventVerifier()
+	if err := verifier.AddEd25519Key("2024-01", oldPublic); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewEventSigner(Ed25519Key("2024-01", oldPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := SignEvent(signedEvent(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the verifiers get the new key before the rotation
+	if err := verifier.AddEd25519Key("2024-06", newPublic); err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.Rotate(Ed25519Key("2024-06", newPrivate)); err != nil {
+		t.Fatal(err)
+	}
+	after, err := SignEvent(signedEvent(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{before, after} {
+		if _, err := VerifyEvent(data, verifier); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	verifier.RemoveKey("2024-01")
+	if _, err := VerifyEvent(before, verifier); !errors.Is(err, ErrUnknownSigningKey) {
+		t.Fatalf("verified an event signed with a removed key: %v", err)
+	}
+	if _, err := VerifyEvent(after, verifier); err != nil {
+		t.Fatal(err)
+	}
+
+	// a key signing with the ID of another key of the verifier
+	forged, err := NewEventSigner(HMACKey("2024-06", []byte("secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := SignEvent(signedEvent(), forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyEvent(data, verifier); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("verified an event signed with the wrong key: %v", err)
+	}
+}
+
+func TestInvalidSigningKeys(t *testing.T) {
+	public, private := newEd25519Key(t)
+	verifier := NewEventVerifier()
+	for _, key := range []ed25519.PublicKey{nil, public[:ed25519.PublicKeySize-1], append(public, 0)} {
+		if err := verifier.AddEd25519Key("ed25519", key); err == nil {
+			t.Fatalf("a public key of %d bytes was accepted", len(key))
+		}
+	}
+	if err := verifier.AddHMACKey("hmac", nil); err == nil {
+		t.Fatal("an empty HMAC secret was accepted")
+	}
+
+	signer, err := NewEventSigner(Ed25519Key("ed25519", private))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []SigningKey{
+		Ed25519Key("ed25519", nil),
+		Ed25519Key("ed25519", private[:ed25519.PrivateKeySize-1]),
+		Ed25519Key("ed25519", ed25519.PrivateKey(public)),
+		HMACKey("hmac", nil),
+		Ed25519Key("", private),
+		{ID: "unknown", Algorithm: "rsa", Secret: []byte("secret")},
+	} {
+		if _, err := NewEventSigner(key); err == nil {
+			t.Fatalf("the signing key %+v was accepted", key)
+		}
+		if err := signer.Rotate(key); err == nil {
+			t.Fatalf("the signer was rotated to the key %+v", key)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package fileWatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/afero"
+	"io"
+	"time"
+)
+
+// snapshotVersion is the version of the format written by SaveSnapshot.
+const snapshotVersion = 1
+
+// savedSnapshot is the state of the watched trees written by SaveSnapshot.
+type savedSnapshot struct {
+	Version int            `json:"v"`
+	Time    time.Time      `json:"time"`
+	Roots   []snapshotRoot `json:"roots"`
+	// Paths holds the entries of every watched path by name, the empty name for a watched file.
+	Paths map[string]map[string]snapshotEntry `json:"paths"`
+}
+
+type snapshotRoot struct {
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive,omitempty"`
+}
+
+type snapshotEntry struct {
+	Dir     bool      `json:"dir,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash,omitempty"`
+}
+
+// SaveSnapshot writes the watch set and the state of the watched trees to out as JSON: the size, modification time
+// and SHA-256 hash of every file. Every file is read, saving the snapshot of a large tree takes a while. A watcher
+// created from the snapshot by InitWithSnapshot reports what changed in the meantime, typically while the process
+// was down.
+func (w *FileWatcher) SaveSnapshot(out io.Writer) error {
+	snapshot := savedSnapshot{
+		Version: snapshotVersion,
+		Time:    time.Now(),
+		Paths:   make(map[string]map[string]snapshotEntry),
+	}
+	for _, root := range w.Roots() {
+		snapshot.Roots = append(snapshot.Roots, snapshotRoot{Path: root.Path, Recursive: root.Recursive})
+	}
+	for path, state := range w.snapshotWatched(true) {
+		entries := make(map[string]snapshotEntry, len(state))
+		for name, entry := range state {
+			entries[name] = snapshotEntry{Dir: entry.isDir, Size: entry.size, ModTime: entry.modTime, Hash: entry.hash}
+		}
+		snapshot.Paths[path] = entries
+	}
+	return json.NewEncoder(out).Encode(snapshot)
+}
+
+// InitWithSnapshot creates a watcher like Init and watches the roots of the snapshot written by SaveSnapshot again.
+// What changed since the snapshot was saved is reported with CREATE, EDIT and DELETE events, the net effect of the
+// changes like Resume, before the live events. A root gone meanwhile is reported as deleted. Every file is read to
+// compare its hash, so the catch-up of a large tree takes a while.
+func InitWithSnapshot(done chan bool, newFs afero.Fs, l Logger, snapshot io.Reader, opts ...Option) (*FileWatcher,
+	error) {
+	var saved savedSnapshot
+	if err := json.NewDecoder(snapshot).Decode(&saved); err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %w", err)
+	}
+	if saved.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", saved.Version)
+	}
+
+	w, err := Init(done, newFs, l, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	state := make(map[string]map[string]polledEntry, len(saved.Paths))
+	for path, entries := range saved.Paths {
+		state[path] = make(map[string]polledEntry, len(entries))
+		for name, entry := range entries {
+			state[path][name] = polledEntry{isDir: entry.Dir, size: entry.Size, modTime: entry.ModTime, hash: entry.Hash}
+		}
+	}
+	// the live events are held back until the catch-up, as if the watcher was paused when the snapshot was saved
+	w.pause.mu.Lock()
+	w.pause.paused = true
+	w.pause.snapshot = state
+	w.pause.hash = true
+	w.pause.mu.Unlock()
+
+	var gone []FileWatcherEvent
+	for _, root := range saved.Roots {
+		var err error
+		if root.Recursive {
+			err = w.AddRecursive(root.Path)
+		} else {
+			err = w.Add(root.Path)
+		}
+		if err == nil {
+			continue
+		}
+		w.log.Warn("Unable to watch ", root.Path, " again: ", err)
+		if _, statErr := w.fs.Stat(root.Path); statErr == nil {
+			continue
+		}
+		e := FileWatcherEvent{Path: root.Path, Event: EventDeleteFile}
+		if entry, ok := state[root.Path][""]; !ok || entry.isDir {
+			e.Event = EventDeleteFolder
+		}
+		gone = append(gone, e)
+	}
+
+	go func() {
+		w.labelGoroutine("snapshot-catch-up")
+		w.Resume()
+		for _, e := range gone {
+			w.traceEvent(e, "root gone since the snapshot")
+			w.emit(e)
+		}
+	}()
+	return w, nil
+}
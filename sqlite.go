@@ -0,0 +1,178 @@
+package fileWatcher
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sqliteSuffixes are the companion files SQLite writes next to the main database file.
+var sqliteSuffixes = []string{"-wal", "-shm", "-journal"}
+
+// Deprecated: use EventDBChanged.
+func (e FileWatcherEvent) DBChangedEvent() EventKind {
+	return EventDBChanged
+}
+
+// Deprecated: compare e.Event with EventDBChanged.
+func (e FileWatcherEvent) IsDBChangedEvent() bool {
+	return e.Event == EventDBChanged
+}
+
+type sqliteState struct {
+	mu sync.Mutex
+	// dbs holds the debounce timer of every watched database, keyed by the path of the main database file.
+	dbs map[string]Timer
+	// dirs counts the watched databases in every directory watched on their behalf.
+	dirs map[string]int
+}
+
+// AddSQLite watches the SQLite database at dbPath. Writes to the database or its -wal, -shm and -journal files are
+// debounced into a single DB_CHANGED event carrying the path of the main database file, so readers can reload their
+// models when another process commits. The containing directory is watched because SQLite creates and deletes the
+// companion files, but other files in it only produce events when they are watched through Add.
+func (w *FileWatcher) AddSQLite(dbPath string) error {
+	dbPath = w.normalize(dbPath)
+	dir := filepath.Dir(dbPath)
+
+	w.sqlite.mu.Lock()
+	defer w.sqlite.mu.Unlock()
+
+	if w.sqlite.dbs == nil {
+		w.sqlite.dbs = make(map[string]Timer)
+		w.sqlite.dirs = make(map[string]int)
+	}
+	if _, ok := w.sqlite.dbs[dbPath]; ok {
+		return nil
+	}
+
+	if w.sqlite.dirs[dir] == 0 {
+		if err := w.currentNotifier().Add(dir); err != nil {
+			return err
+		}
+	}
+	w.sqlite.dirs[dir]++
+	w.sqlite.dbs[dbPath] = nil
+	return nil
+}
+
+// RemoveSQLite stops watching the SQLite database at dbPath.
+func (w *FileWatcher) RemoveSQLite(dbPath string) error {
+	dbPath = w.normalize(dbPath)
+	dir := filepath.Dir(dbPath)
+
+	w.sqlite.mu.Lock()
+	defer w.sqlite.mu.Unlock()
+
+	timer, ok := w.sqlite.dbs[dbPath]
+	if !ok {
+		return nil
+	}
+	if timer != nil {
+		timer.Stop()
+	}
+	delete(w.sqlite.dbs, dbPath)
+
+	w.sqlite.dirs[dir]--
+	if w.sqlite.dirs[dir] > 0 {
+		return nil
+	}
+	delete(w.sqlite.dirs, dir)
+	if w.Contains(dir) {
+		// the directory is also watched on its own
+		return nil
+	}
+	return w.currentNotifier().Remove(dir)
+}
+
+// handleSQLite consumes raw events belonging to watched SQLite databases and reports whether the event was consumed.
+func (w *FileWatcher) handleSQLite(event fsnotify.Event) bool {
+	w.sqlite.mu.Lock()
+	defer w.sqlite.mu.Unlock()
+
+	if len(w.sqlite.dbs) == 0 {
+		return false
+	}
+
+	dbPath := w.sqliteDatabaseLocked(event.Name)
+	timer, ok := w.sqlite.dbs[dbPath]
+	if !ok {
+		// drop the events of unrelated files in directories only watched for a database
+		dir := filepath.Dir(event.Name)
+		return w.sqlite.dirs[dir] > 0 && !w.Contains(dir) && !w.Contains(event.Name)
+	}
+
+	if timer != nil {
+		timer.Reset(w.sqliteDebounce())
+		return true
+	}
+	w.sqlite.dbs[dbPath] = w.afterFunc(w.sqliteDebounce(), func() {
+		w.sqlite.mu.Lock()
+		if _, watched := w.sqlite.dbs[dbPath]; !watched {
+			w.sqlite.mu.Unlock()
+			return
+		}
+		w.sqlite.dbs[dbPath] = nil
+		w.sqlite.mu.Unlock()
+
+		e := FileWatcherEvent{Path: dbPath}
+		e.Event = EventDBChanged
+		w.emit(e)
+	})
+	return true
+}
+
+// flushSQLite emits the DB_CHANGED events still debounced right away.
+func (w *FileWatcher) flushSQLite() {
+	w.sqlite.mu.Lock()
+	var pending []string
+	for dbPath, timer := range w.sqlite.dbs {
+		if timer != nil && timer.Stop() {
+			pending = append(pending, dbPath)
+		}
+		w.sqlite.dbs[dbPath] = nil
+	}
+	w.sqlite.mu.Unlock()
+
+	for _, dbPath := range pending {
+		w.emit(FileWatcherEvent{Path: dbPath, Event: EventDBChanged})
+	}
+}
+
+// sqliteDatabaseLocked returns the main database file path belongs to, or path itself. The caller holds the lock.
+func (w *FileWatcher) sqliteDatabaseLocked(path string) string {
+	dbPath := filepath.Clean(path)
+	for _, suffix := range sqliteSuffixes {
+		if strings.HasSuffix(dbPath, suffix) {
+			if _, ok := w.sqlite.dbs[strings.TrimSuffix(dbPath, suffix)]; ok {
+				return strings.TrimSuffix(dbPath, suffix)
+			}
+			break
+		}
+	}
+	return dbPath
+}
+
+func (w *FileWatcher) sqliteDatabase(path string) string {
+	w.sqlite.mu.Lock()
+	defer w.sqlite.mu.Unlock()
+	return w.sqliteDatabaseLocked(path)
+}
+
+// explainSQLite reports whether the events of path are consumed by the SQLite watcher.
+func (w *FileWatcher) explainSQLite(path string) bool {
+	w.sqlite.mu.Lock()
+	defer w.sqlite.mu.Unlock()
+	_, ok := w.sqlite.dbs[w.sqliteDatabaseLocked(path)]
+	return ok
+}
+
+func (w *FileWatcher) sqliteDebounce() time.Duration {
+	if w.options.SQLiteDebounce > 0 {
+		return w.options.SQLiteDebounce
+	}
+	// a transaction touches the wal and shm files several times, wait for it to settle
+	return 100 * time.Millisecond
+}
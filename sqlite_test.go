@@ -0,0 +1,87 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// noEvent fails the test when w delivers an event within wait.
+func noEvent(t *testing.T, w *FileWatcher, wait time.Duration) {
+	t.Helper()
+	select {
+	case e := <-w.Events:
+		t.Fatalf("unexpected %s %s", e.Event, e.Path)
+	case <-time.After(wait):
+	}
+}
+
+func TestSQLiteTransactionIsOneEvent(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "app.db")
+	writeFile(t, db, "")
+	w := newTestWatcher(t, WithSQLiteDebounce(100*time.Millisecond))
+	if err := w.AddSQLite(db); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, suffix := range []string{"-journal", "", "-wal", "-shm", "-wal"} {
+		writeFile(t, db+suffix, "commit")
+	}
+	// a file of the directory not watched on its own
+	writeFile(t, filepath.Join(dir, "notes.txt"), "")
+
+	e := waitEvent(t, w, func(FileWatcherEvent) bool { return true })
+	if e.Event != EventDBChanged || e.Path != db {
+		t.Fatalf("got %s %s, want DB_CHANGED %s", e.Event, e.Path, db)
+	}
+	noEvent(t, w, 300*time.Millisecond)
+}
+
+func TestRemoveSQLite(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "app.db")
+	writeFile(t, db, "")
+	w := newTestWatcher(t, WithSQLiteDebounce(20*time.Millisecond))
+	if err := w.AddSQLite(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.RemoveSQLite(db); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, db+"-wal", "commit")
+	noEvent(t, w, 200*time.Millisecond)
+}
+
+func TestSQLiteFlushedOnClose(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "app.db")
+	writeFile(t, db, "")
+	w, err := Init(nil, afero.NewOsFs(), nopLogger{}, WithSQLiteDebounce(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddSQLite(db); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, db+"-wal", "commit")
+	// the event loop has to see the write before the close
+	time.Sleep(100 * time.Millisecond)
+
+	events := make(chan FileWatcherEvent, 8)
+	go func() {
+		for e := range w.Events {
+			events <- e
+		}
+		close(events)
+	}()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	e, ok := <-events
+	if !ok || e.Event != EventDBChanged || e.Path != db {
+		t.Fatalf("got %s %s on close, want DB_CHANGED %s", e.Event, e.Path, db)
+	}
+}
@@ -0,0 +1,175 @@
+package fileWatcher
+
+import (
+	"sync"
+	"time"
+)
+
+// StabilityOptions configures the wait for new files to be complete, for large copies reported as soon as they start.
+// A file is complete once its size and modification time stopped changing.
+type StabilityOptions struct {
+	// Quiet is how long the size and the modification time of a new file have to stay the same for the file to be
+	// complete. Zero disables the wait.
+	Quiet time.Duration
+	// Ready delivers CREATE_FILE right away and a FILE_READY event once the file is complete, instead of holding
+	// CREATE_FILE back until then.
+	Ready bool
+	// Timeout is how long a file is waited for at most, after which it is reported as it is. Zero waits for as long
+	// as the file changes.
+	Timeout time.Duration
+}
+
+type stabilityState struct {
+	mu sync.Mutex
+	// waiting maps the new files being waited for to their create event.
+	waiting map[string]*stabilityWait
+}
+
+type stabilityWait struct {
+	event FileWatcherEvent
+}
+
+// checkStable applies the stability wait to e. It reports false when e has been held back and will be handed to
+// deliver later, or when it is part of the creation of a file being waited for.
+func (w *FileWatcher) checkStable(e *FileWatcherEvent, deliver func(FileWatcherEvent)) bool {
+	if w.options.Stability.Quiet <= 0 {
+		return true
+	}
+	if e.Event != EventCreateFile {
+		return w.settleWaiting(*e, deliver)
+	}
+
+	wait := &stabilityWait{event: *e}
+	w.stability.mu.Lock()
+	if w.stability.waiting == nil {
+		w.stability.waiting = make(map[string]*stabilityWait)
+	}
+	w.stability.waiting[e.Path] = wait
+	w.stability.mu.Unlock()
+
+	go w.waitStable(wait, deliver)
+	if w.options.Stability.Ready {
+		return true
+	}
+	w.traceEvent(*e, "held back until the file stops changing")
+	return false
+}
+
+// settleWaiting ends the wait for the file e is about. Edits of a file whose create is held back are part of its
+// creation and dropped, the other events release the create first so the events stay in order.
+func (w *FileWatcher) settleWaiting(e FileWatcherEvent, deliver func(FileWatcherEvent)) bool {
+	path := e.Path
+	if isRename(e) {
+		path = e.PreviousPath
+	}
+	growing := e.Event.Is(EventEditFile | EventChMod)
+	w.stability.mu.Lock()
+	wait, ok := w.stability.waiting[path]
+	if ok && !growing {
+		delete(w.stability.waiting, path)
+	}
+	w.stability.mu.Unlock()
+
+	switch {
+	case !ok || (growing && w.options.Stability.Ready):
+		return true
+	case growing:
+		w.traceEvent(e, "dropped, part of the creation of the file")
+		return false
+	case w.options.Stability.Ready:
+		w.traceEvent(e, "ends the wait for the file to stop changing, no FILE_READY follows")
+		return true
+	default:
+		w.traceEvent(wait.event, "released by %s before the file stopped changing", e.Event)
+		deliver(wait.event)
+		return true
+	}
+}
+
+// claimStable ends the wait and reports whether it was still waited for.
+func (w *FileWatcher) claimStable(wait *stabilityWait) bool {
+	w.stability.mu.Lock()
+	defer w.stability.mu.Unlock()
+	if w.stability.waiting[wait.event.Path] != wait {
+		return false
+	}
+	delete(w.stability.waiting, wait.event.Path)
+	return true
+}
+
+// waitStable polls the file of wait until it is complete, then delivers its create or emits FILE_READY.
+func (w *FileWatcher) waitStable(wait *stabilityWait, deliver func(FileWatcherEvent)) {
+	w.labelGoroutine("stability-wait")
+	options := w.options.Stability
+	interval := options.Quiet / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	path := wait.event.Path
+	start := time.Now()
+	changed := start
+	var size int64
+	var modTime time.Time
+	for stable := false; !stable; {
+		select {
+		case <-ticker.C:
+		case <-w.life.stopping:
+			stable = true
+			continue
+		}
+		w.stability.mu.Lock()
+		current := w.stability.waiting[path] == wait
+		w.stability.mu.Unlock()
+		if !current {
+			return
+		}
+
+		info, err := w.fs.Stat(path)
+		switch {
+		case err != nil:
+			// gone, the delete follows
+			stable = true
+		case info.Size() != size || !info.ModTime().Equal(modTime):
+			size, modTime, changed = info.Size(), info.ModTime(), time.Now()
+		case time.Since(changed) >= options.Quiet:
+			stable = true
+		}
+		if options.Timeout > 0 && time.Since(start) >= options.Timeout {
+			w.log.Warn("File " + path + " is still changing after " + options.Timeout.String())
+			stable = true
+		}
+	}
+
+	if options.Ready {
+		if w.claimStable(wait) {
+			w.emit(FileWatcherEvent{Path: path, Event: EventFileReady})
+		}
+		return
+	}
+	w.inOrder(path, func() {
+		if w.claimStable(wait) {
+			w.traceEvent(wait.event, "released, the file stopped changing")
+			deliver(wait.event)
+		}
+	})
+}
+
+// takeStable ends the waits of the paths held reports true for, and returns their creates held back.
+func (w *FileWatcher) takeStable(held func(string) bool) []FileWatcherEvent {
+	if w.options.Stability.Quiet <= 0 || w.options.Stability.Ready {
+		return nil
+	}
+	w.stability.mu.Lock()
+	defer w.stability.mu.Unlock()
+	var res []FileWatcherEvent
+	for path, wait := range w.stability.waiting {
+		if held(path) {
+			delete(w.stability.waiting, path)
+			res = append(res, wait.event)
+		}
+	}
+	return sortBySeq(res)
+}
@@ -0,0 +1,105 @@
+package fileWatcher
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultStatCacheTTL  = time.Second
+	defaultStatCacheSize = 4096
+)
+
+type statEntry struct {
+	info    os.FileInfo
+	err     error
+	expires time.Time
+}
+
+// statCache remembers stat results so bursts of events for the same paths don't stat them over and over. Entries
+// are invalidated by every event touching their path and expire after a short time to bound staleness for paths the
+// watcher gets no events for.
+type statCache struct {
+	mu      sync.Mutex
+	entries map[string]statEntry
+}
+
+// Stat returns the file info of path, from the stat cache when it holds a fresh result.
+func (w *FileWatcher) Stat(path string) (os.FileInfo, error) {
+	path = w.normalize(path)
+	now := time.Now()
+
+	w.statCache.mu.Lock()
+	entry, ok := w.statCache.entries[path]
+	w.statCache.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.info, entry.err
+	}
+
+	info, err := w.fs.Stat(path)
+	w.cacheStat(path, info, err, now)
+	return info, err
+}
+
+// cacheStat records the result of the stat of path made at now, unless the cache is disabled.
+func (w *FileWatcher) cacheStat(path string, info os.FileInfo, err error, now time.Time) {
+	ttl := w.options.StatCacheTTL
+	if ttl == 0 {
+		ttl = defaultStatCacheTTL
+	}
+	if ttl < 0 {
+		return
+	}
+
+	w.statCache.mu.Lock()
+	defer w.statCache.mu.Unlock()
+	if w.statCache.entries == nil {
+		w.statCache.entries = make(map[string]statEntry)
+	}
+	size := w.options.StatCacheSize
+	if size <= 0 {
+		size = defaultStatCacheSize
+	}
+	if len(w.statCache.entries) >= size {
+		atomic.AddUint64(&w.stateGC.statCacheEvicted, uint64(w.statCache.evict(now, size)))
+	}
+	w.statCache.entries[path] = statEntry{info: info, err: err, expires: now.Add(ttl)}
+}
+
+// evict drops expired entries, and the ones expiring first when that isn't enough to make room for size entries,
+// and returns how many it dropped. A negative size only drops the expired entries.
+func (c *statCache) evict(now time.Time, size int) int {
+	evicted := 0
+	for path, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, path)
+			evicted++
+		}
+	}
+	if size < 0 || len(c.entries) < size {
+		return evicted
+	}
+	ages := make([]stateAge, 0, len(c.entries))
+	for path, entry := range c.entries {
+		ages = append(ages, stateAge{path: path, seen: entry.expires})
+	}
+	for _, path := range oldestPaths(ages, len(c.entries)-size+evictionBatch(size)) {
+		delete(c.entries, path)
+		evicted++
+	}
+	return evicted
+}
+
+// invalidate drops the cached results of paths.
+func (c *statCache) invalidate(paths ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, path := range paths {
+		if path != "" {
+			delete(c.entries, filepath.Clean(path))
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package fileWatcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNoEventInfo is returned by FileWatcherEvent.Stat for the events without file information: deletes, events of
+// paths with StatNever, and events emitted while the watcher shed load.
+var ErrNoEventInfo = errors.New("the event has no file information")
+
+// StatPolicy is when the Info of the events of a watched path is filled in, trading the latency of the events for the
+// accuracy of their information.
+type StatPolicy int
+
+const (
+	// StatEager stats the path in the event loop before the event is delivered, so Info is the state of the path when
+	// the event was emitted. It is the default.
+	StatEager StatPolicy = iota
+	// StatLazy delivers the event right away and stats the path the first time the consumer calls Stat on the event,
+	// so Info may describe a later state of the path. Events never looked at cost no stat.
+	StatLazy
+	// StatNever leaves Info empty.
+	StatNever
+)
+
+func (p StatPolicy) String() string {
+	switch p {
+	case StatEager:
+		return "eager"
+	case StatLazy:
+		return "lazy"
+	case StatNever:
+		return "never"
+	}
+	return "unknown"
+}
+
+type statPolicyState struct {
+	mu    sync.RWMutex
+	paths map[string]StatPolicy
+}
+
+// lazyInfo is the file information of an event with StatLazy, shared by the copies of the event.
+type lazyInfo struct {
+	w    *FileWatcher
+	path string
+	once sync.Once
+	info os.FileInfo
+	err  error
+}
+
+// Stat returns the file information of the event: Info when it is set, the state of the path the first time it is
+// called on an event with StatLazy, and ErrNoEventInfo otherwise.
+func (e FileWatcherEvent) Stat() (os.FileInfo, error) {
+	if e.Info != nil {
+		return e.Info, nil
+	}
+	if e.lazy == nil {
+		return nil, ErrNoEventInfo
+	}
+	e.lazy.once.Do(func() {
+		e.lazy.info, e.lazy.err = e.lazy.w.Stat(e.lazy.path)
+	})
+	return e.lazy.info, e.lazy.err
+}
+
+// AddWithStatPolicy adds path like Add, with policy for the events of path and of everything below it, instead of the
+// StatPolicy option. The policy of the closest path added with a policy applies.
+func (w *FileWatcher) AddWithStatPolicy(path string, policy StatPolicy) error {
+	path = w.normalize(path)
+	if err := w.Add(path); err != nil {
+		return err
+	}
+	w.statPolicy.mu.Lock()
+	defer w.statPolicy.mu.Unlock()
+	if w.statPolicy.paths == nil {
+		w.statPolicy.paths = make(map[string]StatPolicy)
+	}
+	w.statPolicy.paths[path] = policy
+	return nil
+}
+
+// forgetStatPolicy drops the policy of path, once it is removed.
+func (w *FileWatcher) forgetStatPolicy(path string) {
+	w.statPolicy.mu.Lock()
+	defer w.statPolicy.mu.Unlock()
+	delete(w.statPolicy.paths, path)
+}
+
+// statPolicyOf returns the policy of the events of path.
+func (w *FileWatcher) statPolicyOf(path string) StatPolicy {
+	w.statPolicy.mu.RLock()
+	defer w.statPolicy.mu.RUnlock()
+	if len(w.statPolicy.paths) > 0 {
+		for current := path; ; {
+			if policy, ok := w.statPolicy.paths[current]; ok {
+				return policy
+			}
+			parent := filepath.Dir(current)
+			if parent == current {
+				break
+			}
+			current = parent
+		}
+	}
+	if w.options.DisableEventInfo {
+		return StatNever
+	}
+	return w.options.StatPolicy
+}
+
+// fillInfo sets the file information of e according to the policy of its path.
+func (w *FileWatcher) fillInfo(e *FileWatcherEvent) {
+	if e.Info != nil || isDelete(*e) {
+		return
+	}
+	switch w.statPolicyOf(e.Path) {
+	case StatEager:
+		if w.Shedding() {
+			return
+		}
+		// the classification stat of the path is still cached
+		if info, err := w.Stat(e.Path); err == nil {
+			e.Info = info
+		}
+	case StatLazy:
+		e.lazy = &lazyInfo{w: w, path: e.Path}
+	}
+}
@@ -0,0 +1,312 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SubscriptionOptions configures a subscription, see SubscribeWithOptions.
+type SubscriptionOptions struct {
+	// TTL bounds how stale the received events are, for real-time views: an event not received within TTL of being
+	// emitted is dropped and counted in EventStats.Expired. The channel is unbuffered then, so events don't age in it.
+	// Zero means no limit.
+	TTL time.Duration
+	// CatchUp sends a CATCH_UP event once events expired, before the next event received in time. Its path is the
+	// deepest directory holding every path of the expired events, what the subscriber has to rescan to catch up.
+	CatchUp bool
+	// Backfill is what the subscriber receives before the live events, followed by a BACKFILL_COMPLETE event. The
+	// backfilled events are not subject to the TTL.
+	Backfill Backfill
+	// BackfillSince is the time the BackfillHistory events start at, the zero time for the whole history.
+	BackfillSince time.Time
+	// Kinds limits the subscription to these kinds of events, like EventCreateFile | EventEditFile. The other events
+	// are dropped before they are queued, so they never wake the subscriber. Zero means every kind.
+	Kinds EventKind
+	// WatchChanges sends a WATCH_ADDED event when a path at or above the subscribed path starts being watched, and a
+	// WATCH_REMOVED event when it stops, so a subscriber to a path no watch covers yet learns when its events start
+	// flowing. Path is the watched path. The events of the watches added later are received either way.
+	WatchChanges bool
+	// Matcher limits the subscription to the events of the paths it matches, the events of a rename when it matches
+	// one of both paths. Nil means every path.
+	Matcher Matcher
+}
+
+type subscription struct {
+	w    *FileWatcher
+	path string
+	// all subscribes to every path instead of path.
+	all     bool
+	options SubscriptionOptions
+	events  chan FileWatcherEvent
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	// missed is the path of the pending CATCH_UP event, empty when no event expired.
+	missed   string
+	missedMu sync.Mutex
+	backfill backfillState
+}
+
+type subscriberState struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]*subscription
+	// unsubscribes end the subscriptions, once the watcher is closed.
+	unsubscribes map[int]func()
+	closed       bool
+}
+
+// Subscribe returns a channel receiving the events of path and of everything below it, and a function ending the
+// subscription and closing the channel. Path does not have to be watched: the events of the watches other components
+// add under it later are received too, see SubscriptionOptions.WatchChanges. The channel is also closed when the
+// watcher is closed. Renames are received by the subscriptions of both paths.
+//
+// The events are handed to the subscriptions as they are delivered, before they are sent on Events, which the
+// application still reads as before. A subscriber not keeping up holds back the delivery of the events like a consumer
+// of Events not keeping up, see SubscriptionOptions.TTL to bound it. An application consuming the events through
+// subscriptions alone, not reading Events, gives Events a buffer dropping the events, see WithEventBuffer, otherwise
+// the delivery waits for a reader of Events.
+func (w *FileWatcher) Subscribe(path string) (<-chan FileWatcherEvent, func()) {
+	return w.SubscribeWithOptions(path, SubscriptionOptions{})
+}
+
+// SubscribeWithOptions subscribes to path like Subscribe, with the given options.
+func (w *FileWatcher) SubscribeWithOptions(path string, options SubscriptionOptions) (<-chan FileWatcherEvent, func()) {
+	return w.subscribe(w.normalize(path), false, options)
+}
+
+// EventsFiltered returns a channel receiving the events of the given kinds about every watched path, like
+// EventsFiltered(EventCreateFile, EventEditFile). The other events are filtered out before they are queued, so a slow
+// consumer is only woken for the events it cares about. The channel is closed when the watcher is closed. Like
+// Subscribe, it leaves Events as it is.
+func (w *FileWatcher) EventsFiltered(kinds ...EventKind) <-chan FileWatcherEvent {
+	set := EventKind(0)
+	for _, kind := range kinds {
+		set |= kind
+	}
+	events, _ := w.subscribe("", true, SubscriptionOptions{Kinds: set})
+	return events
+}
+
+func (w *FileWatcher) subscribe(path string, all bool, options SubscriptionOptions) (<-chan FileWatcherEvent, func()) {
+	size := 64
+	if options.TTL > 0 {
+		size = 0
+	}
+	s := &subscription{
+		w:       w,
+		path:    path,
+		all:     all,
+		options: options,
+		events:  make(chan FileWatcherEvent, size),
+		done:    make(chan struct{}),
+	}
+	s.backfill.running = options.Backfill != BackfillNone
+
+	w.subscribers.mu.Lock()
+	id := w.subscribers.nextID
+	w.subscribers.nextID++
+	once := sync.Once{}
+	unsubscribe := func() {
+		once.Do(func() {
+			// unblock a delivery waiting on a full channel before taking the lock
+			close(s.done)
+			w.subscribers.mu.Lock()
+			delete(w.subscribers.subs, id)
+			delete(w.subscribers.unsubscribes, id)
+			w.subscribers.mu.Unlock()
+			s.mu.Lock()
+			s.closed = true
+			close(s.events)
+			s.mu.Unlock()
+		})
+	}
+	closed := w.subscribers.closed
+	if !closed {
+		if w.subscribers.subs == nil {
+			w.subscribers.subs = make(map[int]*subscription)
+			w.subscribers.unsubscribes = make(map[int]func())
+		}
+		w.subscribers.subs[id] = s
+		w.subscribers.unsubscribes[id] = unsubscribe
+	}
+	w.subscribers.mu.Unlock()
+
+	if closed {
+		unsubscribe()
+		return s.events, unsubscribe
+	}
+	if s.backfill.running {
+		go s.runBackfill()
+	}
+	return s.events, unsubscribe
+}
+
+// subscriptions returns the current subscriptions.
+func (w *FileWatcher) subscriptions() []*subscription {
+	w.subscribers.mu.RLock()
+	defer w.subscribers.mu.RUnlock()
+	subs := make([]*subscription, 0, len(w.subscribers.subs))
+	for _, s := range w.subscribers.subs {
+		subs = append(subs, s)
+	}
+	return subs
+}
+
+// fanOut hands e to the subscriptions wanting it.
+func (w *FileWatcher) fanOut(e FileWatcherEvent) {
+	for _, s := range w.subscriptions() {
+		s.send(e)
+	}
+}
+
+// closeSubscriptions ends the subscriptions once the watcher is closed, and the ones made later right away.
+func (w *FileWatcher) closeSubscriptions() {
+	w.subscribers.mu.Lock()
+	w.subscribers.closed = true
+	unsubscribes := make([]func(), 0, len(w.subscribers.unsubscribes))
+	for _, unsubscribe := range w.subscribers.unsubscribes {
+		unsubscribes = append(unsubscribes, unsubscribe)
+	}
+	w.subscribers.mu.Unlock()
+	for _, unsubscribe := range unsubscribes {
+		unsubscribe()
+	}
+}
+
+func (s *subscription) send(e FileWatcherEvent) {
+	if !s.wants(e) {
+		return
+	}
+	if s.queue(e) {
+		return
+	}
+	s.forward(e)
+}
+
+// announceWatch tells the subscriptions with WatchChanges whose path is at or below path that path started or
+// stopped being watched, kind being EventWatchAdded or EventWatchRemoved.
+func (w *FileWatcher) announceWatch(path string, kind EventKind) {
+	e := FileWatcherEvent{Path: path, Event: kind, Time: w.clock().Now()}
+	for _, s := range w.subscriptions() {
+		if !s.options.WatchChanges || s.options.Kinds != 0 && !kind.Is(s.options.Kinds) {
+			continue
+		}
+		if s.covers(path) || s.path == path || isBelow(s.path, path) {
+			if !s.queue(e) {
+				s.forward(e)
+			}
+		}
+	}
+}
+
+// forward hands e to the subscriber. With a TTL, e is dropped once it expired.
+func (s *subscription) forward(e FileWatcherEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	if s.options.TTL <= 0 {
+		select {
+		case s.events <- e:
+		case <-s.done:
+		case <-s.w.life.abandon:
+			s.w.countDropped(e, "not received within the close timeout")
+		}
+		return
+	}
+
+	emitted := e.Time
+	if emitted.IsZero() {
+		emitted = time.Now()
+	}
+	expiry := time.NewTimer(time.Until(emitted.Add(s.options.TTL)))
+	defer expiry.Stop()
+	if path := s.takeMissed(); path != "" {
+		catchUp := FileWatcherEvent{Path: path, Event: EventCatchUp, Time: time.Now()}
+		if !s.deliver(catchUp, expiry.C) {
+			s.miss(path)
+			s.expire(e)
+			return
+		}
+	}
+	if !s.deliver(e, expiry.C) {
+		s.expire(e)
+	}
+}
+
+// deliver sends e to the subscriber unless expired fires first, and reports whether it did.
+func (s *subscription) deliver(e FileWatcherEvent, expired <-chan time.Time) bool {
+	select {
+	case <-expired:
+		return false
+	default:
+	}
+	select {
+	case s.events <- e:
+		return true
+	case <-expired:
+		return false
+	case <-s.done:
+		return true
+	case <-s.w.life.abandon:
+		s.w.countDropped(e, "not received within the close timeout")
+		return true
+	}
+}
+
+// expire accounts for e, which was not received in time.
+func (s *subscription) expire(e FileWatcherEvent) {
+	atomic.AddUint64(&s.w.counters.expired, 1)
+	s.w.traceEvent(e, "expired before the subscriber of %s received it", s.path)
+	if !s.options.CatchUp {
+		return
+	}
+	s.miss(e.Path)
+	if s.covers(e.PreviousPath) {
+		s.miss(e.PreviousPath)
+	}
+}
+
+// miss widens the pending CATCH_UP event to path.
+func (s *subscription) miss(path string) {
+	if !s.covers(path) {
+		path = s.path
+	}
+	s.missedMu.Lock()
+	defer s.missedMu.Unlock()
+	if s.missed == "" {
+		s.missed = path
+		return
+	}
+	for s.missed != path && !isBelow(path, s.missed) && s.missed != s.path && filepath.Dir(s.missed) != s.missed {
+		s.missed = filepath.Dir(s.missed)
+	}
+}
+
+// takeMissed returns the path of the pending CATCH_UP event and clears it, or the empty string.
+func (s *subscription) takeMissed() string {
+	s.missedMu.Lock()
+	defer s.missedMu.Unlock()
+	path := s.missed
+	s.missed = ""
+	return path
+}
+
+func (s *subscription) covers(path string) bool {
+	return path != "" && (s.all || path == s.path || isBelow(path, s.path))
+}
+
+// wants reports whether e is about the path of the subscription and of one of its kinds.
+func (s *subscription) wants(e FileWatcherEvent) bool {
+	if s.options.Kinds != 0 && !e.Event.Is(s.options.Kinds) {
+		return false
+	}
+	if m := s.options.Matcher; m != nil && !m.Match(e.Path) && (e.PreviousPath == "" || !m.Match(e.PreviousPath)) {
+		return false
+	}
+	return s.covers(e.Path) || s.covers(e.PreviousPath)
+}
@@ -0,0 +1,95 @@
+package fileWatcher
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSubscribeLeavesEvents(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t, WithErrorBuffer(1))
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+	subscribed, unsubscribe := w.Subscribe(root)
+	defer unsubscribe()
+	filtered := w.EventsFiltered(EventCreateFile)
+
+	const files = 20
+	for i := 0; i < files; i++ {
+		writeFile(t, filepath.Join(root, fmt.Sprint("file", i)), "")
+	}
+	// every reader receives every create, the subscriptions take none away from Events
+	received := map[string]int{}
+	timeout := time.After(testTimeout)
+	for received["events"] < files || received["subscribed"] < files || received["filtered"] < files {
+		select {
+		case e := <-w.Events:
+			if e.Event == EventCreateFile {
+				received["events"]++
+			}
+		case e := <-subscribed:
+			if e.Event == EventCreateFile {
+				received["subscribed"]++
+			}
+		case e := <-filtered:
+			if e.Event != EventCreateFile {
+				t.Fatalf("the filtered channel received %s", e.Event)
+			}
+			received["filtered"]++
+		case <-timeout:
+			t.Fatalf("creates received: %v, want %d on each", received, files)
+		}
+	}
+
+	// the errors are left to Errors too
+	w.sendError(errors.New("failure"))
+	select {
+	case err := <-w.Errors:
+		if err.Error() != "failure" {
+			t.Fatal(err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("the error was not sent on Errors")
+	}
+}
+
+func TestSubscriptionsClosedWithTheWatcher(t *testing.T) {
+	w := newTestWatcher(t)
+	events, _ := w.Subscribe(t.TempDir())
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, open := <-events; open {
+		t.Fatal("the subscription outlived the watcher")
+	}
+	late, _ := w.Subscribe(t.TempDir())
+	if _, open := <-late; open {
+		t.Fatal("a subscription made after Close is open")
+	}
+}
+
+func TestEventsFilteredIsNotAHandler(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t)
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+	edits := w.EventsFiltered(EventEditFile)
+	if goroutines := w.goroutines()["handlers"]; goroutines > 0 {
+		t.Fatalf("EventsFiltered started %d handlers goroutines", goroutines)
+	}
+
+	path := filepath.Join(root, "file")
+	writeFile(t, path, "")
+	// the create is not an edit, it only reaches Events
+	waitEvent(t, w, func(e FileWatcherEvent) bool { return e.Event == EventCreateFile && e.Path == path })
+	select {
+	case e := <-edits:
+		t.Fatalf("the filtered channel received %s %s", e.Event, e.Path)
+	default:
+	}
+}
@@ -0,0 +1,184 @@
+package fileWatcher
+
+import (
+	"errors"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// SymlinkMode decides how the symlinks found in recursively watched trees are handled.
+type SymlinkMode int
+
+const (
+	// SymlinkIgnore does not follow symlinks: the changes made behind a link are not reported.
+	SymlinkIgnore SymlinkMode = iota
+	// SymlinkFollow watches the targets of the links as well, and reports their changes against the path of the link,
+	// as if the target was in the tree.
+	SymlinkFollow
+	// SymlinkResolve watches the targets of the links as well, and reports their changes against their own path.
+	SymlinkResolve
+)
+
+// maxSymlinkHops is the number of links resolved in a row before giving up, the limit of Linux.
+const maxSymlinkHops = 40
+
+type symlinkState struct {
+	mu sync.RWMutex
+	// targets maps the resolved targets of the followed links to the links.
+	targets map[string]string
+}
+
+// followLink watches the target of link, recursively for a directory. Links pointing to a watched path, and links
+// pointing to the directory holding them or above, which would make the tree infinite, are not followed.
+func (w *FileWatcher) followLink(link string) error {
+	target, err := w.resolveLink(link)
+	if err != nil {
+		return err
+	}
+	if target == filepath.Dir(link) || isBelow(filepath.Dir(link), target) {
+		w.log.Warn("Symlink loop, " + link + " points to " + target + " which holds it")
+		return nil
+	}
+	if w.Contains(target) {
+		w.trace(link, "points to %s, which is watched already", target)
+		return nil
+	}
+
+	w.symlinks.mu.Lock()
+	if _, ok := w.symlinks.targets[target]; ok {
+		w.symlinks.mu.Unlock()
+		return nil
+	}
+	if w.symlinks.targets == nil {
+		w.symlinks.targets = make(map[string]string)
+	}
+	w.symlinks.targets[target] = link
+	w.symlinks.mu.Unlock()
+	w.trace(link, "following the symlink to %s", target)
+
+	info, err := w.fs.Stat(target)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return w.Add(target)
+	}
+	w.recursive.mu.Lock()
+	if w.recursive.roots == nil {
+		w.recursive.roots = make(map[string]bool)
+	}
+	w.recursive.roots[target] = true
+	w.recursive.mu.Unlock()
+	return w.addTree(target)
+}
+
+// unfollowLink stops watching the target of link when it was followed.
+func (w *FileWatcher) unfollowLink(link string) {
+	w.symlinks.mu.Lock()
+	var target string
+	for t, l := range w.symlinks.targets {
+		if l == link {
+			target = t
+			delete(w.symlinks.targets, t)
+			break
+		}
+	}
+	w.symlinks.mu.Unlock()
+	if target == "" {
+		return
+	}
+
+	w.recursive.mu.Lock()
+	delete(w.recursive.roots, target)
+	w.recursive.mu.Unlock()
+	w.removeTree(target)
+}
+
+// resolveLink returns the path link points to, following chained links.
+func (w *FileWatcher) resolveLink(link string) (string, error) {
+	reader, ok := w.fs.(afero.LinkReader)
+	if !ok {
+		return "", errors.New("the filesystem does not support symlinks")
+	}
+	path := link
+	for i := 0; i < maxSymlinkHops; i++ {
+		target, err := reader.ReadlinkIfPossible(path)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = filepath.Clean(target)
+		if !w.isSymlink(path) {
+			return path, nil
+		}
+	}
+	return "", errors.New("too many levels of symlinks at " + link)
+}
+
+// isSymlink reports whether path is a symlink.
+func (w *FileWatcher) isSymlink(path string) bool {
+	lstater, ok := w.fs.(afero.Lstater)
+	if !ok {
+		return false
+	}
+	info, lstatCalled, err := lstater.LstatIfPossible(path)
+	return err == nil && lstatCalled && info.Mode()&os.ModeSymlink != 0
+}
+
+// followLinks keeps the followed links in line with e.
+func (w *FileWatcher) followLinks(e FileWatcherEvent) {
+	if w.options.Symlinks == SymlinkIgnore {
+		return
+	}
+	switch {
+	case isDelete(e):
+		w.unfollowLink(e.Path)
+	case e.Event.Is(EventRenameFile | EventRenameFolder):
+		w.unfollowLink(e.PreviousPath)
+	}
+	// links to directories are classified as folders and picked up by the walk of the new tree
+	if e.Event.Is(EventCreateFile|EventRenameFile|EventMovedInFile) && w.recursiveRoot(e.Path) && w.isSymlink(e.Path) {
+		if err := w.followLink(e.Path); err != nil {
+			w.log.Warn("Unable to follow the symlink ", e.Path, ": ", err)
+		}
+	}
+}
+
+// linkPaths reports the changes made behind followed links against the path of the links with SymlinkFollow.
+func (w *FileWatcher) linkPaths(e FileWatcherEvent) FileWatcherEvent {
+	if w.options.Symlinks != SymlinkFollow {
+		return e
+	}
+	w.symlinks.mu.RLock()
+	defer w.symlinks.mu.RUnlock()
+	if len(w.symlinks.targets) == 0 {
+		return e
+	}
+	e.Path = w.linkPath(e.Path)
+	if e.PreviousPath != "" {
+		e.PreviousPath = w.linkPath(e.PreviousPath)
+	}
+	return e
+}
+
+// linkPath rewrites path below the target of a followed link, with the lock held. The links found behind other links
+// are rewritten in turn.
+func (w *FileWatcher) linkPath(path string) string {
+	for i := 0; i <= len(w.symlinks.targets); i++ {
+		found := ""
+		for target := range w.symlinks.targets {
+			if (path == target || isBelow(path, target)) && len(target) > len(found) {
+				found = target
+			}
+		}
+		if found == "" {
+			break
+		}
+		path = w.symlinks.targets[found] + path[len(found):]
+	}
+	return path
+}
@@ -0,0 +1,428 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyntheticFs is an afero.Fs reporting the changes made through it to the watchers using it, the way the operating
+// system would. Wrapping afero.NewMemMapFs, it lets watchers be tested without touching the disk:
+//
+//	mem := NewSyntheticFs(afero.NewMemMapFs())
+//	w, err := Init(done, mem, logger)
+//	...
+//	err = afero.WriteFile(mem, "/watched/file.txt", content, 0644)
+//
+// A watcher given a SyntheticFs to Init uses it as its Backend, unless the Backend option is set. Changes made to the
+// wrapped filesystem directly are not reported.
+//
+// The entries report inode numbers that follow them when they are renamed, like on a real filesystem, so the moves
+// inferred from inodes are recognized.
+type SyntheticFs struct {
+	afero.Fs
+	mu        sync.Mutex
+	notifiers map[*syntheticNotifier]struct{}
+	// inodes holds the inode numbers of the entries, given the first time an entry is looked at
+	inodes    map[string]uint64
+	nextInode uint64
+}
+
+// syntheticInode is the file information of a SyntheticFs, its inode number.
+type syntheticInode uint64
+
+// syntheticInfo is the file information of an entry of a SyntheticFs, with its inode number.
+type syntheticInfo struct {
+	os.FileInfo
+	inode uint64
+}
+
+func (i syntheticInfo) Sys() interface{} {
+	return syntheticInode(i.inode)
+}
+
+// firstSyntheticInode is the first inode number given by a SyntheticFs, high above the numbers set with SetInode.
+const firstSyntheticInode = 1 << 48
+
+// NewSyntheticFs wraps base.
+func NewSyntheticFs(base afero.Fs) *SyntheticFs {
+	return &SyntheticFs{
+		Fs:        base,
+		notifiers: make(map[*syntheticNotifier]struct{}),
+		inodes:    make(map[string]uint64),
+		nextInode: firstSyntheticInode,
+	}
+}
+
+// SetInode sets the inode number of the entry at path, for replaying the changes recorded on a real filesystem.
+func (s *SyntheticFs) SetInode(path string, inode uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inodes[filepath.Clean(path)] = inode
+}
+
+// withInode returns info, the information of the entry at path, with its inode number.
+func (s *SyntheticFs) withInode(path string, info os.FileInfo) os.FileInfo {
+	path = filepath.Clean(path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inode, ok := s.inodes[path]
+	if !ok {
+		inode = s.nextInode
+		s.nextInode++
+		s.inodes[path] = inode
+	}
+	return syntheticInfo{FileInfo: info, inode: inode}
+}
+
+// moveInodes moves the inode numbers of the entries at and below from to to, or forgets them when to is empty.
+func (s *SyntheticFs) moveInodes(from string, to string) {
+	from = filepath.Clean(from)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if to != "" {
+		to = filepath.Clean(to)
+		for path := range s.inodes {
+			if path == to || isBelow(path, to) {
+				delete(s.inodes, path)
+			}
+		}
+	}
+	moved := make(map[string]uint64)
+	for path, inode := range s.inodes {
+		if path != from && !isBelow(path, from) {
+			continue
+		}
+		delete(s.inodes, path)
+		if to != "" {
+			moved[to+path[len(from):]] = inode
+		}
+	}
+	for path, inode := range moved {
+		s.inodes[path] = inode
+	}
+}
+
+func (s *SyntheticFs) Stat(name string) (os.FileInfo, error) {
+	info, err := s.Fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.withInode(name, info), nil
+}
+
+func (s *SyntheticFs) Open(name string) (afero.File, error) {
+	f, err := s.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &syntheticFile{File: f, fs: s, path: name}, nil
+}
+
+func (*SyntheticFs) polls(*FileWatcher, string) bool {
+	return false
+}
+
+// newNotifier returns a notifier receiving the changes made through s.
+func (s *SyntheticFs) newNotifier() *syntheticNotifier {
+	n := &syntheticNotifier{
+		fs:      s,
+		watched: make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+		events:  make(chan fsnotify.Event),
+		errors:  make(chan error),
+		done:    make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.notifiers[n] = struct{}{}
+	s.mu.Unlock()
+	go n.pump()
+	return n
+}
+
+// notify reports the operation op on path to the notifiers watching it.
+func (s *SyntheticFs) notify(op fsnotify.Op, path string) {
+	e := fsnotify.Event{Name: filepath.Clean(path), Op: op}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for n := range s.notifiers {
+		n.queue(e)
+	}
+}
+
+// Notify reports e to the watchers using s as if the operating system sent it, without changing the filesystem, for
+// tests scripting the notifications of other platforms or of races. Like the changes made through s, it only reaches
+// the watchers watching its path or the directory holding it.
+func (s *SyntheticFs) Notify(e fsnotify.Event) {
+	s.notify(e.Op, e.Name)
+}
+
+func (s *SyntheticFs) exists(path string) bool {
+	_, err := s.Fs.Stat(path)
+	return err == nil
+}
+
+func (s *SyntheticFs) Create(name string) (afero.File, error) {
+	existed := s.exists(name)
+	f, err := s.Fs.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	if existed {
+		s.notify(fsnotify.Write, name)
+	} else {
+		s.notify(fsnotify.Create, name)
+	}
+	return &syntheticFile{File: f, fs: s, path: name}, nil
+}
+
+func (s *SyntheticFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	existed := s.exists(name)
+	f, err := s.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !existed && flag&os.O_CREATE != 0:
+		s.notify(fsnotify.Create, name)
+	case existed && flag&os.O_TRUNC != 0:
+		s.notify(fsnotify.Write, name)
+	}
+	return &syntheticFile{File: f, fs: s, path: name}, nil
+}
+
+func (s *SyntheticFs) Mkdir(name string, perm os.FileMode) error {
+	if err := s.Fs.Mkdir(name, perm); err != nil {
+		return err
+	}
+	s.notify(fsnotify.Create, name)
+	return nil
+}
+
+func (s *SyntheticFs) MkdirAll(path string, perm os.FileMode) error {
+	var created []string
+	for dir := filepath.Clean(path); !s.exists(dir); dir = filepath.Dir(dir) {
+		created = append(created, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	if err := s.Fs.MkdirAll(path, perm); err != nil {
+		return err
+	}
+	for i := len(created) - 1; i >= 0; i-- {
+		s.notify(fsnotify.Create, created[i])
+	}
+	return nil
+}
+
+func (s *SyntheticFs) Remove(name string) error {
+	if err := s.Fs.Remove(name); err != nil {
+		return err
+	}
+	s.moveInodes(name, "")
+	s.notify(fsnotify.Remove, name)
+	return nil
+}
+
+func (s *SyntheticFs) RemoveAll(path string) error {
+	var removed []string
+	_ = afero.Walk(s.Fs, path, func(p string, info os.FileInfo, err error) error {
+		if err == nil {
+			removed = append(removed, p)
+		}
+		return nil
+	})
+	if err := s.Fs.RemoveAll(path); err != nil {
+		return err
+	}
+	s.moveInodes(path, "")
+	// the entries go before the directories holding them
+	for i := len(removed) - 1; i >= 0; i-- {
+		s.notify(fsnotify.Remove, removed[i])
+	}
+	return nil
+}
+
+func (s *SyntheticFs) Rename(oldname string, newname string) error {
+	if err := s.Fs.Rename(oldname, newname); err != nil {
+		return err
+	}
+	s.moveInodes(oldname, newname)
+	s.notify(fsnotify.Rename, oldname)
+	s.notify(fsnotify.Create, newname)
+	return nil
+}
+
+func (s *SyntheticFs) Chmod(name string, mode os.FileMode) error {
+	if err := s.Fs.Chmod(name, mode); err != nil {
+		return err
+	}
+	s.notify(fsnotify.Chmod, name)
+	return nil
+}
+
+func (s *SyntheticFs) Chown(name string, uid int, gid int) error {
+	if err := s.Fs.Chown(name, uid, gid); err != nil {
+		return err
+	}
+	s.notify(fsnotify.Chmod, name)
+	return nil
+}
+
+func (s *SyntheticFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := s.Fs.Chtimes(name, atime, mtime); err != nil {
+		return err
+	}
+	s.notify(fsnotify.Chmod, name)
+	return nil
+}
+
+func (s *SyntheticFs) Name() string {
+	return "SyntheticFs"
+}
+
+// syntheticFile reports the writes made to a file, and the inode numbers of the file and of the entries of a
+// directory.
+type syntheticFile struct {
+	afero.File
+	fs   *SyntheticFs
+	path string
+}
+
+func (f *syntheticFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return f.fs.withInode(f.path, info), nil
+}
+
+func (f *syntheticFile) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	for i, info := range infos {
+		infos[i] = f.fs.withInode(filepath.Join(f.path, info.Name()), info)
+	}
+	return infos, err
+}
+
+func (f *syntheticFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	if n > 0 {
+		f.fs.notify(fsnotify.Write, f.path)
+	}
+	return n, err
+}
+
+func (f *syntheticFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.File.WriteAt(p, off)
+	if n > 0 {
+		f.fs.notify(fsnotify.Write, f.path)
+	}
+	return n, err
+}
+
+func (f *syntheticFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *syntheticFile) Truncate(size int64) error {
+	if err := f.File.Truncate(size); err != nil {
+		return err
+	}
+	f.fs.notify(fsnotify.Write, f.path)
+	return nil
+}
+
+// syntheticNotifier delivers the changes made through a SyntheticFs to a watcher, like the notifications of the
+// operating system: for the watched paths and the entries of the watched directories. Changes are queued without a
+// bound, so the filesystem never blocks on a watcher.
+type syntheticNotifier struct {
+	fs      *SyntheticFs
+	mu      sync.Mutex
+	watched map[string]bool
+	pending []fsnotify.Event
+	wake    chan struct{}
+	events  chan fsnotify.Event
+	errors  chan error
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (n *syntheticNotifier) Add(path string) error {
+	if !n.fs.exists(path) {
+		return fmt.Errorf("%w: %s", os.ErrNotExist, path)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.watched[filepath.Clean(path)] = true
+	return nil
+}
+
+func (n *syntheticNotifier) Remove(path string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	path = filepath.Clean(path)
+	if !n.watched[path] {
+		return fmt.Errorf("%w: %s", fsnotify.ErrNonExistentWatch, path)
+	}
+	delete(n.watched, path)
+	return nil
+}
+
+func (n *syntheticNotifier) Close() error {
+	n.once.Do(func() {
+		n.fs.mu.Lock()
+		delete(n.fs.notifiers, n)
+		n.fs.mu.Unlock()
+		close(n.done)
+	})
+	return nil
+}
+
+func (n *syntheticNotifier) notifications() (<-chan fsnotify.Event, <-chan error) {
+	return n.events, n.errors
+}
+
+// queue adds e when its path or its directory is watched.
+func (n *syntheticNotifier) queue(e fsnotify.Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.watched[e.Name] && !n.watched[filepath.Dir(e.Name)] {
+		return
+	}
+	n.pending = append(n.pending, e)
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pump delivers the queued changes in order until the notifier is closed.
+func (n *syntheticNotifier) pump() {
+	labelGoroutine("synthetic-notifier")
+	for {
+		n.mu.Lock()
+		pending := n.pending
+		n.pending = nil
+		n.mu.Unlock()
+
+		for _, e := range pending {
+			select {
+			case n.events <- e:
+			case <-n.done:
+				return
+			}
+		}
+
+		select {
+		case <-n.wake:
+		case <-n.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+type tagState struct {
+	mu    sync.RWMutex
+	paths map[string]map[string]string
+}
+
+// AddWithTags adds path like Add, with tags set on the Tags of the events of path and of everything below it, like the
+// project or the tenant the path belongs to. The tags of the paths added with tags above an event are merged, the
+// closest ones win. Adding path again replaces its tags, nil drops them.
+//
+// The map is shared by the events: it must not be changed once passed, see Use.
+func (w *FileWatcher) AddWithTags(path string, tags map[string]string) error {
+	path = w.normalize(path)
+	if err := w.Add(path); err != nil {
+		return err
+	}
+	w.tags.mu.Lock()
+	defer w.tags.mu.Unlock()
+	if len(tags) == 0 {
+		delete(w.tags.paths, path)
+		return nil
+	}
+	if w.tags.paths == nil {
+		w.tags.paths = make(map[string]map[string]string)
+	}
+	w.tags.paths[path] = tags
+	return nil
+}
+
+// forgetTags drops the tags of path, once it is removed.
+func (w *FileWatcher) forgetTags(path string) {
+	w.tags.mu.Lock()
+	defer w.tags.mu.Unlock()
+	delete(w.tags.paths, path)
+}
+
+// tagsOf returns the tags of the events of path, nil when no path above it was added with tags.
+func (w *FileWatcher) tagsOf(path string) map[string]string {
+	w.tags.mu.RLock()
+	defer w.tags.mu.RUnlock()
+	if len(w.tags.paths) == 0 {
+		return nil
+	}
+	// found holds the tags from the closest path up
+	var found []map[string]string
+	for current := path; ; {
+		if tags, ok := w.tags.paths[current]; ok {
+			found = append(found, tags)
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+	switch len(found) {
+	case 0:
+		return nil
+	case 1:
+		return found[0]
+	}
+	res := make(map[string]string)
+	for i := len(found) - 1; i >= 0; i-- {
+		for k, v := range found[i] {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// tag sets the tags of the watched paths above e on it.
+func (w *FileWatcher) tag(e *FileWatcherEvent) {
+	if tags := w.tagsOf(e.Path); tags != nil {
+		e.Tags = tags
+	}
+}
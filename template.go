@@ -0,0 +1,289 @@
+package fileWatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultEventTemplate is the format of WriterSink when no template is set: one line per event.
+const DefaultEventTemplate = `{{ .Time | date "2006-01-02T15:04:05.000Z07:00" }} {{ .Event }} {{ .Path }}` +
+	`{{ with .PreviousPath }} (from {{ . }}){{ end }}`
+
+// SlackEventTemplate formats an event as the payload of a Slack or Microsoft Teams incoming webhook, to set as the
+// Template of a WebhookSink.
+const SlackEventTemplate = `{"text": {{ printf "%s ` + "`%s`" + `" .Event .Path | toJson }}}`
+
+// TemplateFuncs returns the helper functions available to the event templates, named after their sprig equivalents:
+//
+//	upper, lower, title, trim, trimPrefix, trimSuffix, replace, contains, hasPrefix, hasSuffix, repeat, trunc,
+//	split, join, quote, squote, indent, nindent     strings
+//	base, dir, ext, clean, rel                        paths
+//	default, empty, coalesce, ternary                 defaults
+//	date, now, unixEpoch, ago, duration               times
+//	toJson, toPrettyJson                              JSON, which also escapes strings for JSON payloads
+//	humanSize, size, mode                             file info, from the Info of the event
+//
+// The pipelines follow sprig: the value piped in is the last argument, like in {{ .Path | replace "/" "\\" }}, and
+// the string functions accept any value, like {{ .Event | lower }}.
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"upper":      func(v interface{}) string { return strings.ToUpper(fmt.Sprint(v)) },
+		"lower":      func(v interface{}) string { return strings.ToLower(fmt.Sprint(v)) },
+		"title":      func(v interface{}) string { return templateTitle(fmt.Sprint(v)) },
+		"trim":       func(v interface{}) string { return strings.TrimSpace(fmt.Sprint(v)) },
+		"trimPrefix": func(prefix string, v interface{}) string { return strings.TrimPrefix(fmt.Sprint(v), prefix) },
+		"trimSuffix": func(suffix string, v interface{}) string { return strings.TrimSuffix(fmt.Sprint(v), suffix) },
+		"replace":    func(old, new string, v interface{}) string { return strings.ReplaceAll(fmt.Sprint(v), old, new) },
+		"contains":   func(substr string, v interface{}) bool { return strings.Contains(fmt.Sprint(v), substr) },
+		"hasPrefix":  func(prefix string, v interface{}) bool { return strings.HasPrefix(fmt.Sprint(v), prefix) },
+		"hasSuffix":  func(suffix string, v interface{}) bool { return strings.HasSuffix(fmt.Sprint(v), suffix) },
+		"repeat":     func(count int, v interface{}) string { return strings.Repeat(fmt.Sprint(v), count) },
+		"trunc":      func(length int, v interface{}) string { return templateTrunc(length, fmt.Sprint(v)) },
+		"split":      func(sep string, v interface{}) []string { return strings.Split(fmt.Sprint(v), sep) },
+		"join":       templateJoin,
+		"quote":      func(v interface{}) string { return strconv.Quote(fmt.Sprint(v)) },
+		"squote":     func(v interface{}) string { return "'" + fmt.Sprint(v) + "'" },
+		"indent":     func(spaces int, v interface{}) string { return templateIndent(spaces, fmt.Sprint(v)) },
+		"nindent":    func(spaces int, v interface{}) string { return "\n" + templateIndent(spaces, fmt.Sprint(v)) },
+
+		"base":  filepath.Base,
+		"dir":   filepath.Dir,
+		"ext":   filepath.Ext,
+		"clean": filepath.Clean,
+		"rel":   templateRel,
+
+		"default":  templateDefault,
+		"empty":    templateEmpty,
+		"coalesce": templateCoalesce,
+		"ternary":  templateTernary,
+
+		"date":      func(layout string, t time.Time) string { return t.Format(layout) },
+		"now":       time.Now,
+		"unixEpoch": func(t time.Time) int64 { return t.Unix() },
+		"ago":       func(t time.Time) string { return time.Since(t).Round(time.Second).String() },
+		"duration":  func(d time.Duration) string { return d.String() },
+
+		"toJson":       templateJSON,
+		"toPrettyJson": templatePrettyJSON,
+
+		"humanSize": templateHumanSize,
+		"size":      templateSize,
+		"mode":      templateMode,
+	}
+}
+
+// ParseEventTemplate parses text as a template formatting a FileWatcherEvent, with the functions of TemplateFuncs.
+func ParseEventTemplate(text string) (*template.Template, error) {
+	return template.New("event").Funcs(TemplateFuncs()).Parse(text)
+}
+
+// FormatEvent executes t on e. Events without a time are formatted as if they were emitted now.
+func FormatEvent(t *template.Template, e FileWatcherEvent) ([]byte, error) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	buf := bytes.Buffer{}
+	if err := t.Execute(&buf, e); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriterSink writes every event to a writer, like a log file or the standard output, formatted with a template and
+// followed by a newline.
+type WriterSink struct {
+	mu       sync.Mutex
+	w        io.Writer
+	template *template.Template
+}
+
+// NewWriterSink creates a WriterSink formatting the events with t, or DefaultEventTemplate when t is nil.
+func NewWriterSink(w io.Writer, t *template.Template) *WriterSink {
+	if t == nil {
+		t = template.Must(ParseEventTemplate(DefaultEventTemplate))
+	}
+	return &WriterSink{w: w, template: t}
+}
+
+func (s *WriterSink) Send(e FileWatcherEvent) error {
+	line, err := FormatEvent(s.template, e)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasSuffix(line, []byte("\n")) {
+		line = append(line, '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(line)
+	return err
+}
+
+func templateTitle(s string) string {
+	words := strings.Fields(s)
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// templateTrunc keeps the first length bytes of s, or the last ones when length is negative.
+func templateTrunc(length int, s string) string {
+	switch {
+	case length >= 0 && len(s) > length:
+		return s[:length]
+	case length < 0 && len(s) > -length:
+		return s[len(s)+length:]
+	}
+	return s
+}
+
+func templateJoin(sep string, v interface{}) string {
+	if strs, ok := v.([]string); ok {
+		return strings.Join(strs, sep)
+	}
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return fmt.Sprint(v)
+	}
+	strs := make([]string, val.Len())
+	for i := range strs {
+		strs[i] = fmt.Sprint(val.Index(i).Interface())
+	}
+	return strings.Join(strs, sep)
+}
+
+func templateIndent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// templateRel returns path relative to base, or path when it is not below base.
+func templateRel(base, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
+
+// templateEmpty reports whether v is nil or the zero value of its type.
+func templateEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return val.Len() == 0
+	}
+	return val.IsZero()
+}
+
+func templateDefault(def interface{}, v ...interface{}) interface{} {
+	if len(v) == 0 || templateEmpty(v[0]) {
+		return def
+	}
+	return v[0]
+}
+
+func templateCoalesce(v ...interface{}) interface{} {
+	for _, val := range v {
+		if !templateEmpty(val) {
+			return val
+		}
+	}
+	return nil
+}
+
+func templateTernary(yes, no interface{}, cond bool) interface{} {
+	if cond {
+		return yes
+	}
+	return no
+}
+
+// templateJSON encodes v as JSON, the events in the wire schema.
+func templateJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(templateWire(v))
+	return string(data), err
+}
+
+func templatePrettyJSON(v interface{}) (string, error) {
+	data, err := json.MarshalIndent(templateWire(v), "", "  ")
+	return string(data), err
+}
+
+func templateWire(v interface{}) interface{} {
+	if e, ok := v.(FileWatcherEvent); ok {
+		return NewWireEvent(e)
+	}
+	return v
+}
+
+// templateSize returns the size of v, a FileWatcherEvent or its Info, or zero when it is unknown.
+func templateSize(v interface{}) int64 {
+	if info := templateInfo(v); info != nil {
+		return info.Size()
+	}
+	return 0
+}
+
+func templateMode(v interface{}) string {
+	if info := templateInfo(v); info != nil {
+		return info.Mode().String()
+	}
+	return ""
+}
+
+// templateInfo returns the file info of v, a FileWatcherEvent or an os.FileInfo.
+func templateInfo(v interface{}) os.FileInfo {
+	switch v := v.(type) {
+	case FileWatcherEvent:
+		if v.Info != nil {
+			return v.Info
+		}
+	case *FileWatcherEvent:
+		if v != nil && v.Info != nil {
+			return v.Info
+		}
+	case os.FileInfo:
+		return v
+	}
+	return nil
+}
+
+// templateHumanSize formats a number of bytes, or the size of an event, with a binary unit, like 1.5 KiB.
+func templateHumanSize(v interface{}) string {
+	var size float64
+	switch n := v.(type) {
+	case int:
+		size = float64(n)
+	case int64:
+		size = float64(n)
+	case uint64:
+		size = float64(n)
+	default:
+		size = float64(templateSize(v))
+	}
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for ; size >= 1024 && i < len(units)-1; i++ {
+		size /= 1024
+	}
+	if i == 0 {
+		return strconv.FormatFloat(size, 'f', 0, 64) + " B"
+	}
+	return strconv.FormatFloat(size, 'f', 1, 64) + " " + units[i]
+}
@@ -0,0 +1,90 @@
+package fileWatcher
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// tokenBucket limits a rate of bytes per second, allowing bursts up to its capacity.
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// take waits until n bytes may be read. n must not exceed the capacity.
+func (b *tokenBucket) take(n int) {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	deficit := -b.tokens
+	b.mu.Unlock()
+
+	if deficit > 0 {
+		// the tokens are taken already, later readers queue up behind this one
+		time.Sleep(time.Duration(deficit / b.rate * float64(time.Second)))
+	}
+}
+
+var ioThrottle struct {
+	mu     sync.RWMutex
+	bucket *tokenBucket
+}
+
+// SetIOThrottle limits the reads of the enrichment helpers, like the hashing of the IndexFeeder, the Deriver, the
+// Manifest and the polling backend or the ScanStage, to bytesPerSecond in total, so large changes do not saturate the
+// disks of the application the watcher is embedded in. Bursts of up to burst bytes are read at full speed, burst
+// defaults to one second worth of reads. Zero or less removes the limit.
+func SetIOThrottle(bytesPerSecond int64, burst int64) {
+	ioThrottle.mu.Lock()
+	defer ioThrottle.mu.Unlock()
+	if bytesPerSecond <= 0 {
+		ioThrottle.bucket = nil
+		return
+	}
+	if burst <= 0 {
+		burst = bytesPerSecond
+	}
+	ioThrottle.bucket = &tokenBucket{
+		rate:     float64(bytesPerSecond),
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// throttledReader reads from r within the I/O throttle.
+type throttledReader struct {
+	r      io.Reader
+	bucket *tokenBucket
+}
+
+// throttled wraps r in the I/O throttle, or returns it as is without a limit.
+func throttled(r io.Reader) io.Reader {
+	ioThrottle.mu.RLock()
+	bucket := ioThrottle.bucket
+	ioThrottle.mu.RUnlock()
+	if bucket == nil {
+		return r
+	}
+	return &throttledReader{r: r, bucket: bucket}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if max := int(t.bucket.capacity); len(p) > max && max > 0 {
+		p = p[:max]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.bucket.take(n)
+	}
+	return n, err
+}
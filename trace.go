@@ -0,0 +1,154 @@
+package fileWatcher
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TraceRecord is the decision trail of a raw event: the filters it went through, how it was classified, which other
+// event it was paired with and what was delivered.
+type TraceRecord struct {
+	Time time.Time
+	Path string
+	// Op is the raw operation reported by the operating system, like "CREATE" or "RENAME|REMOVE".
+	Op    string
+	Steps []TraceStep
+}
+
+// TraceStep is a single decision taken for a raw event.
+type TraceStep struct {
+	Time    time.Time
+	Message string
+}
+
+func (r TraceRecord) String() string {
+	s := r.Time.Format(time.RFC3339Nano) + " " + r.Op + " " + r.Path
+	for _, step := range r.Steps {
+		s += "\n  +" + step.Time.Sub(r.Time).String() + " " + step.Message
+	}
+	return s
+}
+
+// tracer keeps the records of the last raw events in a ring.
+type tracer struct {
+	mu      sync.Mutex
+	records []*TraceRecord
+	next    int
+	// latest is the most recent record of each path, so later decisions can be attached to it.
+	latest map[string]*TraceRecord
+}
+
+func newTracer(size int) *tracer {
+	if size <= 0 {
+		return nil
+	}
+	return &tracer{
+		records: make([]*TraceRecord, size),
+		latest:  make(map[string]*TraceRecord),
+	}
+}
+
+// traceRaw starts the record of a raw event.
+func (w *FileWatcher) traceRaw(event fsnotify.Event) {
+	w.instrument(TraceEntry{Kind: TraceRaw, Level: TraceLevelDebug, Path: event.Name, Op: event.Op.String()})
+	t := w.tracer
+	if t == nil {
+		return
+	}
+
+	record := &TraceRecord{Time: time.Now(), Path: event.Name, Op: event.Op.String()}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if old := t.records[t.next]; old != nil && t.latest[old.Path] == old {
+		delete(t.latest, old.Path)
+	}
+	t.records[t.next] = record
+	t.next = (t.next + 1) % len(t.records)
+	t.latest[event.Name] = record
+}
+
+// trace adds a decision to the latest record of path and hands it to the Tracer option. The message is only
+// formatted when tracing is enabled.
+func (w *FileWatcher) trace(path string, format string, args ...interface{}) {
+	if w.tracer == nil && w.options.Tracer == nil {
+		return
+	}
+	w.traceStep(FileWatcherEvent{Path: path}, fmt.Sprintf(format, args...))
+}
+
+// traceEvent adds a decision about a classified event to the records of its paths.
+func (w *FileWatcher) traceEvent(e FileWatcherEvent, format string, args ...interface{}) {
+	if w.tracer == nil && w.options.Tracer == nil {
+		return
+	}
+	w.traceStep(e, fmt.Sprintf(format, args...))
+}
+
+// traceStep hands the decision message about the paths of e to the Tracer option, and adds it to their records.
+func (w *FileWatcher) traceStep(e FileWatcherEvent, message string) {
+	step := TraceStep{Time: time.Now(), Message: message}
+	w.instrument(TraceEntry{
+		Time:         step.Time,
+		Kind:         TraceDecision,
+		Level:        TraceLevelDebug,
+		Path:         e.Path,
+		PreviousPath: e.PreviousPath,
+		Event:        e.Event,
+		Message:      message,
+	})
+	t := w.tracer
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if record, ok := t.latest[e.Path]; ok {
+		record.Steps = append(record.Steps, step)
+	}
+	if e.PreviousPath == "" || e.PreviousPath == e.Path {
+		return
+	}
+	if record, ok := t.latest[e.PreviousPath]; ok {
+		record.Steps = append(record.Steps, step)
+	}
+}
+
+// DebugTrace returns the decision trails of the last raw events, oldest first. It is empty unless the DebugTrace
+// option is set.
+func (w *FileWatcher) DebugTrace() []TraceRecord {
+	t := w.tracer
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res := make([]TraceRecord, 0, len(t.records))
+	for i := range t.records {
+		record := t.records[(t.next+i)%len(t.records)]
+		if record != nil {
+			copied := *record
+			copied.Steps = append([]TraceStep(nil), record.Steps...)
+			res = append(res, copied)
+		}
+	}
+	return res
+}
+
+// DebugTraceFor returns the decision trails of the last raw events for path, oldest first. It answers "why didn't I
+// get an event for this file?".
+func (w *FileWatcher) DebugTraceFor(path string) []TraceRecord {
+	path = w.normalize(path)
+	var res []TraceRecord
+	for _, record := range w.DebugTrace() {
+		if filepath.Clean(record.Path) == path {
+			res = append(res, record)
+		}
+	}
+	return res
+}
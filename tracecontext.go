@@ -0,0 +1,91 @@
+package fileWatcher
+
+import (
+	"sync"
+)
+
+// TraceContext is a distributed tracing context in the W3C Trace Context format, see
+// https://www.w3.org/TR/trace-context/. It is carried by the events of the operations started with BeginOperation and
+// forwarded by the sinks, so the changes made by a known operation, like a deploy job, can be stitched into its trace.
+type TraceContext struct {
+	// TraceParent is the value of the traceparent header, like "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+	TraceParent string
+	// TraceState is the value of the tracestate header, it is optional.
+	TraceState string
+}
+
+// IsZero reports whether tc carries no context.
+func (tc TraceContext) IsZero() bool {
+	return tc.TraceParent == "" && tc.TraceState == ""
+}
+
+type operation struct {
+	path  string
+	trace TraceContext
+}
+
+type operationState struct {
+	mu     sync.RWMutex
+	ops    map[int]*operation
+	nextID int
+}
+
+// BeginOperation attributes the events of path and of everything below it to the operation traced by tc, until the
+// returned function is called. When operations overlap, the events get the context of the deepest path, and of the
+// latest operation for the same path.
+func (w *FileWatcher) BeginOperation(path string, tc TraceContext) func() {
+	op := &operation{path: w.normalize(path), trace: tc}
+
+	w.operations.mu.Lock()
+	if w.operations.ops == nil {
+		w.operations.ops = make(map[int]*operation)
+	}
+	id := w.operations.nextID
+	w.operations.nextID++
+	w.operations.ops[id] = op
+	w.operations.mu.Unlock()
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			w.operations.mu.Lock()
+			delete(w.operations.ops, id)
+			w.operations.mu.Unlock()
+		})
+	}
+}
+
+// traceContext returns the context of the operation path is part of.
+func (w *FileWatcher) traceContext(path string) TraceContext {
+	w.operations.mu.RLock()
+	defer w.operations.mu.RUnlock()
+	var found *operation
+	foundID := -1
+	for id, op := range w.operations.ops {
+		if path != op.path && !isBelow(path, op.path) {
+			continue
+		}
+		if found == nil || len(op.path) > len(found.path) || (len(op.path) == len(found.path) && id > foundID) {
+			found, foundID = op, id
+		}
+	}
+	if found == nil {
+		return TraceContext{}
+	}
+	return found.trace
+}
+
+// assignID fills in the ID and the trace context of e.
+func (w *FileWatcher) assignID(e *FileWatcherEvent) {
+	if w.options.IDGenerator != nil {
+		e.ID = w.options.IDGenerator(*e)
+	} else {
+		e.ID = e.Key
+	}
+	if e.Trace.IsZero() {
+		e.Trace = w.traceContext(e.Path)
+		if e.Trace.IsZero() && e.PreviousPath != "" {
+			e.Trace = w.traceContext(e.PreviousPath)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// trashDirs are the names of the directories the desktops move deleted items to: ~/.Trash and /Volumes/x/.Trashes on
+// macOS, the Recycle Bin of every Windows drive and the home trash of the freedesktop specification. The per volume
+// freedesktop trashes, .Trash-1000 or .Trash/1000, are matched by isTrashDir.
+var trashDirs = []string{".Trash", ".Trashes", "$Recycle.Bin", "RECYCLER", "RECYCLED"}
+
+// isTrashDir reports whether name is the name of a trash directory.
+func isTrashDir(name string) bool {
+	if strings.HasPrefix(name, ".Trash-") {
+		return true
+	}
+	for _, dir := range trashDirs {
+		if strings.EqualFold(name, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// inTrash reports whether path lies in a trash directory.
+func inTrash(path string) bool {
+	path = filepath.ToSlash(filepath.Clean(path))
+	if strings.Contains(path, "/.local/share/Trash/") {
+		return true
+	}
+	parts := strings.Split(path, "/")
+	// the last element is the trashed item itself
+	for _, part := range parts[:len(parts)-1] {
+		if isTrashDir(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyTrash turns a rename into a trash directory into the delete the user meant, flagged as Trashed, and a
+// rename out of one, restoring the item, into a create.
+func classifyTrash(e FileWatcherEvent) FileWatcherEvent {
+	if !isRename(e) || inTrash(e.PreviousPath) == inTrash(e.Path) {
+		return e
+	}
+
+	folder := e.Event == EventRenameFolder
+	if inTrash(e.Path) {
+		e.Path = e.PreviousPath
+		e.Trashed = true
+		e.Event = EventDeleteFile
+		if folder {
+			e.Event = EventDeleteFolder
+		}
+	} else {
+		e.Event = EventCreateFile
+		if folder {
+			e.Event = EventCreateFolder
+		}
+	}
+	e.PreviousPath = ""
+	return e
+}
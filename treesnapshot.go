@@ -0,0 +1,240 @@
+package fileWatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/afero"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// treeSnapshotVersion is the version of the format written by TreeSnapshot.Write.
+const treeSnapshotVersion = 1
+
+// TreeSnapshot is the state of a directory tree in a portable form, to compare a tree with a baseline taken on
+// another machine, see SnapshotTree and CompareSnapshot.
+type TreeSnapshot struct {
+	Version int       `json:"v"`
+	Time    time.Time `json:"time"`
+	// Root is the path of the tree on the machine it was taken on, for information: the entries are relative to it.
+	Root    string      `json:"root"`
+	Entries []TreeEntry `json:"entries"`
+}
+
+// TreeEntry is a file or a folder of a TreeSnapshot.
+type TreeEntry struct {
+	// Path is relative to the root of the snapshot, with forward slashes on every platform.
+	Path    string    `json:"path"`
+	Dir     bool      `json:"dir,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"modTime"`
+	// Hash is the SHA-256 of the content of a file, in hexadecimal.
+	Hash string `json:"hash,omitempty"`
+}
+
+// SnapshotTree returns the state of the tree at root: the size, modification time and SHA-256 hash of every file.
+// Every file is read, taking the snapshot of a large tree takes a while. The ignored paths are left out.
+func (w *FileWatcher) SnapshotTree(root string) (*TreeSnapshot, error) {
+	return snapshotTree(w.fs, w.normalize(root), func(p string) bool {
+		_, ignored := w.ignoreReason(p)
+		return ignored
+	})
+}
+
+// snapshotTree returns the state of the tree at root on fsys, without the paths ignored reports.
+func snapshotTree(fsys afero.Fs, root string, ignored func(string) bool) (*TreeSnapshot, error) {
+	info, err := fsys.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a folder", root)
+	}
+
+	snapshot := &TreeSnapshot{Version: treeSnapshotVersion, Time: time.Now(), Root: root}
+	err = afero.Walk(fsys, root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		if ignored(p) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		entry := newPolledEntry(fsys, p, info, true)
+		te := TreeEntry{Path: filepath.ToSlash(rel), Dir: entry.isDir, ModTime: entry.modTime, Hash: entry.hash}
+		// the size of a folder depends on the filesystem
+		if !entry.isDir {
+			te.Size = entry.size
+		}
+		snapshot.Entries = append(snapshot.Entries, te)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Write writes s to out as JSON, to be read back by ReadTreeSnapshot on any machine.
+func (s *TreeSnapshot) Write(out io.Writer) error {
+	return json.NewEncoder(out).Encode(s)
+}
+
+// ReadTreeSnapshot reads a snapshot written by TreeSnapshot.Write.
+func ReadTreeSnapshot(in io.Reader) (*TreeSnapshot, error) {
+	s := &TreeSnapshot{}
+	if err := json.NewDecoder(in).Decode(s); err != nil {
+		return nil, fmt.Errorf("invalid tree snapshot: %w", err)
+	}
+	if s.Version != treeSnapshotVersion {
+		return nil, fmt.Errorf("unsupported tree snapshot version %d", s.Version)
+	}
+	for _, entry := range s.Entries {
+		clean := path.Clean(entry.Path)
+		if entry.Path == "" || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("invalid tree snapshot entry %q", entry.Path)
+		}
+	}
+	return s, nil
+}
+
+// CompareSnapshot compares the tree at root with baseline, typically taken on another machine, and returns what
+// changed since as the events live watching would report: CREATE, EDIT and DELETE events of the paths below root,
+// folders before their content. The content of a created folder is reported too, the content of a deleted one isn't.
+// Files are compared by hash and size when both sides have a hash, as the modification times rarely survive a copy,
+// and by size and modification time otherwise.
+func (w *FileWatcher) CompareSnapshot(baseline *TreeSnapshot, root string) ([]FileWatcherEvent, error) {
+	current, err := w.SnapshotTree(root)
+	if err != nil {
+		return nil, err
+	}
+	return DiffSnapshots(baseline, current), nil
+}
+
+// Diff compares the trees at oldRoot and newRoot, like a backup and its source or two releases, and returns the events
+// turning the first into the second as live watching would report them, see CompareSnapshot. The paths of the events
+// are below newRoot, the ignored paths are left out. Moves are reported as a DELETE and a CREATE: unlike the kernel,
+// the comparison can't tell a move from a copy.
+func (w *FileWatcher) Diff(oldRoot string, newRoot string) ([]FileWatcherEvent, error) {
+	before, err := w.SnapshotTree(oldRoot)
+	if err != nil {
+		return nil, err
+	}
+	after, err := w.SnapshotTree(newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return DiffSnapshots(before, after), nil
+}
+
+// Diff is FileWatcher.Diff for one-shot comparisons without a watcher, on the filesystem set with SetFs or the one of
+// the first watcher created, the OS filesystem otherwise. Nothing is ignored.
+func Diff(oldRoot string, newRoot string) ([]FileWatcherEvent, error) {
+	fsys := fs
+	if fsys == nil {
+		fsys = afero.NewOsFs()
+	}
+	nothing := func(string) bool {
+		return false
+	}
+	before, err := snapshotTree(fsys, absPath(oldRoot), nothing)
+	if err != nil {
+		return nil, err
+	}
+	after, err := snapshotTree(fsys, absPath(newRoot), nothing)
+	if err != nil {
+		return nil, err
+	}
+	return DiffSnapshots(before, after), nil
+}
+
+// DiffSnapshots returns the events turning the tree of before into the tree of after, see CompareSnapshot. The paths
+// of the events are below the root of after.
+func DiffSnapshots(before *TreeSnapshot, after *TreeSnapshot) []FileWatcherEvent {
+	previous := make(map[string]TreeEntry, len(before.Entries))
+	for _, entry := range before.Entries {
+		previous[path.Clean(entry.Path)] = entry
+	}
+	current := make(map[string]TreeEntry, len(after.Entries))
+	for _, entry := range after.Entries {
+		current[path.Clean(entry.Path)] = entry
+	}
+	at := func(rel string) string {
+		return filepath.Join(after.Root, filepath.FromSlash(rel))
+	}
+
+	var res []FileWatcherEvent
+	rels := make([]string, 0, len(previous)+len(current))
+	for rel := range previous {
+		rels = append(rels, rel)
+	}
+	for rel := range current {
+		if _, ok := previous[rel]; !ok {
+			rels = append(rels, rel)
+		}
+	}
+	sort.Strings(rels)
+	// deleted are the folders reported as deleted, their content isn't
+	var deleted []string
+	for _, rel := range rels {
+		old, existed := previous[rel]
+		now, exists := current[rel]
+		switch {
+		case existed && (!exists || old.Dir != now.Dir):
+			if underAny(rel, deleted) {
+				break
+			}
+			e := FileWatcherEvent{Path: at(rel), Event: EventDeleteFile}
+			if old.Dir {
+				e.Event = EventDeleteFolder
+				deleted = append(deleted, rel)
+			}
+			res = append(res, e)
+		case existed && !now.Dir && treeEntryChanged(old, now):
+			res = append(res, FileWatcherEvent{Path: at(rel), Event: EventEditFile})
+		}
+		if exists && (!existed || old.Dir != now.Dir) {
+			e := FileWatcherEvent{Path: at(rel), Event: EventCreateFile}
+			if now.Dir {
+				e.Event = EventCreateFolder
+			}
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// treeEntryChanged reports whether the content of the file before differs from after.
+func treeEntryChanged(before TreeEntry, after TreeEntry) bool {
+	if before.Size != after.Size {
+		return true
+	}
+	if before.Hash != "" && after.Hash != "" {
+		return before.Hash != after.Hash
+	}
+	return !before.ModTime.Equal(after.ModTime)
+}
+
+// underAny reports whether the slash-separated rel is below one of dirs.
+func underAny(rel string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(rel, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
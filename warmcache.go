@@ -0,0 +1,149 @@
+package fileWatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// warmCacheVersion is the version of the format of WarmCacheFile.
+const warmCacheVersion = 1
+
+// savedWarmCache is what WarmCacheFile holds: the state of the paths the stat cache and the content hashes of
+// VerifyContentChange knew about when the watcher was closed.
+type savedWarmCache struct {
+	Version int       `json:"v"`
+	Time    time.Time `json:"time"`
+	// Fingerprinter is the name of the algorithm of the hashes, see Fingerprinter. The hashes of the files written
+	// before fingerprints are SHA-256.
+	Fingerprinter string           `json:"fingerprinter,omitempty"`
+	Entries       []warmCacheEntry `json:"entries"`
+}
+
+type warmCacheEntry struct {
+	Path    string    `json:"path"`
+	Dir     bool      `json:"dir,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"modTime"`
+	// Hash is the fingerprint of the content of a file, when VerifyContentChange knew it.
+	Hash string `json:"hash,omitempty"`
+}
+
+// saveWarmCache writes the stat cache and the content hashes to WarmCacheFile, replacing it at once so a crash
+// while writing leaves the previous one.
+func (w *FileWatcher) saveWarmCache() error {
+	file := w.options.WarmCacheFile
+	if file == "" {
+		return nil
+	}
+
+	entries := make(map[string]warmCacheEntry)
+	w.statCache.mu.Lock()
+	for path, entry := range w.statCache.entries {
+		if entry.err == nil && entry.info != nil {
+			entries[path] = warmCacheEntry{
+				Path:    path,
+				Dir:     entry.info.IsDir(),
+				Size:    entry.info.Size(),
+				ModTime: entry.info.ModTime(),
+			}
+		}
+	}
+	w.statCache.mu.Unlock()
+	w.content.mu.Lock()
+	for path, entry := range w.content.hashes {
+		cached, ok := entries[path]
+		if !ok || cached.Size != entry.size || !cached.ModTime.Equal(entry.modTime) {
+			cached = warmCacheEntry{Path: path, Size: entry.size, ModTime: entry.modTime}
+		}
+		cached.Hash = entry.hash
+		entries[path] = cached
+	}
+	w.content.mu.Unlock()
+
+	saved := savedWarmCache{Version: warmCacheVersion, Time: time.Now(), Fingerprinter: w.fingerprinter().Name(),
+		Entries: make([]warmCacheEntry, 0, len(entries))}
+	for _, entry := range entries {
+		saved.Entries = append(saved.Entries, entry)
+	}
+	sort.Slice(saved.Entries, func(i, j int) bool {
+		return saved.Entries[i].Path < saved.Entries[j].Path
+	})
+
+	tmp, err := afero.TempFile(w.fs, filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(tmp).Encode(saved); err != nil {
+		_ = tmp.Close()
+		_ = w.fs.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = w.fs.Remove(tmp.Name())
+		return err
+	}
+	return w.fs.Rename(tmp.Name(), file)
+}
+
+// loadWarmCache fills the stat cache and the content hashes from WarmCacheFile. Every entry is checked with a stat:
+// the paths gone or whose type, size or modification time changed while the watcher was down are left out. A missing
+// file is not an error, it is written when the watcher is closed.
+func (w *FileWatcher) loadWarmCache() error {
+	file := w.options.WarmCacheFile
+	if file == "" {
+		return nil
+	}
+	in, err := w.fs.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var saved savedWarmCache
+	if err := json.NewDecoder(in).Decode(&saved); err != nil {
+		return fmt.Errorf("invalid warm cache %s: %w", file, err)
+	}
+	if saved.Version != warmCacheVersion {
+		return fmt.Errorf("unsupported warm cache version %d in %s", saved.Version, file)
+	}
+
+	// the hashes of another algorithm would tell every file apart
+	algorithm := saved.Fingerprinter
+	if algorithm == "" {
+		algorithm = SHA256Fingerprinter{}.Name()
+	}
+	hashes := algorithm == w.fingerprinter().Name()
+	kept := 0
+	for _, entry := range saved.Entries {
+		if !filepath.IsAbs(entry.Path) {
+			continue
+		}
+		now := time.Now()
+		info, err := w.fs.Stat(entry.Path)
+		if err != nil || info.IsDir() != entry.Dir || !info.ModTime().Equal(entry.ModTime) ||
+			!entry.Dir && info.Size() != entry.Size {
+			w.trace(entry.Path, "changed since the warm cache was saved, left out")
+			continue
+		}
+		kept++
+		w.cacheStat(entry.Path, info, nil, now)
+		if entry.Hash != "" && hashes {
+			w.content.mu.Lock()
+			w.rememberContentLocked(Document{Path: entry.Path, Hash: entry.Hash, ModTime: entry.ModTime,
+				Size: entry.Size})
+			w.content.mu.Unlock()
+			w.rememberFingerprint(fingerprintEntry{path: entry.Path, sum: entry.Hash, size: entry.Size,
+				modTime: entry.ModTime})
+		}
+	}
+	w.log.Debug("Warmed the caches with ", kept, " of the ", len(saved.Entries), " entries saved at ", saved.Time)
+	return nil
+}
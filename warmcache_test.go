@@ -0,0 +1,51 @@
+package fileWatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestWarmCacheOnTheFilesystemOfTheWatcher(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	// a path no test could write on the filesystem of the host
+	cache := filepath.Join(string(filepath.Separator), "warmcache-"+t.Name(), "cache.json")
+	file := filepath.Join(string(filepath.Separator), "data", "file")
+	if err := fs.MkdirAll(filepath.Dir(cache), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := afero.WriteFile(fs, file, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := Init(nil, fs, nopLogger{}, WithWarmCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Stat(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(cache); err != nil {
+		t.Fatal("the warm cache was not saved on the filesystem of the watcher: ", err)
+	}
+	if _, err := os.Stat(cache); !os.IsNotExist(err) {
+		t.Fatal("the warm cache was saved on the filesystem of the host")
+	}
+
+	w, err = Init(nil, fs, nopLogger{}, WithWarmCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.statCache.mu.Lock()
+	_, ok := w.statCache.entries[file]
+	w.statCache.mu.Unlock()
+	if !ok {
+		t.Fatal("the warm cache was not loaded from the filesystem of the watcher")
+	}
+}
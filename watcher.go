@@ -3,13 +3,14 @@
 package fileWatcher
 
 import (
-	"fmt"
+	"errors"
 	"github.com/fsnotify/fsnotify"
 	cmap "github.com/orcaman/concurrent-map/v2"
 	"github.com/spf13/afero"
 	"os"
-	"path/filepath"
-	"strings"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -23,116 +24,436 @@ type Logger interface {
 	Print(args ...interface{})
 }
 
+// log and fs are the defaults of the watchers created without a logger or a filesystem, and what the helpers not
+// tied to a watcher, like Deriver or Manifest, use.
 var log Logger
 
+// SetLogger sets the logger of the helpers not tied to a watcher and of the watchers created without one.
+//
+// Deprecated: pass the logger to Init, every watcher keeps its own.
 func SetLogger(l Logger) {
 	log = l
 }
 
 var fs afero.Fs
 
+// SetFs sets the filesystem of the helpers not tied to a watcher and of the watchers created without one.
+//
+// Deprecated: pass the filesystem to Init, every watcher keeps its own.
 func SetFs(newFs afero.Fs) {
 	fs = newFs
 }
 
 type FileWatcher struct {
+	// Watcher is the fsnotify watcher. It is replaced when it dies and is re-created, see Healthy.
 	Watcher    *fsnotify.Watcher
 	WatchedMap cmap.ConcurrentMap[string, string]
 	Events     chan FileWatcherEvent
-	Errors     chan error
+	// Errors receives the errors of the watcher, a WatchError unless stated otherwise, see the ErrorBuffer option.
+	Errors chan error
+	// ChangeSets receives the bursts of events detected when the ChangeSetQuietPeriod option is set.
+	ChangeSets chan *ChangeSet
+	// Batches receives the batches of events when the BatchQuietPeriod option is set, the events are then not
+	// delivered on Events.
+	Batches chan *FileWatcherBatch
+	// Priority receives the events of the paths added with PriorityHigh, see AddWithPriority.
+	Priority chan FileWatcherEvent
+
+	options    Options
+	changeSets changeSetState
+	coalescer  coalesceState
+	sqlite     sqliteState
+	listings   dirListings
+	renames    renameInference
+	statCache  statCache
+	// fingerprints are the fingerprints of the files hashed, see Fingerprint.
+	fingerprints fingerprintCache
+	dispatcher   dispatcher
+	classifier   classifier
+	ignores      *patternSet
+	includes     *patternSet
+	adaptive     adaptiveState
+	memory       memoryState
+	tracer       *tracer
+	ignoreFiles  ignoreFiles
+	poller       poller
+	history      *history
+	quarantine   quarantineState
+	dirty        dirtyState
+	scopes       scopeState
+	recursive    recursiveState
+	debouncer    debounceState
+	groups       groupState
+	handlers     handlerState
+	subscribers  subscriberState
+	switching    sync.Mutex
+	sentinels    sentinelState
+	latency      latencyState
+	load         loadState
+	content      contentState
+	extractions  extractionState
+	bulk         bulkState
+	life         lifecycle
+	counters     counterState
+	overflow     overflowState
+	operations   operationState
+	access       accessState
+	permissions  permissionState
+	symlinks     symlinkState
+	batches      batchState
+	stability    stabilityState
+	pause        pauseState
+	rearm        rearmState
+	statPolicy   statPolicyState
+	priorities   priorityState
+	pathLocks    pathLocks
+	// orderLocks serializes the dispatch of the events of a directory with the OrderedDelivery option.
+	orderLocks  pathLocks
+	atomicSave  atomicSaveState
+	owners      ownerState
+	rates       rateLimitState
+	chmod       chmodState
+	ordering    orderingState
+	middlewares middlewareState
+	stateGC     stateGCState
+	tags        tagState
+	matchers    matcherState
+	health      healthState
+	timers      timerTracker
+	leader      leaderState
+	notifier    notifier
+	seq         uint64
+	epoch       string
+	// instance identifies the watcher among the watchers of the process, in the labels of its goroutines.
+	instance string
+	// fs and log are the filesystem and the logger given to Init.
+	fs  afero.Fs
+	log Logger
 }
 
 type FileWatcherEvent struct {
 	Path         string
 	PreviousPath string
-	Event        string
+	Event        EventKind
+	// Locked is set on Windows when the file was still locked by its writer, see the Lock option.
+	Locked bool
+	// Trashed is set on the delete events of items moved to the trash of the desktop, like ~/.Trash or the Recycle
+	// Bin. Path is where the item was before it was trashed.
+	Trashed bool
+	// Suppressed counts the events of the path dropped by the RateLimit option since the previous event delivered for
+	// it.
+	Suppressed int
+	// Attributes is the change of the mode and the owner of the path of a CHMOD event, nil when the path was gone or
+	// the watcher sheds load.
+	Attributes *AttributeChange
+	// Bulk is the progress of the bulk operation of the BULK_OPERATION events, see the Bulk option.
+	Bulk *BulkProgress
+	// Tags is metadata about the event, like the project its path belongs to, set from the tags of the watched paths,
+	// see AddWithTags, and by middlewares, see Use.
+	Tags map[string]string
+	// Time is when the event was emitted.
+	Time time.Time
+	// Seq is the position of the event in the stream of events emitted by the watcher, starting at one.
+	Seq uint64
+	// Key is a deterministic idempotency key built from Seq and the identity of the watcher, see Idempotent.
+	Key string
+	// ID identifies the event. It is Key unless the IDGenerator option is set.
+	ID string
+	// Trace is the tracing context of the operation the event is part of, see BeginOperation.
+	Trace TraceContext
+	// Info is the state of the file or folder when the event was emitted, so consumers don't race with later changes
+	// by calling Stat themselves. It is nil for deletes, when the path was gone already, with DisableEventInfo and
+	// unless the StatPolicy of the path is StatEager, see the Stat method.
+	Info os.FileInfo
+	// Host, WatcherID and PID identify the watcher that emitted the event when the Identity option is set.
+	Host      string
+	WatcherID string
+	PID       int
+
+	// lazy stats the path on demand with StatLazy.
+	lazy *lazyInfo
 }
 
-func (e FileWatcherEvent) RenameFolderEvent() string {
-	return "RENAME_FOLDER"
+// Deprecated: use EventRenameFolder.
+func (e FileWatcherEvent) RenameFolderEvent() EventKind {
+	return EventRenameFolder
 }
 
+// Deprecated: compare e.Event with EventRenameFolder.
 func (e FileWatcherEvent) IsRenameFolderEvent() bool {
-	return e.Event == e.RenameFolderEvent()
+	return e.Event == EventRenameFolder
 }
 
-func (e FileWatcherEvent) DeleteFolderEvent() string {
-	return "DELETE_FOLDER"
+// Deprecated: use EventDeleteFolder.
+func (e FileWatcherEvent) DeleteFolderEvent() EventKind {
+	return EventDeleteFolder
 }
 
+// Deprecated: compare e.Event with EventDeleteFolder.
 func (e FileWatcherEvent) IsDeleteFolderEvent() bool {
-	return e.Event == e.DeleteFolderEvent()
+	return e.Event == EventDeleteFolder
 }
 
-func (e FileWatcherEvent) CreateFolderEvent() string {
-	return "CREATE_FOLDER"
+// Deprecated: use EventCreateFolder.
+func (e FileWatcherEvent) CreateFolderEvent() EventKind {
+	return EventCreateFolder
 }
 
+// Deprecated: compare e.Event with EventCreateFolder.
 func (e FileWatcherEvent) IsCreateFolderEvent() bool {
-	return e.Event == e.CreateFolderEvent()
+	return e.Event == EventCreateFolder
 }
 
-func (e FileWatcherEvent) CreateFileEvent() string {
-	return "CREATE_FILE"
+// Deprecated: use EventCreateFile.
+func (e FileWatcherEvent) CreateFileEvent() EventKind {
+	return EventCreateFile
 }
 
+// Deprecated: compare e.Event with EventCreateFile.
 func (e FileWatcherEvent) IsCreateFileEvent() bool {
-	return e.Event == e.CreateFileEvent()
+	return e.Event == EventCreateFile
 }
 
-func (e FileWatcherEvent) DeleteFileEvent() string {
-	return "DELETE_FILE"
+// Deprecated: use EventDeleteFile.
+func (e FileWatcherEvent) DeleteFileEvent() EventKind {
+	return EventDeleteFile
 }
 
+// Deprecated: compare e.Event with EventDeleteFile.
 func (e FileWatcherEvent) IsDeleteFileEvent() bool {
-	return e.Event == e.DeleteFileEvent()
+	return e.Event == EventDeleteFile
 }
 
-func (e FileWatcherEvent) RenameFileEvent() string {
-	return "RENAME_FILE"
+// Deprecated: use EventRenameFile.
+func (e FileWatcherEvent) RenameFileEvent() EventKind {
+	return EventRenameFile
 }
 
+// Deprecated: compare e.Event with EventRenameFile.
 func (e FileWatcherEvent) IsRenameFileEvent() bool {
-	return e.Event == e.RenameFileEvent()
+	return e.Event == EventRenameFile
 }
 
-func (e FileWatcherEvent) EditFileEvent() string {
-	return "EDIT_FILE"
+// Deprecated: use EventEditFile.
+func (e FileWatcherEvent) EditFileEvent() EventKind {
+	return EventEditFile
 }
 
+// Deprecated: compare e.Event with EventEditFile.
 func (e FileWatcherEvent) IsEditFileEvent() bool {
-	return e.Event == e.EditFileEvent()
+	return e.Event == EventEditFile
 }
 
-func (e FileWatcherEvent) ChModEvent() string {
-	return "CHMOD"
+// Deprecated: use EventChMod.
+func (e FileWatcherEvent) ChModEvent() EventKind {
+	return EventChMod
 }
 
+// Deprecated: compare e.Event with EventChMod.
 func (e FileWatcherEvent) IsChModEvent() bool {
-	return e.Event == e.ChModEvent()
+	return e.Event == EventChMod
 }
 
-func Init(done chan bool, newFs afero.Fs, l Logger) (*FileWatcher, error) {
-	SetLogger(l)
-	SetFs(newFs)
+// Init creates a FileWatcher using the filesystem newFs and the logger l, the ones set with SetFs and SetLogger when
+// they are nil. The first watcher also provides them to the helpers not tied to a watcher when they were not set.
+func Init(done chan bool, newFs afero.Fs, l Logger, opts ...Option) (*FileWatcher, error) {
+	if l == nil {
+		l = log
+	}
+	if newFs == nil {
+		newFs = fs
+	}
+	if log == nil {
+		log = l
+	}
+	if fs == nil {
+		fs = newFs
+	}
 	// concurrent map: https://github.com/orcaman/concurrent-map
 	wMap := cmap.New[string]()
-	fsWatcher, err := fsnotify.NewWatcher()
+	fsWatcher, err := newNotifier()
 	if err != nil {
 		return nil, err
 	}
 
 	res := FileWatcher{}
+	res.options = buildOptions(opts)
+	res.trackTimers()
+	res.fs = newFs
+	res.log = l
+	res.ignores = newPatternSet()
+	res.ignoreFiles.isDir = res.knownDir
+	if err := res.ignores.add(DefaultExcludePatterns...); err != nil {
+		_ = fsWatcher.Close()
+		return nil, err
+	}
+	res.includes = newPatternSet()
+	if err := validateRules(res.options.ClassificationRules); err != nil {
+		_ = fsWatcher.Close()
+		return nil, err
+	}
+	if len(res.options.AtomicSavePatterns) > 0 {
+		res.atomicSave.temporary = newPatternSet()
+		if err := res.atomicSave.temporary.add(res.options.AtomicSavePatterns...); err != nil {
+			_ = fsWatcher.Close()
+			return nil, err
+		}
+	}
+	res.tracer = newTracer(res.options.DebugTrace)
+	res.history = newHistory(res.options.HistorySize, res.options.HistoryRetention, res.clock())
+	res.epoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+	res.instance = strconv.FormatUint(atomic.AddUint64(&watcherInstances, 1), 10)
 	res.Watcher = fsWatcher
+	res.notifier = res.newNotifier()
 	res.WatchedMap = wMap
-	res.Errors = make(chan error)
-	res.Events = make(chan FileWatcherEvent)
+	res.Errors = make(chan error, res.errorBuffer())
+	res.Events = make(chan FileWatcherEvent, res.options.EventBuffer)
+	res.ChangeSets = make(chan *ChangeSet)
+	res.Batches = make(chan *FileWatcherBatch)
+	res.Priority = make(chan FileWatcherEvent)
+	res.health.recreate = make(chan error, 1)
+	res.life.init()
+	if err := res.loadWarmCache(); err != nil {
+		res.log.Warn("Unable to load the warm cache: ", err)
+	}
 
+	res.startDispatcher()
+	res.startClassifier()
+	res.startLatencyProbe()
+	res.startLoadMonitor()
+	res.startStateGC()
+	res.startHealthCheck()
+	res.startLeaderElection()
 	go res.watchFileChangeEvents(done)
 
 	return &res, nil
 }
 
+// emit hands a classified event to the consumers. The state the classification depends on is updated right away, the
+// rest of the work happens in dispatch, possibly on a dispatch worker.
+func (w *FileWatcher) emit(e FileWatcherEvent) {
+	if w.Paused() || !w.Leading() {
+		w.trace(e.Path, "%s while paused or following another leader, nothing emitted", e.Event)
+		// the watches of the recursive trees still follow the folders
+		w.listings.apply(e)
+		w.followRecursive(e)
+		return
+	}
+	if trashed := classifyTrash(e); trashed.Event != e.Event {
+		w.trace(e.Path, "%s of %s is a move through the trash, emitting %s", e.Event, e.PreviousPath, trashed.Event)
+		e = trashed
+	}
+	e, keep := w.classifyDownload(e)
+	if !keep {
+		w.trace(e.Path, "part of a download in progress, nothing emitted")
+		return
+	}
+	e, keep = w.classifyAtomicSave(e)
+	if !keep {
+		return
+	}
+	if !w.trackExtraction(e) {
+		w.trace(e.Path, "part of an extraction, nothing emitted")
+		// folders extracted below a recursive root still have to be watched
+		w.listings.apply(e)
+		w.followRecursive(e)
+		return
+	}
+	if !w.trackBulk(e) {
+		w.trace(e.Path, "part of a bulk operation, nothing emitted")
+		w.listings.apply(e)
+		w.followRecursive(e)
+		return
+	}
+	// an event may change a file keeping its size and modification time, within the precision of the filesystem
+	w.fingerprints.invalidate(e.Path, e.PreviousPath)
+	if !w.contentChanged(e) {
+		w.trace(e.Path, "content unchanged, nothing emitted")
+		return
+	}
+	w.tag(&e)
+	w.runMiddlewares(e, w.publish)
+	w.statCache.invalidate(e.Path, e.PreviousPath)
+	w.listings.apply(e)
+	w.followRecursive(e)
+}
+
+// publish numbers and stamps e, an event out of the middlewares, and hands it to the delivery.
+func (w *FileWatcher) publish(e FileWatcherEvent) {
+	if w.options.OrderedDelivery {
+		// the events are numbered and handed on at once, so they reach the dispatch in the order of their Seq
+		w.ordering.publishing.Lock()
+		defer w.ordering.publishing.Unlock()
+	}
+	e.Time = w.clock().Now()
+	e.Seq = atomic.AddUint64(&w.seq, 1)
+	w.counters.countEmitted(e.Event)
+	w.stamp(&e)
+	e.Key = w.idempotencyKey(e.Seq)
+	w.assignID(&e)
+	if w.history != nil {
+		w.history.append(e)
+	}
+	w.markDirty(e)
+	w.traceEvent(e, "emitted %s", e.Event)
+	w.instrumentEvent(TraceEmitted, TraceLevelInfo, e, "")
+	w.fillInfo(&e)
+	w.fillAttributes(&e)
+	w.sequence(w.linkPaths(e))
+}
+
+// dispatch delivers a classified event.
+func (w *FileWatcher) dispatch(e FileWatcherEvent) {
+	unlock := w.lockOrder(e)
+	defer unlock()
+	if !w.checkStable(&e, w.dispatchStable) {
+		return
+	}
+	w.dispatchStable(e)
+}
+
+// dispatchStable delivers a classified event that passed the stability wait.
+func (w *FileWatcher) dispatchStable(e FileWatcherEvent) {
+	if !w.checkLock(&e, w.dispatchReady) {
+		return
+	}
+	w.dispatchReady(e)
+}
+
+// dispatchReady delivers a classified event that passed the lock check.
+func (w *FileWatcher) dispatchReady(e FileWatcherEvent) {
+	if !w.checkQuarantine(&e) {
+		return
+	}
+	if !w.rateLimit(&e) {
+		return
+	}
+	w.dispatchLimited(e)
+}
+
+// dispatchLimited delivers a classified event that passed the rate limit.
+func (w *FileWatcher) dispatchLimited(e FileWatcherEvent) {
+	if interval := w.debounceInterval(); interval > 0 && !w.prioritized(e) {
+		w.debounce(e, interval)
+		return
+	}
+	w.dispatchSettled(e)
+}
+
+// dispatchSettled delivers a classified event once it passed the lock check and the debouncing.
+func (w *FileWatcher) dispatchSettled(e FileWatcherEvent) {
+	w.labeled("dispatch", e.Path, func() {
+		w.recordChangeSet(e)
+		if window := w.coalesceWindow(); window > 0 && !w.prioritized(e) {
+			w.traceEvent(e, "held for coalescing during %s", window)
+			w.coalesce(e, window)
+			return
+		}
+		w.deliver(e)
+		w.traceEvent(e, "delivered %s", e.Event)
+	})
+}
+
 func resetStack(s []fsnotify.Event) {
 	s[0] = fsnotify.Event{}
 	s[1] = fsnotify.Event{}
@@ -144,8 +465,8 @@ func resetStack(s []fsnotify.Event) {
 // Delete a folder - cache: [remove|rename, empty] - single event, clear cache
 // REMOVE|RENAME - removed folder path
 
-// Delete a file - cache: [rename, empty] - single event, clear cache
-// RENAME - removed file path
+// Move a file out - cache: [rename, empty] - single event, clear cache
+// RENAME - moved file path
 
 // Rename a folder - cache: [remove|rename, create] - double event, clear cache
 // CREATE - has the path of the renamed folder
@@ -161,177 +482,319 @@ func resetStack(s []fsnotify.Event) {
 // Edit a file - cache: [create, remove] - double event, clear cache
 // REMOVE - has the path of the file being edited
 // CREATE - has the path of the file being edited
+//
+// The changes inotify reports explicitly, the writes of files rewritten in place and the removes, are classified first
+// by classifyExplicit, and the events the platforms report differently, like the explicit renames of
+// ReadDirectoryChangesW, by classifyPlatform. The pairs are made by the classification rules, see
+// DefaultClassificationRules.
 func (w *FileWatcher) watchFileChangeEvents(done chan bool) {
+	w.labelGoroutine("event-loop")
 	eventsList := make([]fsnotify.Event, 2)
+	// arrived holds when the events of eventsList arrived
+	arrived := make([]time.Time, 2)
 	onlyCreateEvent := false
-	delayChan := make(chan bool)
-	e := FileWatcherEvent{}
+	rules := w.classificationRules()
+	// a single timer is reused for every create instead of a goroutine per event
+	delay := w.clock().NewTimer()
+	classifyDelay := w.createClassifyDelay()
+	rescan, stopRescan := w.rescanTicker()
+	platform := w.newPlatformClassifier()
+	events, errs := w.currentNotifier().notifications()
+	defer stopRescan()
 
 	for {
 		select {
-		case event := <-w.Watcher.Events:
+		case event, ok := <-events:
+			if !ok {
+				events, errs = nil, nil
+				w.notifierDead(ErrNotifierClosed)
+				break
+			}
+			event.Name = normalizePath(event.Name)
+			if isSentinel(event.Name) {
+				w.sentinelSeen(event.Name)
+				break
+			}
+			if w.scopes.outOfScope(event.Name) {
+				break
+			}
+			w.traceRaw(event)
+			w.reloadIgnoreFile(event.Name)
+
+			if reason, ok := w.ignoreReason(event.Name); ok {
+				w.trace(event.Name, "ignored by %s", reason)
+				break
+			}
+
+			w.statCache.invalidate(event.Name)
 
-			if strings.Index(event.Name, ".DS_Store") > 0 {
+			if w.handleSQLite(event) {
+				w.trace(event.Name, "consumed by the SQLite watcher")
 				break
 			}
 
 			if event.Has(fsnotify.Chmod) {
+				if w.suppressChmod(event.Name) {
+					w.trace(event.Name, "chmod suppressed by the Chmod option")
+					break
+				}
 				// send chmod events along down the chain right away
-				e.Event = e.ChModEvent()
-				e.Path = event.Name
-				w.Events <- e
+				w.classifyEmit(FileWatcherEvent{Event: EventChMod, Path: event.Name})
+				break
+			}
+
+			pendingCreate := ""
+			if onlyCreateEvent {
+				pendingCreate = eventsList[0].Name
+			}
+			if w.classifyExplicit(event, pendingCreate) || w.classifyPlatform(platform, event, pendingCreate) {
 				break
 			}
 
+			if onlyCreateEvent && event.Has(fsnotify.Create) && event.Name != eventsList[0].Name {
+				// another item is being created, the pending one can't be part of a pair anymore. Classify it now
+				// instead of losing it, bulk copies produce long series of creates.
+				w.trace(eventsList[0].Name, "create of %s arrived, classifying the pending create", event.Name)
+				w.classifyPendingCreate(eventsList[0].Name)
+				resetStack(eventsList)
+				onlyCreateEvent = false
+			}
+
 			// move first entry to last spot
 			eventsList[1] = eventsList[0]
+			arrived[1] = arrived[0]
 			// copy current event to first spot
 			eventsList[0] = event
+			arrived[0] = w.clock().Now()
 
 			if !eventsList[0].Has(fsnotify.Create) {
 				onlyCreateEvent = false
 			}
 
-			deleteFolder := eventsList[0].Has(fsnotify.Rename) && eventsList[0].Has(fsnotify.Remove)
-			deleteFile := eventsList[0].Has(fsnotify.Rename) && !eventsList[0].Has(fsnotify.Remove)
-			renameFolder := eventsList[0].Has(fsnotify.Rename) && eventsList[0].Has(fsnotify.Remove) && eventsList[1].Has(fsnotify.Create)
-			renameFile := eventsList[0].Has(fsnotify.Rename) && eventsList[1].Has(fsnotify.Create)
-			editFile := eventsList[0].Has(fsnotify.Create) && eventsList[1].Has(fsnotify.Remove)
-			rapidDelete := eventsList[0].Has(fsnotify.Remove) && eventsList[1].Has(fsnotify.Create)
-
-			if renameFolder {
-				e.Event = e.RenameFolderEvent()
-				e.Path = eventsList[1].Name
-				e.PreviousPath = eventsList[0].Name
-				w.Events <- e
-				resetStack(eventsList)
-			} else if renameFile {
-				e.Event = e.RenameFileEvent()
-				e.Path = eventsList[1].Name
-				e.PreviousPath = eventsList[0].Name
-				w.Events <- e
-				resetStack(eventsList)
-			} else if editFile {
-				e.Event = e.EditFileEvent()
-				e.Path = eventsList[0].Name
-				e.PreviousPath = ""
-				w.Events <- e
-				resetStack(eventsList)
-			} else if rapidDelete {
-				if eventsList[0].Name == eventsList[1].Name {
-					log.Debug("File " + eventsList[0].Name + "Was rapidly created and then removed")
-				} else {
-					log.Warn("Unexpected series of events: ", eventsList)
-				}
-
-				resetStack(eventsList)
-			} else if deleteFolder {
-				e.Event = e.DeleteFolderEvent()
-				e.Path = eventsList[0].Name
-				e.PreviousPath = ""
-				w.Events <- e
-				resetStack(eventsList)
-			} else if deleteFile {
-				e.Event = e.DeleteFileEvent()
-				e.Path = eventsList[0].Name
-				e.PreviousPath = ""
-				w.Events <- e
+			rule, matched := matchRule(rules, eventsList[0], eventsList[1], arrived[0].Sub(arrived[1]))
+			if matched {
+				w.applyRule(rule, eventsList[0], eventsList[1])
 				resetStack(eventsList)
+				onlyCreateEvent = false
 			} else if eventsList[0].Has(fsnotify.Create) {
 				onlyCreateEvent = true
-				go eventDelay(delayChan)
+				w.trace(eventsList[0].Name, "create waiting %s for a second event", classifyDelay)
+				stopTimer(delay)
+				delay.Reset(classifyDelay)
 			} else if eventsList[0].Has(fsnotify.Remove) && !eventsList[0].Has(fsnotify.Rename) {
 				// do nothing
+				w.trace(event.Name, "remove without rename, nothing emitted")
 			} else {
-				log.Warn("Unknown event " + event.String())
+				w.trace(event.Name, "unknown series of events, nothing emitted")
+				w.log.Warn("Unknown event " + event.String())
+				w.watchError(ErrClassification, event.Name, errors.New("unknown series of events ending with "+
+					event.Op.String()))
 			}
-		case <-delayChan:
+		case <-delay.C():
 			// special create event handling
 			if onlyCreateEvent {
-				fileInfo, err := os.Stat(eventsList[0].Name)
-				if os.IsNotExist(err) {
-					log.Error("File " + eventsList[0].Name + " is missing")
-				}
-
-				if fileInfo.IsDir() {
-					e.Event = e.CreateFolderEvent()
-				} else {
-					e.Event = e.CreateFileEvent()
-				}
-
-				e.Path = eventsList[0].Name
-				e.PreviousPath = ""
+				w.classifyPendingCreate(eventsList[0].Name)
 				resetStack(eventsList)
-				w.Events <- e
 				onlyCreateEvent = false
 			}
-		case err := <-w.Watcher.Errors:
-			w.Errors <- err
-		case <-done:
-			err := w.Close()
-			if err != nil {
-				_ = fmt.Errorf(err.Error())
+		case <-platform.expired():
+			w.flushPlatform(platform)
+		case <-rescan:
+			w.rescanListings()
+		case err, ok := <-errs:
+			if !ok {
+				events, errs = nil, nil
+				w.notifierDead(ErrNotifierClosed)
+				break
 			}
+			w.notifyError(err)
+		case reason := <-w.health.recreate:
+			events, errs = w.recreateNotifier(reason)
+		case <-done:
+			w.shutdown()
+			return
+		case <-w.life.stopping:
+			w.shutdown()
 			return
 		}
 	}
 }
 
-func eventDelay(channel chan bool) {
-	log.Trace("eventDelay() function starting")
-	// 125 milliseconds because it's still a pretty long delay from the computers' perspective, but
-	// barely noticeable from a human perspective.
-	time.Sleep(time.Millisecond * 125)
-	channel <- true
+// defaultCreateClassifyDelay is how long a create event waits for a second event before being classified on its own.
+// 125 milliseconds because it's still a pretty long delay from the computers' perspective, but
+// barely noticeable from a human perspective.
+const defaultCreateClassifyDelay = time.Millisecond * 125
+
+// createClassifyDelay returns how long a create event waits for a second event, see the CreateClassifyDelay option.
+func (w *FileWatcher) createClassifyDelay() time.Duration {
+	if w.options.CreateClassifyDelay > 0 {
+		return w.options.CreateClassifyDelay
+	}
+	return defaultCreateClassifyDelay
+}
+
+// classifyEmit emits e, a fresh event paired by the event loop, from the classification worker of its path.
+func (w *FileWatcher) classifyEmit(e FileWatcherEvent) {
+	w.classify(e.Path, e.PreviousPath, func() {
+		w.emit(e)
+	})
+}
+
+// classifyPendingCreate classifies the create of path from the classification worker of path.
+func (w *FileWatcher) classifyPendingCreate(path string) {
+	w.classify(path, "", func() {
+		w.classifyCreate(path)
+	})
+}
+
+// classifyCreate emits the event of a create that wasn't paired with another event.
+func (w *FileWatcher) classifyCreate(path string) {
+	fileInfo, err := w.Stat(path)
+	if err != nil {
+		w.trace(path, "create not paired, stat failed: %v", err)
+		w.log.Error("File " + path + " is missing")
+		if !os.IsNotExist(err) {
+			w.watchError(ErrStatFailed, path, err)
+		}
+		return
+	}
+	w.trace(path, "create not paired, classified from stat")
+
+	e := FileWatcherEvent{Path: path}
+	if fileInfo.IsDir() {
+		e.Event = EventCreateFolder
+	} else if w.existedBefore(path) {
+		// the file was replaced, for example by an atomic save
+		e.Event = EventEditFile
+	} else {
+		e.Event = EventCreateFile
+	}
+	w.emit(classifyMoveIn(w.pairCreate(e, fileInfo), fileInfo))
 }
 
 func (w *FileWatcher) Add(path string) error {
+	path = w.normalize(path)
+	unlock := w.pathLocks.lock(path)
+	_, alreadyWatching := w.WatchedMap.Get(path)
+	err := w.add(path)
+	unlock()
+	if err != nil {
+		return err
+	}
+	if !alreadyWatching {
+		w.announceWatch(path, EventWatchAdded)
+	}
+	if !alreadyWatching && w.options.EmitExisting {
+		w.scanExisting(path)
+	}
+	return nil
+}
+
+func (w *FileWatcher) add(path string) error {
 	_, alreadyWatching := w.WatchedMap.Get(path)
 	if !alreadyWatching {
-		fileInfo, err := os.Stat(path)
+		fileInfo, err := w.fs.Stat(path)
 
-		if os.IsNotExist(err) {
+		if err != nil {
 			return err
 		}
 
+		if w.shouldPoll(path) {
+			// change notifications are unreliable on network shares
+			w.WatchedMap.Set(path, path)
+			if fileInfo.IsDir() {
+				if err := w.listings.scan(w.fs, path); err != nil {
+					w.log.Warn("Unable to list ", path, ": ", err)
+				}
+			}
+			return w.addPolled(path)
+		}
+
 		if fileInfo.IsDir() {
 			// watch the directory
 			w.WatchedMap.Set(path, path)
-			return w.Watcher.Add(path)
+			if err := w.listings.scan(w.fs, path); err != nil {
+				w.log.Warn("Unable to list ", path, ": ", err)
+			}
+			w.importIgnoreFiles(path)
+			if err := w.currentNotifier().Add(path); err != nil {
+				w.WatchedMap.Remove(path)
+				if err := w.overWatchLimit(path, err); err != nil {
+					w.listings.forget(path)
+					return err
+				}
+				return nil
+			}
+			w.addDir(path)
+			return nil
+		} else if w.pollsFile() {
+			w.trace(path, "over the kqueue budget, polling the file")
+			w.WatchedMap.Set(path, path)
+			return w.addPolled(path)
 		} else {
-			// check if we are already watching the directory the file is in
-			directory := filepath.Dir(path)
-			_, watchingContainingDir := w.WatchedMap.Get(directory)
-
-			if !watchingContainingDir {
-				// not watching the directory the file is in, watch the file itself.
-				w.WatchedMap.Set(path, path)
-				return w.Watcher.Add(path)
+			// the file is recorded even when its directory is watched, so it stays watched when the directory is
+			// removed
+			if err := w.addFile(path); err != nil {
+				return w.overWatchLimit(path, err)
 			}
+			return nil
 		}
 	}
 	return nil
 }
 
+// Remove stops watching path. The files of a directory added individually stay watched unless the PruneOnRemove
+// option is set, and so do the directories below it, see RemoveRecursive.
 func (w *FileWatcher) Remove(path string) error {
+	path = w.normalize(path)
+	if w.options.PruneOnRemove {
+		w.pruneFiles(path)
+	}
+	unlock := w.pathLocks.lock(path)
+	removed, err := w.remove(path)
+	unlock()
+	if removed {
+		w.announceWatch(path, EventWatchRemoved)
+	}
+	return err
+}
+
+// remove stops watching path and reports whether it was watched.
+func (w *FileWatcher) remove(path string) (bool, error) {
+	w.forgetPendingRoot(path)
 	_, ok := w.WatchedMap.Get(path)
 	if ok {
-		err := w.Watcher.Remove(path)
+		if !w.removePolled(path) {
+			var err error
+			if w.isFileWatch(path) {
+				err = w.removeFile(path)
+			} else {
+				err = w.removeDir(path)
+			}
 
-		if err != nil {
-			return err
+			if err != nil {
+				return false, err
+			}
 		}
 
-		w.WatchedMap.Remove(path)
+		w.forgetWatch(path)
 	}
-	return nil
+	return ok, nil
 }
 
-func (w *FileWatcher) Contains(path string) bool {
-	_, ok := w.WatchedMap.Get(path)
-	return ok
+// forgetWatch drops what is recorded about the watch of path.
+func (w *FileWatcher) forgetWatch(path string) {
+	w.WatchedMap.Remove(path)
+	w.listings.forget(path)
+	w.removeAccess(path)
+	w.forgetStatPolicy(path)
+	w.forgetPriority(path)
+	w.forgetTags(path)
+	w.forgetMatcher(path)
+	w.forgetOwners(path)
 }
 
-func (w *FileWatcher) Close() error {
-	return w.Watcher.Close()
+func (w *FileWatcher) Contains(path string) bool {
+	_, ok := w.WatchedMap.Get(w.normalize(path))
+	return ok
 }
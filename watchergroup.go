@@ -0,0 +1,269 @@
+package fileWatcher
+
+import (
+	"errors"
+	"github.com/spf13/afero"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrGroupClosed is returned by WatcherGroup.AddRoot once the group is closed.
+var ErrGroupClosed = errors.New("the watcher group is closed")
+
+// RootError is an error of the watcher of a root of a WatcherGroup.
+type RootError struct {
+	Root string
+	Err  error
+}
+
+func (e *RootError) Error() string {
+	return e.Root + ": " + e.Err.Error()
+}
+
+func (e *RootError) Unwrap() error {
+	return e.Err
+}
+
+// RootStatus is the state of a root of a WatcherGroup.
+type RootStatus struct {
+	Root    string
+	Watcher *FileWatcher
+	Added   time.Time
+	// Watches is the number of paths the watcher of the root watches.
+	Watches int
+	// Events and Errors count what the watcher of the root delivered to the group.
+	Events    uint64
+	Errors    uint64
+	LastError error
+}
+
+// groupRoot is a root of a WatcherGroup and its watcher.
+type groupRoot struct {
+	path   string
+	w      *FileWatcher
+	added  time.Time
+	events uint64
+	errs   uint64
+	mu     sync.Mutex
+	last   error
+	// done is closed once the events and errors of the watcher are forwarded.
+	done chan struct{}
+}
+
+// WatcherGroup manages a watcher per root, like one per project directory, per mount point or per backend, behind a
+// single pair of Events and Errors channels. Roots are added and removed while the group runs, each with its own
+// options on top of the options of the group.
+type WatcherGroup struct {
+	// Events receives the events of every root.
+	Events chan FileWatcherEvent
+	// Errors receives the errors of every root, as RootError.
+	Errors chan error
+
+	fs      afero.Fs
+	log     Logger
+	options []Option
+
+	mu      sync.Mutex
+	roots   map[string]*groupRoot
+	closed  bool
+	closing chan struct{}
+	// forwarders counts the goroutines forwarding the events of the roots, including the roots being removed.
+	forwarders sync.WaitGroup
+}
+
+// NewWatcherGroup returns a group creating the watchers of its roots on newFs with l and options, see Init.
+func NewWatcherGroup(newFs afero.Fs, l Logger, options ...Option) *WatcherGroup {
+	if l == nil {
+		l = log
+	}
+	return &WatcherGroup{
+		Events:  make(chan FileWatcherEvent),
+		Errors:  make(chan error),
+		fs:      newFs,
+		log:     l,
+		options: options,
+		roots:   make(map[string]*groupRoot),
+		closing: make(chan struct{}),
+	}
+}
+
+// AddRoot watches the directory tree at root, see FileWatcher.AddRecursive, with a watcher of its own created with
+// the options of the group followed by options. The group reads the Events, Errors and Priority of the watcher, the
+// options delivering events elsewhere, like BatchQuietPeriod, must not be used. Adding a root twice does nothing.
+func (g *WatcherGroup) AddRoot(root string, options ...Option) error {
+	root = normalizePath(root)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return ErrGroupClosed
+	}
+	if _, ok := g.roots[root]; ok {
+		return nil
+	}
+
+	opts := append(append([]Option{}, g.options...), options...)
+	w, err := Init(nil, g.fs, g.log, opts...)
+	if err != nil {
+		return err
+	}
+	if err := w.AddRecursive(root); err != nil {
+		_ = w.Close()
+		return err
+	}
+	r := &groupRoot{path: root, w: w, added: time.Now(), done: make(chan struct{})}
+	g.roots[root] = r
+	g.forwarders.Add(1)
+	go g.forward(r)
+	return nil
+}
+
+// RemoveRoot stops watching root and closes its watcher. The events the watcher held back are delivered first.
+func (g *WatcherGroup) RemoveRoot(root string) error {
+	root = normalizePath(root)
+	g.mu.Lock()
+	r, ok := g.roots[root]
+	delete(g.roots, root)
+	g.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	err := r.w.Close()
+	<-r.done
+	return err
+}
+
+// Roots returns the roots of the group, sorted.
+func (g *WatcherGroup) Roots() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	roots := make([]string, 0, len(g.roots))
+	for root := range g.roots {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// Watcher returns the watcher of root.
+func (g *WatcherGroup) Watcher(root string) (*FileWatcher, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r, ok := g.roots[normalizePath(root)]
+	if !ok {
+		return nil, false
+	}
+	return r.w, true
+}
+
+// Status returns the state of the roots of the group, sorted by root.
+func (g *WatcherGroup) Status() []RootStatus {
+	g.mu.Lock()
+	roots := make([]*groupRoot, 0, len(g.roots))
+	for _, r := range g.roots {
+		roots = append(roots, r)
+	}
+	g.mu.Unlock()
+
+	res := make([]RootStatus, 0, len(roots))
+	for _, r := range roots {
+		r.mu.Lock()
+		last := r.last
+		r.mu.Unlock()
+		res = append(res, RootStatus{
+			Root:      r.path,
+			Watcher:   r.w,
+			Added:     r.added,
+			Watches:   r.w.Len(),
+			Events:    atomic.LoadUint64(&r.events),
+			Errors:    atomic.LoadUint64(&r.errs),
+			LastError: last,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Root < res[j].Root
+	})
+	return res
+}
+
+// Close closes the watchers of every root, then Events and Errors. The events not received within the CloseTimeout
+// of the watchers are dropped.
+func (g *WatcherGroup) Close() error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil
+	}
+	g.closed = true
+	roots := g.roots
+	g.roots = make(map[string]*groupRoot)
+	g.mu.Unlock()
+
+	var res error
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	for _, r := range roots {
+		wg.Add(1)
+		go func(r *groupRoot) {
+			defer wg.Done()
+			if err := r.w.Close(); err != nil {
+				mu.Lock()
+				res = err
+				mu.Unlock()
+			}
+		}(r)
+	}
+	wg.Wait()
+	// a consumer no longer receiving doesn't hold back the close past the timeout of the watchers
+	close(g.closing)
+	g.forwarders.Wait()
+	close(g.Events)
+	close(g.Errors)
+	return res
+}
+
+// forward hands the events and the errors of the watcher of r to the group until the watcher is closed.
+func (g *WatcherGroup) forward(r *groupRoot) {
+	r.w.labelGoroutine("watcher-group")
+	defer g.forwarders.Done()
+	defer close(r.done)
+	events, errs, priority := r.w.Events, r.w.Errors, r.w.Priority
+	for events != nil || errs != nil || priority != nil {
+		select {
+		case e, ok := <-priority:
+			if !ok {
+				priority = nil
+				continue
+			}
+			select {
+			case g.Events <- e:
+				atomic.AddUint64(&r.events, 1)
+			case <-g.closing:
+			}
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			select {
+			case g.Events <- e:
+				atomic.AddUint64(&r.events, 1)
+			case <-g.closing:
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			r.mu.Lock()
+			r.last = err
+			r.mu.Unlock()
+			atomic.AddUint64(&r.errs, 1)
+			select {
+			case g.Errors <- &RootError{Root: r.path, Err: err}:
+			case <-g.closing:
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package fileWatcher
+
+import (
+	"crypto/sha256"
+	"errors"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// watchFileQuiet is how long WatchFile waits for the changes of a file to settle before reading it.
+const watchFileQuiet = 100 * time.Millisecond
+
+// fileReload is a file watched with WatchFile.
+type fileReload struct {
+	w        *FileWatcher
+	path     string
+	onChange func(path string, content []byte) error
+	// hash is the hash of the content last handed to onChange successfully.
+	hash   [sha256.Size]byte
+	loaded bool
+	// symlink is set when path was a symlink on the last reload, like the files of a Kubernetes ConfigMap, whose
+	// target changes without any event on path itself.
+	symlink bool
+	done    chan struct{}
+}
+
+// WatchFile calls onChange with the content of the file at path now, when it exists, and every time its content
+// changes, for the common case of reloading a configuration file. It watches the folder of the file, so the atomic
+// saves of the editors, renaming a temporary file over it, and the files deleted and created again are followed. The
+// changes are read once they settled, and onChange is only called when the content differs from the content it last
+// accepted: a content it returned an error for is handed again on the next change.
+//
+// The error of the first call of onChange is returned. The later errors are logged, the application keeps running on
+// the last content it accepted. The returned function stops watching the file.
+//
+// The events of the file are received through a subscription, see Subscribe: the application keeps reading Events,
+// which still carries them.
+func (w *FileWatcher) WatchFile(path string, onChange func(path string, content []byte) error) (func(), error) {
+	path = w.normalize(path)
+	dir := filepath.Dir(path)
+	added := false
+	if !w.Contains(dir) {
+		if err := w.Add(dir); err != nil {
+			return nil, err
+		}
+		added = true
+	}
+
+	f := &fileReload{w: w, path: path, onChange: onChange, done: make(chan struct{})}
+	events, unsubscribe := w.Subscribe(dir)
+	stop := func() {
+		unsubscribe()
+		<-f.done
+		if added {
+			if err := w.Remove(dir); err != nil {
+				w.log.Warn("Unable to stop watching ", dir, ": ", err)
+			}
+		}
+	}
+	if err := f.reload(); err != nil {
+		go f.run(events)
+		stop()
+		return nil, err
+	}
+	go f.run(events)
+
+	once := sync.Once{}
+	return func() {
+		once.Do(stop)
+	}, nil
+}
+
+// run reloads the file once the events touching it settled, until the subscription ends.
+func (f *fileReload) run(events <-chan FileWatcherEvent) {
+	f.w.labelGoroutine("watch-file")
+	defer close(f.done)
+	timer := f.w.clock().NewTimer()
+	defer timer.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if f.symlink || e.Path == f.path || e.PreviousPath == f.path {
+				stopTimer(timer)
+				timer.Reset(watchFileQuiet)
+			}
+		case <-timer.C():
+			if err := f.reload(); err != nil {
+				f.w.log.Warn("Unable to reload ", f.path, ": ", err)
+			}
+		}
+	}
+}
+
+// reload hands the content of the file to onChange when it changed. A missing file is waited for.
+func (f *fileReload) reload() error {
+	f.symlink = f.w.isSymlink(f.path)
+	content, err := afero.ReadFile(f.w.fs, f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		f.w.log.Debug("Waiting for ", f.path, " to be created")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(content)
+	if f.loaded && hash == f.hash {
+		return nil
+	}
+	if err := f.onChange(f.path, content); err != nil {
+		return err
+	}
+	f.hash = hash
+	f.loaded = true
+	return nil
+}
@@ -0,0 +1,45 @@
+package fileWatcher
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchFileLeavesEvents(t *testing.T) {
+	root := t.TempDir()
+	config := filepath.Join(root, "config.json")
+	writeFile(t, config, "{}")
+	w := newTestWatcher(t)
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+	reloads := make(chan string, 16)
+	stop, err := w.WatchFile(config, func(path string, content []byte) error {
+		reloads <- string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	<-reloads
+
+	other := filepath.Join(root, "other")
+	writeFile(t, other, "")
+	writeFile(t, config, `{"level":"debug"}`)
+	// the application reads Events meanwhile, and gets the events of both files
+	seen := map[string]bool{}
+	reloaded := false
+	timeout := time.After(testTimeout)
+	for !seen[other] || !seen[config] || !reloaded {
+		select {
+		case e := <-w.Events:
+			seen[e.Path] = true
+		case content := <-reloads:
+			reloaded = content == `{"level":"debug"}`
+		case <-timeout:
+			t.Fatalf("events of %v, reloaded %v", seen, reloaded)
+		}
+	}
+}
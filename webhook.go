@@ -0,0 +1,95 @@
+package fileWatcher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// WebhookSink posts every event as JSON to a URL, see WireEvent for the schema.
+type WebhookSink struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+	// Template formats the body instead of the wire schema, like SlackEventTemplate, see ParseEventTemplate.
+	Template *template.Template
+	// ContentType is the content type of the body formatted with Template. It defaults to application/json.
+	ContentType string
+	// Signer signs the events, in the sig field of the wire schema, see SignEvent, and the body in the
+	// SignatureHeader, which also covers the bodies formatted with Template.
+	Signer *EventSigner
+}
+
+// NewWebhookSink creates a WebhookSink posting to url.
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *WebhookSink) Send(e FileWatcherEvent) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	body, err := s.body(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Template != nil && s.ContentType != "" {
+		req.Header.Set("Content-Type", s.ContentType)
+	}
+	if e.Key != "" {
+		// lets the receiver drop the deliveries retried after a timeout
+		req.Header.Set("Idempotency-Key", e.Key)
+	}
+	if e.Trace.TraceParent != "" {
+		// the receiver continues the trace of the operation that changed the file
+		req.Header.Set("traceparent", e.Trace.TraceParent)
+		if e.Trace.TraceState != "" {
+			req.Header.Set("tracestate", e.Trace.TraceState)
+		}
+	}
+	if s.Signer != nil {
+		req.Header.Set(SignatureHeader, s.Signer.header(body))
+	}
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s answered with status %d", s.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// body formats e with the template of the sink, or in the wire schema.
+func (s *WebhookSink) body(e FileWatcherEvent) ([]byte, error) {
+	if s.Template == nil && s.Signer != nil {
+		return SignEvent(e, s.Signer)
+	}
+	if s.Template == nil {
+		return EncodeEvent(e)
+	}
+	return FormatEvent(s.Template, e)
+}